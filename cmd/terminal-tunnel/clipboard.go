@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// errNoClipboard is returned when no clipboard tool is usable (e.g. headless host)
+var errNoClipboard = errors.New("no clipboard available")
+
+// clipboardCommands returns candidate copy commands for the current platform, in preference order
+func clipboardCommands() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbcopy"}}
+	case "windows":
+		return [][]string{{"clip"}}
+	default:
+		var cmds [][]string
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			cmds = append(cmds, []string{"wl-copy"})
+		}
+		if os.Getenv("DISPLAY") != "" {
+			cmds = append(cmds,
+				[]string{"xclip", "-selection", "clipboard"},
+				[]string{"xsel", "--clipboard", "--input"})
+		}
+		return cmds
+	}
+}
+
+// copyToClipboard writes text to the system clipboard using the first available tool
+func copyToClipboard(text string) error {
+	for _, args := range clipboardCommands() {
+		path, err := exec.LookPath(args[0])
+		if err != nil {
+			continue
+		}
+		cmd := exec.Command(path, args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if err := cmd.Run(); err == nil {
+			return nil
+		}
+	}
+	return errNoClipboard
+}
+
+// printCopyURL copies url to the clipboard and reports the outcome
+func printCopyURL(url string) {
+	if err := copyToClipboard(url); err != nil {
+		fmt.Printf("  (clipboard unavailable, URL not copied)\n")
+		return
+	}
+	fmt.Printf("  URL copied to clipboard\n")
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/artpar/terminal-tunnel/internal/crypto"
+	"github.com/artpar/terminal-tunnel/internal/sink"
+)
+
+// resolveCommand splits --command into a program and its arguments. The
+// program is run directly rather than through a shell, so the split is on
+// whitespace only: quotes, globs, pipes and $VARS are passed through
+// literally. Returns nil when no command was given. tt share runs
+// tail -f on its file instead, and --tmux and --screen attach to a session.
+func resolveCommand() ([]string, error) {
+	if shareFile != "" {
+		return []string{"tail", "-f", shareFile}, nil
+	}
+	if args, err := resolveMultiplexer(); args != nil || err != nil {
+		return args, err
+	}
+	if command == "" {
+		return nil, nil
+	}
+	if shell != "" {
+		return nil, fmt.Errorf("--command and --shell cannot be used together")
+	}
+
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("--command is empty")
+	}
+	return args, nil
+}
+
+// resolveContainerRuntime splits --container-runtime into a command prefix,
+// on whitespace only like --command. Returns nil when none was given.
+func resolveContainerRuntime() ([]string, error) {
+	if containerRT == "" {
+		return nil, nil
+	}
+	if isolate {
+		return nil, fmt.Errorf("--isolate and --container-runtime cannot be used together")
+	}
+	if shell != "" {
+		return nil, fmt.Errorf("--shell has no effect in a container: use --command to choose the program")
+	}
+
+	args := strings.Fields(containerRT)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("--container-runtime is empty")
+	}
+	return args, nil
+}
+
+// maxTermSize bounds each dimension of --size
+const maxTermSize = 1000
+
+// resolveSize parses --size as COLSxROWS, e.g. 120x40. Returns zeros when
+// none was given, for the default size.
+func resolveSize() (rows, cols uint16, err error) {
+	if termSize == "" {
+		return 0, 0, nil
+	}
+	c, r, ok := strings.Cut(strings.ToLower(termSize), "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid --size %q: want COLSxROWS, e.g. 120x40", termSize)
+	}
+	colsN, errC := strconv.Atoi(c)
+	rowsN, errR := strconv.Atoi(r)
+	if errC != nil || errR != nil || colsN < 1 || rowsN < 1 || colsN > maxTermSize || rowsN > maxTermSize {
+		return 0, 0, fmt.Errorf("invalid --size %q: want COLSxROWS, each 1-%d", termSize, maxTermSize)
+	}
+	return uint16(rowsN), uint16(colsN), nil
+}
+
+// resolveGeoIP checks the --geoip databases exist and makes their paths
+// absolute, since the daemon opens them from its own working directory
+func resolveGeoIP() ([]string, error) {
+	var files []string
+	for _, f := range geoIPFiles {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --geoip %q: %w", f, err)
+		}
+		if _, err := os.Stat(abs); err != nil {
+			return nil, fmt.Errorf("invalid --geoip: %w", err)
+		}
+		files = append(files, abs)
+	}
+	return files, nil
+}
+
+// resolveOutputSink checks --output-sink, making a pipe path absolute since
+// the daemon runs from another directory
+func resolveOutputSink() (string, error) {
+	if outputSink == "" {
+		return "", nil
+	}
+	if err := sink.Validate(outputSink); err != nil {
+		return "", fmt.Errorf("invalid --output-sink: %w", err)
+	}
+	if path, ok := strings.CutPrefix(outputSink, "pipe:"); ok {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return "", fmt.Errorf("invalid --output-sink: %w", err)
+		}
+		return "pipe:" + abs, nil
+	}
+	return outputSink, nil
+}
+
+// resolveKDF parses --kdf
+func resolveKDF() (crypto.KDF, error) {
+	kdf, err := crypto.ParseKDF(kdfName)
+	if err != nil {
+		return kdf, fmt.Errorf("invalid --kdf: %w", err)
+	}
+	return kdf, nil
+}
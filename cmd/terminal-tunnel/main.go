@@ -1,15 +1,27 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"crypto/rand"
 	"encoding/base32"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"text/tabwriter"
 	"time"
@@ -20,9 +32,13 @@ import (
 
 	"github.com/artpar/terminal-tunnel/internal/client"
 	"github.com/artpar/terminal-tunnel/internal/daemon"
+	"github.com/artpar/terminal-tunnel/internal/paths"
+	"github.com/artpar/terminal-tunnel/internal/protocol"
 	"github.com/artpar/terminal-tunnel/internal/recording"
 	"github.com/artpar/terminal-tunnel/internal/server"
+	"github.com/artpar/terminal-tunnel/internal/signaling"
 	"github.com/artpar/terminal-tunnel/internal/signaling/relayserver"
+	"github.com/artpar/terminal-tunnel/internal/web"
 )
 
 // setSysProcAttr is defined in daemon_unix.go and daemon_windows.go
@@ -54,12 +70,34 @@ Example:
   tt start -p mysecret # Start a session
   tt list              # List all sessions
   tt stop <code>       # Stop a session
-  tt daemon stop       # Stop the daemon`,
-	Version: version,
+  tt daemon stop       # Stop the daemon
+
+Data (recordings, session state, the daemon's PID file and socket) lives in
+~/.tt, or $XDG_DATA_HOME/tt on Linux if ~/.tt doesn't exist. Set TT_HOME or
+--data-dir to use another directory.`,
+	Version:           version,
+	PersistentPreRunE: applyDataDir,
 }
 
+// dataDir overrides the data directory, see paths.DataDir
+var dataDir string
+
 func init() {
 	rootCmd.SetVersionTemplate(fmt.Sprintf("tt version %s\ncommit: %s\nbuilt: %s\n", version, commit, date))
+	rootCmd.PersistentFlags().StringVar(&dataDir, "data-dir", "", "Directory for recordings, session state and the daemon socket (default ~/.tt, or $TT_HOME)")
+}
+
+// applyDataDir exports --data-dir as TT_HOME, so the daemon started from this
+// process uses the same directory
+func applyDataDir(cmd *cobra.Command, args []string) error {
+	if dataDir == "" {
+		return nil
+	}
+	dir, err := filepath.Abs(dataDir)
+	if err != nil {
+		return fmt.Errorf("invalid --data-dir: %w", err)
+	}
+	return os.Setenv(paths.EnvHome, dir)
 }
 
 // Daemon commands
@@ -103,8 +141,26 @@ Use --detach (-d) for background mode via daemon.`,
 	RunE: runStart,
 }
 
+var shareCmd = &cobra.Command{
+	Use:   "share <file>",
+	Short: "Share a file's tail as a read-only session",
+	Long: `Stream a file, typically a log, to a read-only session: shorthand for
+
+  tt start --command "tail -f <file>" --read-only --public
+
+The client and public viewers watch new lines as they are written but
+cannot type. The terminal follows the client's window size. Press Ctrl+C
+to stop sharing, or use --detach to share in the background.
+
+Example:
+  tt share /var/log/app.log
+  tt share -d --name applog /var/log/app.log`,
+	Args: cobra.ExactArgs(1),
+	RunE: runShare,
+}
+
 var stopCmd = &cobra.Command{
-	Use:   "stop <id|code>",
+	Use:   "stop <id|code|name>",
 	Short: "Stop a terminal session",
 	Args:  cobra.ExactArgs(1),
 	RunE:  runStop,
@@ -116,6 +172,124 @@ var listCmd = &cobra.Command{
 	RunE:  runList,
 }
 
+var infoCmd = &cobra.Command{
+	Use:   "info <id|code|name>",
+	Short: "Show full details of a terminal session",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runInfo,
+}
+
+var markCmd = &cobra.Command{
+	Use:   "mark <id|code|name> [label]",
+	Short: "Add a marker to a session recording",
+	Long: `Add a marker to the recording of a session started with --record.
+
+Markers annotate key moments and act as chapters during playback:
+tt play jumps between them with ] and [, and tt play --markers lists them.
+
+Example:
+  tt mark ABC123 "deploy started"`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runMark,
+}
+
+var dumpCmd = &cobra.Command{
+	Use:   "dump <id|code|name> <path>",
+	Short: "Copy a session's recording so far to a file",
+	Long: `Copy the recording of a session started with --record, up to now, to
+path. Recording continues. The copy is a complete asciicast that tt play
+and other players read, and path is replaced atomically, so a script can
+dump a long-running session periodically without readers seeing a
+partial file.
+
+Example:
+  tt dump ABC123 /tmp/deploy-so-far.cast`,
+	Args: cobra.ExactArgs(2),
+	RunE: runDump,
+}
+
+var pauseCmd = &cobra.Command{
+	Use:   "pause <id|code|name>",
+	Short: "Pause sharing a session's output without disconnecting",
+	Long: `Stop sending a detached session's output to its client and viewers,
+e.g. while typing something private, until tt resume. Clients stay
+connected and see a notice; input still reaches the shell.
+
+Output produced while paused is sent when sharing resumes, so clear the
+screen first if it shows something the client should not see.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPause,
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume <id|code|name>",
+	Short: "Resume sharing a paused session's output",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runPause,
+}
+
+var grantCmd = &cobra.Command{
+	Use:   "grant <id|code|name> [viewer]",
+	Short: "Let a session's public viewer type",
+	Long: `Let a public viewer of a detached session started with --public type
+into the shell, e.g. to hand a student the keyboard, until tt revoke. The
+client and the host can still type too.
+
+Viewers are numbered from 1 in the order they joined. With one viewer
+connected, there is no need to name it. One viewer can type at a time, so
+granting another moves the grant; it also ends when the viewer disconnects.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runGrant,
+}
+
+var revokeCmd = &cobra.Command{
+	Use:   "revoke <id|code|name>",
+	Short: "Make a session's public viewer read-only again",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runGrant,
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats <id|code|name>",
+	Short: "Show WebRTC transport stats of a live session",
+	Long: `Show WebRTC transport stats of a session's client connection: bytes
+on the wire, round trip time, ICE check loss and the candidate pair in use.
+Stats are sampled every few seconds while a client is connected.
+
+Use --verbose for ICE and DTLS state and raw counters.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runStats,
+}
+
+var whoCmd = &cobra.Command{
+	Use:   "who <id|code|name>",
+	Short: "Show who is connected to a session",
+	Long: `Show the client and public viewers connected to a session right now,
+with the address each connects from and when it connected. Useful to spot
+a leaked code, especially on a --public session.
+
+Addresses are the remote side of each connection's ICE candidate pair. For
+a connection relayed through TURN ("relay"), that is the peer's TURN
+server, not the peer itself.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runWho,
+}
+
+var qrCmd = &cobra.Command{
+	Use:   "qr <id|code|name>",
+	Short: "Show the QR code of a running session",
+	Long: `Show the QR code for a detached session's client URL again, e.g. to
+open it on another device after the terminal that started it was closed.
+
+Use --viewer for a --public session's read-only viewer link instead, and
+--png to also write the code as a PNG image.
+
+Example:
+  tt qr ABC123 --png /tmp/session.png`,
+	Args: cobra.ExactArgs(1),
+	RunE: runQR,
+}
+
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show daemon and session status",
@@ -134,31 +308,71 @@ to exchange connection information without direct connectivity.
 The relay only handles SDP signaling (~2KB per connection).
 All terminal traffic goes directly peer-to-peer after connection.
 
+With --with-web the relay also serves the web client at "/", so a single
+port provides the complete self-hosted stack.
+
 Example:
-  tt relay --port 8765`,
+  tt relay --port 8765
+  tt relay --port 8765 --with-web`,
 	RunE: runRelay,
 }
 
+var serveWebCmd = &cobra.Command{
+	Use:   "serve-web",
+	Short: "Serve the web client",
+	Long: `Serve the web client embedded in this binary over HTTP.
+
+Use this to self-host the client instead of relying on GitHub Pages.
+Point hosts at it with TT_CLIENT_URL (and relays with --public-url)
+so session links open your copy.
+
+Example:
+  tt serve-web --port 8080
+  TT_CLIENT_URL=https://tt.example.com tt start`,
+	RunE: runServeWeb,
+}
+
 // Recording commands
 var playCmd = &cobra.Command{
 	Use:   "play <file>",
 	Short: "Play back a recorded session",
 	Long: `Play back a previously recorded terminal session.
 
-Recordings are stored in ~/.tt/recordings/ in asciicast v2 format
+Recordings are stored in recordings/ under the data directory (~/.tt by
+default, see --data-dir) in asciicast v2 format
 and can be played with this command or with asciinema.
 
+During playback press ] and [ to jump to the next and previous marker
+(see tt mark), and q to quit.
+
 Example:
   tt play ~/.tt/recordings/2024-01-01_12-00-00_ABC123.cast
-  tt play --speed 2 recording.cast`,
+  tt play --speed 2 recording.cast
+  tt play --markers recording.cast`,
 	Args: cobra.ExactArgs(1),
 	RunE: runPlay,
 }
 
+var convertCmd = &cobra.Command{
+	Use:   "convert <in.cast> <out>",
+	Short: "Convert a recording to another format",
+	Long: `Convert an asciicast recording to another format, chosen by the output
+file's extension:
+
+  .ttyrec, .tty   ttyrec, for ttyplay and other ttyrec tools (output only:
+                  input, resizes and markers are left out)
+
+Example:
+  tt convert recording.cast recording.ttyrec
+  ttyplay recording.ttyrec`,
+	Args: cobra.ExactArgs(2),
+	RunE: runConvert,
+}
+
 var recordingsCmd = &cobra.Command{
 	Use:   "recordings",
 	Short: "List recorded sessions",
-	Long:  `List all recorded terminal sessions in ~/.tt/recordings/`,
+	Long:  `List all recorded terminal sessions in the recordings directory (~/.tt/recordings/ by default)`,
 	RunE:  runRecordings,
 }
 
@@ -169,13 +383,78 @@ var (
 	noTURN   bool
 	public   bool
 	record   bool
-	detach   bool // Run in background via daemon
+	detach   bool     // Run in background via daemon
+	copyURL  bool     // Copy client URL to clipboard when ready
+	name     string   // Friendly session name (daemon mode)
+	tags     []string // key=value labels (daemon mode)
+
+	guardBinary    bool          // Pause streaming on binary output
+	noViewerRec    bool          // Ask public viewers not to offer recording
+	noJoinNotices  bool          // Don't tell the client when viewers join or leave
+	command        string        // Run this program instead of a shell
+	tmuxSession    string        // Attach to this tmux session instead of a shell
+	screenSession  string        // Attach to this screen session instead of a shell
+	waitTimeout    time.Duration // Give up if no client connects in time (interactive)
+	passwordWords  int           // Generate a passphrase of this many words
+	passwordPolicy int           // Minimum password entropy in bits (0 = off)
+	maxPerIP       int           // Public viewers allowed from one address (0 = no limit)
+	debugBundle    string        // Write connection diagnostics here on exit
+	isolate        bool          // Run the shell in new Linux namespaces
+	containerRT    string        // Start the session in a container with this command prefix
+	coalesce       time.Duration // Hold output this long to merge small writes (0 = off)
+	confirmClient  bool          // Ask before letting the first client in (interactive)
+	offerLifetime  time.Duration // How long a manual-mode offer is accepted (0 = no expiry)
+	offerNonce     bool          // Bind the manual-mode answer to the offer with a nonce
+	notifyBell     bool          // Forward terminal bells to clients
+	lan            bool          // Also signal directly on the local network
+	wsFallback     bool          // Let clients fall back to a WebSocket through the relay
+	printOnly      bool          // Print only the session details as JSON (detached)
+	waitClient     bool          // Return once a client connects (detached)
+	detachOnTerm   bool          // Hand the session to the daemon on SIGTERM (interactive)
+	surviveHangup  bool          // Keep serving clients after losing the terminal (interactive)
+	signalingList  string        // Signaling methods to try in order (interactive)
+	noStandby      bool          // Reconnect without a standby peer (interactive)
+	vanityCode     string        // Ask the relay for this session code
+	termSize       string        // Initial PTY size, COLSxROWS
+	geoIPFiles     []string      // MaxMind DB files for where clients connect from
+	readOnly       bool          // Drop the client's input
+	shareFile      string        // File tt share streams with tail -f
+	kdfName        string        // Password KDF for new sessions
+	outputSink     string        // Also stream output to this syslog, webhook or pipe
+
+	// List flags
+	listFilters []string
+
+	// Info flags
+	infoJSON   bool
+	infoStates bool
+
+	// Stats flags
+	statsVerbose bool
+
+	// Who flags
+	whoJSON bool
+
+	// QR flags
+	qrPNG    string // Also write the QR code to this PNG file
+	qrViewer bool   // Show the public viewer link instead of the client's
 
 	// Relay flags
-	relayPort int
+	relayPort         int
+	relayBind         string        // Address to listen on (empty = all interfaces)
+	relayPublicURL    string        // Web client URL used to build session links
+	relayWithWeb      bool          // Also serve the web client
+	relayTrusted      []string      // Networks exempt from rate limiting
+	relayWriteTimeout time.Duration // Deadline for each WebSocket write
+
+	// Serve-web flags
+	webPort int
+	webBind string // Address to listen on (empty = all interfaces)
 
 	// Play flags
-	playSpeed float64
+	playSpeed   float64
+	playFollow  bool
+	playMarkers bool // List markers instead of playing
 )
 
 func init() {
@@ -187,30 +466,109 @@ func init() {
 
 	// Session commands
 	rootCmd.AddCommand(startCmd)
+	rootCmd.AddCommand(shareCmd)
 	rootCmd.AddCommand(stopCmd)
 	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(infoCmd)
+	rootCmd.AddCommand(markCmd)
+	rootCmd.AddCommand(dumpCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(whoCmd)
+	rootCmd.AddCommand(qrCmd)
+	rootCmd.AddCommand(pauseCmd)
+	rootCmd.AddCommand(resumeCmd)
+	rootCmd.AddCommand(grantCmd)
+	rootCmd.AddCommand(revokeCmd)
 	rootCmd.AddCommand(statusCmd)
 
 	// Relay command
 	rootCmd.AddCommand(relayCmd)
+	rootCmd.AddCommand(serveWebCmd)
 
 	// Recording commands
 	rootCmd.AddCommand(playCmd)
+	rootCmd.AddCommand(convertCmd)
 	rootCmd.AddCommand(recordingsCmd)
 
 	// Start command flags
 	startCmd.Flags().StringVarP(&password, "password", "p", "", "Session password (auto-generated if not provided)")
-	startCmd.Flags().StringVarP(&shell, "shell", "s", "", "Shell to run (default: $SHELL or /bin/sh)")
-	startCmd.Flags().BoolVar(&noTURN, "no-turn", false, "Disable TURN relay (P2P only, may fail with symmetric NAT)")
+	startCmd.Flags().IntVar(&passwordWords, "password-words", 0, "Generate a memorable passphrase of this many words (10 bits each) instead of a random password")
+	startCmd.Flags().IntVar(&passwordPolicy, "password-policy", 0, "Minimum estimated password entropy in bits (0 = length check only)")
+	startCmd.Flags().StringVarP(&shell, "shell", "s", "", "Shell to run (default: $SHELL or your login shell; pwsh or PowerShell on Windows)")
+	startCmd.Flags().StringVar(&command, "command", "", "Run a single program instead of a shell, e.g. \"htop -d 5\" (no shell: quotes and $VARS are not interpreted)")
+	startCmd.Flags().StringVar(&tmuxSession, "tmux", "", "Share this existing tmux session instead of a shell (tmux attach -t <session>)")
+	startCmd.Flags().StringVar(&screenSession, "screen", "", "Share this existing screen session instead of a shell (screen -x <session>)")
+	startCmd.Flags().BoolVar(&noTURN, "no-turn", server.DefaultOptions().NoTURN, "Disable TURN relay (P2P only, may fail with symmetric NAT; default from TT_NO_TURN)")
 	startCmd.Flags().BoolVar(&public, "public", false, "Enable public viewer mode (read-only viewers without password)")
+	startCmd.Flags().BoolVar(&readOnly, "read-only", false, "Let the client watch but not type (local input still works; use with --public for read-only viewers too)")
+	startCmd.Flags().BoolVar(&noViewerRec, "no-viewer-recording", false, "Hide the web client's record button from public viewers (advisory: viewers can still capture what they see)")
+	startCmd.Flags().BoolVar(&noJoinNotices, "no-join-notices", false, "Don't show the client a notice when public viewers join or leave")
+	startCmd.Flags().IntVar(&maxPerIP, "max-viewers-per-ip", server.DefaultMaxViewersPerIP, "Turn away public viewers beyond this many from one address (0 = no limit; viewers relayed through TURN share their TURN server's address)")
 	startCmd.Flags().BoolVar(&record, "record", false, "Record session to ~/.tt/recordings/")
+	startCmd.Flags().StringVar(&outputSink, "output-sink", "", "Also stream output to syslog://host[:port], syslog+tcp://host[:port], an http(s) webhook URL or pipe:/path (batched; output is dropped rather than stalling the session)")
 	startCmd.Flags().BoolVarP(&detach, "detach", "d", false, "Run session in background (via daemon)")
+	startCmd.Flags().BoolVar(&copyURL, "copy", false, "Copy the client URL to the clipboard")
+	startCmd.Flags().BoolVar(&guardBinary, "guard-binary", false, "Pause streaming and warn the client when binary output is detected")
+	startCmd.Flags().DurationVar(&waitTimeout, "wait-timeout", 0, "End an interactive session if no client connects within this time, e.g. 10m (0 = wait forever; reconnects always wait). With --detach --wait, stop waiting after this long and fail")
+	startCmd.Flags().BoolVar(&waitClient, "wait", false, "Return only once a client has connected, for scripts that go on after someone joins (with --detach; see --wait-timeout)")
+	startCmd.Flags().DurationVar(&offerLifetime, "offer-lifetime", signaling.DefaultOfferLifetime, "Reject answers to a manual-mode (QR/copy-paste) offer after this long, so a leaked code stops working (0 = never; interactive)")
+	startCmd.Flags().BoolVar(&offerNonce, "offer-nonce", false, "Put a random nonce in a manual-mode offer and accept only one answer that echoes it, refusing stale or replayed answers (needs a client that supports it; interactive)")
+	startCmd.Flags().BoolVar(&confirmClient, "confirm-client", false, "Ask before letting the first client use the shell, even with the right password (interactive)")
+	startCmd.Flags().StringVar(&debugBundle, "debug-bundle", "", "Write connection diagnostics (redacted SDPs, ICE candidates and states, timings) as JSON to this file on exit, for bug reports")
+	startCmd.Flags().BoolVar(&isolate, "isolate", false, "Run the shell in new user/mount/PID namespaces so it cannot see or signal host processes (Linux only; shares the filesystem)")
+	startCmd.Flags().StringVar(&containerRT, "container-runtime", "", "Start the session in a container with this command prefix, e.g. \"docker run --rm -it alpine\" (--command is appended)")
+	startCmd.Flags().DurationVar(&coalesce, "coalesce", 5*time.Millisecond, "Hold shell output this long to merge small writes into one message (0 = send every write immediately, for the lowest latency)")
+	startCmd.Flags().BoolVar(&notifyBell, "notify-bell", false, "Forward terminal bells so the web client can show a notification while its tab is in the background")
+	startCmd.Flags().BoolVar(&lan, "lan", false, "Also serve signaling on the local network, so clients on the same network can connect without the relay (and keep working if it is down)")
+	startCmd.Flags().BoolVar(&wsFallback, "allow-ws-fallback", false, "Let clients that can't connect over WebRTC, even through TURN, carry the session over a WebSocket through the relay (end-to-end encrypted, but all traffic then passes through the relay; needs a tt relay)")
+	startCmd.Flags().StringVar(&termSize, "size", "", "Start the PTY at this size, COLSxROWS e.g. 120x40, until a client reports its own (default 80x24)")
+	startCmd.Flags().StringVar(&kdfName, "kdf", "default", "Password KDF: default (Argon2id, PBKDF2 for browsers without it), argon2id, scrypt or pbkdf2 (for FIPS environments)")
+	startCmd.Flags().StringArrayVar(&geoIPFiles, "geoip", nil, "Show where clients connect from using this offline MaxMind DB, e.g. GeoLite2-City.mmdb (repeatable: add GeoLite2-ASN.mmdb for the network)")
+	startCmd.Flags().BoolVar(&detachOnTerm, "detach-on-term", false, "On SIGTERM, hand the running shell to the daemon instead of ending the session (interactive, not on Windows)")
+	startCmd.Flags().StringVar(&signalingList, "signaling", "", "Signaling methods to try in order until one works: relay, websocket, upnp, manual, e.g. upnp,relay,manual (default relay,manual; interactive)")
+	startCmd.Flags().BoolVar(&noStandby, "no-standby", false, "Don't keep a standby peer for instant reconnection; reconnects wait for a fresh offer instead, which is slower but simpler (for diagnosing reconnection problems; interactive)")
+	startCmd.Flags().BoolVar(&surviveHangup, "survive-hangup", false, "Keep the session running for its clients if this terminal goes away, e.g. an SSH connection to the host drops (interactive, not on Windows)")
+	startCmd.Flags().BoolVar(&printOnly, "print-only", false, "Print only the session details as JSON, for scripts, e.g. tt start -d --print-only | jq -r .short_code (with --detach)")
+	startCmd.Flags().StringVar(&name, "name", "", "Name the session so it can be stopped by name (with --detach); not all upper case, like a code")
+	startCmd.Flags().StringVar(&vanityCode, "code", "", "Ask the relay for this session code, e.g. team-demo, instead of a generated one: 8-32 letters, digits and hyphens, not ending in V. Easier to guess, so use a strong password")
+	startCmd.Flags().StringArrayVar(&tags, "tag", nil, "Label the session with key=value for tt list --filter, e.g. env=prod (with --detach, repeatable)")
+
+	// Share command flags (shared with tt start)
+	shareCmd.Flags().StringVarP(&password, "password", "p", "", "Session password (auto-generated if not provided)")
+	shareCmd.Flags().BoolVarP(&detach, "detach", "d", false, "Share in the background (via daemon)")
+	shareCmd.Flags().StringVar(&name, "name", "", "Name the session so it can be stopped by name (with --detach)")
+	shareCmd.Flags().BoolVar(&copyURL, "copy", false, "Copy the client URL to the clipboard")
+	shareCmd.Flags().BoolVar(&record, "record", false, "Record session to ~/.tt/recordings/")
+	shareCmd.Flags().BoolVar(&lan, "lan", false, "Also serve signaling on the local network")
+	shareCmd.Flags().StringVar(&outputSink, "output-sink", "", "Also stream output to syslog://host[:port], syslog+tcp://host[:port], an http(s) webhook URL or pipe:/path (batched; output is dropped rather than stalling the session)")
+
+	// List command flags
+	listCmd.Flags().StringArrayVar(&listFilters, "filter", nil, "Only show matching sessions: status=<status>, name=<name>, code=<code> or tag:<key>=<value> (repeatable, all must match)")
+
+	// Info command flags
+	infoCmd.Flags().BoolVar(&infoJSON, "json", false, "Print session details as JSON")
+	infoCmd.Flags().BoolVar(&infoStates, "states", false, "Also show the client connection's recent peer and ICE state changes")
+	statsCmd.Flags().BoolVarP(&statsVerbose, "verbose", "v", false, "Also show ICE/DTLS state and raw counters")
+	whoCmd.Flags().BoolVar(&whoJSON, "json", false, "Print connections as JSON")
+	qrCmd.Flags().StringVar(&qrPNG, "png", "", "Also write the QR code as a PNG image to this file")
+	qrCmd.Flags().BoolVar(&qrViewer, "viewer", false, "Show the read-only viewer link of a --public session instead")
 
 	// Relay command flags
 	relayCmd.Flags().IntVar(&relayPort, "port", 8765, "Port to listen on for WebSocket connections")
+	relayCmd.Flags().StringVar(&relayBind, "bind", "", "Address to listen on, e.g. 127.0.0.1 (default: all interfaces)")
+	relayCmd.Flags().StringVar(&relayPublicURL, "public-url", "", "Web client URL returned in session links (e.g. https://tt.example.com)")
+	relayCmd.Flags().BoolVar(&relayWithWeb, "with-web", false, "Also serve the web client at / (session links default to this relay)")
+	relayCmd.Flags().StringArrayVar(&relayTrusted, "trusted-cidr", nil, "Exempt connections from this network from rate limiting, e.g. 10.0.0.0/8 (repeatable)")
+	relayCmd.Flags().DurationVar(&relayWriteTimeout, "write-timeout", 10*time.Second, "Close a WebSocket whose peer doesn't take a message within this long")
+
+	// Serve-web command flags
+	serveWebCmd.Flags().IntVar(&webPort, "port", 8080, "Port to serve the web client on")
+	serveWebCmd.Flags().StringVar(&webBind, "bind", "", "Address to listen on, e.g. 127.0.0.1 (default: all interfaces)")
 
 	// Play command flags
 	playCmd.Flags().Float64Var(&playSpeed, "speed", 1.0, "Playback speed (e.g., 2.0 for 2x speed)")
+	playCmd.Flags().BoolVarP(&playFollow, "follow", "f", false, "Keep playing new events while the session is still recording")
+	playCmd.Flags().BoolVar(&playMarkers, "markers", false, "List the recording's markers with timestamps instead of playing")
 }
 
 func runDaemonStart(cmd *cobra.Command, args []string) error {
@@ -289,40 +647,210 @@ func runDaemonForeground(cmd *cobra.Command, args []string) error {
 }
 
 func runStart(cmd *cobra.Command, args []string) error {
+	if vanityCode != "" {
+		code, err := signaling.NormalizeRequestedCode(vanityCode)
+		if err != nil {
+			return fmt.Errorf("--code: %w", err)
+		}
+		vanityCode = code
+	}
+
 	// If detach mode, use daemon
 	if detach {
+		if waitTimeout > 0 && !waitClient {
+			return fmt.Errorf("--wait-timeout needs --wait with --detach")
+		}
+		if debugBundle != "" {
+			return fmt.Errorf("--debug-bundle is only supported for interactive sessions")
+		}
+		if confirmClient {
+			return fmt.Errorf("--confirm-client is only supported for interactive sessions")
+		}
+		if detachOnTerm {
+			return fmt.Errorf("--detach-on-term is only supported for interactive sessions")
+		}
+		if surviveHangup {
+			return fmt.Errorf("--survive-hangup is only supported for interactive sessions")
+		}
+		if signalingList != "" {
+			return fmt.Errorf("--signaling is only supported for interactive sessions (detached sessions use the relay)")
+		}
+		if noStandby {
+			return fmt.Errorf("--no-standby is only supported for interactive sessions")
+		}
+		if printOnly && copyURL {
+			return fmt.Errorf("--print-only cannot be used with --copy")
+		}
 		return runStartDetached()
 	}
+	if len(tags) > 0 {
+		return fmt.Errorf("--tag is only supported for detached sessions (--detach)")
+	}
+	if printOnly {
+		return fmt.Errorf("--print-only is only supported for detached sessions (--detach)")
+	}
+	if waitClient {
+		return fmt.Errorf("--wait is only supported for detached sessions (--detach); interactive sessions wait already")
+	}
+	if detachOnTerm && runtime.GOOS == "windows" {
+		return server.ErrHandoffUnsupported
+	}
+	if surviveHangup && runtime.GOOS == "windows" {
+		return fmt.Errorf("--survive-hangup is not supported on Windows")
+	}
 
 	// Interactive mode - run server directly
 	return runStartInteractive()
 }
 
+// runShare runs tt share: a read-only, public tt start running tail -f
+func runShare(cmd *cobra.Command, args []string) error {
+	path, err := filepath.Abs(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid file: %w", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("cannot share %s: %w", args[0], err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("cannot share %s: is a directory", args[0])
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot share %s: %w", args[0], err)
+	}
+	f.Close()
+	if _, err := exec.LookPath("tail"); err != nil {
+		return fmt.Errorf("tt share needs tail: %w", err)
+	}
+
+	shareFile = path
+	readOnly = true
+	public = true
+	return runStart(cmd, nil)
+}
+
 // runStartDetached runs session via daemon (background mode)
 func runStartDetached() error {
 	c := client.NewClient()
 
 	// Check if daemon is running
 	if !c.IsDaemonRunning() {
+		if printOnly {
+			// Scripts need a failing exit status, not a message on stdout
+			return fmt.Errorf("daemon is not running, start it with: tt daemon start")
+		}
 		fmt.Println("Daemon is not running. Start it with: tt daemon start")
 		return nil
 	}
 
-	result, err := c.StartSession(password, shell, noTURN, public, record)
+	sessionPassword, err := resolvePassword()
+	if err != nil {
+		return err
+	}
+
+	commandArgs, err := resolveCommand()
+	if err != nil {
+		return err
+	}
+
+	sessionTags, err := resolveTags()
+	if err != nil {
+		return err
+	}
+
+	runtimeArgs, err := resolveContainerRuntime()
+	if err != nil {
+		return err
+	}
+
+	rows, cols, err := resolveSize()
+	if err != nil {
+		return err
+	}
+
+	geoIP, err := resolveGeoIP()
+	if err != nil {
+		return err
+	}
+
+	kdf, err := resolveKDF()
+	if err != nil {
+		return err
+	}
+
+	sinkSpec, err := resolveOutputSink()
+	if err != nil {
+		return err
+	}
+
+	result, err := c.StartSession(daemon.StartSessionParams{
+		Password:    sessionPassword,
+		Shell:       shell,
+		NoTURN:      noTURN,
+		Public:      public,
+		Record:      record,
+		Name:        name,
+		Code:        vanityCode,
+		Tags:        sessionTags,
+		GuardBinary: guardBinary,
+		OutputSink:  sinkSpec,
+		Command:     commandArgs,
+		ReadOnly:    readOnly,
+
+		NoViewerRecording: noViewerRec,
+		NoJoinNotices:     noJoinNotices,
+		MaxViewersPerIP:   viewersPerIP(),
+		Isolate:           isolate,
+		ContainerRuntime:  runtimeArgs,
+		CoalesceWindow:    coalesceWindow(),
+		BellNotify:        notifyBell,
+		LAN:               lan,
+		AllowWSFallback:   wsFallback,
+		Rows:              rows,
+		Cols:              cols,
+		GeoIP:             geoIP,
+		KDF:               kdf.String(),
+	})
 	if err != nil {
 		return fmt.Errorf("failed to start session: %w", err)
 	}
 
+	if printOnly {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+		if waitClient {
+			return waitForClient(c, result)
+		}
+		return nil
+	}
+
 	fmt.Printf("\nSession started (detached):\n")
 	fmt.Printf("  Code:       %s\n", result.ShortCode)
+	if name != "" {
+		fmt.Printf("  Name:       %s\n", name)
+	}
+	if len(sessionTags) > 0 {
+		fmt.Printf("  Tags:       %s\n", formatTags(sessionTags))
+	}
 	fmt.Printf("  Password:   %s\n", result.Password)
 	if result.ClientURL != "" {
 		fmt.Printf("  URL:        %s\n", result.ClientURL)
 	}
+	if result.LANURL != "" && result.LANURL != result.ClientURL {
+		fmt.Printf("  LAN:        %s\n", result.LANURL)
+	}
 
 	if result.Public && result.ViewerCode != "" {
 		fmt.Printf("  Viewer:     %s (read-only)\n", result.ViewerCode)
 	}
+	if result.Fingerprint != "" {
+		fmt.Printf("  Fingerprint: %s\n", result.Fingerprint)
+	}
 	fmt.Println()
 
 	if result.ClientURL != "" {
@@ -332,31 +860,123 @@ func runStartDetached() error {
 		}
 	}
 
+	if copyURL && result.ClientURL != "" {
+		fmt.Println()
+		printCopyURL(result.ClientURL)
+	}
+
+	if waitClient {
+		fmt.Printf("\nWaiting for a client to connect...\n")
+		if err := waitForClient(c, result); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Client connected\n")
+	}
+
 	fmt.Printf("\nSession running in background. Use 'tt stop %s' to end.\n", result.ShortCode)
 	return nil
 }
 
+// waitForClient blocks until a client connects to a detached session
+// (--wait), failing after --wait-timeout. The session keeps running either
+// way.
+func waitForClient(c *client.Client, session *daemon.StartSessionResult) error {
+	result, err := c.WaitForClient(session.ID, waitTimeout)
+	if err != nil {
+		return fmt.Errorf("waiting for a client: %w", err)
+	}
+	if !result.Connected {
+		return fmt.Errorf("no client connected within %v (the session is still running; end it with 'tt stop %s')", waitTimeout, session.ShortCode)
+	}
+	return nil
+}
+
 // runStartInteractive runs session in foreground with attached terminal (SSH-like)
 func runStartInteractive() error {
-	// Generate password if not provided
-	sessionPassword := password
+	// Validate the password, generating one if not provided
+	sessionPassword, err := resolvePassword()
+	if err != nil {
+		return err
+	}
 	if sessionPassword == "" {
 		sessionPassword = generatePassword()
 	}
 
-	// Validate password length
-	if len(sessionPassword) < 12 {
-		return fmt.Errorf("password must be at least 12 characters")
+	commandArgs, err := resolveCommand()
+	if err != nil {
+		return err
+	}
+
+	runtimeArgs, err := resolveContainerRuntime()
+	if err != nil {
+		return err
+	}
+
+	rows, cols, err := resolveSize()
+	if err != nil {
+		return err
+	}
+
+	geoIP, err := resolveGeoIP()
+	if err != nil {
+		return err
+	}
+
+	kdf, err := resolveKDF()
+	if err != nil {
+		return err
+	}
+
+	sinkSpec, err := resolveOutputSink()
+	if err != nil {
+		return err
+	}
+
+	var signalingChain []signaling.SignalingMethod
+	if signalingList != "" {
+		if signalingChain, err = signaling.ParseMethods(signalingList); err != nil {
+			return err
+		}
 	}
 
 	// Create server options
 	opts := server.Options{
 		Password: sessionPassword,
 		Shell:    shell,
-		Timeout:  0, // No timeout for interactive
+		Timeout:  waitTimeout, // 0 waits for the first client indefinitely
 		NoTURN:   noTURN,
 		Public:   public,
 		Record:   record,
+		Command:  commandArgs,
+
+		OutputSink:  sinkSpec,
+		GuardBinary: guardBinary,
+		ReadOnly:    readOnly,
+
+		NoViewerRecording: noViewerRec,
+		NoJoinNotices:     noJoinNotices,
+		MaxViewersPerIP:   viewersPerIP(),
+
+		Isolate:          isolate,
+		ContainerRuntime: runtimeArgs,
+
+		CoalesceWindow: coalesceWindow(),
+		ConfirmClient:  confirmClient,
+		BellNotify:     notifyBell,
+		LAN:            lan,
+		Rows:           rows,
+		Cols:           cols,
+		GeoIP:          geoIP,
+		KDF:            kdf,
+		Signaling:      signalingChain,
+	}
+	opts.ManualOfferLifetime = offerLifetime
+	opts.ManualOfferNonce = offerNonce
+	opts.DisableStandby = noStandby
+	opts.Code = vanityCode
+	opts.AllowWSFallback = wsFallback
+	if offerLifetime <= 0 {
+		opts.ManualOfferLifetime = -1 // Never expires
 	}
 
 	// Create server
@@ -365,6 +985,19 @@ func runStartInteractive() error {
 		return fmt.Errorf("failed to create server: %w", err)
 	}
 
+	// Record connection details for a bug report, written however the session ends
+	var diag *server.Diagnostics
+	if debugBundle != "" {
+		diag = srv.EnableDiagnostics()
+		defer func() {
+			if err := diag.WriteFile(debugBundle); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				return
+			}
+			fmt.Printf("Debug bundle written to %s\n", debugBundle)
+		}()
+	}
+
 	// Track connection state
 	var shortCode string
 	_ = shortCode // Used in callbacks
@@ -378,9 +1011,26 @@ func runStartInteractive() error {
 	// Track the bridge for stdin forwarding
 	var currentBridge *server.Bridge
 
+	// While a client waits for approval (--confirm-client), the next
+	// keypress answers instead of going to the shell
+	var approvalMu sync.Mutex
+	var approval chan bool
+
+	// With --survive-hangup, losing the terminal (SIGHUP, or a read error
+	// on it) leaves the session serving its clients without local I/O
+	hangup := make(chan struct{}, 1)
+	var headless atomic.Bool
+
 	// Set callbacks
 	srv.SetCallbacks(server.Callbacks{
 		OnShortCodeReady: func(code, url string) {
+			// The relay dropped the session and it was re-registered: the
+			// shell is already running, so just show the new code
+			if shortCode != "" {
+				shortCode = code
+				fmt.Printf("\r\n⚠ Relay session expired - new code: %s\r\n  %s\r\n", code, url)
+				return
+			}
 			shortCode = code
 
 			// Clear screen and show connection info
@@ -390,7 +1040,8 @@ func runStartInteractive() error {
 			fmt.Printf("╠══════════════════════════════════════════════════╣\n")
 			fmt.Printf("║  Code:     %-38s║\n", code)
 			fmt.Printf("║  Password: %-38s║\n", sessionPassword)
-			fmt.Printf("╚══════════════════════════════════════════════════╝\n\n")
+			fmt.Printf("╚══════════════════════════════════════════════════╝\n")
+			fmt.Printf("  Fingerprint: %s\n\n", srv.Fingerprint())
 
 			if url != "" {
 				qr, _ := qrcode.New(url, qrcode.Low)
@@ -398,6 +1049,12 @@ func runStartInteractive() error {
 					fmt.Print(qr.ToSmallString(false))
 				}
 				fmt.Printf("\n  %s\n", url)
+				if lanURL := srv.LANURL(); lanURL != "" && lanURL != url {
+					fmt.Printf("  LAN: %s\n", lanURL)
+				}
+				if copyURL {
+					printCopyURL(url)
+				}
 			}
 
 			fmt.Printf("\n  Connect from another device. Shell starting below...\n")
@@ -426,7 +1083,10 @@ func runStartInteractive() error {
 				}
 			}
 
-			// Forward stdin to PTY
+			// Forward stdin to PTY. Keystrokes go nowhere else: the raw-mode
+			// terminal doesn't echo them, so the screen, the recording and
+			// viewers only see the PTY's own echo, which password prompts
+			// turn off (stty -echo)
 			go func() {
 				buf := make([]byte, 1024)
 				for {
@@ -438,20 +1098,61 @@ func runStartInteractive() error {
 
 					n, err := os.Stdin.Read(buf)
 					if err != nil {
-						if err != io.EOF {
-							// Ignore read errors during shutdown
+						// A terminal that hung up reads as EIO, or EOF on
+						// some systems. Other errors come with shutdown.
+						if surviveHangup && (isTerminal || err != io.EOF) {
+							select {
+							case hangup <- struct{}{}:
+							default:
+							}
 						}
 						return
 					}
+					approvalMu.Lock()
+					pending := approval
+					approval = nil
+					approvalMu.Unlock()
+					if n > 0 && pending != nil {
+						pending <- buf[0] == 'y' || buf[0] == 'Y'
+						continue
+					}
 					if n > 0 && currentBridge != nil {
 						_ = currentBridge.HandleData(buf[:n])
 					}
 				}
 			}()
 		},
+		OnClientConnectRequest: func(req server.ClientConnectRequest) bool {
+			// Note: terminal is in raw mode, use \r\n
+			answer := make(chan bool, 1)
+			approvalMu.Lock()
+			if headless.Load() {
+				// No one is left to ask
+				approvalMu.Unlock()
+				return false
+			}
+			approval = answer
+			approvalMu.Unlock()
+
+			fmt.Printf("\r\n⚠ A client is connecting (%s)\r\n  Let it use this terminal? [y/N] ", req.CandidatePair)
+			if <-answer {
+				fmt.Printf("allowed\r\n")
+				return true
+			}
+			fmt.Printf("rejected\r\n")
+			return false
+		},
 		OnClientConnect: func() {
 			// Client connected in background - they can now see the session
 			// Note: terminal is in raw mode, use \r\n
+			if origin, ok := srv.ClientOrigin(); ok {
+				fmt.Printf("\r\n✓ Client connected from %s\r\n", origin)
+			}
+		},
+		OnClientAttempting: func() {
+			// Fills the gap between the client opening the link and the
+			// handshake finishing (relays with WebSocket support only)
+			fmt.Printf("\r\n… A client is connecting...\r\n")
 		},
 		OnClientDisconnect: func() {
 			// Client disconnected - shell continues running locally
@@ -475,9 +1176,34 @@ func runStartInteractive() error {
 		}
 	}()
 
+	// goHeadless stops local I/O once the terminal is gone. The shell has
+	// its own PTY, so it and the clients carry on.
+	goHeadless := func() {
+		if headless.Swap(true) {
+			return
+		}
+		if bridge := currentBridge; bridge != nil {
+			bridge.SetLocalOutput(nil)
+		}
+		oldState = nil // Nothing left to restore
+		if devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+			os.Stdout, os.Stderr = devNull, devNull
+		}
+		approvalMu.Lock()
+		if approval != nil {
+			approval <- false
+			approval = nil
+		}
+		approvalMu.Unlock()
+		srv.Notify("The host's terminal disconnected; the session keeps running")
+	}
+
 	// Handle signals
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	if surviveHangup {
+		signal.Notify(sigChan, syscall.SIGHUP)
+	}
 
 	// Run server in background
 	serverDone := make(chan error, 1)
@@ -486,24 +1212,144 @@ func runStartInteractive() error {
 	}()
 
 	// Wait for signal or server exit
-	select {
-	case <-sigChan:
-		if oldState != nil {
-			_ = term.Restore(stdinFd, oldState)
-		}
-		fmt.Printf("\r\n\r\nShutting down...\r\n")
-		cancel()
-		_ = srv.Stop()
-	case err := <-serverDone:
-		if err != nil && err != context.Canceled {
-			return err
+wait:
+	for {
+		select {
+		case <-hangup:
+			goHeadless()
+			continue
+		case sig := <-sigChan:
+			if sig == syscall.SIGHUP {
+				goHeadless()
+				continue
+			}
+			if oldState != nil {
+				_ = term.Restore(stdinFd, oldState)
+			}
+			if sig == syscall.SIGTERM && detachOnTerm {
+				cancel()
+				return handOffToDaemon(srv, opts)
+			}
+			fmt.Printf("\r\n\r\nShutting down...\r\n")
+			if srv.NotifyShutdown(protocol.CloseHostEnded, "the host ended the session") {
+				time.Sleep(100 * time.Millisecond) // Let the close message go out
+			}
+			cancel()
+			_ = srv.Stop()
+		case err := <-serverDone:
+			if err != nil && err != context.Canceled {
+				diag.RecordError(err)
+			}
+			if errors.Is(err, server.ErrWaitTimeout) {
+				if oldState != nil {
+					_ = term.Restore(stdinFd, oldState)
+				}
+				fmt.Printf("\r\n%v - session cancelled.\r\n", err)
+				_ = srv.Stop()
+				return nil
+			}
+			if err != nil && err != context.Canceled {
+				srv.NotifyShutdown(protocol.CloseError, "the host hit an error")
+				return explainSignalingError(err)
+			}
 		}
+		break wait
 	}
 
 	fmt.Printf("Session ended.\r\n")
 	return nil
 }
 
+// explainSignalingError adds what to do about a relay failure
+func explainSignalingError(err error) error {
+	switch {
+	case errors.Is(err, server.ErrNoSignaling):
+		return fmt.Errorf("%w\n(check your network connection and TT_RELAY_URL if set, or choose other methods with --signaling, e.g. --signaling manual)", err)
+	case errors.Is(err, signaling.ErrRateLimited):
+		return fmt.Errorf("%w (too many requests from this address: wait a minute, or run your own relay with tt relay)", err)
+	case errors.Is(err, signaling.ErrRelayUnreachable):
+		return fmt.Errorf("%w (check your network connection, and TT_RELAY_URL if set)", err)
+	case errors.Is(err, signaling.ErrSessionExpired):
+		return fmt.Errorf("%w (start a new session for a new code)", err)
+	case errors.Is(err, signaling.ErrCodeTaken):
+		return fmt.Errorf("%w (choose another --code, or leave it out for a generated one)", err)
+	case errors.Is(err, signaling.ErrRelayVersion):
+		return fmt.Errorf("%w (or point TT_RELAY_URL at a relay running this version)", err)
+	}
+	return err
+}
+
+// handOffToDaemon moves an interactive session to the daemon on SIGTERM
+// (--detach-on-term). The shell keeps running and the daemon serves it
+// under a new code with the same password. Without a daemon the session
+// ends as usual.
+func handOffToDaemon(srv *server.Server, opts server.Options) error {
+	c := client.NewClient()
+	if !c.IsDaemonRunning() {
+		fmt.Printf("\r\n\r\nDaemon is not running, shutting down...\r\n")
+		_ = srv.Stop()
+		fmt.Printf("Session ended.\r\n")
+		return nil
+	}
+
+	pty, err := srv.Detach()
+	if err != nil {
+		_ = srv.Stop()
+		return fmt.Errorf("failed to detach session: %w", err)
+	}
+	params := daemon.AdoptSessionParams{
+		StartSessionParams: daemon.StartSessionParams{
+			Password:    opts.Password,
+			Shell:       opts.Shell,
+			Command:     opts.Command,
+			NoTURN:      opts.NoTURN,
+			Public:      opts.Public,
+			Record:      opts.Record,
+			OutputSink:  opts.OutputSink,
+			GuardBinary: opts.GuardBinary,
+			ReadOnly:    opts.ReadOnly,
+
+			NoViewerRecording: opts.NoViewerRecording,
+			NoJoinNotices:     opts.NoJoinNotices,
+			MaxViewersPerIP:   opts.MaxViewersPerIP,
+			CoalesceWindow:    opts.CoalesceWindow,
+			BellNotify:        opts.BellNotify,
+			LAN:               opts.LAN,
+			AllowWSFallback:   opts.AllowWSFallback,
+			GeoIP:             opts.GeoIP,
+			KDF:               opts.KDF.String(),
+		},
+		ShellPID: pty.PID(),
+	}
+	result, err := c.AdoptSession(params, pty.File())
+	if err != nil {
+		// Nobody else has the shell - end it rather than orphan it
+		_ = pty.Close()
+		return fmt.Errorf("failed to hand the session to the daemon: %w", err)
+	}
+	// The daemon has its own copy of the PTY now
+	_ = pty.File().Close()
+
+	fmt.Printf("\r\n\r\nSession handed to the daemon (the shell is still running):\r\n")
+	fmt.Printf("  Code:     %s\r\n", result.ShortCode)
+	fmt.Printf("  Password: unchanged\r\n")
+	if result.ClientURL != "" {
+		fmt.Printf("  URL:      %s\r\n", result.ClientURL)
+	}
+	fmt.Printf("Clients reconnect with the new code. Use 'tt stop %s' to end it.\r\n", result.ShortCode)
+	return nil
+}
+
+// viewersPerIP converts --max-viewers-per-ip to
+// server.Options.MaxViewersPerIP, where zero selects the default and
+// negative means no limit
+func viewersPerIP() int {
+	if maxPerIP <= 0 {
+		return -1
+	}
+	return maxPerIP
+}
+
 // generatePassword creates a random 16-character password
 func generatePassword() string {
 	bytes := make([]byte, 10)
@@ -537,6 +1383,15 @@ func runList(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	filters := make([]daemon.SessionFilter, 0, len(listFilters))
+	for _, f := range listFilters {
+		filter, err := daemon.ParseSessionFilter(f)
+		if err != nil {
+			return err
+		}
+		filters = append(filters, filter)
+	}
+
 	sessions, err := c.ListSessions()
 	if err != nil {
 		return fmt.Errorf("failed to list sessions: %w", err)
@@ -547,16 +1402,411 @@ func runList(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "ID\tCODE\tSTATUS\tSHELL\tCREATED")
+	matched := sessions[:0]
 	for _, s := range sessions {
-		age := formatAge(time.Since(s.CreatedAt))
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
-			s.ID, s.ShortCode, s.Status, s.Shell, age)
+		if matchesFilters(s, filters) {
+			matched = append(matched, s)
+		}
 	}
-	_ = w.Flush()
-
-	return nil
+	sessions = matched
+	if len(sessions) == 0 {
+		fmt.Println("No sessions match the filter")
+		return nil
+	}
+
+	// Only show the tags column when some session has tags
+	showTags := false
+	for _, s := range sessions {
+		if len(s.Tags) > 0 {
+			showTags = true
+			break
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if showTags {
+		fmt.Fprintln(w, "ID\tCODE\tNAME\tSTATUS\tSHELL\tCREATED\tTAGS")
+	} else {
+		fmt.Fprintln(w, "ID\tCODE\tNAME\tSTATUS\tSHELL\tCREATED")
+	}
+	for _, s := range sessions {
+		age := formatAge(time.Since(s.CreatedAt))
+		sessionName := s.Name
+		if sessionName == "" {
+			sessionName = "-"
+		}
+		status := string(s.Status)
+		if s.RelayDegraded {
+			status += " (relay degraded)"
+		}
+		if showTags {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				s.ID, s.ShortCode, sessionName, status, s.Shell, age, formatTags(s.Tags))
+		} else {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				s.ID, s.ShortCode, sessionName, status, s.Shell, age)
+		}
+	}
+	_ = w.Flush()
+
+	for _, s := range sessions {
+		if s.Status == daemon.StatusFailed && s.Error != "" {
+			fmt.Printf("\nSession %s failed: %s\n", s.ID, s.Error)
+		}
+		if s.RelayDegraded {
+			fmt.Printf("\nSession %s: relay heartbeats are failing (%s); a client that drops may not be able to reconnect\n", s.ShortCode, s.RelayError)
+		}
+	}
+
+	return nil
+}
+
+func runInfo(cmd *cobra.Command, args []string) error {
+	c := client.NewClient()
+
+	if !c.IsDaemonRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	s, err := c.SessionInfo(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to get session info: %w", err)
+	}
+
+	if infoJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID:\t%s\n", s.ID)
+	fmt.Fprintf(w, "Code:\t%s\n", s.ShortCode)
+	if s.Name != "" {
+		fmt.Fprintf(w, "Name:\t%s\n", s.Name)
+	}
+	if len(s.Tags) > 0 {
+		fmt.Fprintf(w, "Tags:\t%s\n", formatTags(s.Tags))
+	}
+	status := string(s.Status)
+	switch s.ConnectionType {
+	case "relay":
+		status += " (via TURN relay)"
+	case "p2p":
+		status += " (peer-to-peer)"
+	case "websocket":
+		status += " (WebSocket through the relay)"
+	}
+	fmt.Fprintf(w, "Status:\t%s\n", status)
+	if s.ClientOrigin != "" {
+		fmt.Fprintf(w, "Client:\tconnected from %s\n", s.ClientOrigin)
+	}
+	if s.Error != "" {
+		fmt.Fprintf(w, "Error:\t%s\n", s.Error)
+	}
+	if s.RelayDown {
+		fmt.Fprintf(w, "Relay:\tappears down, checking periodically (new clients cannot connect until it answers)\n")
+	} else if s.RelayDegraded {
+		fmt.Fprintf(w, "Relay:\theartbeats failing: %s (a client that drops may not be able to reconnect)\n", s.RelayError)
+	}
+	if s.ShellPID > 0 {
+		fmt.Fprintf(w, "Shell:\t%s (PID %d)\n", s.Shell, s.ShellPID)
+	} else {
+		fmt.Fprintf(w, "Shell:\t%s\n", s.Shell)
+	}
+	fmt.Fprintf(w, "Created:\t%s (%s)\n", s.CreatedAt.Format("2006-01-02 15:04:05"), formatAge(time.Since(s.CreatedAt)))
+	fmt.Fprintf(w, "Last seen:\t%s (%s)\n", s.LastSeen.Format("2006-01-02 15:04:05"), formatAge(time.Since(s.LastSeen)))
+	if s.ClientURL != "" {
+		fmt.Fprintf(w, "URL:\t%s\n", s.ClientURL)
+	}
+	if s.Public {
+		fmt.Fprintf(w, "Viewer code:\t%s (%d connected)\n", s.ViewerCode, s.Viewers)
+		if s.ViewerURL != "" {
+			fmt.Fprintf(w, "Viewer URL:\t%s\n", s.ViewerURL)
+		}
+	}
+	fmt.Fprintf(w, "Reconnects:\t%d\n", s.Reconnects)
+	fmt.Fprintf(w, "Traffic:\t%s in, %s out\n", formatSize(s.BytesIn), formatSize(s.BytesOut))
+	switch s.Encoding {
+	case "":
+	case server.EncodingBinary:
+		fmt.Fprintf(w, "Encoding:\t%s (start sessions like this with --guard-binary to pause on binary output)\n", s.Encoding)
+	case server.EncodingLegacy:
+		fmt.Fprintf(w, "Encoding:\t%s (not UTF-8: set a UTF-8 locale if the client shows garbled characters)\n", s.Encoding)
+	default:
+		fmt.Fprintf(w, "Encoding:\t%s\n", s.Encoding)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if infoStates {
+		fmt.Println()
+		if len(s.States) == 0 {
+			fmt.Println("No connection state changes yet")
+			return nil
+		}
+		fmt.Println("Connection states:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, t := range s.States {
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", t.At.Format("2006-01-02 15:04:05.000"), t.Layer, t.State)
+		}
+		return w.Flush()
+	}
+	return nil
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	c := client.NewClient()
+
+	if !c.IsDaemonRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	s, err := c.TransportStats(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to get session stats: %w", err)
+	}
+
+	if !s.Sampled {
+		fmt.Printf("No stats for %s yet: no client has connected (status: %s)\n", s.ShortCode, s.Status)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Code:\t%s\n", s.ShortCode)
+	fmt.Fprintf(w, "Status:\t%s\n", s.Status)
+	fmt.Fprintf(w, "Sampled:\t%s (%s)\n", s.SampledAt.Format("2006-01-02 15:04:05"), formatAge(time.Since(s.SampledAt)))
+	fmt.Fprintf(w, "Traffic:\t%s in, %s out\n", formatSize(int64(s.BytesReceived)), formatSize(int64(s.BytesSent)))
+	fmt.Fprintf(w, "RTT:\t%.1f ms\n", s.RTTMillis)
+	fmt.Fprintf(w, "Check loss:\t%.1f%%\n", s.CheckLoss)
+	if s.CandidatePair != "" {
+		fmt.Fprintf(w, "Candidate pair:\t%s\n", s.CandidatePair)
+	}
+	if statsVerbose {
+		fmt.Fprintf(w, "ICE state:\t%s\n", s.ICEState)
+		fmt.Fprintf(w, "DTLS state:\t%s\n", s.DTLSState)
+		fmt.Fprintf(w, "Bytes:\t%d received, %d sent\n", s.BytesReceived, s.BytesSent)
+		fmt.Fprintf(w, "Messages:\t%d received, %d sent\n", s.MessagesReceived, s.MessagesSent)
+		fmt.Fprintf(w, "ICE checks:\t%d sent, %d answered\n", s.ChecksSent, s.ChecksAnswered)
+	}
+	return w.Flush()
+}
+
+func runWho(cmd *cobra.Command, args []string) error {
+	c := client.NewClient()
+
+	if !c.IsDaemonRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	s, err := c.Who(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to get connections: %w", err)
+	}
+
+	if whoJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	}
+
+	if len(s.Connections) == 0 {
+		fmt.Printf("No one is connected to %s (status: %s)\n", s.ShortCode, s.Status)
+		return nil
+	}
+
+	relayed := false
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "WHO\tADDRESS\tCANDIDATE\tLOCATION\tCONNECTED")
+	for _, conn := range s.Connections {
+		who := conn.Role
+		if conn.Viewer != 0 {
+			who = fmt.Sprintf("viewer %d", conn.Viewer)
+		}
+		address, candidate, location := conn.Address, conn.CandidateType, conn.Location
+		if address == "" {
+			address, candidate = "-", "-"
+		}
+		if candidate == "relay" {
+			candidate += "*"
+			relayed = true
+		}
+		if location == "" {
+			location = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", who, address, candidate, location, formatAge(time.Since(conn.ConnectedAt)))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if relayed {
+		fmt.Println("\n* Relayed through TURN: the address is the peer's TURN server, not the peer")
+	}
+	return nil
+}
+
+func runQR(cmd *cobra.Command, args []string) error {
+	c := client.NewClient()
+
+	if !c.IsDaemonRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	s, err := c.SessionInfo(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to get session info: %w", err)
+	}
+
+	url := s.ClientURL
+	if qrViewer {
+		if s.ViewerURL == "" {
+			return fmt.Errorf("session %s has no viewer link (start it with --public)", s.ShortCode)
+		}
+		url = s.ViewerURL
+	}
+	if url == "" {
+		return fmt.Errorf("session %s has no client URL yet (status: %s)", s.ShortCode, s.Status)
+	}
+
+	qr, err := qrcode.New(url, qrcode.Low)
+	if err != nil {
+		return fmt.Errorf("failed to generate QR code: %w", err)
+	}
+	fmt.Print(qr.ToSmallString(false))
+	fmt.Printf("\n  %s\n", url)
+
+	if qrPNG != "" {
+		if err := qrcode.WriteFile(url, qrcode.Medium, 256, qrPNG); err != nil {
+			return fmt.Errorf("failed to write QR PNG: %w", err)
+		}
+		fmt.Printf("\nQR code written to %s\n", qrPNG)
+	}
+	return nil
+}
+
+func runMark(cmd *cobra.Command, args []string) error {
+	c := client.NewClient()
+
+	if !c.IsDaemonRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	label := ""
+	if len(args) > 1 {
+		label = args[1]
+	}
+
+	result, err := c.AddMarker(args[0], label)
+	if err != nil {
+		return fmt.Errorf("failed to add marker: %w", err)
+	}
+
+	fmt.Printf("Marker added at %s in %s\n", formatOffset(result.Time), result.Recording)
+	return nil
+}
+
+func runDump(cmd *cobra.Command, args []string) error {
+	c := client.NewClient()
+
+	if !c.IsDaemonRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	// The daemon has its own working directory
+	path, err := filepath.Abs(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+
+	result, err := c.DumpRecording(args[0], path)
+	if err != nil {
+		return fmt.Errorf("failed to dump recording: %w", err)
+	}
+
+	fmt.Printf("Copied %s (%d bytes) of %s to %s\n", formatOffset(result.Duration), result.Bytes, result.Recording, result.Path)
+	return nil
+}
+
+// runPause runs tt pause and tt resume
+func runPause(cmd *cobra.Command, args []string) error {
+	c := client.NewClient()
+
+	if !c.IsDaemonRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	pause := cmd.Name() == "pause"
+	var result *daemon.SessionPauseResult
+	var err error
+	if pause {
+		result, err = c.PauseSharing(args[0])
+	} else {
+		result, err = c.ResumeSharing(args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("failed to %s sharing: %w", cmd.Name(), err)
+	}
+
+	switch {
+	case pause && result.Changed:
+		fmt.Printf("Sharing paused for %s (tt resume %s to continue)\n", args[0], args[0])
+	case pause:
+		fmt.Printf("Sharing is already paused for %s\n", args[0])
+	case result.Changed:
+		fmt.Printf("Sharing resumed for %s\n", args[0])
+	default:
+		fmt.Printf("Sharing is not paused for %s\n", args[0])
+	}
+	return nil
+}
+
+// runGrant runs tt grant and tt revoke
+func runGrant(cmd *cobra.Command, args []string) error {
+	c := client.NewClient()
+
+	if !c.IsDaemonRunning() {
+		fmt.Println("Daemon is not running")
+		return nil
+	}
+
+	grant := cmd.Name() == "grant"
+	var result *daemon.SessionGrantResult
+	var err error
+	if grant {
+		viewer := 0
+		if len(args) > 1 {
+			viewer, err = strconv.Atoi(args[1])
+			if err != nil || viewer < 1 {
+				return fmt.Errorf("invalid viewer number: %s", args[1])
+			}
+		}
+		result, err = c.GrantViewerWrite(args[0], viewer)
+	} else {
+		result, err = c.RevokeViewerWrite(args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("failed to %s viewer input: %w", cmd.Name(), err)
+	}
+
+	switch {
+	case grant && result.Changed:
+		fmt.Printf("Viewer %d of %s can type (tt revoke %s to stop)\n", result.Viewer, args[0], args[0])
+	case grant:
+		fmt.Printf("Viewer %d of %s can already type\n", result.Viewer, args[0])
+	case result.Changed:
+		fmt.Printf("The viewer of %s is read-only again\n", args[0])
+	default:
+		fmt.Printf("No viewer of %s can type\n", args[0])
+	}
+	return nil
 }
 
 func runStatus(cmd *cobra.Command, args []string) error {
@@ -578,20 +1828,144 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	if status.ActiveCount > 0 {
 		fmt.Printf(", %d connected", status.ActiveCount)
 	}
+	if status.TotalReconnects > 0 {
+		fmt.Printf(", %d reconnects", status.TotalReconnects)
+	}
 	fmt.Println()
 
+	for _, code := range status.UnstableSessions {
+		fmt.Printf("⚠ Session %s: your connection is unstable (more than %d reconnects)\n",
+			code, daemon.UnstableReconnectThreshold)
+	}
+	for _, code := range status.RelayDownSessions {
+		fmt.Printf("⚠ Session %s: the relay appears down; new clients cannot connect until it answers\n", code)
+	}
+	for _, code := range status.RelayDegradedSessions {
+		fmt.Printf("⚠ Session %s: relay heartbeats are failing; a client that drops may not be able to reconnect\n", code)
+	}
+
 	return nil
 }
 
 func runRelay(cmd *cobra.Command, args []string) error {
-	fmt.Printf("Starting relay server on port %d...\n", relayPort)
+	if relayPublicURL != "" {
+		u, err := url.Parse(relayPublicURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid --public-url %q: must be an http(s) URL", relayPublicURL)
+		}
+	}
+	if relayWriteTimeout <= 0 {
+		return fmt.Errorf("invalid --write-timeout %v: must be positive", relayWriteTimeout)
+	}
+
+	host := "<your-ip>"
+	if relayBind != "" {
+		fmt.Printf("Starting relay server on %s port %d...\n", relayBind, relayPort)
+		host = relayBind
+	} else {
+		fmt.Printf("Starting relay server on port %d...\n", relayPort)
+	}
 	fmt.Printf("\n")
 	fmt.Printf("Hosts can use this relay with:\n")
-	fmt.Printf("  Set RELAY_URL=ws://<your-ip>:%d in environment\n", relayPort)
+	fmt.Printf("  Set RELAY_URL=ws://%s in environment\n", net.JoinHostPort(host, strconv.Itoa(relayPort)))
+	if relayWithWeb {
+		fmt.Printf("  Set %s=http://%s in environment to link to the web client served here\n", signaling.EnvClientURL, net.JoinHostPort(host, strconv.Itoa(relayPort)))
+	}
 	fmt.Printf("\n")
 
 	rs := relayserver.NewRelayServer()
-	return rs.Start(relayPort)
+	rs.SetWriteTimeout(relayWriteTimeout)
+	for _, cidr := range relayTrusted {
+		if err := rs.TrustCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid --trusted-cidr: %w", err)
+		}
+	}
+	if relayWithWeb {
+		rs.ServeWebClient()
+	}
+	if relayPublicURL != "" {
+		rs.SetPublicURL(relayPublicURL)
+		fmt.Printf("Session links will use %s\n\n", strings.TrimSuffix(relayPublicURL, "/"))
+	}
+	return rs.StartOn(relayBind, relayPort)
+}
+
+func runServeWeb(cmd *cobra.Command, args []string) error {
+	addr := net.JoinHostPort(webBind, strconv.Itoa(webPort))
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	host := "<your-ip>"
+	if webBind != "" {
+		host = webBind
+	}
+	fmt.Printf("Serving web client on %s\n", ln.Addr())
+	fmt.Printf("\n")
+	fmt.Printf("Hosts can use this client with:\n")
+	fmt.Printf("  Set %s=http://%s in environment\n", signaling.EnvClientURL, net.JoinHostPort(host, strconv.Itoa(webPort)))
+	fmt.Printf("\n")
+
+	srv := &http.Server{
+		Handler:      web.Handler(),
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  120 * time.Second,
+	}
+	return srv.Serve(ln)
+}
+
+// coalesceWindow converts --coalesce to server.Options.CoalesceWindow, where
+// zero selects the default and negative disables coalescing
+func coalesceWindow() time.Duration {
+	if coalesce <= 0 {
+		return -1
+	}
+	return coalesce
+}
+
+// matchesFilters reports whether a session passes every --filter
+func matchesFilters(s daemon.SessionInfo, filters []daemon.SessionFilter) bool {
+	for _, f := range filters {
+		if !f.Match(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveTags parses the --tag flags into a map
+func resolveTags() (map[string]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]string, len(tags))
+	for _, t := range tags {
+		key, value, err := daemon.ParseTag(t)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+// formatTags renders tags as "key=value" pairs sorted by key, or "-"
+func formatTags(t map[string]string) string {
+	if len(t) == 0 {
+		return "-"
+	}
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + t[k]
+	}
+	return strings.Join(pairs, ",")
 }
 
 // formatAge formats a duration as a human-readable age
@@ -620,6 +1994,46 @@ func formatAge(d time.Duration) string {
 	return fmt.Sprintf("%d days ago", days)
 }
 
+// convertFormats maps the output extensions tt convert knows to the writer
+// of that format
+var convertFormats = map[string]func(io.Writer, *recording.Recording) error{
+	".ttyrec": recording.WriteTTYRec,
+	".tty":    recording.WriteTTYRec,
+}
+
+func runConvert(cmd *cobra.Command, args []string) error {
+	in, out := args[0], args[1]
+	write, ok := convertFormats[strings.ToLower(filepath.Ext(out))]
+	if !ok {
+		return fmt.Errorf("unknown output format %q: use a .ttyrec or .tty file", filepath.Ext(out))
+	}
+
+	rec, err := recording.LoadRecording(in)
+	if err != nil {
+		return fmt.Errorf("failed to load recording: %w", err)
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", out, err)
+	}
+	w := bufio.NewWriter(f)
+	err = write(w, rec)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(out)
+		return fmt.Errorf("failed to write %s: %w", out, err)
+	}
+
+	fmt.Printf("Converted %s to %s (%d events, %v)\n", in, out, rec.EventCount(), rec.Duration().Round(time.Second))
+	return nil
+}
+
 func runPlay(cmd *cobra.Command, args []string) error {
 	path := args[0]
 
@@ -629,12 +2043,34 @@ func runPlay(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load recording: %w", err)
 	}
 
+	markers := rec.Markers()
+	if playMarkers {
+		if len(markers) == 0 {
+			fmt.Println("No markers in recording")
+			return nil
+		}
+		for _, m := range markers {
+			fmt.Printf("  %s  %s\n", formatOffset(m.Time), m.Data)
+		}
+		return nil
+	}
+
 	fmt.Printf("Playing: %s\n", path)
 	fmt.Printf("Size: %dx%d, Duration: %v, Events: %d\n",
 		rec.Header.Width, rec.Header.Height,
 		rec.Duration().Round(time.Second), rec.EventCount())
-	fmt.Printf("Speed: %.1fx\n\n", playSpeed)
-	fmt.Printf("Press Ctrl+C to stop playback\n\n")
+	if isRecordingLive(path) {
+		if playFollow {
+			fmt.Printf("Following live recording - new output will play as it is recorded\n")
+		} else {
+			fmt.Printf("Note: session is still recording, playing what is available so far (use --follow to keep watching)\n")
+		}
+	}
+	fmt.Printf("Speed: %.1fx\n", playSpeed)
+	if len(markers) > 0 {
+		fmt.Printf("Markers: %d (press ] / [ to jump)\n", len(markers))
+	}
+	fmt.Printf("\nPress Ctrl+C to stop playback\n\n")
 
 	// Set up signal handler
 	sigCh := make(chan os.Signal, 1)
@@ -644,20 +2080,43 @@ func runPlay(cmd *cobra.Command, args []string) error {
 	player := recording.NewPlayer(rec, os.Stdout)
 	player.SetSpeed(playSpeed)
 
+	// Show the current marker in the terminal title
+	player.SetMarkerHandler(func(m recording.Event) {
+		fmt.Printf("\x1b]2;[%s] %s\x07", formatOffset(m.Time), m.Data)
+	})
+
+	// Read playback keys in raw mode (not while following - markers are
+	// only navigable in a complete recording)
+	restoreTerm := func() {}
+	stdinFd := int(os.Stdin.Fd())
+	if !playFollow && term.IsTerminal(stdinFd) {
+		if oldState, err := term.MakeRaw(stdinFd); err == nil {
+			restoreTerm = func() { _ = term.Restore(stdinFd, oldState) }
+			go readPlayKeys(player, sigCh)
+		}
+	}
+	defer restoreTerm()
+
 	// Play in goroutine so we can handle signals
 	done := make(chan error, 1)
 	go func() {
+		if playFollow {
+			done <- player.Follow(path)
+			return
+		}
 		done <- player.Play()
 	}()
 
 	// Wait for completion or signal
 	select {
 	case err := <-done:
+		restoreTerm()
 		if err != nil {
 			return err
 		}
 	case <-sigCh:
 		player.Stop()
+		restoreTerm()
 		fmt.Printf("\n\nPlayback stopped\n")
 	}
 
@@ -665,6 +2124,35 @@ func runPlay(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// readPlayKeys handles playback keys: ] and [ jump between markers, and q or
+// Ctrl+C (which raw mode no longer turns into a signal) stop playback
+func readPlayKeys(player *recording.Player, sigCh chan<- os.Signal) {
+	buf := make([]byte, 32)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, key := range buf[:n] {
+			switch key {
+			case ']':
+				player.NextMarker()
+			case '[':
+				player.PrevMarker()
+			case 'q', 0x03:
+				sigCh <- syscall.SIGINT
+				return
+			}
+		}
+	}
+}
+
+// formatOffset formats seconds from the start of a recording as h:mm:ss
+func formatOffset(seconds float64) string {
+	d := time.Duration(seconds * float64(time.Second))
+	return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
+
 func runRecordings(cmd *cobra.Command, args []string) error {
 	recordings, err := recording.ListRecordings()
 	if err != nil {
@@ -680,11 +2168,15 @@ func runRecordings(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Recordings in %s:\n\n", recording.GetRecordingsDir())
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "NAME\tSIZE\tCREATED")
+	fmt.Fprintln(w, "NAME\tSIZE\tCREATED\tSTATE")
 	for _, r := range recordings {
 		size := formatSize(r.Size)
 		age := formatAge(time.Since(r.ModTime))
-		fmt.Fprintf(w, "%s\t%s\t%s\n", r.Name, size, age)
+		state := "complete"
+		if r.LivePID > 0 && server.IsProcessRunning(r.LivePID) {
+			state = "recording"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Name, size, age, state)
 	}
 	_ = w.Flush()
 
@@ -692,6 +2184,12 @@ func runRecordings(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// isRecordingLive reports whether a recording is still being written by a running session
+func isRecordingLive(path string) bool {
+	pid := recording.LivePID(path)
+	return pid > 0 && server.IsProcessRunning(pid)
+}
+
 // formatSize formats a byte count as human-readable
 func formatSize(bytes int64) string {
 	const unit = 1024
@@ -705,4 +2203,3 @@ func formatSize(bytes int64) string {
 	}
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
-
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// resolveMultiplexer turns --tmux or --screen into the command that attaches
+// to that session, after checking the program is installed and the session
+// exists. Returns nil when neither was given.
+func resolveMultiplexer() ([]string, error) {
+	if tmuxSession == "" && screenSession == "" {
+		return nil, nil
+	}
+	flag := "--tmux"
+	if screenSession != "" {
+		flag = "--screen"
+	}
+	switch {
+	case tmuxSession != "" && screenSession != "":
+		return nil, fmt.Errorf("--tmux and --screen cannot be used together")
+	case command != "":
+		return nil, fmt.Errorf("%s and --command cannot be used together", flag)
+	case shell != "":
+		return nil, fmt.Errorf("%s and --shell cannot be used together", flag)
+	case isolate || containerRT != "":
+		// The session lives on the host, so sharing it would undo both
+		return nil, fmt.Errorf("%s attaches to a session on the host: it cannot be used with --isolate or --container-runtime", flag)
+	}
+
+	if tmuxSession != "" {
+		return tmuxAttach(tmuxSession)
+	}
+	return screenAttach(screenSession)
+}
+
+// tmuxAttach returns the command that attaches to a tmux session
+func tmuxAttach(session string) ([]string, error) {
+	tmux, err := exec.LookPath("tmux")
+	if err != nil {
+		return nil, fmt.Errorf("--tmux: tmux is not installed (not found in PATH)")
+	}
+	// "=" matches the name exactly rather than as a prefix
+	if err := exec.Command(tmux, "has-session", "-t", "="+session).Run(); err != nil {
+		return nil, fmt.Errorf("--tmux: no tmux session named %q (tmux ls lists them)", session)
+	}
+	// tmux refuses to attach from inside another tmux client unless TMUX is
+	// unset, which it is when tt itself runs in tmux
+	return []string{"env", "-u", "TMUX", tmux, "attach-session", "-t", "=" + session}, nil
+}
+
+// screenAttach returns the command that attaches to a screen session
+// without detaching anyone already attached (screen -x)
+func screenAttach(session string) ([]string, error) {
+	screen, err := exec.LookPath("screen")
+	if err != nil {
+		return nil, fmt.Errorf("--screen: screen is not installed (not found in PATH)")
+	}
+	// screen -ls exits non-zero even when it lists sessions, so read its
+	// output instead. Sessions are listed as "\t<pid>.<name>\t(...)".
+	out, _ := exec.Command(screen, "-ls", session).Output()
+	if !screenListed(string(out), session) {
+		return nil, fmt.Errorf("--screen: no screen session named %q (screen -ls lists them)", session)
+	}
+	return []string{screen, "-x", session}, nil
+}
+
+// screenListed reports whether screen -ls output lists session, by name or
+// as pid.name
+func screenListed(out, session string) bool {
+	for _, line := range strings.Split(out, "\n") {
+		if !strings.HasPrefix(line, "\t") {
+			continue
+		}
+		id, _, _ := strings.Cut(strings.TrimSpace(line), "\t")
+		pid, name, _ := strings.Cut(id, ".")
+		if name == session || id == session || pid == session {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/artpar/terminal-tunnel/internal/crypto"
+)
+
+const (
+	// minPasswordLength matches the daemon's minimum for user-supplied passwords
+	minPasswordLength = 12
+
+	// minPassphraseWords keeps generated passphrases above the length
+	// minimum and out of the weak range (40 bits)
+	minPassphraseWords = 4
+)
+
+// resolvePassword applies the password flags. With --password-words it
+// generates a passphrase; a given or generated password is then checked
+// against the length minimum and --password-policy, with a warning if it
+// is weak. Returns "" when the caller (or daemon) should generate one.
+func resolvePassword() (string, error) {
+	pw := password
+	if passwordWords > 0 {
+		if pw != "" {
+			return "", fmt.Errorf("--password and --password-words cannot be used together")
+		}
+		if passwordWords < minPassphraseWords {
+			return "", fmt.Errorf("--password-words must be at least %d", minPassphraseWords)
+		}
+		phrase, err := crypto.GeneratePassphrase(passwordWords)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate passphrase: %w", err)
+		}
+		pw = phrase
+	}
+	if pw == "" {
+		return "", nil
+	}
+
+	if len(pw) < minPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+
+	bits := crypto.EstimateEntropy(pw)
+	if passwordPolicy > 0 && bits < float64(passwordPolicy) {
+		return "", fmt.Errorf("password too weak: about %.0f bits of entropy, --password-policy requires %d", bits, passwordPolicy)
+	}
+	if crypto.PasswordStrength(bits) == crypto.StrengthWeak {
+		fmt.Fprintf(os.Stderr, "⚠ Weak password (about %.0f bits of entropy). Use a longer one or --password-words.\n", bits)
+	}
+	return pw, nil
+}
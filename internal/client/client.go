@@ -3,8 +3,11 @@ package client
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"os"
 	"time"
 
 	"github.com/artpar/terminal-tunnel/internal/daemon"
@@ -24,6 +27,12 @@ func NewClient() *Client {
 
 // call makes a JSON-RPC call to the daemon
 func (c *Client) call(method string, params interface{}) (*daemon.Response, error) {
+	return c.callWithFiles(method, params, nil)
+}
+
+// callWithFiles makes a JSON-RPC call, sending files' descriptors with the
+// request (Unix only)
+func (c *Client) callWithFiles(method string, params interface{}, files []*os.File) (*daemon.Response, error) {
 	conn, err := net.DialTimeout("unix", c.socketPath, 5*time.Second)
 	if err != nil {
 		return nil, fmt.Errorf("daemon not running (could not connect to %s)", c.socketPath)
@@ -53,7 +62,7 @@ func (c *Client) call(method string, params interface{}) (*daemon.Response, erro
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	if _, err := conn.Write(append(data, '\n')); err != nil {
+	if err := writeRequest(conn, append(data, '\n'), files); err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 
@@ -61,27 +70,37 @@ func (c *Client) call(method string, params interface{}) (*daemon.Response, erro
 	reader := bufio.NewReader(conn)
 	line, err := reader.ReadBytes('\n')
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, responseReadError(method, line, err)
 	}
 
 	var resp daemon.Response
 	if err := json.Unmarshal(line, &resp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+		return nil, fmt.Errorf("invalid response from daemon to %s (%d bytes): %w", method, len(line), err)
+	}
+	if resp.ID != "" && resp.ID != req.ID {
+		return nil, fmt.Errorf("invalid response from daemon to %s: it answers request %s, not %s", method, resp.ID, req.ID)
 	}
 
 	return &resp, nil
 }
 
-// StartSession starts a new terminal session
-func (c *Client) StartSession(password, shell string, noTURN, public, record bool) (*daemon.StartSessionResult, error) {
-	params := daemon.StartSessionParams{
-		Password: password,
-		Shell:    shell,
-		NoTURN:   noTURN,
-		Public:   public,
-		Record:   record,
+// responseReadError explains why no complete response line arrived; partial
+// holds what was read before err
+func responseReadError(method string, partial []byte, err error) error {
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return fmt.Errorf("timed out waiting for the daemon to answer %s (%d bytes received)", method, len(partial))
+	case errors.Is(err, io.EOF) && len(partial) == 0:
+		return fmt.Errorf("daemon closed the connection without answering %s (it may have stopped)", method)
+	case errors.Is(err, io.EOF):
+		return fmt.Errorf("daemon response to %s was cut off after %d bytes", method, len(partial))
 	}
+	return fmt.Errorf("failed to read response to %s: %w", method, err)
+}
 
+// StartSession starts a new terminal session
+func (c *Client) StartSession(params daemon.StartSessionParams) (*daemon.StartSessionResult, error) {
 	resp, err := c.call(daemon.MethodSessionStart, params)
 	if err != nil {
 		return nil, err
@@ -99,7 +118,27 @@ func (c *Client) StartSession(password, shell string, noTURN, public, record boo
 	return &result, nil
 }
 
-// StopSession stops a session by ID or short code
+// AdoptSession hands a running shell to the daemon, which serves it as a
+// detached session under a new code. ptmx is the shell's PTY master.
+func (c *Client) AdoptSession(params daemon.AdoptSessionParams, ptmx *os.File) (*daemon.StartSessionResult, error) {
+	resp, err := c.callWithFiles(daemon.MethodSessionAdopt, params, []*os.File{ptmx})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var result daemon.StartSessionResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse result: %w", err)
+	}
+
+	return &result, nil
+}
+
+// StopSession stops a session by ID, short code or name
 func (c *Client) StopSession(idOrCode string) error {
 	params := daemon.StopSessionParams{
 		ID: idOrCode,
@@ -136,6 +175,226 @@ func (c *Client) ListSessions() ([]daemon.SessionInfo, error) {
 	return result.Sessions, nil
 }
 
+// SessionInfo gets full details for a session by ID, short code or name
+func (c *Client) SessionInfo(idOrCode string) (*daemon.SessionDetails, error) {
+	params := daemon.SessionInfoParams{
+		ID: idOrCode,
+	}
+
+	resp, err := c.call(daemon.MethodSessionInfo, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var result daemon.SessionDetails
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse result: %w", err)
+	}
+
+	return &result, nil
+}
+
+// AddMarker writes a labelled marker into a session's recording
+func (c *Client) AddMarker(idOrCode, label string) (*daemon.SessionMarkResult, error) {
+	params := daemon.SessionMarkParams{
+		ID:    idOrCode,
+		Label: label,
+	}
+
+	resp, err := c.call(daemon.MethodSessionMark, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var result daemon.SessionMarkResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse result: %w", err)
+	}
+
+	return &result, nil
+}
+
+// DumpRecording copies a session's recording so far to path, which must
+// be absolute because the daemon resolves it
+func (c *Client) DumpRecording(idOrCode, path string) (*daemon.SessionDumpResult, error) {
+	params := daemon.SessionDumpParams{
+		ID:   idOrCode,
+		Path: path,
+	}
+
+	resp, err := c.call(daemon.MethodSessionDump, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var result daemon.SessionDumpResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse result: %w", err)
+	}
+
+	return &result, nil
+}
+
+// WaitForClient waits until a client has connected to a session, or until
+// timeout passes (0 = no limit), and returns whether one did. The wait is
+// made of session.wait requests of at most daemon.MaxSessionWait, so each
+// is answered within the call deadline.
+func (c *Client) WaitForClient(idOrCode string, timeout time.Duration) (*daemon.SessionWaitResult, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		wait := daemon.MaxSessionWait
+		if timeout > 0 {
+			wait = max(min(wait, time.Until(deadline)), time.Millisecond)
+		}
+		params := daemon.SessionWaitParams{
+			ID:      idOrCode,
+			Timeout: wait,
+		}
+
+		resp, err := c.call(daemon.MethodSessionWait, params)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+
+		var result daemon.SessionWaitResult
+		if err := json.Unmarshal(resp.Result, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse result: %w", err)
+		}
+
+		if result.Connected || (timeout > 0 && !time.Now().Before(deadline)) {
+			return &result, nil
+		}
+	}
+}
+
+// PauseSharing stops streaming a session's output until ResumeSharing
+func (c *Client) PauseSharing(idOrCode string) (*daemon.SessionPauseResult, error) {
+	return c.setSharingPaused(daemon.MethodSessionPause, idOrCode)
+}
+
+// ResumeSharing streams a paused session's output again
+func (c *Client) ResumeSharing(idOrCode string) (*daemon.SessionPauseResult, error) {
+	return c.setSharingPaused(daemon.MethodSessionResume, idOrCode)
+}
+
+func (c *Client) setSharingPaused(method, idOrCode string) (*daemon.SessionPauseResult, error) {
+	params := daemon.SessionPauseParams{
+		ID: idOrCode,
+	}
+
+	resp, err := c.call(method, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var result daemon.SessionPauseResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse result: %w", err)
+	}
+
+	return &result, nil
+}
+
+// GrantViewerWrite lets one of a session's public viewers type until
+// RevokeViewerWrite. viewer is its number; 0 picks the only one connected.
+func (c *Client) GrantViewerWrite(idOrCode string, viewer int) (*daemon.SessionGrantResult, error) {
+	return c.setViewerWrite(daemon.MethodSessionGrant, idOrCode, viewer)
+}
+
+// RevokeViewerWrite makes a session's public viewers read-only again
+func (c *Client) RevokeViewerWrite(idOrCode string) (*daemon.SessionGrantResult, error) {
+	return c.setViewerWrite(daemon.MethodSessionRevoke, idOrCode, 0)
+}
+
+func (c *Client) setViewerWrite(method, idOrCode string, viewer int) (*daemon.SessionGrantResult, error) {
+	params := daemon.SessionGrantParams{
+		ID:     idOrCode,
+		Viewer: viewer,
+	}
+
+	resp, err := c.call(method, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var result daemon.SessionGrantResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse result: %w", err)
+	}
+
+	return &result, nil
+}
+
+// TransportStats gets the latest WebRTC transport stats of a session
+func (c *Client) TransportStats(idOrCode string) (*daemon.SessionStatsResult, error) {
+	params := daemon.SessionStatsParams{
+		ID: idOrCode,
+	}
+
+	resp, err := c.call(daemon.MethodSessionStats, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var result daemon.SessionStatsResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse result: %w", err)
+	}
+
+	return &result, nil
+}
+
+// Who gets the client and viewers connected to a session right now
+func (c *Client) Who(idOrCode string) (*daemon.SessionWhoResult, error) {
+	params := daemon.SessionWhoParams{
+		ID: idOrCode,
+	}
+
+	resp, err := c.call(daemon.MethodSessionWho, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	var result daemon.SessionWhoResult
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse result: %w", err)
+	}
+
+	return &result, nil
+}
+
 // Status gets daemon status
 func (c *Client) Status() (*daemon.DaemonStatusResult, error) {
 	resp, err := c.call(daemon.MethodDaemonStatus, nil)
@@ -0,0 +1,298 @@
+//go:build !windows
+
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/artpar/terminal-tunnel/internal/daemon"
+	"github.com/artpar/terminal-tunnel/internal/paths"
+	"github.com/artpar/terminal-tunnel/internal/server"
+	"github.com/artpar/terminal-tunnel/internal/signaling"
+)
+
+// fakeRelay answers session creation with a fixed code and holds every poll
+// open until the poller gives up, standing in for the relay so sessions can
+// start without a network
+func fakeRelay(t *testing.T) *httptest.Server {
+	t.Helper()
+	done := make(chan struct{})
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/session":
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"code":         "TEST1234",
+				"delete_token": "token",
+				"expires_in":   300,
+			})
+		case r.Method == http.MethodGet:
+			select {
+			case <-r.Context().Done():
+			case <-done:
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(func() {
+		close(done)
+		relay.Close()
+	})
+	return relay
+}
+
+// startDaemon runs a daemon with its own data directory and socket, and
+// returns a client connected to it. The daemon is shut down at the end of
+// the test if the test didn't.
+func startDaemon(t *testing.T) *Client {
+	t.Helper()
+	t.Setenv(paths.EnvHome, t.TempDir())
+	t.Setenv(signaling.EnvRelayURL, fakeRelay(t).URL)
+
+	d, err := daemon.NewDaemon()
+	if err != nil {
+		t.Fatalf("NewDaemon failed: %v", err)
+	}
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- d.Start()
+	}()
+	t.Cleanup(func() {
+		d.Shutdown()
+		<-stopped
+	})
+
+	c := NewClient()
+	deadline := time.Now().Add(5 * time.Second)
+	for !c.IsDaemonRunning() {
+		if time.Now().After(deadline) {
+			t.Fatal("daemon did not start listening")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return c
+}
+
+// rpcErrorCode returns the daemon error code of err, or 0
+func rpcErrorCode(err error) int {
+	var rpcErr *daemon.RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code
+	}
+	return 0
+}
+
+func TestDaemonSessionLifecycle(t *testing.T) {
+	c := startDaemon(t)
+
+	status, err := c.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if !status.Running || status.SessionCount != 0 {
+		t.Errorf("status = %+v, want running with no sessions", status)
+	}
+
+	started, err := c.StartSession(daemon.StartSessionParams{Shell: "/bin/sh", NoTURN: true, Name: "rpc-test"})
+	if err != nil {
+		t.Fatalf("StartSession failed: %v", err)
+	}
+	if started.ShortCode != "TEST1234" || started.Password == "" {
+		t.Errorf("started = %+v, want code TEST1234 and a generated password", started)
+	}
+
+	sessions, err := c.ListSessions()
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != started.ID || sessions[0].Name != "rpc-test" {
+		t.Fatalf("sessions = %+v, want the started session", sessions)
+	}
+
+	details, err := c.SessionInfo("rpc-test")
+	if err != nil {
+		t.Fatalf("SessionInfo by name failed: %v", err)
+	}
+	if details.ShortCode != started.ShortCode {
+		t.Errorf("SessionInfo code = %q, want %q", details.ShortCode, started.ShortCode)
+	}
+
+	if err := c.StopSession(started.ShortCode); err != nil {
+		t.Fatalf("StopSession failed: %v", err)
+	}
+	if sessions, err := c.ListSessions(); err != nil || len(sessions) != 0 {
+		t.Errorf("after stop: sessions = %+v, err = %v, want none", sessions, err)
+	}
+	if err := c.StopSession(started.ShortCode); rpcErrorCode(err) != daemon.ErrCodeSessionNotFound {
+		t.Errorf("stopping it again: err = %v, want code %d", err, daemon.ErrCodeSessionNotFound)
+	}
+}
+
+func TestDaemonStartSessionErrors(t *testing.T) {
+	c := startDaemon(t)
+
+	tests := []struct {
+		name   string
+		params daemon.StartSessionParams
+	}{
+		{"short password", daemon.StartSessionParams{Password: "short", Shell: "/bin/sh"}},
+		{"unknown kdf", daemon.StartSessionParams{Shell: "/bin/sh", KDF: "md5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := c.call(daemon.MethodSessionStart, tt.params)
+			if err != nil {
+				t.Fatalf("call failed: %v", err)
+			}
+			if resp.Error == nil || resp.Error.Code != daemon.ErrCodeSessionCreateFailed {
+				t.Errorf("error = %+v, want code %d", resp.Error, daemon.ErrCodeSessionCreateFailed)
+			}
+		})
+	}
+}
+
+func TestDaemonBadRequests(t *testing.T) {
+	c := startDaemon(t)
+
+	// Unknown method
+	resp, err := c.call("session.nope", nil)
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if resp.Error == nil || resp.Error.Code != daemon.ErrCodeInvalidParams {
+		t.Errorf("unknown method: error = %+v, want code %d", resp.Error, daemon.ErrCodeInvalidParams)
+	}
+
+	// Missing and mistyped params
+	for _, params := range []any{nil, map[string]int{"id": 5}} {
+		resp, err := c.call(daemon.MethodSessionStop, params)
+		if err != nil {
+			t.Fatalf("call failed: %v", err)
+		}
+		if resp.Error == nil || resp.Error.Code != daemon.ErrCodeInvalidParams {
+			t.Errorf("session.stop with params %v: error = %+v, want code %d", params, resp.Error, daemon.ErrCodeInvalidParams)
+		}
+	}
+
+	// Unknown session
+	if _, err := c.SessionInfo("NOSUCH"); rpcErrorCode(err) != daemon.ErrCodeSessionNotFound {
+		t.Errorf("unknown session: err = %v, want code %d", err, daemon.ErrCodeSessionNotFound)
+	}
+
+	// Malformed JSON gets an answer rather than a dropped connection
+	conn, err := net.Dial("unix", c.socketPath)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte("{not json\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("no response to malformed JSON: %v", err)
+	}
+	var malformed daemon.Response
+	if err := json.Unmarshal(line, &malformed); err != nil {
+		t.Fatalf("invalid response %q: %v", line, err)
+	}
+	if malformed.Error == nil || malformed.Error.Code != daemon.ErrCodeInvalidParams {
+		t.Errorf("malformed JSON: error = %+v, want code %d", malformed.Error, daemon.ErrCodeInvalidParams)
+	}
+}
+
+func TestDaemonShutdown(t *testing.T) {
+	c := startDaemon(t)
+
+	result, err := c.Shutdown()
+	if err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if !result.Success {
+		t.Errorf("result = %+v, want success", result)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c.IsDaemonRunning() {
+		if time.Now().After(deadline) {
+			t.Fatal("daemon still answering after shutdown")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if _, err := c.Status(); err == nil {
+		t.Error("Status should fail once the daemon has stopped")
+	}
+}
+
+func TestDaemonWaitForClient(t *testing.T) {
+	c := startDaemon(t)
+
+	started, err := c.StartSession(daemon.StartSessionParams{Shell: "/bin/sh", NoTURN: true})
+	if err != nil {
+		t.Fatalf("StartSession failed: %v", err)
+	}
+
+	// Nobody joins, so the wait times out without an error
+	begin := time.Now()
+	result, err := c.WaitForClient(started.ShortCode, 300*time.Millisecond)
+	if err != nil {
+		t.Fatalf("WaitForClient failed: %v", err)
+	}
+	if result.Connected || result.Status != daemon.StatusWaiting {
+		t.Errorf("result = %+v, want still waiting", result)
+	}
+	if took := time.Since(begin); took < 300*time.Millisecond || took > 10*time.Second {
+		t.Errorf("wait took %v, want about the timeout", took)
+	}
+
+	// Stopping the session ends a wait in progress
+	waitErr := make(chan error, 1)
+	go func() {
+		_, err := c.WaitForClient(started.ShortCode, 0)
+		waitErr <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if err := c.StopSession(started.ShortCode); err != nil {
+		t.Fatalf("StopSession failed: %v", err)
+	}
+	select {
+	case err := <-waitErr:
+		if err == nil {
+			t.Error("wait on a stopped session succeeded")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("wait not ended by stopping the session")
+	}
+
+	if _, err := c.WaitForClient("NOSUCH", time.Second); rpcErrorCode(err) != daemon.ErrCodeSessionNotFound {
+		t.Errorf("unknown session: err = %v, want code %d", err, daemon.ErrCodeSessionNotFound)
+	}
+}
+
+func TestDaemonAddMarker(t *testing.T) {
+	c := startDaemon(t)
+
+	// A session that isn't recording has nowhere to put the marker. The
+	// marker itself is tested with the server, which needs a client
+	// connected before it records.
+	started, err := c.StartSession(daemon.StartSessionParams{Shell: "/bin/sh", NoTURN: true, Name: "plain"})
+	if err != nil {
+		t.Fatalf("StartSession failed: %v", err)
+	}
+	if _, err := c.AddMarker("plain", "nowhere"); err == nil || !strings.Contains(err.Error(), server.ErrNotRecording.Error()) {
+		t.Errorf("AddMarker without a recording: err = %v, want %v", err, server.ErrNotRecording)
+	}
+
+	if _, err := c.AddMarker("NOSUCH", "x"); rpcErrorCode(err) != daemon.ErrCodeSessionNotFound {
+		t.Errorf("unknown session: err = %v, want code %d", err, daemon.ErrCodeSessionNotFound)
+	}
+	_ = c.StopSession(started.ID)
+}
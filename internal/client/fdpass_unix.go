@@ -0,0 +1,36 @@
+//go:build !windows
+
+package client
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// writeRequest sends a request line, with files' descriptors as SCM_RIGHTS
+func writeRequest(conn net.Conn, data []byte, files []*os.File) error {
+	if len(files) == 0 {
+		_, err := conn.Write(data)
+		return err
+	}
+
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return fmt.Errorf("files can only be sent over a Unix socket")
+	}
+	fds := make([]int, len(files))
+	for i, f := range files {
+		// Not f.Fd(), which would switch the shared PTY to blocking mode
+		raw, err := f.SyscallConn()
+		if err != nil {
+			return err
+		}
+		if err := raw.Control(func(fd uintptr) { fds[i] = int(fd) }); err != nil {
+			return err
+		}
+	}
+	_, _, err := uc.WriteMsgUnix(data, syscall.UnixRights(fds...), nil)
+	return err
+}
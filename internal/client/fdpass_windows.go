@@ -0,0 +1,18 @@
+//go:build windows
+
+package client
+
+import (
+	"fmt"
+	"net"
+	"os"
+)
+
+// writeRequest sends a request line. Windows can't pass file descriptors.
+func writeRequest(conn net.Conn, data []byte, files []*os.File) error {
+	if len(files) > 0 {
+		return fmt.Errorf("passing files to the daemon is not supported on Windows")
+	}
+	_, err := conn.Write(data)
+	return err
+}
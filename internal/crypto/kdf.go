@@ -0,0 +1,111 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// KDF identifies the key derivation function a session's password key is
+// derived with. Hosts choose it; the salt they share tells clients which
+// one to run.
+type KDF byte
+
+const (
+	// KDFDefault is Argon2id, with PBKDF2 accepted from browsers that
+	// cannot run Argon2. Its salt is plain random bytes, as before KDFs
+	// could be chosen, so every client supports it.
+	KDFDefault KDF = iota
+	// KDFArgon2id is Argon2id only: clients that cannot run it can't connect
+	KDFArgon2id
+	// KDFScrypt is scrypt (N=2^15, r=8, p=1)
+	KDFScrypt
+	// KDFPBKDF2 is PBKDF2-SHA256 only, for FIPS-constrained environments
+	KDFPBKDF2
+)
+
+const (
+	// saltHeaderVersion starts a salt that names its KDF: the version, the
+	// KDF, then saltLen random bytes. The whole salt is KDF input, so the
+	// choice of KDF cannot be changed without changing the key.
+	saltHeaderVersion = 0x01
+	saltHeaderLen     = 2
+
+	// scrypt parameters: 32 MB, the interactive-login recommendation
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+var kdfNames = map[KDF]string{
+	KDFDefault:  "default",
+	KDFArgon2id: "argon2id",
+	KDFScrypt:   "scrypt",
+	KDFPBKDF2:   "pbkdf2",
+}
+
+// String returns the name ParseKDF accepts
+func (k KDF) String() string {
+	if name, ok := kdfNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("kdf(%d)", byte(k))
+}
+
+// ParseKDF parses a KDF name: default (or empty), argon2id, scrypt or pbkdf2
+func ParseKDF(name string) (KDF, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return KDFDefault, nil
+	}
+	for kdf, n := range kdfNames {
+		if n == name {
+			return kdf, nil
+		}
+	}
+	return KDFDefault, fmt.Errorf("unknown KDF %q: want default, argon2id, scrypt or pbkdf2", name)
+}
+
+// GenerateSaltFor creates a salt for kdf: plain random bytes for
+// KDFDefault, or a header naming kdf followed by them.
+func GenerateSaltFor(kdf KDF) ([]byte, error) {
+	if kdf == KDFDefault {
+		return GenerateSalt()
+	}
+	if _, ok := kdfNames[kdf]; !ok {
+		return nil, fmt.Errorf("unknown KDF %d", byte(kdf))
+	}
+	salt := make([]byte, saltHeaderLen+saltLen)
+	salt[0] = saltHeaderVersion
+	salt[1] = byte(kdf)
+	if _, err := rand.Read(salt[saltHeaderLen:]); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// SaltKDF returns the KDF a salt names, or KDFDefault for a plain salt
+func SaltKDF(salt []byte) KDF {
+	if len(salt) != saltHeaderLen+saltLen || salt[0] != saltHeaderVersion {
+		return KDFDefault
+	}
+	kdf := KDF(salt[1])
+	if _, ok := kdfNames[kdf]; !ok || kdf == KDFDefault {
+		return KDFDefault
+	}
+	return kdf
+}
+
+// deriveKeyScrypt derives a 256-bit encryption key using scrypt
+func deriveKeyScrypt(password string, salt []byte) [32]byte {
+	var keyArray [32]byte
+	key, err := scrypt.Key([]byte(password), salt, scryptN, scryptR, scryptP, argonKeyLen)
+	if err != nil {
+		// Only invalid parameters fail, and these are constant
+		panic(err)
+	}
+	copy(keyArray[:], key)
+	return keyArray
+}
@@ -0,0 +1,69 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseKDF(t *testing.T) {
+	for _, kdf := range []KDF{KDFDefault, KDFArgon2id, KDFScrypt, KDFPBKDF2} {
+		got, err := ParseKDF(kdf.String())
+		if err != nil || got != kdf {
+			t.Errorf("ParseKDF(%q) = %v, %v; want %v", kdf.String(), got, err, kdf)
+		}
+	}
+	if got, err := ParseKDF(""); err != nil || got != KDFDefault {
+		t.Errorf("ParseKDF(\"\") = %v, %v; want default", got, err)
+	}
+	if got, err := ParseKDF(" SCRYPT "); err != nil || got != KDFScrypt {
+		t.Errorf("ParseKDF is not case-insensitive: %v, %v", got, err)
+	}
+	if _, err := ParseKDF("bcrypt"); err == nil {
+		t.Error("ParseKDF(bcrypt) should fail")
+	}
+}
+
+func TestSaltKDF(t *testing.T) {
+	for _, kdf := range []KDF{KDFDefault, KDFArgon2id, KDFScrypt, KDFPBKDF2} {
+		salt, err := GenerateSaltFor(kdf)
+		if err != nil {
+			t.Fatalf("GenerateSaltFor(%v) failed: %v", kdf, err)
+		}
+		if got := SaltKDF(salt); got != kdf {
+			t.Errorf("SaltKDF(GenerateSaltFor(%v)) = %v", kdf, got)
+		}
+	}
+
+	// A plain salt is the default, whatever its bytes
+	plain := append([]byte{saltHeaderVersion, byte(KDFScrypt)}, make([]byte, saltLen-2)...)
+	if got := SaltKDF(plain); got != KDFDefault {
+		t.Errorf("SaltKDF(16-byte salt) = %v, want default", got)
+	}
+	if _, err := GenerateSaltFor(KDF(99)); err == nil {
+		t.Error("GenerateSaltFor(unknown) should fail")
+	}
+}
+
+func TestDeriveKeyDispatch(t *testing.T) {
+	password := "test-password-123"
+	random := bytes.Repeat([]byte{7}, saltLen)
+	salt := func(kdf KDF) []byte {
+		return append([]byte{saltHeaderVersion, byte(kdf)}, random...)
+	}
+
+	pbkdf2 := DeriveKey(password, salt(KDFPBKDF2))
+	if pbkdf2 != DeriveKeyPBKDF2(password, salt(KDFPBKDF2)) {
+		t.Error("a PBKDF2 salt should derive with PBKDF2")
+	}
+
+	scrypt := DeriveKey(password, salt(KDFScrypt))
+	if scrypt != DeriveKey(password, salt(KDFScrypt)) {
+		t.Error("scrypt derivation should be deterministic")
+	}
+	if scrypt == pbkdf2 || scrypt == DeriveKeyPBKDF2(password, salt(KDFScrypt)) {
+		t.Error("a scrypt salt should not derive with PBKDF2")
+	}
+	if scrypt == DeriveKey("different-password", salt(KDFScrypt)) {
+		t.Error("different passwords should produce different scrypt keys")
+	}
+}
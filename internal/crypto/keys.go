@@ -21,9 +21,17 @@ const (
 	pbkdf2Iterations = 600000 // High iteration count for security
 )
 
-// DeriveKey derives a 256-bit encryption key from a password using Argon2id.
+// DeriveKey derives a 256-bit encryption key from a password with the KDF
+// the salt names (see GenerateSaltFor), or Argon2id for a plain salt.
 // The salt should be randomly generated and shared with the peer.
 func DeriveKey(password string, salt []byte) [32]byte {
+	switch SaltKDF(salt) {
+	case KDFScrypt:
+		return deriveKeyScrypt(password, salt)
+	case KDFPBKDF2:
+		return DeriveKeyPBKDF2(password, salt)
+	}
+
 	key := argon2.IDKey(
 		[]byte(password),
 		salt,
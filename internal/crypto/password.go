@@ -0,0 +1,191 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Strength thresholds in estimated bits of entropy
+const (
+	WeakEntropyBits   = 40 // Below this a password is weak
+	StrongEntropyBits = 60 // At or above this a password is strong
+)
+
+// Password strength ratings returned by PasswordStrength
+const (
+	StrengthWeak   = "weak"
+	StrengthFair   = "fair"
+	StrengthStrong = "strong"
+)
+
+// PassphraseSeparator joins the words of a generated passphrase
+const PassphraseSeparator = "-"
+
+// ErrInvalidWordCount is returned by GeneratePassphrase for a non-positive word count
+var ErrInvalidWordCount = errors.New("passphrase needs at least one word")
+
+// commonPasswords are substrings that add almost nothing to a password.
+// Matching is case-insensitive.
+var commonPasswords = []string{
+	"password", "passw0rd", "qwerty", "asdf", "zxcv", "letmein", "welcome",
+	"admin", "login", "secret", "iloveyou", "monkey", "dragon", "master",
+	"shadow", "sunshine", "princess", "football", "baseball", "superman",
+	"trustno1", "starwars", "whatever", "freedom", "hello", "terminal",
+	"tunnel", "abc123", "123456", "654321", "111111", "000000",
+}
+
+// EstimateEntropy returns a conservative estimate of a password's entropy in
+// bits. Each character is worth log2 of the character pool in use, except
+// repeats and steps of a sequence ("aaaa", "1234", "cba"), which count one
+// bit, and common passwords, which count as a single guess from a short
+// list. A passphrase from GeneratePassphrase is scored by its word count.
+func EstimateEntropy(password string) float64 {
+	if password == "" {
+		return 0
+	}
+	if words, ok := passphraseWordCount(password); ok {
+		return float64(words) * math.Log2(float64(len(passphraseWords)))
+	}
+
+	// Collapse common passwords to a single placeholder character
+	lower := strings.ToLower(password)
+	common := 0
+	for _, c := range commonPasswords {
+		if n := strings.Count(lower, c); n > 0 {
+			common += n
+			lower = strings.ReplaceAll(lower, c, "\x00")
+		}
+	}
+
+	perChar := math.Log2(float64(poolSize(password)))
+	bits := float64(common) * math.Log2(float64(len(commonPasswords)))
+
+	var prev rune
+	step := rune(0)
+	for i, r := range []rune(lower) {
+		if r == 0 {
+			prev, step = 0, 0
+			continue
+		}
+		d := r - prev
+		switch {
+		case i > 0 && prev != 0 && (d == 0 || (d == step && (d == 1 || d == -1))):
+			bits++ // Repeat or continued sequence
+		case i > 0 && prev != 0 && (d == 1 || d == -1):
+			step = d
+			bits += perChar / 2 // Possible start of a sequence
+		default:
+			step = 0
+			bits += perChar
+		}
+		prev = r
+	}
+	return bits
+}
+
+// poolSize returns the size of the character set a password draws from
+func poolSize(password string) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r > unicode.MaxASCII:
+			other = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	size := 0
+	if lower {
+		size += 26
+	}
+	if upper {
+		size += 26
+	}
+	if digit {
+		size += 10
+	}
+	if symbol {
+		size += 33
+	}
+	if other {
+		size += 100
+	}
+	return size
+}
+
+// passphraseWordCount reports how many words a password has if it is made
+// only of passphrase words joined by PassphraseSeparator
+func passphraseWordCount(password string) (int, bool) {
+	words := strings.Split(password, PassphraseSeparator)
+	if len(words) < 2 {
+		return 0, false
+	}
+	for _, w := range words {
+		if !isPassphraseWord(w) {
+			return 0, false
+		}
+	}
+	return len(words), true
+}
+
+// isPassphraseWord reports whether w is in the passphrase word list
+func isPassphraseWord(w string) bool {
+	// The list is sorted, so binary search
+	lo, hi := 0, len(passphraseWords)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		switch {
+		case passphraseWords[mid] == w:
+			return true
+		case passphraseWords[mid] < w:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+	return false
+}
+
+// PasswordStrength rates an entropy estimate as weak, fair or strong
+func PasswordStrength(bits float64) string {
+	switch {
+	case bits < WeakEntropyBits:
+		return StrengthWeak
+	case bits < StrongEntropyBits:
+		return StrengthFair
+	default:
+		return StrengthStrong
+	}
+}
+
+// GeneratePassphrase creates a diceware-style passphrase of random words
+// joined by PassphraseSeparator. Each word adds 10 bits of entropy.
+func GeneratePassphrase(words int) (string, error) {
+	if words < 1 {
+		return "", ErrInvalidWordCount
+	}
+
+	buf := make([]byte, 2*words)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	chosen := make([]string, words)
+	for i := range chosen {
+		// The list has 1024 entries, so masking 10 bits is uniform
+		idx := binary.BigEndian.Uint16(buf[2*i:]) & (uint16(len(passphraseWords)) - 1)
+		chosen[i] = passphraseWords[idx]
+	}
+	return strings.Join(chosen, PassphraseSeparator), nil
+}
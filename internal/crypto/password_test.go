@@ -0,0 +1,80 @@
+package crypto
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestEstimateEntropy(t *testing.T) {
+	tests := []struct {
+		password string
+		want     string
+	}{
+		{"aaaaaaaaaaaa", StrengthWeak},
+		{"123456789012", StrengthWeak},
+		{"password1234", StrengthWeak},
+		{"Qwerty123456", StrengthWeak},
+		{"abcdefghijkl", StrengthWeak},
+		{"kxtmqvbpwzrn", StrengthFair},
+		{"w8Kz#q2Lp!x9Tb4m", StrengthStrong},
+		{"dqzk3mhyp2vxtn7r", StrengthStrong}, // generatePassword-style base32
+		{"otter-glove-maple-radar-onion-blend", StrengthStrong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			bits := EstimateEntropy(tt.password)
+			if got := PasswordStrength(bits); got != tt.want {
+				t.Errorf("strength = %s (%.1f bits), want %s", got, bits, tt.want)
+			}
+		})
+	}
+
+	if EstimateEntropy("") != 0 {
+		t.Error("empty password should have no entropy")
+	}
+}
+
+func TestEstimateEntropyPassphrase(t *testing.T) {
+	// Word passphrases are scored by word count, not character count
+	if got := EstimateEntropy("otter-glove-maple"); got != 30 {
+		t.Errorf("3-word passphrase = %.1f bits, want 30", got)
+	}
+}
+
+func TestGeneratePassphrase(t *testing.T) {
+	phrase, err := GeneratePassphrase(6)
+	if err != nil {
+		t.Fatalf("GeneratePassphrase failed: %v", err)
+	}
+
+	words := strings.Split(phrase, PassphraseSeparator)
+	if len(words) != 6 {
+		t.Fatalf("expected 6 words, got %d: %q", len(words), phrase)
+	}
+	for _, w := range words {
+		if !isPassphraseWord(w) {
+			t.Errorf("word %q not in word list", w)
+		}
+	}
+	if got := EstimateEntropy(phrase); got != 60 {
+		t.Errorf("6-word passphrase = %.1f bits, want 60", got)
+	}
+
+	if _, err := GeneratePassphrase(0); err != ErrInvalidWordCount {
+		t.Errorf("expected ErrInvalidWordCount, got %v", err)
+	}
+}
+
+func TestPassphraseWordList(t *testing.T) {
+	// isPassphraseWord relies on the list being sorted and unique
+	if !sort.StringsAreSorted(passphraseWords[:]) {
+		t.Error("word list must be sorted")
+	}
+	for i := 1; i < len(passphraseWords); i++ {
+		if passphraseWords[i] == passphraseWords[i-1] {
+			t.Errorf("duplicate word %q", passphraseWords[i])
+		}
+	}
+}
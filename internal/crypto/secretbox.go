@@ -9,6 +9,10 @@ import (
 
 const (
 	nonceLen = 24 // NaCl nonce size
+
+	// Overhead is how much longer Encrypt makes a message: the nonce and
+	// the authentication tag
+	Overhead = nonceLen + secretbox.Overhead
 )
 
 var (
@@ -19,6 +23,12 @@ var (
 // Encrypt encrypts plaintext using NaCl SecretBox with a random nonce.
 // Returns: nonce (24 bytes) || ciphertext (with 16-byte auth tag)
 func Encrypt(plaintext []byte, key *[32]byte) ([]byte, error) {
+	return EncryptAppend(make([]byte, 0, Overhead+len(plaintext)), plaintext, key)
+}
+
+// EncryptAppend is Encrypt appending to dst, so callers can reuse a buffer.
+// dst must not overlap plaintext.
+func EncryptAppend(dst, plaintext []byte, key *[32]byte) ([]byte, error) {
 	var nonce [nonceLen]byte
 	if _, err := rand.Read(nonce[:]); err != nil {
 		return nil, err
@@ -26,24 +36,27 @@ func Encrypt(plaintext []byte, key *[32]byte) ([]byte, error) {
 
 	// secretbox.Seal appends the encrypted data to the first argument
 	// Format: nonce || ciphertext
-	encrypted := make([]byte, nonceLen)
-	copy(encrypted, nonce[:])
-	encrypted = secretbox.Seal(encrypted, plaintext, &nonce, key)
-
-	return encrypted, nil
+	dst = append(dst, nonce[:]...)
+	return secretbox.Seal(dst, plaintext, &nonce, key), nil
 }
 
 // Decrypt decrypts ciphertext encrypted with Encrypt.
 // Expects: nonce (24 bytes) || ciphertext (with 16-byte auth tag)
 func Decrypt(ciphertext []byte, key *[32]byte) ([]byte, error) {
-	if len(ciphertext) < nonceLen+secretbox.Overhead {
+	return DecryptAppend(nil, ciphertext, key)
+}
+
+// DecryptAppend is Decrypt appending to dst, so callers can reuse a buffer.
+// dst must not overlap ciphertext.
+func DecryptAppend(dst, ciphertext []byte, key *[32]byte) ([]byte, error) {
+	if len(ciphertext) < Overhead {
 		return nil, ErrCiphertextShort
 	}
 
 	var nonce [nonceLen]byte
 	copy(nonce[:], ciphertext[:nonceLen])
 
-	plaintext, ok := secretbox.Open(nil, ciphertext[nonceLen:], &nonce, key)
+	plaintext, ok := secretbox.Open(dst, ciphertext[nonceLen:], &nonce, key)
 	if !ok {
 		return nil, ErrDecryptionFailed
 	}
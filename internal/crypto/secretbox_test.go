@@ -125,3 +125,27 @@ func TestEncryptEmptyMessage(t *testing.T) {
 		t.Errorf("expected empty decrypted, got %v", decrypted)
 	}
 }
+
+func TestEncryptAppendReusesBuffer(t *testing.T) {
+	key := DeriveKey("test", []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+	buf := make([]byte, 0, 256)
+
+	sealed, err := EncryptAppend(buf, []byte("frame"), &key)
+	if err != nil {
+		t.Fatalf("EncryptAppend failed: %v", err)
+	}
+	if len(sealed) != Overhead+len("frame") {
+		t.Errorf("sealed length = %d, want %d", len(sealed), Overhead+len("frame"))
+	}
+	if &sealed[0] != &buf[:1][0] {
+		t.Error("EncryptAppend did not use the buffer's spare capacity")
+	}
+
+	plaintext, err := DecryptAppend(make([]byte, 0, 256), sealed, &key)
+	if err != nil {
+		t.Fatalf("DecryptAppend failed: %v", err)
+	}
+	if string(plaintext) != "frame" {
+		t.Errorf("DecryptAppend = %q, want %q", plaintext, "frame")
+	}
+}
@@ -0,0 +1,110 @@
+package crypto
+
+// passphraseWords is the word list for GeneratePassphrase: 1024 short,
+// common English words (10 bits each), chosen to be easy to spell and read
+// aloud, with homophones left out.
+var passphraseWords = [1024]string{
+	"able", "acid", "acorn", "acre", "actor", "adapt", "admit", "adobe", "adult", "after",
+	"again", "agent", "agree", "ahead", "aim", "alarm", "album", "alert", "alien", "alley",
+	"allow", "almond", "alpha", "amber", "amend", "ample", "anchor", "angel", "angle", "ankle",
+	"apple", "april", "apron", "arch", "arena", "argue", "armor", "army", "aroma", "arrow",
+	"art", "aspen", "atlas", "atom", "attic", "audio", "august", "aunt", "autumn", "avenue",
+	"awake", "award", "axis", "bacon", "badge", "bagel", "baker", "ball", "bamboo", "banana",
+	"band", "banjo", "bank", "barn", "baron", "barrel", "basil", "basin", "basket", "batch",
+	"bath", "beach", "beacon", "bead", "beam", "bean", "beard", "beaver", "bed", "beef",
+	"beetle", "begin", "bell", "belt", "bench", "bike", "birch", "bird", "bison", "blade",
+	"blank", "blast", "blaze", "blend", "bless", "blimp", "blink", "bliss", "block", "bloom",
+	"blue", "blur", "boat", "body", "bolt", "bone", "bonus", "book", "boost", "boot",
+	"border", "boss", "bottle", "bounce", "bowl", "box", "brain", "branch", "brass", "brave",
+	"bread", "breeze", "brick", "bride", "bridge", "brief", "bright", "brisk", "brook", "broom",
+	"brown", "brush", "bubble", "bucket", "buddy", "budget", "bugle", "build", "bulb", "bunch",
+	"bundle", "bunny", "burger", "burst", "bus", "bush", "butter", "button", "buzz", "cabin",
+	"cable", "cactus", "cafe", "cage", "cake", "calm", "camel", "camera", "camp", "canal",
+	"candle", "candy", "canoe", "canvas", "canyon", "cape", "car", "carbon", "card", "cargo",
+	"carpet", "carrot", "cart", "case", "cash", "castle", "cat", "catch", "cedar", "cello",
+	"cement", "chair", "chalk", "champ", "chant", "charm", "chart", "chase", "cheek", "cheese",
+	"chef", "cherry", "chess", "chest", "chick", "chief", "chili", "chin", "chip", "choir",
+	"chorus", "cider", "cinema", "circle", "circus", "citrus", "city", "civic", "claim", "clam",
+	"clap", "clay", "clean", "clerk", "cliff", "climb", "clinic", "clip", "cloak", "clock",
+	"cloud", "clover", "clown", "club", "coach", "coast", "coat", "cobalt", "cocoa", "code",
+	"coffee", "coil", "coin", "cola", "collar", "comet", "comic", "cone", "coral", "cord",
+	"cork", "corn", "cotton", "couch", "cougar", "count", "cousin", "cover", "cow", "cowboy",
+	"crab", "craft", "crane", "crater", "crayon", "cream", "crest", "crew", "crisp", "crop",
+	"crow", "crown", "crumb", "crust", "cube", "cuckoo", "cup", "curb", "curl", "curve",
+	"cycle", "cymbal", "daisy", "dance", "dash", "dawn", "deer", "delta", "denim", "dent",
+	"depot", "desert", "desk", "detail", "dial", "diary", "diesel", "dime", "diner", "dingo",
+	"dinner", "disco", "dish", "dive", "dock", "doctor", "dodge", "dome", "donkey", "donut",
+	"door", "dove", "dozen", "draft", "dragon", "drama", "drift", "drill", "drink", "drum",
+	"duck", "dune", "dust", "eager", "eagle", "earth", "easel", "east", "echo", "edge",
+	"eel", "effort", "egg", "elbow", "elder", "elm", "ember", "emblem", "enjoy", "entry",
+	"envoy", "epic", "equal", "era", "errand", "essay", "ethic", "event", "exact", "exit",
+	"expert", "extra", "fabric", "face", "fact", "fairy", "falcon", "fame", "fancy", "farm",
+	"fault", "fawn", "feast", "fence", "fern", "ferry", "fever", "fiber", "fiddle", "field",
+	"fig", "film", "finch", "finger", "fire", "fish", "flag", "flame", "flash", "flask",
+	"fleet", "flint", "flock", "flood", "floor", "flower", "flute", "foam", "focus", "fog",
+	"folk", "font", "forest", "forge", "fork", "fort", "fossil", "fox", "frame", "fresh",
+	"frog", "frost", "fruit", "fudge", "fuel", "fun", "funnel", "gadget", "galaxy", "gallon",
+	"game", "garage", "garden", "garlic", "gate", "gauge", "gear", "gecko", "gem", "genie",
+	"giant", "gift", "ginger", "glass", "glide", "globe", "glove", "glow", "glue", "goat",
+	"gold", "golf", "gong", "goose", "grace", "grain", "grape", "graph", "grass", "gravel",
+	"gravy", "green", "grid", "grill", "grin", "grove", "guard", "guest", "guide", "guitar",
+	"gull", "gum", "guru", "gust", "habit", "hammer", "hand", "harbor", "harp", "hat",
+	"hawk", "hazel", "head", "heart", "hedge", "helmet", "herb", "hero", "heron", "hill",
+	"hinge", "hippo", "hive", "hobby", "hockey", "holly", "home", "honey", "hood", "hook",
+	"hope", "horn", "horse", "host", "hotel", "hound", "house", "hub", "hug", "hull",
+	"human", "humor", "hunt", "hut", "ice", "icon", "idea", "igloo", "image", "inch",
+	"index", "ink", "inlet", "input", "iris", "iron", "island", "ivory", "ivy", "jacket",
+	"jaguar", "jam", "jar", "jazz", "jeans", "jelly", "jewel", "jigsaw", "job", "jockey",
+	"join", "joke", "jolly", "joy", "juice", "july", "jumbo", "jump", "jungle", "junior",
+	"kayak", "keen", "kettle", "key", "kick", "kid", "king", "kiosk", "kit", "kite",
+	"kitten", "kiwi", "knee", "knife", "knob", "koala", "label", "lace", "ladder", "lady",
+	"lagoon", "lake", "lamb", "lamp", "land", "lane", "laptop", "lark", "laser", "latch",
+	"lava", "lawn", "layer", "leaf", "lemon", "lens", "letter", "level", "lever", "lid",
+	"light", "lilac", "lily", "lime", "linen", "lion", "lizard", "llama", "loaf", "lobby",
+	"locket", "lodge", "logic", "lotus", "lounge", "loyal", "lucky", "lumber", "lunar", "lunch",
+	"lyric", "macaw", "magic", "magnet", "mango", "manor", "maple", "marble", "march", "market",
+	"mask", "mason", "master", "match", "meadow", "melody", "melon", "menu", "merit", "meteor",
+	"middle", "mile", "milk", "mill", "mimic", "mint", "mirror", "mitten", "mixer", "model",
+	"modem", "mole", "monk", "monkey", "month", "moon", "moose", "mosaic", "moss", "motel",
+	"moth", "motor", "mouse", "mouth", "movie", "mud", "muffin", "mule", "mural", "museum",
+	"music", "myth", "nail", "name", "napkin", "narrow", "navy", "neck", "nectar", "needle",
+	"nest", "net", "nickel", "night", "ninja", "noble", "noodle", "north", "nose", "note",
+	"novel", "number", "nurse", "nut", "nylon", "oak", "oasis", "oat", "ocean", "octave",
+	"olive", "omega", "onion", "opal", "opera", "orange", "orbit", "orchid", "order", "organ",
+	"otter", "outfit", "oval", "oven", "owl", "oxygen", "oyster", "paddle", "page", "paint",
+	"palace", "palm", "panda", "panel", "paper", "parade", "park", "parrot", "party", "pasta",
+	"patch", "path", "peach", "peanut", "pearl", "pebble", "pecan", "pedal", "pencil", "pepper",
+	"piano", "pickle", "picnic", "pigeon", "pillow", "pilot", "pine", "pink", "pipe", "pirate",
+	"pixel", "pizza", "planet", "plant", "plate", "plaza", "plum", "plume", "poem", "poet",
+	"polar", "pony", "poodle", "pool", "poppy", "porch", "port", "potato", "powder", "prism",
+	"prize", "proud", "puddle", "pulse", "puma", "pump", "puppet", "puppy", "purple", "puzzle",
+	"quail", "quart", "queen", "quest", "quick", "quiet", "quill", "quilt", "quiz", "rabbit",
+	"radar", "radio", "raft", "rail", "raisin", "rally", "ranch", "range", "rapid", "raven",
+	"razor", "recipe", "reef", "relay", "relic", "remedy", "rhino", "ribbon", "rice", "riddle",
+	"ridge", "ring", "ripple", "river", "robin", "robot", "rocket", "rodeo", "roof", "rookie",
+	"room", "rope", "rotor", "round", "route", "rover", "royal", "ruby", "rudder", "rug",
+	"ruler", "rumble", "runway", "rust", "saddle", "safari", "saga", "salad", "salmon", "salon",
+	"salt", "sample", "sand", "sandal", "satin", "sauce", "sauna", "scale", "scarf", "school",
+	"scoop", "scout", "screen", "script", "scroll", "seal", "season", "seed", "shadow", "shark",
+	"sheep", "shelf", "shell", "shield", "ship", "shirt", "shoe", "shore", "shovel", "shrimp",
+	"siren", "sister", "sketch", "ski", "skirt", "sky", "slate", "sled", "slope", "smile",
+	"smoke", "snack", "snail", "snake", "snow", "soap", "soccer", "sock", "sofa", "soil",
+	"solar", "sonic", "soup", "south", "spark", "speech", "sphere", "spice", "spider", "spike",
+	"spine", "spiral", "sponge", "spoon", "sport", "spring", "sprout", "spruce", "squid", "stable",
+	"stage", "stairs", "stamp", "star", "statue", "steam", "stem", "stereo", "stick", "stone",
+	"stool", "storm", "story", "stove", "straw", "stream", "street", "string", "studio", "sugar",
+	"suit", "summer", "summit", "sunset", "surf", "swan", "swing", "syrup", "table", "tablet",
+	"tackle", "talent", "tango", "tank", "tape", "target", "taxi", "temple", "tennis", "tent",
+	"thorn", "thread", "throne", "thumb", "ticket", "tiger", "tile", "timber", "toast", "token",
+	"tomato", "tongue", "tool", "topaz", "torch", "totem", "tower", "town", "toy", "track",
+	"trail", "train", "tram", "travel", "tray", "tree", "trend", "trick", "trophy", "trout",
+	"truck", "trunk", "tulip", "tuna", "tundra", "tunnel", "turkey", "turtle", "tutor", "twig",
+	"twin", "uncle", "union", "unit", "upper", "urban", "utopia", "vacuum", "valley", "valve",
+	"vapor", "vase", "vault", "velvet", "vendor", "venue", "verse", "vessel", "vest", "video",
+	"view", "villa", "vine", "violet", "violin", "visa", "visit", "vista", "vivid", "voice",
+	"voyage", "wafer", "wagon", "waiter", "walnut", "walrus", "wand", "wave", "wax", "weasel",
+	"weaver", "web", "wedge", "wheat", "wheel", "willow", "wind", "window", "wing", "winter",
+	"wizard", "wolf", "wombat", "wonder", "wool", "world", "worm", "wreath", "wrist", "yacht",
+	"yard", "yarn", "yeast", "yellow", "yoga", "yogurt", "young", "zebra", "zero", "zigzag",
+	"zinc", "zipper", "zone", "zoo",
+}
@@ -11,14 +11,21 @@ import (
 	"sync"
 	"syscall"
 	"time"
+
+	"github.com/artpar/terminal-tunnel/internal/server"
 )
 
 // Default timeouts
 const (
 	DefaultIdleTimeout    = 30 * time.Minute // Cleanup disconnected sessions after 30 mins
 	DefaultCleanupInterval = 1 * time.Minute  // Check for idle sessions every minute
+	DefaultIdleWarning     = 1 * time.Minute  // Warn viewers a minute before cleanup
 )
 
+// EnvIdleWarning overrides DefaultIdleWarning, e.g. "2m"; "0" turns the
+// warning off
+const EnvIdleWarning = "TT_IDLE_WARNING"
+
 // Daemon represents the terminal-tunnel daemon
 type Daemon struct {
 	statePath       string
@@ -31,6 +38,7 @@ type Daemon struct {
 	shutdownCh      chan struct{}
 	idleTimeout     time.Duration // How long a disconnected session can remain idle
 	cleanupInterval time.Duration // How often to check for idle sessions
+	idleWarning     time.Duration // How long before cleanup viewers are warned; 0 = never
 }
 
 // NewDaemon creates a new daemon instance
@@ -49,6 +57,7 @@ func NewDaemon() (*Daemon, error) {
 		shutdownCh:      make(chan struct{}),
 		idleTimeout:     DefaultIdleTimeout,
 		cleanupInterval: DefaultCleanupInterval,
+		idleWarning:     idleWarningFromEnv(),
 	}
 
 	d.sessions = NewSessionManager(d)
@@ -146,8 +155,15 @@ func (d *Daemon) handleConnection(conn net.Conn) {
 	// Set read deadline
 	_ = conn.SetReadDeadline(time.Now().Add(30 * time.Second))
 
-	reader := bufio.NewReader(conn)
-	line, err := reader.ReadBytes('\n')
+	line, files, err := readRequest(conn)
+	// Files a handler doesn't take are closed
+	defer func() {
+		for _, f := range files {
+			if f != nil {
+				_ = f.Close()
+			}
+		}
+	}()
 	if err != nil {
 		return
 	}
@@ -159,12 +175,19 @@ func (d *Daemon) handleConnection(conn net.Conn) {
 		return
 	}
 
-	resp := d.handleRequest(&req)
+	resp := d.handleRequest(&req, files)
 	d.sendResponse(conn, resp)
 }
 
-// handleRequest processes a request and returns a response
-func (d *Daemon) handleRequest(req *Request) *Response {
+// readLine reads one request line from conn
+func readLine(conn net.Conn) ([]byte, error) {
+	return bufio.NewReader(conn).ReadBytes('\n')
+}
+
+// handleRequest processes a request and returns a response. files holds
+// any descriptors sent with the request; a handler that keeps one sets its
+// entry to nil.
+func (d *Daemon) handleRequest(req *Request, files []*os.File) *Response {
 	switch req.Method {
 	case MethodSessionStart:
 		return d.handleSessionStart(req)
@@ -172,6 +195,28 @@ func (d *Daemon) handleRequest(req *Request) *Response {
 		return d.handleSessionStop(req)
 	case MethodSessionList:
 		return d.handleSessionList(req)
+	case MethodSessionInfo:
+		return d.handleSessionInfo(req)
+	case MethodSessionMark:
+		return d.handleSessionMark(req)
+	case MethodSessionDump:
+		return d.handleSessionDump(req)
+	case MethodSessionPause:
+		return d.handleSessionPause(req, true)
+	case MethodSessionResume:
+		return d.handleSessionPause(req, false)
+	case MethodSessionGrant:
+		return d.handleSessionGrant(req, true)
+	case MethodSessionRevoke:
+		return d.handleSessionGrant(req, false)
+	case MethodSessionStats:
+		return d.handleSessionStats(req)
+	case MethodSessionWho:
+		return d.handleSessionWho(req)
+	case MethodSessionWait:
+		return d.handleSessionWait(req)
+	case MethodSessionAdopt:
+		return d.handleSessionAdopt(req, files)
 	case MethodDaemonStatus:
 		return d.handleDaemonStatus(req)
 	case MethodDaemonStop:
@@ -195,24 +240,59 @@ func (d *Daemon) handleSessionStart(req *Request) *Response {
 		return NewErrorResponse(req.ID, ErrCodeSessionCreateFailed, err.Error())
 	}
 
-	result := StartSessionResult{
-		ID:         info.ID,
-		ShortCode:  info.ShortCode,
-		Password:   info.Password,
-		ClientURL:  info.ClientURL,
-		Status:     string(info.Status),
-		Public:     info.Public,
-		ViewerCode: info.ViewerCode,
-		ViewerURL:  info.ViewerURL,
+	resp, err := NewSuccessResponse(req.ID, newStartSessionResult(info))
+	if err != nil {
+		return NewErrorResponse(req.ID, ErrCodeInternalError, err.Error())
+	}
+	return resp
+}
+
+// handleSessionAdopt handles session.adopt requests, which carry the PTY
+// master of a running shell in files
+func (d *Daemon) handleSessionAdopt(req *Request, files []*os.File) *Response {
+	var params AdoptSessionParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return NewErrorResponse(req.ID, ErrCodeInvalidParams, "invalid params: "+err.Error())
+	}
+	if len(files) != 1 {
+		return NewErrorResponse(req.ID, ErrCodeInvalidParams, "session.adopt needs the PTY sent with the request")
 	}
 
-	resp, err := NewSuccessResponse(req.ID, result)
+	pty, err := server.AdoptPTY(files[0], params.ShellPID)
+	if err != nil {
+		return NewErrorResponse(req.ID, ErrCodeSessionCreateFailed, err.Error())
+	}
+	files[0] = nil // Owned by the session now
+
+	info, err := d.sessions.AdoptSession(params.StartSessionParams, pty)
+	if err != nil {
+		_ = pty.Close()
+		return NewErrorResponse(req.ID, ErrCodeSessionCreateFailed, err.Error())
+	}
+
+	resp, err := NewSuccessResponse(req.ID, newStartSessionResult(info))
 	if err != nil {
 		return NewErrorResponse(req.ID, ErrCodeInternalError, err.Error())
 	}
 	return resp
 }
 
+// newStartSessionResult converts a started session for the wire
+func newStartSessionResult(info *SessionStartResult) StartSessionResult {
+	return StartSessionResult{
+		ID:          info.ID,
+		ShortCode:   info.ShortCode,
+		Password:    info.Password,
+		ClientURL:   info.ClientURL,
+		Status:      string(info.Status),
+		Public:      info.Public,
+		ViewerCode:  info.ViewerCode,
+		ViewerURL:   info.ViewerURL,
+		Fingerprint: info.Fingerprint,
+		LANURL:      info.LANURL,
+	}
+}
+
 // handleSessionStop handles session.stop requests
 func (d *Daemon) handleSessionStop(req *Request) *Response {
 	var params StopSessionParams
@@ -251,14 +331,177 @@ func (d *Daemon) handleSessionList(req *Request) *Response {
 	return resp
 }
 
+// handleSessionInfo handles session.info requests
+func (d *Daemon) handleSessionInfo(req *Request) *Response {
+	var params SessionInfoParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return NewErrorResponse(req.ID, ErrCodeInvalidParams, "invalid params: "+err.Error())
+	}
+
+	details, err := d.sessions.SessionDetails(params.ID)
+	if err != nil {
+		return NewErrorResponse(req.ID, ErrCodeSessionNotFound, err.Error())
+	}
+
+	resp, err := NewSuccessResponse(req.ID, details)
+	if err != nil {
+		return NewErrorResponse(req.ID, ErrCodeInternalError, err.Error())
+	}
+	return resp
+}
+
+// handleSessionMark handles session.mark requests
+func (d *Daemon) handleSessionMark(req *Request) *Response {
+	var params SessionMarkParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return NewErrorResponse(req.ID, ErrCodeInvalidParams, "invalid params: "+err.Error())
+	}
+
+	result, err := d.sessions.AddMarker(params.ID, params.Label)
+	if err != nil {
+		return NewErrorResponse(req.ID, ErrCodeSessionNotFound, err.Error())
+	}
+
+	resp, err := NewSuccessResponse(req.ID, result)
+	if err != nil {
+		return NewErrorResponse(req.ID, ErrCodeInternalError, err.Error())
+	}
+	return resp
+}
+
+// handleSessionDump handles session.dump-recording requests
+func (d *Daemon) handleSessionDump(req *Request) *Response {
+	var params SessionDumpParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return NewErrorResponse(req.ID, ErrCodeInvalidParams, "invalid params: "+err.Error())
+	}
+
+	result, err := d.sessions.DumpRecording(params.ID, params.Path)
+	if err != nil {
+		return NewErrorResponse(req.ID, ErrCodeSessionNotFound, err.Error())
+	}
+
+	resp, err := NewSuccessResponse(req.ID, result)
+	if err != nil {
+		return NewErrorResponse(req.ID, ErrCodeInternalError, err.Error())
+	}
+	return resp
+}
+
+// handleSessionGrant handles session.grant and session.revoke requests
+func (d *Daemon) handleSessionGrant(req *Request, grant bool) *Response {
+	var params SessionGrantParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return NewErrorResponse(req.ID, ErrCodeInvalidParams, "invalid params: "+err.Error())
+	}
+
+	result, err := d.sessions.SetViewerWrite(params.ID, params.Viewer, grant)
+	if err != nil {
+		return NewErrorResponse(req.ID, ErrCodeSessionNotFound, err.Error())
+	}
+
+	resp, err := NewSuccessResponse(req.ID, result)
+	if err != nil {
+		return NewErrorResponse(req.ID, ErrCodeInternalError, err.Error())
+	}
+	return resp
+}
+
+// handleSessionPause handles session.pause and session.resume requests
+func (d *Daemon) handleSessionPause(req *Request, pause bool) *Response {
+	var params SessionPauseParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return NewErrorResponse(req.ID, ErrCodeInvalidParams, "invalid params: "+err.Error())
+	}
+
+	result, err := d.sessions.SetSharingPaused(params.ID, pause)
+	if err != nil {
+		return NewErrorResponse(req.ID, ErrCodeSessionNotFound, err.Error())
+	}
+
+	resp, err := NewSuccessResponse(req.ID, result)
+	if err != nil {
+		return NewErrorResponse(req.ID, ErrCodeInternalError, err.Error())
+	}
+	return resp
+}
+
+// handleSessionWait handles session.wait requests, holding them until a
+// client connects or the timeout passes
+func (d *Daemon) handleSessionWait(req *Request) *Response {
+	var params SessionWaitParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return NewErrorResponse(req.ID, ErrCodeInvalidParams, "invalid params: "+err.Error())
+	}
+
+	result, err := d.sessions.WaitForClient(params.ID, params.Timeout)
+	if err != nil {
+		return NewErrorResponse(req.ID, ErrCodeSessionNotFound, err.Error())
+	}
+
+	resp, err := NewSuccessResponse(req.ID, result)
+	if err != nil {
+		return NewErrorResponse(req.ID, ErrCodeInternalError, err.Error())
+	}
+	return resp
+}
+
+// handleSessionStats handles session.stats requests
+func (d *Daemon) handleSessionStats(req *Request) *Response {
+	var params SessionStatsParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return NewErrorResponse(req.ID, ErrCodeInvalidParams, "invalid params: "+err.Error())
+	}
+
+	result, err := d.sessions.TransportStats(params.ID)
+	if err != nil {
+		return NewErrorResponse(req.ID, ErrCodeSessionNotFound, err.Error())
+	}
+
+	resp, err := NewSuccessResponse(req.ID, result)
+	if err != nil {
+		return NewErrorResponse(req.ID, ErrCodeInternalError, err.Error())
+	}
+	return resp
+}
+
+// handleSessionWho handles session.who requests
+func (d *Daemon) handleSessionWho(req *Request) *Response {
+	var params SessionWhoParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return NewErrorResponse(req.ID, ErrCodeInvalidParams, "invalid params: "+err.Error())
+	}
+
+	result, err := d.sessions.Connections(params.ID)
+	if err != nil {
+		return NewErrorResponse(req.ID, ErrCodeSessionNotFound, err.Error())
+	}
+
+	resp, err := NewSuccessResponse(req.ID, result)
+	if err != nil {
+		return NewErrorResponse(req.ID, ErrCodeInternalError, err.Error())
+	}
+	return resp
+}
+
 // handleDaemonStatus handles daemon.status requests
 func (d *Daemon) handleDaemonStatus(req *Request) *Response {
 	sessions := d.sessions.ListSessions()
 	activeCount := 0
+	var unstable, relayDown, relayDegraded []string
 	for _, s := range sessions {
 		if s.Status == StatusConnected {
 			activeCount++
 		}
+		if s.Reconnects > UnstableReconnectThreshold {
+			unstable = append(unstable, s.ShortCode)
+		}
+		if s.RelayDown {
+			relayDown = append(relayDown, s.ShortCode)
+		}
+		if s.RelayDegraded {
+			relayDegraded = append(relayDegraded, s.ShortCode)
+		}
 	}
 
 	uptime := time.Since(d.startTime).Round(time.Second).String()
@@ -269,6 +512,12 @@ func (d *Daemon) handleDaemonStatus(req *Request) *Response {
 		Uptime:       uptime,
 		SessionCount: len(sessions),
 		ActiveCount:  activeCount,
+
+		TotalReconnects:   d.sessions.TotalReconnects(),
+		UnstableSessions:  unstable,
+		RelayDownSessions: relayDown,
+
+		RelayDegradedSessions: relayDegraded,
 	}
 
 	resp, err := NewSuccessResponse(req.ID, result)
@@ -301,13 +550,29 @@ func (d *Daemon) handleDaemonShutdown(req *Request) *Response {
 	return resp
 }
 
-// sendResponse sends a response to the client
+// responseWriteTimeout bounds sending a response, so a client that stops
+// reading cannot hold its handler forever
+const responseWriteTimeout = 30 * time.Second
+
+// sendResponse sends a response to the client as one JSON line. Nothing
+// can be reported to a client whose connection fails, so failures are
+// logged; the client sees the response cut short and says so.
 func (d *Daemon) sendResponse(conn net.Conn, resp *Response) {
 	data, err := json.Marshal(resp)
 	if err != nil {
-		return
+		fmt.Fprintf(os.Stderr, "Failed to encode response %s: %v\n", resp.ID, err)
+		// Answer anyway rather than leave the client waiting
+		data, _ = json.Marshal(NewErrorResponse(resp.ID, ErrCodeInternalError, "failed to encode response"))
+	}
+
+	_ = conn.SetWriteDeadline(time.Now().Add(responseWriteTimeout))
+	// Flush writes all of the buffer or returns why it could not
+	w := bufio.NewWriterSize(conn, len(data)+1)
+	_, _ = w.Write(data)
+	_ = w.WriteByte('\n')
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to send response %s (%d bytes): %v\n", resp.ID, len(data)+1, err)
 	}
-	_, _ = conn.Write(append(data, '\n'))
 }
 
 // Shutdown gracefully shuts down the daemon
@@ -354,6 +619,7 @@ func (d *Daemon) cleanupLoop() {
 	for {
 		select {
 		case <-ticker.C:
+			d.sessions.WarnIdleSessions(d.idleTimeout, d.idleWarning, d.cleanupInterval)
 			cleaned := d.sessions.CleanupIdleSessions(d.idleTimeout)
 			if cleaned > 0 {
 				fmt.Printf("Cleaned up %d idle session(s)\n", cleaned)
@@ -364,6 +630,21 @@ func (d *Daemon) cleanupLoop() {
 	}
 }
 
+// idleWarningFromEnv returns the idle warning lead time from EnvIdleWarning,
+// or DefaultIdleWarning when it is unset or invalid
+func idleWarningFromEnv() time.Duration {
+	value := os.Getenv(EnvIdleWarning)
+	if value == "" {
+		return DefaultIdleWarning
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		fmt.Fprintf(os.Stderr, "Ignoring invalid %s=%q, using %v\n", EnvIdleWarning, value, DefaultIdleWarning)
+		return DefaultIdleWarning
+	}
+	return d
+}
+
 // GetIdleTimeout returns the configured idle timeout
 func (d *Daemon) GetIdleTimeout() time.Duration {
 	return d.idleTimeout
@@ -0,0 +1,63 @@
+//go:build !windows
+
+package daemon
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"syscall"
+)
+
+// maxRequestFiles is the most descriptors accepted with one request
+const maxRequestFiles = 4
+
+// readRequest reads one request line from conn, with any file descriptors
+// sent alongside it as SCM_RIGHTS (session.adopt sends a PTY this way)
+func readRequest(conn net.Conn) ([]byte, []*os.File, error) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		line, err := readLine(conn)
+		return line, nil, err
+	}
+
+	var line []byte
+	var files []*os.File
+	buf := make([]byte, 4096)
+	oob := make([]byte, syscall.CmsgSpace(4*maxRequestFiles))
+	for {
+		n, oobn, _, _, err := uc.ReadMsgUnix(buf, oob)
+		if oobn > 0 {
+			files = append(files, parseRights(oob[:oobn])...)
+		}
+		line = append(line, buf[:n]...)
+		if i := bytes.IndexByte(line, '\n'); i >= 0 {
+			return line[:i+1], files, nil
+		}
+		if err != nil {
+			return nil, files, err
+		}
+	}
+}
+
+// parseRights returns the descriptors in a control message as files
+func parseRights(oob []byte) []*os.File {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil
+	}
+	var files []*os.File
+	for _, msg := range msgs {
+		fds, err := syscall.ParseUnixRights(&msg)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			syscall.CloseOnExec(fd)
+			// Non-blocking so reads can time out like any PTY's
+			_ = syscall.SetNonblock(fd, true)
+			files = append(files, os.NewFile(uintptr(fd), "pty"))
+		}
+	}
+	return files
+}
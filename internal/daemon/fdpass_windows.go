@@ -0,0 +1,15 @@
+//go:build windows
+
+package daemon
+
+import (
+	"net"
+	"os"
+)
+
+// readRequest reads one request line from conn. Windows can't pass file
+// descriptors, so there are never any files.
+func readRequest(conn net.Conn) ([]byte, []*os.File, error) {
+	line, err := readLine(conn)
+	return line, nil, err
+}
@@ -0,0 +1,70 @@
+package daemon
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Session filter fields accepted by tt list --filter
+const (
+	FilterStatus = "status"
+	FilterName   = "name"
+	FilterCode   = "code"
+	FilterTag    = "tag"
+)
+
+// SessionFilter selects sessions by one field, e.g. "status=connected" or
+// "tag:env=prod"
+type SessionFilter struct {
+	Field string // FilterStatus, FilterName, FilterCode or FilterTag
+	Key   string // Tag key (FilterTag only)
+	Value string
+}
+
+// ParseSessionFilter parses a filter of the form field=value, where field
+// is status, name, code or tag:<key>
+func ParseSessionFilter(s string) (SessionFilter, error) {
+	field, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return SessionFilter{}, fmt.Errorf("invalid filter %q: want field=value, e.g. status=connected or tag:env=prod", s)
+	}
+	field = strings.TrimSpace(field)
+
+	if key, isTag := strings.CutPrefix(field, FilterTag+":"); isTag {
+		if key == "" {
+			return SessionFilter{}, fmt.Errorf("invalid filter %q: missing tag key", s)
+		}
+		return SessionFilter{Field: FilterTag, Key: key, Value: value}, nil
+	}
+	switch field {
+	case FilterStatus, FilterName, FilterCode:
+		return SessionFilter{Field: field, Value: value}, nil
+	}
+	return SessionFilter{}, fmt.Errorf("invalid filter %q: unknown field %q (use status, name, code or tag:<key>)", s, field)
+}
+
+// Match reports whether a session passes the filter
+func (f SessionFilter) Match(info SessionInfo) bool {
+	switch f.Field {
+	case FilterStatus:
+		return string(info.Status) == f.Value
+	case FilterName:
+		return info.Name == f.Value
+	case FilterCode:
+		return strings.EqualFold(info.ShortCode, f.Value)
+	case FilterTag:
+		value, ok := info.Tags[f.Key]
+		return ok && value == f.Value
+	}
+	return false
+}
+
+// ParseTag parses a session tag of the form key=value
+func ParseTag(s string) (key, value string, err error) {
+	key, value, ok := strings.Cut(s, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return "", "", fmt.Errorf("invalid tag %q: want key=value, e.g. env=prod", s)
+	}
+	return key, value, nil
+}
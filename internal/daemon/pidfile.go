@@ -6,11 +6,11 @@ import (
 	"path/filepath"
 	"strconv"
 	"syscall"
+
+	"github.com/artpar/terminal-tunnel/internal/paths"
 )
 
 const (
-	// DefaultStateDir is the default directory for daemon state
-	DefaultStateDir = ".tt"
 	// PIDFileName is the name of the PID file
 	PIDFileName = "tt.pid"
 	// SocketFileName is the name of the Unix socket
@@ -19,13 +19,9 @@ const (
 	SessionsDir = "sessions"
 )
 
-// GetStateDir returns the path to the state directory
+// GetStateDir returns the path to the state directory, see paths.DataDir
 func GetStateDir() string {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return filepath.Join(os.TempDir(), DefaultStateDir)
-	}
-	return filepath.Join(home, DefaultStateDir)
+	return paths.DataDir()
 }
 
 // GetPIDPath returns the path to the PID file
@@ -33,9 +29,9 @@ func GetPIDPath() string {
 	return filepath.Join(GetStateDir(), PIDFileName)
 }
 
-// GetSocketPath returns the path to the Unix socket
+// GetSocketPath returns the path to the Unix socket, see paths.SocketDir
 func GetSocketPath() string {
-	return filepath.Join(GetStateDir(), SocketFileName)
+	return filepath.Join(paths.SocketDir(SocketFileName), SocketFileName)
 }
 
 // GetSessionsDir returns the path to the sessions directory
@@ -53,6 +49,9 @@ func EnsureStateDir() error {
 	if err := os.MkdirAll(sessionsDir, 0700); err != nil {
 		return fmt.Errorf("failed to create sessions directory: %w", err)
 	}
+	if err := paths.EnsureSocketDir(SocketFileName); err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
 	return nil
 }
 
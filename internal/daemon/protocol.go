@@ -7,11 +7,22 @@ import (
 
 // RPC Methods
 const (
-	MethodSessionStart = "session.start"
-	MethodSessionStop  = "session.stop"
-	MethodSessionList  = "session.list"
-	MethodDaemonStatus = "daemon.status"
-	MethodDaemonStop   = "daemon.shutdown"
+	MethodSessionStart  = "session.start"
+	MethodSessionStop   = "session.stop"
+	MethodSessionList   = "session.list"
+	MethodSessionInfo   = "session.info"
+	MethodSessionMark   = "session.mark"
+	MethodSessionStats  = "session.stats"
+	MethodSessionWho    = "session.who"
+	MethodSessionPause  = "session.pause"
+	MethodSessionResume = "session.resume"
+	MethodSessionGrant  = "session.grant"
+	MethodSessionRevoke = "session.revoke"
+	MethodSessionAdopt  = "session.adopt"
+	MethodSessionDump   = "session.dump-recording"
+	MethodSessionWait   = "session.wait"
+	MethodDaemonStatus  = "daemon.status"
+	MethodDaemonStop    = "daemon.shutdown"
 )
 
 // Error codes
@@ -75,15 +86,99 @@ func NewSuccessResponse(id string, result interface{}) (*Response, error) {
 // StartSessionParams represents parameters for session.start
 type StartSessionParams struct {
 	Password string `json:"password,omitempty"` // Auto-generated if empty
-	Shell    string `json:"shell,omitempty"`    // Default to $SHELL
+	Shell    string `json:"shell,omitempty"`    // Default to the user's login shell
 	NoTURN   bool   `json:"no_turn,omitempty"`  // Disable TURN relay (P2P only)
 	Public   bool   `json:"public,omitempty"`   // Enable public viewer mode (read-only viewers without password)
 	Record   bool   `json:"record,omitempty"`   // Enable session recording
+	Name     string `json:"name,omitempty"`     // Optional friendly name usable in place of ID/code
+	Code     string `json:"code,omitempty"`     // Ask the relay for this session code instead of a generated one
+
+	Tags map[string]string `json:"tags,omitempty"` // Key/value labels for filtering tt list
+
+	GuardBinary bool     `json:"guard_binary,omitempty"` // Pause streaming on binary output
+	OutputSink  string   `json:"output_sink,omitempty"`  // Also stream output to a syslog, webhook or pipe destination
+	Command     []string `json:"command,omitempty"`      // Run this program directly instead of a shell
+	ReadOnly    bool     `json:"read_only,omitempty"`    // Drop the client's input
+
+	NoViewerRecording bool `json:"no_viewer_recording,omitempty"` // Ask public viewers not to offer recording
+	NoJoinNotices     bool `json:"no_join_notices,omitempty"`     // Don't tell the client when viewers join or leave
+	MaxViewersPerIP   int  `json:"max_viewers_per_ip,omitempty"`  // Public viewers allowed from one address (0 = default, negative = no limit)
+
+	Isolate          bool     `json:"isolate,omitempty"`           // Run in new Linux namespaces
+	ContainerRuntime []string `json:"container_runtime,omitempty"` // Start the session in a container with this command prefix
+
+	CoalesceWindow time.Duration `json:"coalesce_window,omitempty"` // Output coalescing window (0 = default, negative = off)
+	BellNotify     bool          `json:"bell_notify,omitempty"`     // Forward terminal bells to clients
+	LAN            bool          `json:"lan,omitempty"`             // Also signal directly on the local network
+
+	AllowWSFallback bool `json:"allow_ws_fallback,omitempty"` // Let clients fall back to a WebSocket through the relay
+
+	Rows uint16 `json:"rows,omitempty"` // Initial PTY size until a client reports its own (0 = 24)
+	Cols uint16 `json:"cols,omitempty"` // (0 = 80)
+
+	GeoIP []string `json:"geoip,omitempty"` // MaxMind DB files describing where clients connect from
+	KDF   string   `json:"kdf,omitempty"`   // Password KDF: default, argon2id, scrypt or pbkdf2
+}
+
+// AdoptSessionParams represents parameters for session.adopt, which takes
+// over a running shell from an interactive tt start. The PTY master is sent
+// with the request as SCM_RIGHTS ancillary data.
+type AdoptSessionParams struct {
+	StartSessionParams
+	ShellPID int `json:"shell_pid"` // The shell on the PTY
 }
 
 // StopSessionParams represents parameters for session.stop
 type StopSessionParams struct {
-	ID string `json:"id"` // Session ID or short code
+	ID string `json:"id"` // Session ID, short code or name
+}
+
+// SessionInfoParams represents parameters for session.info
+type SessionInfoParams struct {
+	ID string `json:"id"` // Session ID, short code or name
+}
+
+// SessionMarkParams represents parameters for session.mark
+type SessionMarkParams struct {
+	ID    string `json:"id"`              // Session ID, short code or name
+	Label string `json:"label,omitempty"` // Marker label shown by players
+}
+
+// SessionDumpParams represents parameters for session.dump-recording
+type SessionDumpParams struct {
+	ID   string `json:"id"`   // Session ID, short code or name
+	Path string `json:"path"` // Absolute path to copy the recording to
+}
+
+// SessionPauseParams represents parameters for session.pause and session.resume
+type SessionPauseParams struct {
+	ID string `json:"id"` // Session ID, short code or name
+}
+
+// SessionGrantParams represents parameters for session.grant and session.revoke
+type SessionGrantParams struct {
+	ID     string `json:"id"`               // Session ID, short code or name
+	Viewer int    `json:"viewer,omitempty"` // session.grant: viewer number; 0 picks the only one connected
+}
+
+// SessionWaitParams represents parameters for session.wait
+type SessionWaitParams struct {
+	ID      string        `json:"id"`                // Session ID, short code or name
+	Timeout time.Duration `json:"timeout,omitempty"` // Up to MaxSessionWait (0 = MaxSessionWait)
+}
+
+// MaxSessionWait is the longest one session.wait request is held open,
+// within the client's deadline for an answer. Longer waits repeat it.
+const MaxSessionWait = 25 * time.Second
+
+// SessionStatsParams represents parameters for session.stats
+type SessionStatsParams struct {
+	ID string `json:"id"` // Session ID, short code or name
+}
+
+// SessionWhoParams represents parameters for session.who
+type SessionWhoParams struct {
+	ID string `json:"id"` // Session ID, short code or name
 }
 
 // --- Response Results ---
@@ -94,34 +189,56 @@ type SessionStatus string
 const (
 	StatusWaiting      SessionStatus = "waiting"
 	StatusConnected    SessionStatus = "connected"
+	StatusReconnecting SessionStatus = "reconnecting" // Client dropped, output buffered while it reconnects
 	StatusDisconnected SessionStatus = "disconnected"
 	StatusRecovered    SessionStatus = "recovered" // Shell alive but no signaling after daemon restart
+	StatusFailed       SessionStatus = "failed"    // Session could not start (see Error)
 )
 
 // SessionInfo represents information about a session
 type SessionInfo struct {
-	ID         string        `json:"id"`
-	ShortCode  string        `json:"short_code"`
-	Status     SessionStatus `json:"status"`
-	Shell      string        `json:"shell"`
-	CreatedAt  time.Time     `json:"created_at"`
-	LastSeen   time.Time     `json:"last_seen"`
-	ClientURL  string        `json:"client_url"`
-	Public     bool          `json:"public,omitempty"`      // True if public viewer mode is enabled
-	ViewerCode string        `json:"viewer_code,omitempty"` // Code for public viewers (ends with V)
-	ViewerURL  string        `json:"viewer_url,omitempty"`  // URL for public viewers
+	ID         string            `json:"id"`
+	ShortCode  string            `json:"short_code"`
+	Name       string            `json:"name,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"` // Key/value labels from tt start --tag
+	Status     SessionStatus     `json:"status"`
+	Shell      string            `json:"shell"`
+	CreatedAt  time.Time         `json:"created_at"`
+	LastSeen   time.Time         `json:"last_seen"`
+	ClientURL  string            `json:"client_url"`
+	Public     bool              `json:"public,omitempty"`      // True if public viewer mode is enabled
+	ViewerCode string            `json:"viewer_code,omitempty"` // Code for public viewers (ends with V)
+	ViewerURL  string            `json:"viewer_url,omitempty"`  // URL for public viewers
+	Reconnects int64             `json:"reconnects,omitempty"`  // Client reconnections since start
+	Error      string            `json:"error,omitempty"`       // Why the session failed (StatusFailed)
+	RelayDown  bool              `json:"relay_down,omitempty"`  // Relay requests paused after repeated failures
+
+	RelayDegraded bool   `json:"relay_degraded,omitempty"` // Relay heartbeats failing, so reconnecting clients may not find the session
+	RelayError    string `json:"relay_error,omitempty"`    // Why the last heartbeat failed
 }
 
+// RelayDegradedHeartbeats is how many relay heartbeats must fail in a row
+// for a session's relay to be reported as degraded. Heartbeats are 4
+// minutes apart and the relay forgets a code after 5, so by the second the
+// code has expired.
+const RelayDegradedHeartbeats = 2
+
+// UnstableReconnectThreshold is the reconnect count above which a session
+// is reported as having an unstable connection
+const UnstableReconnectThreshold = 10
+
 // StartSessionResult represents the result of session.start
 type StartSessionResult struct {
-	ID         string `json:"id"`
-	ShortCode  string `json:"short_code"`
-	Password   string `json:"password"` // Return generated password
-	ClientURL  string `json:"client_url"`
-	Status     string `json:"status"`
-	Public     bool   `json:"public,omitempty"`      // True if public viewer mode is enabled
-	ViewerCode string `json:"viewer_code,omitempty"` // Code for public viewers (ends with V)
-	ViewerURL  string `json:"viewer_url,omitempty"`  // URL for public viewers
+	ID          string `json:"id"`
+	ShortCode   string `json:"short_code"`
+	Password    string `json:"password"` // Return generated password
+	ClientURL   string `json:"client_url"`
+	Status      string `json:"status"`
+	Public      bool   `json:"public,omitempty"`      // True if public viewer mode is enabled
+	ViewerCode  string `json:"viewer_code,omitempty"` // Code for public viewers (ends with V)
+	ViewerURL   string `json:"viewer_url,omitempty"`  // URL for public viewers
+	Fingerprint string `json:"fingerprint,omitempty"` // DTLS certificate fingerprint
+	LANURL      string `json:"lan_url,omitempty"`     // Web client served by the host on the local network
 }
 
 // StopSessionResult represents the result of session.stop
@@ -130,6 +247,109 @@ type StopSessionResult struct {
 	Message string `json:"message,omitempty"`
 }
 
+// SessionMarkResult represents the result of session.mark
+type SessionMarkResult struct {
+	Recording string  `json:"recording"` // Path of the recording the marker was written to
+	Time      float64 `json:"time"`      // Seconds from the start of the recording
+}
+
+// SessionDumpResult represents the result of session.dump-recording
+type SessionDumpResult struct {
+	Recording string  `json:"recording"` // Path of the recording that was copied
+	Path      string  `json:"path"`      // Path the copy was written to
+	Bytes     int64   `json:"bytes"`     // Size of the copy
+	Duration  float64 `json:"duration"`  // Seconds of the session the copy covers
+}
+
+// SessionWaitResult represents the result of session.wait
+type SessionWaitResult struct {
+	Connected bool          `json:"connected"` // A client has connected since the session started
+	Status    SessionStatus `json:"status"`
+}
+
+// SessionPauseResult represents the result of session.pause and session.resume
+type SessionPauseResult struct {
+	Paused  bool `json:"paused"`  // Whether sharing is now paused
+	Changed bool `json:"changed"` // False if it already was in that state
+}
+
+// SessionGrantResult represents the result of session.grant and session.revoke
+type SessionGrantResult struct {
+	Granted bool `json:"granted"`          // Whether the viewer can now type
+	Changed bool `json:"changed"`          // False if it already was in that state
+	Viewer  int  `json:"viewer,omitempty"` // session.grant: the viewer that can type
+}
+
+// SessionDetails represents the result of session.info: the summary from
+// session.list plus live connection details
+type SessionDetails struct {
+	SessionInfo
+	ShellPID       int    `json:"shell_pid,omitempty"`
+	BytesIn        int64  `json:"bytes_in"`                  // Client input written to the PTY
+	BytesOut       int64  `json:"bytes_out"`                 // Output sent to the client
+	ConnectionType string `json:"connection_type,omitempty"` // "p2p", "relay" (TURN) or "websocket" (relay pipe) while connected
+	Viewers        int    `json:"viewers"`                   // Connected read-only viewers
+	Encoding       string `json:"encoding,omitempty"`        // "utf-8", "legacy-8bit" or "binary-heavy", from sampled output
+
+	// Where the client connects from, while connected
+	ClientAddress   string `json:"client_address,omitempty"`   // Remote ICE candidate address (a TURN server for "relay")
+	ClientCandidate string `json:"client_candidate,omitempty"` // Remote ICE candidate type
+	ClientLocation  string `json:"client_location,omitempty"`  // From the GeoIP databases
+	ClientOrigin    string `json:"client_origin,omitempty"`    // All of the above, for display
+
+	// Recent peer and ICE state changes of the client connection, oldest
+	// first, for looking into dropped or failed connections
+	States []StateTransition `json:"states,omitempty"`
+}
+
+// StateTransition is a change in the state of a session's client connection
+type StateTransition struct {
+	At    time.Time `json:"at"`
+	Layer string    `json:"layer"` // "peer" or "ice"
+	State string    `json:"state"`
+}
+
+// SessionStatsResult represents the result of session.stats: WebRTC
+// transport stats of the client connection, sampled while connected
+type SessionStatsResult struct {
+	ID        string        `json:"id"`
+	ShortCode string        `json:"short_code"`
+	Status    SessionStatus `json:"status"`
+	Sampled   bool          `json:"sampled"` // False until a client has connected
+
+	SampledAt        time.Time `json:"sampled_at,omitempty"`
+	BytesSent        uint64    `json:"bytes_sent"`     // On the ICE transport, including overhead
+	BytesReceived    uint64    `json:"bytes_received"` // On the ICE transport, including overhead
+	MessagesSent     uint64    `json:"messages_sent"`
+	MessagesReceived uint64    `json:"messages_received"`
+	RTTMillis        float64   `json:"rtt_ms"`
+	ChecksSent       uint64    `json:"checks_sent"`     // ICE connectivity checks on the active pair
+	ChecksAnswered   uint64    `json:"checks_answered"` // Responses to those checks
+	CheckLoss        float64   `json:"check_loss"`      // Percentage of checks unanswered
+	ICEState         string    `json:"ice_state,omitempty"`
+	DTLSState        string    `json:"dtls_state,omitempty"`
+	CandidatePair    string    `json:"candidate_pair,omitempty"`
+}
+
+// SessionWhoResult represents the result of session.who: the client and
+// public viewers connected right now
+type SessionWhoResult struct {
+	ID          string           `json:"id"`
+	ShortCode   string           `json:"short_code"`
+	Status      SessionStatus    `json:"status"`
+	Connections []ConnectionInfo `json:"connections"` // Client first, then viewers in the order they joined
+}
+
+// ConnectionInfo is the client or a viewer connected to a session
+type ConnectionInfo struct {
+	Role          string    `json:"role"`                     // "client" or "viewer"
+	Viewer        int       `json:"viewer,omitempty"`         // Viewer number, as tt grant takes
+	Address       string    `json:"address,omitempty"`        // Remote ICE candidate address (a TURN server for "relay")
+	CandidateType string    `json:"candidate_type,omitempty"` // Remote ICE candidate type
+	Location      string    `json:"location,omitempty"`       // From the GeoIP databases
+	ConnectedAt   time.Time `json:"connected_at"`
+}
+
 // ListSessionsResult represents the result of session.list
 type ListSessionsResult struct {
 	Sessions []SessionInfo `json:"sessions"`
@@ -142,6 +362,12 @@ type DaemonStatusResult struct {
 	Uptime       string `json:"uptime"`
 	SessionCount int    `json:"session_count"`
 	ActiveCount  int    `json:"active_count"` // Currently connected
+
+	TotalReconnects   int64    `json:"total_reconnects"`              // Reconnections across all sessions since the daemon started, ended ones included
+	UnstableSessions  []string `json:"unstable_sessions,omitempty"`   // Codes over UnstableReconnectThreshold
+	RelayDownSessions []string `json:"relay_down_sessions,omitempty"` // Codes whose relay appears down
+
+	RelayDegradedSessions []string `json:"relay_degraded_sessions,omitempty"` // Codes whose relay heartbeats are failing
 }
 
 // ShutdownResult represents the result of daemon.shutdown
@@ -6,10 +6,15 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
+	ttcrypto "github.com/artpar/terminal-tunnel/internal/crypto"
+	"github.com/artpar/terminal-tunnel/internal/protocol"
 	"github.com/artpar/terminal-tunnel/internal/server"
+	"github.com/artpar/terminal-tunnel/internal/signaling"
 )
 
 // Security: Minimum password length to prevent brute-force attacks
@@ -24,6 +29,40 @@ var ErrPasswordTooShort = errors.New("password must be at least 12 characters")
 // ErrTooManySessions is returned when session limit is reached
 var ErrTooManySessions = errors.New("maximum session limit reached")
 
+// shutdownDrainDelay gives close notifications time to flush before peers are torn down
+const shutdownDrainDelay = 500 * time.Millisecond
+
+// ReconnectingTimeout is how long a session whose client dropped shows as
+// reconnecting (output is buffered for the client) before it is reported as
+// disconnected. Not to be confused with the server's ReconnectGrace, the
+// pause before it offers the client a fresh connection.
+const ReconnectingTimeout = 2 * time.Minute
+
+// FailedSessionRetention is how long a failed session stays listed before cleanup
+const FailedSessionRetention = 5 * time.Minute
+
+// ErrNameInUse is returned when another session already has the requested
+// name, or has it as its ID or short code
+var ErrNameInUse = errors.New("session name already in use")
+
+// ErrNameLikeCode is returned for a name that could be taken for a session
+// code, which a later session might be given
+var ErrNameLikeCode = errors.New("session name looks like a session code; use lower case or add other characters")
+
+// codeShaped reports whether name is written like a short code: upper-case
+// letters, digits and hyphens, at a code's length (or a viewer code's)
+func codeShaped(name string) bool {
+	if len(name) < signaling.MinRequestedCodeLength || len(name) > signaling.MaxRequestedCodeLength+1 {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
 // ManagedSession represents a session managed by the daemon
 type ManagedSession struct {
 	State    *SessionState
@@ -31,36 +70,73 @@ type ManagedSession struct {
 	Cancel   context.CancelFunc
 	Password string      // Not persisted, kept in memory
 	pty      *server.PTY // For recovered sessions without server
+
+	graceTimer *time.Timer   // Moves a reconnecting session to disconnected
+	idleWarned time.Time     // LastSeen when viewers were warned of cleanup
+	connected  chan struct{} // Closed when a client first connects
+	done       chan struct{} // Closed when the server stops
+}
+
+// stopGraceTimer cancels a pending reconnecting -> disconnected transition.
+// Caller must hold the session manager lock.
+func (ms *ManagedSession) stopGraceTimer() {
+	if ms.graceTimer != nil {
+		ms.graceTimer.Stop()
+		ms.graceTimer = nil
+	}
+}
+
+// reconnectCount returns the server's reconnection count, 0 for recovered sessions
+func (ms *ManagedSession) reconnectCount() int64 {
+	if ms.Server == nil {
+		return 0
+	}
+	return ms.Server.ReconnectCount()
 }
 
 // SessionState represents the persistent state of a session
 type SessionState struct {
-	ID         string        `json:"id"`
-	ShortCode  string        `json:"short_code"`
-	PTYPath    string        `json:"pty_path"`
-	ShellPID   int           `json:"shell_pid"`
-	Shell      string        `json:"shell"`
-	Salt       string        `json:"salt"`
-	Status     SessionStatus `json:"status"`
-	CreatedAt  time.Time     `json:"created_at"`
-	LastSeen   time.Time     `json:"last_seen"`
-	RelayURL   string        `json:"relay_url"`
-	ClientURL  string        `json:"client_url"`
-	Public     bool          `json:"public,omitempty"`      // True if public viewer mode enabled
-	ViewerCode string        `json:"viewer_code,omitempty"` // Code for public viewers (ends with V)
-	ViewerURL  string        `json:"viewer_url,omitempty"`  // URL for public viewers
+	ID         string            `json:"id"`
+	ShortCode  string            `json:"short_code"`
+	Name       string            `json:"name,omitempty"` // Friendly name, survives daemon restarts
+	Tags       map[string]string `json:"tags,omitempty"` // Key/value labels for filtering tt list
+	PTYPath    string            `json:"pty_path"`
+	ShellPID   int               `json:"shell_pid"`
+	Shell      string            `json:"shell"`
+	Salt       string            `json:"salt"`
+	Status     SessionStatus     `json:"status"`
+	CreatedAt  time.Time         `json:"created_at"`
+	LastSeen   time.Time         `json:"last_seen"`
+	RelayURL   string            `json:"relay_url"`
+	ClientURL  string            `json:"client_url"`
+	Public     bool              `json:"public,omitempty"`      // True if public viewer mode enabled
+	ViewerCode string            `json:"viewer_code,omitempty"` // Code for public viewers (ends with V)
+	ViewerURL  string            `json:"viewer_url,omitempty"`  // URL for public viewers
+	Error      string            `json:"-"`                     // Failure reason (StatusFailed), not persisted
+	RelayDown  bool              `json:"-"`                     // Relay requests paused after repeated failures
+
+	HeartbeatFailures int    `json:"-"` // Relay heartbeats failed in a row
+	HeartbeatError    string `json:"-"` // Why the last one failed
+}
+
+// relayDegraded reports whether enough relay heartbeats failed in a row that
+// the code has likely expired on the relay
+func (s *SessionState) relayDegraded() bool {
+	return s.HeartbeatFailures >= RelayDegradedHeartbeats
 }
 
 // SessionStartResult contains info returned when starting a session
 type SessionStartResult struct {
-	ID         string
-	ShortCode  string
-	Password   string
-	ClientURL  string
-	Status     SessionStatus
-	Public     bool   // True if public viewer mode enabled
-	ViewerCode string // Code for public viewers (ends with V)
-	ViewerURL  string // URL for public viewers
+	ID          string
+	ShortCode   string
+	Password    string
+	ClientURL   string
+	Status      SessionStatus
+	Public      bool   // True if public viewer mode enabled
+	ViewerCode  string // Code for public viewers (ends with V)
+	ViewerURL   string // URL for public viewers
+	Fingerprint string // DTLS certificate fingerprint for out-of-band verification
+	LANURL      string // Web client served by the host on the local network
 }
 
 // SessionManager manages all sessions
@@ -68,7 +144,11 @@ type SessionManager struct {
 	mu       sync.RWMutex
 	sessions map[string]*ManagedSession // keyed by ID
 	byCode   map[string]*ManagedSession // keyed by short code
+	byName   map[string]*ManagedSession // keyed by friendly name
 	daemon   *Daemon
+
+	reconnectingTimeout time.Duration // ReconnectingTimeout; shortened by tests
+	pastReconnects      int64         // Reconnections of sessions since removed
 }
 
 // NewSessionManager creates a new session manager
@@ -76,7 +156,36 @@ func NewSessionManager(d *Daemon) *SessionManager {
 	return &SessionManager{
 		sessions: make(map[string]*ManagedSession),
 		byCode:   make(map[string]*ManagedSession),
+		byName:   make(map[string]*ManagedSession),
 		daemon:   d,
+
+		reconnectingTimeout: ReconnectingTimeout,
+	}
+}
+
+// lookup finds a session by ID, short code or name. Caller must hold sm.mu.
+func (sm *SessionManager) lookup(key string) (*ManagedSession, bool) {
+	if ms, ok := sm.sessions[key]; ok {
+		return ms, true
+	}
+	if ms, ok := sm.byCode[key]; ok {
+		return ms, true
+	}
+	ms, ok := sm.byName[key]
+	return ms, ok
+}
+
+// unindex removes a session from all lookup maps, keeping its reconnections
+// in the daemon's total. Caller must hold sm.mu.
+func (sm *SessionManager) unindex(ms *ManagedSession) {
+	ms.stopGraceTimer()
+	sm.pastReconnects += ms.reconnectCount()
+	delete(sm.sessions, ms.State.ID)
+	if ms.State.ShortCode != "" {
+		delete(sm.byCode, ms.State.ShortCode)
+	}
+	if ms.State.Name != "" && sm.byName[ms.State.Name] == ms {
+		delete(sm.byName, ms.State.Name)
 	}
 }
 
@@ -96,6 +205,22 @@ func generatePassword() string {
 
 // StartSession starts a new session
 func (sm *SessionManager) StartSession(params StartSessionParams) (*SessionStartResult, error) {
+	return sm.startSession(params, nil)
+}
+
+// AdoptSession starts a session around a shell that is already running on
+// pty, handed over by an interactive tt start
+func (sm *SessionManager) AdoptSession(params StartSessionParams, pty *server.PTY) (*SessionStartResult, error) {
+	return sm.startSession(params, pty)
+}
+
+// startSession starts a session, on pty if it is not nil
+func (sm *SessionManager) startSession(params StartSessionParams, pty *server.PTY) (*SessionStartResult, error) {
+	kdf, err := ttcrypto.ParseKDF(params.KDF)
+	if err != nil {
+		return nil, err
+	}
+
 	sm.mu.Lock()
 
 	// Security: Check session limit (DoS protection)
@@ -104,6 +229,19 @@ func (sm *SessionManager) StartSession(params StartSessionParams) (*SessionStart
 		return nil, ErrTooManySessions
 	}
 
+	if params.Name != "" {
+		if codeShaped(params.Name) {
+			sm.mu.Unlock()
+			return nil, ErrNameLikeCode
+		}
+		// Names share a namespace with IDs and codes; lookup would
+		// find the other session first
+		if _, exists := sm.lookup(params.Name); exists {
+			sm.mu.Unlock()
+			return nil, ErrNameInUse
+		}
+	}
+
 	// Generate ID and password
 	id := generateID()
 	password := params.Password
@@ -119,7 +257,11 @@ func (sm *SessionManager) StartSession(params StartSessionParams) (*SessionStart
 
 	shell := params.Shell
 	if shell == "" {
-		shell = "/bin/sh"
+		shell = server.DefaultShell()
+	}
+	if len(params.Command) > 0 {
+		// Shown in list/info in place of the shell
+		shell = strings.Join(params.Command, " ")
 	}
 
 	// Create server options
@@ -130,6 +272,34 @@ func (sm *SessionManager) StartSession(params StartSessionParams) (*SessionStart
 		NoTURN:   params.NoTURN,
 		Public:   params.Public,
 		Record:   params.Record,
+		Command:  params.Command,
+		Code:     params.Code,
+
+		GuardBinary: params.GuardBinary,
+		OutputSink:  params.OutputSink,
+		ReadOnly:    params.ReadOnly,
+
+		NoViewerRecording: params.NoViewerRecording,
+		NoJoinNotices:     params.NoJoinNotices,
+		MaxViewersPerIP:   params.MaxViewersPerIP,
+
+		Isolate:          params.Isolate,
+		ContainerRuntime: params.ContainerRuntime,
+
+		CoalesceWindow: params.CoalesceWindow,
+		BellNotify:     params.BellNotify,
+		LAN:            params.LAN,
+		Rows:           params.Rows,
+		Cols:           params.Cols,
+
+		AllowWSFallback: params.AllowWSFallback,
+
+		GeoIP: params.GeoIP,
+		KDF:   kdf,
+
+		// Manual mode needs a terminal the daemon doesn't have, so a
+		// session the relay can't take fails instead
+		Signaling: []signaling.SignalingMethod{signaling.MethodShortCode},
 	}
 
 	// Create context for this session
@@ -147,19 +317,31 @@ func (sm *SessionManager) StartSession(params StartSessionParams) (*SessionStart
 	ms := &ManagedSession{
 		State: &SessionState{
 			ID:        id,
+			Name:      params.Name,
+			Tags:      params.Tags,
 			Status:    StatusWaiting,
 			Shell:     shell,
 			CreatedAt: time.Now(),
 			LastSeen:  time.Now(),
 			Public:    params.Public,
 		},
-		Server:   srv,
-		Cancel:   cancel,
-		Password: password,
+		Server:    srv,
+		Cancel:    cancel,
+		Password:  password,
+		connected: make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	if pty != nil {
+		srv.SetPTY(pty)
+		ms.State.PTYPath = pty.Name()
+		ms.State.ShellPID = pty.PID()
 	}
 
 	// Store session
 	sm.sessions[id] = ms
+	if params.Name != "" {
+		sm.byName[params.Name] = ms
+	}
 
 	// Channel to wait for short code
 	shortCodeReady := make(chan struct{}, 1)
@@ -168,9 +350,19 @@ func (sm *SessionManager) StartSession(params StartSessionParams) (*SessionStart
 	srv.SetCallbacks(server.Callbacks{
 		OnShortCodeReady: func(code, clientURL string) {
 			sm.mu.Lock()
+			// A new code replaces the old one if the relay dropped the session
+			oldCode := ms.State.ShortCode
+			if oldCode != "" && oldCode != code {
+				delete(sm.byCode, oldCode)
+				RemoveSessionState(oldCode)
+			}
 			ms.State.ShortCode = code
 			ms.State.ClientURL = clientURL
 			sm.byCode[code] = ms
+			// Saved once the shell runs; an adopted shell already does
+			if ms.State.ShellPID > 0 {
+				sm.SaveSession(ms)
+			}
 			sm.mu.Unlock()
 			// Signal that short code is ready
 			select {
@@ -185,15 +377,10 @@ func (sm *SessionManager) StartSession(params StartSessionParams) (*SessionStart
 			sm.mu.Unlock()
 		},
 		OnClientConnect: func() {
-			sm.mu.Lock()
-			ms.State.Status = StatusConnected
-			ms.State.LastSeen = time.Now()
-			sm.mu.Unlock()
+			sm.clientConnected(ms)
 		},
 		OnClientDisconnect: func() {
-			sm.mu.Lock()
-			ms.State.Status = StatusDisconnected
-			sm.mu.Unlock()
+			sm.clientDisconnected(ms)
 		},
 		OnViewerConnect: func() {
 			sm.mu.Lock()
@@ -203,6 +390,32 @@ func (sm *SessionManager) StartSession(params StartSessionParams) (*SessionStart
 		OnViewerDisconnect: func() {
 			// Viewers disconnecting doesn't change session status
 		},
+		OnRelayStateChange: func(down bool) {
+			sm.mu.Lock()
+			ms.State.RelayDown = down
+			sm.mu.Unlock()
+		},
+		OnHeartbeatFailure: func(failures int, err error) {
+			sm.mu.Lock()
+			ms.State.HeartbeatFailures = failures
+			ms.State.HeartbeatError = ""
+			if err != nil {
+				ms.State.HeartbeatError = err.Error()
+			}
+			sm.mu.Unlock()
+		},
+		OnError: func(err error) {
+			sm.mu.Lock()
+			ms.State.Status = StatusFailed
+			ms.State.Error = err.Error()
+			ms.State.LastSeen = time.Now()
+			sm.mu.Unlock()
+			// No short code is coming if signaling failed
+			select {
+			case shortCodeReady <- struct{}{}:
+			default:
+			}
+		},
 		OnPTYReady: func(ptyPath string, shellPID int) {
 			sm.mu.Lock()
 			ms.State.PTYPath = ptyPath
@@ -219,11 +432,14 @@ func (sm *SessionManager) StartSession(params StartSessionParams) (*SessionStart
 	go func() {
 		defer func() {
 			sm.mu.Lock()
-			delete(sm.sessions, id)
-			if ms.State.ShortCode != "" {
-				delete(sm.byCode, ms.State.ShortCode)
+			// Failed sessions stay listed so the error is visible; cleanup removes them later
+			if ms.State.Status != StatusFailed {
+				sm.unindex(ms)
+			} else {
+				RemoveSessionState(ms.State.ShortCode)
 			}
 			sm.mu.Unlock()
+			close(ms.done)
 		}()
 
 		// Start the server
@@ -245,51 +461,110 @@ func (sm *SessionManager) StartSession(params StartSessionParams) (*SessionStart
 	}
 
 	sm.mu.RLock()
+	if ms.State.Status == StatusFailed && ms.State.ShortCode == "" {
+		// Listed as failed until cleanup, so tt list shows why too
+		reason := ms.State.Error
+		sm.mu.RUnlock()
+		return nil, errors.New(reason)
+	}
 	result := &SessionStartResult{
-		ID:         id,
-		ShortCode:  ms.State.ShortCode,
-		Password:   password,
-		ClientURL:  ms.State.ClientURL,
-		Status:     ms.State.Status,
-		Public:     ms.State.Public,
-		ViewerCode: ms.State.ViewerCode,
-		ViewerURL:  ms.State.ViewerURL,
+		ID:          id,
+		ShortCode:   ms.State.ShortCode,
+		Password:    password,
+		ClientURL:   ms.State.ClientURL,
+		Status:      ms.State.Status,
+		Public:      ms.State.Public,
+		ViewerCode:  ms.State.ViewerCode,
+		ViewerURL:   ms.State.ViewerURL,
+		Fingerprint: srv.Fingerprint(),
+		LANURL:      srv.LANURL(),
 	}
 	sm.mu.RUnlock()
 
 	return result, nil
 }
 
-// StopSession stops a session by ID or short code
-func (sm *SessionManager) StopSession(idOrCode string) error {
+// clientConnected marks ms connected, ending any reconnecting grace period
+func (sm *SessionManager) clientConnected(ms *ManagedSession) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	ms.stopGraceTimer()
+	ms.State.Status = StatusConnected
+	ms.State.LastSeen = time.Now()
+	select {
+	case <-ms.connected:
+	default:
+		close(ms.connected)
+	}
+}
+
+// clientDisconnected marks ms reconnecting. The server keeps the bridge
+// buffering and waits for the client to come back; report it as gone only
+// if it isn't back within sm.reconnectingTimeout.
+func (sm *SessionManager) clientDisconnected(ms *ManagedSession) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
+	ms.stopGraceTimer()
+	ms.State.Status = StatusReconnecting
+	ms.graceTimer = time.AfterFunc(sm.reconnectingTimeout, func() {
+		sm.mu.Lock()
+		if ms.State.Status == StatusReconnecting {
+			ms.State.Status = StatusDisconnected
+		}
+		sm.mu.Unlock()
+	})
+}
 
-	// Try to find by ID first
-	ms, ok := sm.sessions[idOrCode]
-	if !ok {
-		// Try by short code
-		ms, ok = sm.byCode[idOrCode]
+// TotalReconnects returns the client reconnections of every session since
+// the daemon started, including sessions that have since ended
+func (sm *SessionManager) TotalReconnects() int64 {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	total := sm.pastReconnects
+	for _, ms := range sm.sessions {
+		total += ms.reconnectCount()
 	}
+	return total
+}
+
+// StopSession stops a session by ID, short code or name
+func (sm *SessionManager) StopSession(idOrCode string) error {
+	sm.mu.RLock()
+	ms, ok := sm.lookup(idOrCode)
+	sm.mu.RUnlock()
 	if !ok {
 		return fmt.Errorf("session not found: %s", idOrCode)
 	}
 
+	// Tell the client and viewers why before tearing down, without holding
+	// the lock while the notice drains
+	if ms.Server != nil && ms.Server.NotifyShutdown(protocol.CloseHostEnded, "the host stopped the session") {
+		time.Sleep(shutdownDrainDelay)
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if _, ok := sm.sessions[ms.State.ID]; !ok {
+		return nil // Stopped meanwhile
+	}
+
 	// Cancel the context to stop the server (if running)
 	if ms.Cancel != nil {
 		ms.Cancel()
 	}
 
+	// Invalidate the code on the relay right away (network call, don't hold the lock)
+	if ms.Server != nil {
+		go ms.Server.DeleteRelaySession()
+	}
+
 	// Close PTY for recovered sessions without server
 	if ms.pty != nil && ms.Server == nil {
 		ms.pty.Close()
 	}
 
 	// Remove from maps
-	delete(sm.sessions, ms.State.ID)
-	if ms.State.ShortCode != "" {
-		delete(sm.byCode, ms.State.ShortCode)
-	}
+	sm.unindex(ms)
 
 	// Remove state file
 	RemoveSessionState(ms.State.ShortCode)
@@ -299,9 +574,29 @@ func (sm *SessionManager) StopSession(idOrCode string) error {
 
 // StopAllSessions stops all sessions
 func (sm *SessionManager) StopAllSessions() {
+	// Tell connected clients why they are being dropped before tearing
+	// down, without holding the lock while the notices drain
+	sm.mu.RLock()
+	servers := make([]*server.Server, 0, len(sm.sessions))
+	for _, ms := range sm.sessions {
+		if ms.Server != nil {
+			servers = append(servers, ms.Server)
+		}
+	}
+	sm.mu.RUnlock()
+
+	notified := false
+	for _, srv := range servers {
+		if srv.NotifyShutdown(protocol.CloseHostEnded, "host daemon shutting down") {
+			notified = true
+		}
+	}
+	if notified {
+		time.Sleep(shutdownDrainDelay)
+	}
+
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
-
 	for _, ms := range sm.sessions {
 		// Cancel server if running
 		if ms.Cancel != nil {
@@ -318,6 +613,7 @@ func (sm *SessionManager) StopAllSessions() {
 
 	sm.sessions = make(map[string]*ManagedSession)
 	sm.byCode = make(map[string]*ManagedSession)
+	sm.byName = make(map[string]*ManagedSession)
 }
 
 // ListSessions returns info about all sessions
@@ -328,42 +624,334 @@ func (sm *SessionManager) ListSessions() []SessionInfo {
 	result := make([]SessionInfo, 0, len(sm.sessions))
 	for _, ms := range sm.sessions {
 		result = append(result, SessionInfo{
-			ID:        ms.State.ID,
-			ShortCode: ms.State.ShortCode,
-			Status:    ms.State.Status,
-			Shell:     ms.State.Shell,
-			CreatedAt: ms.State.CreatedAt,
-			LastSeen:  ms.State.LastSeen,
-			ClientURL: ms.State.ClientURL,
+			ID:         ms.State.ID,
+			ShortCode:  ms.State.ShortCode,
+			Name:       ms.State.Name,
+			Tags:       ms.State.Tags,
+			Status:     ms.State.Status,
+			Error:      ms.State.Error,
+			Shell:      ms.State.Shell,
+			CreatedAt:  ms.State.CreatedAt,
+			LastSeen:   ms.State.LastSeen,
+			ClientURL:  ms.State.ClientURL,
+			Reconnects: ms.reconnectCount(),
+			RelayDown:  ms.State.RelayDown,
+
+			RelayDegraded: ms.State.relayDegraded(),
+			RelayError:    ms.State.HeartbeatError,
 		})
 	}
 	return result
 }
 
-// GetSession returns a session by ID or short code
+// GetSession returns a session by ID, short code or name
 func (sm *SessionManager) GetSession(idOrCode string) (*SessionInfo, error) {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 
-	ms, ok := sm.sessions[idOrCode]
+	ms, ok := sm.lookup(idOrCode)
+	if !ok {
+		return nil, fmt.Errorf("session not found: %s", idOrCode)
+	}
+
+	return &SessionInfo{
+		ID:         ms.State.ID,
+		ShortCode:  ms.State.ShortCode,
+		Name:       ms.State.Name,
+		Tags:       ms.State.Tags,
+		Status:     ms.State.Status,
+		Error:      ms.State.Error,
+		Shell:      ms.State.Shell,
+		CreatedAt:  ms.State.CreatedAt,
+		LastSeen:   ms.State.LastSeen,
+		ClientURL:  ms.State.ClientURL,
+		Reconnects: ms.reconnectCount(),
+		RelayDown:  ms.State.RelayDown,
+
+		RelayDegraded: ms.State.relayDegraded(),
+		RelayError:    ms.State.HeartbeatError,
+	}, nil
+}
+
+// SessionDetails returns full details for a session by ID, short code or name
+func (sm *SessionManager) SessionDetails(idOrCode string) (*SessionDetails, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	ms, ok := sm.lookup(idOrCode)
 	if !ok {
-		ms, ok = sm.byCode[idOrCode]
+		return nil, fmt.Errorf("session not found: %s", idOrCode)
 	}
+
+	details := &SessionDetails{
+		SessionInfo: SessionInfo{
+			ID:         ms.State.ID,
+			ShortCode:  ms.State.ShortCode,
+			Name:       ms.State.Name,
+			Tags:       ms.State.Tags,
+			Status:     ms.State.Status,
+			Error:      ms.State.Error,
+			Shell:      ms.State.Shell,
+			CreatedAt:  ms.State.CreatedAt,
+			LastSeen:   ms.State.LastSeen,
+			ClientURL:  ms.State.ClientURL,
+			Public:     ms.State.Public,
+			ViewerCode: ms.State.ViewerCode,
+			ViewerURL:  ms.State.ViewerURL,
+			Reconnects: ms.reconnectCount(),
+			RelayDown:  ms.State.RelayDown,
+
+			RelayDegraded: ms.State.relayDegraded(),
+			RelayError:    ms.State.HeartbeatError,
+		},
+		ShellPID: ms.State.ShellPID,
+	}
+	if ms.Server != nil {
+		stats := ms.Server.Stats()
+		details.BytesIn = stats.BytesIn
+		details.BytesOut = stats.BytesOut
+		details.ConnectionType = stats.ConnectionType
+		details.Viewers = stats.Viewers
+		details.Encoding = stats.Encoding
+		for _, t := range ms.Server.StateHistory() {
+			details.States = append(details.States, StateTransition{At: t.At, Layer: t.Layer, State: t.State})
+		}
+		if origin, ok := ms.Server.ClientOrigin(); ok && ms.State.Status == StatusConnected {
+			details.ClientAddress = origin.Address
+			details.ClientCandidate = origin.CandidateType
+			details.ClientLocation = origin.Location
+			details.ClientOrigin = origin.String()
+		}
+	}
+	return details, nil
+}
+
+// TransportStats returns the latest WebRTC transport stats of a session by
+// ID, short code or name
+func (sm *SessionManager) TransportStats(idOrCode string) (*SessionStatsResult, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	ms, ok := sm.lookup(idOrCode)
 	if !ok {
 		return nil, fmt.Errorf("session not found: %s", idOrCode)
 	}
 
-	return &SessionInfo{
+	result := &SessionStatsResult{
 		ID:        ms.State.ID,
 		ShortCode: ms.State.ShortCode,
 		Status:    ms.State.Status,
-		Shell:     ms.State.Shell,
-		CreatedAt: ms.State.CreatedAt,
-		LastSeen:  ms.State.LastSeen,
-		ClientURL: ms.State.ClientURL,
+	}
+	if ms.Server == nil {
+		return result, nil
+	}
+	stats, ok := ms.Server.TransportStats()
+	if !ok {
+		return result, nil
+	}
+	result.Sampled = true
+	result.SampledAt = stats.Time
+	result.BytesSent = stats.BytesSent
+	result.BytesReceived = stats.BytesReceived
+	result.MessagesSent = stats.MessagesSent
+	result.MessagesReceived = stats.MessagesReceived
+	result.RTTMillis = float64(stats.RTT) / float64(time.Millisecond)
+	result.ChecksSent = stats.ChecksSent
+	result.ChecksAnswered = stats.ChecksAnswered
+	result.CheckLoss = stats.CheckLoss()
+	result.ICEState = stats.ICEState
+	result.DTLSState = stats.DTLSState
+	result.CandidatePair = stats.CandidatePair
+	return result, nil
+}
+
+// AddMarker writes a marker into a session's recording by ID, short code or name
+func (sm *SessionManager) AddMarker(idOrCode, label string) (*SessionMarkResult, error) {
+	sm.mu.RLock()
+	ms, ok := sm.lookup(idOrCode)
+	sm.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("session not found: %s", idOrCode)
+	}
+	if ms.Server == nil {
+		return nil, server.ErrNotRecording
+	}
+
+	path, offset, err := ms.Server.AddMarker(label)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionMarkResult{Recording: path, Time: offset.Seconds()}, nil
+}
+
+// DumpRecording copies a session's recording so far to path, by ID, short
+// code or name. The session keeps recording.
+func (sm *SessionManager) DumpRecording(idOrCode, path string) (*SessionDumpResult, error) {
+	if !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("path must be absolute: %s", path)
+	}
+
+	sm.mu.RLock()
+	ms, ok := sm.lookup(idOrCode)
+	sm.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("session not found: %s", idOrCode)
+	}
+	if ms.Server == nil {
+		return nil, server.ErrNotRecording
+	}
+
+	recording, n, duration, err := ms.Server.DumpRecording(path)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionDumpResult{
+		Recording: recording,
+		Path:      path,
+		Bytes:     n,
+		Duration:  duration.Seconds(),
 	}, nil
 }
 
+// SetSharingPaused pauses or resumes streaming a session's output by ID,
+// short code or name. Clients stay connected either way.
+func (sm *SessionManager) SetSharingPaused(idOrCode string, pause bool) (*SessionPauseResult, error) {
+	sm.mu.RLock()
+	ms, ok := sm.lookup(idOrCode)
+	sm.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("session not found: %s", idOrCode)
+	}
+	if ms.Server == nil {
+		return nil, server.ErrNoTerminal
+	}
+
+	var changed bool
+	var err error
+	if pause {
+		changed, err = ms.Server.PauseSharing()
+	} else {
+		changed, err = ms.Server.ResumeSharing()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &SessionPauseResult{Paused: pause, Changed: changed}, nil
+}
+
+// WaitForClient waits up to timeout, at most MaxSessionWait, for a client
+// to connect to a session by ID, short code or name, e.g. so a script can
+// go on once someone has joined. It returns at once if a client has already
+// connected, even if it has dropped since, and fails if the session ends
+// first.
+func (sm *SessionManager) WaitForClient(idOrCode string, timeout time.Duration) (*SessionWaitResult, error) {
+	sm.mu.RLock()
+	ms, ok := sm.lookup(idOrCode)
+	sm.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("session not found: %s", idOrCode)
+	}
+	if ms.Server == nil {
+		return nil, fmt.Errorf("session %s was recovered after a daemon restart and can't take clients", idOrCode)
+	}
+
+	if timeout <= 0 || timeout > MaxSessionWait {
+		timeout = MaxSessionWait
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-ms.connected:
+	case <-ms.done:
+	case <-timer.C:
+	case <-sm.daemon.GetContext().Done():
+	}
+
+	sm.mu.RLock()
+	status, reason := ms.State.Status, ms.State.Error
+	sm.mu.RUnlock()
+	select {
+	case <-ms.connected:
+		return &SessionWaitResult{Connected: true, Status: status}, nil
+	default:
+	}
+	if status == StatusFailed {
+		return nil, fmt.Errorf("session failed: %s", reason)
+	}
+	select {
+	case <-ms.done:
+		return nil, fmt.Errorf("session ended before a client connected")
+	default:
+	}
+	return &SessionWaitResult{Status: status}, nil
+}
+
+// Connections returns who is connected to a session right now, by ID,
+// short code or name
+func (sm *SessionManager) Connections(idOrCode string) (*SessionWhoResult, error) {
+	sm.mu.RLock()
+	ms, ok := sm.lookup(idOrCode)
+	var result *SessionWhoResult
+	if ok {
+		result = &SessionWhoResult{
+			ID:        ms.State.ID,
+			ShortCode: ms.State.ShortCode,
+			Status:    ms.State.Status,
+		}
+	}
+	sm.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("session not found: %s", idOrCode)
+	}
+
+	result.Connections = []ConnectionInfo{}
+	if ms.Server == nil {
+		return result, nil
+	}
+	for _, conn := range ms.Server.Connections() {
+		info := ConnectionInfo{
+			Role:          "client",
+			Viewer:        conn.Viewer,
+			Address:       conn.Origin.Address,
+			CandidateType: conn.Origin.CandidateType,
+			Location:      conn.Origin.Location,
+			ConnectedAt:   conn.ConnectedAt,
+		}
+		if conn.Viewer != 0 {
+			info.Role = "viewer"
+		}
+		result.Connections = append(result.Connections, info)
+	}
+	return result, nil
+}
+
+// SetViewerWrite lets one of a session's public viewers type, or stops it,
+// by ID, short code or name. viewer numbers the viewer to grant; 0 picks the
+// only one connected.
+func (sm *SessionManager) SetViewerWrite(idOrCode string, viewer int, grant bool) (*SessionGrantResult, error) {
+	sm.mu.RLock()
+	ms, ok := sm.lookup(idOrCode)
+	sm.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("session not found: %s", idOrCode)
+	}
+	if ms.Server == nil {
+		return nil, server.ErrNoViewer
+	}
+
+	var changed bool
+	var err error
+	if grant {
+		viewer, changed, err = ms.Server.GrantViewerWrite(viewer)
+	} else {
+		changed, err = ms.Server.RevokeViewerWrite()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &SessionGrantResult{Granted: grant, Changed: changed, Viewer: viewer}, nil
+}
+
 // SaveSession saves session state to disk
 func (sm *SessionManager) SaveSession(ms *ManagedSession) error {
 	if ms.State.ShortCode == "" {
@@ -372,7 +960,37 @@ func (sm *SessionManager) SaveSession(ms *ManagedSession) error {
 	return SaveSessionState(ms.State)
 }
 
-// CleanupIdleSessions removes sessions that have been disconnected/recovered for too long
+// WarnIdleSessions tells the viewers of disconnected sessions that will be
+// cleaned up within warning, once per idle period. Checks run every
+// interval, so a session is removed at the first check after idleTimeout.
+func (sm *SessionManager) WarnIdleSessions(idleTimeout, warning, interval time.Duration) {
+	if warning <= 0 {
+		return
+	}
+	sm.mu.Lock()
+	now := time.Now()
+	notify := make(map[*server.Server]string)
+	for _, ms := range sm.sessions {
+		if ms.State.Status != StatusDisconnected || ms.Server == nil || ms.idleWarned.Equal(ms.State.LastSeen) {
+			continue
+		}
+		remaining := idleTimeout - now.Sub(ms.State.LastSeen)
+		if remaining < 0 || remaining > warning {
+			continue
+		}
+		ms.idleWarned = ms.State.LastSeen
+		endsIn := (remaining/interval + 1) * interval
+		notify[ms.Server] = fmt.Sprintf("This session will end in %v due to inactivity unless its client reconnects", endsIn)
+	}
+	sm.mu.Unlock()
+
+	for srv, text := range notify {
+		srv.Notify(text)
+	}
+}
+
+// CleanupIdleSessions removes sessions that have been disconnected/recovered for too long,
+// and failed sessions once FailedSessionRetention has passed
 func (sm *SessionManager) CleanupIdleSessions(idleTimeout time.Duration) int {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
@@ -381,17 +999,35 @@ func (sm *SessionManager) CleanupIdleSessions(idleTimeout time.Duration) int {
 	toRemove := make([]string, 0)
 
 	for id, ms := range sm.sessions {
-		// Only cleanup disconnected or recovered sessions
-		if ms.State.Status != StatusDisconnected && ms.State.Status != StatusRecovered {
+		timeout := idleTimeout
+		switch ms.State.Status {
+		case StatusDisconnected, StatusRecovered:
+		case StatusFailed:
+			timeout = min(idleTimeout, FailedSessionRetention)
+		default:
+			// Only cleanup disconnected, recovered or failed sessions
 			continue
 		}
 
 		// Check if session has been idle too long
-		if now.Sub(ms.State.LastSeen) > idleTimeout {
+		if now.Sub(ms.State.LastSeen) > timeout {
 			toRemove = append(toRemove, id)
 		}
 	}
 
+	// Tell viewers still watching why the session is going away
+	notified := false
+	for _, id := range toRemove {
+		if ms := sm.sessions[id]; ms.Server != nil && ms.State.Status == StatusDisconnected {
+			if ms.Server.NotifyShutdown(protocol.CloseIdleTimeout, "the session was idle too long") {
+				notified = true
+			}
+		}
+	}
+	if notified {
+		time.Sleep(shutdownDrainDelay)
+	}
+
 	// Remove idle sessions
 	for _, id := range toRemove {
 		ms := sm.sessions[id]
@@ -407,10 +1043,7 @@ func (sm *SessionManager) CleanupIdleSessions(idleTimeout time.Duration) int {
 		}
 
 		// Remove from maps
-		delete(sm.sessions, id)
-		if ms.State.ShortCode != "" {
-			delete(sm.byCode, ms.State.ShortCode)
-		}
+		sm.unindex(ms)
 
 		// Remove state file
 		RemoveSessionState(ms.State.ShortCode)
@@ -469,6 +1102,9 @@ func (sm *SessionManager) LoadFromDisk() error {
 		if state.ShortCode != "" {
 			sm.byCode[state.ShortCode] = ms
 		}
+		if state.Name != "" {
+			sm.byName[state.Name] = ms
+		}
 
 		// Update state file
 		SaveSessionState(state)
@@ -0,0 +1,76 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+)
+
+// newGraceSession returns a session manager with a short reconnecting
+// timeout and a connected session registered with it
+func newGraceSession(t *testing.T) (*SessionManager, *ManagedSession) {
+	t.Helper()
+	sm := NewSessionManager(nil)
+	sm.reconnectingTimeout = 50 * time.Millisecond
+	ms := &ManagedSession{
+		State:     &SessionState{ID: "grace", Status: StatusConnected},
+		connected: make(chan struct{}),
+	}
+	sm.sessions[ms.State.ID] = ms
+	return sm, ms
+}
+
+// statusOf reads ms's status under sm's lock
+func statusOf(sm *SessionManager, ms *ManagedSession) SessionStatus {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return ms.State.Status
+}
+
+func TestReconnectingTimeout(t *testing.T) {
+	sm, ms := newGraceSession(t)
+
+	sm.clientDisconnected(ms)
+	if got := statusOf(sm, ms); got != StatusReconnecting {
+		t.Fatalf("status after disconnect = %s, want %s", got, StatusReconnecting)
+	}
+	time.Sleep(4 * sm.reconnectingTimeout)
+	if got := statusOf(sm, ms); got != StatusDisconnected {
+		t.Errorf("status after the timeout = %s, want %s", got, StatusDisconnected)
+	}
+}
+
+func TestReconnectingClientReturns(t *testing.T) {
+	sm, ms := newGraceSession(t)
+
+	sm.clientDisconnected(ms)
+	sm.clientConnected(ms)
+	time.Sleep(4 * sm.reconnectingTimeout)
+	if got := statusOf(sm, ms); got != StatusConnected {
+		t.Errorf("status after the client came back = %s, want %s", got, StatusConnected)
+	}
+
+	// Dropping again starts a fresh timeout
+	sm.clientDisconnected(ms)
+	if got := statusOf(sm, ms); got != StatusReconnecting {
+		t.Errorf("status after a second disconnect = %s, want %s", got, StatusReconnecting)
+	}
+}
+
+func TestReconnectingSessionStopped(t *testing.T) {
+	sm, ms := newGraceSession(t)
+
+	sm.clientDisconnected(ms)
+	if err := sm.StopSession(ms.State.ID); err != nil {
+		t.Fatalf("StopSession: %v", err)
+	}
+	sm.mu.RLock()
+	timer := ms.graceTimer
+	sm.mu.RUnlock()
+	if timer != nil {
+		t.Error("grace timer still set after the session stopped")
+	}
+	time.Sleep(4 * sm.reconnectingTimeout)
+	if got := statusOf(sm, ms); got != StatusReconnecting {
+		t.Errorf("status of a stopped session = %s, want %s left as it was", got, StatusReconnecting)
+	}
+}
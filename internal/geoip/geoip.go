@@ -0,0 +1,118 @@
+// Package geoip resolves IP addresses to a coarse location and network
+// using offline MaxMind DB files (e.g. GeoLite2-City and GeoLite2-ASN)
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Location is what the databases know about an address. Fields are empty
+// when no database has them.
+type Location struct {
+	Org     string // Network owner, e.g. "Comcast Cable Communications, LLC"
+	ASN     uint64 // Autonomous system number
+	City    string
+	Country string
+}
+
+// String describes the location briefly, e.g. "Comcast, Seattle", or ""
+// if nothing is known
+func (l Location) String() string {
+	var parts []string
+	if l.Org != "" {
+		parts = append(parts, l.Org)
+	} else if l.ASN != 0 {
+		parts = append(parts, fmt.Sprintf("AS%d", l.ASN))
+	}
+	if l.City != "" {
+		parts = append(parts, l.City)
+	} else if l.Country != "" {
+		parts = append(parts, l.Country)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// DB looks addresses up in one or more MaxMind DB files. MaxMind splits
+// network owners (ASN, ISP) and places (City, Country) into separate
+// databases, so lookups merge what each file knows.
+type DB struct {
+	readers []*reader
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = map[string]*reader{} // Loaded files, shared by every session
+)
+
+// Open loads the MaxMind DB files at paths. A file loaded before is shared
+// rather than read again, so a daemon running many sessions holds one copy.
+func Open(paths ...string) (*DB, error) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	db := &DB{}
+	for _, path := range paths {
+		r, ok := cache[path]
+		if !ok {
+			buf, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read GeoIP database: %w", err)
+			}
+			r, err = newReader(buf)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load GeoIP database %s: %w", path, err)
+			}
+			cache[path] = r
+		}
+		db.readers = append(db.readers, r)
+	}
+	return db, nil
+}
+
+// Lookup returns what the databases know about ip. A nil DB knows nothing.
+func (db *DB) Lookup(ip net.IP) Location {
+	var loc Location
+	if db == nil {
+		return loc
+	}
+	for _, r := range db.readers {
+		record, err := r.lookup(ip)
+		if err != nil || record == nil {
+			continue
+		}
+		fill(&loc.Org, str(record, "autonomous_system_organization"))
+		fill(&loc.Org, str(record, "isp"))
+		fill(&loc.Org, str(record, "organization"))
+		if loc.ASN == 0 {
+			loc.ASN, _ = record["autonomous_system_number"].(uint64)
+		}
+		fill(&loc.City, str(record, "city", "names", "en"))
+		fill(&loc.Country, str(record, "country", "names", "en"))
+	}
+	return loc
+}
+
+// fill sets an empty field
+func fill(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
+// str returns the string at a path of map keys, or "" if there is none
+func str(record map[string]any, keys ...string) string {
+	var v any = record
+	for _, key := range keys {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return ""
+		}
+		v = m[key]
+	}
+	s, _ := v.(string)
+	return s
+}
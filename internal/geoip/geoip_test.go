@@ -0,0 +1,209 @@
+package geoip
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The helpers below write just enough of the MaxMind DB format to build
+// small test databases
+
+func encString(s string) []byte {
+	if len(s) >= 29 {
+		return append([]byte{2<<5 | 29, byte(len(s) - 29)}, s...)
+	}
+	return append([]byte{2<<5 | byte(len(s))}, s...)
+}
+
+func encUint32(n uint32) []byte {
+	return []byte{6<<5 | 4, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+}
+
+func encMap(pairs ...[]byte) []byte {
+	b := []byte{7<<5 | byte(len(pairs)/2)}
+	for _, p := range pairs {
+		b = append(b, p...)
+	}
+	return b
+}
+
+// encPointer points to offset (< 2048) in the data section
+func encPointer(offset int) []byte {
+	return []byte{1<<5 | byte(offset>>8), byte(offset)}
+}
+
+// trie is a search tree node under construction
+type trie struct {
+	child [2]*trie
+	data  int // Offset in the data section of a leaf, -1 for inner nodes
+}
+
+// buildDB writes a database mapping each prefix (of ipVersion addresses)
+// to the record at its offset in data
+func buildDB(t *testing.T, ipVersion, recordSize int, data []byte, prefixes map[string]int) string {
+	t.Helper()
+	root := &trie{data: -1}
+	for cidr, offset := range prefixes {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ones, _ := network.Mask.Size()
+		ip := network.IP.To16()
+		if ip4 := network.IP.To4(); ipVersion == 4 {
+			ip = ip4
+		} else if ip4 != nil {
+			ip = append(make([]byte, 12), ip4...) // IPv4 under ::/96
+			ones += 96
+		}
+		n := root
+		for i := 0; i < ones; i++ {
+			bit := ip[i/8] >> (7 - i%8) & 1
+			if n.child[bit] == nil {
+				n.child[bit] = &trie{data: -1}
+			}
+			n = n.child[bit]
+		}
+		n.data = offset
+	}
+
+	// Number the inner nodes breadth first
+	var nodes []*trie
+	index := map[*trie]int{}
+	for queue := []*trie{root}; len(queue) > 0; queue = queue[1:] {
+		n := queue[0]
+		index[n] = len(nodes)
+		nodes = append(nodes, n)
+		for _, c := range n.child {
+			if c != nil && c.data < 0 {
+				queue = append(queue, c)
+			}
+		}
+	}
+	nodeCount := len(nodes)
+	value := func(c *trie) uint32 {
+		switch {
+		case c == nil:
+			return uint32(nodeCount)
+		case c.data >= 0:
+			return uint32(nodeCount + dataSectionSeparator + c.data)
+		default:
+			return uint32(index[c])
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, n := range nodes {
+		l, r := value(n.child[0]), value(n.child[1])
+		switch recordSize {
+		case 24:
+			buf.Write([]byte{byte(l >> 16), byte(l >> 8), byte(l), byte(r >> 16), byte(r >> 8), byte(r)})
+		case 28:
+			buf.Write([]byte{byte(l >> 16), byte(l >> 8), byte(l), byte(l>>24)<<4 | byte(r>>24), byte(r >> 16), byte(r >> 8), byte(r)})
+		default:
+			buf.Write([]byte{byte(l >> 24), byte(l >> 16), byte(l >> 8), byte(l), byte(r >> 24), byte(r >> 16), byte(r >> 8), byte(r)})
+		}
+	}
+	buf.Write(make([]byte, dataSectionSeparator))
+	buf.Write(data)
+	buf.Write(metadataMarker)
+	buf.Write(encMap(
+		encString("node_count"), encUint32(uint32(nodeCount)),
+		encString("record_size"), []byte{5<<5 | 1, byte(recordSize)},
+		encString("ip_version"), []byte{5<<5 | 1, byte(ipVersion)},
+		encString("database_type"), encString("Test"),
+	))
+
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLookup(t *testing.T) {
+	// A City database (IPv6, 28-bit records) whose records share a
+	// country through a pointer
+	var city []byte
+	country := len(city)
+	city = append(city, encMap(encString("names"), encMap(encString("en"), encString("United States")))...)
+	seattle := len(city)
+	city = append(city, encMap(
+		encString("city"), encMap(encString("names"), encMap(encString("en"), encString("Seattle"))),
+		encString("country"), encPointer(country),
+	)...)
+	unnamed := len(city)
+	city = append(city, encMap(encString("country"), encPointer(country))...)
+	cityDB := buildDB(t, 6, 28, city, map[string]int{
+		"203.0.113.0/24":  seattle,
+		"2001:db8::/32":   seattle,
+		"198.51.100.0/24": unnamed,
+	})
+
+	// An ASN database (IPv4, 24-bit records)
+	asn := encMap(
+		encString("autonomous_system_number"), encUint32(7922),
+		encString("autonomous_system_organization"), encString("Comcast"),
+	)
+	asnDB := buildDB(t, 4, 24, asn, map[string]int{"203.0.113.0/24": 0})
+
+	db, err := Open(cityDB, asnDB)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	tests := []struct {
+		ip   string
+		want Location
+		str  string
+	}{
+		{"203.0.113.7", Location{Org: "Comcast", ASN: 7922, City: "Seattle", Country: "United States"}, "Comcast, Seattle"},
+		{"2001:db8::1", Location{City: "Seattle", Country: "United States"}, "Seattle"},
+		{"198.51.100.1", Location{Country: "United States"}, "United States"},
+		{"192.0.2.1", Location{}, ""},
+		{"2001:db9::1", Location{}, ""},
+	}
+	for _, tt := range tests {
+		got := db.Lookup(net.ParseIP(tt.ip))
+		if got != tt.want {
+			t.Errorf("Lookup(%s) = %#v, want %#v", tt.ip, got, tt.want)
+		}
+		if got.String() != tt.str {
+			t.Errorf("Lookup(%s).String() = %q, want %q", tt.ip, got.String(), tt.str)
+		}
+	}
+
+	var none *DB
+	if got := none.Lookup(net.ParseIP("203.0.113.7")); got != (Location{}) {
+		t.Errorf("nil DB Lookup = %#v, want nothing", got)
+	}
+}
+
+func TestRecordSizes(t *testing.T) {
+	data := encMap(encString("autonomous_system_organization"), encString("Example"))
+	for _, size := range []int{24, 28, 32} {
+		db, err := Open(buildDB(t, 6, size, data, map[string]int{"192.0.2.0/24": 0}))
+		if err != nil {
+			t.Fatalf("record size %d: Open failed: %v", size, err)
+		}
+		if got := db.Lookup(net.ParseIP("192.0.2.1")).Org; got != "Example" {
+			t.Errorf("record size %d: Org = %q, want Example", size, got)
+		}
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.mmdb")
+	if err := os.WriteFile(path, []byte("not a database"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Error("Open of a file without metadata should fail")
+	}
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.mmdb")); err == nil {
+		t.Error("Open of a missing file should fail")
+	}
+}
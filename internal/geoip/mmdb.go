@@ -0,0 +1,332 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+)
+
+// metadataMarker starts the metadata section at the end of a MaxMind DB file
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// dataSectionSeparator is the run of zero bytes between the search tree and
+// the data section
+const dataSectionSeparator = 16
+
+// maxDepth bounds nested maps and arrays, and pointer chains, so a corrupt
+// file cannot recurse forever
+const maxDepth = 32
+
+var errCorrupt = errors.New("corrupt MaxMind DB")
+
+// reader looks up records in a MaxMind DB (.mmdb) file, as documented at
+// https://maxmind.github.io/MaxMind-DB/. It reads the whole file into memory.
+type reader struct {
+	tree       []byte
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	ipv4Start  uint // Node for ::0.0.0.0 in an IPv6 tree
+}
+
+func newReader(buf []byte) (*reader, error) {
+	start := bytes.LastIndex(buf, metadataMarker)
+	if start < 0 {
+		return nil, errors.New("not a MaxMind DB: no metadata")
+	}
+	d := decoder{buf: buf[start+len(metadataMarker):]}
+	v, _, err := d.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	meta, ok := v.(map[string]any)
+	if !ok {
+		return nil, errCorrupt
+	}
+
+	r := &reader{
+		nodeCount:  uint(metaUint(meta, "node_count")),
+		recordSize: uint(metaUint(meta, "record_size")),
+		ipVersion:  uint(metaUint(meta, "ip_version")),
+	}
+
+	switch r.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported record size %d", r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("unsupported IP version %d", r.ipVersion)
+	}
+
+	treeSize := r.nodeCount * r.recordSize / 4
+	if treeSize+dataSectionSeparator > uint(start) {
+		return nil, errCorrupt
+	}
+	r.tree = buf[:treeSize]
+	r.data = buf[treeSize+dataSectionSeparator : start]
+
+	// IPv4 addresses live under ::/96 in an IPv6 tree
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// metaUint returns an unsigned metadata field, or 0 if it is missing
+func metaUint(meta map[string]any, key string) uint64 {
+	n, _ := meta[key].(uint64)
+	return n
+}
+
+// lookup returns the record for ip, or nil if the database has none
+func (r *reader) lookup(ip net.IP) (map[string]any, error) {
+	var node uint
+	var bits []byte
+	if ip4 := ip.To4(); ip4 != nil {
+		bits = ip4
+		node = r.ipv4Start
+	} else if r.ipVersion == 6 {
+		bits = ip.To16()
+	} else {
+		return nil, nil
+	}
+	if bits == nil {
+		return nil, nil
+	}
+
+	for i := 0; i < len(bits)*8 && node < r.nodeCount; i++ {
+		bit := (bits[i/8] >> (7 - i%8)) & 1
+		node = r.record(node, uint(bit))
+	}
+	if node == r.nodeCount {
+		return nil, nil
+	}
+	if node < r.nodeCount {
+		return nil, errCorrupt
+	}
+
+	offset := node - r.nodeCount - dataSectionSeparator
+	d := decoder{buf: r.data}
+	v, _, err := d.decode(offset, 0)
+	if err != nil {
+		return nil, err
+	}
+	record, _ := v.(map[string]any)
+	return record, nil
+}
+
+// record returns the left (bit 0) or right (bit 1) record of a node
+func (r *reader) record(node, bit uint) uint {
+	size := r.recordSize / 4 // Bytes per node
+	b := r.tree[node*size : node*size+size]
+	switch r.recordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		// The middle byte holds the high nibble of each record
+		if bit == 0 {
+			return uint(b[3]>>4)<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(b[bit*4:]))
+	}
+}
+
+// decoder reads values from the data section
+type decoder struct {
+	buf []byte
+}
+
+// Data section types
+const (
+	typeExtended = 0
+	typePointer  = 1
+	typeString   = 2
+	typeDouble   = 3
+	typeBytes    = 4
+	typeUint16   = 5
+	typeUint32   = 6
+	typeMap      = 7
+	typeInt32    = 8
+	typeUint64   = 9
+	typeUint128  = 10
+	typeArray    = 11
+	typeBool     = 14
+	typeFloat    = 15
+)
+
+// decode returns the value at offset and the offset after it. Maps decode
+// to map[string]any, arrays to []any, integers to uint64 or int64 and
+// floating point numbers to float64.
+func (d *decoder) decode(offset, depth uint) (any, uint, error) {
+	if depth > maxDepth {
+		return nil, 0, errCorrupt
+	}
+	ctrl, err := d.byteAt(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset++
+
+	typ := uint(ctrl >> 5)
+	if typ == typePointer {
+		target, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := d.decode(target, depth+1)
+		return v, next, err
+	}
+	if typ == typeExtended {
+		ext, err := d.byteAt(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		typ = 7 + uint(ext)
+		offset++
+	}
+
+	size := uint(ctrl & 0x1F)
+	if size >= 29 {
+		n := size - 28 // Bytes holding the size
+		b, err := d.bytes(offset, n)
+		if err != nil {
+			return nil, 0, err
+		}
+		offset += n
+		extra := uint(0)
+		for _, c := range b {
+			extra = extra<<8 | uint(c)
+		}
+		switch n {
+		case 1:
+			size = 29 + extra
+		case 2:
+			size = 285 + extra
+		default:
+			size = 65821 + extra
+		}
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]any, size)
+		for i := uint(0); i < size; i++ {
+			k, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, errCorrupt
+			}
+			v, next, err := d.decode(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+			offset = next
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]any, 0, size)
+		for i := uint(0); i < size; i++ {
+			v, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+			offset = next
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	b, err := d.bytes(offset, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset += size
+
+	switch typ {
+	case typeString:
+		return string(b), offset, nil
+	case typeBytes:
+		return append([]byte(nil), b...), offset, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errCorrupt
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errCorrupt
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), offset, nil
+	case typeUint16, typeUint32, typeUint64, typeUint128:
+		// uint128 values (rare) keep their low 64 bits
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		return n, offset, nil
+	case typeInt32:
+		var n uint32
+		for _, c := range b {
+			n = n<<8 | uint32(c)
+		}
+		return int64(int32(n)), offset, nil
+	default:
+		return nil, 0, fmt.Errorf("%w: unknown type %d", errCorrupt, typ)
+	}
+}
+
+// pointer resolves a pointer whose control byte is ctrl and whose payload
+// starts at offset. It returns the target and the offset after the pointer.
+func (d *decoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	n := uint(ctrl>>3&0x3) + 1
+	b, err := d.bytes(offset, n)
+	if err != nil {
+		return 0, 0, err
+	}
+	v := uint(ctrl & 0x7)
+	if n == 4 {
+		v = 0
+	}
+	for _, c := range b {
+		v = v<<8 | uint(c)
+	}
+	switch n {
+	case 2:
+		v += 2048
+	case 3:
+		v += 526336
+	}
+	return v, offset + n, nil
+}
+
+func (d *decoder) byteAt(offset uint) (byte, error) {
+	if offset >= uint(len(d.buf)) {
+		return 0, errCorrupt
+	}
+	return d.buf[offset], nil
+}
+
+func (d *decoder) bytes(offset, n uint) ([]byte, error) {
+	if offset+n > uint(len(d.buf)) || offset+n < offset {
+		return nil, errCorrupt
+	}
+	return d.buf[offset : offset+n], nil
+}
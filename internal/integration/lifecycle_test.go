@@ -726,3 +726,78 @@ func BenchmarkMessageThroughput(b *testing.B) {
 	hostChannel.Close()
 	clientChannel.Close()
 }
+
+// BenchmarkChunkSizeThroughput measures bulk throughput through the encrypted
+// channel at the PTY read chunk sizes the bridge supports
+func BenchmarkChunkSizeThroughput(b *testing.B) {
+	for _, size := range []int{4 * 1024, 32 * 1024, 60 * 1024} {
+		b.Run(fmt.Sprintf("%dKB", size/1024), func(b *testing.B) {
+			password := "testpassword123"
+			salt := make([]byte, 16)
+			key := crypto.DeriveKey(password, salt)
+
+			hostPeer, _ := ttwebrtc.NewPeer(ttwebrtc.DefaultConfig())
+			defer hostPeer.Close()
+
+			clientPeer, _ := ttwebrtc.NewPeer(ttwebrtc.DefaultConfig())
+			defer clientPeer.Close()
+
+			hostDC, _ := hostPeer.CreateDataChannel("terminal")
+
+			clientDCChan := make(chan *webrtc.DataChannel, 1)
+			clientPeer.OnDataChannel(func(dc *webrtc.DataChannel) {
+				clientDCChan <- dc
+			})
+
+			offer, _ := hostPeer.CreateOffer()
+			clientPeer.SetRemoteDescription(webrtc.SDPTypeOffer, offer)
+			answer, _ := clientPeer.CreateAnswer()
+			hostPeer.SetRemoteDescription(webrtc.SDPTypeAnswer, answer)
+
+			clientDC := <-clientDCChan
+
+			hostOpen := make(chan bool, 1)
+			clientOpen := make(chan bool, 1)
+			hostDC.OnOpen(func() { hostOpen <- true })
+			clientDC.OnOpen(func() { clientOpen <- true })
+			<-hostOpen
+			<-clientOpen
+
+			hostChannel := ttwebrtc.NewEncryptedChannel(hostDC, &key)
+			clientChannel := ttwebrtc.NewEncryptedChannel(clientDC, &key)
+
+			var received atomic.Int64
+			clientChannel.OnData(func(data []byte) {
+				received.Add(int64(len(data)))
+			})
+
+			chunk := make([]byte, size)
+			total := int64(size) * int64(b.N)
+
+			b.SetBytes(int64(size))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// Apply backpressure like a real sender would, so we measure delivery rather than queueing
+				for hostDC.BufferedAmount() > 1024*1024 {
+					time.Sleep(100 * time.Microsecond)
+				}
+				if err := hostChannel.SendData(chunk); err != nil {
+					b.Fatalf("SendData failed: %v", err)
+				}
+			}
+
+			deadline := time.Now().Add(30 * time.Second)
+			for received.Load() < total && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			b.StopTimer()
+
+			if received.Load() < total {
+				b.Fatalf("received %d of %d bytes", received.Load(), total)
+			}
+
+			hostChannel.Close()
+			clientChannel.Close()
+		})
+	}
+}
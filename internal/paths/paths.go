@@ -0,0 +1,98 @@
+// Package paths locates the directories tt keeps its data in
+package paths
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+const (
+	// EnvHome overrides the data directory (recordings, session state, PID
+	// file and socket). The --data-dir flag sets it for the daemon too.
+	EnvHome = "TT_HOME"
+
+	// legacyDir is the data directory under the home directory
+	legacyDir = ".tt"
+
+	// xdgName is the directory name under XDG_DATA_HOME and XDG_RUNTIME_DIR
+	xdgName = "tt"
+
+	// maxSocketPath keeps socket paths within sun_path, which is 104 bytes
+	// on macOS and the BSDs and 108 on Linux, including the terminator
+	maxSocketPath = 103
+)
+
+// DataDir returns the base directory for tt's data. In order:
+// $TT_HOME, ~/.tt if it already exists, $XDG_DATA_HOME/tt on Linux, ~/.tt.
+func DataDir() string {
+	dir, _ := dataDir()
+	return dir
+}
+
+// dataDir is DataDir, also reporting whether the result is the legacy ~/.tt
+func dataDir() (string, bool) {
+	if dir := os.Getenv(EnvHome); dir != "" {
+		return dir, false
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), legacyDir), false
+	}
+	legacy := filepath.Join(home, legacyDir)
+
+	// Keep using an existing ~/.tt so upgrading doesn't lose recordings or
+	// orphan a running daemon
+	if _, err := os.Stat(legacy); err == nil {
+		return legacy, true
+	}
+	if xdg := os.Getenv("XDG_DATA_HOME"); runtime.GOOS == "linux" && xdg != "" {
+		return filepath.Join(xdg, xdgName), false
+	}
+	return legacy, true
+}
+
+// SocketDir returns the directory for the daemon's socket: $XDG_RUNTIME_DIR/tt
+// on Linux, otherwise the data directory. A data directory set with TT_HOME
+// keeps its own socket, so daemons for different data directories don't
+// collide, and so does the legacy ~/.tt, so the CLI still finds a daemon
+// started before an upgrade. If a socket named name would exceed the socket
+// path limit, a short per-user directory under the temp dir is used instead.
+func SocketDir(name string) string {
+	dir, _ := socketDir(name)
+	return dir
+}
+
+// socketDir is SocketDir, also reporting whether the result is the fallback
+// under the temp dir
+func socketDir(name string) (string, bool) {
+	dir, legacy := dataDir()
+	if xdg := os.Getenv("XDG_RUNTIME_DIR"); runtime.GOOS == "linux" && xdg != "" && os.Getenv(EnvHome) == "" && !legacy {
+		dir = filepath.Join(xdg, xdgName)
+	}
+	if len(filepath.Join(dir, name)) <= maxSocketPath {
+		return dir, false
+	}
+
+	// Name the fallback after the data directory it serves
+	sum := sha256.Sum256([]byte(DataDir()))
+	return filepath.Join(os.TempDir(), fmt.Sprintf("tt-%d-%x", os.Getuid(), sum[:4])), true
+}
+
+// EnsureSocketDir creates the directory SocketDir returns for name. The
+// fallback under the temp dir has a predictable name in a directory anyone
+// can write to, so if another user created it first it is refused: it must
+// be a directory, not a symlink, owned by the current user with mode 0700.
+func EnsureSocketDir(name string) error {
+	dir, shared := socketDir(name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if !shared {
+		return nil
+	}
+	return checkPrivateDir(dir)
+}
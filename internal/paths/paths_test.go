@@ -0,0 +1,129 @@
+//go:build !windows
+
+package paths
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// setEnv points HOME, the temp dir and the XDG variables at fresh directories
+// and clears TT_HOME
+func setEnv(t *testing.T) (home, dataHome, runtimeDir string) {
+	t.Helper()
+	home = t.TempDir()
+	dataHome = t.TempDir()
+	runtimeDir = t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("TMPDIR", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", dataHome)
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	t.Setenv(EnvHome, "")
+	return home, dataHome, runtimeDir
+}
+
+func TestDirPrecedence(t *testing.T) {
+	linux := runtime.GOOS == "linux"
+
+	tests := []struct {
+		name       string
+		ttHome     bool // set TT_HOME to a directory of its own
+		legacy     bool // create ~/.tt first
+		wantData   string
+		wantSocket string
+	}{
+		{name: "tt home", ttHome: true, legacy: true, wantData: "tthome", wantSocket: "tthome"},
+		{name: "legacy", legacy: true, wantData: "legacy", wantSocket: "legacy"},
+		{name: "xdg", wantData: "xdg", wantSocket: "runtime"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home, dataHome, runtimeDir := setEnv(t)
+			dirs := map[string]string{
+				"tthome":  filepath.Join(t.TempDir(), "data"),
+				"legacy":  filepath.Join(home, legacyDir),
+				"xdg":     filepath.Join(dataHome, xdgName),
+				"runtime": filepath.Join(runtimeDir, xdgName),
+			}
+			if !linux {
+				// Elsewhere the XDG variables are ignored
+				dirs["xdg"] = dirs["legacy"]
+				dirs["runtime"] = dirs["legacy"]
+			}
+			if tt.ttHome {
+				t.Setenv(EnvHome, dirs["tthome"])
+			}
+			if tt.legacy {
+				if err := os.Mkdir(dirs["legacy"], 0700); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := DataDir(); got != dirs[tt.wantData] {
+				t.Errorf("DataDir() = %q, want %q", got, dirs[tt.wantData])
+			}
+			if got := SocketDir("tt.sock"); got != dirs[tt.wantSocket] {
+				t.Errorf("SocketDir() = %q, want %q", got, dirs[tt.wantSocket])
+			}
+		})
+	}
+}
+
+func TestSocketDirFallback(t *testing.T) {
+	setEnv(t)
+	long := filepath.Join(t.TempDir(), strings.Repeat("d", maxSocketPath))
+	t.Setenv(EnvHome, long)
+
+	dir := SocketDir("tt.sock")
+	if filepath.Dir(dir) != filepath.Clean(os.TempDir()) {
+		t.Fatalf("SocketDir() = %q, want a directory under %q", dir, os.TempDir())
+	}
+	if len(filepath.Join(dir, "tt.sock")) > maxSocketPath {
+		t.Errorf("fallback socket path %q is too long", dir)
+	}
+
+	// Each data directory gets its own fallback
+	t.Setenv(EnvHome, long+"2")
+	if other := SocketDir("tt.sock"); other == dir {
+		t.Errorf("two data directories share the fallback %q", dir)
+	}
+}
+
+func TestEnsureSocketDir(t *testing.T) {
+	setEnv(t)
+	t.Setenv(EnvHome, filepath.Join(t.TempDir(), strings.Repeat("d", maxSocketPath)))
+	dir := SocketDir("tt.sock")
+
+	if err := EnsureSocketDir("tt.sock"); err != nil {
+		t.Fatalf("EnsureSocketDir: %v", err)
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		t.Errorf("mode = %04o, want 0700", perm)
+	}
+
+	// A fallback someone else could have prepared is refused
+	if err := os.Chmod(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := EnsureSocketDir("tt.sock"); err == nil {
+		t.Error("EnsureSocketDir accepted a world-writable directory")
+	}
+
+	if err := os.Remove(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(t.TempDir(), dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := EnsureSocketDir("tt.sock"); err == nil {
+		t.Error("EnsureSocketDir accepted a symlink")
+	}
+}
@@ -0,0 +1,32 @@
+//go:build !windows
+
+package paths
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkPrivateDir returns an error unless dir is a directory, not a symlink,
+// owned by the current user and accessible only to them
+func checkPrivateDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("%s: cannot determine owner", dir)
+	}
+	if int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("%s is owned by uid %d, not %d", dir, st.Uid, os.Getuid())
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		return fmt.Errorf("%s has mode %04o, want 0700", dir, perm)
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package paths
+
+// checkPrivateDir is a no-op on Windows: the temp dir is already per-user
+// and Unix ownership and modes don't apply
+func checkPrivateDir(dir string) error {
+	return nil
+}
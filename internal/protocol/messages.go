@@ -4,6 +4,7 @@ package protocol
 import (
 	"encoding/binary"
 	"errors"
+	"sort"
 )
 
 // MsgType represents the type of terminal message
@@ -15,6 +16,110 @@ const (
 	MsgPing   MsgType = 0x03 // Keepalive ping
 	MsgPong   MsgType = 0x04 // Keepalive pong
 	MsgClose  MsgType = 0x05 // Graceful close
+
+	// MsgZmodem notifies the client that a zmodem transfer is starting in the
+	// output stream. Payload: 1 byte direction (ZmodemSend or ZmodemReceive).
+	MsgZmodem MsgType = 0x06
+
+	// MsgSizeRequest asks the client to report its current terminal size
+	// with a resize message. Sent on reconnect before replaying buffered output.
+	MsgSizeRequest MsgType = 0x07
+
+	// MsgViewerInfo is sent to a read-only viewer when it joins, before the
+	// screen history, so a viewer-side recording starts with the right size.
+	// Payload: 1 byte flags, 2 bytes rows, 2 bytes cols (big-endian).
+	MsgViewerInfo MsgType = 0x08
+
+	// MsgCapabilities lists the features the sender supports, so both sides
+	// can agree on protocol extensions. Sent by each peer when the channel
+	// opens; a peer answers the first one it receives with a reply. Payload:
+	// 1 byte protocol version, 1 byte flags, then per feature 1 byte name
+	// length, the name, and 1 byte feature version.
+	MsgCapabilities MsgType = 0x09
+
+	// MsgBell tells the client the shell rang the terminal bell (BEL outside
+	// an escape sequence), so it can notify the user even when its tab is in
+	// the background. The BEL byte itself stays in the output. No payload.
+	MsgBell MsgType = 0x0A
+
+	// MsgReset asks the host to reset a terminal left wedged by a program
+	// (raw mode, alternate screen, scrambled character set): the host
+	// restores the PTY's line settings and sends a reset sequence in the
+	// output. Client to host only. No payload.
+	MsgReset MsgType = 0x0B
+
+	// MsgNotice carries a short out-of-band message from the host for the
+	// client to show apart from the terminal, e.g. that a viewer joined.
+	// Host to client only. Payload: UTF-8 text.
+	MsgNotice MsgType = 0x0C
+
+	// MsgWriteAccess tells a public viewer whether the host lets it type
+	// into the session. Viewers start read-only. Host to viewer only.
+	// Payload: 1 byte, 1 if the viewer may type, 0 if not.
+	MsgWriteAccess MsgType = 0x0D
+
+	// MsgBandwidthHint asks the host to trade smoothness for bandwidth on
+	// this connection, e.g. for a viewer on a poor mobile link. Client or
+	// viewer to host only. Payload: 1 byte mode, BandwidthFull or
+	// BandwidthEconomy.
+	MsgBandwidthHint MsgType = 0x0E
+)
+
+// ProtocolVersion is the version of the message format sent in capabilities
+const ProtocolVersion byte = 1
+
+// Capabilities flags
+const (
+	// CapabilitiesReply marks an answer to the peer's capabilities, which
+	// must not be answered again
+	CapabilitiesReply byte = 0x01
+)
+
+// Feature names exchanged in capabilities messages. Peers that predate
+// negotiation send none and are assumed to support only the base messages.
+const (
+	FeaturePingSize      = "ping-size"      // Pings carry terminal size
+	FeatureSizeRequest   = "size-request"   // MsgSizeRequest before a replay
+	FeatureZmodem        = "zmodem"         // MsgZmodem transfer notices
+	FeatureViewerInfo    = "viewer-info"    // MsgViewerInfo for viewers
+	FeatureCloseReason   = "close-reason"   // MsgClose carries a reason; v2 adds a CloseCode
+	FeatureBell          = "bell"           // MsgBell notifications
+	FeatureReset         = "reset"          // MsgReset terminal resets
+	FeatureNotice        = "notice"         // MsgNotice messages
+	FeatureWriteAccess   = "write-access"   // MsgWriteAccess for viewers
+	FeatureBandwidthHint = "bandwidth-hint" // MsgBandwidthHint economy mode
+)
+
+// Viewer info flags
+const (
+	// ViewerRecordingAllowed tells the viewer it may record the session.
+	// Advisory: a viewer can always capture what it is shown.
+	ViewerRecordingAllowed byte = 0x01
+)
+
+// CloseCode says why the host closed the session, at the start of a
+// close-reason v2 MsgClose payload
+type CloseCode byte
+
+const (
+	CloseUnknown     CloseCode = 0x00 // No code given (close-reason v1 or older)
+	CloseShellExited CloseCode = 0x01 // The shell or program exited
+	CloseHostEnded   CloseCode = 0x02 // The host stopped the session
+	CloseIdleTimeout CloseCode = 0x03 // The host ended a session left idle
+	CloseRejected    CloseCode = 0x04 // The host turned this client away
+	CloseError       CloseCode = 0x05 // The host failed
+)
+
+// Bandwidth modes in a MsgBandwidthHint
+const (
+	BandwidthFull    byte = 0x00 // Every output write, as soon as it happens
+	BandwidthEconomy byte = 0x01 // Output merged and sent a few times a second
+)
+
+// Zmodem transfer directions (from the host's point of view)
+const (
+	ZmodemSend    byte = 0x00 // Host runs sz - client receives a file
+	ZmodemReceive byte = 0x01 // Host runs rz - client uploads a file
 )
 
 // Header size: 1 byte type + 2 bytes length
@@ -31,6 +136,62 @@ type Message struct {
 	Payload []byte
 }
 
+// ViewerInfoPayload describes the session to a joining viewer
+type ViewerInfoPayload struct {
+	Flags byte
+	Rows  uint16
+	Cols  uint16
+}
+
+// RecordingAllowed reports whether the host permits viewer recording
+func (v *ViewerInfoPayload) RecordingAllowed() bool {
+	return v.Flags&ViewerRecordingAllowed != 0
+}
+
+// Capabilities maps feature names to the version the peer supports
+type Capabilities map[string]byte
+
+// LocalCapabilities returns the features this build supports
+func LocalCapabilities() Capabilities {
+	return Capabilities{
+		FeaturePingSize:      1,
+		FeatureSizeRequest:   1,
+		FeatureZmodem:        1,
+		FeatureViewerInfo:    1,
+		FeatureCloseReason:   2,
+		FeatureBell:          1,
+		FeatureReset:         1,
+		FeatureNotice:        1,
+		FeatureWriteAccess:   1,
+		FeatureBandwidthHint: 1,
+	}
+}
+
+// Has reports whether a feature is in the set
+func (c Capabilities) Has(feature string) bool {
+	_, ok := c[feature]
+	return ok
+}
+
+// Intersect returns the features in both sets, each at the lower of the
+// two versions
+func (c Capabilities) Intersect(other Capabilities) Capabilities {
+	result := make(Capabilities)
+	for name, version := range c {
+		if otherVersion, ok := other[name]; ok {
+			result[name] = min(version, otherVersion)
+		}
+	}
+	return result
+}
+
+// CapabilitiesPayload is a parsed capabilities message
+type CapabilitiesPayload struct {
+	Version  byte
+	Reply    bool
+	Features Capabilities
+}
+
 // ResizePayload contains terminal dimensions
 type ResizePayload struct {
 	Rows uint16
@@ -43,17 +204,20 @@ const MaxPayloadSize = 65535
 // Encode serializes a message to wire format.
 // Format: [1 byte type][2 byte length (big-endian)][payload]
 func (m *Message) Encode() []byte {
+	return m.AppendEncode(make([]byte, 0, headerSize+min(len(m.Payload), MaxPayloadSize)))
+}
+
+// AppendEncode is Encode appending to dst, so callers can reuse a buffer
+func (m *Message) AppendEncode(dst []byte) []byte {
 	length := len(m.Payload)
 	if length > MaxPayloadSize {
 		// Truncate if too large - this shouldn't happen in normal operation
 		length = MaxPayloadSize
 		m.Payload = m.Payload[:MaxPayloadSize]
 	}
-	buf := make([]byte, headerSize+length)
-	buf[0] = byte(m.Type)
-	binary.BigEndian.PutUint16(buf[1:3], uint16(length)) //nolint:gosec // length is bounds-checked above
-	copy(buf[headerSize:], m.Payload)
-	return buf
+	dst = append(dst, byte(m.Type), 0, 0)
+	binary.BigEndian.PutUint16(dst[len(dst)-2:], uint16(length)) //nolint:gosec // length is bounds-checked above
+	return append(dst, m.Payload...)
 }
 
 // DecodeMessage parses a wire format message.
@@ -113,12 +277,215 @@ func NewPingMessage() *Message {
 	return &Message{Type: MsgPing}
 }
 
+// NewPingMessageWithSize creates a keepalive ping that also carries the sender's
+// current terminal dimensions. The payload layout matches a resize message so
+// the receiver can detect and correct size drift without a separate message.
+func NewPingMessageWithSize(rows, cols uint16) *Message {
+	msg := NewResizeMessage(rows, cols)
+	msg.Type = MsgPing
+	return msg
+}
+
+// ParsePingPayload extracts the optional dimensions from a ping payload.
+// Returns false if the ping carries no size information (plain keepalive).
+func ParsePingPayload(payload []byte) (*ResizePayload, bool) {
+	size, err := ParseResizePayload(payload)
+	if err != nil || size.Rows == 0 || size.Cols == 0 {
+		return nil, false
+	}
+	return size, true
+}
+
+// Key flags carried by the first ping of a connection (NewKeyPingMessage):
+// which key derived from the password the client encrypts with. A host that
+// accepts a fallback key learns from it which key to reply with.
+const (
+	KeySession  byte = 0x00 // The KDF the salt names, Argon2id by default
+	KeyFallback byte = 0x01 // PBKDF2, for browsers that can't run Argon2id
+)
+
+// NewKeyPingMessage creates the ping a client sends first on a connection,
+// naming the key it encrypts with (KeySession or KeyFallback). Hosts that
+// predate it treat it as a plain keepalive.
+func NewKeyPingMessage(key byte) *Message {
+	return &Message{Type: MsgPing, Payload: []byte{key}}
+}
+
+// ParseKeyPingPayload extracts the key flag from a ping payload. Returns
+// false if the ping names no key.
+func ParseKeyPingPayload(payload []byte) (byte, bool) {
+	if len(payload) != 1 {
+		return 0, false
+	}
+	return payload[0], true
+}
+
 // NewPongMessage creates a keepalive pong.
 func NewPongMessage() *Message {
 	return &Message{Type: MsgPong}
 }
 
+// NewZmodemMessage creates a zmodem transfer start notification.
+func NewZmodemMessage(direction byte) *Message {
+	return &Message{
+		Type:    MsgZmodem,
+		Payload: []byte{direction},
+	}
+}
+
+// NewBellMessage creates a terminal bell notification.
+func NewBellMessage() *Message {
+	return &Message{Type: MsgBell}
+}
+
+// NewResetMessage creates a request to reset the host's terminal.
+func NewResetMessage() *Message {
+	return &Message{Type: MsgReset}
+}
+
+// NewNoticeMessage creates a notice for the client to show the user.
+func NewNoticeMessage(text string) *Message {
+	return &Message{
+		Type:    MsgNotice,
+		Payload: []byte(text),
+	}
+}
+
+// NewWriteAccessMessage tells a viewer whether it may type.
+func NewWriteAccessMessage(granted bool) *Message {
+	var payload byte
+	if granted {
+		payload = 1
+	}
+	return &Message{
+		Type:    MsgWriteAccess,
+		Payload: []byte{payload},
+	}
+}
+
+// NewBandwidthHintMessage asks the host for a bandwidth mode.
+func NewBandwidthHintMessage(mode byte) *Message {
+	return &Message{
+		Type:    MsgBandwidthHint,
+		Payload: []byte{mode},
+	}
+}
+
+// ParseBandwidthHintPayload extracts the mode from a bandwidth hint.
+// Unknown modes are returned as they are, for the host to treat as full.
+func ParseBandwidthHintPayload(payload []byte) (byte, error) {
+	if len(payload) < 1 {
+		return 0, ErrMessageTooShort
+	}
+	return payload[0], nil
+}
+
+// NewSizeRequestMessage creates a request for the client's terminal size.
+func NewSizeRequestMessage() *Message {
+	return &Message{Type: MsgSizeRequest}
+}
+
+// NewViewerInfoMessage creates the message sent to a joining viewer.
+func NewViewerInfoMessage(flags byte, rows, cols uint16) *Message {
+	payload := make([]byte, 5)
+	payload[0] = flags
+	binary.BigEndian.PutUint16(payload[1:3], rows)
+	binary.BigEndian.PutUint16(payload[3:5], cols)
+	return &Message{
+		Type:    MsgViewerInfo,
+		Payload: payload,
+	}
+}
+
+// ParseViewerInfoPayload extracts the flags and size from a viewer info message.
+func ParseViewerInfoPayload(payload []byte) (*ViewerInfoPayload, error) {
+	if len(payload) < 5 {
+		return nil, ErrMessageTooShort
+	}
+	return &ViewerInfoPayload{
+		Flags: payload[0],
+		Rows:  binary.BigEndian.Uint16(payload[1:3]),
+		Cols:  binary.BigEndian.Uint16(payload[3:5]),
+	}, nil
+}
+
+// NewCapabilitiesMessage creates a capabilities message. Names longer than
+// 255 bytes are left out.
+func NewCapabilitiesMessage(caps Capabilities, reply bool) *Message {
+	names := make([]string, 0, len(caps))
+	for name := range caps {
+		if len(name) > 0 && len(name) <= 255 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var flags byte
+	if reply {
+		flags |= CapabilitiesReply
+	}
+	payload := []byte{ProtocolVersion, flags}
+	for _, name := range names {
+		payload = append(payload, byte(len(name)))
+		payload = append(payload, name...)
+		payload = append(payload, caps[name])
+	}
+	return &Message{
+		Type:    MsgCapabilities,
+		Payload: payload,
+	}
+}
+
+// ParseCapabilitiesPayload extracts the features from a capabilities message.
+func ParseCapabilitiesPayload(payload []byte) (*CapabilitiesPayload, error) {
+	if len(payload) < 2 {
+		return nil, ErrMessageTooShort
+	}
+	result := &CapabilitiesPayload{
+		Version:  payload[0],
+		Reply:    payload[1]&CapabilitiesReply != 0,
+		Features: make(Capabilities),
+	}
+	rest := payload[2:]
+	for len(rest) > 0 {
+		n := int(rest[0])
+		if n == 0 || len(rest) < 1+n+1 {
+			return nil, ErrInvalidLength
+		}
+		result.Features[string(rest[1:1+n])] = rest[1+n]
+		rest = rest[2+n:]
+	}
+	return result, nil
+}
+
 // NewCloseMessage creates a graceful close message.
 func NewCloseMessage() *Message {
 	return &Message{Type: MsgClose}
 }
+
+// NewCloseMessageWithReason creates a graceful close message carrying a
+// human-readable reason (UTF-8) for the client to display.
+func NewCloseMessageWithReason(reason string) *Message {
+	return &Message{
+		Type:    MsgClose,
+		Payload: []byte(reason),
+	}
+}
+
+// NewCloseMessageWithCode creates a close-reason v2 close message: a code,
+// then an optional human-readable reason (UTF-8).
+func NewCloseMessageWithCode(code CloseCode, reason string) *Message {
+	return &Message{
+		Type:    MsgClose,
+		Payload: append([]byte{byte(code)}, reason...),
+	}
+}
+
+// ParseClosePayload extracts the code and reason from a close-reason v2
+// close message. An empty payload is a close without a reason.
+func ParseClosePayload(payload []byte) (CloseCode, string) {
+	if len(payload) == 0 {
+		return CloseUnknown, ""
+	}
+	return CloseCode(payload[0]), string(payload[1:])
+}
@@ -36,6 +36,11 @@ func TestMessageEncodeDecode(t *testing.T) {
 			msg:     NewCloseMessage(),
 			wantLen: 3,
 		},
+		{
+			name:    "close with reason",
+			msg:     NewCloseMessageWithReason("bye"),
+			wantLen: 6,
+		},
 	}
 
 	for _, tt := range tests {
@@ -97,6 +102,152 @@ func TestResizeMessage(t *testing.T) {
 	}
 }
 
+func TestPingMessageWithSize(t *testing.T) {
+	msg := NewPingMessageWithSize(40, 120)
+	if msg.Type != MsgPing {
+		t.Errorf("type = %v, want MsgPing", msg.Type)
+	}
+
+	decoded, err := DecodeMessage(msg.Encode())
+	if err != nil {
+		t.Fatalf("DecodeMessage failed: %v", err)
+	}
+
+	size, ok := ParsePingPayload(decoded.Payload)
+	if !ok {
+		t.Fatal("expected ping to carry dimensions")
+	}
+	if size.Rows != 40 || size.Cols != 120 {
+		t.Errorf("size = %dx%d, want 120x40", size.Cols, size.Rows)
+	}
+
+	// Plain pings carry no dimensions
+	if _, ok := ParsePingPayload(NewPingMessage().Payload); ok {
+		t.Error("plain ping should not carry dimensions")
+	}
+}
+
+func TestKeyPingMessage(t *testing.T) {
+	decoded, err := DecodeMessage(NewKeyPingMessage(KeyFallback).Encode())
+	if err != nil {
+		t.Fatalf("DecodeMessage failed: %v", err)
+	}
+	if decoded.Type != MsgPing {
+		t.Errorf("type = %v, want MsgPing", decoded.Type)
+	}
+	if key, ok := ParseKeyPingPayload(decoded.Payload); !ok || key != KeyFallback {
+		t.Errorf("key = %d, %v, want %d", key, ok, KeyFallback)
+	}
+	// Hosts that predate the flag see a ping without dimensions
+	if _, ok := ParsePingPayload(decoded.Payload); ok {
+		t.Error("key ping should not carry dimensions")
+	}
+
+	for _, msg := range []*Message{NewPingMessage(), NewPingMessageWithSize(40, 120)} {
+		if _, ok := ParseKeyPingPayload(msg.Payload); ok {
+			t.Errorf("ping with payload %v should name no key", msg.Payload)
+		}
+	}
+}
+
+func TestCloseMessageWithCode(t *testing.T) {
+	decoded, err := DecodeMessage(NewCloseMessageWithCode(CloseShellExited, "the shell exited").Encode())
+	if err != nil {
+		t.Fatalf("DecodeMessage failed: %v", err)
+	}
+	code, reason := ParseClosePayload(decoded.Payload)
+	if code != CloseShellExited || reason != "the shell exited" {
+		t.Errorf("got %v %q, want %v %q", code, reason, CloseShellExited, "the shell exited")
+	}
+
+	if code, reason := ParseClosePayload(NewCloseMessageWithCode(CloseIdleTimeout, "").Payload); code != CloseIdleTimeout || reason != "" {
+		t.Errorf("code without reason: got %v %q", code, reason)
+	}
+	if code, reason := ParseClosePayload(nil); code != CloseUnknown || reason != "" {
+		t.Errorf("empty payload: got %v %q", code, reason)
+	}
+}
+
+func TestBandwidthHintMessage(t *testing.T) {
+	decoded, err := DecodeMessage(NewBandwidthHintMessage(BandwidthEconomy).Encode())
+	if err != nil {
+		t.Fatalf("DecodeMessage failed: %v", err)
+	}
+	if mode, err := ParseBandwidthHintPayload(decoded.Payload); err != nil || mode != BandwidthEconomy {
+		t.Errorf("mode = %d, %v, want %d", mode, err, BandwidthEconomy)
+	}
+	if _, err := ParseBandwidthHintPayload(nil); err != ErrMessageTooShort {
+		t.Errorf("empty payload: err = %v, want %v", err, ErrMessageTooShort)
+	}
+}
+
+func TestViewerInfoMessage(t *testing.T) {
+	decoded, err := DecodeMessage(NewViewerInfoMessage(ViewerRecordingAllowed, 40, 120).Encode())
+	if err != nil {
+		t.Fatalf("DecodeMessage failed: %v", err)
+	}
+
+	info, err := ParseViewerInfoPayload(decoded.Payload)
+	if err != nil {
+		t.Fatalf("ParseViewerInfoPayload failed: %v", err)
+	}
+	if !info.RecordingAllowed() {
+		t.Error("expected recording to be allowed")
+	}
+	if info.Rows != 40 || info.Cols != 120 {
+		t.Errorf("size = %dx%d, want 120x40", info.Cols, info.Rows)
+	}
+
+	info, err = ParseViewerInfoPayload(NewViewerInfoMessage(0, 24, 80).Payload)
+	if err != nil || info.RecordingAllowed() {
+		t.Errorf("recording should be disallowed without the flag (err %v)", err)
+	}
+
+	if _, err := ParseViewerInfoPayload([]byte{0x01, 0x00}); err != ErrMessageTooShort {
+		t.Errorf("expected ErrMessageTooShort, got %v", err)
+	}
+}
+
+func TestCapabilitiesMessage(t *testing.T) {
+	caps := Capabilities{FeatureZmodem: 1, "future-thing": 3}
+	decoded, err := DecodeMessage(NewCapabilitiesMessage(caps, true).Encode())
+	if err != nil {
+		t.Fatalf("DecodeMessage failed: %v", err)
+	}
+
+	parsed, err := ParseCapabilitiesPayload(decoded.Payload)
+	if err != nil {
+		t.Fatalf("ParseCapabilitiesPayload failed: %v", err)
+	}
+	if parsed.Version != ProtocolVersion || !parsed.Reply {
+		t.Errorf("version %d reply %v, want %d true", parsed.Version, parsed.Reply, ProtocolVersion)
+	}
+	if len(parsed.Features) != 2 || parsed.Features[FeatureZmodem] != 1 || parsed.Features["future-thing"] != 3 {
+		t.Errorf("features = %v, want %v", parsed.Features, caps)
+	}
+
+	// Truncated feature entry
+	if _, err := ParseCapabilitiesPayload([]byte{ProtocolVersion, 0, 5, 'z', 'm'}); err != ErrInvalidLength {
+		t.Errorf("expected ErrInvalidLength, got %v", err)
+	}
+	if _, err := ParseCapabilitiesPayload([]byte{ProtocolVersion}); err != ErrMessageTooShort {
+		t.Errorf("expected ErrMessageTooShort, got %v", err)
+	}
+}
+
+func TestCapabilitiesIntersect(t *testing.T) {
+	local := Capabilities{FeatureZmodem: 2, FeaturePingSize: 1, "local-only": 1}
+	remote := Capabilities{FeatureZmodem: 1, FeaturePingSize: 1, "remote-only": 1}
+
+	got := local.Intersect(remote)
+	if len(got) != 2 || got[FeatureZmodem] != 1 || got[FeaturePingSize] != 1 {
+		t.Errorf("Intersect = %v, want zmodem:1 ping-size:1", got)
+	}
+	if got.Has("local-only") || got.Has("remote-only") {
+		t.Error("one-sided features should not be negotiated")
+	}
+}
+
 func TestDecodeMessageTooShort(t *testing.T) {
 	_, err := DecodeMessage([]byte{0x01, 0x00})
 	if err != ErrMessageTooShort {
@@ -150,6 +301,17 @@ func TestMessageTypes(t *testing.T) {
 		{NewPingMessage(), MsgPing},
 		{NewPongMessage(), MsgPong},
 		{NewCloseMessage(), MsgClose},
+		{NewCloseMessageWithReason("bye"), MsgClose},
+		{NewCloseMessageWithCode(CloseHostEnded, "bye"), MsgClose},
+		{NewZmodemMessage(ZmodemSend), MsgZmodem},
+		{NewSizeRequestMessage(), MsgSizeRequest},
+		{NewBellMessage(), MsgBell},
+		{NewResetMessage(), MsgReset},
+		{NewNoticeMessage("A viewer joined"), MsgNotice},
+		{NewWriteAccessMessage(true), MsgWriteAccess},
+		{NewBandwidthHintMessage(BandwidthEconomy), MsgBandwidthHint},
+		{NewViewerInfoMessage(ViewerRecordingAllowed, 24, 80), MsgViewerInfo},
+		{NewCapabilitiesMessage(LocalCapabilities(), false), MsgCapabilities},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,89 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// followPollInterval is how often Follow checks for newly appended events
+const followPollInterval = 100 * time.Millisecond
+
+// Follow plays a recording from the start and then keeps tailing the file,
+// playing events as they are appended (like tail -f). Pacing follows the
+// recorded timestamps adjusted for speed, so events that arrive in a burst
+// are spread out as they were recorded. Returns when the recorder finishes
+// (its live marker is removed) or Stop is called.
+func (p *Player) Follow(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+
+	// Header was already parsed by LoadRecording
+	if _, err := reader.ReadString('\n'); err != nil {
+		return fmt.Errorf("failed to read header: %w", err)
+	}
+
+	// Events are re-read from the file as they are played
+	p.recording.Events = p.recording.Events[:0]
+	p.index = 0
+	p.stopped = false
+	p.paused = false
+
+	var lastTime float64
+	lastWall := time.Now()
+	partial := ""
+	finished := false
+
+	for !p.stopped {
+		line, err := reader.ReadString('\n')
+		partial += line
+		if err == io.EOF {
+			// Drain once more after the recorder finishes, then stop
+			if finished {
+				return nil
+			}
+			finished = LivePID(path) == 0
+			if !finished {
+				time.Sleep(followPollInterval)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read recording: %w", err)
+		}
+
+		var event Event
+		if err := json.Unmarshal([]byte(partial), &event); err != nil {
+			// Skip malformed lines
+			partial = ""
+			continue
+		}
+		partial = ""
+
+		// Wait out whatever part of the recorded gap hasn't already elapsed
+		delay := time.Duration(float64(time.Second) * (event.Time - lastTime) / p.speed)
+		if delay > 2*time.Second {
+			delay = 2 * time.Second
+		}
+		if wait := delay - time.Since(lastWall); wait > 0 {
+			time.Sleep(wait)
+		}
+
+		p.writeEvent(event)
+		p.recording.Events = append(p.recording.Events, event)
+		p.index++
+
+		lastTime = event.Time
+		lastWall = time.Now()
+	}
+
+	return nil
+}
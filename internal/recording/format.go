@@ -26,11 +26,11 @@ type Theme struct {
 
 // Event represents a single asciicast event
 // Format: [time, event_type, data]
-// event_type: "o" for output, "i" for input, "r" for resize
+// event_type: "o" for output, "i" for input, "r" for resize, "m" for marker
 type Event struct {
 	Time float64 // Seconds since start
-	Type string  // "o" = output, "i" = input, "r" = resize
-	Data string  // Event data (terminal output or input)
+	Type string  // "o" = output, "i" = input, "r" = resize, "m" = marker
+	Data string  // Event data (terminal output or input, or marker label)
 }
 
 // MarshalJSON implements custom JSON marshaling for Event
@@ -90,6 +90,17 @@ func (r *Recording) Duration() time.Duration {
 	return time.Duration(lastEvent.Time * float64(time.Second))
 }
 
+// Markers returns the marker events in the recording, in order
+func (r *Recording) Markers() []Event {
+	var markers []Event
+	for _, event := range r.Events {
+		if event.Type == "m" {
+			markers = append(markers, event)
+		}
+	}
+	return markers
+}
+
 // EventCount returns the number of events in the recording
 func (r *Recording) EventCount() int {
 	return len(r.Events)
@@ -17,6 +17,8 @@ type Player struct {
 	index     int
 	paused    bool
 	stopped   bool
+	jump      chan int    // Pending marker jump: +1 next, -1 previous
+	onMarker  func(Event) // Called when playback reaches a marker
 }
 
 // NewPlayer creates a new player for the given recording
@@ -26,6 +28,7 @@ func NewPlayer(rec *Recording, output io.Writer) *Player {
 		speed:     1.0,
 		output:    output,
 		index:     0,
+		jump:      make(chan int, 1),
 	}
 }
 
@@ -104,29 +107,25 @@ func (p *Player) Resume() error {
 		event := p.recording.Events[p.index]
 
 		// Calculate delay
+		var adjustedDelay time.Duration
 		delay := event.Time - lastTime
 		if delay > 0 {
 			// Apply speed adjustment
-			adjustedDelay := time.Duration(float64(time.Second) * delay / p.speed)
+			adjustedDelay = time.Duration(float64(time.Second) * delay / p.speed)
 
 			// Cap maximum delay to 2 seconds
 			if adjustedDelay > 2*time.Second {
 				adjustedDelay = 2 * time.Second
 			}
-
-			time.Sleep(adjustedDelay)
 		}
 
-		// Handle event based on type
-		switch event.Type {
-		case "o": // output
-			p.output.Write([]byte(event.Data))
-		case "i": // input - typically not played back
-			// Could optionally display input differently
-		case "r": // resize
-			// Could signal terminal resize if supported
+		if dir, ok := p.wait(adjustedDelay); ok {
+			lastTime = p.jumpMarker(dir, lastTime)
+			continue
 		}
 
+		p.writeEvent(event)
+
 		lastTime = event.Time
 		p.index++
 	}
@@ -134,11 +133,115 @@ func (p *Player) Resume() error {
 	return nil
 }
 
+// writeEvent renders a single event to the output
+func (p *Player) writeEvent(event Event) {
+	// Handle event based on type
+	switch event.Type {
+	case "o": // output
+		p.output.Write([]byte(event.Data))
+	case "i": // input - typically not played back
+		// Could optionally display input differently
+	case "r": // resize
+		// Could signal terminal resize if supported
+	case "m": // marker
+		if p.onMarker != nil {
+			p.onMarker(event)
+		}
+	}
+}
+
+// wait sleeps for d, returning early with the direction of a marker jump
+// requested in the meantime
+func (p *Player) wait(d time.Duration) (int, bool) {
+	if d <= 0 {
+		select {
+		case dir := <-p.jump:
+			return dir, true
+		default:
+			return 0, false
+		}
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case dir := <-p.jump:
+		return dir, true
+	case <-timer.C:
+		return 0, false
+	}
+}
+
+// jumpMarker moves playback to the next (dir > 0) or previous (dir < 0)
+// marker and redraws the screen as it was at that point. Jumping back past
+// the first marker restarts from the beginning. Returns the timestamp to
+// pace the following events from.
+func (p *Player) jumpMarker(dir int, lastTime float64) float64 {
+	events := p.recording.Events
+	target := -1
+	if dir > 0 {
+		for i := p.index; i < len(events); i++ {
+			if events[i].Type == "m" {
+				target = i
+				break
+			}
+		}
+		if target < 0 {
+			return lastTime
+		}
+	} else {
+		// Skip the marker just played so repeated presses keep going back
+		for i := p.index - 2; i >= 0; i-- {
+			if events[i].Type == "m" {
+				target = i
+				break
+			}
+		}
+	}
+
+	p.output.Write([]byte("\x1b[H\x1b[2J"))
+	if target < 0 {
+		p.index = 0
+		return 0
+	}
+
+	p.index = target
+	p.PlayInstant()
+	p.writeEvent(events[target])
+	p.index = target + 1
+	return events[target].Time
+}
+
 // Pause pauses playback
 func (p *Player) Pause() {
 	p.paused = true
 }
 
+// NextMarker jumps to the next marker during playback
+func (p *Player) NextMarker() {
+	p.requestJump(1)
+}
+
+// PrevMarker jumps back to the previous marker during playback
+func (p *Player) PrevMarker() {
+	p.requestJump(-1)
+}
+
+// requestJump queues a marker jump for the playback loop, dropping it if
+// one is already pending
+func (p *Player) requestJump(dir int) {
+	select {
+	case p.jump <- dir:
+	default:
+	}
+}
+
+// SetMarkerHandler sets a callback invoked when playback reaches a marker
+func (p *Player) SetMarkerHandler(fn func(Event)) {
+	p.onMarker = fn
+}
+
 // Stop stops playback
 func (p *Player) Stop() {
 	p.stopped = true
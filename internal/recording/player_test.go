@@ -0,0 +1,86 @@
+package recording
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPlayerJumpMarker(t *testing.T) {
+	rec := &Recording{
+		Header: Header{Version: 2, Width: 80, Height: 24},
+		Events: []Event{
+			{Time: 0.1, Type: "o", Data: "a"},
+			{Time: 0.2, Type: "m", Data: "one"},
+			{Time: 0.3, Type: "o", Data: "b"},
+			{Time: 0.4, Type: "m", Data: "two"},
+			{Time: 0.5, Type: "o", Data: "c"},
+		},
+	}
+	const clear = "\x1b[H\x1b[2J"
+
+	tests := []struct {
+		name      string
+		index     int // Next event to play
+		dir       int
+		wantIndex int
+		wantTime  float64
+		wantOut   string
+		wantMark  string
+	}{
+		{"next from the start", 0, 1, 2, 0.2, clear + "a", "one"},
+		{"next after the first", 2, 1, 4, 0.4, clear + "ab", "two"},
+		{"next past the last", 5, 1, 5, 0.45, "", ""},
+		{"back from the end", 5, -1, 4, 0.4, clear + "ab", "two"},
+		{"back from just after the second", 4, -1, 2, 0.2, clear + "a", "one"},
+		{"back from just after the first", 2, -1, 0, 0, clear, ""},
+		{"back from the start", 0, -1, 0, 0, clear, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			p := NewPlayer(rec, &out)
+			var mark string
+			p.SetMarkerHandler(func(e Event) { mark = e.Data })
+			p.index = tt.index
+
+			got := p.jumpMarker(tt.dir, 0.45)
+			if p.index != tt.wantIndex || got != tt.wantTime {
+				t.Errorf("jumpMarker(%d) = index %d at %v, want index %d at %v", tt.dir, p.index, got, tt.wantIndex, tt.wantTime)
+			}
+			if out.String() != tt.wantOut {
+				t.Errorf("output = %q, want %q", out.String(), tt.wantOut)
+			}
+			if mark != tt.wantMark {
+				t.Errorf("marker reached = %q, want %q", mark, tt.wantMark)
+			}
+		})
+	}
+}
+
+func TestPlayerNextMarkerDuringPlayback(t *testing.T) {
+	rec := &Recording{
+		Header: Header{Version: 2, Width: 80, Height: 24},
+		Events: []Event{
+			{Time: 60, Type: "o", Data: "skipped wait "},
+			{Time: 61, Type: "m", Data: "here"},
+			{Time: 62, Type: "o", Data: "rest"},
+		},
+	}
+	var out bytes.Buffer
+	p := NewPlayer(rec, &out)
+	var marks []string
+	p.SetMarkerHandler(func(e Event) { marks = append(marks, e.Data) })
+
+	// Queued before playback, the jump cuts the first long wait short
+	p.NextMarker()
+	p.SetSpeed(100)
+	if err := p.Play(); err != nil {
+		t.Fatalf("Play: %v", err)
+	}
+	if want := "\x1b[H\x1b[2Jskipped wait rest"; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+	if len(marks) != 1 || marks[0] != "here" {
+		t.Errorf("markers reached = %q, want [here]", marks)
+	}
+}
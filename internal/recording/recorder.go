@@ -3,12 +3,26 @@ package recording
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/artpar/terminal-tunnel/internal/paths"
 )
 
+// flushInterval is how often an in-progress recording is synced to disk.
+// Events are written unbuffered, so readers always see complete lines;
+// the sync makes them durable if the host crashes mid-session.
+const flushInterval = 2 * time.Second
+
+// liveSuffix marks a recording that is still being written. The marker file
+// holds the recorder's PID and is removed when the recorder closes.
+const liveSuffix = ".live"
+
 // Recorder writes terminal output to an asciicast v2 file
 type Recorder struct {
 	file      *os.File
@@ -17,6 +31,7 @@ type Recorder struct {
 	height    int
 	mu        sync.Mutex
 	closed    bool
+	stopFlush chan struct{}
 }
 
 // NewRecorder creates a new recorder that writes to the specified path
@@ -44,6 +59,7 @@ func NewRecorder(path string, width, height int, title string) (*Recorder, error
 		startTime: time.Now(),
 		width:     width,
 		height:    height,
+		stopFlush: make(chan struct{}),
 	}
 
 	// Write header
@@ -59,9 +75,34 @@ func NewRecorder(path string, width, height int, title string) (*Recorder, error
 		return nil, fmt.Errorf("failed to write header: %w", err)
 	}
 
+	// Mark as in progress (best effort - only affects listing)
+	_ = os.WriteFile(path+liveSuffix, []byte(strconv.Itoa(os.Getpid())), 0600)
+
+	go r.flushLoop()
+
 	return r, nil
 }
 
+// flushLoop periodically syncs the file so an in-progress recording is
+// always a valid, playable asciicast on disk
+func (r *Recorder) flushLoop() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stopFlush:
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			if !r.closed {
+				_ = r.file.Sync()
+			}
+			r.mu.Unlock()
+		}
+	}
+}
+
 // WriteOutput records terminal output data
 func (r *Recorder) WriteOutput(data []byte) error {
 	r.mu.Lock()
@@ -91,7 +132,9 @@ func (r *Recorder) WriteOutput(data []byte) error {
 	return nil
 }
 
-// WriteInput records terminal input data (optional, for full recording)
+// WriteInput records terminal input data (optional, for full recording).
+// Sessions don't call it: input includes passwords typed with echo off,
+// which output recordings never see.
 func (r *Recorder) WriteInput(data []byte) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -152,6 +195,36 @@ func (r *Recorder) WriteResize(width, height int) error {
 	return nil
 }
 
+// WriteMarker records an asciicast v2 marker event. Players use markers as
+// chapters, so the label should describe the moment (e.g. "deploy started").
+func (r *Recorder) WriteMarker(label string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return fmt.Errorf("recorder is closed")
+	}
+
+	elapsed := time.Since(r.startTime).Seconds()
+
+	event := Event{
+		Time: elapsed,
+		Type: "m", // marker
+		Data: label,
+	}
+
+	eventData, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	if _, err := r.file.Write(append(eventData, '\n')); err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+
+	return nil
+}
+
 // Close closes the recorder and flushes any pending data
 func (r *Recorder) Close() error {
 	r.mu.Lock()
@@ -162,6 +235,8 @@ func (r *Recorder) Close() error {
 	}
 
 	r.closed = true
+	close(r.stopFlush)
+	_ = os.Remove(r.file.Name() + liveSuffix)
 
 	if err := r.file.Sync(); err != nil {
 		r.file.Close()
@@ -171,6 +246,58 @@ func (r *Recorder) Close() error {
 	return r.file.Close()
 }
 
+// CopyTo writes a snapshot of the recording so far to dst and returns its
+// size. Recording continues. Events are appended whole under mu, so the
+// bytes written before the snapshot are a valid asciicast on their own and
+// are copied without holding up the session. dst is replaced atomically:
+// readers see either the old file or the complete snapshot.
+func (r *Recorder) CopyTo(dst string) (int64, error) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return 0, fmt.Errorf("recorder is closed")
+	}
+	size, err := r.file.Seek(0, io.SeekCurrent)
+	if err == nil {
+		err = r.file.Sync()
+	}
+	r.mu.Unlock()
+	if err != nil {
+		return 0, fmt.Errorf("failed to flush recording: %w", err)
+	}
+
+	src, err := os.Open(r.file.Name())
+	if err != nil {
+		return 0, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, io.NewSectionReader(src, 0, size))
+	if err == nil && n != size {
+		err = io.ErrUnexpectedEOF
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy recording: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return 0, fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+	return n, nil
+}
+
 // Path returns the path to the recording file
 func (r *Recorder) Path() string {
 	return r.file.Name()
@@ -181,13 +308,10 @@ func (r *Recorder) Duration() time.Duration {
 	return time.Since(r.startTime)
 }
 
-// GetRecordingsDir returns the default recordings directory
+// GetRecordingsDir returns the default recordings directory, under the data
+// directory (see paths.DataDir)
 func GetRecordingsDir() string {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return ".tt/recordings"
-	}
-	return filepath.Join(homeDir, ".tt", "recordings")
+	return filepath.Join(paths.DataDir(), "recordings")
 }
 
 // GenerateRecordingPath generates a unique recording file path
@@ -233,6 +357,7 @@ func ListRecordings() ([]RecordingInfo, error) {
 				Size:     info.Size(),
 				ModTime:  info.ModTime(),
 				Duration: 0,
+				LivePID:  LivePID(path),
 			})
 			continue
 		}
@@ -253,6 +378,22 @@ type RecordingInfo struct {
 	Width    int
 	Height   int
 	Title    string
+	LivePID  int // PID of the process still writing this recording, 0 if finished
+}
+
+// LivePID returns the PID recorded in the in-progress marker for a
+// recording, or 0 if the recording has been finalized. The process may
+// have died without cleaning up, so callers should check it is running.
+func LivePID(path string) int {
+	data, err := os.ReadFile(path + liveSuffix)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
 }
 
 // LoadRecordingInfo loads metadata from a recording file
@@ -303,6 +444,7 @@ func LoadRecordingInfo(path string) (*RecordingInfo, error) {
 		Width:   header.Width,
 		Height:  header.Height,
 		Title:   header.Title,
+		LivePID: LivePID(path),
 	}
 
 	return rec, nil
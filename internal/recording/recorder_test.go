@@ -0,0 +1,53 @@
+package recording
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteMarker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.cast")
+	r, err := NewRecorder(path, 80, 24, "test")
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	steps := []func() error{
+		func() error { return r.WriteOutput([]byte("$ make\r\n")) },
+		func() error { return r.WriteMarker("build started") },
+		func() error { return r.WriteOutput([]byte("ok\r\n")) },
+		func() error { return r.WriteMarker("") },
+		func() error { return r.WriteMarker("done: \"all\" ✓") },
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := r.WriteMarker("late"); err == nil {
+		t.Error("WriteMarker after Close succeeded")
+	}
+
+	rec, err := LoadRecording(path)
+	if err != nil {
+		t.Fatalf("LoadRecording: %v", err)
+	}
+	markers := rec.Markers()
+	want := []string{"build started", "", "done: \"all\" ✓"}
+	if len(markers) != len(want) {
+		t.Fatalf("Markers() = %+v, want %q", markers, want)
+	}
+	for i, m := range markers {
+		if m.Type != "m" || m.Data != want[i] {
+			t.Errorf("marker %d = %+v, want %q", i, m, want[i])
+		}
+		if i > 0 && m.Time < markers[i-1].Time {
+			t.Errorf("marker %d at %v, before marker %d at %v", i, m.Time, i-1, markers[i-1].Time)
+		}
+	}
+	if n := rec.EventCount(); n != len(steps) {
+		t.Errorf("EventCount() = %d, want %d", n, len(steps))
+	}
+}
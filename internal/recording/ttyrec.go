@@ -0,0 +1,39 @@
+package recording
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// WriteTTYRec writes rec in the ttyrec format read by ttyplay and other
+// ttyrec tools: one record per output event, each a header of three
+// little-endian uint32s (seconds and microseconds of the wall-clock time,
+// then the data length) followed by the data. Times are the recording's
+// start plus the event offset. ttyrec has no place for input, resizes or
+// markers, so those events are left out.
+func WriteTTYRec(w io.Writer, rec *Recording) error {
+	start := time.Unix(rec.Header.Timestamp, 0)
+	var header [12]byte
+	for _, event := range rec.Events {
+		if event.Type != "o" || event.Data == "" {
+			continue
+		}
+		if len(event.Data) > math.MaxUint32 {
+			return fmt.Errorf("event at %.3fs is too large for ttyrec", event.Time)
+		}
+		t := start.Add(time.Duration(math.Round(event.Time*1e6)) * time.Microsecond)
+		binary.LittleEndian.PutUint32(header[0:], uint32(t.Unix()))
+		binary.LittleEndian.PutUint32(header[4:], uint32(t.Nanosecond()/1000))
+		binary.LittleEndian.PutUint32(header[8:], uint32(len(event.Data)))
+		if _, err := w.Write(header[:]); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, event.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package recording
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func TestWriteTTYRec(t *testing.T) {
+	rec := &Recording{
+		Header: Header{Version: 2, Width: 80, Height: 24, Timestamp: 1700000000},
+		Events: []Event{
+			{Time: 0.5, Type: "o", Data: "$ ls\r\n"},
+			{Time: 0.75, Type: "i", Data: "ls\r"},
+			{Time: 1.25, Type: "r", Data: "100x30"},
+			{Time: 2.000125, Type: "o", Data: "file.txt\r\n"},
+			{Time: 3, Type: "m", Data: "done"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteTTYRec(&buf, rec); err != nil {
+		t.Fatalf("WriteTTYRec: %v", err)
+	}
+
+	type record struct {
+		sec, usec uint32
+		data      string
+	}
+	var got []record
+	r := bytes.NewReader(buf.Bytes())
+	for {
+		var header [3]uint32
+		if err := binary.Read(r, binary.LittleEndian, &header); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("reading header: %v", err)
+		}
+		data := make([]byte, header[2])
+		if _, err := io.ReadFull(r, data); err != nil {
+			t.Fatalf("reading data: %v", err)
+		}
+		got = append(got, record{header[0], header[1], string(data)})
+	}
+
+	// Only output is kept, timed from the recording's start
+	want := []record{
+		{1700000000, 500000, "$ ls\r\n"},
+		{1700000002, 125, "file.txt\r\n"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d records %+v, want %+v", len(got), got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("record %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
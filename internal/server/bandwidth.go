@@ -0,0 +1,84 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/artpar/terminal-tunnel/internal/protocol"
+	ttwebrtc "github.com/artpar/terminal-tunnel/internal/webrtc"
+)
+
+// economyInterval is how often a connection in economy mode gets output.
+// A rapidly redrawing screen then costs a few frames a second, each
+// carrying only the bytes written since the last.
+const economyInterval = 200 * time.Millisecond
+
+// bandwidthSender sends output to one connection in the bandwidth mode its
+// client asked for (protocol.MsgBandwidthHint). In full mode every write is
+// sent as it comes; in economy mode output is merged and sent at most once
+// per economyInterval. Errors from merged sends are dropped: a connection
+// that fails is noticed by its close handler and keepalive.
+type bandwidthSender struct {
+	send func([]byte) error
+
+	mu      sync.Mutex
+	economy bool
+	pending []byte
+	armed   bool // A flush is scheduled
+}
+
+func newBandwidthSender(send func([]byte) error) *bandwidthSender {
+	return &bandwidthSender{send: send}
+}
+
+// Send sends data, or queues it for the next flush in economy mode
+func (s *bandwidthSender) Send(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.economy {
+		return s.send(data)
+	}
+	if len(s.pending)+len(data) > coalesceMaxFrame {
+		// Keep frames under the data channel message limit
+		_ = s.send(s.pending)
+		s.pending = nil
+	}
+	s.pending = append(s.pending, data...)
+	if !s.armed {
+		s.armed = true
+		time.AfterFunc(economyInterval, s.flush)
+	}
+	return nil
+}
+
+// flush sends the output merged since the last flush
+func (s *bandwidthSender) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.armed = false
+	if len(s.pending) > 0 {
+		_ = s.send(s.pending)
+		s.pending = nil
+	}
+}
+
+// SetMode switches between protocol.BandwidthFull and
+// protocol.BandwidthEconomy; unknown modes mean full. Leaving economy mode
+// sends what is queued at once.
+func (s *bandwidthSender) SetMode(mode byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.economy = mode == protocol.BandwidthEconomy
+	if !s.economy && len(s.pending) > 0 {
+		_ = s.send(s.pending)
+		s.pending = nil
+	}
+}
+
+// bandwidthSend returns the send function for output to channel, in the
+// bandwidth mode its client asks for
+func bandwidthSend(channel *ttwebrtc.EncryptedChannel) func([]byte) error {
+	sender := newBandwidthSender(channel.SendData)
+	channel.OnBandwidthHint(sender.SetMode)
+	return sender.Send
+}
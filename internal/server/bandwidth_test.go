@@ -0,0 +1,79 @@
+package server
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/artpar/terminal-tunnel/internal/protocol"
+)
+
+// sentFrames collects what a bandwidthSender sends
+type sentFrames struct {
+	mu     sync.Mutex
+	frames []string
+}
+
+func (f *sentFrames) send(data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.frames = append(f.frames, string(data))
+	return nil
+}
+
+func (f *sentFrames) get() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.frames...)
+}
+
+func TestBandwidthSenderFull(t *testing.T) {
+	var sent sentFrames
+	s := newBandwidthSender(sent.send)
+	s.Send([]byte("a"))
+	s.Send([]byte("b"))
+	if got := sent.get(); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("frames = %q, want each write as it comes", got)
+	}
+}
+
+func TestBandwidthSenderEconomy(t *testing.T) {
+	var sent sentFrames
+	s := newBandwidthSender(sent.send)
+	s.SetMode(protocol.BandwidthEconomy)
+
+	for _, data := range []string{"frame1 ", "frame2 ", "frame3"} {
+		s.Send([]byte(data))
+	}
+	if got := sent.get(); len(got) != 0 {
+		t.Fatalf("frames = %q before the interval, want none", got)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(sent.get()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := sent.get(); len(got) != 1 || got[0] != "frame1 frame2 frame3" {
+		t.Errorf("frames = %q, want the writes merged into one", got)
+	}
+}
+
+func TestBandwidthSenderBackToFull(t *testing.T) {
+	var sent sentFrames
+	s := newBandwidthSender(sent.send)
+	s.SetMode(protocol.BandwidthEconomy)
+	s.Send([]byte("queued "))
+
+	// Queued output goes out at once, ahead of what follows
+	s.SetMode(protocol.BandwidthFull)
+	s.Send([]byte("live"))
+	if got := sent.get(); len(got) != 2 || got[0] != "queued " || got[1] != "live" {
+		t.Errorf("frames = %q, want the queued output then the new write", got)
+	}
+
+	// The flush scheduled in economy mode finds nothing left
+	time.Sleep(economyInterval + 50*time.Millisecond)
+	if got := sent.get(); len(got) != 2 {
+		t.Errorf("frames = %q after the interval, want no more", got)
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import "time"
+
+// bellMinInterval limits bell notifications, so output that rings many
+// times (a tab completion storm, binary on the terminal) notifies once
+const bellMinInterval = time.Second
+
+// bellDetector finds terminal bells (BEL) in PTY output. BEL also ends OSC
+// sequences such as the window title that many prompts set, so BEL inside
+// an escape string is not a bell. State carries across chunks.
+type bellDetector struct {
+	state    bellState
+	lastRing time.Time
+}
+
+type bellState byte
+
+const (
+	bellGround       bellState = iota
+	bellEscape                 // After ESC
+	bellString                 // Inside OSC, DCS, SOS, PM or APC
+	bellStringEscape           // After ESC inside a string, maybe ST (ESC \)
+)
+
+// Scan reports whether data rings the bell, at most once per
+// bellMinInterval
+func (d *bellDetector) Scan(data []byte, now time.Time) bool {
+	rang := false
+	for _, c := range data {
+		switch d.state {
+		case bellGround:
+			switch c {
+			case 0x07:
+				rang = true
+			case 0x1b:
+				d.state = bellEscape
+			}
+		case bellEscape:
+			switch c {
+			case ']', 'P', 'X', '^', '_':
+				d.state = bellString
+			case 0x1b:
+			default:
+				d.state = bellGround
+			}
+		case bellString:
+			switch c {
+			case 0x07:
+				d.state = bellGround // Ends an OSC
+			case 0x1b:
+				d.state = bellStringEscape
+			}
+		case bellStringEscape:
+			if c == '\\' {
+				d.state = bellGround
+			} else if c != 0x1b {
+				d.state = bellString
+			}
+		}
+	}
+
+	if !rang || now.Sub(d.lastRing) < bellMinInterval {
+		return false
+	}
+	d.lastRing = now
+	return true
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBellDetector(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   bool
+	}{
+		{
+			name:   "plain bell",
+			chunks: []string{"build done\a"},
+			want:   true,
+		},
+		{
+			name:   "window title ended by BEL",
+			chunks: []string{"\x1b]0;user@host: ~\a$ "},
+			want:   false,
+		},
+		{
+			name:   "title split across reads",
+			chunks: []string{"\x1b]2;vim", " main.go\a"},
+			want:   false,
+		},
+		{
+			name:   "bell after a title ended by ST",
+			chunks: []string{"\x1b]0;title\x1b\\", "\a"},
+			want:   true,
+		},
+		{
+			name:   "BEL inside DCS",
+			chunks: []string{"\x1bPq#0\a\x1b\\"},
+			want:   false,
+		},
+		{
+			name:   "CSI sequence then bell",
+			chunks: []string{"\x1b[1;32mok\x1b[0m\a"},
+			want:   true,
+		},
+		{
+			name:   "no bell",
+			chunks: []string{"$ ls\r\n", "\x1b]0;title\a"},
+			want:   false,
+		},
+	}
+
+	now := time.Now()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d bellDetector
+			rang := false
+			for _, chunk := range tt.chunks {
+				if d.Scan([]byte(chunk), now) {
+					rang = true
+				}
+			}
+			if rang != tt.want {
+				t.Errorf("rang = %v, want %v", rang, tt.want)
+			}
+		})
+	}
+}
+
+func TestBellDetectorRateLimit(t *testing.T) {
+	var d bellDetector
+	now := time.Now()
+
+	if !d.Scan([]byte("\a"), now) {
+		t.Fatal("first bell not reported")
+	}
+	if d.Scan([]byte("\a"), now.Add(bellMinInterval/2)) {
+		t.Error("bell within bellMinInterval should be suppressed")
+	}
+	if !d.Scan([]byte("\a"), now.Add(bellMinInterval)) {
+		t.Error("bell after bellMinInterval not reported")
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// Binary detection thresholds. Chunks shorter than binaryMinSample are never
+// flagged, so short escape sequences and keystroke echoes pass through.
+const (
+	binaryMinSample = 64
+	binaryMaxRatio  = 0.3 // Fraction of non-text bytes above which output is binary
+
+	// After a zmodem start the guard stands down until output goes quiet,
+	// since the transfer itself is binary
+	binaryZmodemQuiet = 2 * time.Second
+)
+
+// binaryWarning is streamed to the client in place of the suppressed output
+var binaryWarning = []byte("\r\n\x1b[33m[tt] Binary output detected - streaming paused. Press any key to continue.\x1b[0m\r\n")
+
+// looksBinary reports whether a chunk of PTY output is mostly non-text.
+// Printable ASCII, common control characters and valid UTF-8 count as text.
+func looksBinary(data []byte) bool {
+	if len(data) < binaryMinSample {
+		return false
+	}
+
+	nonText := 0
+	for i := 0; i < len(data); {
+		c := data[i]
+		if c >= 0x80 {
+			r, size := utf8.DecodeRune(data[i:])
+			if r == utf8.RuneError && size <= 1 {
+				nonText++
+				i++
+				continue
+			}
+			i += size
+			continue
+		}
+		switch {
+		case c >= 0x20 && c < 0x7f:
+		case c == '\t', c == '\n', c == '\r', c == '\b', c == '\a', c == 0x1b:
+		default:
+			nonText++
+		}
+		i++
+	}
+
+	return float64(nonText)/float64(len(data)) > binaryMaxRatio
+}
+
+// binaryGuard suppresses binary output to remote clients until they
+// acknowledge it with a keypress. It is disabled unless enabled is set.
+// Filter runs under the bridge lock; Release is lock-free so input is
+// never blocked behind a slow send.
+type binaryGuard struct {
+	enabled bool
+	held    atomic.Bool // Streaming paused until the client presses a key
+	zmodem  bool        // zmodem transfer in progress, guard stands down
+	last    time.Time   // Time of the previous chunk
+}
+
+// Filter returns the bytes to stream to remote clients for a chunk of output:
+// the data itself, the warning when the guard trips, or nil while held.
+func (g *binaryGuard) Filter(data []byte, zmodemStart bool, now time.Time) []byte {
+	if !g.enabled {
+		return data
+	}
+
+	if zmodemStart {
+		g.zmodem = true
+	} else if g.zmodem && now.Sub(g.last) > binaryZmodemQuiet {
+		g.zmodem = false
+	}
+	g.last = now
+
+	if g.held.Load() {
+		return nil
+	}
+	if !g.zmodem && looksBinary(data) {
+		g.held.Store(true)
+		return binaryWarning
+	}
+	return data
+}
+
+// Release resumes streaming after the client acknowledges the warning.
+// Returns true if the guard was holding output.
+func (g *binaryGuard) Release() bool {
+	return g.held.CompareAndSwap(true, false)
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLooksBinary(t *testing.T) {
+	binary := make([]byte, 512)
+	for i := range binary {
+		binary[i] = byte(i)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"plain text", []byte(strings.Repeat("hello world\r\n", 20)), false},
+		{"ansi colors", []byte(strings.Repeat("\x1b[31mred\x1b[0m text\r\n", 20)), false},
+		{"utf-8", []byte(strings.Repeat("héllo wörld ✓ 日本語\n", 20)), false},
+		{"binary", binary, true},
+		{"nul padding", make([]byte, 256), true},
+		{"short chunk", []byte{0, 1, 2, 3}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := looksBinary(tt.data); got != tt.want {
+				t.Errorf("looksBinary() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBinaryGuard(t *testing.T) {
+	text := []byte(strings.Repeat("ok\r\n", 32))
+	binary := make([]byte, 256)
+	now := time.Now()
+
+	t.Run("disabled passes everything", func(t *testing.T) {
+		var g binaryGuard
+		if out := g.Filter(binary, false, now); !bytes.Equal(out, binary) {
+			t.Error("disabled guard should not modify output")
+		}
+	})
+
+	t.Run("trips, holds and releases", func(t *testing.T) {
+		g := binaryGuard{enabled: true}
+		if out := g.Filter(text, false, now); !bytes.Equal(out, text) {
+			t.Fatal("text should pass through")
+		}
+		if out := g.Filter(binary, false, now); !bytes.Equal(out, binaryWarning) {
+			t.Fatal("binary should be replaced by the warning")
+		}
+		if out := g.Filter(text, false, now); out != nil {
+			t.Fatal("output should be held until release")
+		}
+		if !g.Release() {
+			t.Fatal("Release should report held output")
+		}
+		if g.Release() {
+			t.Fatal("second Release should be a no-op")
+		}
+		if out := g.Filter(text, false, now); !bytes.Equal(out, text) {
+			t.Fatal("text should pass after release")
+		}
+	})
+
+	t.Run("stands down during zmodem", func(t *testing.T) {
+		g := binaryGuard{enabled: true}
+		if out := g.Filter(binary, true, now); !bytes.Equal(out, binary) {
+			t.Fatal("zmodem start chunk should pass through")
+		}
+		if out := g.Filter(binary, false, now.Add(time.Second)); !bytes.Equal(out, binary) {
+			t.Fatal("transfer data should pass through")
+		}
+		later := now.Add(time.Second + 2*binaryZmodemQuiet)
+		if out := g.Filter(binary, false, later); !bytes.Equal(out, binaryWarning) {
+			t.Fatal("guard should resume after output goes quiet")
+		}
+	})
+}
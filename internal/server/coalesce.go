@@ -0,0 +1,51 @@
+package server
+
+import "time"
+
+// defaultCoalesceWindow is how long output is held before it is sent.
+// Typing echo and prompt redraws reach the PTY as many tiny writes; merging
+// them saves an encrypted frame (and its AEAD and SCTP overhead) per write
+// while staying well under perceptible latency.
+const defaultCoalesceWindow = 5 * time.Millisecond
+
+// coalesceMaxFrame caps merged output, like maxReadSize, so a framed and
+// encrypted send stays under the 64KB data channel message limit
+const coalesceMaxFrame = maxReadSize
+
+// outputCoalescer merges PTY output that arrives within a short window into
+// one send, like Nagle's algorithm. The first chunk starts the window; the
+// owner flushes when it ends. A zero window sends every chunk at once. It
+// runs under the bridge lock.
+type outputCoalescer struct {
+	window  time.Duration
+	pending []byte
+	armed   bool // A window is open and a flush is scheduled
+}
+
+// Add queues output and returns what must be sent now: data itself when
+// coalescing is off, or the pending output when data would push it past
+// coalesceMaxFrame. arm reports that a window opened, so the caller must
+// schedule a Take after c.window.
+func (c *outputCoalescer) Add(data []byte) (ready []byte, arm bool) {
+	if c.window <= 0 {
+		return data, false
+	}
+	if len(c.pending)+len(data) > coalesceMaxFrame {
+		ready = c.pending
+		c.pending = nil
+	}
+	c.pending = append(c.pending, data...)
+	if !c.armed {
+		c.armed = true
+		arm = true
+	}
+	return ready, arm
+}
+
+// Take returns the pending output and closes the window
+func (c *outputCoalescer) Take() []byte {
+	data := c.pending
+	c.pending = nil
+	c.armed = false
+	return data
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestOutputCoalescerMerges(t *testing.T) {
+	c := outputCoalescer{window: time.Millisecond}
+
+	ready, arm := c.Add([]byte("a"))
+	if ready != nil || !arm {
+		t.Fatalf("first Add = %q, %v; want nil, true", ready, arm)
+	}
+	ready, arm = c.Add([]byte("\x1b[K"))
+	if ready != nil || arm {
+		t.Fatalf("second Add = %q, %v; want nil, false", ready, arm)
+	}
+	if got := c.Take(); string(got) != "a\x1b[K" {
+		t.Errorf("Take = %q, want %q", got, "a\x1b[K")
+	}
+
+	// The next chunk opens a new window
+	if _, arm := c.Add([]byte("b")); !arm {
+		t.Error("Add after Take should open a new window")
+	}
+}
+
+func TestOutputCoalescerMaxFrame(t *testing.T) {
+	c := outputCoalescer{window: time.Millisecond}
+
+	first := bytes.Repeat([]byte("x"), coalesceMaxFrame-10)
+	c.Add(first)
+	ready, arm := c.Add(bytes.Repeat([]byte("y"), 20))
+	if !bytes.Equal(ready, first) {
+		t.Errorf("Add past the cap returned %d bytes, want the %d pending", len(ready), len(first))
+	}
+	if arm {
+		t.Error("window is still open, Add should not re-arm it")
+	}
+	if got := c.Take(); len(got) != 20 {
+		t.Errorf("Take = %d bytes, want 20", len(got))
+	}
+}
+
+func TestOutputCoalescerDisabled(t *testing.T) {
+	var c outputCoalescer
+
+	ready, arm := c.Add([]byte("a"))
+	if string(ready) != "a" || arm {
+		t.Errorf("Add = %q, %v; want %q, false", ready, arm, "a")
+	}
+	if got := c.Take(); got != nil {
+		t.Errorf("Take = %q, want nothing pending", got)
+	}
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	ttwebrtc "github.com/artpar/terminal-tunnel/internal/webrtc"
+)
+
+// maxDiagnosticSDPs bounds how many offers and answers a bundle keeps
+const maxDiagnosticSDPs = 10
+
+// icePwdPattern matches the ICE password in an SDP, which is redacted
+var icePwdPattern = regexp.MustCompile(`(?m)^(a=ice-pwd:)[^\r\n]*`)
+
+// Diagnostics records what happened while connecting, so a failed session
+// can be written out as a bundle for a bug report (tt start --debug-bundle).
+// A nil *Diagnostics records nothing.
+type Diagnostics struct {
+	mu      sync.Mutex
+	started time.Time
+	bundle  DiagnosticsBundle
+}
+
+// DiagnosticsBundle is the JSON written by Diagnostics.WriteFile. SDPs have
+// their ICE passwords redacted and TURN credentials are left out; the
+// session password never appears in signaling.
+type DiagnosticsBundle struct {
+	Created      time.Time           `json:"created"`
+	RelayURL     string              `json:"relay_url,omitempty"`
+	Signaling    string              `json:"signaling,omitempty"`
+	UseTURN      bool                `json:"use_turn"`
+	ICEServers   []string            `json:"ice_servers"`
+	Offers       []string            `json:"offers"`
+	Answers      []string            `json:"answers"`
+	LocalCands   map[string]int      `json:"local_candidate_types"`
+	RemoteCands  map[string]int      `json:"remote_candidate_types"`
+	SelectedPair string              `json:"selected_candidate_pair,omitempty"`
+	States       []DiagnosticsEvent  `json:"state_history"`
+	Timings      map[string]Duration `json:"timings"`
+	Error        string              `json:"error,omitempty"`
+}
+
+// DiagnosticsEvent is a connection state change
+type DiagnosticsEvent struct {
+	At    Duration `json:"at"`    // Since the server started
+	Layer string   `json:"layer"` // "peer" or "ice"
+	State string   `json:"state"`
+}
+
+// Duration marshals as a human-readable string such as "1.25s"
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).Round(time.Millisecond).String())
+}
+
+func newDiagnostics() *Diagnostics {
+	now := time.Now()
+	return &Diagnostics{
+		started: now,
+		bundle: DiagnosticsBundle{
+			Created: now,
+			Timings: make(map[string]Duration),
+		},
+	}
+}
+
+// since returns the time since recording started; the caller holds mu
+func (d *Diagnostics) since() Duration {
+	return Duration(time.Since(d.started))
+}
+
+// recordSetup notes how the server signals and which ICE servers it uses.
+// Only server URLs are kept, never TURN credentials.
+func (d *Diagnostics) recordSetup(method, relayURL string, config ttwebrtc.Config) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.bundle.Signaling = method
+	d.bundle.RelayURL = relayURL
+	d.bundle.UseTURN = config.UseTURN
+	d.bundle.ICEServers = ttwebrtc.ICEServerURLs(config)
+}
+
+// markLocked notes when a milestone was first reached; the caller holds mu
+func (d *Diagnostics) markLocked(name string) {
+	if _, ok := d.bundle.Timings[name]; !ok {
+		d.bundle.Timings[name] = d.since()
+	}
+}
+
+func (d *Diagnostics) recordOffer(sdp string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.bundle.Offers = appendSDP(d.bundle.Offers, sdp)
+	d.markLocked("first_offer")
+}
+
+func (d *Diagnostics) recordAnswer(sdp string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.bundle.Answers = appendSDP(d.bundle.Answers, sdp)
+	d.markLocked("first_answer")
+}
+
+func (d *Diagnostics) recordState(layer, state string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.bundle.States = append(d.bundle.States, DiagnosticsEvent{At: d.since(), Layer: layer, State: state})
+}
+
+// recordConnected notes an open data channel and the ICE path it uses
+func (d *Diagnostics) recordConnected(pair string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.markLocked("first_connected")
+	if pair != "" {
+		d.bundle.SelectedPair = pair
+	}
+}
+
+// RecordError notes the error the session ended with
+func (d *Diagnostics) RecordError(err error) {
+	if d == nil || err == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.bundle.Error = err.Error()
+}
+
+// Bundle returns a snapshot of what has been recorded
+func (d *Diagnostics) Bundle() DiagnosticsBundle {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	b := d.bundle
+	b.Offers = append([]string(nil), b.Offers...)
+	b.Answers = append([]string(nil), b.Answers...)
+	b.States = append([]DiagnosticsEvent(nil), b.States...)
+	b.Timings = make(map[string]Duration, len(d.bundle.Timings))
+	for k, v := range d.bundle.Timings {
+		b.Timings[k] = v
+	}
+	b.LocalCands = countCandidateTypes(b.Offers)
+	b.RemoteCands = countCandidateTypes(b.Answers)
+	return b
+}
+
+// WriteFile writes the bundle as indented JSON, readable only by the user
+func (d *Diagnostics) WriteFile(path string) error {
+	data, err := json.MarshalIndent(d.Bundle(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write debug bundle: %w", err)
+	}
+	return nil
+}
+
+// appendSDP adds a redacted SDP, keeping only the most recent ones
+func appendSDP(sdps []string, sdp string) []string {
+	sdps = append(sdps, redactSDP(sdp))
+	if len(sdps) > maxDiagnosticSDPs {
+		sdps = sdps[len(sdps)-maxDiagnosticSDPs:]
+	}
+	return sdps
+}
+
+// redactSDP hides the ICE password, which would let anyone holding the
+// bundle answer connectivity checks
+func redactSDP(sdp string) string {
+	return icePwdPattern.ReplaceAllString(sdp, "${1}<redacted>")
+}
+
+// countCandidateTypes tallies the ICE candidate types (host, srflx, prflx,
+// relay) and protocols offered in the SDPs, e.g. "host/udp": 2
+func countCandidateTypes(sdps []string) map[string]int {
+	counts := make(map[string]int)
+	for _, sdp := range sdps {
+		for _, line := range strings.Split(sdp, "\n") {
+			line = strings.TrimSpace(line)
+			if !strings.HasPrefix(line, "a=candidate:") {
+				continue
+			}
+			// a=candidate:<foundation> <component> <protocol> <priority> <ip> <port> typ <type> ...
+			fields := strings.Fields(line)
+			if len(fields) < 8 || fields[6] != "typ" {
+				continue
+			}
+			counts[fields[7]+"/"+strings.ToLower(fields[2])]++
+		}
+	}
+	return counts
+}
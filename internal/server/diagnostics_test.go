@@ -0,0 +1,70 @@
+package server
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const diagTestOffer = "v=0\r\n" +
+	"a=ice-ufrag:abcd\r\n" +
+	"a=ice-pwd:s3cretIcePassword\r\n" +
+	"a=candidate:1 1 udp 2130706431 192.168.1.5 51234 typ host\r\n" +
+	"a=candidate:2 1 UDP 1694498815 203.0.113.7 40000 typ srflx raddr 0.0.0.0 rport 51234\r\n" +
+	"a=candidate:3 1 udp 2130706431 10.0.0.2 51235 typ host\r\n"
+
+func TestDiagnosticsRedactsICEPassword(t *testing.T) {
+	d := newDiagnostics()
+	d.recordOffer(diagTestOffer)
+
+	offer := d.Bundle().Offers[0]
+	if strings.Contains(offer, "s3cretIcePassword") {
+		t.Errorf("ICE password not redacted:\n%s", offer)
+	}
+	if !strings.Contains(offer, "a=ice-pwd:<redacted>\r\n") {
+		t.Errorf("redacted line missing or mangled:\n%q", offer)
+	}
+	if !strings.Contains(offer, "a=ice-ufrag:abcd") {
+		t.Error("other SDP lines should be kept")
+	}
+}
+
+func TestDiagnosticsCandidateTypes(t *testing.T) {
+	d := newDiagnostics()
+	d.recordOffer(diagTestOffer)
+
+	got := d.Bundle().LocalCands
+	if got["host/udp"] != 2 || got["srflx/udp"] != 1 || len(got) != 2 {
+		t.Errorf("local candidate types = %v, want host/udp:2 srflx/udp:1", got)
+	}
+}
+
+func TestDiagnosticsWriteFile(t *testing.T) {
+	d := newDiagnostics()
+	d.recordOffer(diagTestOffer)
+	d.recordState("ice", "failed")
+	d.RecordError(errors.New("no client connected"))
+
+	path := filepath.Join(t.TempDir(), "bundle.json")
+	if err := d.WriteFile(path); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"state": "failed"`, `"error": "no client connected"`, `"first_offer"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("bundle missing %s:\n%s", want, data)
+		}
+	}
+}
+
+func TestDiagnosticsNilIsNoop(t *testing.T) {
+	var d *Diagnostics
+	d.recordOffer(diagTestOffer)
+	d.recordState("peer", "connected")
+	d.RecordError(errors.New("ignored"))
+}
@@ -0,0 +1,85 @@
+package server
+
+import "unicode/utf8"
+
+// Output encodings reported by Bridge.OutputEncoding
+const (
+	EncodingUTF8   = "utf-8"        // Text, valid UTF-8 (plain ASCII included)
+	EncodingLegacy = "legacy-8bit"  // Text, but its non-ASCII bytes aren't UTF-8, e.g. Latin-1
+	EncodingBinary = "binary-heavy" // Much of the output is binary
+)
+
+// Encoding detection samples output rather than scanning all of it: the
+// first bytes of one PTY read in every encodingSampleEvery
+const (
+	encodingSampleEvery = 8
+	encodingSampleSize  = 512
+
+	// Share of sampled reads that must look binary for the output to count
+	// as binary-heavy, so one stray cat of a binary file doesn't
+	encodingBinaryShare = 0.1
+)
+
+// encodingDetector classifies a session's output from samples of it. It
+// runs under the bridge lock.
+type encodingDetector struct {
+	reads   int64 // PTY reads seen, sampled or not
+	samples int64 // Reads sampled
+	binary  int64 // Sampled reads that looked binary
+	high    int64 // Sampled bytes >= 0x80
+	invalid int64 // Sampled bytes >= 0x80 that weren't part of valid UTF-8
+}
+
+// Scan samples a chunk of output, if its turn
+func (d *encodingDetector) Scan(data []byte) {
+	d.reads++
+	if (d.reads-1)%encodingSampleEvery != 0 || len(data) == 0 {
+		return
+	}
+	if len(data) > encodingSampleSize {
+		data = data[:encodingSampleSize]
+	}
+	// A read can end mid-rune; that isn't invalid UTF-8
+	for cut := len(data) - 1; cut >= 0 && cut >= len(data)-utf8.UTFMax; cut-- {
+		if utf8.RuneStart(data[cut]) {
+			if !utf8.FullRune(data[cut:]) {
+				data = data[:cut]
+			}
+			break
+		}
+	}
+
+	d.samples++
+	if looksBinary(data) {
+		d.binary++
+	}
+	for i := 0; i < len(data); {
+		if data[i] < 0x80 {
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size <= 1 {
+			d.high++
+			d.invalid++
+			i++
+			continue
+		}
+		d.high += int64(size)
+		i += size
+	}
+}
+
+// Encoding returns how the output sampled so far looks, or "" before any
+func (d *encodingDetector) Encoding() string {
+	switch {
+	case d.samples == 0:
+		return ""
+	case float64(d.binary) > float64(d.samples)*encodingBinaryShare:
+		return EncodingBinary
+	case d.invalid*2 > d.high:
+		// Most non-ASCII output isn't UTF-8, rather than the odd bad byte
+		return EncodingLegacy
+	}
+	return EncodingUTF8
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncodingDetector(t *testing.T) {
+	binary := make([]byte, 512)
+	for i := range binary {
+		binary[i] = byte(i)
+	}
+	utf8Text := []byte(strings.Repeat("héllo wörld ✓ 日本語\r\n", 40))
+	latin1 := []byte(strings.Repeat("h\xe9llo w\xf6rld\r\n", 40))
+
+	tests := []struct {
+		name   string
+		chunks [][]byte
+		want   string
+	}{
+		{"nothing yet", nil, ""},
+		{"ascii", [][]byte{[]byte("ls -la\r\n")}, EncodingUTF8},
+		{"utf-8", [][]byte{utf8Text}, EncodingUTF8},
+		{"latin-1", [][]byte{latin1}, EncodingLegacy},
+		{"binary", [][]byte{binary}, EncodingBinary},
+		// The rune split across reads isn't a bad byte
+		{"split rune", [][]byte{[]byte("ok ✓"[:5])}, EncodingUTF8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d encodingDetector
+			for _, chunk := range tt.chunks {
+				d.Scan(chunk)
+			}
+			if got := d.Encoding(); got != tt.want {
+				t.Errorf("Encoding() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodingDetectorSamples(t *testing.T) {
+	binary := make([]byte, 512)
+	for i := range binary {
+		binary[i] = byte(i)
+	}
+
+	var d encodingDetector
+	d.Scan([]byte("text\r\n"))
+	// Reads between samples aren't looked at
+	for i := 1; i < encodingSampleEvery; i++ {
+		d.Scan(binary)
+	}
+	if got := d.Encoding(); got != EncodingUTF8 {
+		t.Errorf("Encoding() = %q after unsampled binary, want %q", got, EncodingUTF8)
+	}
+	d.Scan(binary)
+	if got := d.Encoding(); got != EncodingBinary {
+		t.Errorf("Encoding() = %q after sampled binary, want %q", got, EncodingBinary)
+	}
+}
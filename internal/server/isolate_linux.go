@@ -0,0 +1,27 @@
+package server
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// mountProcScript remounts /proc inside the new PID namespace so ps and
+// friends only see the session's processes, then execs the real command.
+// Mounting fails harmlessly where the mount binary is missing.
+const mountProcScript = `mount -t proc proc /proc 2>/dev/null; exec "$0" "$@"`
+
+// isolatedCommand returns a command running args in new user, mount, PID,
+// UTS and IPC namespaces. The caller's user becomes root inside, without
+// privileges outside: the session cannot see or signal host processes,
+// but it shares the host's filesystem and network.
+func isolatedCommand(args []string) (*exec.Cmd, error) {
+	cmd := exec.Command("/bin/sh", append([]string{"-c", mountProcScript}, args...)...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID |
+			syscall.CLONE_NEWUTS | syscall.CLONE_NEWIPC,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+	}
+	return cmd, nil
+}
@@ -0,0 +1,29 @@
+package server
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStartIsolated(t *testing.T) {
+	// The command is PID 1 of its own namespace and root inside it
+	pty, err := StartIsolated("", []string{"/bin/sh", "-c", "echo pid=$$ uid=$(id -u)"})
+	if err != nil {
+		t.Skipf("user namespaces unavailable: %v", err)
+	}
+	defer pty.Close()
+
+	var output bytes.Buffer
+	buf := make([]byte, 1024)
+	for {
+		n, err := pty.Read(buf)
+		output.Write(buf[:n])
+		if err != nil {
+			break
+		}
+	}
+	if got := strings.TrimSpace(output.String()); got != "pid=1 uid=0" {
+		t.Errorf("output = %q, want %q", got, "pid=1 uid=0")
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package server
+
+import (
+	"os/exec"
+)
+
+// isolatedCommand is only implemented on Linux
+func isolatedCommand(args []string) (*exec.Cmd, error) {
+	return nil, ErrIsolationUnsupported
+}
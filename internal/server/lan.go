@@ -0,0 +1,337 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/artpar/terminal-tunnel/internal/signaling"
+	"github.com/artpar/terminal-tunnel/internal/web"
+)
+
+// lanCodeAlphabet matches the relay's short codes, for the code made up
+// when the relay cannot be reached
+const (
+	lanCodeAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+	lanCodeLength   = 8
+)
+
+// lanSignaler serves the relay's session API (GET /session/{code} and
+// POST /session/{code}/answer) for this session on the local network, next
+// to the relay. A client on the same network signals directly with the host,
+// so the relay adds no latency and need not be reachable. It also serves the
+// web client, so the LAN URL works with no internet access at all.
+//
+// If the relay cannot be reached, the session continues on the LAN alone
+// under a locally generated code.
+type lanSignaler struct {
+	listener net.Listener
+	server   *http.Server
+
+	mu       sync.Mutex
+	relay    Signaler // nil once the relay has failed
+	code     string
+	offer    string
+	salt     string
+	answer   string
+	answered chan struct{} // Closed when the current offer is answered
+}
+
+var _ Signaler = (*lanSignaler)(nil)
+
+// newLANSignaler listens on ip and starts serving. relay may be nil for a
+// LAN-only session.
+func newLANSignaler(relay Signaler, ip string) (*lanSignaler, error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort(ip, "0"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", ip, err)
+	}
+
+	l := &lanSignaler{
+		relay:    relay,
+		listener: listener,
+		answered: make(chan struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/session/", l.handleSession)
+	mux.Handle("/", web.Handler())
+
+	l.server = &http.Server{
+		Handler:      mux,
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+	go l.server.Serve(listener)
+	return l, nil
+}
+
+// Addr returns the host:port clients reach the LAN signaling on
+func (l *lanSignaler) Addr() string {
+	return l.listener.Addr().String()
+}
+
+// LANURL returns a web client link served by the host that signals over the
+// LAN only
+func (l *lanSignaler) LANURL() string {
+	base := "http://" + l.Addr()
+	return fmt.Sprintf("%s/?c=%s&relay=%s", base, l.GetCode(), url.QueryEscape(base))
+}
+
+// Close stops serving
+func (l *lanSignaler) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return l.server.Shutdown(ctx)
+}
+
+// CreateSession registers the offer with the relay and serves it on the LAN
+// under the same code. If the relay fails, it is dropped and the session
+// gets a local code; a requested code the relay says is taken is an error.
+func (l *lanSignaler) CreateSession(sdp, salt string) (string, error) {
+	relay := l.relayClient()
+	var code string
+	if relay != nil {
+		var err error
+		code, err = relay.CreateSession(sdp, salt)
+		if errors.Is(err, signaling.ErrCodeTaken) {
+			return "", err
+		}
+		if err != nil {
+			fmt.Printf("⚠ Relay unavailable (%v), signaling on the local network only\n", err)
+			relay = nil
+		}
+	}
+	if relay == nil {
+		code = generateLANCode()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.relay = relay
+	l.code = code
+	l.setOffer(sdp, salt)
+	return code, nil
+}
+
+// CreateSessionWithViewer needs the relay: viewers only connect through it
+func (l *lanSignaler) CreateSessionWithViewer(sdp, salt, viewerSDP, viewerKey string) (string, string, error) {
+	relay := l.relayClient()
+	if relay == nil {
+		return "", "", fmt.Errorf("viewer sessions need the relay")
+	}
+	code, viewerCode, err := relay.CreateSessionWithViewer(sdp, salt, viewerSDP, viewerKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.code = code
+	l.setOffer(sdp, salt)
+	return code, viewerCode, nil
+}
+
+// UpdateSession replaces the offer on the LAN and the relay
+func (l *lanSignaler) UpdateSession(sdp, salt string) error {
+	l.mu.Lock()
+	l.setOffer(sdp, salt)
+	relay := l.relay
+	l.mu.Unlock()
+
+	if relay == nil {
+		return nil
+	}
+	return relay.UpdateSession(sdp, salt)
+}
+
+// setOffer replaces the offer and clears its answer; the caller holds mu
+func (l *lanSignaler) setOffer(sdp, salt string) {
+	l.offer = sdp
+	l.salt = salt
+	if l.answer != "" {
+		l.answer = ""
+		l.answered = make(chan struct{})
+	}
+}
+
+// WaitForAnswerWithContext returns the first answer from either the LAN or
+// the relay
+func (l *lanSignaler) WaitForAnswerWithContext(ctx context.Context) (string, error) {
+	l.mu.Lock()
+	answer, answered, relay := l.answer, l.answered, l.relay
+	l.mu.Unlock()
+	if answer != "" {
+		return answer, nil
+	}
+
+	var relayResult chan relayAnswer
+	if relay != nil {
+		relayCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		relayResult = make(chan relayAnswer, 1)
+		go func() {
+			answer, err := relay.WaitForAnswerWithContext(relayCtx)
+			relayResult <- relayAnswer{answer, err}
+		}()
+	}
+
+	select {
+	case <-answered:
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return l.answer, nil
+	case res := <-relayResult:
+		return res.answer, res.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// relayAnswer carries the relay's WaitForAnswerWithContext result
+type relayAnswer struct {
+	answer string
+	err    error
+}
+
+// WaitForViewerAnswerWithContext waits on the relay, which viewers use
+func (l *lanSignaler) WaitForViewerAnswerWithContext(ctx context.Context) (string, error) {
+	relay := l.relayClient()
+	if relay == nil {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	return relay.WaitForViewerAnswerWithContext(ctx)
+}
+
+// UpdateViewerSession replaces the viewer offer on the relay, where viewers
+// connect
+func (l *lanSignaler) UpdateViewerSession(viewerSDP string) error {
+	updater, ok := l.relayClient().(viewerOfferUpdater)
+	if !ok {
+		return fmt.Errorf("viewer sessions need the relay")
+	}
+	return updater.UpdateViewerSession(viewerSDP)
+}
+
+// SendHeartbeat keeps the relay session alive. LAN sessions don't expire.
+func (l *lanSignaler) SendHeartbeat() error {
+	relay := l.relayClient()
+	if relay == nil {
+		return nil
+	}
+	return relay.SendHeartbeat()
+}
+
+// DeleteSession stops serving the code on the LAN and deletes it from the relay
+func (l *lanSignaler) DeleteSession() error {
+	l.mu.Lock()
+	l.code = ""
+	relay := l.relay
+	l.mu.Unlock()
+
+	if relay == nil {
+		return nil
+	}
+	return relay.DeleteSession()
+}
+
+// relayClient returns the relay, or nil for a LAN-only session
+func (l *lanSignaler) relayClient() Signaler {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.relay
+}
+
+func (l *lanSignaler) GetCode() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.code
+}
+
+// GetClientURL returns the relay link with a LAN hint the web client tries
+// first, or the LAN link when there is no relay
+func (l *lanSignaler) GetClientURL() string {
+	relay := l.relayClient()
+	if relay == nil {
+		return l.LANURL()
+	}
+	return relay.GetClientURL() + "&lan=" + url.QueryEscape(l.Addr())
+}
+
+func (l *lanSignaler) GetViewerURL() string {
+	relay := l.relayClient()
+	if relay == nil {
+		return ""
+	}
+	return relay.GetViewerURL()
+}
+
+// handleSession serves GET /session/{code} and POST /session/{code}/answer
+// like the relay, for the current code only
+func (l *lanSignaler) handleSession(w http.ResponseWriter, r *http.Request) {
+	// The web client may be loaded from the relay's origin
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/session/")
+	isAnswer := strings.HasSuffix(path, "/answer")
+	code := strings.ToUpper(strings.TrimSuffix(path, "/answer"))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.code == "" || code != l.code {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && !isAnswer:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"sdp":  l.offer,
+			"salt": l.salt,
+		})
+	case r.Method == http.MethodPost && isAnswer:
+		var req struct {
+			SDP string `json:"sdp"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil || req.SDP == "" {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if l.answer == "" {
+			close(l.answered)
+		}
+		l.answer = req.SDP
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// generateLANCode creates a random code for a session without the relay
+func generateLANCode() string {
+	code := make([]byte, lanCodeLength)
+	alphabetLen := big.NewInt(int64(len(lanCodeAlphabet)))
+	for i := range code {
+		n, _ := rand.Int(rand.Reader, alphabetLen)
+		code[i] = lanCodeAlphabet[n.Int64()]
+	}
+	return string(code)
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lanGet fetches the session from the LAN signaler as the web client does
+func lanGet(t *testing.T, l *lanSignaler, code string) (int, map[string]string) {
+	t.Helper()
+	resp, err := http.Get("http://" + l.Addr() + "/session/" + code)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var body map[string]string
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body
+}
+
+// lanPostAnswer submits an answer to the LAN signaler
+func lanPostAnswer(t *testing.T, l *lanSignaler, code, answer string) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"sdp": answer})
+	resp, err := http.Post("http://"+l.Addr()+"/session/"+code+"/answer", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST answer status = %d", resp.StatusCode)
+	}
+}
+
+func TestLANSignaler(t *testing.T) {
+	relay := newMemSignaler()
+	l, err := newLANSignaler(relay, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("newLANSignaler failed: %v", err)
+	}
+	defer l.Close()
+
+	code, err := l.CreateSession("offer-1", "salt")
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if code != relay.GetCode() {
+		t.Errorf("code = %q, want the relay's %q", code, relay.GetCode())
+	}
+	if url := l.GetClientURL(); !strings.Contains(url, "&lan=127.0.0.1") {
+		t.Errorf("client URL %q has no LAN hint", url)
+	}
+
+	status, body := lanGet(t, l, strings.ToLower(code))
+	if status != http.StatusOK || body["sdp"] != "offer-1" || body["salt"] != "salt" {
+		t.Fatalf("GET = %d %v, want the offer", status, body)
+	}
+	if status, _ := lanGet(t, l, "NOTACODE"); status != http.StatusNotFound {
+		t.Errorf("GET unknown code = %d, want 404", status)
+	}
+
+	// An answer over the LAN
+	lanPostAnswer(t, l, code, "answer-lan")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if answer, err := l.WaitForAnswerWithContext(ctx); err != nil || answer != "answer-lan" {
+		t.Fatalf("WaitForAnswer = %q, %v; want answer-lan", answer, err)
+	}
+
+	// A new offer clears it, and an answer through the relay also counts
+	if err := l.UpdateSession("offer-2", "salt"); err != nil {
+		t.Fatalf("UpdateSession failed: %v", err)
+	}
+	if _, body := lanGet(t, l, code); body["sdp"] != "offer-2" {
+		t.Errorf("GET after update = %v, want offer-2", body)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		relay.postAnswer("answer-relay")
+	}()
+	if answer, err := l.WaitForAnswerWithContext(ctx); err != nil || answer != "answer-relay" {
+		t.Fatalf("WaitForAnswer = %q, %v; want answer-relay", answer, err)
+	}
+
+	if err := l.DeleteSession(); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	if status, _ := lanGet(t, l, code); status != http.StatusNotFound {
+		t.Errorf("GET after delete = %d, want 404", status)
+	}
+}
+
+// downSignaler is a relay that cannot be reached
+type downSignaler struct {
+	*memSignaler
+}
+
+func (downSignaler) CreateSession(sdp, salt string) (string, error) {
+	return "", errors.New("connection refused")
+}
+
+func TestLANSignalerWithoutRelay(t *testing.T) {
+	l, err := newLANSignaler(downSignaler{newMemSignaler()}, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("newLANSignaler failed: %v", err)
+	}
+	defer l.Close()
+
+	code, err := l.CreateSession("offer", "salt")
+	if err != nil {
+		t.Fatalf("CreateSession should fall back to the LAN, got %v", err)
+	}
+	if len(code) != lanCodeLength {
+		t.Errorf("local code %q, want %d characters", code, lanCodeLength)
+	}
+	if url := l.GetClientURL(); url != l.LANURL() {
+		t.Errorf("client URL = %q, want the LAN URL %q", url, l.LANURL())
+	}
+	if err := l.SendHeartbeat(); err != nil {
+		t.Errorf("SendHeartbeat without relay = %v", err)
+	}
+
+	lanPostAnswer(t, l, code, "answer")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if answer, err := l.WaitForAnswerWithContext(ctx); err != nil || answer != "answer" {
+		t.Errorf("WaitForAnswer = %q, %v; want answer", answer, err)
+	}
+}
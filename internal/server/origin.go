@@ -0,0 +1,110 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"time"
+
+	ttwebrtc "github.com/artpar/terminal-tunnel/internal/webrtc"
+)
+
+// ClientOrigin describes where the connected client's traffic comes from,
+// taken from the remote side of the selected ICE candidate pair
+type ClientOrigin struct {
+	Address        string // Remote candidate address
+	CandidateType  string // "host", "srflx", "prflx" or "relay"
+	Location       string // From Options.GeoIP, e.g. "Comcast, Seattle"; "" if unknown
+	ConnectionType string // "p2p" or "relay" (TURN on either side)
+}
+
+// Relayed reports whether Address is the client's TURN server rather than
+// the client, so it says nothing about where the client is
+func (o ClientOrigin) Relayed() bool {
+	return o.CandidateType == "relay"
+}
+
+// String describes the origin for the host, e.g. "Comcast, Seattle (P2P)",
+// or "203.0.113.7 (srflx, P2P)" without a location
+func (o ClientOrigin) String() string {
+	path := "P2P"
+	if o.ConnectionType == "relay" {
+		path = "TURN relay"
+	}
+	if o.Relayed() {
+		where := o.Address
+		if o.Location != "" {
+			where += ", " + o.Location
+		}
+		return fmt.Sprintf("a TURN server at %s (%s; the client's own address is hidden)", where, path)
+	}
+	if o.Location != "" {
+		return fmt.Sprintf("%s (%s)", o.Location, path)
+	}
+	return fmt.Sprintf("%s (%s, %s)", o.Address, o.CandidateType, path)
+}
+
+// ClientOrigin returns where the current client connects from. ok is false
+// when no client is connected.
+func (s *Server) ClientOrigin() (origin ClientOrigin, ok bool) {
+	peer := s.currentPeer()
+	if peer == nil {
+		return ClientOrigin{}, false
+	}
+	return s.clientOrigin(peer)
+}
+
+// clientOrigin describes peer's selected candidate pair
+func (s *Server) clientOrigin(peer *ttwebrtc.Peer) (ClientOrigin, bool) {
+	address, candidateType := peer.RemoteCandidate()
+	if address == "" {
+		return ClientOrigin{}, false
+	}
+	origin := ClientOrigin{
+		Address:        address,
+		CandidateType:  candidateType,
+		ConnectionType: peer.ConnectionType(),
+	}
+	if ip := net.ParseIP(address); ip != nil {
+		origin.Location = s.geoip.Lookup(ip).String()
+	}
+	return origin, true
+}
+
+// clientConnected notes when the client connected and reports where it
+// comes from
+func (s *Server) clientConnected(peer *ttwebrtc.Peer) {
+	s.clientSince.Store(time.Now().UnixNano())
+	if origin, ok := s.clientOrigin(peer); ok {
+		s.log("✓ Client connected from %s\n", origin)
+	}
+}
+
+// Connection is the client or a public viewer connected to the session
+type Connection struct {
+	Viewer      int // Viewer number (GrantViewerWrite); 0 for the client
+	ConnectedAt time.Time
+	Origin      ClientOrigin // Zero until a candidate pair is selected
+}
+
+// Connections returns who is connected to the session now: the client
+// first, then the viewers in the order they joined. A relayed connection's
+// address is its TURN server's, as with ClientOrigin.
+func (s *Server) Connections() []Connection {
+	var conns []Connection
+	if since := s.clientSince.Load(); since != 0 {
+		if peer := s.currentPeer(); peer != nil {
+			origin, _ := s.clientOrigin(peer)
+			conns = append(conns, Connection{ConnectedAt: time.Unix(0, since), Origin: origin})
+		}
+	}
+
+	s.viewerMu.Lock()
+	viewers := slices.Clone(s.viewerList)
+	s.viewerMu.Unlock()
+	for _, v := range viewers {
+		origin, _ := s.clientOrigin(v.peer)
+		conns = append(conns, Connection{Viewer: v.id, ConnectedAt: v.joined, Origin: origin})
+	}
+	return conns
+}
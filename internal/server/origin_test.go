@@ -0,0 +1,40 @@
+package server
+
+import "testing"
+
+func TestClientOriginString(t *testing.T) {
+	tests := []struct {
+		name   string
+		origin ClientOrigin
+		want   string
+	}{
+		{
+			name:   "located",
+			origin: ClientOrigin{Address: "203.0.113.7", CandidateType: "srflx", Location: "Comcast, Seattle", ConnectionType: "p2p"},
+			want:   "Comcast, Seattle (P2P)",
+		},
+		{
+			name:   "no GeoIP database",
+			origin: ClientOrigin{Address: "192.168.1.20", CandidateType: "host", ConnectionType: "p2p"},
+			want:   "192.168.1.20 (host, P2P)",
+		},
+		{
+			name:   "host's TURN relay still sees the client",
+			origin: ClientOrigin{Address: "203.0.113.7", CandidateType: "srflx", Location: "Comcast, Seattle", ConnectionType: "relay"},
+			want:   "Comcast, Seattle (TURN relay)",
+		},
+		{
+			name:   "client behind its TURN server",
+			origin: ClientOrigin{Address: "198.51.100.9", CandidateType: "relay", Location: "Cloudflare, Frankfurt", ConnectionType: "relay"},
+			want:   "a TURN server at 198.51.100.9, Cloudflare, Frankfurt (TURN relay; the client's own address is hidden)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.origin.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
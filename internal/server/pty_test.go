@@ -2,9 +2,13 @@ package server
 
 import (
 	"bytes"
+	"errors"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/artpar/terminal-tunnel/internal/protocol"
 )
 
 func TestStartPTY(t *testing.T) {
@@ -30,6 +34,67 @@ func TestStartPTYDefaultShell(t *testing.T) {
 	defer pty.Close()
 }
 
+func TestDefaultShell(t *testing.T) {
+	t.Setenv("SHELL", "/bin/sh")
+	if got := DefaultShell(); got != "/bin/sh" {
+		t.Errorf("DefaultShell() = %q, want $SHELL", got)
+	}
+
+	// A stale $SHELL falls back to the login shell or /bin/sh
+	t.Setenv("SHELL", "/nonexistent/shell")
+	if got := DefaultShell(); got == "/nonexistent/shell" || got == "" {
+		t.Errorf("DefaultShell() = %q, want a usable shell", got)
+	}
+}
+
+func TestStartCommand(t *testing.T) {
+	// Arguments reach the program as-is: no shell expands $HOME or splits words
+	pty, err := StartCommand([]string{"echo", "$HOME", "a  b"})
+	if err != nil {
+		t.Fatalf("StartCommand failed: %v", err)
+	}
+	defer pty.Close()
+
+	var output bytes.Buffer
+	buf := make([]byte, 1024)
+	for {
+		n, err := pty.Read(buf)
+		output.Write(buf[:n])
+		if err != nil {
+			break
+		}
+	}
+	if got := strings.TrimSpace(output.String()); got != "$HOME a  b" {
+		t.Errorf("output = %q, want %q", got, "$HOME a  b")
+	}
+
+	if _, err := StartCommand(nil); err == nil {
+		t.Error("StartCommand with no args should fail")
+	}
+}
+
+func TestStartCommandSize(t *testing.T) {
+	// The program sees the size as soon as it starts
+	pty, err := StartCommandSize([]string{"stty", "size"}, 40, 120)
+	if err != nil {
+		t.Fatalf("StartCommandSize failed: %v", err)
+	}
+	defer pty.Close()
+
+	var output bytes.Buffer
+	buf := make([]byte, 1024)
+	for {
+		n, err := pty.Read(buf)
+		output.Write(buf[:n])
+		if err != nil {
+			break
+		}
+	}
+	if got := strings.TrimSpace(output.String()); got != "40 120" {
+		t.Errorf("stty size = %q, want %q", got, "40 120")
+	}
+}
+
 func TestPTYReadWrite(t *testing.T) {
 	pty, err := StartPTY("/bin/sh")
 	if err != nil {
@@ -153,6 +218,9 @@ func TestBridge(t *testing.T) {
 		case data := <-received:
 			output.Write(data)
 			if strings.Contains(output.String(), "test") {
+				if in, _ := bridge.Traffic(); in != int64(len("echo test\n")) {
+					t.Errorf("Traffic() in = %d, want %d", in, len("echo test\n"))
+				}
 				return // Success
 			}
 		case <-timeout:
@@ -179,6 +247,45 @@ func TestBridgeResize(t *testing.T) {
 	}
 }
 
+func TestBridgeSyncSize(t *testing.T) {
+	pty, err := StartPTY("/bin/sh")
+	if err != nil {
+		t.Fatalf("StartPTY failed: %v", err)
+	}
+	defer pty.Close()
+
+	bridge := NewBridge(pty, func(data []byte) error {
+		return nil
+	})
+	defer bridge.Close()
+
+	// Matching size should be a no-op
+	resized, err := bridge.SyncSize(24, 80)
+	if err != nil {
+		t.Fatalf("SyncSize failed: %v", err)
+	}
+	if resized {
+		t.Error("SyncSize should not resize when size already matches")
+	}
+
+	// Drifted size should be corrected
+	resized, err = bridge.SyncSize(40, 120)
+	if err != nil {
+		t.Fatalf("SyncSize failed: %v", err)
+	}
+	if !resized {
+		t.Error("SyncSize should resize when size differs")
+	}
+
+	rows, cols, err := pty.Size()
+	if err != nil {
+		t.Fatalf("Size failed: %v", err)
+	}
+	if rows != 40 || cols != 120 {
+		t.Errorf("size = %dx%d, want 120x40", cols, rows)
+	}
+}
+
 func TestBridgeClose(t *testing.T) {
 	pty, err := StartPTY("/bin/sh")
 	if err != nil {
@@ -202,3 +309,503 @@ func TestBridgeClose(t *testing.T) {
 		t.Errorf("Second Close failed: %v", err)
 	}
 }
+
+func TestBridgeExitHandler(t *testing.T) {
+	// Fires when the program exits...
+	pty, err := StartCommand([]string{"true"})
+	if err != nil {
+		t.Fatalf("StartCommand failed: %v", err)
+	}
+	exited := make(chan struct{})
+	bridge := NewBridge(pty, nil)
+	bridge.SetExitHandler(func() { close(exited) })
+	bridge.Start()
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("exit handler not called when the program exited")
+	}
+
+	// ...but not when the bridge is closed
+	pty, err = StartPTY("/bin/sh")
+	if err != nil {
+		t.Fatalf("StartPTY failed: %v", err)
+	}
+	called := make(chan struct{}, 1)
+	bridge = NewBridge(pty, nil)
+	bridge.SetExitHandler(func() { called <- struct{}{} })
+	bridge.Start()
+	_ = bridge.Close()
+	bridge.WaitForExit(time.Second)
+	select {
+	case <-called:
+		t.Error("exit handler called on Close")
+	default:
+	}
+}
+
+func TestBridgePauseSharing(t *testing.T) {
+	pty, err := StartCommand([]string{"cat"})
+	if err != nil {
+		t.Fatalf("StartCommand failed: %v", err)
+	}
+	defer pty.Close()
+
+	var mu sync.Mutex
+	var output bytes.Buffer
+	received := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return output.String()
+	}
+	waitFor := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !strings.Contains(received(), want) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timeout waiting for %q, got %q", want, received())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	bridge := NewBridge(pty, func(data []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		output.Write(data)
+		return nil
+	})
+	bridge.Start()
+	defer bridge.Close()
+
+	if err := bridge.HandleData([]byte("before\n")); err != nil {
+		t.Fatalf("HandleData failed: %v", err)
+	}
+	waitFor("before")
+
+	if !bridge.PauseSharing() {
+		t.Fatal("PauseSharing returned false")
+	}
+	if bridge.PauseSharing() {
+		t.Error("second PauseSharing should report no change")
+	}
+	waitFor(string(sharingPausedNotice))
+
+	if err := bridge.HandleData([]byte("secret\n")); err != nil {
+		t.Fatalf("HandleData failed: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if strings.Contains(received(), "secret") {
+		t.Fatal("output was sent while sharing was paused")
+	}
+
+	// A client attaching meanwhile gets the history without the held output
+	var late bytes.Buffer
+	lateReplay := make(chan struct{}, 1)
+	bridge.AttachSender(func(data []byte) error {
+		late.Write(data)
+		select {
+		case lateReplay <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	<-lateReplay
+	if got := late.String(); strings.Contains(got, "secret") || !strings.Contains(got, string(sharingPausedNotice)) {
+		t.Errorf("late client got %q, want history and the notice without held output", got)
+	}
+
+	if !bridge.ResumeSharing() {
+		t.Fatal("ResumeSharing returned false")
+	}
+	if bridge.SharingPaused() {
+		t.Error("SharingPaused after ResumeSharing")
+	}
+	if !strings.Contains(late.String(), "secret") {
+		t.Errorf("held output not sent on resume, got %q", late.String())
+	}
+}
+
+func TestBridgeEchoOff(t *testing.T) {
+	// A password prompt turns echo off. Input reaches the shell, but none
+	// of the outputs - client, recording, local terminal - may show it.
+	pty, err := StartCommand([]string{"/bin/sh", "-c", `stty -echo; printf 'Password: '; read pw; printf 'got %d chars' ${#pw}`})
+	if err != nil {
+		t.Fatalf("StartCommand failed: %v", err)
+	}
+	defer pty.Close()
+
+	var mu sync.Mutex
+	var sent, recorded, local bytes.Buffer
+	bridge := NewBridge(pty, func(data []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sent.Write(data)
+		return nil
+	})
+	bridge.AddRecorder(func(data []byte) error {
+		recorded.Write(data) // Called under the bridge lock
+		return nil
+	})
+	bridge.SetLocalOutput(&local)
+	bridge.Start()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		prompted := strings.Contains(sent.String(), "Password:")
+		mu.Unlock()
+		if prompted {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for the prompt")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := bridge.HandleData([]byte("hunter2\n")); err != nil {
+		t.Fatalf("HandleData failed: %v", err)
+	}
+	if !bridge.WaitForExit(10 * time.Second) {
+		t.Fatal("timeout waiting for the script to finish")
+	}
+	bridge.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !strings.Contains(sent.String(), "got 7 chars") {
+		t.Fatalf("shell did not read the input, output %q", sent.String())
+	}
+	for name, out := range map[string]string{"client": sent.String(), "recording": recorded.String(), "local": local.String()} {
+		if strings.Contains(out, "hunter2") {
+			t.Errorf("%s output leaked the input: %q", name, out)
+		}
+	}
+}
+
+func TestBridgeRecorders(t *testing.T) {
+	// A full recording and a redacted copy each get all output, and a
+	// failing recorder affects neither
+	pty, err := StartCommand([]string{"/bin/sh", "-c", "printf 'token=s3cret'"})
+	if err != nil {
+		t.Fatalf("StartCommand failed: %v", err)
+	}
+	defer pty.Close()
+
+	var full, redacted bytes.Buffer // Written under the bridge lock
+	bridge := NewBridge(pty, nil)
+	bridge.AddRecorder(func(data []byte) error {
+		full.Write(data)
+		return nil
+	})
+	bridge.AddRecorder(func(data []byte) error {
+		return errors.New("disk full")
+	})
+	bridge.AddRecorder(func(data []byte) error {
+		redacted.Write(bytes.ReplaceAll(data, []byte("s3cret"), []byte("******")))
+		return nil
+	})
+	bridge.Start()
+
+	if !bridge.WaitForExit(10 * time.Second) {
+		t.Fatal("timeout waiting for the command to finish")
+	}
+	bridge.Close()
+
+	if !strings.Contains(full.String(), "token=s3cret") {
+		t.Errorf("full recording = %q, want the output", full.String())
+	}
+	if got := redacted.String(); !strings.Contains(got, "token=******") || strings.Contains(got, "s3cret") {
+		t.Errorf("redacted recording = %q, want the output redacted", got)
+	}
+}
+
+func TestBridgeRemoveViewerSend(t *testing.T) {
+	// A viewer that left stops getting output; the others carry on
+	pty, err := StartCommand([]string{"cat"})
+	if err != nil {
+		t.Fatalf("StartCommand failed: %v", err)
+	}
+	defer pty.Close()
+
+	bridge := NewBridge(pty, func([]byte) error { return nil })
+	stayed := make(chan []byte, 16)
+	left := make(chan []byte, 16)
+	bridge.AddViewerSend(func(data []byte) error {
+		stayed <- data
+		return nil
+	})
+	remove := bridge.AddViewerSend(func(data []byte) error {
+		left <- data
+		return nil
+	})
+	remove()
+	bridge.Start()
+	defer bridge.Close()
+
+	if err := bridge.HandleData([]byte("hello\n")); err != nil {
+		t.Fatalf("HandleData failed: %v", err)
+	}
+	select {
+	case <-stayed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the remaining viewer got no output")
+	}
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case data := <-left:
+		t.Errorf("removed viewer got %q", data)
+	default:
+	}
+}
+
+func TestBridgeReset(t *testing.T) {
+	// A program that exits with echo off leaves the terminal wedged until
+	// the client asks for a reset
+	pty, err := StartCommand([]string{"/bin/sh", "-c", `stty -echo; echo wedged; read line`})
+	if err != nil {
+		t.Fatalf("StartCommand failed: %v", err)
+	}
+	defer pty.Close()
+
+	var mu sync.Mutex
+	var sent bytes.Buffer
+	bridge := NewBridge(pty, func(data []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sent.Write(data)
+		return nil
+	})
+	bridge.Start()
+	defer bridge.Close()
+
+	waitFor := func(what string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			mu.Lock()
+			found := strings.Contains(sent.String(), what)
+			mu.Unlock()
+			if found {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("timeout waiting for %q", what)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitFor("wedged")
+
+	if err := bridge.Reset(); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	waitFor(string(terminalReset))
+	if !bytes.Contains(bridge.historyBuffer, terminalReset) {
+		t.Error("reset sequence missing from the history")
+	}
+
+	// Echo is back on, so the input shows up
+	if err := bridge.HandleData([]byte("typed\n")); err != nil {
+		t.Fatalf("HandleData failed: %v", err)
+	}
+	waitFor("typed")
+}
+
+func TestBridgeWriteTimeoutXOFF(t *testing.T) {
+	pty, err := StartPTY("/bin/sh")
+	if err != nil {
+		t.Fatalf("StartPTY failed: %v", err)
+	}
+
+	bridge := NewBridge(pty, func(data []byte) error {
+		return nil
+	})
+	bridge.SetWriteTimeout(200 * time.Millisecond)
+	bridge.Start()
+	defer bridge.Close()
+
+	// Leave the shell busy and not reading, with non-canonical input so the
+	// queue fills instead of discarding, then stop output with Ctrl+S (XOFF)
+	if err := bridge.HandleData([]byte("stty -icanon; sleep 30\n")); err != nil {
+		t.Fatalf("HandleData failed: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if err := bridge.HandleData([]byte{0x13}); err != nil {
+		t.Fatalf("HandleData(XOFF) failed: %v", err)
+	}
+
+	chunk := bytes.Repeat([]byte("x"), 1024)
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		start := time.Now()
+		err := bridge.HandleData(chunk)
+		if err == nil {
+			if time.Now().After(deadline) {
+				t.Fatal("PTY never stopped accepting input")
+			}
+			continue
+		}
+		if err != ErrWriteTimeout {
+			t.Fatalf("expected ErrWriteTimeout, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Fatalf("HandleData blocked for %v despite 200ms timeout", elapsed)
+		}
+		break
+	}
+
+	// Later input is dropped immediately rather than queued behind the stuck write
+	start := time.Now()
+	if err := bridge.HandleData([]byte("y")); err != ErrInputStalled {
+		t.Fatalf("expected ErrInputStalled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("stalled HandleData took %v, want immediate", elapsed)
+	}
+}
+
+// typingScript mimics a line editor: each keystroke echoes the character
+// and redraws with a few escape sequences, written separately
+const typingScript = `for i in 1 2 3 4 5 6 7 8 9 10; do
+	printf a; printf '\033[K'; printf '\033[D'; printf '\033[C'; sleep 0.02
+done`
+
+const typingKeystrokes = 10
+
+// runTypingSession runs typingScript through a bridge and returns the
+// number of frames sent and the output
+func runTypingSession(tb testing.TB, window time.Duration) (int, string) {
+	pty, err := StartCommand([]string{"/bin/sh", "-c", typingScript})
+	if err != nil {
+		tb.Fatalf("StartCommand failed: %v", err)
+	}
+	defer pty.Close()
+
+	var frames int
+	var output bytes.Buffer
+	bridge := NewBridge(pty, func(data []byte) error {
+		frames++
+		output.Write(data)
+		return nil
+	})
+	bridge.SetCoalesceWindow(window)
+	bridge.Start()
+	if !bridge.WaitForExit(10 * time.Second) {
+		tb.Fatal("timeout waiting for the script to finish")
+	}
+	return frames, output.String()
+}
+
+func TestBridgeCoalesce(t *testing.T) {
+	frames, output := runTypingSession(t, 0)
+
+	want := strings.Repeat("a\x1b[K\x1b[D\x1b[C", typingKeystrokes)
+	if output != want {
+		t.Errorf("output = %q, want %q", output, want)
+	}
+	// Each keystroke's four writes should share a frame
+	if frames >= 4*typingKeystrokes {
+		t.Errorf("sent %d frames for %d writes, want them coalesced", frames, 4*typingKeystrokes)
+	}
+}
+
+func TestBridgeBell(t *testing.T) {
+	// A prompt setting the window title ends its OSC with BEL; only the
+	// bare bells ring, and the second is within bellMinInterval
+	pty, err := StartCommand([]string{"/bin/sh", "-c", `printf '\033]0;title\007$ '; printf 'done\007'; printf '\007'`})
+	if err != nil {
+		t.Fatalf("StartCommand failed: %v", err)
+	}
+	defer pty.Close()
+
+	rings := make(chan struct{}, 10)
+	bridge := NewBridge(pty, func(data []byte) error { return nil })
+	bridge.SetBellHandler(func() { rings <- struct{}{} })
+	bridge.Start()
+	if !bridge.WaitForExit(10 * time.Second) {
+		t.Fatal("timeout waiting for the script to finish")
+	}
+	bridge.Close()
+
+	if got := len(rings); got != 1 {
+		t.Errorf("bell handler called %d times, want 1", got)
+	}
+}
+
+func TestBridgeZmodemWhilePaused(t *testing.T) {
+	// sz starts while no client is connected; the next client is told
+	// before it is sent the transfer's bytes
+	pty, err := StartCommand([]string{"/bin/sh", "-c", `printf '**\030B00000000000000\r'; sleep 10`})
+	if err != nil {
+		t.Fatalf("StartCommand failed: %v", err)
+	}
+	defer pty.Close()
+
+	var mu sync.Mutex
+	var events []string
+	bridge := NewBridge(pty, nil)
+	bridge.SetZmodemHandler(func(direction byte) {
+		t.Error("zmodem handler called while paused")
+	})
+	bridge.Pause()
+	bridge.Start()
+	defer bridge.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		bridge.mu.Lock()
+		pending := bridge.zmodemPending
+		bridge.mu.Unlock()
+		if pending {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for the zmodem start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	bridge.SetZmodemHandler(func(direction byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		if direction != protocol.ZmodemSend {
+			t.Errorf("direction = %d, want ZmodemSend", direction)
+		}
+		events = append(events, "zmodem")
+	})
+	bridge.Resume(func(data []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if bytes.Contains(data, zmodemHeader) {
+			events = append(events, "transfer")
+		}
+		return nil
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(events, ",") != "zmodem,transfer" {
+		t.Errorf("events = %v, want the zmodem notice before the transfer", events)
+	}
+}
+
+func BenchmarkBridgeTypingFrames(b *testing.B) {
+	for _, bc := range []struct {
+		name   string
+		window time.Duration
+	}{
+		{"coalesce-5ms", 5 * time.Millisecond},
+		{"off", -1},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			var total int
+			for i := 0; i < b.N; i++ {
+				frames, _ := runTypingSession(b, bc.window)
+				total += frames
+			}
+			b.ReportMetric(float64(total)/float64(b.N*typingKeystrokes), "frames/keystroke")
+		})
+	}
+}
@@ -8,19 +8,26 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"os/user"
+	"runtime"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/creack/pty"
+	"golang.org/x/term"
 )
 
 // PTY manages a pseudo-terminal
 type PTY struct {
 	ptmx       *os.File
 	cmd        *exec.Cmd
-	pid        int  // stored PID for reattached PTYs
-	reattached bool // true if this PTY was reattached
+	pid        int         // stored PID for reattached PTYs
+	reattached bool        // true if this PTY was reattached
+	mode       *term.State // Line settings at start, for RestoreMode
 
 	mu     sync.Mutex
 	closed bool
@@ -28,30 +35,130 @@ type PTY struct {
 
 // StartPTY creates a new PTY with the given shell
 func StartPTY(shell string) (*PTY, error) {
+	return StartPTYSize(shell, DefaultRows, DefaultCols)
+}
+
+// StartPTYSize is StartPTY with the PTY at rows x cols from the start
+func StartPTYSize(shell string, rows, cols uint16) (*PTY, error) {
+	return StartCommandSize([]string{resolveShell(shell)}, rows, cols)
+}
+
+// resolveShell returns shell, or the default shell if it is empty
+func resolveShell(shell string) string {
 	if shell == "" {
-		shell = os.Getenv("SHELL")
+		return DefaultShell()
+	}
+	return shell
+}
+
+// DefaultShell returns the shell to run when none is given: $SHELL, then
+// the login shell from the user database (for daemons started without
+// $SHELL), then /bin/sh. Shells that are not executable are skipped.
+func DefaultShell() string {
+	for _, shell := range []string{os.Getenv("SHELL"), loginShell()} {
 		if shell == "" {
-			shell = "/bin/sh"
+			continue
+		}
+		if _, err := exec.LookPath(shell); err == nil {
+			return shell
 		}
 	}
+	return "/bin/sh"
+}
 
-	cmd := exec.Command(shell)
-	cmd.Env = append(os.Environ(), "TERM=xterm-256color")
+// loginShell returns the current user's login shell, or "" if unknown
+func loginShell() string {
+	u, err := user.Current()
+	if err != nil {
+		return ""
+	}
+
+	if runtime.GOOS == "darwin" {
+		// macOS keeps accounts in Directory Services, not /etc/passwd
+		out, err := exec.Command("dscl", ".", "-read", "/Users/"+u.Username, "UserShell").Output()
+		if err != nil {
+			return ""
+		}
+		// UserShell: /bin/zsh
+		_, shell, _ := strings.Cut(string(out), ":")
+		return strings.TrimSpace(shell)
+	}
+
+	data, err := os.ReadFile("/etc/passwd")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		// name:password:uid:gid:gecos:home:shell
+		fields := strings.Split(line, ":")
+		if len(fields) == 7 && fields[2] == u.Uid {
+			return strings.TrimSpace(fields[6])
+		}
+	}
+	return ""
+}
+
+// StartCommand creates a new PTY running a single program. args[0] is
+// executed directly with the remaining arguments - no shell is involved,
+// so the session ends when the program exits.
+func StartCommand(args []string) (*PTY, error) {
+	return StartCommandSize(args, DefaultRows, DefaultCols)
+}
+
+// StartCommandSize is StartCommand with the PTY at rows x cols before the
+// program runs, so programs that read their size at launch see it
+func StartCommandSize(args []string, rows, cols uint16) (*PTY, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no command given")
+	}
+
+	return startPTYCommand(exec.Command(args[0], args[1:]...), rows, cols)
+}
+
+// StartIsolated creates a new PTY running args, or the shell if args is
+// empty, in new Linux namespaces so the session cannot see or signal host
+// processes. Returns ErrIsolationUnsupported on other platforms.
+func StartIsolated(shell string, args []string) (*PTY, error) {
+	return StartIsolatedSize(shell, args, DefaultRows, DefaultCols)
+}
 
-	ptmx, err := pty.Start(cmd)
+// StartIsolatedSize is StartIsolated with the PTY at rows x cols from the start
+func StartIsolatedSize(shell string, args []string, rows, cols uint16) (*PTY, error) {
+	if len(args) == 0 {
+		args = []string{resolveShell(shell)}
+	}
+	cmd, err := isolatedCommand(args)
 	if err != nil {
 		return nil, err
 	}
+	p, err := startPTYCommand(cmd, rows, cols)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start isolated session (unprivileged user namespaces may be disabled): %w", err)
+	}
+	return p, nil
+}
 
-	// Set initial size
-	pty.Setsize(ptmx, &pty.Winsize{
-		Rows: 24,
-		Cols: 80,
+// startPTYCommand starts cmd on a new PTY of rows x cols
+func startPTYCommand(cmd *exec.Cmd, rows, cols uint16) (*PTY, error) {
+	cmd.Env = append(os.Environ(), "TERM=xterm-256color")
+
+	// Sized before cmd runs, not after, so it never sees another size
+	ptmx, err := pty.StartWithSize(cmd, &pty.Winsize{
+		Rows: rows,
+		Cols: cols,
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Remember the line settings so a wedged terminal can be reset; a
+	// reattached PTY's settings may already be wedged, so it has none
+	mode, _ := term.GetState(int(ptmx.Fd()))
 
 	return &PTY{
 		ptmx: ptmx,
 		cmd:  cmd,
+		mode: mode,
 	}, nil
 }
 
@@ -76,6 +183,20 @@ func ReattachPTY(ptyPath string, shellPID int) (*PTY, error) {
 	}, nil
 }
 
+// AdoptPTY wraps a PTY master handed over by another process (see
+// Server.Detach), whose shell has the given PID
+func AdoptPTY(ptmx *os.File, shellPID int) (*PTY, error) {
+	if !IsProcessRunning(shellPID) {
+		return nil, fmt.Errorf("shell process %d is not running", shellPID)
+	}
+
+	return &PTY{
+		ptmx:       ptmx,
+		pid:        shellPID,
+		reattached: true,
+	}, nil
+}
+
 // IsProcessRunning checks if a process with the given PID is running
 func IsProcessRunning(pid int) bool {
 	if pid <= 0 {
@@ -120,6 +241,38 @@ func (p *PTY) Resize(rows, cols uint16) error {
 	})
 }
 
+// Size returns the PTY's current dimensions
+func (p *PTY) Size() (rows, cols uint16, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return 0, 0, io.ErrClosedPipe
+	}
+
+	ws, err := pty.GetsizeFull(p.ptmx)
+	if err != nil {
+		return 0, 0, err
+	}
+	return ws.Rows, ws.Cols, nil
+}
+
+// RestoreMode puts back the line settings (echo, canonical mode, signals)
+// the PTY started with, undoing a program that exited in raw mode.
+// Reattached PTYs have nothing to restore.
+func (p *PTY) RestoreMode() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return io.ErrClosedPipe
+	}
+	if p.mode == nil {
+		return nil
+	}
+	return term.Restore(int(p.ptmx.Fd()), p.mode)
+}
+
 // Name returns the PTY device path (e.g., /dev/pts/0)
 func (p *PTY) Name() string {
 	return p.ptmx.Name()
@@ -169,26 +322,54 @@ func (p *PTY) Wait() error {
 	return p.cmd.Wait()
 }
 
+// File returns the PTY master, to hand the session to another process
+func (p *PTY) File() *os.File {
+	return p.ptmx
+}
+
 // Fd returns the file descriptor of the PTY
 func (p *PTY) Fd() uintptr {
 	return p.ptmx.Fd()
 }
 
+// viewerSend is a viewer's output queue, with the ID that removes it
+type viewerSend struct {
+	id    int
+	queue *sendQueue
+}
+
 // Bridge connects the PTY to a data channel for bidirectional I/O
 type Bridge struct {
 	pty           *PTY
 	send          func([]byte) error
-	viewerSends   []func([]byte) error // Additional send functions for viewers (read-only)
-	recorder      func([]byte) error   // Optional recording callback
+	viewerSends   []viewerSend         // Additional send functions for viewers (read-only)
+	viewerSendID  int                  // Last ID given out by AddViewerSend
+	recorders     []func([]byte) error // Recording callbacks, each sent all output
 	localOutput   io.Writer            // Optional local output (for interactive mode)
+	onZmodem      func(direction byte) // Optional zmodem transfer start callback
+	zmodem        zmodemDetector       // Scans output for zmodem start sequences
+	zmodemPending bool                 // A zmodem start no client has been told of yet
+	zmodemDir     byte                 // Direction of the pending zmodem start
+	onBell        func()               // Optional terminal bell callback
+	onExit        func()               // Optional callback when the PTY closes on its own
+	bell          bellDetector         // Scans output for bells when onBell is set
+	binary        binaryGuard          // Optional binary output suppression for remote clients
+	encoding      encodingDetector     // Samples output to tell text from binary
+	input         inputWriter          // Bounds PTY writes so a stuck shell can't block input
+	output        outputCoalescer      // Merges small output chunks into one send
 	done          chan struct{}
 	exited        chan struct{} // Closed when readLoop exits
 	closed        bool
-	started       bool   // Prevents double-starting readLoop
-	paused        bool   // When true, output is buffered instead of sent
-	buffer        []byte // Ring buffer for output during pause
-	historyBuffer []byte // Always-on buffer for late-join viewer replay
-	bufferMax     int    // Maximum buffer size (default 64KB)
+	started       bool         // Prevents double-starting readLoop
+	paused        bool         // When true, output is buffered instead of sent
+	buffer        []byte       // Ring buffer for output during pause
+	sharingPaused bool         // Host paused sharing: output is held, even from attached clients
+	held          []byte       // Output held while sharing is paused
+	historyBuffer []byte       // Always-on buffer for late-join viewer replay
+	bufferMax     int          // Maximum buffer size (default 64KB)
+	readSize      int          // PTY read chunk size (default 4KB)
+	bytesIn       atomic.Int64 // Client input written to the PTY
+	bytesOut      atomic.Int64 // Output sent to the primary client
 	mu            sync.Mutex
 	closeOnce     sync.Once // Ensures channels are closed only once
 	exitOnce      sync.Once // Ensures exited channel is closed only once
@@ -196,6 +377,14 @@ type Bridge struct {
 
 const defaultBufferMax = 64 * 1024 // 64KB default buffer
 
+// PTY read chunk sizes. Small chunks keep interactive echo snappy; large
+// chunks cut per-message overhead for bulk output. The max keeps a framed,
+// encrypted chunk under the 64KB data channel message limit.
+const (
+	defaultReadSize = 4 * 1024
+	maxReadSize     = 60 * 1024
+)
+
 // NewBridge creates a bridge between a PTY and a send function
 // send can be nil for local-only mode (PTY output only goes to localOutput)
 func NewBridge(pty *PTY, send func([]byte) error) *Bridge {
@@ -205,7 +394,49 @@ func NewBridge(pty *PTY, send func([]byte) error) *Bridge {
 		done:      make(chan struct{}),
 		exited:    make(chan struct{}),
 		bufferMax: defaultBufferMax,
+		readSize:  defaultReadSize,
+		output:    outputCoalescer{window: defaultCoalesceWindow},
+	}
+}
+
+// SetBinaryGuard enables pausing remote output when binary data is detected.
+// Streaming resumes when the client sends any input.
+func (b *Bridge) SetBinaryGuard(enabled bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.binary.enabled = enabled
+}
+
+// SetReadSize sets the PTY read chunk size; must be called before Start.
+// Values <= 0 select the default and values above maxReadSize are clamped.
+func (b *Bridge) SetReadSize(n int) {
+	if n <= 0 {
+		n = defaultReadSize
+	}
+	if n > maxReadSize {
+		n = maxReadSize
+	}
+	b.mu.Lock()
+	b.readSize = n
+	b.mu.Unlock()
+}
+
+// SetCoalesceWindow sets how long output is held to merge small chunks
+// into one send. d == 0 selects the default (5ms) and d < 0 sends every
+// chunk as soon as it is read.
+func (b *Bridge) SetCoalesceWindow(d time.Duration) {
+	if d == 0 {
+		d = defaultCoalesceWindow
 	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.output.window = d
+}
+
+// SetWriteTimeout sets how long client input may wait for the shell to
+// accept it before HandleData gives up. d <= 0 selects the default (5s).
+func (b *Bridge) SetWriteTimeout(d time.Duration) {
+	b.input.SetTimeout(d)
 }
 
 // AttachSender attaches or updates the primary send function
@@ -214,12 +445,19 @@ func (b *Bridge) AttachSender(send func([]byte) error) int {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	// Pending output is already in the history; deliver it to the current
+	// receivers so the new client doesn't get it twice
+	b.sendLocked(b.output.Take())
+
 	// Send history buffer to new client for late-join replay
 	bufferedBytes := len(b.historyBuffer)
-	if bufferedBytes > 0 && send != nil {
+	if (bufferedBytes > 0 || b.sharingPaused) && send != nil {
 		// Debug: Sending history to new client
 		history := make([]byte, len(b.historyBuffer))
 		copy(history, b.historyBuffer)
+		if b.sharingPaused {
+			history = append(history, sharingPausedNotice...)
+		}
 		go send(history) // Non-blocking send
 	}
 
@@ -234,7 +472,12 @@ func (b *Bridge) Pause() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 	b.paused = true
-	b.viewerSends = nil // Clear viewer sends when pausing
+	b.closeViewerSendsLocked() // Clear viewer sends when pausing
+	// Output held for coalescing goes to the buffer
+	b.buffer = append(b.buffer, b.output.Take()...)
+	if len(b.buffer) > b.bufferMax {
+		b.buffer = b.buffer[len(b.buffer)-b.bufferMax:]
+	}
 	// Debug: Bridge paused
 }
 
@@ -244,21 +487,81 @@ func (b *Bridge) Resume(send func([]byte) error) int {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	// The new client hears of a transfer in the buffer before its bytes
+	if !b.sharingPaused {
+		b.notifyZmodemLocked()
+	}
+
 	bufferedBytes := len(b.buffer)
 	if bufferedBytes > 0 {
 		// Debug: Bridge resuming
 		// Send buffered data to new client
 		if err := send(b.buffer); err != nil {
 			// Error flushing buffer - ignored
+		} else {
+			b.bytesOut.Add(int64(bufferedBytes))
 		}
 		b.buffer = nil // Clear buffer
 	}
+	if b.sharingPaused {
+		_ = send(sharingPausedNotice)
+	}
 
 	b.send = send
 	b.paused = false
 	return bufferedBytes
 }
 
+// PauseSharing stops sending output to the client and viewers until
+// ResumeSharing, while the session stays connected. Unlike Pause it lasts
+// across reconnects, and the held output is kept out of the history that
+// late joiners replay. Receivers are told sharing is paused. Returns false
+// if sharing was already paused.
+func (b *Bridge) PauseSharing() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.sharingPaused {
+		return false
+	}
+	// Output from before the pause still goes out
+	b.sendLocked(b.output.Take())
+	if !b.paused {
+		b.sendLocked(sharingPausedNotice)
+	}
+	b.sharingPaused = true
+	return true
+}
+
+// ResumeSharing ends PauseSharing, sending the held output so receivers'
+// screens catch up. Returns false if sharing was not paused.
+func (b *Bridge) ResumeSharing() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.sharingPaused {
+		return false
+	}
+	b.sharingPaused = false
+	held := b.held
+	b.held = nil
+
+	b.historyBuffer = appendBounded(b.historyBuffer, held, b.bufferMax)
+	if b.paused {
+		// No client: Resume replays it once one reconnects
+		b.buffer = appendBounded(b.buffer, held, b.bufferMax)
+		return true
+	}
+	b.notifyZmodemLocked()
+	b.sendLocked(append(sharingResumedNotice[:len(sharingResumedNotice):len(sharingResumedNotice)], held...))
+	return true
+}
+
+// SharingPaused reports whether the host has paused sharing
+func (b *Bridge) SharingPaused() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sharingPaused
+}
+
 // IsPaused returns whether the bridge is in paused (buffering) mode
 func (b *Bridge) IsPaused() bool {
 	b.mu.Lock()
@@ -268,34 +571,116 @@ func (b *Bridge) IsPaused() bool {
 
 // AddViewerSend adds an additional send function for viewer channels (read-only)
 // This also sends any buffered output to the new viewer for late-join replay
-func (b *Bridge) AddViewerSend(send func([]byte) error) {
+// The returned function removes it again, for when that viewer leaves
+func (b *Bridge) AddViewerSend(send func([]byte) error) (remove func()) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	// Send history buffer to new viewer for late-join replay
-	if len(b.historyBuffer) > 0 {
+	// Pending output is already in the history
+	b.sendLocked(b.output.Take())
+
+	queue := newSendQueue(viewerSendPool(), send)
+
+	// Send history buffer to new viewer for late-join replay, trimmed to
+	// the current screen when it starts with a full-screen clear
+	if len(b.historyBuffer) > 0 || b.sharingPaused {
 		// Debug: Sending history to new viewer
 		// Make a copy to avoid race conditions
 		history := make([]byte, len(b.historyBuffer))
 		copy(history, b.historyBuffer)
-		go send(history) // Non-blocking send
+		history = viewerHistory(history)
+		if b.sharingPaused {
+			history = append(history, sharingPausedNotice...)
+		}
+		queue.push(history)
 	}
 
-	b.viewerSends = append(b.viewerSends, send)
+	b.viewerSendID++
+	id := b.viewerSendID
+	b.viewerSends = append(b.viewerSends, viewerSend{id: id, queue: queue})
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.viewerSends = slices.DeleteFunc(b.viewerSends, func(v viewerSend) bool {
+			if v.id == id {
+				v.queue.close()
+				return true
+			}
+			return false
+		})
+	}
 }
 
 // ClearViewerSends removes all viewer send functions
 func (b *Bridge) ClearViewerSends() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	b.closeViewerSendsLocked()
+}
+
+// closeViewerSendsLocked drops every viewer and the output queued for them
+func (b *Bridge) closeViewerSendsLocked() {
+	for _, viewer := range b.viewerSends {
+		viewer.queue.close()
+	}
 	b.viewerSends = nil
 }
 
-// SetRecorder sets the recording callback for PTY output
-func (b *Bridge) SetRecorder(recorder func([]byte) error) {
+// AddRecorder adds a recording callback for PTY output. Each recorder gets
+// all output independently, so a full recording and a filtered copy can be
+// written side by side. Recorders are called under the bridge lock.
+func (b *Bridge) AddRecorder(recorder func([]byte) error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.recorders = append(b.recorders, recorder)
+}
+
+// recordLocked passes data to every recorder; the caller holds mu.
+// Recording is best effort, so one failing recorder doesn't stop the
+// others or the session.
+func (b *Bridge) recordLocked(data []byte) {
+	for _, record := range b.recorders {
+		_ = record(data)
+	}
+}
+
+// SetZmodemHandler sets the callback invoked when a zmodem transfer starts.
+// Output bytes are always passed through unmodified, so a capable client can
+// hand the raw stream to its own zmodem implementation. A start seen while
+// no client was receiving output is reported once one is: here if output
+// is flowing, otherwise by Resume or ResumeSharing.
+func (b *Bridge) SetZmodemHandler(handler func(direction byte)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onZmodem = handler
+	if !b.paused && !b.sharingPaused {
+		b.notifyZmodemLocked()
+	}
+}
+
+// notifyZmodemLocked reports a pending zmodem start to the handler, if
+// there is one; the caller holds mu
+func (b *Bridge) notifyZmodemLocked() {
+	if b.zmodemPending && b.onZmodem != nil {
+		b.zmodemPending = false
+		b.onZmodem(b.zmodemDir)
+	}
+}
+
+// SetBellHandler sets the callback invoked when the output rings the
+// terminal bell, at most once a second. nil stops bell detection.
+func (b *Bridge) SetBellHandler(handler func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onBell = handler
+}
+
+// SetExitHandler sets the callback invoked when the PTY closes because the
+// shell exited, rather than because the bridge was closed
+func (b *Bridge) SetExitHandler(handler func()) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	b.recorder = recorder
+	b.onExit = handler
 }
 
 // SetLocalOutput sets a local output writer (for interactive/SSH-like mode)
@@ -320,7 +705,9 @@ func (b *Bridge) Start() {
 // readLoop continuously reads from PTY and sends to channel
 func (b *Bridge) readLoop() {
 	defer b.exitOnce.Do(func() { close(b.exited) }) // Signal that readLoop has exited (safe close)
-	buf := make([]byte, 4096)
+	b.mu.Lock()
+	buf := make([]byte, b.readSize)
+	b.mu.Unlock()
 
 	for {
 		select {
@@ -338,7 +725,14 @@ func (b *Bridge) readLoop() {
 			if os.IsTimeout(err) {
 				continue
 			}
+			b.flushOutput() // Send output still held for coalescing
+			b.mu.Lock()
+			exited, onExit := !b.closed, b.onExit
+			b.mu.Unlock()
 			b.Close()
+			if exited && onExit != nil {
+				onExit()
+			}
 			return
 		}
 
@@ -349,15 +743,39 @@ func (b *Bridge) readLoop() {
 
 			b.mu.Lock()
 
+			// Detect zmodem transfers (sz/rz) so the client can switch modes
+			direction, zmodemStart := b.zmodem.Scan(data)
+			if zmodemStart {
+				// Told below, or to the next client if none is
+				// receiving output now
+				b.zmodemPending, b.zmodemDir = true, direction
+			}
+
+			// Sample output so the session can report its encoding
+			b.encoding.Scan(data)
+
+			// Output for remote clients - binary may be replaced by a warning or held back
+			remote := b.binary.Filter(data, zmodemStart, time.Now())
+
+			// Detect terminal bells while a handler wants them
+			bell := b.onBell != nil && b.bell.Scan(data, time.Now())
+
+			if b.sharingPaused {
+				// Hold everything until the host resumes sharing
+				b.held = appendBounded(b.held, remote, b.bufferMax)
+				b.mu.Unlock()
+				continue
+			}
+
 			// Always update history buffer for late-join viewer replay
-			b.historyBuffer = append(b.historyBuffer, data...)
+			b.historyBuffer = append(b.historyBuffer, remote...)
 			if len(b.historyBuffer) > b.bufferMax {
 				b.historyBuffer = b.historyBuffer[len(b.historyBuffer)-b.bufferMax:]
 			}
 
 			if b.paused {
 				// Buffer the data instead of sending
-				b.buffer = append(b.buffer, data...)
+				b.buffer = append(b.buffer, remote...)
 				// Trim to max buffer size (keep most recent data)
 				if len(b.buffer) > b.bufferMax {
 					b.buffer = b.buffer[len(b.buffer)-b.bufferMax:]
@@ -366,26 +784,36 @@ func (b *Bridge) readLoop() {
 				continue
 			}
 
-			// Send to primary (control) channel if connected
-			if b.send != nil {
-				if err := b.send(data); err != nil {
-					// Debug: Bridge send error
+			// Notify the client before it receives the transfer bytes
+			if b.zmodemPending && b.onZmodem != nil {
+				if err := b.sendLocked(b.output.Take()); err != nil {
 					b.mu.Unlock()
 					b.Close()
 					return
 				}
+				b.notifyZmodemLocked()
 			}
 
-			// Send to viewer channels (best effort - don't fail if viewers disconnect)
-			// Use goroutines to prevent slow viewers from blocking main stream
-			for _, viewerSend := range b.viewerSends {
-				vs := viewerSend // Capture for goroutine
-				go vs(data)      // Non-blocking send
+			if len(remote) > 0 {
+				// Hold small chunks briefly so a burst goes out as one frame
+				ready, arm := b.output.Add(remote)
+				if arm {
+					time.AfterFunc(b.output.window, b.flushOutput)
+				}
+				if err := b.sendLocked(ready); err != nil {
+					// Debug: Bridge send error
+					b.mu.Unlock()
+					b.Close()
+					return
+				}
 			}
-			// Record if recorder is set (best effort - don't fail on recording errors)
-			if b.recorder != nil {
-				b.recorder(data)
+
+			// Ring after queuing the output that carried the bell
+			if bell {
+				b.onBell()
 			}
+
+			b.recordLocked(data)
 			// Write to local output if set (for interactive mode)
 			if b.localOutput != nil {
 				b.localOutput.Write(data)
@@ -395,17 +823,121 @@ func (b *Bridge) readLoop() {
 	}
 }
 
-// HandleData writes incoming data to the PTY
+// sendLocked sends output to the primary channel, if connected, and to the
+// viewers; the caller holds mu
+func (b *Bridge) sendLocked(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if b.send != nil {
+		if err := b.send(data); err != nil {
+			return err
+		}
+		b.bytesOut.Add(int64(len(data)))
+	}
+
+	// Send to viewer channels (best effort - don't fail if viewers disconnect)
+	// Queued for the shared viewer send pool so slow viewers don't block
+	// the main stream
+	for _, viewer := range b.viewerSends {
+		viewer.queue.push(data)
+	}
+	return nil
+}
+
+// flushOutput sends output held for coalescing once its window ends
+func (b *Bridge) flushOutput() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	err := b.sendLocked(b.output.Take())
+	b.mu.Unlock()
+	if err != nil {
+		b.Close()
+	}
+}
+
+// HandleData writes incoming data to the PTY.
+// Any input also releases output held by the binary guard.
+// Returns ErrWriteTimeout or ErrInputStalled if the shell is not reading.
 func (b *Bridge) HandleData(data []byte) error {
-	_, err := b.pty.Write(data)
+	b.binary.Release()
+
+	n, err := b.input.Write(b.pty.Write, data)
+	b.bytesIn.Add(int64(n))
 	return err
 }
 
+// Traffic returns the bytes of client input written to the PTY and of
+// output sent to the primary client over the bridge's lifetime
+func (b *Bridge) Traffic() (in, out int64) {
+	return b.bytesIn.Load(), b.bytesOut.Load()
+}
+
+// OutputEncoding returns how the output so far looks: EncodingUTF8,
+// EncodingLegacy or EncodingBinary, or "" before there is any. It is
+// judged from samples, so a little binary output may go unnoticed.
+func (b *Bridge) OutputEncoding() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.encoding.Encoding()
+}
+
 // HandleResize resizes the PTY
 func (b *Bridge) HandleResize(rows, cols uint16) error {
 	return b.pty.Resize(rows, cols)
 }
 
+// SyncSize re-applies the client's reported dimensions if they differ from the
+// PTY's current size, self-healing drift from missed resize events.
+// Returns true if a resize was applied.
+func (b *Bridge) SyncSize(rows, cols uint16) (bool, error) {
+	if rows == 0 || cols == 0 {
+		return false, nil
+	}
+	curRows, curCols, err := b.pty.Size()
+	if err != nil {
+		return false, err
+	}
+	if curRows == rows && curCols == cols {
+		return false, nil
+	}
+	if err := b.pty.Resize(rows, cols); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Reset recovers a terminal a program left wedged: it restores the PTY's
+// line settings and sends terminalReset to the client and viewers. The
+// sequence goes wherever output would, so the history, the recording and
+// output held while paused reset too.
+func (b *Bridge) Reset() error {
+	modeErr := b.pty.RestoreMode()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.recordLocked(terminalReset)
+	if b.sharingPaused {
+		b.held = appendBounded(b.held, terminalReset, b.bufferMax)
+		return modeErr
+	}
+	b.historyBuffer = appendBounded(b.historyBuffer, terminalReset, b.bufferMax)
+	if b.paused {
+		b.buffer = appendBounded(b.buffer, terminalReset, b.bufferMax)
+		return modeErr
+	}
+	if err := b.sendLocked(b.output.Take()); err != nil {
+		return err
+	}
+	if err := b.sendLocked(terminalReset); err != nil {
+		return err
+	}
+	return modeErr
+}
+
 // Close stops the bridge and closes the PTY
 func (b *Bridge) Close() error {
 	b.mu.Lock()
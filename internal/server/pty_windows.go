@@ -9,7 +9,11 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/UserExistsError/conpty"
@@ -19,6 +23,8 @@ import (
 type PTY struct {
 	cpty *conpty.ConPty
 	cmd  *exec.Cmd
+	rows uint16 // Last applied size (ConPTY has no size query)
+	cols uint16
 
 	mu     sync.Mutex
 	closed bool
@@ -26,22 +32,71 @@ type PTY struct {
 
 // StartPTY creates a new PTY with the given shell using ConPTY
 func StartPTY(shell string) (*PTY, error) {
+	return StartPTYSize(shell, DefaultRows, DefaultCols)
+}
+
+// StartPTYSize is StartPTY with the PTY at rows x cols from the start
+func StartPTYSize(shell string, rows, cols uint16) (*PTY, error) {
 	if shell == "" {
-		// Default to PowerShell on Windows, fallback to cmd.exe
-		shell = "powershell.exe"
-		if _, err := exec.LookPath(shell); err != nil {
-			shell = "cmd.exe"
+		shell = DefaultShell()
+	}
+
+	return startConPTY(shell, rows, cols)
+}
+
+// DefaultShell returns the shell to run when none is given: PowerShell
+// Core (pwsh) if installed, then Windows PowerShell, then cmd.exe
+func DefaultShell() string {
+	for _, shell := range []string{"pwsh.exe", "powershell.exe"} {
+		if _, err := exec.LookPath(shell); err == nil {
+			return shell
 		}
 	}
+	return "cmd.exe"
+}
+
+// StartCommand creates a new PTY running a single program. args[0] is
+// executed directly with the remaining arguments - no shell is involved,
+// so the session ends when the program exits.
+func StartCommand(args []string) (*PTY, error) {
+	return StartCommandSize(args, DefaultRows, DefaultCols)
+}
+
+// StartCommandSize is StartCommand with the PTY at rows x cols before the
+// program runs, so programs that read their size at launch see it
+func StartCommandSize(args []string, rows, cols uint16) (*PTY, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no command given")
+	}
 
-	// Create ConPTY with initial size 80x24
-	cpty, err := conpty.Start(shell, conpty.ConPtyDimensions(80, 24))
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = syscall.EscapeArg(arg)
+	}
+	return startConPTY(strings.Join(quoted, " "), rows, cols)
+}
+
+// StartIsolated is not supported on Windows
+func StartIsolated(shell string, args []string) (*PTY, error) {
+	return nil, ErrIsolationUnsupported
+}
+
+// StartIsolatedSize is not supported on Windows
+func StartIsolatedSize(shell string, args []string, rows, cols uint16) (*PTY, error) {
+	return nil, ErrIsolationUnsupported
+}
+
+// startConPTY starts a command line in a new ConPTY of rows x cols
+func startConPTY(commandLine string, rows, cols uint16) (*PTY, error) {
+	cpty, err := conpty.Start(commandLine, conpty.ConPtyDimensions(int(cols), int(rows)))
 	if err != nil {
 		return nil, fmt.Errorf("failed to start ConPTY: %w", err)
 	}
 
 	return &PTY{
 		cpty: cpty,
+		rows: rows,
+		cols: cols,
 	}, nil
 }
 
@@ -51,6 +106,12 @@ func ReattachPTY(ptyPath string, shellPID int) (*PTY, error) {
 	return nil, fmt.Errorf("PTY reattachment not supported on Windows")
 }
 
+// AdoptPTY is not supported on Windows: a ConPTY can't be handed to
+// another process
+func AdoptPTY(ptmx *os.File, shellPID int) (*PTY, error) {
+	return nil, ErrHandoffUnsupported
+}
+
 // IsProcessRunning checks if a process with the given PID is running
 func IsProcessRunning(pid int) bool {
 	if pid <= 0 {
@@ -107,7 +168,30 @@ func (p *PTY) Resize(rows, cols uint16) error {
 		return io.ErrClosedPipe
 	}
 
-	return p.cpty.Resize(int(cols), int(rows))
+	if err := p.cpty.Resize(int(cols), int(rows)); err != nil {
+		return err
+	}
+	p.rows = rows
+	p.cols = cols
+	return nil
+}
+
+// Size returns the PTY's current dimensions
+func (p *PTY) Size() (rows, cols uint16, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return 0, 0, io.ErrClosedPipe
+	}
+
+	return p.rows, p.cols, nil
+}
+
+// RestoreMode does nothing on Windows: console modes belong to the program
+// attached to the ConPTY, which resets them itself
+func (p *PTY) RestoreMode() error {
+	return nil
 }
 
 // Close closes the PTY and terminates the shell process
@@ -138,6 +222,11 @@ func (p *PTY) Wait() error {
 	return err
 }
 
+// File returns nil: a ConPTY has no master file to hand over
+func (p *PTY) File() *os.File {
+	return nil
+}
+
 // Fd returns the file descriptor of the PTY (not applicable on Windows)
 func (p *PTY) Fd() uintptr {
 	return 0 // ConPTY doesn't expose a single FD
@@ -160,21 +249,44 @@ func (p *PTY) PID() int {
 	return p.cpty.Pid()
 }
 
+// viewerSend is a viewer's output queue, with the ID that removes it
+type viewerSend struct {
+	id    int
+	queue *sendQueue
+}
+
 // Bridge connects the PTY to a data channel for bidirectional I/O
 type Bridge struct {
 	pty           *PTY
 	send          func([]byte) error
-	viewerSends   []func([]byte) error // Additional send functions for viewers (read-only)
-	recorder      func([]byte) error   // Optional recording callback
+	viewerSends   []viewerSend         // Additional send functions for viewers (read-only)
+	viewerSendID  int                  // Last ID given out by AddViewerSend
+	recorders     []func([]byte) error // Recording callbacks, each sent all output
 	localOutput   io.Writer            // Optional local output (for interactive mode)
+	onZmodem      func(direction byte) // Optional zmodem transfer start callback
+	zmodem        zmodemDetector       // Scans output for zmodem start sequences
+	zmodemPending bool                 // A zmodem start no client has been told of yet
+	zmodemDir     byte                 // Direction of the pending zmodem start
+	onBell        func()               // Optional terminal bell callback
+	onExit        func()               // Optional callback when the PTY closes on its own
+	bell          bellDetector         // Scans output for bells when onBell is set
+	binary        binaryGuard          // Optional binary output suppression for remote clients
+	encoding      encodingDetector     // Samples output to tell text from binary
+	input         inputWriter          // Bounds PTY writes so a stuck shell can't block input
+	output        outputCoalescer      // Merges small output chunks into one send
 	done          chan struct{}
 	exited        chan struct{} // Closed when readLoop exits
 	closed        bool
-	started       bool   // Prevents double-starting readLoop
-	paused        bool   // When true, output is buffered instead of sent
-	buffer        []byte // Ring buffer for output during pause
-	historyBuffer []byte // Always-on buffer for late-join viewer replay
-	bufferMax     int    // Maximum buffer size (default 64KB)
+	started       bool         // Prevents double-starting readLoop
+	paused        bool         // When true, output is buffered instead of sent
+	buffer        []byte       // Ring buffer for output during pause
+	sharingPaused bool         // Host paused sharing: output is held, even from attached clients
+	held          []byte       // Output held while sharing is paused
+	historyBuffer []byte       // Always-on buffer for late-join viewer replay
+	bufferMax     int          // Maximum buffer size (default 64KB)
+	readSize      int          // PTY read chunk size (default 4KB)
+	bytesIn       atomic.Int64 // Client input written to the PTY
+	bytesOut      atomic.Int64 // Output sent to the primary client
 	mu            sync.Mutex
 	closeOnce     sync.Once // Ensures channels are closed only once
 	exitOnce      sync.Once // Ensures exited channel is closed only once
@@ -182,6 +294,14 @@ type Bridge struct {
 
 const defaultBufferMax = 64 * 1024 // 64KB default buffer
 
+// PTY read chunk sizes. Small chunks keep interactive echo snappy; large
+// chunks cut per-message overhead for bulk output. The max keeps a framed,
+// encrypted chunk under the 64KB data channel message limit.
+const (
+	defaultReadSize = 4 * 1024
+	maxReadSize     = 60 * 1024
+)
+
 // NewBridge creates a bridge between a PTY and a send function
 func NewBridge(pty *PTY, send func([]byte) error) *Bridge {
 	return &Bridge{
@@ -190,21 +310,70 @@ func NewBridge(pty *PTY, send func([]byte) error) *Bridge {
 		done:      make(chan struct{}),
 		exited:    make(chan struct{}),
 		bufferMax: defaultBufferMax,
+		readSize:  defaultReadSize,
+		output:    outputCoalescer{window: defaultCoalesceWindow},
 	}
 }
 
+// SetBinaryGuard enables pausing remote output when binary data is detected.
+// Streaming resumes when the client sends any input.
+func (b *Bridge) SetBinaryGuard(enabled bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.binary.enabled = enabled
+}
+
+// SetReadSize sets the PTY read chunk size; must be called before Start.
+// Values <= 0 select the default and values above maxReadSize are clamped.
+func (b *Bridge) SetReadSize(n int) {
+	if n <= 0 {
+		n = defaultReadSize
+	}
+	if n > maxReadSize {
+		n = maxReadSize
+	}
+	b.mu.Lock()
+	b.readSize = n
+	b.mu.Unlock()
+}
+
+// SetCoalesceWindow sets how long output is held to merge small chunks
+// into one send. d == 0 selects the default (5ms) and d < 0 sends every
+// chunk as soon as it is read.
+func (b *Bridge) SetCoalesceWindow(d time.Duration) {
+	if d == 0 {
+		d = defaultCoalesceWindow
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.output.window = d
+}
+
+// SetWriteTimeout sets how long client input may wait for the shell to
+// accept it before HandleData gives up. d <= 0 selects the default (5s).
+func (b *Bridge) SetWriteTimeout(d time.Duration) {
+	b.input.SetTimeout(d)
+}
+
 // AttachSender attaches or updates the primary send function
 // This is used to connect WebRTC channel after PTY is already running
 func (b *Bridge) AttachSender(send func([]byte) error) int {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	// Pending output is already in the history; deliver it to the current
+	// receivers so the new client doesn't get it twice
+	b.sendLocked(b.output.Take())
+
 	// Send history buffer to new client for late-join replay
 	bufferedBytes := len(b.historyBuffer)
-	if bufferedBytes > 0 && send != nil {
+	if (bufferedBytes > 0 || b.sharingPaused) && send != nil {
 		// Debug: Sending history to new client
 		history := make([]byte, len(b.historyBuffer))
 		copy(history, b.historyBuffer)
+		if b.sharingPaused {
+			history = append(history, sharingPausedNotice...)
+		}
 		go send(history) // Non-blocking send
 	}
 
@@ -219,7 +388,12 @@ func (b *Bridge) Pause() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 	b.paused = true
-	b.viewerSends = nil // Clear viewer sends when pausing
+	b.closeViewerSendsLocked() // Clear viewer sends when pausing
+	// Output held for coalescing goes to the buffer
+	b.buffer = append(b.buffer, b.output.Take()...)
+	if len(b.buffer) > b.bufferMax {
+		b.buffer = b.buffer[len(b.buffer)-b.bufferMax:]
+	}
 	// Debug: Bridge paused
 }
 
@@ -229,21 +403,81 @@ func (b *Bridge) Resume(send func([]byte) error) int {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	// The new client hears of a transfer in the buffer before its bytes
+	if !b.sharingPaused {
+		b.notifyZmodemLocked()
+	}
+
 	bufferedBytes := len(b.buffer)
 	if bufferedBytes > 0 {
 		// Debug: Bridge resuming
 		// Send buffered data to new client
 		if err := send(b.buffer); err != nil {
 			// Error flushing buffer - ignored
+		} else {
+			b.bytesOut.Add(int64(bufferedBytes))
 		}
 		b.buffer = nil // Clear buffer
 	}
+	if b.sharingPaused {
+		_ = send(sharingPausedNotice)
+	}
 
 	b.send = send
 	b.paused = false
 	return bufferedBytes
 }
 
+// PauseSharing stops sending output to the client and viewers until
+// ResumeSharing, while the session stays connected. Unlike Pause it lasts
+// across reconnects, and the held output is kept out of the history that
+// late joiners replay. Receivers are told sharing is paused. Returns false
+// if sharing was already paused.
+func (b *Bridge) PauseSharing() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.sharingPaused {
+		return false
+	}
+	// Output from before the pause still goes out
+	b.sendLocked(b.output.Take())
+	if !b.paused {
+		b.sendLocked(sharingPausedNotice)
+	}
+	b.sharingPaused = true
+	return true
+}
+
+// ResumeSharing ends PauseSharing, sending the held output so receivers'
+// screens catch up. Returns false if sharing was not paused.
+func (b *Bridge) ResumeSharing() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.sharingPaused {
+		return false
+	}
+	b.sharingPaused = false
+	held := b.held
+	b.held = nil
+
+	b.historyBuffer = appendBounded(b.historyBuffer, held, b.bufferMax)
+	if b.paused {
+		// No client: Resume replays it once one reconnects
+		b.buffer = appendBounded(b.buffer, held, b.bufferMax)
+		return true
+	}
+	b.notifyZmodemLocked()
+	b.sendLocked(append(sharingResumedNotice[:len(sharingResumedNotice):len(sharingResumedNotice)], held...))
+	return true
+}
+
+// SharingPaused reports whether the host has paused sharing
+func (b *Bridge) SharingPaused() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sharingPaused
+}
+
 // IsPaused returns whether the bridge is in paused (buffering) mode
 func (b *Bridge) IsPaused() bool {
 	b.mu.Lock()
@@ -253,34 +487,116 @@ func (b *Bridge) IsPaused() bool {
 
 // AddViewerSend adds an additional send function for viewer channels (read-only)
 // This also sends any buffered output to the new viewer for late-join replay
-func (b *Bridge) AddViewerSend(send func([]byte) error) {
+// The returned function removes it again, for when that viewer leaves
+func (b *Bridge) AddViewerSend(send func([]byte) error) (remove func()) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	// Send history buffer to new viewer for late-join replay
-	if len(b.historyBuffer) > 0 {
+	// Pending output is already in the history
+	b.sendLocked(b.output.Take())
+
+	queue := newSendQueue(viewerSendPool(), send)
+
+	// Send history buffer to new viewer for late-join replay, trimmed to
+	// the current screen when it starts with a full-screen clear
+	if len(b.historyBuffer) > 0 || b.sharingPaused {
 		// Debug: Sending history to new viewer
 		// Make a copy to avoid race conditions
 		history := make([]byte, len(b.historyBuffer))
 		copy(history, b.historyBuffer)
-		go send(history) // Non-blocking send
+		history = viewerHistory(history)
+		if b.sharingPaused {
+			history = append(history, sharingPausedNotice...)
+		}
+		queue.push(history)
 	}
 
-	b.viewerSends = append(b.viewerSends, send)
+	b.viewerSendID++
+	id := b.viewerSendID
+	b.viewerSends = append(b.viewerSends, viewerSend{id: id, queue: queue})
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.viewerSends = slices.DeleteFunc(b.viewerSends, func(v viewerSend) bool {
+			if v.id == id {
+				v.queue.close()
+				return true
+			}
+			return false
+		})
+	}
 }
 
 // ClearViewerSends removes all viewer send functions
 func (b *Bridge) ClearViewerSends() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	b.closeViewerSendsLocked()
+}
+
+// closeViewerSendsLocked drops every viewer and the output queued for them
+func (b *Bridge) closeViewerSendsLocked() {
+	for _, viewer := range b.viewerSends {
+		viewer.queue.close()
+	}
 	b.viewerSends = nil
 }
 
-// SetRecorder sets the recording callback for PTY output
-func (b *Bridge) SetRecorder(recorder func([]byte) error) {
+// AddRecorder adds a recording callback for PTY output. Each recorder gets
+// all output independently, so a full recording and a filtered copy can be
+// written side by side. Recorders are called under the bridge lock.
+func (b *Bridge) AddRecorder(recorder func([]byte) error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.recorders = append(b.recorders, recorder)
+}
+
+// recordLocked passes data to every recorder; the caller holds mu.
+// Recording is best effort, so one failing recorder doesn't stop the
+// others or the session.
+func (b *Bridge) recordLocked(data []byte) {
+	for _, record := range b.recorders {
+		_ = record(data)
+	}
+}
+
+// SetZmodemHandler sets the callback invoked when a zmodem transfer starts.
+// Output bytes are always passed through unmodified, so a capable client can
+// hand the raw stream to its own zmodem implementation. A start seen while
+// no client was receiving output is reported once one is: here if output
+// is flowing, otherwise by Resume or ResumeSharing.
+func (b *Bridge) SetZmodemHandler(handler func(direction byte)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onZmodem = handler
+	if !b.paused && !b.sharingPaused {
+		b.notifyZmodemLocked()
+	}
+}
+
+// notifyZmodemLocked reports a pending zmodem start to the handler, if
+// there is one; the caller holds mu
+func (b *Bridge) notifyZmodemLocked() {
+	if b.zmodemPending && b.onZmodem != nil {
+		b.zmodemPending = false
+		b.onZmodem(b.zmodemDir)
+	}
+}
+
+// SetBellHandler sets the callback invoked when the output rings the
+// terminal bell, at most once a second. nil stops bell detection.
+func (b *Bridge) SetBellHandler(handler func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onBell = handler
+}
+
+// SetExitHandler sets the callback invoked when the PTY closes because the
+// shell exited, rather than because the bridge was closed
+func (b *Bridge) SetExitHandler(handler func()) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	b.recorder = recorder
+	b.onExit = handler
 }
 
 // SetLocalOutput sets a local output writer (for interactive/SSH-like mode)
@@ -305,7 +621,9 @@ func (b *Bridge) Start() {
 // readLoop continuously reads from PTY and sends to channel
 func (b *Bridge) readLoop() {
 	defer b.exitOnce.Do(func() { close(b.exited) }) // Signal that readLoop has exited (safe close)
-	buf := make([]byte, 4096)
+	b.mu.Lock()
+	buf := make([]byte, b.readSize)
+	b.mu.Unlock()
 
 	for {
 		select {
@@ -330,7 +648,14 @@ func (b *Bridge) readLoop() {
 			return
 		case <-readDone:
 			if err != nil {
+				b.flushOutput() // Send output still held for coalescing
+				b.mu.Lock()
+				exited, onExit := !b.closed, b.onExit
+				b.mu.Unlock()
 				b.Close()
+				if exited && onExit != nil {
+					onExit()
+				}
 				return
 			}
 		case <-time.After(100 * time.Millisecond):
@@ -345,15 +670,39 @@ func (b *Bridge) readLoop() {
 
 			b.mu.Lock()
 
+			// Detect zmodem transfers (sz/rz) so the client can switch modes
+			direction, zmodemStart := b.zmodem.Scan(data)
+			if zmodemStart {
+				// Told below, or to the next client if none is
+				// receiving output now
+				b.zmodemPending, b.zmodemDir = true, direction
+			}
+
+			// Sample output so the session can report its encoding
+			b.encoding.Scan(data)
+
+			// Output for remote clients - binary may be replaced by a warning or held back
+			remote := b.binary.Filter(data, zmodemStart, time.Now())
+
+			// Detect terminal bells while a handler wants them
+			bell := b.onBell != nil && b.bell.Scan(data, time.Now())
+
+			if b.sharingPaused {
+				// Hold everything until the host resumes sharing
+				b.held = appendBounded(b.held, remote, b.bufferMax)
+				b.mu.Unlock()
+				continue
+			}
+
 			// Always update history buffer for late-join viewer replay
-			b.historyBuffer = append(b.historyBuffer, data...)
+			b.historyBuffer = append(b.historyBuffer, remote...)
 			if len(b.historyBuffer) > b.bufferMax {
 				b.historyBuffer = b.historyBuffer[len(b.historyBuffer)-b.bufferMax:]
 			}
 
 			if b.paused {
 				// Buffer the data instead of sending
-				b.buffer = append(b.buffer, data...)
+				b.buffer = append(b.buffer, remote...)
 				// Trim to max buffer size (keep most recent data)
 				if len(b.buffer) > b.bufferMax {
 					b.buffer = b.buffer[len(b.buffer)-b.bufferMax:]
@@ -362,24 +711,36 @@ func (b *Bridge) readLoop() {
 				continue
 			}
 
-			// Send to primary (control) channel
-			if err := b.send(data); err != nil {
-				// Debug: Bridge send error
-				b.mu.Unlock()
-				b.Close()
-				return
+			// Notify the client before it receives the transfer bytes
+			if b.zmodemPending && b.onZmodem != nil {
+				if err := b.sendLocked(b.output.Take()); err != nil {
+					b.mu.Unlock()
+					b.Close()
+					return
+				}
+				b.notifyZmodemLocked()
 			}
 
-			// Send to viewer channels (best effort - don't fail if viewers disconnect)
-			// Use goroutines to prevent slow viewers from blocking main stream
-			for _, viewerSend := range b.viewerSends {
-				vs := viewerSend // Capture for goroutine
-				go vs(data)      // Non-blocking send
+			if len(remote) > 0 {
+				// Hold small chunks briefly so a burst goes out as one frame
+				ready, arm := b.output.Add(remote)
+				if arm {
+					time.AfterFunc(b.output.window, b.flushOutput)
+				}
+				if err := b.sendLocked(ready); err != nil {
+					// Debug: Bridge send error
+					b.mu.Unlock()
+					b.Close()
+					return
+				}
 			}
-			// Record if recorder is set (best effort - don't fail on recording errors)
-			if b.recorder != nil {
-				b.recorder(data)
+
+			// Ring after queuing the output that carried the bell
+			if bell {
+				b.onBell()
 			}
+
+			b.recordLocked(data)
 			// Write to local output if set (for interactive mode)
 			if b.localOutput != nil {
 				b.localOutput.Write(data)
@@ -389,17 +750,121 @@ func (b *Bridge) readLoop() {
 	}
 }
 
-// HandleData writes incoming data to the PTY
+// sendLocked sends output to the primary channel, if connected, and to the
+// viewers; the caller holds mu
+func (b *Bridge) sendLocked(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if b.send != nil {
+		if err := b.send(data); err != nil {
+			return err
+		}
+		b.bytesOut.Add(int64(len(data)))
+	}
+
+	// Send to viewer channels (best effort - don't fail if viewers disconnect)
+	// Queued for the shared viewer send pool so slow viewers don't block
+	// the main stream
+	for _, viewer := range b.viewerSends {
+		viewer.queue.push(data)
+	}
+	return nil
+}
+
+// flushOutput sends output held for coalescing once its window ends
+func (b *Bridge) flushOutput() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	err := b.sendLocked(b.output.Take())
+	b.mu.Unlock()
+	if err != nil {
+		b.Close()
+	}
+}
+
+// HandleData writes incoming data to the PTY.
+// Any input also releases output held by the binary guard.
+// Returns ErrWriteTimeout or ErrInputStalled if the shell is not reading.
 func (b *Bridge) HandleData(data []byte) error {
-	_, err := b.pty.Write(data)
+	b.binary.Release()
+
+	n, err := b.input.Write(b.pty.Write, data)
+	b.bytesIn.Add(int64(n))
 	return err
 }
 
+// Traffic returns the bytes of client input written to the PTY and of
+// output sent to the primary client over the bridge's lifetime
+func (b *Bridge) Traffic() (in, out int64) {
+	return b.bytesIn.Load(), b.bytesOut.Load()
+}
+
+// OutputEncoding returns how the output so far looks: EncodingUTF8,
+// EncodingLegacy or EncodingBinary, or "" before there is any. It is
+// judged from samples, so a little binary output may go unnoticed.
+func (b *Bridge) OutputEncoding() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.encoding.Encoding()
+}
+
 // HandleResize resizes the PTY
 func (b *Bridge) HandleResize(rows, cols uint16) error {
 	return b.pty.Resize(rows, cols)
 }
 
+// SyncSize re-applies the client's reported dimensions if they differ from the
+// PTY's current size, self-healing drift from missed resize events.
+// Returns true if a resize was applied.
+func (b *Bridge) SyncSize(rows, cols uint16) (bool, error) {
+	if rows == 0 || cols == 0 {
+		return false, nil
+	}
+	curRows, curCols, err := b.pty.Size()
+	if err != nil {
+		return false, err
+	}
+	if curRows == rows && curCols == cols {
+		return false, nil
+	}
+	if err := b.pty.Resize(rows, cols); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Reset recovers a terminal a program left wedged: it restores the PTY's
+// line settings and sends terminalReset to the client and viewers. The
+// sequence goes wherever output would, so the history, the recording and
+// output held while paused reset too.
+func (b *Bridge) Reset() error {
+	modeErr := b.pty.RestoreMode()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.recordLocked(terminalReset)
+	if b.sharingPaused {
+		b.held = appendBounded(b.held, terminalReset, b.bufferMax)
+		return modeErr
+	}
+	b.historyBuffer = appendBounded(b.historyBuffer, terminalReset, b.bufferMax)
+	if b.paused {
+		b.buffer = appendBounded(b.buffer, terminalReset, b.bufferMax)
+		return modeErr
+	}
+	if err := b.sendLocked(b.output.Take()); err != nil {
+		return err
+	}
+	if err := b.sendLocked(terminalReset); err != nil {
+		return err
+	}
+	return modeErr
+}
+
 // Close stops the bridge and closes the PTY
 func (b *Bridge) Close() error {
 	b.mu.Lock()
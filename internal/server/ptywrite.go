@@ -0,0 +1,78 @@
+package server
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// defaultWriteTimeout bounds how long client input may wait for the shell
+// to accept it before the write is abandoned
+const defaultWriteTimeout = 5 * time.Second
+
+var (
+	// ErrWriteTimeout is returned when the shell does not accept input within
+	// the write timeout, e.g. it stopped reading or output is paused with
+	// Ctrl+S and the terminal's input queue is full
+	ErrWriteTimeout = errors.New("pty write timed out: shell is not reading input")
+
+	// ErrInputStalled is returned while a timed-out write is still pending.
+	// Input is dropped until the shell accepts it, so it is never reordered.
+	ErrInputStalled = errors.New("pty input stalled: input dropped")
+)
+
+// inputStalledWarning is shown to the client when its input times out
+var inputStalledWarning = []byte("\r\n\x1b[33m[tt] Shell is not reading input (paused with Ctrl+S? press Ctrl+Q) - input dropped.\x1b[0m\r\n")
+
+// inputWriter bounds PTY writes so a shell that stops reading cannot block
+// the caller (the data channel's receive path). The zero value uses
+// defaultWriteTimeout.
+type inputWriter struct {
+	timeout atomic.Int64 // time.Duration; <= 0 selects defaultWriteTimeout
+	stalled atomic.Bool  // A timed-out write is still waiting for the shell
+}
+
+// SetTimeout sets the write timeout; d <= 0 selects the default
+func (w *inputWriter) SetTimeout(d time.Duration) {
+	w.timeout.Store(int64(d))
+}
+
+// Write runs write(data) and waits up to the timeout for it to finish.
+// A write that times out keeps running in the background; until it
+// completes, further writes fail with ErrInputStalled.
+func (w *inputWriter) Write(write func([]byte) (int, error), data []byte) (int, error) {
+	if w.stalled.Load() {
+		return 0, ErrInputStalled
+	}
+
+	type result struct {
+		n   int
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		n, err := write(data)
+		done <- result{n, err}
+	}()
+
+	timeout := time.Duration(w.timeout.Load())
+	if timeout <= 0 {
+		timeout = defaultWriteTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-done:
+		return r.n, r.err
+	case <-timer.C:
+	}
+
+	// Accept input again once the shell takes the pending write
+	w.stalled.Store(true)
+	go func() {
+		<-done
+		w.stalled.Store(false)
+	}()
+	return 0, ErrWriteTimeout
+}
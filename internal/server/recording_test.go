@@ -0,0 +1,58 @@
+package server
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/artpar/terminal-tunnel/internal/recording"
+)
+
+func TestServerAddMarker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.cast")
+	sig := newMemSignaler()
+	s := startTestServer(t, sig, Callbacks{}, func(o *Options) {
+		o.Record = true
+		o.RecordFile = path
+	})
+
+	// Recording starts with the shell, when the first client connects
+	if _, _, err := s.AddMarker("too early"); !errors.Is(err, ErrNotRecording) {
+		t.Errorf("AddMarker before a client: err = %v, want ErrNotRecording", err)
+	}
+	client, _ := connectClient(t, sig, "test-password", 0)
+	defer client.Close()
+	client.expectEcho(t, "before")
+
+	got, offset, err := s.AddMarker("checkpoint")
+	if err != nil {
+		t.Fatalf("AddMarker failed: %v", err)
+	}
+	if got != path || offset <= 0 {
+		t.Errorf("AddMarker = %q at %v, want %q after the start", got, offset, path)
+	}
+	client.expectEcho(t, "after")
+
+	rec, err := recording.LoadRecording(path)
+	if err != nil {
+		t.Fatalf("LoadRecording failed: %v", err)
+	}
+	markers := rec.Markers()
+	if len(markers) != 1 || markers[0].Data != "checkpoint" {
+		t.Fatalf("markers = %+v, want checkpoint", markers)
+	}
+
+	// The marker sits between the output around it
+	var output string
+	for _, event := range rec.Events {
+		switch event.Type {
+		case "o":
+			output += event.Data
+		case "m":
+			if !strings.Contains(output, "before") || strings.Contains(output, "after") {
+				t.Errorf("marker recorded after output %q, want it between the echoes of before and after", output)
+			}
+		}
+	}
+}
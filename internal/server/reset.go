@@ -0,0 +1,15 @@
+package server
+
+// terminalReset is RIS (ESC c), the full reset that `tput reset` and
+// `reset` send: it leaves the alternate screen and clears modes, character
+// sets and scroll regions the wedged program set
+var terminalReset = []byte("\x1bc")
+
+// resetTerminal handles a client's request to reset a wedged terminal
+func (s *Server) resetTerminal(bridge *Bridge) {
+	if err := bridge.Reset(); err != nil {
+		s.log("  [Debug] Terminal reset: %v\n", err)
+		return
+	}
+	s.log("↺ Terminal reset by client\n")
+}
@@ -0,0 +1,104 @@
+package server
+
+import "bytes"
+
+// Screen clears that start a fresh frame. Output before the last one is
+// not visible any more, so late-joining viewers don't need it.
+var screenResets = [][]byte{
+	[]byte("\x1b[2J"), // Erase display
+	[]byte("\x1bc"),   // Full reset (RIS)
+}
+
+// Private modes that change how the frame after a clear renders. Their last
+// setting before the cut is replayed so the trimmed history draws the same.
+var (
+	altScreenModes = [][2][]byte{
+		{[]byte("\x1b[?1049h"), []byte("\x1b[?1049l")},
+		{[]byte("\x1b[?1047h"), []byte("\x1b[?1047l")},
+		{[]byte("\x1b[?47h"), []byte("\x1b[?47l")},
+	}
+	cursorModes = [][2][]byte{
+		{[]byte("\x1b[?25l"), []byte("\x1b[?25h")},
+	}
+)
+
+// viewerHistory returns the output a late-joining viewer needs to render
+// the current screen: the history from the last full-screen clear onward,
+// prefixed with the alternate screen and cursor visibility in effect there.
+// Full-screen apps like vim clear on start and redraw, so this skips the
+// intermediate states a raw replay would render. Without a clear in the
+// buffer the raw history is returned unchanged.
+func viewerHistory(history []byte) []byte {
+	reset := -1
+	for _, seq := range screenResets {
+		if i := bytes.LastIndex(history, seq); i > reset {
+			reset = i
+		}
+	}
+	if reset < 0 {
+		return history
+	}
+
+	// Keep the escape sequences leading up to the clear (cursor home,
+	// colours), which the clear itself depends on
+	cut := precedingCSIStart(history, reset)
+
+	var prefix []byte
+	if history[reset+1] != 'c' { // RIS resets modes itself
+		before := history[:reset]
+		if lastModeSet(before, altScreenModes) {
+			// Cleared on the alternate screen: once the app exits, the
+			// main screen from before the cut is visible again
+			if !lastModeSet(history, altScreenModes) {
+				return history
+			}
+			prefix = append(prefix, "\x1b[?1049h"...)
+		}
+		if lastModeSet(before, cursorModes) {
+			prefix = append(prefix, "\x1b[?25l"...)
+		}
+		prefix = append(prefix, "\x1b[H"...)
+	}
+
+	if len(prefix)+len(history)-cut >= len(history) {
+		return history
+	}
+	snapshot := make([]byte, 0, len(prefix)+len(history)-cut)
+	snapshot = append(snapshot, prefix...)
+	return append(snapshot, history[cut:]...)
+}
+
+// precedingCSIStart returns the start of the run of complete CSI sequences
+// (ESC [ params final) that ends at end, or end if there are none
+func precedingCSIStart(data []byte, end int) int {
+	start := end
+	for {
+		i := start - 1
+		if i < 2 || data[i] < 0x40 || data[i] > 0x7e {
+			return start
+		}
+		i--
+		for i >= 0 && data[i] >= 0x20 && data[i] <= 0x3f {
+			i--
+		}
+		if i < 1 || data[i] != '[' || data[i-1] != 0x1b {
+			return start
+		}
+		start = i - 1
+	}
+}
+
+// lastModeSet reports whether the last occurrence of any of the mode pairs
+// in data is a set (first) rather than a reset (second) sequence
+func lastModeSet(data []byte, modes [][2][]byte) bool {
+	last, set := -1, false
+	for _, mode := range modes {
+		if i := bytes.LastIndex(data, mode[0]); i > last {
+			last, set = i, true
+		}
+		if i := bytes.LastIndex(data, mode[1]); i > last {
+			last, set = i, false
+		}
+	}
+	return set
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// vimSession builds output resembling a shell session followed by vim:
+// shell scrollback, vim's start-up clear and first frame, incremental
+// redraws while scrolling, and a final full redraw (Ctrl+L).
+func vimSession() (history, lastFrame []byte) {
+	var out bytes.Buffer
+	for i := 0; i < 400; i++ {
+		fmt.Fprintf(&out, "$ ls -la /var/log/app-%03d\r\n-rw-r--r-- 1 root root 4096 app.log\r\n", i)
+	}
+
+	frame := func() []byte {
+		var f bytes.Buffer
+		for row := 1; row <= 24; row++ {
+			fmt.Fprintf(&f, "\x1b[%d;1H\x1b[34m%3d \x1b[0m%s", row, row, strings.Repeat("x", 70))
+		}
+		return f.Bytes()
+	}
+
+	out.WriteString("\x1b[?1049h\x1b[22;0;0t\x1b[1;24r\x1b[?25l\x1b[m\x1b[H\x1b[2J")
+	out.Write(frame())
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&out, "\x1b[1;24r\x1b[24;1H\n\x1b[24;1H\x1b[34m%3d \x1b[0m%s", i, strings.Repeat("y", 70))
+	}
+
+	start := out.Len()
+	out.WriteString("\x1b[m\x1b[H\x1b[2J")
+	out.Write(frame())
+	return out.Bytes(), out.Bytes()[start:]
+}
+
+func TestViewerHistoryVim(t *testing.T) {
+	history, lastFrame := vimSession()
+	got := viewerHistory(history)
+
+	// The viewer gets the last frame, back on the alternate screen with the
+	// cursor hidden as it was when the frame was drawn
+	want := append([]byte("\x1b[?1049h\x1b[?25l\x1b[H"), lastFrame...)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("viewerHistory returned %d bytes, want the %d-byte last frame", len(got), len(want))
+	}
+
+	t.Logf("bytes to first correct frame: raw history %d, screen history %d (%.0f%% less)",
+		len(history), len(got), 100*(1-float64(len(got))/float64(len(history))))
+}
+
+func TestViewerHistoryFallback(t *testing.T) {
+	// No full-screen clear: replay everything
+	history := []byte(strings.Repeat("$ make\r\nok\r\n", 50))
+	if got := viewerHistory(history); !bytes.Equal(got, history) {
+		t.Error("history without a clear should be returned unchanged")
+	}
+}
+
+func TestViewerHistoryLeftAltScreen(t *testing.T) {
+	// vim cleared the alternate screen, then exited: the shell output from
+	// before vim is on screen again, so nothing can be trimmed
+	history := []byte(strings.Repeat("$ ls\r\nfile\r\n", 20) +
+		"\x1b[?1049h\x1b[22;0;0t\x1b[H\x1b[2J" + "~\r\n~\r\n" + "\x1b[?1049l$ ")
+	if got := viewerHistory(history); !bytes.Equal(got, history) {
+		t.Errorf("viewerHistory trimmed history after leaving the alternate screen: %q", got)
+	}
+}
+
+func TestViewerHistoryReset(t *testing.T) {
+	history := []byte(strings.Repeat("old output\r\n", 20) + "\x1bc$ ")
+	if got, want := viewerHistory(history), []byte("\x1bc$ "); !bytes.Equal(got, want) {
+		t.Errorf("viewerHistory = %q, want %q", got, want)
+	}
+}
@@ -5,10 +5,14 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -16,8 +20,11 @@ import (
 	"github.com/skip2/go-qrcode"
 
 	"github.com/artpar/terminal-tunnel/internal/crypto"
+	"github.com/artpar/terminal-tunnel/internal/geoip"
+	"github.com/artpar/terminal-tunnel/internal/protocol"
 	"github.com/artpar/terminal-tunnel/internal/recording"
 	"github.com/artpar/terminal-tunnel/internal/signaling"
+	"github.com/artpar/terminal-tunnel/internal/sink"
 	ttwebrtc "github.com/artpar/terminal-tunnel/internal/webrtc"
 	"github.com/artpar/terminal-tunnel/internal/web"
 )
@@ -28,10 +35,12 @@ func hashSDP(sdp string) string {
 	return hex.EncodeToString(h[:8])
 }
 
-// Options configures the terminal tunnel server
+// Options configures the terminal tunnel server. Timeout bounds the wait
+// for the first client to connect (0 = wait forever); reconnects always wait.
 type Options struct {
 	Password   string
 	Shell      string
+	Command    []string // Run this program directly instead of a shell (see StartCommand)
 	Timeout    time.Duration
 	RelayURL   string // WebSocket relay URL for signaling
 	NoRelay    bool   // Disable relay, use manual if UPnP fails
@@ -40,11 +49,138 @@ type Options struct {
 	Public     bool   // Enable public viewer mode (read-only viewers without password)
 	Record     bool   // Enable session recording
 	RecordFile string // Custom recording file path (optional)
+	OutputSink string // Also stream output to a syslog, webhook or pipe destination (see sink.Open)
+	ReadSize   int    // PTY read chunk size in bytes (0 = 4KB default, max 60KB)
+
+	GuardBinary bool // Pause streaming and warn the client when binary output is detected
+
+	// ReadOnly drops the client's input: it can watch and resize the
+	// terminal but not type into it. Local input still reaches the PTY.
+	ReadOnly bool
+
+	NoViewerRecording bool // Ask public viewers not to offer recording (advisory)
+	NoJoinNotices     bool // Don't tell the client when viewers join or leave
+
+	// MaxViewersPerIP caps the public viewers connected from one address at
+	// once (0 = DefaultMaxViewersPerIP, negative = no limit). The address is
+	// the remote side of the viewer's ICE candidate pair, so viewers relayed
+	// through TURN count against their TURN server's address.
+	MaxViewersPerIP int
+
+	WriteTimeout time.Duration // Max wait for the shell to accept client input (0 = 5s default)
+
+	// CoalesceWindow is how long PTY output is held to merge small reads
+	// into one frame (0 = 5ms default, negative = send every read at once)
+	CoalesceWindow time.Duration
+
+	// LAN also serves short code signaling on the local network, so a
+	// client on the same network can skip the relay. If the relay is
+	// unreachable the session continues on the LAN alone.
+	LAN bool
+
+	// BellNotify forwards terminal bells to clients that support them, so
+	// the web client can notify when its tab is in the background
+	BellNotify bool
+
+	// Rows and Cols size the PTY until a client reports its own size, for
+	// programs that read their size at launch (0 = DefaultRows/DefaultCols)
+	Rows, Cols uint16
+
+	Signaler Signaler // Exchange SDP through this instead of the relay (e.g. in tests)
+
+	// Code asks the relay for this session code instead of a generated one
+	// (see signaling.NormalizeRequestedCode). Start fails with
+	// signaling.ErrCodeTaken if another session holds it, rather than
+	// falling back to other signaling methods.
+	Code string
+
+	// Signaling lists the signaling methods to try for the first
+	// connection, in order, overriding Manual and NoRelay. A method that
+	// can't be set up (relay unreachable, no UPnP) falls back to the next;
+	// if none can, Start returns a *SignalingError. Manual mode waits for
+	// the answer on stdin, so it belongs last. Empty picks the chain from
+	// Manual, NoRelay and LAN.
+	Signaling []signaling.SignalingMethod
+
+	RelayPollInterval time.Duration // Delay between relay answer polls while a client is likely (0 = 100ms default)
+	RelayRetryBackoff time.Duration // Delay before retrying relay network errors (0 = 1s default)
+
+	// After the code is shown, or a client fetches the offer, the relay is
+	// polled every RelayPollInterval for RelayFastPollWindow, then less
+	// often, up to RelayMaxPollInterval apart (see signaling.ShortCodeConfig)
+	RelayFastPollWindow  time.Duration // 0 = 20s default
+	RelayMaxPollInterval time.Duration // 0 = 2s default
+
+	// After RelayFailureThreshold failed relay requests in a row the relay
+	// is treated as down and only checked every RelayCircuitCooldown (see
+	// signaling.ShortCodeConfig)
+	RelayFailureThreshold int           // 0 = 5 default, negative = keep retrying
+	RelayCircuitCooldown  time.Duration // 0 = 30s default
+
+	// ManualOfferLifetime is how long a manual-mode offer is accepted
+	// (0 = signaling.DefaultOfferLifetime, negative = no expiry)
+	ManualOfferLifetime time.Duration
+
+	// ManualOfferNonce puts a random nonce in a manual-mode offer and
+	// accepts only one answer that echoes it
+	ManualOfferNonce bool
+
+	// Isolate runs the shell or command in new Linux user, mount, PID, UTS
+	// and IPC namespaces (Linux only)
+	Isolate bool
+
+	// ContainerRuntime is a command prefix that starts the session in a
+	// container, e.g. docker run --rm -it alpine. Command is appended; with
+	// no Command the image's default program runs.
+	ContainerRuntime []string
+
+	// ReconnectGrace is how long to wait after a client drops before offering
+	// a fresh peer (0 = 1s default, negative = no wait). It only applies when
+	// no standby peer is ready: a standby offer is already on the relay, so
+	// reconnects through it never wait.
+	ReconnectGrace time.Duration
+
+	// DisableStandby skips the standby peer: after a drop the relay gets a
+	// fresh offer and the server waits for the client's answer to it. A
+	// client that reloads is noticed only once the old connection times
+	// out. Reconnects are slower but take a simpler path, which helps tell
+	// whether the standby logic is behind a reconnection problem.
+	DisableStandby bool
+
+	// ConfirmClient holds the first client until the host approves it through
+	// Callbacks.OnClientConnectRequest, before the shell is exposed; a
+	// rejected client is disconnected. Reconnects after approval are let in.
+	// Without the callback, as in daemon sessions, clients are approved.
+	ConfirmClient bool
+
+	// GeoIP lists MaxMind DB files (.mmdb, e.g. GeoLite2-City and
+	// GeoLite2-ASN) used to describe where clients connect from. Without
+	// them only the address and candidate type are shown.
+	GeoIP []string
+
+	// KDF derives the session key from the password. The salt tells
+	// clients which one to use; KDFDefault (Argon2id, with a PBKDF2
+	// fallback for browsers that can't run it) works with every client.
+	KDF crypto.KDF
+
+	// AllowWSFallback lets clients that can't connect over WebRTC, not even
+	// through TURN, carry the session over a WebSocket through the relay
+	// instead. The relay forwards the encrypted frames, so it sees no more
+	// than it would of a TURN connection, but every byte then costs the
+	// relay bandwidth. Relays without the pipe (see signaling.EnablePipe)
+	// leave the session on WebRTC alone.
+	AllowWSFallback bool
+}
+
+// ClientConnectRequest describes a client waiting for host approval
+// (Options.ConfirmClient)
+type ClientConnectRequest struct {
+	CandidatePair string // As described by Peer.SelectedCandidatePair: "<local> -> <remote>", or ttwebrtc.WebSocketLabel over the relay pipe
 }
 
 // Callbacks for daemon integration
 type Callbacks struct {
-	OnShortCodeReady   func(code, clientURL string)
+	OnShortCodeReady   func(code, clientURL string)       // Called again with a new code if the relay drops the session
 	OnViewerCodeReady  func(viewerCode, viewerURL string) // For public viewer mode
 	OnClientConnect    func()
 	OnClientDisconnect func()
@@ -52,13 +188,38 @@ type Callbacks struct {
 	OnViewerDisconnect func()
 	OnPTYReady         func(ptyPath string, shellPID int)
 	OnBridgeReady      func(bridge *Bridge) // Called when bridge is ready for local I/O
+	OnError            func(err error)      // Called when the session fails and cannot continue
+
+	// OnClientConnectRequest asks the host to let a client in
+	// (Options.ConfirmClient); it may block until the host decides
+	OnClientConnectRequest func(req ClientConnectRequest) bool
+
+	// OnClientAttempting is called when a client fetches the offer from the
+	// relay, before the WebRTC handshake. Only relays that accept WebSocket
+	// connections (tt relay) report it.
+	OnClientAttempting func()
+
+	// OnRelayStateChange is called with true when repeated failures make
+	// the relay look down, so requests to it pause, and with false once it
+	// answers again
+	OnRelayStateChange func(down bool)
+
+	// OnHeartbeatFailure is called after each failed relay heartbeat with
+	// the number failed in a row and the last error, and with 0 and nil
+	// once one succeeds again. A relay that misses heartbeats lets the code
+	// expire, and reconnecting clients can't find the session. Heartbeats
+	// skipped while the relay looks down go to OnRelayStateChange instead.
+	OnHeartbeatFailure func(failures int, err error)
 }
 
-// DefaultOptions returns sensible defaults
+// DefaultOptions returns sensible defaults, taking the relay URL and TURN
+// setting from the environment (TT_RELAY_URL, TT_NO_TURN)
 func DefaultOptions() Options {
 	return Options{
-		Shell:   "",
-		Timeout: 5 * time.Minute,
+		Shell:    "",
+		Timeout:  5 * time.Minute,
+		RelayURL: os.Getenv(signaling.EnvRelayURL),
+		NoTURN:   ttwebrtc.NoTURNFromEnv(),
 	}
 }
 
@@ -66,19 +227,44 @@ func DefaultOptions() Options {
 // Set to 4 minutes (session TTL is 5 min) to minimize KV operations
 const relayHeartbeatInterval = 4 * time.Minute
 
+// defaultReconnectGrace is Options.ReconnectGrace when unset. Without a
+// standby peer the client may still be answering the offer of the dropped
+// connection; waiting until the web client's first retry, 1s after the
+// drop, lets that answer land before the fresh offer replaces it. The wait
+// adds to every reconnect without a standby peer, which otherwise takes
+// under a second on a LAN, so it is kept to that one retry.
+const defaultReconnectGrace = 1 * time.Second
+
+// clientKeyTimeout is how long a new connection waits for the client's
+// first message before sending anything, so replies go out under the key
+// the client uses (see EncryptedChannel.WaitForKey). Clients send it as the
+// channel opens; past this the primary key is assumed.
+const clientKeyTimeout = 2 * time.Second
+
+// How often WebRTC transport stats are sampled while a client is connected
+const transportStatsInterval = 5 * time.Second
+
+// Reconnect size handshake: how long to wait for the client's terminal size
+// before replaying buffered output, and how long to let the app redraw after
+const (
+	clientSizeTimeout = 500 * time.Millisecond
+	resizeSettleDelay = 50 * time.Millisecond
+)
+
 // Server orchestrates the terminal tunnel
 type Server struct {
 	opts            Options
 	peer            *ttwebrtc.Peer
 	signaling       *SignalingServer
 	relayClient     *signaling.RelayClient
-	shortCodeClient *signaling.ShortCodeClient
+	shortCodeClient Signaler
+	lan             *lanSignaler // LAN signaling next to the relay (Options.LAN)
 	pty             *PTY
 	bridge          *Bridge
 	channel         *ttwebrtc.EncryptedChannel
 	salt            []byte
 	key             [32]byte
-	pbkdf2Key       [32]byte // PBKDF2 fallback key for CSP-restricted browsers
+	pbkdf2Key       *[32]byte // PBKDF2 fallback key for CSP-restricted browsers (nil unless KDFDefault)
 	sessionID       string
 	upnpClose       func() error
 	disconnected    chan bool
@@ -86,19 +272,51 @@ type Server struct {
 	cancel          context.CancelFunc
 	callbacks       Callbacks
 	webrtcConfig    ttwebrtc.Config
+	fingerprint     string // SHA-256 fingerprint of the DTLS certificate
 
 	// Public viewer support (dual-peer architecture)
-	viewerPeer    *ttwebrtc.Peer
-	viewerChannel *ttwebrtc.EncryptedChannel
-	viewerKey     [32]byte // Random key for viewer encryption (stored in relay)
-	viewerCode    string   // Viewer session code (ends with V)
+	viewerKey  [32]byte // Random key for viewer encryption (stored in relay)
+	viewerCode string   // Viewer session code (ends with V)
+
+	// Public viewers, each on its own peer connection
+	viewerMu      sync.Mutex
+	viewerPeer    *ttwebrtc.Peer // Pending: its offer is on the relay for the next viewer
+	viewerList    []*viewer      // Connected viewers, in the order they joined
+	lastViewerID  int
+	viewerWriter  int  // ID of the viewer the host let type (GrantViewerWrite), 0 if none
+	viewersClosed bool // The session stopped; no more viewers may join
+
+	// Guards bridge, which viewer and daemon goroutines read while a client
+	// connection replaces it
+	bridgeMu sync.Mutex
+
+	// Guards peer and channel for readers outside the connection loop, which
+	// is their only writer
+	connMu sync.Mutex
 
 	// Recording support
-	recorder *recording.Recorder
+	recorder   *recording.Recorder
+	outputSink *sink.Sink
 
 	// Relay heartbeat
 	heartbeatStop chan struct{}
 
+	// Ensures the relay session is deleted only once on shutdown
+	relayDeleteOnce sync.Once
+
+	// Stops watching the current short code for client interest
+	interestMu   sync.Mutex
+	stopInterest context.CancelFunc
+
+	// Number of client reconnections since start (instability metric)
+	reconnects atomic.Int64
+
+	// When the current client connected, in Unix nanoseconds; 0 while none is
+	clientSince atomic.Int64
+
+	// Latest WebRTC transport stats of the client connection (tt stats)
+	transportStats atomic.Pointer[ttwebrtc.TransportStats]
+
 	// Standby peer for instant reconnection (pre-created while connected)
 	// The relay always has the NEXT peer's offer, not the current one
 	// This eliminates the race condition where client gets stale offer
@@ -106,12 +324,30 @@ type Server struct {
 	standbyDc    *webrtc.DataChannel
 	standbyOffer string
 
+	// The first standby, gathered while waiting for the first client so it
+	// only needs a relay update once the client connects (nil once used)
+	prewarmed chan *preparedPeer
+
 	// Answer watcher for detecting client reconnection
 	newAnswer     chan string
 	answerWatcher chan struct{}
 
+	// Clients that joined through the relay pipe (nil without
+	// Options.AllowWSFallback; see startPipeListener)
+	pipeClients chan *ttwebrtc.EncryptedChannel
+
 	// Quiet mode - suppress output after initial display to avoid terminal corruption
 	quiet bool
+
+	diag *Diagnostics // Connection details for a debug bundle (nil = off)
+
+	states stateHistory // Recent connection state changes
+
+	clientApproved bool // The host let a client in (Options.ConfirmClient)
+
+	readOnlyNotice atomic.Int64 // When the client was last told it is read-only (Options.ReadOnly), in Unix nanoseconds
+
+	geoip *geoip.DB // Options.GeoIP, nil without databases
 }
 
 // log prints a message only if not in quiet mode
@@ -126,17 +362,29 @@ func (s *Server) SetQuiet(quiet bool) {
 	s.quiet = quiet
 }
 
+// EnableDiagnostics starts recording connection details (SDPs, ICE
+// candidates and states, timings) for a debug bundle. Call before Start.
+func (s *Server) EnableDiagnostics() *Diagnostics {
+	s.diag = newDiagnostics()
+	return s.diag
+}
+
 // NewServer creates a new terminal tunnel server
 func NewServer(opts Options) (*Server, error) {
-	// Generate salt for key derivation
-	salt, err := crypto.GenerateSalt()
+	// Generate salt for key derivation, naming the KDF if one was chosen
+	salt, err := crypto.GenerateSaltFor(opts.KDF)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate salt: %w", err)
 	}
 
-	// Derive encryption keys (Argon2 primary, PBKDF2 fallback for CSP-restricted browsers)
+	// Derive encryption keys (Argon2 primary, PBKDF2 fallback for
+	// CSP-restricted browsers; a chosen KDF has no fallback)
 	key := crypto.DeriveKey(opts.Password, salt)
-	pbkdf2Key := crypto.DeriveKeyPBKDF2(opts.Password, salt)
+	var pbkdf2Key *[32]byte
+	if opts.KDF == crypto.KDFDefault {
+		k := crypto.DeriveKeyPBKDF2(opts.Password, salt)
+		pbkdf2Key = &k
+	}
 
 	// Generate session ID
 	sessionID := generateSessionID()
@@ -145,9 +393,15 @@ func NewServer(opts Options) (*Server, error) {
 	var webrtcConfig ttwebrtc.Config
 	if opts.NoTURN {
 		webrtcConfig = ttwebrtc.ConfigWithoutTURN()
+	} else if envConfig, ok := ttwebrtc.ConfigFromEnv(); ok {
+		// STUN/TURN servers set in the environment replace the relay's
+		webrtcConfig = envConfig
 	} else {
 		// Try to fetch ICE servers from relay (includes TURN if configured)
-		relayURL := signaling.GetRelayURL()
+		relayURL := opts.RelayURL
+		if relayURL == "" {
+			relayURL = signaling.GetRelayURL()
+		}
 		if iceResp, err := signaling.FetchICEServers(relayURL); err == nil {
 			// Convert signaling config to webrtc config
 			var relayConfigs []ttwebrtc.RelayICEConfig
@@ -165,7 +419,28 @@ func NewServer(opts Options) (*Server, error) {
 		}
 	}
 
+	// One DTLS certificate per server so every peer (including reconnects
+	// and viewers) presents the same fingerprint for out-of-band verification
+	cert, err := ttwebrtc.GenerateCertificate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate DTLS certificate: %w", err)
+	}
+	webrtcConfig.Certificate = cert
+	fingerprint, err := ttwebrtc.CertificateFingerprint(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	var geo *geoip.DB
+	if len(opts.GeoIP) > 0 {
+		if geo, err = geoip.Open(opts.GeoIP...); err != nil {
+			return nil, err
+		}
+	}
+
 	server := &Server{
+		geoip:        geo,
+		fingerprint:  fingerprint,
 		opts:         opts,
 		salt:         salt,
 		key:          key,
@@ -183,6 +458,10 @@ func NewServer(opts Options) (*Server, error) {
 		copy(server.viewerKey[:], viewerKeyBytes)
 	}
 
+	if opts.AllowWSFallback {
+		server.pipeClients = make(chan *ttwebrtc.EncryptedChannel)
+	}
+
 	return server, nil
 }
 
@@ -201,6 +480,142 @@ func (s *Server) SetPTY(pty *PTY) {
 	s.pty = pty
 }
 
+// ReconnectCount returns how many times the client has dropped and
+// reconnected since the server started
+func (s *Server) ReconnectCount() int64 {
+	return s.reconnects.Load()
+}
+
+// StateHistory returns the client connection's recent peer and ICE state
+// changes, oldest first
+func (s *Server) StateHistory() []StateTransition {
+	return s.states.history()
+}
+
+// recordState notes a peer or ICE state change, for StateHistory and the
+// debug bundle
+func (s *Server) recordState(layer, state string) {
+	s.states.record(layer, state)
+	s.diag.recordState(layer, state)
+}
+
+// SessionStats is a snapshot of a session's traffic and connection details
+type SessionStats struct {
+	BytesIn        int64  // Client input written to the PTY
+	BytesOut       int64  // Output sent to the primary client
+	ConnectionType string // "p2p", "relay" (TURN), "websocket" (relay pipe), or "" when not connected
+	Viewers        int    // Connected read-only viewers
+	Encoding       string // How the output looks, see Bridge.OutputEncoding
+}
+
+// Stats returns a snapshot of the session's traffic and connection details
+func (s *Server) Stats() SessionStats {
+	stats := SessionStats{Viewers: s.viewerCount()}
+	if bridge := s.GetBridge(); bridge != nil {
+		stats.BytesIn, stats.BytesOut = bridge.Traffic()
+		stats.Encoding = bridge.OutputEncoding()
+	}
+	if peer := s.currentPeer(); peer != nil {
+		stats.ConnectionType = peer.ConnectionType()
+	} else if channel := s.currentChannel(); channel != nil && channel.Label() == ttwebrtc.WebSocketLabel {
+		stats.ConnectionType = "websocket"
+	}
+	return stats
+}
+
+// TransportStats returns the most recent WebRTC transport stats of the
+// client connection, sampled every few seconds while connected. ok is false
+// if no client has connected yet.
+func (s *Server) TransportStats() (stats ttwebrtc.TransportStats, ok bool) {
+	if latest := s.transportStats.Load(); latest != nil {
+		return *latest, true
+	}
+	return stats, false
+}
+
+// startStatsCollector samples the client connection's transport stats until
+// the server stops
+func (s *Server) startStatsCollector() {
+	sample := func() {
+		if peer := s.currentPeer(); peer != nil && peer.ConnectionState() == webrtc.PeerConnectionStateConnected {
+			stats := peer.GetStats()
+			s.transportStats.Store(&stats)
+		}
+	}
+	sample()
+
+	go func() {
+		ticker := time.NewTicker(transportStatsInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				sample()
+			}
+		}
+	}()
+}
+
+// Default PTY size, until a client reports its own (see Options.Rows)
+const (
+	DefaultRows = 24
+	DefaultCols = 80
+)
+
+// ErrWaitTimeout is returned by Start when no client connects within
+// Options.Timeout
+var ErrWaitTimeout = errors.New("no client connected")
+
+// ErrNotRecording is returned by AddMarker and DumpRecording when the session has no recording
+var ErrNotRecording = errors.New("session is not being recorded")
+
+// ErrIsolationUnsupported is returned when Options.Isolate is set on a
+// platform other than Linux
+var ErrIsolationUnsupported = errors.New("session isolation is only supported on Linux")
+
+// ErrHandoffUnsupported is returned by Detach when the session's shell
+// can't be handed to another process
+var ErrHandoffUnsupported = errors.New("handing a session to the daemon is not supported on Windows")
+
+// AddMarker writes a labelled marker into the session recording. Returns
+// the recording's path and the marker's offset from the start.
+func (s *Server) AddMarker(label string) (string, time.Duration, error) {
+	rec := s.recorder
+	if rec == nil {
+		return "", 0, ErrNotRecording
+	}
+	offset := rec.Duration()
+	if err := rec.WriteMarker(label); err != nil {
+		return "", 0, err
+	}
+	return rec.Path(), offset, nil
+}
+
+// DumpRecording copies the session's recording so far to path, atomically,
+// while recording continues. It returns the recording's path, the number of
+// bytes copied and the recording's duration at the time of the copy.
+func (s *Server) DumpRecording(path string) (string, int64, time.Duration, error) {
+	rec := s.recorder
+	if rec == nil {
+		return "", 0, 0, ErrNotRecording
+	}
+	duration := rec.Duration()
+	n, err := rec.CopyTo(path)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	return rec.Path(), n, duration, nil
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the DTLS certificate used
+// for all connections to this server, in SDP form ("sha-256 AB:CD:...")
+func (s *Server) Fingerprint() string {
+	return s.fingerprint
+}
+
 // GetPTY returns the PTY (may be nil if not started yet)
 func (s *Server) GetPTY() *PTY {
 	return s.pty
@@ -208,9 +623,47 @@ func (s *Server) GetPTY() *PTY {
 
 // GetBridge returns the Bridge (may be nil if not connected)
 func (s *Server) GetBridge() *Bridge {
+	s.bridgeMu.Lock()
+	defer s.bridgeMu.Unlock()
 	return s.bridge
 }
 
+// currentPeer returns the client's peer connection, nil if none
+func (s *Server) currentPeer() *ttwebrtc.Peer {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	return s.peer
+}
+
+// setPeer replaces the client's peer connection
+func (s *Server) setPeer(peer *ttwebrtc.Peer) {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	s.peer = peer
+}
+
+// currentChannel returns the client's channel, nil if none
+func (s *Server) currentChannel() *ttwebrtc.EncryptedChannel {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	return s.channel
+}
+
+// setChannel replaces the client's channel
+func (s *Server) setChannel(channel *ttwebrtc.EncryptedChannel) {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	s.channel = channel
+}
+
+// setBridge replaces the bridge; see GetBridge for reading it from other
+// goroutines
+func (s *Server) setBridge(bridge *Bridge) {
+	s.bridgeMu.Lock()
+	defer s.bridgeMu.Unlock()
+	s.bridge = bridge
+}
+
 // StartPTYEarly creates the PTY and bridge immediately (before client connects)
 // This allows the local user to start using the shell while waiting for remote connections.
 // Returns the bridge for setting up local I/O.
@@ -220,7 +673,7 @@ func (s *Server) StartPTYEarly() (*Bridge, error) {
 		return s.bridge, nil
 	}
 
-	pty, err := StartPTY(s.opts.Shell)
+	pty, err := s.startPTY()
 	if err != nil {
 		return nil, fmt.Errorf("failed to start PTY: %w", err)
 	}
@@ -237,7 +690,8 @@ func (s *Server) StartPTYEarly() (*Bridge, error) {
 		if recordPath == "" {
 			recordPath = recording.GenerateRecordingPath(s.sessionID)
 		}
-		rec, err := recording.NewRecorder(recordPath, 80, 24, "Terminal Tunnel Session")
+		rows, cols := s.initialSize()
+		rec, err := recording.NewRecorder(recordPath, int(cols), int(rows), "Terminal Tunnel Session")
 		if err != nil {
 			s.log("⚠ Failed to start recording: %v\n", err)
 		} else {
@@ -248,12 +702,18 @@ func (s *Server) StartPTYEarly() (*Bridge, error) {
 
 	// Create bridge with nil sender (local-only mode initially)
 	bridge := NewBridge(s.pty, nil)
-	s.bridge = bridge
+	bridge.SetReadSize(s.opts.ReadSize)
+	bridge.SetWriteTimeout(s.opts.WriteTimeout)
+	bridge.SetCoalesceWindow(s.opts.CoalesceWindow)
+	bridge.SetBinaryGuard(s.opts.GuardBinary)
+	s.setBridge(bridge)
 
 	// Attach recorder if enabled
 	if s.recorder != nil {
-		bridge.SetRecorder(s.recorder.WriteOutput)
+		bridge.AddRecorder(s.recorder.WriteOutput)
 	}
+	s.attachOutputSink(bridge)
+	bridge.SetExitHandler(s.shellExited)
 
 	// Start the bridge - it will output to localOutput only until client connects
 	bridge.Start()
@@ -291,11 +751,17 @@ func (s *Server) Start(ctx ...context.Context) error {
 		}()
 	}
 
-	// Determine signaling method once
-	sigMethod := s.determineSignalingMethod()
+	// Determine the signaling methods to try once; sigMethod becomes the
+	// one that worked
+	chain := s.signalingChain()
+	sigMethod := chain[0]
 	fmt.Printf("Using signaling method: %s\n", sigMethod)
+	s.diag.recordSetup(sigMethod.String(), s.opts.RelayURL, s.webrtcConfig)
 
 	// Display TURN configuration status
+	if s.opts.DisableStandby {
+		fmt.Printf("⚠ Standby peer disabled (reconnects will be slower)\n")
+	}
 	if !s.webrtcConfig.UseTURN {
 		fmt.Printf("⚠ TURN disabled (may fail with symmetric NAT)\n")
 	} else {
@@ -319,9 +785,9 @@ func (s *Server) Start(ctx ...context.Context) error {
 
 		// Check env-based TURN
 		if !hasTurn {
-			if turnConfig := ttwebrtc.GetTURNFromEnv(); turnConfig != nil {
+			if turnConfigs := ttwebrtc.TURNServersFromEnv(); len(turnConfigs) > 0 {
 				hasTurn = true
-				turnSource = turnConfig.URLs[0]
+				turnSource = turnConfigs[0].URLs[0]
 			}
 		}
 
@@ -340,7 +806,12 @@ func (s *Server) Start(ctx ...context.Context) error {
 
 	isFirstConnection := true
 
+	// A client that joined through the relay pipe, in place of a WebRTC
+	// answer (Options.AllowWSFallback)
+	var pipeChannel *ttwebrtc.EncryptedChannel
+
 	// Connection loop - allows reconnection
+connect:
 	for {
 		var peer *ttwebrtc.Peer
 		var dc *webrtc.DataChannel
@@ -348,7 +819,7 @@ func (s *Server) Start(ctx ...context.Context) error {
 		var err error
 
 		// Check if we have a standby peer ready (for instant reconnection)
-		useStandby := !isFirstConnection && s.standbyPeer != nil && s.standbyDc != nil
+		useStandby := pipeChannel == nil && !isFirstConnection && s.standbyPeer != nil && s.standbyDc != nil
 		standbyFailed := false
 
 		if useStandby {
@@ -358,7 +829,7 @@ func (s *Server) Start(ctx ...context.Context) error {
 
 			peer = s.standbyPeer
 			dc = s.standbyDc
-			s.peer = peer
+			s.setPeer(peer)
 			s.standbyPeer = nil
 			s.standbyDc = nil
 			s.standbyOffer = ""
@@ -366,6 +837,7 @@ func (s *Server) Start(ctx ...context.Context) error {
 			// Set up connection state monitoring on the standby peer
 			peer.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
 				s.log("  [WebRTC] Connection state: %s\n", state.String())
+				s.recordState("peer", state.String())
 				switch state {
 				case webrtc.PeerConnectionStateDisconnected:
 					s.log("\n⚠ WebRTC connection disconnected (may recover)\n")
@@ -380,6 +852,7 @@ func (s *Server) Start(ctx ...context.Context) error {
 
 			peer.OnICEConnectionStateChange(func(state webrtc.ICEConnectionState) {
 				s.log("  [ICE] Connection state: %s\n", state.String())
+				s.recordState("ice", state.String())
 				switch state {
 				case webrtc.ICEConnectionStateDisconnected:
 					s.log("\n⚠ ICE disconnected (checking connectivity...)\n")
@@ -389,37 +862,34 @@ func (s *Server) Start(ctx ...context.Context) error {
 			})
 
 			// Just wait for answer - client already has the correct (standby) offer
-			var reconnCtx context.Context
-			var reconnCancel context.CancelFunc
-			if s.opts.Timeout > 0 {
-				reconnCtx, reconnCancel = context.WithTimeout(s.ctx, s.opts.Timeout)
-			} else {
-				reconnCtx, reconnCancel = context.WithCancel(s.ctx)
-			}
-			answer, err = s.shortCodeClient.WaitForAnswerWithContext(reconnCtx)
-			reconnCancel()
+			// (no timeout - Options.Timeout only covers the first connection)
+			answer, pipeChannel, err = s.waitForClient(s.ctx)
 			if err != nil {
 				if s.ctx.Err() != nil {
 					return s.Stop()
 				}
 				s.log("⚠ Standby reconnection failed: %v, creating new peer\n", err)
 				peer.Close()
-				s.peer = nil
+				s.setPeer(nil)
 				standbyFailed = true
+			} else if pipeChannel != nil {
+				peer.Close()
+				s.setPeer(nil)
 			}
 		}
 
-		if !useStandby || standbyFailed {
+		if pipeChannel == nil && (!useStandby || standbyFailed) {
 			// Create fresh WebRTC peer
 			peer, err = ttwebrtc.NewPeer(s.webrtcConfig)
 			if err != nil {
 				return fmt.Errorf("failed to create peer: %w", err)
 			}
-			s.peer = peer
+			s.setPeer(peer)
 
 			// Monitor connection state for debugging and early disconnect detection
 			peer.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
 				s.log("  [WebRTC] Connection state: %s\n", state.String())
+				s.recordState("peer", state.String())
 				switch state {
 				case webrtc.PeerConnectionStateConnected:
 					// Connection established
@@ -440,6 +910,7 @@ func (s *Server) Start(ctx ...context.Context) error {
 			// Monitor ICE connection state for debugging
 			peer.OnICEConnectionStateChange(func(state webrtc.ICEConnectionState) {
 				s.log("  [ICE] Connection state: %s\n", state.String())
+				s.recordState("ice", state.String())
 				switch state {
 				case webrtc.ICEConnectionStateDisconnected:
 					s.log("\n⚠ ICE disconnected (checking connectivity...)\n")
@@ -461,6 +932,7 @@ func (s *Server) Start(ctx ...context.Context) error {
 			if err != nil {
 				return fmt.Errorf("failed to create offer: %w", err)
 			}
+			s.diag.recordOffer(offer)
 
 			// Get public IP from STUN (for display purposes) - only on first connection
 			if isFirstConnection {
@@ -470,50 +942,38 @@ func (s *Server) Start(ctx ...context.Context) error {
 				}
 			}
 
+			// Gather the first standby while the client is on its way, so the
+			// first reconnect is as fast as later ones
+			if isFirstConnection && sigMethod == signaling.MethodShortCode {
+				s.prewarmStandbyPeer()
+			}
+
 			if isFirstConnection {
 				// First connection - create new session
-				switch sigMethod {
-				case signaling.MethodHTTP:
-					answer, err = s.startHTTPSignaling(offer, saltB64)
-				case signaling.MethodRelay:
-					answer, err = s.startRelaySignaling(offer, saltB64)
-				case signaling.MethodManual:
-					answer, err = s.startManualSignaling(offer)
-				case signaling.MethodShortCode:
-					answer, err = s.startShortCodeSignaling(offer, saltB64)
-				}
+				answer, sigMethod, err = s.runSignalingChain(chain, offer, saltB64)
 			} else {
 				// Reconnection without standby - update session with new offer
 				if sigMethod == signaling.MethodShortCode && s.shortCodeClient != nil {
 					s.log("\n  Waiting for reconnection... (same code: %s)\n\n", s.shortCodeClient.GetCode())
 					err = s.shortCodeClient.UpdateSession(offer, saltB64)
+					if errors.Is(err, signaling.ErrSessionExpired) {
+						// The relay dropped the session - carry on under a new code
+						err = s.renewShortCode(offer, saltB64)
+					}
 					if err != nil {
 						s.log("⚠ Failed to update session: %v\n", err)
 						return err
 					}
 					// Use context for cancellation support
-					var reconnCtx context.Context
-					var reconnCancel context.CancelFunc
-					if s.opts.Timeout > 0 {
-						reconnCtx, reconnCancel = context.WithTimeout(s.ctx, s.opts.Timeout)
-					} else {
-						reconnCtx, reconnCancel = context.WithCancel(s.ctx)
-					}
-					answer, err = s.shortCodeClient.WaitForAnswerWithContext(reconnCtx)
-					reconnCancel()
+					// No timeout - Options.Timeout only covers the first connection
+					answer, pipeChannel, err = s.waitForClient(s.ctx)
 					if err != nil && s.ctx.Err() != nil {
 						return s.Stop()
 					}
 				} else {
-					// For other methods, fall back to creating new session
-					switch sigMethod {
-					case signaling.MethodHTTP:
-						answer, err = s.startHTTPSignaling(offer, saltB64)
-					case signaling.MethodRelay:
-						answer, err = s.startRelaySignaling(offer, saltB64)
-					case signaling.MethodManual:
-						answer, err = s.startManualSignaling(offer)
-					}
+					// For other methods, fall back to creating new session,
+					// starting from the method that worked before
+					answer, sigMethod, err = s.runSignalingChain(chain[slices.Index(chain, sigMethod):], offer, saltB64)
 				}
 			}
 
@@ -521,105 +981,156 @@ func (s *Server) Start(ctx ...context.Context) error {
 				if s.ctx.Err() != nil {
 					return s.Stop()
 				}
+				if errors.Is(err, ErrNoSignaling) && s.callbacks.OnError != nil {
+					s.callbacks.OnError(err)
+				}
 				return err
 			}
 		}
 
-		s.log("✓ Received client answer\n")
-
-		// Set up data channel open handler BEFORE setting remote description
-		// to avoid race condition where channel opens before handler is set
-		dcOpen := make(chan bool, 1)
-		dc.OnOpen(func() {
-			select {
-			case dcOpen <- true:
-			default:
-			}
-		})
+		var channel *ttwebrtc.EncryptedChannel
+		if pipeChannel == nil {
+			s.log("✓ Received client answer\n")
+			s.diag.recordAnswer(answer)
+
+			// Wrap the data channel before it can open, so the client's first
+			// message, which shows the key it encrypts with, isn't missed. The
+			// PBKDF2 key is the fallback for CSP-restricted browsers.
+			channel = ttwebrtc.NewEncryptedChannel(dc, &s.key)
+			channel.SetAltKey(s.pbkdf2Key)
+
+			// Set up data channel open handler BEFORE setting remote description
+			// to avoid race condition where channel opens before handler is set
+			dcOpen := make(chan bool, 1)
+			dc.OnOpen(func() {
+				select {
+				case dcOpen <- true:
+				default:
+				}
+			})
 
-		// Debug: log answer SDP candidates when DEBUG_ICE is set
-		if os.Getenv("DEBUG_ICE") == "1" {
-			s.log("  [Debug] Answer SDP candidates:\n")
-			for _, line := range strings.Split(answer, "\n") {
-				if strings.Contains(line, "candidate") {
-					s.log("    %s\n", strings.TrimSpace(line))
+			// Debug: log answer SDP candidates when DEBUG_ICE is set
+			if os.Getenv("DEBUG_ICE") == "1" {
+				s.log("  [Debug] Answer SDP candidates:\n")
+				for _, line := range strings.Split(answer, "\n") {
+					if strings.Contains(line, "candidate") {
+						s.log("    %s\n", strings.TrimSpace(line))
+					}
 				}
 			}
-		}
 
-		// Set remote description
-		if err := peer.SetRemoteDescription(webrtc.SDPTypeAnswer, answer); err != nil {
-			return fmt.Errorf("failed to set answer: %w", err)
-		}
+			// Set remote description
+			if err := peer.SetRemoteDescription(webrtc.SDPTypeAnswer, answer); err != nil {
+				return fmt.Errorf("failed to set answer: %w", err)
+			}
 
-		// Check if already open (in case OnOpen fired before we got here)
-		if dc.ReadyState() == webrtc.DataChannelStateOpen {
-			select {
-			case dcOpen <- true:
-			default:
+			// Check if already open (in case OnOpen fired before we got here)
+			if dc.ReadyState() == webrtc.DataChannelStateOpen {
+				select {
+				case dcOpen <- true:
+				default:
+				}
 			}
-		}
 
-		// Start watching for new answers during ICE connection phase
-		// This handles the case where client times out and posts a new answer
-		newAnswerDuringICE := make(chan struct{}, 1)
-		stopICEAnswerWatch := make(chan struct{})
-		currentAnswerHash := hashSDP(answer)
-		if s.shortCodeClient != nil {
-			go func() {
-				for {
-					select {
-					case <-stopICEAnswerWatch:
-						return
-					default:
-					}
-					ctx, cancel := context.WithTimeout(s.ctx, 2*time.Second)
-					newAns, err := s.shortCodeClient.WaitForAnswerWithContext(ctx)
-					cancel()
-					if err == nil && newAns != "" && hashSDP(newAns) != currentAnswerHash {
+			// Start watching for new answers during ICE connection phase
+			// This handles the case where client times out and posts a new answer
+			newAnswerDuringICE := make(chan struct{}, 1)
+			stopICEAnswerWatch := make(chan struct{})
+			currentAnswerHash := hashSDP(answer)
+			if s.shortCodeClient != nil {
+				go func() {
+					for {
 						select {
-						case newAnswerDuringICE <- struct{}{}:
+						case <-stopICEAnswerWatch:
+							return
 						default:
 						}
-						return
-					}
-					select {
-					case <-stopICEAnswerWatch:
-						return
-					case <-time.After(500 * time.Millisecond):
+						ctx, cancel := context.WithTimeout(s.ctx, 2*time.Second)
+						newAns, err := s.shortCodeClient.WaitForAnswerWithContext(ctx)
+						cancel()
+						if err == nil && newAns != "" && hashSDP(newAns) != currentAnswerHash {
+							select {
+							case newAnswerDuringICE <- struct{}{}:
+							default:
+							}
+							return
+						}
+						select {
+						case <-stopICEAnswerWatch:
+							return
+						case <-time.After(500 * time.Millisecond):
+						}
 					}
+				}()
+			}
+
+			select {
+			case <-dcOpen:
+				close(stopICEAnswerWatch)
+				s.log("✓ Data channel connected\n")
+				s.diag.recordConnected(peer.SelectedCandidatePair())
+				s.clientConnected(peer)
+			case pipeChannel = <-s.pipeClients:
+				// WebRTC failed on the client's side first
+				close(stopICEAnswerWatch)
+				peer.Close()
+				s.setPeer(nil)
+			case <-newAnswerDuringICE:
+				close(stopICEAnswerWatch)
+				peer.Close()
+				s.setPeer(nil)
+				s.log("  [ICE] Client reconnected with new credentials, restarting...\n")
+				// Mark first connection done so we use the existing session code
+				if isFirstConnection && s.shortCodeClient != nil {
+					isFirstConnection = false
+				}
+				continue // Restart the connection loop with new offer/answer
+			case <-time.After(30 * time.Second):
+				close(stopICEAnswerWatch)
+				peer.Close()
+				s.log("⚠ Connection timeout, waiting for new client...\n")
+				// Mark first connection done so we don't create new session code on retry
+				// The session already exists on relay, we just need to update the offer
+				if isFirstConnection && s.shortCodeClient != nil {
+					isFirstConnection = false
 				}
-			}()
+				continue
+			case <-s.ctx.Done():
+				close(stopICEAnswerWatch)
+				return s.Stop()
+			}
+		}
+		if pipeChannel != nil {
+			channel = pipeChannel
+			pipeChannel = nil
+			peer = nil
+			s.log("✓ Client connected through the relay WebSocket (WebRTC could not connect)\n")
+			s.recordState("peer", "relay-websocket")
+			s.clientSince.Store(time.Now().UnixNano())
 		}
 
-		select {
-		case <-dcOpen:
-			close(stopICEAnswerWatch)
-			s.log("✓ Data channel connected\n")
-		case <-newAnswerDuringICE:
-			close(stopICEAnswerWatch)
-			peer.Close()
-			s.peer = nil
-			s.log("  [ICE] Client reconnected with new credentials, restarting...\n")
-			// Mark first connection done so we use the existing session code
-			if isFirstConnection && s.shortCodeClient != nil {
-				isFirstConnection = false
-			}
-			continue // Restart the connection loop with new offer/answer
-		case <-time.After(30 * time.Second):
-			close(stopICEAnswerWatch)
-			peer.Close()
-			s.log("⚠ Connection timeout, waiting for new client...\n")
-			// Mark first connection done so we don't create new session code on retry
-			// The session already exists on relay, we just need to update the offer
-			if isFirstConnection && s.shortCodeClient != nil {
-				isFirstConnection = false
+		send := bandwidthSend(channel)
+
+		if s.opts.ConfirmClient && !s.clientApproved {
+			if !s.confirmClient(peer, channel) {
+				if s.ctx.Err() != nil {
+					return s.Stop()
+				}
+				if peer != nil {
+					peer.Close()
+					s.setPeer(nil)
+				} else {
+					_ = channel.Close()
+				}
+				s.log("✗ Client rejected, waiting for a new client...\n")
+				if isFirstConnection && s.shortCodeClient != nil {
+					isFirstConnection = false
+				}
+				continue
 			}
-			continue
-		case <-s.ctx.Done():
-			close(stopICEAnswerWatch)
-			return s.Stop()
+			s.clientApproved = true
 		}
+		s.setChannel(channel)
 
 		// Close signaling server - no longer needed
 		if s.signaling != nil {
@@ -629,9 +1140,14 @@ func (s *Server) Start(ctx ...context.Context) error {
 
 		// Start PTY only on first connection
 		if s.pty == nil {
-			pty, err := StartPTY(s.opts.Shell)
+			pty, err := s.startPTY()
 			if err != nil {
-				return fmt.Errorf("failed to start PTY: %w", err)
+				err = fmt.Errorf("failed to start PTY: %w", err)
+				if s.callbacks.OnError != nil {
+					s.callbacks.OnError(err)
+				}
+				_ = s.Stop()
+				return err
 			}
 			s.pty = pty
 
@@ -651,7 +1167,8 @@ func (s *Server) Start(ctx ...context.Context) error {
 					}
 					recordPath = recording.GenerateRecordingPath(code)
 				}
-				rec, err := recording.NewRecorder(recordPath, 80, 24, "Terminal Tunnel Session")
+				rows, cols := s.initialSize()
+				rec, err := recording.NewRecorder(recordPath, int(cols), int(rows), "Terminal Tunnel Session")
 				if err != nil {
 					s.log("⚠ Failed to start recording: %v\n", err)
 				} else {
@@ -669,38 +1186,44 @@ func (s *Server) Start(ctx ...context.Context) error {
 		}
 		s.log("\n")
 
-		// Create encrypted channel with PBKDF2 fallback for CSP-restricted browsers
-		channel := ttwebrtc.NewEncryptedChannel(dc, &s.key)
-		channel.SetAltKey(&s.pbkdf2Key)
-		s.channel = channel
-
 		// Create or resume bridge
 		var bridge *Bridge
+		resume := false
 		if s.bridge != nil && s.bridge.IsPaused() {
-			// Resume paused bridge (from previous disconnection)
+			// Paused bridge from a previous disconnection - resumed below,
+			// once the client has reported its current size
 			bridge = s.bridge
-			bufferedBytes := bridge.Resume(channel.SendData)
-			if bufferedBytes > 0 {
-				s.log("  [Debug] Replayed %d bytes of buffered output\n", bufferedBytes)
-			}
+			resume = true
 		} else if s.bridge != nil {
 			// Bridge already running (started early) - attach WebRTC sender
 			bridge = s.bridge
-			bufferedBytes := bridge.AttachSender(channel.SendData)
+			bufferedBytes := bridge.AttachSender(send)
 			if bufferedBytes > 0 {
 				s.log("  [Debug] Client joined session, replayed %d bytes of history\n", bufferedBytes)
 			}
 		} else {
 			// Create new bridge
-			bridge = NewBridge(s.pty, channel.SendData)
-			s.bridge = bridge
+			bridge = NewBridge(s.pty, send)
+			bridge.SetReadSize(s.opts.ReadSize)
+			bridge.SetWriteTimeout(s.opts.WriteTimeout)
+			bridge.SetCoalesceWindow(s.opts.CoalesceWindow)
+			bridge.SetBinaryGuard(s.opts.GuardBinary)
+			// Attach recorder to bridge if recording is enabled; a
+			// bridge kept from before already has it
+			if s.recorder != nil {
+				bridge.AddRecorder(s.recorder.WriteOutput)
+			}
+			s.attachOutputSink(bridge)
+			bridge.SetExitHandler(s.shellExited)
+			s.setBridge(bridge)
 			bridge.Start()
 		}
 
-		// Attach recorder to bridge if recording is enabled
-		if s.recorder != nil {
-			bridge.SetRecorder(s.recorder.WriteOutput)
-		}
+		// Notify client of zmodem transfers (sz/rz) in the output stream
+		bridge.SetZmodemHandler(func(direction byte) {
+			_ = channel.SendZmodemStart(direction)
+		})
+		s.setBellHandler(bridge, channel)
 
 		// Invoke bridge ready callback for interactive mode
 		if s.callbacks.OnBridgeReady != nil {
@@ -709,11 +1232,23 @@ func (s *Server) Start(ctx ...context.Context) error {
 
 		// Handle incoming data
 		channel.OnData(func(data []byte) {
-			bridge.HandleData(data)
+			s.handleInput(bridge, channel, data)
 		})
 
+		sized := make(chan struct{}, 1)
 		channel.OnResize(func(rows, cols uint16) {
 			bridge.HandleResize(rows, cols)
+			signalSized(sized)
+		})
+
+		// Client pings carry its dimensions - re-apply if the PTY has drifted
+		channel.OnSizeSync(func(rows, cols uint16) {
+			s.syncPTYSize(bridge, rows, cols)
+			signalSized(sized)
+		})
+
+		channel.OnReset(func() {
+			s.resetTerminal(bridge)
 		})
 
 		channel.OnClose(func() {
@@ -733,9 +1268,18 @@ func (s *Server) Start(ctx ...context.Context) error {
 			}
 		})
 
-		// Brief delay to receive client's initial ping (for encryption key detection)
-		// Client sends ping immediately on connection to signal which key it uses
-		time.Sleep(100 * time.Millisecond)
+		// The client's first message shows which key it encrypts with
+		if !channel.WaitForKey(clientKeyTimeout) {
+			s.log("  [Debug] No message from client in %v, assuming the primary key\n", clientKeyTimeout)
+		}
+
+		// Offer our protocol extensions; the client's own capabilities are
+		// answered by the channel, so a lost offer is harmless
+		_ = channel.SendCapabilities()
+
+		if resume {
+			s.resumeBridge(bridge, channel, send, sized)
+		}
 
 		// Start bridge (PTY -> channel)
 		s.log("  [Debug] Starting bridge\n")
@@ -748,6 +1292,7 @@ func (s *Server) Start(ctx ...context.Context) error {
 		// Start relay heartbeat on first connection (keeps session alive on relay)
 		if isFirstConnection {
 			s.startRelayHeartbeat()
+			s.startStatsCollector()
 		}
 
 		isFirstConnection = false
@@ -761,173 +1306,227 @@ func (s *Server) Start(ctx ...context.Context) error {
 		// Start answer watcher to detect client reconnection (fast reconnect)
 		s.startAnswerWatcher()
 
-		// Wait for disconnection, keepalive timeout, new answer, or termination
-		select {
-		case <-s.disconnected:
-			// Client disconnected, clean up and wait for reconnection
-			s.stopAnswerWatcher()
-			s.cleanupConnection()
-			// Drain any stale disconnected signals (cleanup itself can trigger OnClose)
-			select {
-			case <-s.disconnected:
-			default:
-			}
-			// Delay before accepting reconnection to avoid race condition
-			// where client reconnects with stale offer (must be longer than client's reconnect delay)
-			time.Sleep(3 * time.Second)
-			continue
-		case <-keepaliveTimeout:
-			// Keepalive timed out - no pong received within timeout
-			s.log("\n⚠ Connection timed out (no response from client)\n")
-			s.stopAnswerWatcher()
-			s.cleanupConnection()
-			// Drain any stale disconnected signals
+		// Wait for disconnection, keepalive timeout, new answer, or
+		// termination; only moving a client off the relay pipe keeps waiting
+		for {
 			select {
 			case <-s.disconnected:
-			default:
-			}
-			time.Sleep(3 * time.Second)
-			continue
-		case receivedAnswer := <-s.newAnswer:
-			// New answer received while connected - client is reconnecting (e.g., page refresh)
-			// With standby peer pattern, this answer IS for the standby offer!
-			// Use it directly for instant reconnection.
-			s.log("\n✓ Client reconnection detected (instant reconnect with standby peer)\n")
-			s.stopAnswerWatcher()
-
-			// Check if we have a standby peer ready
-			if s.standbyPeer != nil && s.standbyDc != nil && receivedAnswer != "" {
-				// Use standby peer directly with the received answer
-				standbyPeer := s.standbyPeer
-				standbyDc := s.standbyDc
-				s.standbyPeer = nil
-				s.standbyDc = nil
-				s.standbyOffer = ""
-
-				// Clean up current connection
+				// Client disconnected, clean up and wait for reconnection
+				s.reconnects.Add(1)
+				s.stopAnswerWatcher()
 				s.cleanupConnection()
-
-				// Set remote description on standby peer
-				if err := standbyPeer.SetRemoteDescription(webrtc.SDPTypeAnswer, receivedAnswer); err != nil {
-					s.log("⚠ Failed to set answer on standby peer: %v\n", err)
-					standbyPeer.Close()
+				// Drain any stale disconnected signals (cleanup itself can trigger OnClose)
+				select {
+				case <-s.disconnected:
+				default:
+				}
+				s.waitReconnectGrace()
+				continue connect
+			case <-keepaliveTimeout:
+				// Keepalive timed out - no pong received within timeout
+				s.log("\n⚠ Connection timed out (no response from client)\n")
+				s.reconnects.Add(1)
+				s.stopAnswerWatcher()
+				s.cleanupConnection()
+				// Drain any stale disconnected signals
+				select {
+				case <-s.disconnected:
+				default:
+				}
+				s.waitReconnectGrace()
+				continue connect
+			case receivedAnswer := <-s.newAnswer:
+				if s.channel != nil && s.channel.Label() == ttwebrtc.WebSocketLabel && s.channel.Ready() {
+					// The client on the relay pipe is trying WebRTC again
+					// through the standby offer, not reconnecting
+					s.stopAnswerWatcher()
+					s.upgradePipe(receivedAnswer)
+					if err := s.createStandbyPeer(); err != nil {
+						s.log("  [Debug] Standby peer creation failed: %v\n", err)
+					}
+					s.startAnswerWatcher()
 					continue
 				}
 
-				// Set up connection state monitoring
-				standbyPeer.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
-					s.log("  [WebRTC] Connection state: %s\n", state.String())
-					switch state {
-					case webrtc.PeerConnectionStateDisconnected:
-						s.log("\n⚠ WebRTC connection disconnected (may recover)\n")
-					case webrtc.PeerConnectionStateFailed:
-						s.log("\n✗ WebRTC connection failed\n")
-						select {
-						case s.disconnected <- true:
-						default:
-						}
+				// New answer received while connected - client is reconnecting (e.g., page refresh)
+				// With standby peer pattern, this answer IS for the standby offer!
+				// Use it directly for instant reconnection.
+				s.log("\n✓ Client reconnection detected (instant reconnect with standby peer)\n")
+				s.reconnects.Add(1)
+				s.stopAnswerWatcher()
+
+				// Check if we have a standby peer ready
+				if s.standbyPeer != nil && s.standbyDc != nil && receivedAnswer != "" {
+					// Use standby peer directly with the received answer
+					standbyPeer := s.standbyPeer
+					standbyDc := s.standbyDc
+					s.standbyPeer = nil
+					s.standbyDc = nil
+					s.standbyOffer = ""
+
+					// Clean up current connection
+					s.cleanupConnection()
+
+					// Wrap the data channel before it can open (see above)
+					channel := ttwebrtc.NewEncryptedChannel(standbyDc, &s.key)
+					channel.SetAltKey(s.pbkdf2Key)
+
+					// Set remote description on standby peer
+					s.diag.recordAnswer(receivedAnswer)
+					if err := standbyPeer.SetRemoteDescription(webrtc.SDPTypeAnswer, receivedAnswer); err != nil {
+						s.log("⚠ Failed to set answer on standby peer: %v\n", err)
+						standbyPeer.Close()
+						continue connect
 					}
-				})
 
-				standbyPeer.OnICEConnectionStateChange(func(state webrtc.ICEConnectionState) {
-					s.log("  [ICE] Connection state: %s\n", state.String())
-				})
+					// Set up connection state monitoring
+					standbyPeer.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+						s.log("  [WebRTC] Connection state: %s\n", state.String())
+						s.recordState("peer", state.String())
+						switch state {
+						case webrtc.PeerConnectionStateDisconnected:
+							s.log("\n⚠ WebRTC connection disconnected (may recover)\n")
+						case webrtc.PeerConnectionStateFailed:
+							s.log("\n✗ WebRTC connection failed\n")
+							select {
+							case s.disconnected <- true:
+							default:
+							}
+						}
+					})
 
-				// Wait for data channel to open
-				dcOpen := make(chan bool, 1)
-				standbyDc.OnOpen(func() {
-					dcOpen <- true
-				})
+					standbyPeer.OnICEConnectionStateChange(func(state webrtc.ICEConnectionState) {
+						s.log("  [ICE] Connection state: %s\n", state.String())
+						s.recordState("ice", state.String())
+					})
 
-				select {
-				case <-dcOpen:
-					s.log("✓ Data channel connected (instant reconnect)\n")
-				case <-time.After(30 * time.Second):
-					// Use 30s timeout to allow TURN relay connectivity checks on mobile
-					standbyPeer.Close()
-					s.log("⚠ Standby connection timeout\n")
-					continue
-				case <-s.ctx.Done():
-					return s.Stop()
-				}
+					// Wait for data channel to open
+					dcOpen := make(chan bool, 1)
+					standbyDc.OnOpen(func() {
+						dcOpen <- true
+					})
 
-				// Connection successful - set as active peer
-				s.peer = standbyPeer
+					select {
+					case <-dcOpen:
+						s.log("✓ Data channel connected (instant reconnect)\n")
+						s.diag.recordConnected(standbyPeer.SelectedCandidatePair())
+						s.clientConnected(standbyPeer)
+					case <-time.After(30 * time.Second):
+						// Use 30s timeout to allow TURN relay connectivity checks on mobile
+						standbyPeer.Close()
+						s.log("⚠ Standby connection timeout\n")
+						continue connect
+					case <-s.ctx.Done():
+						return s.Stop()
+					}
 
-				// Drain any stale disconnected signals
-				select {
-				case <-s.disconnected:
-				default:
-				}
+					// Connection successful - set as active peer
+					s.setPeer(standbyPeer)
 
-				// Now we need to set up the rest of the connection (encrypted channel, bridge, etc.)
-				// Jump to the post-connection setup by going to a labeled section
-				// For simplicity, let's inline the critical parts here
+					// Drain any stale disconnected signals
+					select {
+					case <-s.disconnected:
+					default:
+					}
 
-				// Create encrypted channel
-				channel := ttwebrtc.NewEncryptedChannel(standbyDc, &s.key)
-				channel.SetAltKey(&s.pbkdf2Key)
-				s.channel = channel
+					// Now we need to set up the rest of the connection (encrypted channel, bridge, etc.)
+					// Jump to the post-connection setup by going to a labeled section
+					// For simplicity, let's inline the critical parts here
+
+					send := bandwidthSend(channel)
+					s.setChannel(channel)
+
+					s.bridge.SetZmodemHandler(func(direction byte) {
+						_ = channel.SendZmodemStart(direction)
+					})
+					s.setBellHandler(s.bridge, channel)
+
+					// Handle incoming data
+					channel.OnData(func(data []byte) {
+						s.handleInput(s.bridge, channel, data)
+					})
+
+					sized := make(chan struct{}, 1)
+					channel.OnResize(func(rows, cols uint16) {
+						s.bridge.HandleResize(rows, cols)
+						signalSized(sized)
+					})
+
+					channel.OnSizeSync(func(rows, cols uint16) {
+						s.syncPTYSize(s.bridge, rows, cols)
+						signalSized(sized)
+					})
+
+					channel.OnReset(func() {
+						s.resetTerminal(s.bridge)
+					})
+
+					channel.OnClose(func() {
+						s.log("\n✓ Client disconnected (data channel closed)\n")
+						if s.callbacks.OnClientDisconnect != nil {
+							s.callbacks.OnClientDisconnect()
+						}
+						select {
+						case s.disconnected <- true:
+						default:
+						}
+					})
 
-				// Resume bridge
-				if s.bridge != nil && s.bridge.IsPaused() {
-					bufferedBytes := s.bridge.Resume(channel.SendData)
-					if bufferedBytes > 0 {
-						s.log("  [Debug] Replayed %d bytes of buffered output\n", bufferedBytes)
+					if !channel.WaitForKey(clientKeyTimeout) {
+						s.log("  [Debug] No message from client in %v, assuming the primary key\n", clientKeyTimeout)
 					}
-				}
+					_ = channel.SendCapabilities()
 
-				// Handle incoming data
-				channel.OnData(func(data []byte) {
-					s.bridge.HandleData(data)
-				})
+					// Resume bridge
+					if s.bridge != nil && s.bridge.IsPaused() {
+						s.resumeBridge(s.bridge, channel, send, sized)
+					}
 
-				channel.OnResize(func(rows, cols uint16) {
-					s.bridge.HandleResize(rows, cols)
-				})
+					// Start keepalive
+					keepaliveTimeout = channel.StartKeepalive()
 
-				channel.OnClose(func() {
-					s.log("\n✓ Client disconnected (data channel closed)\n")
-					if s.callbacks.OnClientDisconnect != nil {
-						s.callbacks.OnClientDisconnect()
+					// Invoke client connect callback
+					if s.callbacks.OnClientConnect != nil {
+						s.callbacks.OnClientConnect()
 					}
-					select {
-					case s.disconnected <- true:
-					default:
+
+					// Create new standby peer for next reconnection
+					if err := s.createStandbyPeer(); err != nil {
+						s.log("  [Debug] Standby peer creation failed: %v\n", err)
 					}
-				})
 
-				// Start keepalive
-				keepaliveTimeout = channel.StartKeepalive()
+					// Start answer watcher again
+					s.startAnswerWatcher()
 
-				// Invoke client connect callback
-				if s.callbacks.OnClientConnect != nil {
-					s.callbacks.OnClientConnect()
+					// Continue waiting for next disconnect
+					continue connect
 				}
 
-				// Create new standby peer for next reconnection
-				if err := s.createStandbyPeer(); err != nil {
-					s.log("  [Debug] Standby peer creation failed: %v\n", err)
+				// No standby peer - fall back to normal reconnection
+				s.cleanupConnection()
+				select {
+				case <-s.disconnected:
+				default:
 				}
+				time.Sleep(100 * time.Millisecond)
+				continue connect
+
+			case pipeChannel = <-s.pipeClients:
+				// The client came back through the relay pipe before its old
+				// connection was noticed as gone
+				s.log("\n✓ Client reconnecting through the relay WebSocket\n")
+				s.reconnects.Add(1)
+				s.stopAnswerWatcher()
+				s.cleanupConnection()
+				select {
+				case <-s.disconnected:
+				default:
+				}
+				continue connect
 
-				// Start answer watcher again
-				s.startAnswerWatcher()
-
-				// Continue waiting for next disconnect
-				continue
-			}
-
-			// No standby peer - fall back to normal reconnection
-			s.cleanupConnection()
-			select {
-			case <-s.disconnected:
-			default:
+			case <-s.ctx.Done():
+				return s.Stop()
 			}
-			time.Sleep(100 * time.Millisecond)
-			continue
-
-		case <-s.ctx.Done():
-			return s.Stop()
 		}
 	}
 }
@@ -941,31 +1540,238 @@ func (s *Server) cleanupConnection() {
 		s.bridge.Pause()            // Switch to buffering mode (keeps reading from PTY)
 		// Don't set bridge to nil - we'll resume it on reconnect
 	}
+	s.clientSince.Store(0)
 	if s.channel != nil {
 		s.channel.StopKeepalive() // Stop keepalive before closing
 		s.channel.Close()
-		s.channel = nil
-	}
-	if s.viewerChannel != nil {
-		s.viewerChannel.Close()
-		s.viewerChannel = nil
+		s.setChannel(nil)
 	}
+	// Viewers watch through the client's connection, but can join again
+	// while it reconnects
+	s.closeViewers(false)
 	if s.peer != nil {
 		s.peer.Close()
-		s.peer = nil
-	}
-	if s.viewerPeer != nil {
-		s.viewerPeer.Close()
-		s.viewerPeer = nil
+		s.setPeer(nil)
 	}
 	s.log("  [Debug] cleanupConnection complete\n")
 }
 
+// clientApprovalNotice is shown to a client while the host decides
+var clientApprovalNotice = []byte("\r\n\x1b[33m[tt] Waiting for the host to approve this connection...\x1b[0m\r\n")
+
+// confirmClient asks the host through OnClientConnectRequest whether to let
+// a client in. The client is told it is waiting and, if rejected, why the
+// channel closes. Without the callback the client is approved. peer is nil
+// for a client on the relay pipe.
+func (s *Server) confirmClient(peer *ttwebrtc.Peer, channel *ttwebrtc.EncryptedChannel) bool {
+	if s.callbacks.OnClientConnectRequest == nil {
+		return true
+	}
+
+	// Tell the client it is waiting under the key it uses
+	channel.WaitForKey(clientKeyTimeout)
+	_ = channel.SendData(clientApprovalNotice)
+
+	req := ClientConnectRequest{CandidatePair: ttwebrtc.WebSocketLabel}
+	if peer != nil {
+		req.CandidatePair = peer.SelectedCandidatePair()
+	}
+	approved := make(chan bool, 1)
+	go func() {
+		approved <- s.callbacks.OnClientConnectRequest(req)
+	}()
+
+	select {
+	case ok := <-approved:
+		if !ok {
+			_ = channel.SendCloseWithCode(protocol.CloseRejected, "connection rejected by host")
+			time.Sleep(100 * time.Millisecond) // Let the close message go out
+		}
+		return ok
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// waitReconnectGrace waits out Options.ReconnectGrace after a disconnect,
+// unless a standby peer is ready or the server is stopping
+func (s *Server) waitReconnectGrace() {
+	if s.standbyPeer != nil {
+		return
+	}
+	grace := s.opts.ReconnectGrace
+	if grace == 0 {
+		grace = defaultReconnectGrace
+	}
+	if grace < 0 {
+		return
+	}
+	select {
+	case <-time.After(grace):
+	case <-s.ctx.Done():
+	}
+}
+
+// watchClientInterest reports through OnClientAttempting each time a client
+// fetches the offer for code, if the signaler can tell, in place of any
+// watch on an earlier code. It is best effort: a relay without WebSocket
+// support just never reports.
+func (s *Server) watchClientInterest(code string) {
+	watcher, ok := s.shortCodeClient.(clientInterestWatcher)
+	onAttempting := s.callbacks.OnClientAttempting
+	if !ok || onAttempting == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.interestMu.Lock()
+	if s.stopInterest != nil {
+		s.stopInterest()
+	}
+	s.stopInterest = cancel
+	s.interestMu.Unlock()
+
+	go func() {
+		_ = watcher.WatchClientInterest(ctx, code, onAttempting)
+	}()
+}
+
+// renewShortCode registers a new relay session with the given offer after
+// the relay dropped the current one (e.g. it expired), and reports the new
+// code through OnShortCodeReady. A public viewer link is not renewed.
+func (s *Server) renewShortCode(offer, saltB64 string) error {
+	oldCode := s.shortCodeClient.GetCode()
+	code, err := s.shortCodeClient.CreateSession(offer, saltB64)
+	if err != nil {
+		return fmt.Errorf("relay dropped session %s and creating a new one failed: %w", oldCode, err)
+	}
+
+	s.watchClientInterest(code)
+
+	clientURL := s.shortCodeClient.GetClientURL()
+	s.log("⚠ Relay dropped session %s - new code: %s\n", oldCode, code)
+	s.log("  %s\n\n", clientURL)
+	if s.callbacks.OnShortCodeReady != nil {
+		s.callbacks.OnShortCodeReady(code, clientURL)
+	}
+	return nil
+}
+
+// startPTY starts the configured command, or the shell if none is set
+func (s *Server) startPTY() (*PTY, error) {
+	rows, cols := s.initialSize()
+	if len(s.opts.ContainerRuntime) > 0 {
+		// The host's shell may not exist in the image, so it is not appended
+		args := append(append([]string(nil), s.opts.ContainerRuntime...), s.opts.Command...)
+		return StartCommandSize(args, rows, cols)
+	}
+	if s.opts.Isolate {
+		return StartIsolatedSize(s.opts.Shell, s.opts.Command, rows, cols)
+	}
+	if len(s.opts.Command) > 0 {
+		return StartCommandSize(s.opts.Command, rows, cols)
+	}
+	return StartPTYSize(s.opts.Shell, rows, cols)
+}
+
+// initialSize returns the size the PTY starts at
+func (s *Server) initialSize() (rows, cols uint16) {
+	rows, cols = s.opts.Rows, s.opts.Cols
+	if rows == 0 {
+		rows = DefaultRows
+	}
+	if cols == 0 {
+		cols = DefaultCols
+	}
+	return rows, cols
+}
+
+// setBellHandler forwards the bridge's terminal bells to the client when
+// BellNotify is set and the client negotiated FeatureBell
+func (s *Server) setBellHandler(bridge *Bridge, channel *ttwebrtc.EncryptedChannel) {
+	if !s.opts.BellNotify {
+		return
+	}
+	bridge.SetBellHandler(func() {
+		if caps, ok := channel.Capabilities(); ok && caps.Has(protocol.FeatureBell) {
+			_ = channel.SendBell()
+		}
+	})
+}
+
+// readOnlyWarning is shown to a client that types into a read-only session,
+// at most once per readOnlyWarningInterval
+var readOnlyWarning = []byte("\r\n\x1b[33m[tt] This session is read-only - input dropped.\x1b[0m\r\n")
+
+const readOnlyWarningInterval = 30 * time.Second
+
+// handleInput writes client input to the PTY. If the shell has stopped
+// reading, the client is told its input is being dropped rather than the
+// data channel's receive path blocking behind the write.
+func (s *Server) handleInput(bridge *Bridge, channel *ttwebrtc.EncryptedChannel, data []byte) {
+	if s.opts.ReadOnly {
+		now := time.Now().UnixNano()
+		if last := s.readOnlyNotice.Load(); now-last >= int64(readOnlyWarningInterval) && s.readOnlyNotice.CompareAndSwap(last, now) {
+			_ = channel.SendData(readOnlyWarning)
+		}
+		return
+	}
+	if err := bridge.HandleData(data); errors.Is(err, ErrWriteTimeout) {
+		s.log("  [Debug] %v\n", err)
+		_ = channel.SendData(inputStalledWarning)
+	}
+}
+
+// resumeBridge replays a paused bridge's buffered output to a reconnected
+// client. The client's window may have been resized (or the phone rotated)
+// while disconnected, so it first asks for the current size and applies it,
+// letting the replay and the app's redraw render at the right dimensions.
+// sized is signalled by the channel's resize handlers, and send delivers
+// output to the channel.
+func (s *Server) resumeBridge(bridge *Bridge, channel *ttwebrtc.EncryptedChannel, send func([]byte) error, sized <-chan struct{}) {
+	if err := channel.SendSizeRequest(); err == nil {
+		select {
+		case <-sized:
+			// Give the foreground app a moment to redraw after SIGWINCH
+			time.Sleep(resizeSettleDelay)
+		case <-time.After(clientSizeTimeout):
+			s.log("  [Debug] No size from client, replaying at current size\n")
+		}
+	}
+
+	bufferedBytes := bridge.Resume(send)
+	if bufferedBytes > 0 {
+		s.log("  [Debug] Replayed %d bytes of buffered output\n", bufferedBytes)
+	}
+}
+
+// signalSized records that the client reported its size, without blocking
+func signalSized(sized chan<- struct{}) {
+	select {
+	case sized <- struct{}{}:
+	default:
+	}
+}
+
+// syncPTYSize corrects PTY size drift reported by a client heartbeat
+func (s *Server) syncPTYSize(bridge *Bridge, rows, cols uint16) {
+	if bridge == nil {
+		return
+	}
+	resized, err := bridge.SyncSize(rows, cols)
+	if err != nil {
+		return
+	}
+	if resized {
+		s.log("  [Debug] Corrected PTY size drift to %dx%d\n", cols, rows)
+	}
+}
+
 // createStandbyPeer creates a standby peer for instant reconnection
 // This should be called after connection is established
 // The standby offer is immediately uploaded to relay, so clients always get fresh offers
 func (s *Server) createStandbyPeer() error {
-	if s.shortCodeClient == nil {
+	if s.shortCodeClient == nil || s.opts.DisableStandby {
 		return nil // Only works with relay signaling
 	}
 
@@ -977,30 +1783,27 @@ func (s *Server) createStandbyPeer() error {
 		s.standbyOffer = ""
 	}
 
-	// Create new WebRTC peer for standby
-	peer, err := ttwebrtc.NewPeer(s.webrtcConfig)
-	if err != nil {
-		return fmt.Errorf("failed to create standby peer: %w", err)
-	}
-
-	// Create data channel
-	dc, err := peer.CreateDataChannel("terminal")
-	if err != nil {
-		peer.Close()
-		return fmt.Errorf("failed to create standby data channel: %w", err)
+	// Use the prewarmed peer if there is one: gathering started before the
+	// first client connected, so it is ready or close to it
+	var prepared *preparedPeer
+	if s.prewarmed != nil {
+		prepared = <-s.prewarmed
+		s.prewarmed = nil
 	}
-
-	// Create SDP offer
-	offer, err := peer.CreateOffer()
-	if err != nil {
-		peer.Close()
-		return fmt.Errorf("failed to create standby offer: %w", err)
+	if prepared == nil {
+		var err error
+		prepared, err = s.preparePeer()
+		if err != nil {
+			return err
+		}
 	}
+	offer := prepared.offer
 
 	// Store standby peer
-	s.standbyPeer = peer
-	s.standbyDc = dc
+	s.standbyPeer = prepared.peer
+	s.standbyDc = prepared.dc
 	s.standbyOffer = offer
+	s.diag.recordOffer(offer)
 
 	// Update relay with standby offer - this is the KEY to eliminating race conditions
 	// Client will always get this fresh, unused offer
@@ -1019,6 +1822,58 @@ func (s *Server) createStandbyPeer() error {
 	return nil
 }
 
+// preparedPeer is a peer with its data channel and gathered offer, ready to
+// be uploaded as the standby
+type preparedPeer struct {
+	peer  *ttwebrtc.Peer
+	dc    *webrtc.DataChannel
+	offer string
+}
+
+// preparePeer creates a standby peer and gathers its offer. This is the slow
+// part of creating a standby: it waits for STUN (and TURN) candidates.
+func (s *Server) preparePeer() (*preparedPeer, error) {
+	// Create new WebRTC peer for standby
+	peer, err := ttwebrtc.NewPeer(s.webrtcConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create standby peer: %w", err)
+	}
+
+	// Create data channel
+	dc, err := peer.CreateDataChannel("terminal")
+	if err != nil {
+		peer.Close()
+		return nil, fmt.Errorf("failed to create standby data channel: %w", err)
+	}
+
+	// Create SDP offer
+	offer, err := peer.CreateOffer()
+	if err != nil {
+		peer.Close()
+		return nil, fmt.Errorf("failed to create standby offer: %w", err)
+	}
+	return &preparedPeer{peer: peer, dc: dc, offer: offer}, nil
+}
+
+// prewarmStandbyPeer starts preparing the first standby peer in the
+// background; createStandbyPeer picks it up. Without it the first standby is
+// gathered only after the client connects, and a drop in that window (such
+// as a page refresh) misses it and waits for a fresh peer instead.
+func (s *Server) prewarmStandbyPeer() {
+	if s.prewarmed != nil || s.opts.DisableStandby {
+		return
+	}
+	prewarmed := make(chan *preparedPeer, 1)
+	s.prewarmed = prewarmed
+	go func() {
+		prepared, err := s.preparePeer()
+		if err != nil {
+			s.log("  [Debug] Standby prewarm failed: %v\n", err)
+		}
+		prewarmed <- prepared
+	}()
+}
+
 // promoteStandbyPeer promotes the standby peer to become the active peer
 // Returns true if standby was available and promoted, false otherwise
 func (s *Server) promoteStandbyPeer() bool {
@@ -1027,7 +1882,7 @@ func (s *Server) promoteStandbyPeer() bool {
 	}
 
 	// Promote standby to active
-	s.peer = s.standbyPeer
+	s.setPeer(s.standbyPeer)
 	s.standbyPeer = nil
 	s.standbyDc = nil
 	s.standbyOffer = ""
@@ -1038,36 +1893,70 @@ func (s *Server) promoteStandbyPeer() bool {
 
 // Stop gracefully shuts down the server
 func (s *Server) Stop() error {
+	return s.stop(false)
+}
+
+// Detach stops the server like Stop but leaves the shell running, and
+// returns its PTY for another process to adopt (see AdoptPTY). Clients are
+// disconnected and the code is deleted from the relay, so the adopting
+// process signals under a new code.
+func (s *Server) Detach() (*PTY, error) {
+	pty := s.pty
+	if pty == nil {
+		return nil, ErrNoTerminal
+	}
+	if pty.File() == nil {
+		return nil, ErrHandoffUnsupported
+	}
+	return pty, s.stop(true)
+}
+
+// stop shuts the server down, closing the PTY unless keepPTY is set
+func (s *Server) stop(keepPTY bool) error {
 	s.stopRelayHeartbeat()
 	s.stopAnswerWatcher()
 	if s.bridge != nil {
-		s.bridge.Close()
+		if keepPTY {
+			// Nothing may read the PTY once it is handed over
+			s.bridge.CloseWithoutPTY()
+			s.bridge.WaitForExit(time.Second)
+		} else {
+			s.bridge.Close()
+		}
 	}
 	if s.channel != nil {
 		s.channel.Close()
 	}
-	if s.viewerChannel != nil {
-		s.viewerChannel.Close()
-	}
-	if s.pty != nil {
+	s.closeViewers(true)
+	if s.pty != nil && !keepPTY {
 		s.pty.Close()
 	}
 	if s.signaling != nil {
 		s.signaling.Close()
 	}
+	if s.lan != nil {
+		_ = s.lan.Close()
+	}
 	if s.relayClient != nil {
 		s.relayClient.Close()
 	}
+	s.DeleteRelaySession()
 	if s.peer != nil {
 		s.peer.Close()
 	}
-	if s.viewerPeer != nil {
-		_ = s.viewerPeer.Close()
-	}
 	if s.standbyPeer != nil {
 		_ = s.standbyPeer.Close()
 		s.standbyPeer = nil
 	}
+	if s.prewarmed != nil {
+		// Close the prewarmed peer once its gathering finishes
+		go func(prewarmed chan *preparedPeer) {
+			if prepared := <-prewarmed; prepared != nil {
+				_ = prepared.peer.Close()
+			}
+		}(s.prewarmed)
+		s.prewarmed = nil
+	}
 	if s.upnpClose != nil {
 		s.upnpClose()
 	}
@@ -1078,37 +1967,140 @@ func (s *Server) Stop() error {
 		_ = s.recorder.Close()
 		s.log("✓ Recording saved: %s (duration: %v)\n", path, duration.Round(time.Second))
 	}
+	if s.outputSink != nil {
+		if err := s.outputSink.Close(); err != nil {
+			s.log("⚠ %v\n", err)
+		}
+		if dropped := s.outputSink.Dropped(); dropped > 0 {
+			s.log("⚠ Output sink %s missed %d bytes of output\n", s.outputSink, dropped)
+		}
+	}
 	return nil
 }
 
+// attachOutputSink tees bridge output to Options.OutputSink, opening it on
+// first use. The sink never holds up the session: output it can't keep up
+// with is dropped.
+func (s *Server) attachOutputSink(bridge *Bridge) {
+	if s.opts.OutputSink == "" {
+		return
+	}
+	if s.outputSink == nil {
+		code := s.sessionID
+		if s.shortCodeClient != nil {
+			code = s.shortCodeClient.GetCode()
+		}
+		out, err := sink.Open(s.opts.OutputSink, code)
+		if err != nil {
+			s.log("⚠ Failed to start output sink: %v\n", err)
+			return
+		}
+		out.OnError(func(err error) {
+			s.log("⚠ %v (output is dropped until it recovers)\n", err)
+		})
+		s.outputSink = out
+		s.log("✓ Streaming output to: %s\n", out)
+	}
+	bridge.AddRecorder(s.outputSink.Write)
+}
+
+// NotifyShutdown tells the connected client and viewers that the session is
+// ending and why, so they can show it and stop reconnecting. Best effort.
+// Returns false if no one was connected to tell.
+func (s *Server) NotifyShutdown(code protocol.CloseCode, reason string) bool {
+	notified := false
+	if ch := s.currentChannel(); ch != nil {
+		_ = ch.SendCloseWithCode(code, reason)
+		notified = true
+	}
+	for _, channel := range s.viewerChannels() {
+		_ = channel.SendCloseWithCode(code, reason)
+		notified = true
+	}
+	return notified
+}
+
+// shellExited tells the client and viewers the shell is gone, once the
+// bridge finds the PTY closed
+func (s *Server) shellExited() {
+	s.log("✓ Shell exited\n")
+	s.NotifyShutdown(protocol.CloseShellExited, "the shell exited")
+}
+
+// DeleteRelaySession removes the short code session from the relay so the
+// code is invalidated immediately. Safe to call more than once.
+func (s *Server) DeleteRelaySession() {
+	if s.shortCodeClient == nil || s.shortCodeClient.GetCode() == "" {
+		return
+	}
+	s.relayDeleteOnce.Do(func() {
+		if err := s.shortCodeClient.DeleteSession(); err != nil {
+			s.log("⚠ Failed to delete relay session: %v\n", err)
+		}
+	})
+}
+
 // startRelayHeartbeat starts a goroutine to periodically send heartbeats to keep the relay session alive
 func (s *Server) startRelayHeartbeat() {
 	if s.shortCodeClient == nil {
 		return
 	}
 
-	s.heartbeatStop = make(chan struct{})
+	// The goroutine keeps its own copy: stopRelayHeartbeat clears the field
+	stop := make(chan struct{})
+	s.heartbeatStop = stop
 
 	go func() {
 		ticker := time.NewTicker(relayHeartbeatInterval)
 		defer ticker.Stop()
 
+		failures := 0 // Heartbeats failed in a row
 		for {
 			select {
-			case <-s.heartbeatStop:
+			case <-stop:
 				return
 			case <-s.ctx.Done():
 				return
 			case <-ticker.C:
-				if err := s.shortCodeClient.SendHeartbeat(); err != nil {
-					// Log but don't fail - session might still work
+				// Report but don't fail - session might still work. A relay
+				// that looks down was already reported by relayStateChanged.
+				err := s.shortCodeClient.SendHeartbeat()
+				switch {
+				case errors.Is(err, signaling.ErrRelayDown):
+				case err != nil:
+					failures++
 					s.log("⚠ Relay heartbeat failed: %v\n", err)
+					s.heartbeatFailed(failures, err)
+				case failures > 0:
+					failures = 0
+					s.log("✓ Relay heartbeat succeeded again\n")
+					s.heartbeatFailed(0, nil)
 				}
 			}
 		}
 	}()
 }
 
+// relayStateChanged reports the relay going down or coming back
+func (s *Server) relayStateChanged(down bool) {
+	if down {
+		s.log("⚠ Relay appears down after repeated failures; pausing requests and checking it periodically\n")
+	} else {
+		s.log("✓ Relay is reachable again\n")
+	}
+	if s.callbacks.OnRelayStateChange != nil {
+		s.callbacks.OnRelayStateChange(down)
+	}
+}
+
+// heartbeatFailed reports failed relay heartbeats, or 0 and nil once they
+// succeed again
+func (s *Server) heartbeatFailed(failures int, err error) {
+	if s.callbacks.OnHeartbeatFailure != nil {
+		s.callbacks.OnHeartbeatFailure(failures, err)
+	}
+}
+
 // stopRelayHeartbeat stops the relay heartbeat goroutine
 func (s *Server) stopRelayHeartbeat() {
 	if s.heartbeatStop != nil {
@@ -1125,17 +2117,22 @@ func (s *Server) stopRelayHeartbeat() {
 // startAnswerWatcher starts a goroutine that polls for new answers while connected
 // This enables fast reconnection when client refreshes (instead of waiting for ICE timeout)
 func (s *Server) startAnswerWatcher() {
-	if s.shortCodeClient == nil {
+	// Without a standby the relay still holds the answer the client just
+	// used, so the watcher would take it for a new one
+	if s.shortCodeClient == nil || s.opts.DisableStandby {
 		return
 	}
 
-	s.newAnswer = make(chan string, 1)
-	s.answerWatcher = make(chan struct{})
+	// The goroutine keeps its own copies: stopAnswerWatcher clears the field
+	newAnswer := make(chan string, 1)
+	stop := make(chan struct{})
+	s.newAnswer = newAnswer
+	s.answerWatcher = stop
 
 	go func() {
 		for {
 			select {
-			case <-s.answerWatcher:
+			case <-stop:
 				return
 			case <-s.ctx.Done():
 				return
@@ -1153,7 +2150,7 @@ func (s *Server) startAnswerWatcher() {
 				}
 				// No answer yet or timeout - continue polling
 				select {
-				case <-s.answerWatcher:
+				case <-stop:
 					return
 				case <-s.ctx.Done():
 					return
@@ -1165,7 +2162,7 @@ func (s *Server) startAnswerWatcher() {
 			// New answer received - signal for immediate reconnection
 			s.log("\n✓ Client reconnection detected (new answer received)\n")
 			select {
-			case s.newAnswer <- answer:
+			case newAnswer <- answer:
 			default:
 				// Channel full, answer will be lost but that's ok
 			}
@@ -1187,80 +2184,40 @@ func (s *Server) stopAnswerWatcher() {
 	}
 }
 
-// determineSignalingMethod decides which signaling method to use
-func (s *Server) determineSignalingMethod() signaling.SignalingMethod {
-	// If manual mode is forced, use it
-	if s.opts.Manual {
-		return signaling.MethodManual
-	}
-
-	// If relay URL is set and not disabled, use short code mode (default)
-	if s.opts.RelayURL != "" && !s.opts.NoRelay {
-		return signaling.MethodShortCode
-	}
-
-	// If no relay configured but not disabled, use default public relay
-	if !s.opts.NoRelay {
-		s.opts.RelayURL = signaling.GetRelayURL()
-		return signaling.MethodShortCode
-	}
-
-	// Default to HTTP (with UPnP attempt)
-	return signaling.MethodHTTP
-}
-
 // startHTTPSignaling uses the HTTP server for signaling (with UPnP)
 func (s *Server) startHTTPSignaling(offer, saltB64 string) (string, error) {
 	// Start signaling server
 	sig, err := NewSignalingServer(offer, s.sessionID, saltB64, web.StaticFS)
 	if err != nil {
-		return "", fmt.Errorf("failed to create signaling server: %w", err)
+		return "", unavailable(fmt.Errorf("failed to create signaling server: %w", err))
 	}
 	s.signaling = sig
 
 	if err := sig.Start(); err != nil {
-		return "", fmt.Errorf("failed to start signaling: %w", err)
+		s.signaling = nil
+		return "", unavailable(fmt.Errorf("failed to start signaling: %w", err))
 	}
 
 	sigPort := sig.Port()
 	if sigPort < 0 || sigPort > 65535 {
-		return "", fmt.Errorf("invalid port number: %d", sigPort)
+		_ = sig.Close()
+		s.signaling = nil
+		return "", unavailable(fmt.Errorf("invalid port number: %d", sigPort))
 	}
 	port := uint16(sigPort)
 
-	// Try UPnP port mapping
-	localIP, err := GetLocalIP()
-	if err != nil {
-		localIP = "localhost"
-	}
-
-	externalIP := localIP
-	upnpMapped := false
-
+	// Clients outside the local network need the port mapped
 	mapping, err := MapPort(port, "Terminal Tunnel")
-	if err == nil {
-		externalIP = mapping.ExternalIP
-		upnpMapped = true
-		s.upnpClose = mapping.Close
-		fmt.Printf("✓ UPnP port mapping successful\n")
-	} else {
-		fmt.Printf("⚠ UPnP not available: %v\n", err)
-		// If UPnP failed and relay is available, switch to relay
-		if s.opts.RelayURL != "" && !s.opts.NoRelay {
-			_ = s.signaling.Close()
-			s.signaling = nil
-			return s.startRelaySignaling(offer, saltB64)
-		}
-		// If no relay, fall back to manual
-		if !upnpMapped {
-			_ = s.signaling.Close()
-			s.signaling = nil
-			return s.startManualSignaling(offer)
-		}
+	if err != nil {
+		_ = sig.Close()
+		s.signaling = nil
+		return "", unavailable(fmt.Errorf("UPnP not available: %w", err))
 	}
+	s.upnpClose = mapping.Close
+	fmt.Printf("✓ UPnP port mapping successful\n")
 
 	// Display connection info
-	url := fmt.Sprintf("http://%s:%d", externalIP, port)
+	url := fmt.Sprintf("http://%s:%d", mapping.ExternalIP, port)
 	fmt.Printf("\n")
 	fmt.Printf("═══════════════════════════════════════════════════\n")
 	fmt.Printf("  Terminal Tunnel Ready!\n")
@@ -1270,12 +2227,6 @@ func (s *Server) startHTTPSignaling(offer, saltB64 string) (string, error) {
 	fmt.Printf("  Password: %s\n", s.opts.Password)
 	fmt.Printf("\n")
 
-	if !upnpMapped {
-		fmt.Printf("  ⚠ Note: Port %d may need manual forwarding\n", port)
-		fmt.Printf("  Local URL: http://%s:%d\n", localIP, port)
-		fmt.Printf("\n")
-	}
-
 	// Generate QR code
 	qr, err := qrcode.New(url, qrcode.Medium)
 	if err == nil {
@@ -1309,13 +2260,13 @@ func (s *Server) startRelaySignaling(offer, saltB64 string) (string, error) {
 
 	// Create relay client
 	relay := signaling.NewRelayClient(s.opts.RelayURL, s.sessionID, saltB64)
+	relay.OnClientInterest(s.callbacks.OnClientAttempting)
 	s.relayClient = relay
 
 	// Connect and send offer
 	if err := relay.ConnectAsHost(offer); err != nil {
-		fmt.Printf("⚠ Relay connection failed: %v\n", err)
-		fmt.Printf("Falling back to manual mode...\n")
-		return s.startManualSignaling(offer)
+		s.relayClient = nil
+		return "", unavailable(fmt.Errorf("relay connection failed: %w", err))
 	}
 
 	fmt.Printf("✓ Connected to relay\n")
@@ -1334,23 +2285,107 @@ func (s *Server) startRelaySignaling(offer, saltB64 string) (string, error) {
 // startManualSignaling uses QR code and copy-paste for signaling
 func (s *Server) startManualSignaling(offer string) (string, error) {
 	manual := signaling.NewManualSignaling(offer, s.salt)
+	if s.opts.ManualOfferLifetime != 0 {
+		manual.SetLifetime(s.opts.ManualOfferLifetime)
+	}
+	if s.opts.ManualOfferNonce {
+		if err := manual.RequireNonce(); err != nil {
+			return "", err
+		}
+	}
 
 	// Print instructions with QR code
 	manual.PrintInstructions(s.sessionID, s.opts.Password)
 
 	// Read answer from stdin
-	answer, err := signaling.ReadAnswer()
+	answer, err := manual.ReadAnswer()
 	if err != nil {
 		return "", fmt.Errorf("failed to read answer: %w", err)
 	}
+	// Whoever answers late may hold a leaked copy of the offer
+	if manual.Expired() {
+		return "", fmt.Errorf("answer arrived too late: %w; start a new session", signaling.ErrOfferExpired)
+	}
 
 	return answer, nil
 }
 
+// startLANSignaling serves signaling on the local network next to relay
+func (s *Server) startLANSignaling(relay Signaler) (*lanSignaler, error) {
+	ip, err := GetLocalIP()
+	if err != nil {
+		return nil, fmt.Errorf("no local network address: %w", err)
+	}
+	lan, err := newLANSignaler(relay, ip)
+	if err != nil {
+		return nil, err
+	}
+	s.lan = lan
+	s.log("✓ LAN signaling on http://%s\n", lan.Addr())
+	return lan, nil
+}
+
+// shortCodeUnavailable undoes short code signaling that failed to create a
+// session, so reconnects don't go through it, and marks err for falling
+// back to the next method. An offer the relay rejected as invalid ends the
+// chain instead: every method would carry the same offer. So does a
+// requested code (Options.Code) that is taken.
+func (s *Server) shortCodeUnavailable(err error) error {
+	s.shortCodeClient = nil
+	if s.lan != nil {
+		_ = s.lan.Close()
+		s.lan = nil
+	}
+	if errors.Is(err, signaling.ErrInvalidSDP) || errors.Is(err, signaling.ErrCodeTaken) {
+		return err
+	}
+	return unavailable(err)
+}
+
+// LANURL returns the web client link served on the local network, or ""
+// without Options.LAN
+func (s *Server) LANURL() string {
+	if s.lan == nil || s.lan.GetCode() == "" {
+		return ""
+	}
+	return s.lan.LANURL()
+}
+
 // startShortCodeSignaling uses the relay HTTP API with short codes
 func (s *Server) startShortCodeSignaling(offer, saltB64 string) (string, error) {
 	// Create short code client and save for reconnection
-	client := signaling.NewShortCodeClient(s.opts.RelayURL, signaling.GetClientURL())
+	client := s.opts.Signaler
+	var pipeRelay *signaling.ShortCodeClient
+	if client == nil && !s.opts.NoRelay {
+		relay := signaling.NewShortCodeClientWithConfig(s.opts.RelayURL, signaling.GetClientURL(), signaling.ShortCodeConfig{
+			PollInterval:     s.opts.RelayPollInterval,
+			RetryBackoff:     s.opts.RelayRetryBackoff,
+			FastPollWindow:   s.opts.RelayFastPollWindow,
+			MaxPollInterval:  s.opts.RelayMaxPollInterval,
+			FailureThreshold: s.opts.RelayFailureThreshold,
+			CircuitCooldown:  s.opts.RelayCircuitCooldown,
+		})
+		relay.OnRelayStateChange(s.relayStateChanged)
+		if s.opts.Code != "" {
+			relay.RequestCode(s.opts.Code)
+		}
+		if s.opts.AllowWSFallback {
+			relay.EnablePipe()
+			pipeRelay = relay
+		}
+		client = relay
+	}
+	if s.opts.LAN {
+		lan, err := s.startLANSignaling(client)
+		if err != nil {
+			if client == nil {
+				return "", fmt.Errorf("failed to start LAN signaling: %w", err)
+			}
+			fmt.Printf("⚠ LAN signaling unavailable: %v\n", err)
+		} else {
+			client = lan
+		}
+	}
 	s.shortCodeClient = client
 
 	var code string
@@ -1359,25 +2394,11 @@ func (s *Server) startShortCodeSignaling(offer, saltB64 string) (string, error)
 
 	// If public mode, create viewer peer and session
 	if s.opts.Public {
-		// Create viewer WebRTC peer
-		viewerPeer, err := ttwebrtc.NewPeer(s.webrtcConfig)
-		if err != nil {
-			return "", fmt.Errorf("failed to create viewer peer: %w", err)
-		}
-		s.viewerPeer = viewerPeer
-
-		// Create viewer data channel
-		viewerDC, err := viewerPeer.CreateDataChannel("terminal")
+		// Create the peer for the first viewer; each viewer to join gets
+		// a fresh one
+		viewerPeer, viewerOffer, err := s.newViewerPeer()
 		if err != nil {
-			_ = viewerPeer.Close()
-			return "", fmt.Errorf("failed to create viewer data channel: %w", err)
-		}
-
-		// Create viewer SDP offer
-		viewerOffer, err := viewerPeer.CreateOffer()
-		if err != nil {
-			_ = viewerPeer.Close()
-			return "", fmt.Errorf("failed to create viewer offer: %w", err)
+			return "", err
 		}
 
 		// Encode viewer key
@@ -1387,49 +2408,31 @@ func (s *Server) startShortCodeSignaling(offer, saltB64 string) (string, error)
 		code, viewerCode, err = client.CreateSessionWithViewer(offer, saltB64, viewerOffer, viewerKeyB64)
 		if err != nil {
 			_ = viewerPeer.Close()
-			fmt.Printf("⚠ Failed to create session with viewer: %v\n", err)
-			fmt.Printf("Falling back to manual mode...\n")
-			return s.startManualSignaling(offer)
+			return "", s.shortCodeUnavailable(fmt.Errorf("failed to create session with viewer: %w", err))
 		}
 		s.viewerCode = viewerCode
+		s.viewerPeer = viewerPeer
 
-		// Set up viewer data channel handler (output only, no input)
-		viewerDC.OnOpen(func() {
-			s.log("✓ Viewer connected\n")
-			if s.callbacks.OnViewerConnect != nil {
-				s.callbacks.OnViewerConnect()
-			}
-
-			// Create encrypted channel for viewer with viewer key
-			viewerChannel := ttwebrtc.NewEncryptedChannel(viewerDC, &s.viewerKey)
-			s.viewerChannel = viewerChannel
-
-			// Add viewer to bridge output (if bridge exists)
-			if s.bridge != nil {
-				s.bridge.AddViewerSend(viewerChannel.SendData)
-			}
-
-			// Handle viewer disconnect (no input handling for viewers)
-			viewerChannel.OnClose(func() {
-				s.log("✓ Viewer disconnected\n")
-				if s.callbacks.OnViewerDisconnect != nil {
-					s.callbacks.OnViewerDisconnect()
-				}
-			})
-		})
-
-		// Start waiting for viewer answer in background
+		// Start waiting for viewer answers in background
 		go s.waitForViewerConnection()
 	} else {
 		// Normal session without viewer
 		code, err = client.CreateSession(offer, saltB64)
 		if err != nil {
-			fmt.Printf("⚠ Failed to create session: %v\n", err)
-			fmt.Printf("Falling back to manual mode...\n")
-			return s.startManualSignaling(offer)
+			return "", s.shortCodeUnavailable(fmt.Errorf("failed to create session: %w", err))
 		}
 	}
 
+	if s.opts.Code != "" && code != s.opts.Code {
+		// Older relays ignore the request, and the LAN alone makes its own
+		s.log("⚠ Code %s not available from this relay, using %s\n", s.opts.Code, code)
+	}
+
+	s.watchClientInterest(code)
+	if pipeRelay != nil {
+		s.startPipeListener(pipeRelay)
+	}
+
 	clientURL := client.GetClientURL()
 
 	// Display connection info (skip if CLI is handling display via callback)
@@ -1443,6 +2446,9 @@ func (s *Server) startShortCodeSignaling(offer, saltB64 string) (string, error)
 		fmt.Printf("  Password: %s\n", s.opts.Password)
 		fmt.Printf("\n")
 		fmt.Printf("  Or open: %s\n", clientURL)
+		if lanURL := s.LANURL(); lanURL != "" && lanURL != clientURL {
+			fmt.Printf("  LAN:     %s\n", lanURL)
+		}
 	}
 
 	// Display viewer info if public mode
@@ -1492,32 +2498,58 @@ func (s *Server) startShortCodeSignaling(offer, saltB64 string) (string, error)
 		if s.ctx.Err() != nil {
 			return "", s.ctx.Err()
 		}
+		if errors.Is(waitCtx.Err(), context.DeadlineExceeded) {
+			return "", fmt.Errorf("%w within %s", ErrWaitTimeout, s.opts.Timeout)
+		}
 		return "", fmt.Errorf("failed to receive answer: %w", err)
 	}
 
 	return answer, nil
 }
 
-// waitForViewerConnection waits for a viewer to connect in the background
-func (s *Server) waitForViewerConnection() {
-	if s.shortCodeClient == nil || s.viewerPeer == nil {
+// notifyClient shows text to the connected client apart from the terminal,
+// so people sharing a session know who else is watching. Nothing is sent
+// with Options.NoJoinNotices, or to clients that can't show notices.
+func (s *Server) notifyClient(text string) {
+	if s.opts.NoJoinNotices {
 		return
 	}
+	sendNotice(s.currentChannel(), text)
+}
 
-	// Wait for viewer answer
-	answer, err := s.shortCodeClient.WaitForViewerAnswerWithContext(s.ctx)
-	if err != nil {
-		if s.ctx.Err() == nil {
-			s.log("⚠ Viewer connection failed: %v\n", err)
-		}
-		return
+// Notify shows text apart from the terminal to the client and the viewer,
+// where connected and able to show notices
+func (s *Server) Notify(text string) {
+	sendNotice(s.currentChannel(), text)
+	for _, channel := range s.viewerChannels() {
+		sendNotice(channel, text)
 	}
+}
 
-	// Set remote description
-	if err := s.viewerPeer.SetRemoteDescription(webrtc.SDPTypeAnswer, answer); err != nil {
-		s.log("⚠ Failed to set viewer answer: %v\n", err)
+// sendNotice sends text on channel if its peer negotiated FeatureNotice
+func sendNotice(channel *ttwebrtc.EncryptedChannel, text string) {
+	if channel == nil {
 		return
 	}
+	if caps, ok := channel.Capabilities(); ok && caps.Has(protocol.FeatureNotice) {
+		_ = channel.SendNotice(text)
+	}
+}
 
-	s.log("✓ Viewer answer received\n")
+// sendViewerInfo sends a joining viewer the terminal size and whether the
+// host allows viewer-side recording
+func (s *Server) sendViewerInfo(channel *ttwebrtc.EncryptedChannel) {
+	var flags byte
+	if !s.opts.NoViewerRecording {
+		flags |= protocol.ViewerRecordingAllowed
+	}
+	var rows, cols uint16 = 24, 80
+	if s.pty != nil {
+		if r, c, err := s.pty.Size(); err == nil && r > 0 && c > 0 {
+			rows, cols = r, c
+		}
+	}
+	if err := channel.SendViewerInfo(flags, rows, cols); err != nil {
+		s.log("  [Debug] Failed to send viewer info: %v\n", err)
+	}
 }
@@ -0,0 +1,53 @@
+package server
+
+import "errors"
+
+// ErrNoTerminal is returned by PauseSharing and ResumeSharing before the
+// session's terminal has started
+var ErrNoTerminal = errors.New("session has no terminal yet")
+
+// Notices shown to the client and viewers when the host pauses and resumes
+// sharing. They go straight to receivers, never into the history.
+var (
+	sharingPausedNotice  = []byte("\r\n\x1b[33m[tt] The host paused sharing. Output will resume when they continue.\x1b[0m\r\n")
+	sharingResumedNotice = []byte("\r\n\x1b[33m[tt] The host resumed sharing.\x1b[0m\r\n")
+)
+
+// PauseSharing stops sending terminal output to the client and viewers,
+// e.g. while the host types something private, without disconnecting them.
+// Returns false if sharing was already paused.
+func (s *Server) PauseSharing() (bool, error) {
+	bridge := s.GetBridge()
+	if bridge == nil {
+		return false, ErrNoTerminal
+	}
+	changed := bridge.PauseSharing()
+	if changed {
+		s.log("⏸ Sharing paused\n")
+	}
+	return changed, nil
+}
+
+// ResumeSharing sends the output held since PauseSharing and streams again.
+// Returns false if sharing was not paused.
+func (s *Server) ResumeSharing() (bool, error) {
+	bridge := s.GetBridge()
+	if bridge == nil {
+		return false, ErrNoTerminal
+	}
+	changed := bridge.ResumeSharing()
+	if changed {
+		s.log("▶ Sharing resumed\n")
+	}
+	return changed, nil
+}
+
+// appendBounded appends data to buf, keeping at most max of the most
+// recent bytes
+func appendBounded(buf, data []byte, max int) []byte {
+	buf = append(buf, data...)
+	if len(buf) > max {
+		buf = buf[len(buf)-max:]
+	}
+	return buf
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+
+	"github.com/artpar/terminal-tunnel/internal/signaling"
+)
+
+// Signaler exchanges SDP offers and answers with clients under a short code.
+// *signaling.ShortCodeClient implements it against the relay; Options.Signaler
+// substitutes another implementation, such as an in-memory one in tests.
+type Signaler interface {
+	// CreateSession registers an offer and returns the session's short code
+	CreateSession(sdp, salt string) (string, error)
+	// CreateSessionWithViewer also registers a read-only viewer offer,
+	// returning the viewer code as well
+	CreateSessionWithViewer(sdp, salt, viewerSDP, viewerKey string) (string, string, error)
+	// UpdateSession replaces the offer for reconnection and clears any
+	// previous answer. Returns signaling.ErrSessionExpired if the session
+	// no longer exists.
+	UpdateSession(sdp, salt string) error
+	// WaitForAnswerWithContext waits for the answer to the current offer.
+	// The answer is returned again on later calls until the offer changes.
+	WaitForAnswerWithContext(ctx context.Context) (string, error)
+	// WaitForViewerAnswerWithContext waits for the viewer's answer
+	WaitForViewerAnswerWithContext(ctx context.Context) (string, error)
+	// SendHeartbeat keeps the session from expiring
+	SendHeartbeat() error
+	// DeleteSession invalidates the session's codes
+	DeleteSession() error
+
+	GetCode() string
+	GetClientURL() string
+	GetViewerURL() string
+}
+
+var _ Signaler = (*signaling.ShortCodeClient)(nil)
+
+// clientInterestWatcher is implemented by signalers that can tell when a
+// client fetches the offer, before its answer arrives
+type clientInterestWatcher interface {
+	WatchClientInterest(ctx context.Context, code string, onInterest func()) error
+}
+
+var _ clientInterestWatcher = (*signaling.ShortCodeClient)(nil)
+
+// viewerOfferUpdater is implemented by signalers that can replace the
+// viewer offer once it has been answered. Without it, only the first viewer
+// to open the viewer link can join.
+type viewerOfferUpdater interface {
+	UpdateViewerSession(viewerSDP string) error
+}
+
+var _ viewerOfferUpdater = (*signaling.ShortCodeClient)(nil)
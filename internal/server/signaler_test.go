@@ -0,0 +1,517 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+
+	"github.com/artpar/terminal-tunnel/internal/crypto"
+	"github.com/artpar/terminal-tunnel/internal/protocol"
+	"github.com/artpar/terminal-tunnel/internal/signaling"
+	ttwebrtc "github.com/artpar/terminal-tunnel/internal/webrtc"
+)
+
+// memSignaler is an in-memory Signaler playing the relay's part, so tests
+// can drive Server.Start without a network. Clients read the current offer
+// with waitOffer and reply with postAnswer.
+type memSignaler struct {
+	mu       sync.Mutex
+	code     string
+	sessions int    // Sessions created, numbering the codes
+	offer    string // Current offer
+	salt     string
+	offerSeq int // Incremented whenever the offer changes
+	answer   string
+	changed  chan struct{} // Closed (and replaced) on any change
+}
+
+func newMemSignaler() *memSignaler {
+	return &memSignaler{changed: make(chan struct{})}
+}
+
+// notify wakes waiters; the caller holds mu
+func (m *memSignaler) notify() {
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
+
+func (m *memSignaler) CreateSession(sdp, salt string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions++
+	m.code = fmt.Sprintf("MEM%03d", m.sessions)
+	m.setOffer(sdp, salt)
+	return m.code, nil
+}
+
+func (m *memSignaler) CreateSessionWithViewer(sdp, salt, viewerSDP, viewerKey string) (string, string, error) {
+	code, err := m.CreateSession(sdp, salt)
+	return code, code + "V", err
+}
+
+func (m *memSignaler) UpdateSession(sdp, salt string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.code == "" {
+		return signaling.ErrSessionExpired
+	}
+	m.setOffer(sdp, salt)
+	return nil
+}
+
+// setOffer replaces the offer and clears its answer; the caller holds mu
+func (m *memSignaler) setOffer(sdp, salt string) {
+	m.offer = sdp
+	m.salt = salt
+	m.offerSeq++
+	m.answer = ""
+	m.notify()
+}
+
+func (m *memSignaler) WaitForAnswerWithContext(ctx context.Context) (string, error) {
+	for {
+		m.mu.Lock()
+		code, answer, changed := m.code, m.answer, m.changed
+		m.mu.Unlock()
+		if code == "" {
+			return "", signaling.ErrSessionExpired
+		}
+		if answer != "" {
+			return answer, nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// WaitForViewerAnswerWithContext never sees a viewer join
+func (m *memSignaler) WaitForViewerAnswerWithContext(ctx context.Context) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func (m *memSignaler) SendHeartbeat() error { return nil }
+
+func (m *memSignaler) DeleteSession() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.code = ""
+	return nil
+}
+
+func (m *memSignaler) GetCode() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.code
+}
+
+func (m *memSignaler) GetClientURL() string {
+	return "https://client.test/?c=" + m.GetCode()
+}
+
+func (m *memSignaler) GetViewerURL() string {
+	return "https://client.test/?c=" + m.GetCode() + "V"
+}
+
+// drop forgets the session, as a relay does when it expires
+func (m *memSignaler) drop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.code = ""
+	m.offer = ""
+	m.answer = ""
+	m.notify()
+}
+
+// waitOffer returns the first offer newer than seq, as a client fetching
+// the code would see it
+func (m *memSignaler) waitOffer(t *testing.T, seq int) (offer, salt string, offerSeq int) {
+	t.Helper()
+	deadline := time.After(20 * time.Second)
+	for {
+		m.mu.Lock()
+		offer, salt, offerSeq = m.offer, m.salt, m.offerSeq
+		changed := m.changed
+		m.mu.Unlock()
+		if offerSeq > seq && offer != "" {
+			return offer, salt, offerSeq
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			t.Fatalf("no offer after #%d", seq)
+		}
+	}
+}
+
+// postAnswer stores a client's answer to the current offer
+func (m *memSignaler) postAnswer(answer string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.answer = answer
+	m.notify()
+}
+
+// testClient is a browser-like client connecting through a memSignaler
+type testClient struct {
+	peer    *ttwebrtc.Peer
+	channel *ttwebrtc.EncryptedChannel
+
+	mu     sync.Mutex
+	output bytes.Buffer
+}
+
+// connectClient answers the first offer newer than seq and waits for the
+// data channel to open. Returns the offer sequence it answered.
+func connectClient(t *testing.T, sig *memSignaler, password string, seq int) (*testClient, int) {
+	t.Helper()
+	offer, saltB64, offerSeq := sig.waitOffer(t, seq)
+	salt, err := base64.StdEncoding.DecodeString(saltB64)
+	if err != nil {
+		t.Fatalf("bad salt: %v", err)
+	}
+	key := crypto.DeriveKey(password, salt)
+
+	peer, err := ttwebrtc.NewPeer(ttwebrtc.ConfigWithoutTURN())
+	if err != nil {
+		t.Fatalf("NewPeer failed: %v", err)
+	}
+	dcOpen := make(chan *webrtc.DataChannel, 1)
+	peer.OnDataChannel(func(dc *webrtc.DataChannel) {
+		dc.OnOpen(func() {
+			dcOpen <- dc
+		})
+	})
+	if err := peer.SetRemoteDescription(webrtc.SDPTypeOffer, offer); err != nil {
+		t.Fatalf("SetRemoteDescription failed: %v", err)
+	}
+	answer, err := peer.CreateAnswer()
+	if err != nil {
+		t.Fatalf("CreateAnswer failed: %v", err)
+	}
+	sig.postAnswer(answer)
+
+	c := &testClient{peer: peer}
+	select {
+	case dc := <-dcOpen:
+		c.channel = ttwebrtc.NewEncryptedChannel(dc, &key)
+		c.channel.OnData(func(data []byte) {
+			c.mu.Lock()
+			c.output.Write(data)
+			c.mu.Unlock()
+		})
+		// Like the web client, say which key we use before anything else
+		_ = c.channel.SendKeyPing(protocol.KeySession)
+	case <-time.After(20 * time.Second):
+		peer.Close()
+		t.Fatal("timeout waiting for data channel")
+	}
+	return c, offerSeq
+}
+
+// expectEcho sends a line and waits for the shell to echo it back
+func (c *testClient) expectEcho(t *testing.T, line string) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if err := c.channel.SendData([]byte(line + "\n")); err != nil {
+			t.Fatalf("SendData failed: %v", err)
+		}
+		time.Sleep(200 * time.Millisecond)
+		c.mu.Lock()
+		found := bytes.Contains(c.output.Bytes(), []byte(line))
+		c.mu.Unlock()
+		if found {
+			return
+		}
+	}
+	t.Fatalf("%q was not echoed", line)
+}
+
+func (c *testClient) Close() {
+	c.channel.Close()
+	c.peer.Close()
+}
+
+// startTestServer runs Server.Start over sig until the test ends. configure
+// may adjust the options first.
+func startTestServer(t *testing.T, sig *memSignaler, cb Callbacks, configure ...func(*Options)) *Server {
+	t.Helper()
+	opts := Options{
+		Password: "test-password",
+		Command:  []string{"cat"},
+		NoTURN:   true,
+		Signaler: sig,
+	}
+	for _, fn := range configure {
+		fn(&opts)
+	}
+	s, err := NewServer(opts)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	s.SetQuiet(true)
+	s.SetCallbacks(cb)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Error("Start did not return after cancel")
+		}
+	})
+	return s
+}
+
+func TestServerWithSignalerConnects(t *testing.T) {
+	sig := newMemSignaler()
+	codes := make(chan string, 1)
+	startTestServer(t, sig, Callbacks{
+		OnShortCodeReady: func(code, clientURL string) { codes <- code },
+	})
+
+	client, _ := connectClient(t, sig, "test-password", 0)
+	defer client.Close()
+	client.expectEcho(t, "hello")
+
+	if code := <-codes; code != "MEM001" {
+		t.Errorf("code = %q, want MEM001", code)
+	}
+}
+
+func TestServerWithSignalerReconnectsViaStandby(t *testing.T) {
+	sig := newMemSignaler()
+	s := startTestServer(t, sig, Callbacks{})
+
+	client, seq := connectClient(t, sig, "test-password", 0)
+	client.expectEcho(t, "first")
+	client.Close()
+
+	// The standby offer replaced the first one under the same code
+	client, _ = connectClient(t, sig, "test-password", seq)
+	defer client.Close()
+	client.expectEcho(t, "second")
+
+	if got := sig.GetCode(); got != "MEM001" {
+		t.Errorf("code = %q, want MEM001", got)
+	}
+	if n := s.ReconnectCount(); n != 1 {
+		t.Errorf("ReconnectCount = %d, want 1", n)
+	}
+}
+
+func TestServerWithSignalerStandbySkipsReconnectGrace(t *testing.T) {
+	sig := newMemSignaler()
+	startTestServer(t, sig, Callbacks{}, func(o *Options) {
+		o.ReconnectGrace = time.Minute
+	})
+
+	client, seq := connectClient(t, sig, "test-password", 0)
+	client.expectEcho(t, "first")
+	client.Close()
+
+	// Reconnecting through the standby offer must not wait out the grace
+	// (connectClient gives up long before a minute)
+	start := time.Now()
+	client, _ = connectClient(t, sig, "test-password", seq)
+	defer client.Close()
+	client.expectEcho(t, "second")
+	if took := time.Since(start); took > 10*time.Second {
+		t.Errorf("standby reconnect took %v", took)
+	}
+}
+
+func TestServerWithSignalerReconnectGrace(t *testing.T) {
+	sig := newMemSignaler()
+	startTestServer(t, sig, Callbacks{}, func(o *Options) {
+		o.DisableStandby = true
+	})
+
+	client, seq := connectClient(t, sig, "test-password", 0)
+	client.expectEcho(t, "first")
+	client.Close()
+
+	// Without a standby the fresh offer waits out the default grace, and
+	// not much longer
+	start := time.Now()
+	client, _ = connectClient(t, sig, "test-password", seq)
+	defer client.Close()
+	client.expectEcho(t, "second")
+	if took := time.Since(start); took < defaultReconnectGrace || took > defaultReconnectGrace+5*time.Second {
+		t.Errorf("reconnect without a standby took %v, want %v to %v", took, defaultReconnectGrace, defaultReconnectGrace+5*time.Second)
+	}
+}
+
+func TestServerWithSignalerNoStandby(t *testing.T) {
+	sig := newMemSignaler()
+	startTestServer(t, sig, Callbacks{}, func(o *Options) {
+		o.DisableStandby = true
+		o.ReconnectGrace = -1
+	})
+
+	client, seq := connectClient(t, sig, "test-password", 0)
+	client.expectEcho(t, "first")
+
+	// No standby offer replaces the one the client answered
+	time.Sleep(500 * time.Millisecond)
+	sig.mu.Lock()
+	offerSeq := sig.offerSeq
+	sig.mu.Unlock()
+	if offerSeq != seq {
+		t.Errorf("offer #%d uploaded while connected, want none after #%d", offerSeq, seq)
+	}
+	client.Close()
+
+	// A fresh offer goes up once the client drops
+	client, _ = connectClient(t, sig, "test-password", seq)
+	defer client.Close()
+	client.expectEcho(t, "second")
+}
+
+func TestServerWithSignalerRenewsDroppedSession(t *testing.T) {
+	sig := newMemSignaler()
+	var mu sync.Mutex
+	var codes []string
+	startTestServer(t, sig, Callbacks{
+		OnShortCodeReady: func(code, clientURL string) {
+			mu.Lock()
+			codes = append(codes, code)
+			mu.Unlock()
+		},
+	})
+
+	client, seq := connectClient(t, sig, "test-password", 0)
+	client.expectEcho(t, "first")
+	// The relay expires the session while the client is away
+	sig.drop()
+	client.Close()
+
+	client, _ = connectClient(t, sig, "test-password", seq)
+	defer client.Close()
+	client.expectEcho(t, "second")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(codes) != 2 || codes[0] != "MEM001" || codes[1] != "MEM002" {
+		t.Errorf("OnShortCodeReady codes = %v, want [MEM001 MEM002]", codes)
+	}
+}
+
+func TestServerWithSignalerWaitTimeout(t *testing.T) {
+	sig := newMemSignaler()
+	s, err := NewServer(Options{
+		Password: "test-password",
+		Command:  []string{"cat"},
+		NoTURN:   true,
+		Timeout:  200 * time.Millisecond,
+		Signaler: sig,
+	})
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	s.SetQuiet(true)
+	s.SetCallbacks(Callbacks{OnShortCodeReady: func(code, clientURL string) {}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.Start(ctx); !errors.Is(err, ErrWaitTimeout) {
+		t.Errorf("Start error = %v, want ErrWaitTimeout", err)
+	}
+}
+
+func TestServerWithSignalerConfirmClient(t *testing.T) {
+	sig := newMemSignaler()
+	requests := make(chan ClientConnectRequest, 2)
+	var mu sync.Mutex
+	approve := false
+	startTestServer(t, sig, Callbacks{
+		OnClientConnectRequest: func(req ClientConnectRequest) bool {
+			requests <- req
+			mu.Lock()
+			defer mu.Unlock()
+			return approve
+		},
+	}, func(o *Options) {
+		o.ConfirmClient = true
+	})
+
+	// The host rejects the first client, which is told why
+	client, seq := connectClient(t, sig, "test-password", 0)
+	closed := make(chan struct{}, 1)
+	client.channel.OnClose(func() {
+		select {
+		case closed <- struct{}{}:
+		default:
+		}
+	})
+	select {
+	case req := <-requests:
+		if req.CandidatePair == "" {
+			t.Error("request has no candidate pair")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("host was not asked to approve the client")
+	}
+	select {
+	case <-closed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("rejected client was not disconnected")
+	}
+	client.mu.Lock()
+	notified := bytes.Contains(client.output.Bytes(), []byte("Waiting for the host"))
+	client.mu.Unlock()
+	if !notified {
+		t.Error("client was not told it is waiting for approval")
+	}
+	client.Close()
+
+	// A fresh offer lets the next client in once approved
+	mu.Lock()
+	approve = true
+	mu.Unlock()
+	client, _ = connectClient(t, sig, "test-password", seq)
+	defer client.Close()
+	client.expectEcho(t, "approved")
+}
+
+func TestServerWithSignalerFirstReconnectLatency(t *testing.T) {
+	sig := newMemSignaler()
+	startTestServer(t, sig, Callbacks{})
+
+	// The first standby was gathered while waiting for this client, so it
+	// reaches the relay right after connecting and even an immediate drop
+	// reconnects through it, like later drops do
+	client, seq := connectClient(t, sig, "test-password", 0)
+	client.expectEcho(t, "first")
+
+	var took []time.Duration
+	for _, line := range []string{"second", "third"} {
+		client.Close()
+		start := time.Now()
+		client, seq = connectClient(t, sig, "test-password", seq)
+		client.expectEcho(t, line)
+		took = append(took, time.Since(start))
+	}
+	client.Close()
+
+	if got := sig.GetCode(); got != "MEM001" {
+		t.Errorf("code = %q, want MEM001", got)
+	}
+	t.Logf("first reconnect took %v, second %v", took[0], took[1])
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/artpar/terminal-tunnel/internal/signaling"
+)
+
+// ErrNoSignaling is returned by Start when no signaling method in the chain
+// could be set up. The error is a *SignalingError listing why each failed.
+var ErrNoSignaling = errors.New("no signaling method worked")
+
+// SignalingAttempt is a signaling method tried for the first connection,
+// and why it couldn't be used
+type SignalingAttempt struct {
+	Method signaling.SignalingMethod
+	Err    error
+}
+
+// SignalingError reports that every method in the signaling chain failed to
+// set up. It matches ErrNoSignaling and each attempt's error with errors.Is,
+// e.g. signaling.ErrRelayUnreachable.
+type SignalingError struct {
+	Attempts []SignalingAttempt
+}
+
+func (e *SignalingError) Error() string {
+	var b strings.Builder
+	b.WriteString(ErrNoSignaling.Error())
+	b.WriteString(", tried:")
+	for _, a := range e.Attempts {
+		fmt.Fprintf(&b, "\n  %s: %v", a.Method, a.Err)
+	}
+	return b.String()
+}
+
+func (e *SignalingError) Unwrap() []error {
+	errs := []error{ErrNoSignaling}
+	for _, a := range e.Attempts {
+		errs = append(errs, a.Err)
+	}
+	return errs
+}
+
+// unavailableError is a signaling method that could not be set up, such as
+// an unreachable relay or no UPnP router, so the next method in the chain
+// is tried. Failures after setup, like no client answering in time, end the
+// chain.
+type unavailableError struct {
+	err error
+}
+
+func (e *unavailableError) Error() string { return e.err.Error() }
+func (e *unavailableError) Unwrap() error { return e.err }
+
+// unavailable marks err as a failure to set up a signaling method
+func unavailable(err error) error {
+	return &unavailableError{err: err}
+}
+
+// signalingChain returns the signaling methods to try for the first
+// connection, in order. Options.Signaling sets it; otherwise it follows
+// from Manual, NoRelay and LAN, ending in manual mode.
+func (s *Server) signalingChain() []signaling.SignalingMethod {
+	chain := s.opts.Signaling
+	switch {
+	case len(chain) > 0:
+	case s.opts.Signaler != nil:
+		// A provided signaler replaces the relay
+		chain = []signaling.SignalingMethod{signaling.MethodShortCode, signaling.MethodManual}
+	case s.opts.Manual:
+		chain = []signaling.SignalingMethod{signaling.MethodManual}
+	case s.opts.NoRelay && s.opts.LAN:
+		// LAN signaling hands out short codes without a relay
+		chain = []signaling.SignalingMethod{signaling.MethodShortCode, signaling.MethodManual}
+	case s.opts.NoRelay:
+		chain = []signaling.SignalingMethod{signaling.MethodHTTP, signaling.MethodManual}
+	default:
+		chain = []signaling.SignalingMethod{signaling.MethodShortCode, signaling.MethodManual}
+	}
+
+	// Methods that go through the relay use the public one unless set
+	if s.opts.RelayURL == "" && s.opts.Signaler == nil &&
+		(slices.Contains(chain, signaling.MethodShortCode) || slices.Contains(chain, signaling.MethodRelay)) {
+		s.opts.RelayURL = signaling.GetRelayURL()
+	}
+	return chain
+}
+
+// runSignalingChain gets the client's answer to offer through the first
+// method in chain that can be set up, and returns the method used. If none
+// can, the error is a *SignalingError.
+func (s *Server) runSignalingChain(chain []signaling.SignalingMethod, offer, saltB64 string) (string, signaling.SignalingMethod, error) {
+	var attempts []SignalingAttempt
+	for i, method := range chain {
+		var answer string
+		var err error
+		switch method {
+		case signaling.MethodHTTP:
+			answer, err = s.startHTTPSignaling(offer, saltB64)
+		case signaling.MethodRelay:
+			answer, err = s.startRelaySignaling(offer, saltB64)
+		case signaling.MethodManual:
+			answer, err = s.startManualSignaling(offer)
+		case signaling.MethodShortCode:
+			answer, err = s.startShortCodeSignaling(offer, saltB64)
+		default:
+			err = unavailable(fmt.Errorf("unknown signaling method %d", method))
+		}
+		if err == nil {
+			return answer, method, nil
+		}
+
+		var unavailableErr *unavailableError
+		if !errors.As(err, &unavailableErr) || s.ctx.Err() != nil {
+			return "", method, err
+		}
+		attempts = append(attempts, SignalingAttempt{Method: method, Err: unavailableErr.err})
+		fmt.Printf("⚠ %s signaling failed: %v\n", method, unavailableErr.err)
+		if i+1 < len(chain) {
+			fmt.Printf("Falling back to %s...\n", chain[i+1])
+		}
+	}
+	return "", chain[len(chain)-1], &SignalingError{Attempts: attempts}
+}
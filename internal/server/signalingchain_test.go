@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/artpar/terminal-tunnel/internal/signaling"
+)
+
+func TestSignalingChain(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want []signaling.SignalingMethod
+	}{
+		{"default", Options{}, []signaling.SignalingMethod{signaling.MethodShortCode, signaling.MethodManual}},
+		{"manual", Options{Manual: true}, []signaling.SignalingMethod{signaling.MethodManual}},
+		{"no relay", Options{NoRelay: true}, []signaling.SignalingMethod{signaling.MethodHTTP, signaling.MethodManual}},
+		{"no relay with LAN", Options{NoRelay: true, LAN: true}, []signaling.SignalingMethod{signaling.MethodShortCode, signaling.MethodManual}},
+		{"configured", Options{Manual: true, Signaling: []signaling.SignalingMethod{signaling.MethodHTTP, signaling.MethodShortCode}}, []signaling.SignalingMethod{signaling.MethodHTTP, signaling.MethodShortCode}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{opts: tt.opts}
+			if got := s.signalingChain(); !slices.Equal(got, tt.want) {
+				t.Errorf("signalingChain() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerNoSignaling(t *testing.T) {
+	s, err := NewServer(Options{
+		Password:  "test-password",
+		Command:   []string{"cat"},
+		NoTURN:    true,
+		Signaler:  downSignaler{newMemSignaler()},
+		Signaling: []signaling.SignalingMethod{signaling.MethodShortCode},
+	})
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	s.SetQuiet(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = s.Start(ctx)
+	var sigErr *SignalingError
+	if !errors.Is(err, ErrNoSignaling) || !errors.As(err, &sigErr) {
+		t.Fatalf("Start error = %v, want a SignalingError", err)
+	}
+	if len(sigErr.Attempts) != 1 || sigErr.Attempts[0].Method != signaling.MethodShortCode {
+		t.Errorf("attempts = %+v, want the short code method", sigErr.Attempts)
+	}
+}
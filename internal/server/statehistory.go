@@ -0,0 +1,48 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// maxStateTransitions bounds how many connection state changes a server
+// remembers; older ones are dropped first
+const maxStateTransitions = 100
+
+// StateTransition is a change in the state of the client connection
+type StateTransition struct {
+	At    time.Time `json:"at"`
+	Layer string    `json:"layer"` // "peer" or "ice"
+	State string    `json:"state"`
+}
+
+// stateHistory keeps the most recent connection state changes of a
+// session, so a connection that dropped or failed can be looked at after
+// the fact (tt info --states)
+type stateHistory struct {
+	mu          sync.Mutex
+	transitions []StateTransition // Ring buffer, oldest at next once full
+	next        int
+}
+
+// record notes a state change
+func (h *stateHistory) record(layer, state string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t := StateTransition{At: time.Now(), Layer: layer, State: state}
+	if len(h.transitions) < maxStateTransitions {
+		h.transitions = append(h.transitions, t)
+		return
+	}
+	h.transitions[h.next] = t
+	h.next = (h.next + 1) % maxStateTransitions
+}
+
+// history returns the recorded state changes, oldest first
+func (h *stateHistory) history() []StateTransition {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	result := make([]StateTransition, 0, len(h.transitions))
+	result = append(result, h.transitions[h.next:]...)
+	return append(result, h.transitions[:h.next]...)
+}
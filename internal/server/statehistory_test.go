@@ -0,0 +1,37 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStateHistory(t *testing.T) {
+	var h stateHistory
+	if got := h.history(); len(got) != 0 {
+		t.Fatalf("history() = %v before any change, want none", got)
+	}
+
+	h.record("peer", "connecting")
+	h.record("ice", "checking")
+	got := h.history()
+	if len(got) != 2 || got[0].State != "connecting" || got[1].Layer != "ice" {
+		t.Fatalf("history() = %+v, want connecting then ice checking", got)
+	}
+
+	// Past the bound the oldest changes go, and order is kept
+	for i := 0; i < maxStateTransitions+10; i++ {
+		h.record("peer", fmt.Sprint(i))
+	}
+	got = h.history()
+	if len(got) != maxStateTransitions {
+		t.Fatalf("len(history()) = %d, want %d", len(got), maxStateTransitions)
+	}
+	if got[0].State != "10" || got[len(got)-1].State != fmt.Sprint(maxStateTransitions+9) {
+		t.Errorf("history() runs %q..%q, want 10..%d", got[0].State, got[len(got)-1].State, maxStateTransitions+9)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].At.Before(got[i-1].At) {
+			t.Fatalf("history() out of order at %d", i)
+		}
+	}
+}
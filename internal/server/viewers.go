@@ -0,0 +1,309 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+
+	"github.com/artpar/terminal-tunnel/internal/protocol"
+	"github.com/artpar/terminal-tunnel/internal/signaling"
+	ttwebrtc "github.com/artpar/terminal-tunnel/internal/webrtc"
+)
+
+// viewerOfferRetry is how long to wait before offering the relay a fresh
+// viewer offer again after it failed
+const viewerOfferRetry = 5 * time.Second
+
+// DefaultMaxViewersPerIP is how many public viewers may connect from one
+// address at once, unless Options.MaxViewersPerIP says otherwise
+const DefaultMaxViewersPerIP = 3
+
+// viewer is a connected public viewer. Each viewer has its own peer
+// connection, made for the offer that was on the relay when it joined.
+type viewer struct {
+	id         int // Numbers viewers from 1, in the order they joined
+	peer       *ttwebrtc.Peer
+	channel    *ttwebrtc.EncryptedChannel
+	removeSend func() // Stops the bridge sending output to the viewer; guarded by viewerMu
+	joined     time.Time
+	address    string // Remote address of the selected candidate pair, "" if unknown
+}
+
+// newViewerPeer creates a peer for the next viewer to join and returns its
+// offer. The viewer is added once its data channel opens.
+func (s *Server) newViewerPeer() (*ttwebrtc.Peer, string, error) {
+	peer, err := ttwebrtc.NewPeer(s.webrtcConfig)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create viewer peer: %w", err)
+	}
+
+	dc, err := peer.CreateDataChannel("terminal")
+	if err != nil {
+		_ = peer.Close()
+		return nil, "", fmt.Errorf("failed to create viewer data channel: %w", err)
+	}
+
+	offer, err := peer.CreateOffer()
+	if err != nil {
+		_ = peer.Close()
+		return nil, "", fmt.Errorf("failed to create viewer offer: %w", err)
+	}
+
+	dc.OnOpen(func() {
+		s.viewerJoined(peer, ttwebrtc.NewEncryptedChannel(dc, &s.viewerKey))
+	})
+	return peer, offer, nil
+}
+
+// viewerJoined starts sending the session to a viewer whose channel opened.
+// Viewers only watch until the host grants one write access. A viewer from
+// an address that already has the maximum number connected is turned away.
+func (s *Server) viewerJoined(peer *ttwebrtc.Peer, channel *ttwebrtc.EncryptedChannel) {
+	v := &viewer{peer: peer, channel: channel, joined: time.Now()}
+	v.address, _ = peer.RemoteCandidate()
+
+	// Counted and added at once, so viewers opening together can't both
+	// take the last place
+	s.viewerMu.Lock()
+	if limit := s.maxViewersPerIP(); limit > 0 && s.viewersFromLocked(v.address) >= limit {
+		s.viewerMu.Unlock()
+		s.log("⚠ Turned away a viewer from %s: %d already watching from there\n", v.address, limit)
+		go func() {
+			_ = channel.SendCloseWithCode(protocol.CloseRejected, "too many viewers from your address")
+			time.Sleep(100 * time.Millisecond) // Let the close message go out
+			channel.Close()
+			_ = peer.Close()
+		}()
+		return
+	}
+	s.lastViewerID++
+	v.id = s.lastViewerID
+	s.viewerList = append(s.viewerList, v)
+	watching := len(s.viewerList)
+	s.viewerMu.Unlock()
+
+	_ = channel.SendCapabilities()
+
+	// Tell the viewer the size and recording policy before the screen
+	// history, so its recording starts from a correct screen
+	s.sendViewerInfo(channel)
+
+	if bridge := s.GetBridge(); bridge != nil {
+		removeSend := bridge.AddViewerSend(bandwidthSend(channel))
+		s.viewerMu.Lock()
+		closed := !slices.Contains(s.viewerList, v)
+		if !closed {
+			v.removeSend = removeSend
+		}
+		s.viewerMu.Unlock()
+		if closed {
+			// closeViewers ran while the viewer was joining
+			removeSend()
+			return
+		}
+	}
+
+	s.log("✓ Viewer %d connected\n", v.id)
+	s.notifyClient(fmt.Sprintf("A viewer joined (%d watching)", watching))
+	if s.callbacks.OnViewerConnect != nil {
+		s.callbacks.OnViewerConnect()
+	}
+
+	channel.OnData(func(data []byte) {
+		s.handleViewerInput(v, data)
+	})
+	channel.OnClose(func() {
+		s.viewerLeft(v)
+	})
+}
+
+// maxViewersPerIP returns the per-address viewer limit, 0 if there is none
+func (s *Server) maxViewersPerIP() int {
+	switch limit := s.opts.MaxViewersPerIP; {
+	case limit == 0:
+		return DefaultMaxViewersPerIP
+	case limit < 0:
+		return 0
+	default:
+		return limit
+	}
+}
+
+// viewersFromLocked counts the viewers connected from address; the caller
+// holds viewerMu. An unknown address matches no one.
+func (s *Server) viewersFromLocked(address string) int {
+	if address == "" {
+		return 0
+	}
+	n := 0
+	for _, v := range s.viewerList {
+		if v.address == address {
+			n++
+		}
+	}
+	return n
+}
+
+// viewerLeft forgets a viewer whose channel closed. A grant doesn't carry
+// over to whoever joins next.
+func (s *Server) viewerLeft(v *viewer) {
+	s.viewerMu.Lock()
+	i := slices.Index(s.viewerList, v)
+	if i < 0 {
+		// Already closed along with the connection or the session
+		s.viewerMu.Unlock()
+		return
+	}
+	s.viewerList = slices.Delete(s.viewerList, i, i+1)
+	watching := len(s.viewerList)
+	if s.viewerWriter == v.id {
+		s.viewerWriter = 0
+	}
+	removeSend := v.removeSend
+	s.viewerMu.Unlock()
+
+	if removeSend != nil {
+		removeSend()
+	}
+	// Not from the channel's own callback
+	go v.peer.Close()
+
+	s.log("✓ Viewer %d disconnected\n", v.id)
+	s.notifyClient(fmt.Sprintf("A viewer left (%d watching)", watching))
+	if s.callbacks.OnViewerDisconnect != nil {
+		s.callbacks.OnViewerDisconnect()
+	}
+}
+
+// viewerChannels returns the channels of the connected viewers
+func (s *Server) viewerChannels() []*ttwebrtc.EncryptedChannel {
+	s.viewerMu.Lock()
+	defer s.viewerMu.Unlock()
+	channels := make([]*ttwebrtc.EncryptedChannel, len(s.viewerList))
+	for i, v := range s.viewerList {
+		channels[i] = v.channel
+	}
+	return channels
+}
+
+// viewerCount returns how many viewers are connected
+func (s *Server) viewerCount() int {
+	s.viewerMu.Lock()
+	defer s.viewerMu.Unlock()
+	return len(s.viewerList)
+}
+
+// closeViewers disconnects every connected viewer. With pending, it also
+// closes the peer waiting for the next viewer, for good.
+func (s *Server) closeViewers(pending bool) {
+	s.viewerMu.Lock()
+	viewers := s.viewerList
+	s.viewerList = nil
+	s.viewerWriter = 0
+	var peer *ttwebrtc.Peer
+	if pending {
+		peer = s.viewerPeer
+		s.viewerPeer = nil
+		s.viewersClosed = true
+	}
+	removeSends := make([]func(), len(viewers))
+	for i, v := range viewers {
+		removeSends[i] = v.removeSend
+	}
+	s.viewerMu.Unlock()
+
+	for i, v := range viewers {
+		if removeSends[i] != nil {
+			removeSends[i]()
+		}
+		v.channel.Close()
+		_ = v.peer.Close()
+	}
+	if peer != nil {
+		_ = peer.Close()
+	}
+}
+
+// waitForViewerConnection connects viewers in the background for the life
+// of the session. Each answer on the relay is for the pending viewer peer;
+// once it arrives, a fresh peer's offer replaces it for the next viewer.
+func (s *Server) waitForViewerConnection() {
+	if s.shortCodeClient == nil {
+		return
+	}
+	updater, canUpdate := s.shortCodeClient.(viewerOfferUpdater)
+
+	for {
+		s.viewerMu.Lock()
+		peer := s.viewerPeer
+		s.viewerMu.Unlock()
+		if peer == nil {
+			return
+		}
+
+		// Wait for viewer answer
+		answer, err := s.shortCodeClient.WaitForViewerAnswerWithContext(s.ctx)
+		if err != nil {
+			if s.ctx.Err() == nil {
+				s.log("⚠ Viewer connection failed: %v\n", err)
+			}
+			return
+		}
+
+		// Set remote description
+		if err := peer.SetRemoteDescription(webrtc.SDPTypeAnswer, answer); err != nil {
+			s.log("⚠ Failed to set viewer answer: %v\n", err)
+			_ = peer.Close()
+		} else {
+			s.log("✓ Viewer answer received\n")
+		}
+
+		if !canUpdate {
+			return
+		}
+		if !s.offerNextViewer(updater) {
+			return
+		}
+	}
+}
+
+// offerNextViewer puts a fresh viewer offer on the relay, retrying until it
+// succeeds or the session ends. Returns false if no more viewers can join.
+func (s *Server) offerNextViewer(updater viewerOfferUpdater) bool {
+	peer, offer, err := s.newViewerPeer()
+	if err != nil {
+		s.log("⚠ No more viewers can join: %v\n", err)
+		return false
+	}
+
+	for {
+		err := updater.UpdateViewerSession(offer)
+		if err == nil {
+			break
+		}
+		if errors.Is(err, signaling.ErrSessionExpired) {
+			_ = peer.Close()
+			s.log("⚠ No more viewers can join: %v\n", err)
+			return false
+		}
+		s.log("⚠ Failed to offer the viewer link to the next viewer: %v\n", err)
+		select {
+		case <-s.ctx.Done():
+			_ = peer.Close()
+			return false
+		case <-time.After(viewerOfferRetry):
+		}
+	}
+
+	s.viewerMu.Lock()
+	defer s.viewerMu.Unlock()
+	if s.viewersClosed {
+		_ = peer.Close()
+		return false
+	}
+	s.viewerPeer = peer
+	return true
+}
@@ -0,0 +1,260 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+
+	"github.com/artpar/terminal-tunnel/internal/signaling"
+	ttwebrtc "github.com/artpar/terminal-tunnel/internal/webrtc"
+)
+
+// memViewerSignaler is a memSignaler that also holds the viewer offer, as
+// the relay does for public sessions. Viewers read it with waitViewerOffer
+// and reply with postViewerAnswer.
+type memViewerSignaler struct {
+	*memSignaler
+	viewerKey    string
+	viewerOffer  string
+	viewerSeq    int // Incremented whenever the viewer offer changes
+	viewerAnswer string
+}
+
+func newMemViewerSignaler() *memViewerSignaler {
+	return &memViewerSignaler{memSignaler: newMemSignaler()}
+}
+
+func (m *memViewerSignaler) CreateSessionWithViewer(sdp, salt, viewerSDP, viewerKey string) (string, string, error) {
+	code, err := m.CreateSession(sdp, salt)
+	if err != nil {
+		return "", "", err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.viewerKey = viewerKey
+	m.setViewerOffer(viewerSDP)
+	return code, code + "V", nil
+}
+
+func (m *memViewerSignaler) UpdateViewerSession(viewerSDP string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.code == "" {
+		return signaling.ErrSessionExpired
+	}
+	m.setViewerOffer(viewerSDP)
+	return nil
+}
+
+// setViewerOffer replaces the viewer offer and clears its answer; the
+// caller holds mu
+func (m *memViewerSignaler) setViewerOffer(sdp string) {
+	m.viewerOffer = sdp
+	m.viewerSeq++
+	m.viewerAnswer = ""
+	m.notify()
+}
+
+func (m *memViewerSignaler) WaitForViewerAnswerWithContext(ctx context.Context) (string, error) {
+	for {
+		m.mu.Lock()
+		answer, changed := m.viewerAnswer, m.changed
+		m.viewerAnswer = ""
+		m.mu.Unlock()
+		if answer != "" {
+			return answer, nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// waitViewerOffer returns the first viewer offer newer than seq
+func (m *memViewerSignaler) waitViewerOffer(t *testing.T, seq int) (offer, key string, offerSeq int) {
+	t.Helper()
+	deadline := time.After(20 * time.Second)
+	for {
+		m.mu.Lock()
+		offer, key, offerSeq = m.viewerOffer, m.viewerKey, m.viewerSeq
+		changed := m.changed
+		m.mu.Unlock()
+		if offerSeq > seq && offer != "" {
+			return offer, key, offerSeq
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			t.Fatalf("no viewer offer after #%d", seq)
+		}
+	}
+}
+
+// postViewerAnswer stores a viewer's answer to the current viewer offer
+func (m *memViewerSignaler) postViewerAnswer(answer string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.viewerAnswer = answer
+	m.notify()
+}
+
+// connectViewer answers the first viewer offer newer than seq and waits for
+// the data channel to open. Returns the offer sequence it answered.
+func connectViewer(t *testing.T, sig *memViewerSignaler, seq int) (*testClient, int) {
+	t.Helper()
+	offer, keyB64, offerSeq := sig.waitViewerOffer(t, seq)
+	keyBytes, err := base64.StdEncoding.DecodeString(keyB64)
+	if err != nil {
+		t.Fatalf("bad viewer key: %v", err)
+	}
+	var key [32]byte
+	copy(key[:], keyBytes)
+
+	peer, err := ttwebrtc.NewPeer(ttwebrtc.ConfigWithoutTURN())
+	if err != nil {
+		t.Fatalf("NewPeer failed: %v", err)
+	}
+	dcOpen := make(chan *webrtc.DataChannel, 1)
+	peer.OnDataChannel(func(dc *webrtc.DataChannel) {
+		dc.OnOpen(func() {
+			dcOpen <- dc
+		})
+	})
+	if err := peer.SetRemoteDescription(webrtc.SDPTypeOffer, offer); err != nil {
+		t.Fatalf("SetRemoteDescription failed: %v", err)
+	}
+	answer, err := peer.CreateAnswer()
+	if err != nil {
+		t.Fatalf("CreateAnswer failed: %v", err)
+	}
+	sig.postViewerAnswer(answer)
+
+	c := &testClient{peer: peer}
+	select {
+	case dc := <-dcOpen:
+		c.channel = ttwebrtc.NewEncryptedChannel(dc, &key)
+		c.channel.OnData(func(data []byte) {
+			c.mu.Lock()
+			c.output.Write(data)
+			c.mu.Unlock()
+		})
+	case <-time.After(20 * time.Second):
+		peer.Close()
+		t.Fatal("timeout waiting for viewer data channel")
+	}
+	return c, offerSeq
+}
+
+// expectOutput waits for text to show up in what c received
+func (c *testClient) expectOutput(t *testing.T, text string) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		found := bytes.Contains(c.output.Bytes(), []byte(text))
+		c.mu.Unlock()
+		if found {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("%q never arrived", text)
+}
+
+func TestServerViewersJoinOverTime(t *testing.T) {
+	sig := newMemViewerSignaler()
+	s := startTestServer(t, sig.memSignaler, Callbacks{}, func(opts *Options) {
+		opts.Public = true
+		opts.Signaler = sig
+	})
+
+	client, _ := connectClient(t, sig.memSignaler, "test-password", 0)
+	defer client.Close()
+	client.expectEcho(t, "hello")
+
+	// Each viewer answers the offer put up after the previous one joined,
+	// and everyone connected so far sees the output
+	var viewers []*testClient
+	seq := 0
+	for i := 1; i <= 3; i++ {
+		v, offerSeq := connectViewer(t, sig, seq)
+		defer v.Close()
+		seq = offerSeq
+		viewers = append(viewers, v)
+
+		deadline := time.Now().Add(10 * time.Second)
+		for s.viewerCount() != i && time.Now().Before(deadline) {
+			time.Sleep(50 * time.Millisecond)
+		}
+		if n := s.viewerCount(); n != i {
+			t.Fatalf("after viewer %d joined, viewerCount() = %d", i, n)
+		}
+
+		line := fmt.Sprintf("after-viewer-%d", i)
+		client.expectEcho(t, line)
+		for _, v := range viewers {
+			v.expectOutput(t, line)
+		}
+	}
+}
+
+func TestServerViewersPerIPLimit(t *testing.T) {
+	sig := newMemViewerSignaler()
+	s := startTestServer(t, sig.memSignaler, Callbacks{}, func(opts *Options) {
+		opts.Public = true
+		opts.Signaler = sig
+		opts.MaxViewersPerIP = 2
+	})
+
+	client, _ := connectClient(t, sig.memSignaler, "test-password", 0)
+	defer client.Close()
+
+	// The viewers connect from whichever of this machine's addresses ICE
+	// picks, so keep adding them until one is turned away. With the limit
+	// at 2 that happens by the time each address has had three.
+	seq := 0
+	for i := 1; ; i++ {
+		if i > 10 {
+			t.Fatal("no viewer was turned away")
+		}
+		watching := s.viewerCount()
+		v, offerSeq := connectViewer(t, sig, seq)
+		defer v.Close()
+		seq = offerSeq
+
+		deadline := time.Now().Add(10 * time.Second)
+		for s.viewerCount() == watching && v.channel.Ready() && time.Now().Before(deadline) {
+			time.Sleep(50 * time.Millisecond)
+		}
+		if s.viewerCount() > watching {
+			continue
+		}
+		if v.channel.Ready() {
+			t.Fatalf("viewer %d was neither let in nor turned away", i)
+		}
+		break
+	}
+
+	// Only a viewer from an address at the limit is turned away
+	s.viewerMu.Lock()
+	defer s.viewerMu.Unlock()
+	full := false
+	for _, v := range s.viewerList {
+		switch n := s.viewersFromLocked(v.address); {
+		case n > 2:
+			t.Errorf("%d viewers from %s, want at most 2", n, v.address)
+		case n == 2:
+			full = true
+		}
+	}
+	if !full {
+		t.Errorf("a viewer was turned away with no address at the limit (%d watching)", len(s.viewerList))
+	}
+}
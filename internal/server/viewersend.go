@@ -0,0 +1,160 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// EnvViewerSendWorkers sets how many goroutines send output to public
+// viewers. The workers are shared by every session in the process, so a
+// daemon with many sessions and viewers keeps a bounded number.
+const EnvViewerSendWorkers = "TT_VIEWER_SEND_WORKERS"
+
+// DefaultViewerSendWorkers is the number of viewer send workers when
+// EnvViewerSendWorkers is unset
+const DefaultViewerSendWorkers = 16
+
+var (
+	viewerPoolOnce sync.Once
+	viewerPool     *sendPool
+)
+
+// viewerSendPool returns the process-wide pool for viewer sends, sized from
+// EnvViewerSendWorkers on first use
+func viewerSendPool() *sendPool {
+	viewerPoolOnce.Do(func() {
+		viewerPool = newSendPool(viewerSendWorkersFromEnv())
+	})
+	return viewerPool
+}
+
+// viewerSendWorkersFromEnv returns the worker count from
+// EnvViewerSendWorkers, or DefaultViewerSendWorkers when it is unset or
+// invalid
+func viewerSendWorkersFromEnv() int {
+	value := os.Getenv(EnvViewerSendWorkers)
+	if value == "" {
+		return DefaultViewerSendWorkers
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		fmt.Fprintf(os.Stderr, "Ignoring invalid %s=%q, using %d\n", EnvViewerSendWorkers, value, DefaultViewerSendWorkers)
+		return DefaultViewerSendWorkers
+	}
+	return n
+}
+
+// sendPool runs queued sends on a fixed number of workers, started on
+// first use. Queues take turns: a worker sends what one queue holds, then
+// puts it at the back of the line if more arrived meanwhile.
+type sendPool struct {
+	workers int
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	ready   []*sendQueue // Queues with output waiting and no worker on them
+	started bool
+}
+
+func newSendPool(workers int) *sendPool {
+	p := &sendPool{workers: workers}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// schedule puts q in line for a worker
+func (p *sendPool) schedule(q *sendQueue) {
+	p.mu.Lock()
+	if !p.started {
+		p.started = true
+		for i := 0; i < p.workers; i++ {
+			go p.work()
+		}
+	}
+	p.ready = append(p.ready, q)
+	p.mu.Unlock()
+	p.cond.Signal()
+}
+
+func (p *sendPool) work() {
+	for {
+		p.mu.Lock()
+		for len(p.ready) == 0 {
+			p.cond.Wait()
+		}
+		q := p.ready[0]
+		p.ready[0] = nil
+		p.ready = p.ready[1:]
+		p.mu.Unlock()
+
+		q.run()
+	}
+}
+
+// sendQueue is one viewer's output waiting for a pool worker. Pushing never
+// blocks, and the viewer gets its output in order.
+type sendQueue struct {
+	send   func([]byte) error
+	pool   *sendPool
+	closed atomic.Bool
+
+	mu        sync.Mutex
+	pending   [][]byte
+	scheduled bool // In the pool's line or being sent by a worker
+}
+
+func newSendQueue(pool *sendPool, send func([]byte) error) *sendQueue {
+	return &sendQueue{send: send, pool: pool}
+}
+
+// push queues data for sending. data must not be modified afterwards.
+func (q *sendQueue) push(data []byte) {
+	if len(data) == 0 || q.closed.Load() {
+		return
+	}
+	q.mu.Lock()
+	q.pending = append(q.pending, data)
+	if q.scheduled {
+		q.mu.Unlock()
+		return
+	}
+	q.scheduled = true
+	q.mu.Unlock()
+	q.pool.schedule(q)
+}
+
+// run sends what is queued; a pool worker calls it
+func (q *sendQueue) run() {
+	q.mu.Lock()
+	batch := q.pending
+	q.pending = nil
+	q.mu.Unlock()
+
+	for _, data := range batch {
+		if q.closed.Load() {
+			break
+		}
+		_ = q.send(data) // Best effort: a viewer that fails is on its way out
+	}
+
+	q.mu.Lock()
+	if len(q.pending) == 0 || q.closed.Load() {
+		q.pending = nil
+		q.scheduled = false
+		q.mu.Unlock()
+		return
+	}
+	q.mu.Unlock()
+	q.pool.schedule(q)
+}
+
+// close drops what is queued and any later pushes
+func (q *sendQueue) close() {
+	q.closed.Store(true)
+	q.mu.Lock()
+	q.pending = nil
+	q.mu.Unlock()
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSendQueueOrder(t *testing.T) {
+	pool := newSendPool(4)
+	var mu sync.Mutex
+	var got []string
+	done := make(chan struct{})
+	q := newSendQueue(pool, func(data []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, string(data))
+		if len(got) == 100 {
+			close(done)
+		}
+		return nil
+	})
+
+	for i := 0; i < 100; i++ {
+		q.push([]byte(fmt.Sprint(i)))
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("sends not delivered")
+	}
+	for i, data := range got {
+		if data != fmt.Sprint(i) {
+			t.Fatalf("send %d = %q, want %q", i, data, fmt.Sprint(i))
+		}
+	}
+}
+
+func TestSendPoolBounded(t *testing.T) {
+	const workers = 3
+	pool := newSendPool(workers)
+	var running, peak atomic.Int32
+	var wg sync.WaitGroup
+	send := func([]byte) error {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		running.Add(-1)
+		wg.Done()
+		return nil
+	}
+
+	for i := 0; i < 20; i++ {
+		q := newSendQueue(pool, send)
+		for j := 0; j < 5; j++ {
+			wg.Add(1)
+			q.push([]byte("x"))
+		}
+	}
+	wg.Wait()
+	if p := peak.Load(); p > workers {
+		t.Errorf("%d sends ran at once, want at most %d", p, workers)
+	}
+}
+
+func TestSendQueueClose(t *testing.T) {
+	pool := newSendPool(1)
+	release := make(chan struct{})
+	var sent atomic.Int32
+	q := newSendQueue(pool, func([]byte) error {
+		sent.Add(1)
+		<-release
+		return nil
+	})
+
+	q.push([]byte("a"))
+	for sent.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// Queued behind the blocked send, then dropped
+	q.push([]byte("b"))
+	q.close()
+	q.push([]byte("c"))
+	close(release)
+
+	time.Sleep(20 * time.Millisecond)
+	if n := sent.Load(); n != 1 {
+		t.Errorf("%d sends after close, want only the one in progress", n)
+	}
+}
+
+func TestViewerSendWorkersFromEnv(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"", DefaultViewerSendWorkers},
+		{"4", 4},
+		{"0", DefaultViewerSendWorkers},
+		{"many", DefaultViewerSendWorkers},
+	}
+	for _, tt := range tests {
+		t.Setenv(EnvViewerSendWorkers, tt.value)
+		if got := viewerSendWorkersFromEnv(); got != tt.want {
+			t.Errorf("%s=%q: got %d, want %d", EnvViewerSendWorkers, tt.value, got, tt.want)
+		}
+	}
+}
+
+// Viewer sends for 50 sessions with 20 viewers each, with a goroutine per
+// send as before the pool and with the pool. Each send takes a millisecond,
+// like a viewer on a slow link; peak-goroutines is the most seen at once.
+const (
+	benchSessions = 50
+	benchViewers  = 20
+	benchSendTime = time.Millisecond
+)
+
+func BenchmarkViewerSendsGoroutinePerSend(b *testing.B) {
+	benchmarkViewerSends(b, func(send func([]byte) error) func([]byte) {
+		return func(data []byte) { go send(data) }
+	})
+}
+
+func BenchmarkViewerSendsPool(b *testing.B) {
+	pool := newSendPool(DefaultViewerSendWorkers)
+	benchmarkViewerSends(b, func(send func([]byte) error) func([]byte) {
+		return newSendQueue(pool, send).push
+	})
+}
+
+func benchmarkViewerSends(b *testing.B, newViewer func(send func([]byte) error) func([]byte)) {
+	var wg sync.WaitGroup
+	send := func([]byte) error {
+		time.Sleep(benchSendTime)
+		wg.Done()
+		return nil
+	}
+	viewers := make([]func([]byte), benchSessions*benchViewers)
+	for i := range viewers {
+		viewers[i] = newViewer(send)
+	}
+
+	var peak int
+	data := []byte("output")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		wg.Add(len(viewers))
+		for _, push := range viewers {
+			push(data)
+		}
+		peak = max(peak, runtime.NumGoroutine())
+		wg.Wait()
+	}
+	b.ReportMetric(float64(peak), "peak-goroutines")
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/artpar/terminal-tunnel/internal/protocol"
+)
+
+// ErrNoViewer is returned by GrantViewerWrite when no public viewer is
+// connected, or none by the number given
+var ErrNoViewer = errors.New("no viewer is connected")
+
+// ErrWhichViewer is returned by GrantViewerWrite when several viewers are
+// connected and none was named
+var ErrWhichViewer = errors.New("several viewers are connected; name one")
+
+// ErrViewerCantWrite is returned by GrantViewerWrite when the viewer's
+// client predates write access and would never send input
+var ErrViewerCantWrite = errors.New("the viewer's client does not support typing")
+
+// ErrSessionReadOnly is returned by GrantViewerWrite when the session was
+// started with Options.ReadOnly, where nobody but the host may type
+var ErrSessionReadOnly = errors.New("the session is read-only")
+
+// GrantViewerWrite lets a connected public viewer type into the session,
+// e.g. to hand a student the keyboard. Viewers are numbered from 1 in the
+// order they joined; id 0 picks the only one connected. One viewer may type
+// at a time, so the grant moves from any other viewer. The client and the
+// host can still type too. The grant ends with RevokeViewerWrite or when
+// the viewer disconnects. Returns the viewer's number, and false if it
+// could already type. A read-only session can't be granted.
+func (s *Server) GrantViewerWrite(id int) (int, bool, error) {
+	if s.opts.ReadOnly {
+		return 0, false, ErrSessionReadOnly
+	}
+	s.viewerMu.Lock()
+	v, err := s.findViewerLocked(id)
+	if err != nil {
+		s.viewerMu.Unlock()
+		return 0, false, err
+	}
+	if caps, ok := v.channel.Capabilities(); !ok || !caps.Has(protocol.FeatureWriteAccess) {
+		s.viewerMu.Unlock()
+		return v.id, false, ErrViewerCantWrite
+	}
+	if s.viewerWriter == v.id {
+		s.viewerMu.Unlock()
+		return v.id, false, nil
+	}
+	previous := s.viewerByIDLocked(s.viewerWriter)
+	s.viewerWriter = v.id
+	s.viewerMu.Unlock()
+
+	if previous != nil {
+		_ = previous.channel.SendWriteAccess(false)
+		sendNotice(previous.channel, "The host made this session read-only for you again")
+	}
+	s.log("✓ Viewer %d can type\n", v.id)
+	_ = v.channel.SendWriteAccess(true)
+	sendNotice(v.channel, "The host let you type in this session")
+	sendNotice(s.currentChannel(), "A viewer can now type in this session")
+	return v.id, true, nil
+}
+
+// RevokeViewerWrite makes the viewer that could type read-only again.
+// Returns false if none could.
+func (s *Server) RevokeViewerWrite() (bool, error) {
+	s.viewerMu.Lock()
+	v := s.viewerByIDLocked(s.viewerWriter)
+	s.viewerWriter = 0
+	s.viewerMu.Unlock()
+	if v == nil {
+		return false, nil
+	}
+
+	s.log("✓ Viewer %d is read-only again\n", v.id)
+	_ = v.channel.SendWriteAccess(false)
+	sendNotice(v.channel, "The host made this session read-only for you again")
+	sendNotice(s.currentChannel(), "The viewer can no longer type")
+	return true, nil
+}
+
+// ViewerCanWrite reports whether a viewer has been let to type
+func (s *Server) ViewerCanWrite() bool {
+	s.viewerMu.Lock()
+	defer s.viewerMu.Unlock()
+	return s.viewerWriter != 0
+}
+
+// findViewerLocked returns the connected viewer numbered id, or the only
+// one for id 0. The caller holds viewerMu.
+func (s *Server) findViewerLocked(id int) (*viewer, error) {
+	if id != 0 {
+		if v := s.viewerByIDLocked(id); v != nil {
+			return v, nil
+		}
+		return nil, fmt.Errorf("%w as viewer %d", ErrNoViewer, id)
+	}
+	switch len(s.viewerList) {
+	case 0:
+		return nil, ErrNoViewer
+	case 1:
+		return s.viewerList[0], nil
+	}
+	ids := make([]string, len(s.viewerList))
+	for i, v := range s.viewerList {
+		ids[i] = strconv.Itoa(v.id)
+	}
+	return nil, fmt.Errorf("%w (connected: %s)", ErrWhichViewer, strings.Join(ids, ", "))
+}
+
+// viewerByIDLocked returns the connected viewer numbered id, or nil. The
+// caller holds viewerMu.
+func (s *Server) viewerByIDLocked(id int) *viewer {
+	if id == 0 {
+		return nil
+	}
+	for _, v := range s.viewerList {
+		if v.id == id {
+			return v
+		}
+	}
+	return nil
+}
+
+// handleViewerInput writes a viewer's input to the PTY while it may type,
+// and drops it otherwise, always in a read-only session
+func (s *Server) handleViewerInput(v *viewer, data []byte) {
+	if s.opts.ReadOnly {
+		return
+	}
+	s.viewerMu.Lock()
+	canWrite := s.viewerWriter == v.id
+	s.viewerMu.Unlock()
+
+	bridge := s.GetBridge()
+	if bridge == nil || !canWrite {
+		return
+	}
+	if err := bridge.HandleData(data); errors.Is(err, ErrWriteTimeout) {
+		_ = v.channel.SendData(inputStalledWarning)
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFindViewer(t *testing.T) {
+	s := &Server{}
+	if _, err := s.findViewerLocked(0); !errors.Is(err, ErrNoViewer) {
+		t.Errorf("no viewers: err = %v, want ErrNoViewer", err)
+	}
+
+	s.viewerList = []*viewer{{id: 2}}
+	if v, err := s.findViewerLocked(0); err != nil || v.id != 2 {
+		t.Errorf("one viewer: got %v, %v, want viewer 2", v, err)
+	}
+
+	s.viewerList = append(s.viewerList, &viewer{id: 5})
+	if _, err := s.findViewerLocked(0); !errors.Is(err, ErrWhichViewer) || err.Error() != "several viewers are connected; name one (connected: 2, 5)" {
+		t.Errorf("several viewers: err = %v, want ErrWhichViewer listing them", err)
+	}
+	if v, err := s.findViewerLocked(5); err != nil || v.id != 5 {
+		t.Errorf("viewer 5: got %v, %v", v, err)
+	}
+	if _, err := s.findViewerLocked(3); !errors.Is(err, ErrNoViewer) {
+		t.Errorf("viewer 3: err = %v, want ErrNoViewer", err)
+	}
+}
+
+func TestViewerWriteReadOnly(t *testing.T) {
+	s := &Server{opts: Options{ReadOnly: true}}
+	v := &viewer{id: 1}
+	s.viewerList = []*viewer{v}
+	if _, _, err := s.GrantViewerWrite(0); !errors.Is(err, ErrSessionReadOnly) {
+		t.Errorf("grant: err = %v, want ErrSessionReadOnly", err)
+	}
+	if s.ViewerCanWrite() {
+		t.Error("a viewer can write to a read-only session")
+	}
+
+	pty, err := StartPTY("/bin/sh")
+	if err != nil {
+		t.Fatalf("StartPTY failed: %v", err)
+	}
+	defer pty.Close()
+	bridge := NewBridge(pty, func([]byte) error { return nil })
+	bridge.Start()
+	defer bridge.Close()
+	s.setBridge(bridge)
+
+	// Even a viewer marked as the writer is dropped
+	s.viewerWriter = v.id
+	s.handleViewerInput(v, []byte("echo typed\n"))
+	if in, _ := bridge.Traffic(); in != 0 {
+		t.Errorf("%d bytes of viewer input reached the read-only session", in)
+	}
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+
+	"github.com/artpar/terminal-tunnel/internal/signaling"
+	ttwebrtc "github.com/artpar/terminal-tunnel/internal/webrtc"
+)
+
+// pipeRetryDelay is how long the pipe listener waits before connecting to
+// the relay again after a failure
+const pipeRetryDelay = 5 * time.Second
+
+// closeNotifier is a Transport that also closes done once it has closed
+type closeNotifier struct {
+	ttwebrtc.Transport
+	done chan struct{}
+}
+
+func (t *closeNotifier) OnClose(handler func()) {
+	t.Transport.OnClose(func() {
+		if handler != nil {
+			handler()
+		}
+		close(t.done)
+	})
+}
+
+// startPipeListener keeps a host connection waiting on the relay pipe of
+// the session (Options.AllowWSFallback), so a client whose WebRTC
+// connection fails can reach the session through the relay instead. Each
+// client that joins is handed to the connection loop on s.pipeClients; the
+// next connection is made once it disconnects.
+func (s *Server) startPipeListener(relay *signaling.ShortCodeClient) {
+	go func() {
+		supported := false
+		for {
+			conn, err := relay.AcceptPipe(s.ctx)
+			if err != nil {
+				if s.ctx.Err() != nil {
+					return
+				}
+				if errors.Is(err, signaling.ErrPipeUnsupported) && !supported {
+					s.log("⚠ WebSocket fallback unavailable: %v\n", err)
+					return
+				}
+				// A renewed session code gets its pipe on the next try
+				s.log("  [Debug] Relay pipe: %v\n", err)
+				select {
+				case <-time.After(pipeRetryDelay):
+					continue
+				case <-s.ctx.Done():
+					return
+				}
+			}
+			supported = true
+
+			t := &closeNotifier{
+				Transport: ttwebrtc.NewWebSocketTransport(conn),
+				done:      make(chan struct{}),
+			}
+			channel := ttwebrtc.NewTransportChannel(t, &s.key)
+			channel.SetAltKey(s.pbkdf2Key)
+
+			select {
+			case s.pipeClients <- channel:
+			case <-t.done:
+				continue // The client left before the session took it
+			case <-s.ctx.Done():
+				_ = conn.Close()
+				return
+			}
+			select {
+			case <-t.done:
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// waitForClient waits for the client's answer on the relay, or for a
+// client joining through the relay pipe, whichever comes first. The pipe
+// client's channel is returned in place of an answer.
+func (s *Server) waitForClient(ctx context.Context) (string, *ttwebrtc.EncryptedChannel, error) {
+	if s.pipeClients == nil {
+		answer, err := s.shortCodeClient.WaitForAnswerWithContext(ctx)
+		return answer, nil, err
+	}
+
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		answer string
+		err    error
+	}
+	answered := make(chan result, 1)
+	go func() {
+		answer, err := s.shortCodeClient.WaitForAnswerWithContext(waitCtx)
+		answered <- result{answer, err}
+	}()
+
+	select {
+	case r := <-answered:
+		return r.answer, nil, r.err
+	case channel := <-s.pipeClients:
+		return "", channel, nil
+	}
+}
+
+// pipeUpgradeTimeout bounds the wait for a pipe client's data channel to
+// open before it is left on the pipe
+const pipeUpgradeTimeout = 20 * time.Second
+
+// upgradePipe moves the client on the relay pipe onto WebRTC, using its
+// answer to the standby offer. The session's channel keeps its keys,
+// handlers and keepalive, and the bridge keeps running, so nothing is
+// replayed; only the transport changes. If the data channel doesn't open
+// the client stays on the pipe. The standby is used up either way.
+func (s *Server) upgradePipe(answer string) bool {
+	peer, dc := s.standbyPeer, s.standbyDc
+	s.standbyPeer, s.standbyDc, s.standbyOffer = nil, nil, ""
+	if peer == nil || dc == nil {
+		return false
+	}
+
+	dcOpen := make(chan struct{}, 1)
+	dc.OnOpen(func() {
+		select {
+		case dcOpen <- struct{}{}:
+		default:
+		}
+	})
+	s.diag.recordAnswer(answer)
+	if err := peer.SetRemoteDescription(webrtc.SDPTypeAnswer, answer); err != nil {
+		s.log("⚠ Failed to set answer for WebRTC upgrade: %v\n", err)
+		peer.Close()
+		return false
+	}
+
+	select {
+	case <-dcOpen:
+	case <-time.After(pipeUpgradeTimeout):
+		peer.Close()
+		s.log("  WebRTC still can't connect, staying on the relay WebSocket\n")
+		return false
+	case <-s.ctx.Done():
+		peer.Close()
+		return false
+	}
+	if !s.channel.Ready() {
+		// The pipe dropped meanwhile; the connection loop sees it
+		peer.Close()
+		return false
+	}
+
+	peer.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		s.log("  [WebRTC] Connection state: %s\n", state.String())
+		s.recordState("peer", state.String())
+		if state == webrtc.PeerConnectionStateFailed {
+			s.log("\n✗ WebRTC connection failed\n")
+			select {
+			case s.disconnected <- true:
+			default:
+			}
+		}
+	})
+	peer.OnICEConnectionStateChange(func(state webrtc.ICEConnectionState) {
+		s.log("  [ICE] Connection state: %s\n", state.String())
+		s.recordState("ice", state.String())
+	})
+
+	s.channel.SwitchTransport(ttwebrtc.NewDataChannelTransport(dc))
+	s.setPeer(peer)
+	s.diag.recordConnected(peer.SelectedCandidatePair())
+	s.log("✓ Client moved from the relay WebSocket to WebRTC\n")
+	return true
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pion/webrtc/v4"
+
+	"github.com/artpar/terminal-tunnel/internal/crypto"
+	"github.com/artpar/terminal-tunnel/internal/protocol"
+	ttwebrtc "github.com/artpar/terminal-tunnel/internal/webrtc"
+)
+
+// pipeClient connects a client to s as if it came through the relay pipe,
+// over a local WebSocket in place of the relay
+func pipeClient(t *testing.T, s *Server, sig *memSignaler, password string) *testClient {
+	t.Helper()
+	accepted := make(chan *websocket.Conn, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Upgrade: %v", err)
+			return
+		}
+		accepted <- conn
+	}))
+	t.Cleanup(ts.Close)
+	clientConn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+
+	// The host's side, as startPipeListener sets it up
+	hostChannel := ttwebrtc.NewTransportChannel(ttwebrtc.NewWebSocketTransport(<-accepted), &s.key)
+	hostChannel.SetAltKey(s.pbkdf2Key)
+	select {
+	case s.pipeClients <- hostChannel:
+	case <-time.After(20 * time.Second):
+		t.Fatal("server did not take the pipe client")
+	}
+
+	sig.mu.Lock()
+	saltB64 := sig.salt
+	sig.mu.Unlock()
+	salt, err := base64.StdEncoding.DecodeString(saltB64)
+	if err != nil {
+		t.Fatalf("bad salt: %v", err)
+	}
+	key := crypto.DeriveKey(password, salt)
+
+	c := &testClient{channel: ttwebrtc.NewTransportChannel(ttwebrtc.NewWebSocketTransport(clientConn), &key)}
+	c.channel.OnData(func(data []byte) {
+		c.mu.Lock()
+		c.output.Write(data)
+		c.mu.Unlock()
+	})
+	_ = c.channel.SendKeyPing(protocol.KeySession)
+	return c
+}
+
+func TestServerWithSignalerPipeClient(t *testing.T) {
+	sig := newMemSignaler()
+	s := startTestServer(t, sig, Callbacks{}, func(o *Options) {
+		o.AllowWSFallback = true
+	})
+
+	client, _ := connectClient(t, sig, "test-password", 0)
+	client.expectEcho(t, "webrtc")
+	client.Close()
+
+	// WebRTC fails for the next attempt, so the client comes back through
+	// the relay pipe instead of answering the standby offer
+	pipe := pipeClient(t, s, sig, "test-password")
+	defer pipe.channel.Close()
+	pipe.expectEcho(t, "websocket")
+
+	if got := s.Stats().ConnectionType; got != "websocket" {
+		t.Errorf("ConnectionType = %q, want websocket", got)
+	}
+	if n := s.ReconnectCount(); n != 1 {
+		t.Errorf("ReconnectCount = %d, want 1", n)
+	}
+}
+
+// upgradeClient moves c from the relay pipe onto WebRTC by answering the
+// first offer newer than seq, as the web client does while on the pipe
+func upgradeClient(t *testing.T, sig *memSignaler, c *testClient, seq int) {
+	t.Helper()
+	offer, _, _ := sig.waitOffer(t, seq)
+	peer, err := ttwebrtc.NewPeer(ttwebrtc.ConfigWithoutTURN())
+	if err != nil {
+		t.Fatalf("NewPeer failed: %v", err)
+	}
+	dcOpen := make(chan *webrtc.DataChannel, 1)
+	peer.OnDataChannel(func(dc *webrtc.DataChannel) {
+		dc.OnOpen(func() {
+			dcOpen <- dc
+		})
+	})
+	if err := peer.SetRemoteDescription(webrtc.SDPTypeOffer, offer); err != nil {
+		t.Fatalf("SetRemoteDescription failed: %v", err)
+	}
+	answer, err := peer.CreateAnswer()
+	if err != nil {
+		t.Fatalf("CreateAnswer failed: %v", err)
+	}
+	sig.postAnswer(answer)
+
+	select {
+	case dc := <-dcOpen:
+		c.channel.SwitchTransport(ttwebrtc.NewDataChannelTransport(dc))
+		c.peer = peer
+	case <-time.After(20 * time.Second):
+		peer.Close()
+		t.Fatal("timeout waiting for data channel")
+	}
+}
+
+func TestServerWithSignalerPipeUpgrade(t *testing.T) {
+	sig := newMemSignaler()
+	s := startTestServer(t, sig, Callbacks{}, func(o *Options) {
+		o.AllowWSFallback = true
+	})
+
+	client, seq := connectClient(t, sig, "test-password", 0)
+	client.Close()
+	pipe := pipeClient(t, s, sig, "test-password")
+	pipe.expectEcho(t, "before")
+
+	// seq+1 was the standby the pipe client took the place of; the next
+	// one is offered while it is on the pipe
+	upgradeClient(t, sig, pipe, seq+1)
+	defer pipe.Close()
+	pipe.expectEcho(t, "after")
+
+	if got := s.Stats().ConnectionType; got != "p2p" {
+		t.Errorf("ConnectionType = %q, want p2p", got)
+	}
+	if n := s.ReconnectCount(); n != 1 {
+		t.Errorf("ReconnectCount = %d, want 1 (moving off the pipe is no reconnect)", n)
+	}
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"bytes"
+
+	"github.com/artpar/terminal-tunnel/internal/protocol"
+)
+
+// zmodemHeader is the hex header prefix (ZPAD ZPAD ZDLE 'B') that opens every
+// zmodem session. It is followed by the frame type as two hex digits:
+// "00" (ZRQINIT, sent by sz) or "01" (ZRINIT, sent by rz).
+var zmodemHeader = []byte("**\x18B0")
+
+// zmodemDetector scans PTY output for zmodem session start sequences.
+// It keeps a short tail of the previous chunk so sequences split across
+// reads are still detected. The data itself is never modified.
+type zmodemDetector struct {
+	tail []byte
+}
+
+// Scan checks a chunk of output for a zmodem start sequence.
+// Returns the transfer direction and true if one was found.
+func (d *zmodemDetector) Scan(data []byte) (byte, bool) {
+	window := append(d.tail, data...)
+
+	// Keep enough trailing bytes to match a header split across chunks
+	keep := len(zmodemHeader)
+	if len(window) < keep {
+		keep = len(window)
+	}
+	d.tail = append([]byte(nil), window[len(window)-keep:]...)
+
+	idx := bytes.Index(window, zmodemHeader)
+	if idx < 0 || idx+len(zmodemHeader) >= len(window) {
+		return 0, false
+	}
+
+	switch window[idx+len(zmodemHeader)] {
+	case '0':
+		d.tail = nil // Don't report the same header twice
+		return protocol.ZmodemSend, true
+	case '1':
+		d.tail = nil
+		return protocol.ZmodemReceive, true
+	}
+	return 0, false
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/artpar/terminal-tunnel/internal/protocol"
+)
+
+func TestZmodemDetector(t *testing.T) {
+	tests := []struct {
+		name    string
+		chunks  []string
+		wantDir byte
+		wantHit bool
+	}{
+		{
+			name:    "sz start",
+			chunks:  []string{"rz\r**\x18B00000000000000\r\x8a\x11"},
+			wantDir: protocol.ZmodemSend,
+			wantHit: true,
+		},
+		{
+			name:    "rz start",
+			chunks:  []string{"**\x18B0100000023be50\r\x8a\x11"},
+			wantDir: protocol.ZmodemReceive,
+			wantHit: true,
+		},
+		{
+			name:    "split across reads",
+			chunks:  []string{"rz\r**\x18", "B00000000000000"},
+			wantDir: protocol.ZmodemSend,
+			wantHit: true,
+		},
+		{
+			name:    "direction digit in next read",
+			chunks:  []string{"**\x18B0", "1000000"},
+			wantDir: protocol.ZmodemReceive,
+			wantHit: true,
+		},
+		{
+			name:    "ordinary output",
+			chunks:  []string{"$ ls -la\r\n", "**bold** text\r\n"},
+			wantHit: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d zmodemDetector
+			var dir byte
+			hit := false
+			for _, chunk := range tt.chunks {
+				if got, ok := d.Scan([]byte(chunk)); ok {
+					dir, hit = got, true
+				}
+			}
+			if hit != tt.wantHit {
+				t.Fatalf("hit = %v, want %v", hit, tt.wantHit)
+			}
+			if hit && dir != tt.wantDir {
+				t.Errorf("direction = %d, want %d", dir, tt.wantDir)
+			}
+		})
+	}
+}
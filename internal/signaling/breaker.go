@@ -0,0 +1,74 @@
+package signaling
+
+import (
+	"sync"
+	"time"
+)
+
+// breaker is a circuit breaker for relay requests. After threshold
+// consecutive failures it opens: requests fail at once with ErrRelayDown
+// for cooldown, after which one request at a time is let through to probe
+// the relay. A probe that succeeds closes the circuit; one that fails opens
+// it for another cooldown.
+type breaker struct {
+	threshold int // Consecutive failures that open the circuit; 0 = never
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int       // Consecutive failures
+	openUntil time.Time // When the next probe may go out; zero while closed
+	probing   bool      // A probe is in flight
+	onChange  func(down bool)
+}
+
+// allow returns ErrRelayDown while the circuit is open and no probe is due
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openUntil.IsZero() {
+		return nil
+	}
+	if b.probing || time.Now().Before(b.openUntil) {
+		return ErrRelayDown
+	}
+	b.probing = true
+	return nil
+}
+
+// retryIn returns how long until the circuit lets a probe through
+func (b *breaker) retryIn() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return time.Until(b.openUntil)
+}
+
+// record notes the outcome of a request allow let through
+func (b *breaker) record(failed bool) {
+	b.mu.Lock()
+	wasOpen := !b.openUntil.IsZero()
+	b.probing = false
+	if failed {
+		b.failures++
+		if b.threshold > 0 && b.failures >= b.threshold {
+			b.openUntil = time.Now().Add(b.cooldown)
+		}
+	} else {
+		b.failures = 0
+		b.openUntil = time.Time{}
+	}
+	isOpen := !b.openUntil.IsZero()
+	onChange := b.onChange
+	b.mu.Unlock()
+
+	if isOpen != wasOpen && onChange != nil {
+		onChange(isOpen)
+	}
+}
+
+// abandon notes that a request allow let through was cancelled by the
+// caller, which says nothing about the relay
+func (b *breaker) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
@@ -0,0 +1,107 @@
+package signaling
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	// Each step is one call on a breaker with threshold 3: "allow" must
+	// succeed, "deny" must fail with ErrRelayDown, "fail" and "ok" record
+	// an outcome, "abandon" cancels, and "cool" ends the cooldown
+	tests := []struct {
+		name    string
+		steps   []string
+		changes []bool // onChange calls, in order
+	}{
+		{
+			name:  "below threshold",
+			steps: []string{"allow", "fail", "allow", "fail", "allow"},
+		},
+		{
+			name:    "opens at threshold",
+			steps:   []string{"allow", "fail", "allow", "fail", "allow", "fail", "deny", "deny"},
+			changes: []bool{true},
+		},
+		{
+			name:  "success resets the count",
+			steps: []string{"allow", "fail", "allow", "fail", "allow", "ok", "allow", "fail", "allow", "fail", "allow"},
+		},
+		{
+			name:    "one probe at a time",
+			steps:   []string{"allow", "fail", "allow", "fail", "allow", "fail", "cool", "allow", "deny", "deny"},
+			changes: []bool{true},
+		},
+		{
+			name:    "successful probe closes",
+			steps:   []string{"allow", "fail", "allow", "fail", "allow", "fail", "cool", "allow", "ok", "allow", "allow"},
+			changes: []bool{true, false},
+		},
+		{
+			name:    "failed probe reopens",
+			steps:   []string{"allow", "fail", "allow", "fail", "allow", "fail", "cool", "allow", "fail", "deny"},
+			changes: []bool{true},
+		},
+		{
+			name:  "abandon is not a failure",
+			steps: []string{"allow", "fail", "allow", "fail", "allow", "abandon", "allow", "abandon", "allow"},
+		},
+		{
+			name:    "abandoned probe frees the slot",
+			steps:   []string{"allow", "fail", "allow", "fail", "allow", "fail", "cool", "allow", "deny", "abandon", "allow"},
+			changes: []bool{true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var changes []bool
+			b := &breaker{
+				threshold: 3,
+				cooldown:  time.Hour,
+				onChange:  func(down bool) { changes = append(changes, down) },
+			}
+			for i, step := range tt.steps {
+				switch step {
+				case "allow":
+					if err := b.allow(); err != nil {
+						t.Fatalf("step %d: allow() = %v, want nil", i, err)
+					}
+				case "deny":
+					if err := b.allow(); !errors.Is(err, ErrRelayDown) {
+						t.Fatalf("step %d: allow() = %v, want ErrRelayDown", i, err)
+					}
+				case "fail":
+					b.record(true)
+				case "ok":
+					b.record(false)
+				case "abandon":
+					b.abandon()
+				case "cool":
+					b.mu.Lock()
+					b.openUntil = time.Now().Add(-time.Second)
+					b.mu.Unlock()
+				}
+			}
+			if len(changes) != len(tt.changes) {
+				t.Fatalf("onChange calls = %v, want %v", changes, tt.changes)
+			}
+			for i := range changes {
+				if changes[i] != tt.changes[i] {
+					t.Fatalf("onChange calls = %v, want %v", changes, tt.changes)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b := &breaker{cooldown: time.Hour}
+	for i := 0; i < 10; i++ {
+		if err := b.allow(); err != nil {
+			t.Fatalf("allow() = %v after %d failures with threshold 0", err, i)
+		}
+		b.record(true)
+	}
+}
@@ -0,0 +1,36 @@
+package signaling
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Bounds on a requested session code (tt start --code). The lower bound
+// matches the length of generated codes; a shorter name is easier to guess
+// than a generated code by more than its length alone suggests.
+const (
+	MinRequestedCodeLength = 8
+	MaxRequestedCodeLength = 32
+)
+
+// NormalizeRequestedCode checks a requested session code and returns it as
+// the relay stores it, in upper case. Codes are letters, digits and inner
+// hyphens; they may not end in V, which marks read-only viewer codes.
+func NormalizeRequestedCode(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) < MinRequestedCodeLength || len(code) > MaxRequestedCodeLength {
+		return "", fmt.Errorf("session code must be %d to %d characters", MinRequestedCodeLength, MaxRequestedCodeLength)
+	}
+	for _, r := range code {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '-' {
+			return "", fmt.Errorf("session code may only contain letters, digits and hyphens")
+		}
+	}
+	if code[0] == '-' || code[len(code)-1] == '-' {
+		return "", fmt.Errorf("session code may not start or end with a hyphen")
+	}
+	if strings.HasSuffix(code, "V") {
+		return "", fmt.Errorf("session code may not end in V (reserved for viewer codes)")
+	}
+	return code, nil
+}
@@ -0,0 +1,33 @@
+package signaling
+
+import "testing"
+
+func TestNormalizeRequestedCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want string // "" = rejected
+	}{
+		{"team-demo", "TEAM-DEMO"},
+		{" build2024 ", "BUILD2024"},
+		{"ABCD1234", "ABCD1234"},
+		{"short", ""},
+		{"this-code-is-far-too-long-to-be-accepted", ""},
+		{"-leading", ""},
+		{"trailing-", ""},
+		{"under_score", ""},
+		{"café-demo", ""},
+		{"team-dev", ""}, // Ends in V, like a viewer code
+	}
+	for _, tt := range tests {
+		got, err := NormalizeRequestedCode(tt.code)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("NormalizeRequestedCode(%q) = %q, want an error", tt.code, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("NormalizeRequestedCode(%q) = %q, %v; want %q", tt.code, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package signaling
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Signaling failures callers may want to tell apart. Methods return them
+// wrapped with what was being done, so test with errors.Is.
+var (
+	// ErrSessionExpired means the relay no longer has the session: it
+	// expired, was deleted, or the relay restarted
+	ErrSessionExpired = errors.New("session expired or not found")
+
+	// ErrRateLimited means the relay refused the request because this
+	// address made too many; it usually clears within a minute
+	ErrRateLimited = errors.New("relay rate limit exceeded")
+
+	// ErrRelayUnreachable means no reply came from the relay: DNS,
+	// connection, TLS or timeout failures
+	ErrRelayUnreachable = errors.New("relay unreachable")
+
+	// ErrRelayDown means the request was not sent because recent requests
+	// to the relay kept failing (see ShortCodeConfig). It is also an
+	// ErrRelayUnreachable.
+	ErrRelayDown = fmt.Errorf("%w: appears down after repeated failures, retrying periodically", ErrRelayUnreachable)
+
+	// ErrRelayVersion means the relay and this build speak relay protocol
+	// versions the other can't; the message says which to upgrade
+	ErrRelayVersion = errors.New("incompatible relay protocol version")
+
+	// ErrCodeTaken means the relay refused a requested session code
+	// because another session holds it (or its viewer code)
+	ErrCodeTaken = errors.New("session code is taken")
+
+	// ErrInvalidSDP means an offer or answer was rejected by the relay or
+	// could not be decoded
+	ErrInvalidSDP = errors.New("invalid SDP")
+
+	// ErrOfferExpired means a manual-mode offer, or the answer to it, came
+	// after the offer's lifetime ended
+	ErrOfferExpired = errors.New("offer expired")
+
+	// ErrStaleAnswer means a manual-mode answer doesn't echo the nonce of
+	// the offer it should answer, or the offer was already answered: it is
+	// left over from another offer, or a replay
+	ErrStaleAnswer = errors.New("answer is not for the current offer")
+)
+
+// unreachable wraps the transport error from a relay request that got no
+// reply. The cause stays visible to errors.Is, e.g. for isConnectionError.
+func unreachable(action string, err error) error {
+	return fmt.Errorf("failed to %s: %w: %w", action, ErrRelayUnreachable, err)
+}
+
+// statusError describes a relay reply with an unexpected status, wrapping
+// the error for its cause where the status says what it was
+func statusError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	msg := strings.TrimSpace(string(body))
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return ErrSessionExpired
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusConflict:
+		return ErrCodeTaken
+	case http.StatusBadRequest:
+		return fmt.Errorf("%w: %s", ErrInvalidSDP, msg)
+	}
+	return fmt.Errorf("relay returned %s: %s", resp.Status, msg)
+}
@@ -4,27 +4,94 @@ import (
 	"bufio"
 	"bytes"
 	"compress/flate"
+	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/skip2/go-qrcode"
 )
 
+// DefaultOfferLifetime is how long a manual-mode offer is accepted. Its
+// candidates go stale well before this, but the salt in it would not, so a
+// photographed QR code stops being useful once it passes.
+const DefaultOfferLifetime = 10 * time.Minute
+
 // ManualSignaling handles QR code and copy-paste based SDP exchange
 type ManualSignaling struct {
-	offer string
-	salt  []byte
+	offer    string
+	salt     []byte
+	expires  time.Time // Zero if the offer never expires
+	nonce    []byte    // The answer must echo it; nil unless RequireNonce
+	answered bool      // An answer with the nonce was accepted
 }
 
-// NewManualSignaling creates a new manual signaling handler
+// NewManualSignaling creates a new manual signaling handler whose offer
+// expires after DefaultOfferLifetime
 func NewManualSignaling(offer string, salt []byte) *ManualSignaling {
 	return &ManualSignaling{
-		offer: offer,
-		salt:  salt,
+		offer:   offer,
+		salt:    salt,
+		expires: time.Now().Add(DefaultOfferLifetime),
+	}
+}
+
+// SetLifetime makes the offer expire lifetime from now. With lifetime <= 0
+// it never expires and is encoded in the older formats without an expiry.
+func (m *ManualSignaling) SetLifetime(lifetime time.Duration) {
+	if lifetime <= 0 {
+		m.expires = time.Time{}
+		return
+	}
+	m.expires = time.Now().Add(lifetime)
+}
+
+// Expired reports whether the offer's lifetime has ended, after which its
+// answer must not be accepted
+func (m *ManualSignaling) Expired() bool {
+	return !m.expires.IsZero() && time.Now().After(m.expires)
+}
+
+// RequireNonce puts a fresh random nonce in the offer, encoded in the
+// CompactVersionNonce format. AcceptAnswer then takes only an answer that
+// echoes it, and only once, so an answer to an older offer, or a replayed
+// copy of this one's, is refused before it reaches WebRTC. Clients that
+// predate the format can't answer such an offer.
+func (m *ManualSignaling) RequireNonce() error {
+	nonce := make([]byte, NonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
 	}
+	m.nonce = nonce
+	return nil
+}
+
+// AcceptAnswer decodes a compact answer to the offer. With RequireNonce, an
+// answer that doesn't echo the nonce, or comes after one that did, is
+// rejected with ErrStaleAnswer.
+func (m *ManualSignaling) AcceptAnswer(encoded string) (string, error) {
+	sdp, nonce, err := DecodeCompactAnswerNonce(encoded)
+	if err != nil {
+		return "", err
+	}
+	if m.nonce == nil {
+		return sdp, nil
+	}
+	switch {
+	case nonce == nil:
+		return "", fmt.Errorf("%w: it carries no nonce; the client must echo the one in the offer", ErrStaleAnswer)
+	case subtle.ConstantTimeCompare(nonce, m.nonce) != 1:
+		return "", fmt.Errorf("%w: its nonce is for another offer", ErrStaleAnswer)
+	case m.answered:
+		return "", fmt.Errorf("%w: the offer was already answered", ErrStaleAnswer)
+	}
+	m.answered = true
+	return sdp, nil
 }
 
 // StripSDP removes unnecessary lines from SDP to reduce size
@@ -88,7 +155,12 @@ func StripSDP(sdp string) string {
 }
 
 // CompactOffer creates a compressed, base64-encoded offer for QR/text
-// Format: base64(version[1] + salt[16] + deflate(SDP))
+// Format: base64(version[1] + expires[4] + len[1] + salt + deflate(SDP)),
+// with expires in Unix seconds (big-endian). Offers that never expire use
+// base64(version[1] + salt[16] + deflate(SDP)), or for salts of any other
+// size base64(version[1] + len[1] + salt + deflate(SDP)). With RequireNonce,
+// base64(version[1] + expires[4] + nonce[8] + len[1] + salt + deflate(SDP)),
+// with expires 0 for an offer that never expires.
 func (m *ManualSignaling) CompactOffer() (string, error) {
 	// Strip SDP to reduce size
 	strippedSDP := StripSDP(m.offer)
@@ -104,58 +176,130 @@ func (m *ManualSignaling) CompactOffer() (string, error) {
 	compressed := buf.Bytes()
 
 	// Build compact format: version + salt + compressed_sdp
-	data := make([]byte, 1+SaltSize+len(compressed))
-	data[0] = CompactVersion
-	copy(data[1:1+SaltSize], m.salt)
-	copy(data[1+SaltSize:], compressed)
+	var data []byte
+	switch {
+	case m.nonce != nil:
+		var expires uint32
+		if !m.expires.IsZero() {
+			expires = uint32(m.expires.Unix()) //nolint:gosec // Unix seconds fit until 2106
+		}
+		data = binary.BigEndian.AppendUint32([]byte{CompactVersionNonce}, expires)
+		data = append(data, m.nonce...)
+		data = append(data, byte(len(m.salt)))
+		data = append(data, m.salt...)
+	case !m.expires.IsZero():
+		data = binary.BigEndian.AppendUint32([]byte{CompactVersionExpiry}, uint32(m.expires.Unix())) //nolint:gosec // Unix seconds fit until 2106
+		data = append(data, byte(len(m.salt)))
+		data = append(data, m.salt...)
+	case len(m.salt) == SaltSize:
+		data = append([]byte{CompactVersion}, m.salt...)
+	default:
+		data = append([]byte{CompactVersionSaltLen, byte(len(m.salt))}, m.salt...)
+	}
+	data = append(data, compressed...)
 
 	// Encode as URL-safe base64
 	return base64.RawURLEncoding.EncodeToString(data), nil
 }
 
-// DecodeCompactOffer decodes a compact offer string
+// DecodeCompactOffer decodes a compact offer string. An offer past its
+// expiry is rejected with ErrOfferExpired.
 func DecodeCompactOffer(encoded string) (sdp string, salt []byte, err error) {
+	sdp, salt, _, err = DecodeCompactOfferNonce(encoded)
+	return sdp, salt, err
+}
+
+// DecodeCompactOfferNonce is DecodeCompactOffer that also returns the
+// offer's nonce, which the answer must echo (CompactAnswerWithNonce), or
+// nil if it has none
+func DecodeCompactOfferNonce(encoded string) (sdp string, salt, nonce []byte, err error) {
 	// Decode base64
 	data, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(encoded))
 	if err != nil {
 		// Try standard base64 as fallback
 		data, err = base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
 		if err != nil {
-			return "", nil, fmt.Errorf("invalid base64: %w", err)
+			return "", nil, nil, fmt.Errorf("%w: invalid base64: %w", ErrInvalidSDP, err)
 		}
 	}
 
 	// Verify minimum length
 	if len(data) < 1+SaltSize+1 {
-		return "", nil, fmt.Errorf("data too short")
+		return "", nil, nil, fmt.Errorf("%w: data too short", ErrInvalidSDP)
 	}
 
-	// Check version
-	version := data[0]
-	if version != CompactVersion {
-		return "", nil, fmt.Errorf("unsupported version: %d", version)
+	// Check version and extract salt
+	var compressed []byte
+	switch version := data[0]; version {
+	case CompactVersion:
+		salt = append([]byte(nil), data[1:1+SaltSize]...)
+		compressed = data[1+SaltSize:]
+	case CompactVersionSaltLen:
+		n := int(data[1])
+		if len(data) < 2+n+1 {
+			return "", nil, nil, fmt.Errorf("%w: data too short", ErrInvalidSDP)
+		}
+		salt = append([]byte(nil), data[2:2+n]...)
+		compressed = data[2+n:]
+	case CompactVersionExpiry:
+		n := int(data[5])
+		if len(data) < 6+n+1 {
+			return "", nil, nil, fmt.Errorf("%w: data too short", ErrInvalidSDP)
+		}
+		expires := time.Unix(int64(binary.BigEndian.Uint32(data[1:5])), 0)
+		if time.Now().After(expires) {
+			return "", nil, nil, fmt.Errorf("%w at %s", ErrOfferExpired, expires.Format(time.Kitchen))
+		}
+		salt = append([]byte(nil), data[6:6+n]...)
+		compressed = data[6+n:]
+	case CompactVersionNonce:
+		if len(data) < 5+NonceSize+1 {
+			return "", nil, nil, fmt.Errorf("%w: data too short", ErrInvalidSDP)
+		}
+		n := int(data[5+NonceSize])
+		if len(data) < 6+NonceSize+n+1 {
+			return "", nil, nil, fmt.Errorf("%w: data too short", ErrInvalidSDP)
+		}
+		if unix := binary.BigEndian.Uint32(data[1:5]); unix != 0 {
+			expires := time.Unix(int64(unix), 0)
+			if time.Now().After(expires) {
+				return "", nil, nil, fmt.Errorf("%w at %s", ErrOfferExpired, expires.Format(time.Kitchen))
+			}
+		}
+		nonce = append([]byte(nil), data[5:5+NonceSize]...)
+		salt = append([]byte(nil), data[6+NonceSize:6+NonceSize+n]...)
+		compressed = data[6+NonceSize+n:]
+	default:
+		return "", nil, nil, fmt.Errorf("%w: unsupported version: %d", ErrInvalidSDP, version)
 	}
 
-	// Extract salt
-	salt = make([]byte, SaltSize)
-	copy(salt, data[1:1+SaltSize])
-
 	// Decompress SDP with deflate
-	compressed := data[1+SaltSize:]
 	reader := flate.NewReader(bytes.NewReader(compressed))
 	defer reader.Close()
 
 	sdpBytes, err := io.ReadAll(reader)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to decompress: %w", err)
+		return "", nil, nil, fmt.Errorf("%w: failed to decompress: %w", ErrInvalidSDP, err)
 	}
 
-	return string(sdpBytes), salt, nil
+	return string(sdpBytes), salt, nonce, nil
 }
 
 // CompactAnswer creates a compressed, base64-encoded answer
 // Format: base64(version[1] + deflate(SDP)) - no salt needed for answer
 func CompactAnswer(answer string) (string, error) {
+	return CompactAnswerWithNonce(answer, nil)
+}
+
+// CompactAnswerWithNonce creates a compact answer echoing the nonce of an
+// offer that has one (DecodeCompactOfferNonce)
+// Format: base64(version[1] + nonce[8] + deflate(SDP)), or that of
+// CompactAnswer for a nil nonce
+func CompactAnswerWithNonce(answer string, nonce []byte) (string, error) {
+	if nonce != nil && len(nonce) != NonceSize {
+		return "", fmt.Errorf("nonce must be %d bytes", NonceSize)
+	}
+
 	var buf bytes.Buffer
 	writer, err := flate.NewWriter(&buf, flate.BestCompression)
 	if err != nil {
@@ -165,43 +309,60 @@ func CompactAnswer(answer string) (string, error) {
 	_ = writer.Close()
 	compressed := buf.Bytes()
 
-	// Build compact format: version + compressed_sdp
-	data := make([]byte, 1+len(compressed))
-	data[0] = CompactVersion
-	copy(data[1:], compressed)
+	// Build compact format: version + [nonce] + compressed_sdp
+	data := []byte{CompactVersion}
+	if nonce != nil {
+		data = append([]byte{CompactVersionNonce}, nonce...)
+	}
+	data = append(data, compressed...)
 
 	return base64.RawURLEncoding.EncodeToString(data), nil
 }
 
 // DecodeCompactAnswer decodes a compact answer string
 func DecodeCompactAnswer(encoded string) (string, error) {
+	sdp, _, err := DecodeCompactAnswerNonce(encoded)
+	return sdp, err
+}
+
+// DecodeCompactAnswerNonce is DecodeCompactAnswer that also returns the
+// nonce the answer echoes, or nil if it has none
+func DecodeCompactAnswerNonce(encoded string) (sdp string, nonce []byte, err error) {
 	data, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(encoded))
 	if err != nil {
 		data, err = base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
 		if err != nil {
-			return "", fmt.Errorf("invalid base64: %w", err)
+			return "", nil, fmt.Errorf("%w: invalid base64: %w", ErrInvalidSDP, err)
 		}
 	}
 
 	if len(data) < 2 {
-		return "", fmt.Errorf("data too short")
+		return "", nil, fmt.Errorf("%w: data too short", ErrInvalidSDP)
 	}
 
-	version := data[0]
-	if version != CompactVersion {
-		return "", fmt.Errorf("unsupported version: %d", version)
+	var compressed []byte
+	switch version := data[0]; version {
+	case CompactVersion:
+		compressed = data[1:]
+	case CompactVersionNonce:
+		if len(data) < 1+NonceSize+1 {
+			return "", nil, fmt.Errorf("%w: data too short", ErrInvalidSDP)
+		}
+		nonce = append([]byte(nil), data[1:1+NonceSize]...)
+		compressed = data[1+NonceSize:]
+	default:
+		return "", nil, fmt.Errorf("%w: unsupported version: %d", ErrInvalidSDP, version)
 	}
 
-	compressed := data[1:]
 	reader := flate.NewReader(bytes.NewReader(compressed))
 	defer reader.Close()
 
 	sdpBytes, err := io.ReadAll(reader)
 	if err != nil {
-		return "", fmt.Errorf("failed to decompress: %w", err)
+		return "", nil, fmt.Errorf("%w: failed to decompress: %w", ErrInvalidSDP, err)
 	}
 
-	return string(sdpBytes), nil
+	return string(sdpBytes), nonce, nil
 }
 
 // GenerateQR creates an ASCII QR code from the compact offer
@@ -287,13 +448,19 @@ func (m *ManualSignaling) PrintInstructions(sessionID, password string) {
 	fmt.Println()
 	fmt.Println("  ─────────────────────────")
 	fmt.Println()
+	if !m.expires.IsZero() {
+		fmt.Printf("  The code expires at %s.\n", m.expires.Format(time.Kitchen))
+	}
+	if m.nonce != nil {
+		fmt.Println("  Only one answer to this code is accepted.")
+	}
 	fmt.Println("  Open terminal-tunnel client and paste this code.")
 	fmt.Println("  Then enter the answer code below:")
 	fmt.Println()
 }
 
-// ReadAnswer reads an answer from stdin
-func ReadAnswer() (string, error) {
+// ReadAnswer reads an answer to the offer from stdin (see AcceptAnswer)
+func (m *ManualSignaling) ReadAnswer() (string, error) {
 	fmt.Print("Enter the answer code from the client: ")
 
 	reader := bufio.NewReader(os.Stdin)
@@ -303,5 +470,5 @@ func ReadAnswer() (string, error) {
 	}
 
 	// Decode the compact answer
-	return DecodeCompactAnswer(strings.TrimSpace(line))
+	return m.AcceptAnswer(strings.TrimSpace(line))
 }
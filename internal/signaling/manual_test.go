@@ -0,0 +1,67 @@
+package signaling
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+const testSDP = "v=0\r\no=- 1 2 IN IP4 127.0.0.1\r\ns=-\r\n"
+
+func TestOfferNonce(t *testing.T) {
+	salt := bytes.Repeat([]byte{7}, SaltSize)
+	m := NewManualSignaling(testSDP, salt)
+	if err := m.RequireNonce(); err != nil {
+		t.Fatal(err)
+	}
+	offer, err := m.CompactOffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sdp, gotSalt, nonce, err := DecodeCompactOfferNonce(offer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sdp != StripSDP(testSDP) || !bytes.Equal(gotSalt, salt) || len(nonce) != NonceSize {
+		t.Fatalf("decoded offer = %q, %x, %x", sdp, gotSalt, nonce)
+	}
+
+	// An answer without the nonce, or with another offer's, is refused
+	plain, _ := CompactAnswer("answer")
+	if _, err := m.AcceptAnswer(plain); !errors.Is(err, ErrStaleAnswer) {
+		t.Errorf("answer without nonce: err = %v, want ErrStaleAnswer", err)
+	}
+	other, _ := CompactAnswerWithNonce("answer", make([]byte, NonceSize))
+	if _, err := m.AcceptAnswer(other); !errors.Is(err, ErrStaleAnswer) {
+		t.Errorf("answer to another offer: err = %v, want ErrStaleAnswer", err)
+	}
+
+	// The echoing answer is accepted once
+	answer, err := CompactAnswerWithNonce("answer", nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := m.AcceptAnswer(answer); err != nil || got != "answer" {
+		t.Fatalf("AcceptAnswer = %q, %v", got, err)
+	}
+	if _, err := m.AcceptAnswer(answer); !errors.Is(err, ErrStaleAnswer) {
+		t.Errorf("replayed answer: err = %v, want ErrStaleAnswer", err)
+	}
+}
+
+func TestOfferWithoutNonce(t *testing.T) {
+	// Without RequireNonce, offers and answers keep their older formats
+	m := NewManualSignaling(testSDP, bytes.Repeat([]byte{7}, SaltSize))
+	offer, err := m.CompactOffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, nonce, err := DecodeCompactOfferNonce(offer); err != nil || nonce != nil {
+		t.Fatalf("nonce = %x, err = %v, want none", nonce, err)
+	}
+	answer, _ := CompactAnswer("answer")
+	if got, err := m.AcceptAnswer(answer); err != nil || got != "answer" {
+		t.Fatalf("AcceptAnswer = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,95 @@
+package signaling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// The relay pipe carries a session's encrypted terminal data over
+// WebSockets, for clients that can't connect over WebRTC at all, not even
+// through TURN. The host opts in when creating the session (EnablePipe);
+// the relay then forwards frames between one host and one client
+// connection at /session/{code}/pipe, seeing only ciphertext.
+const (
+	// PipeRoleHost and PipeRoleClient are the role query values of a pipe
+	// connection. The host's must carry the session's owner token.
+	PipeRoleHost   = "host"
+	PipeRoleClient = "client"
+
+	// PipePaired is the text message the relay sends the host's pipe once
+	// a client is on the other end. Data frames are binary messages.
+	PipePaired = "paired"
+)
+
+// ErrPipeUnsupported means the relay has no pipe for the session: it
+// predates the pipe, or the session was created without EnablePipe
+var ErrPipeUnsupported = errors.New("relay does not offer a WebSocket pipe for this session")
+
+// EnablePipe makes later CreateSession calls ask the relay to allow the
+// pipe for the session, so clients can fall back to it
+func (c *ShortCodeClient) EnablePipe() {
+	c.pipe = true
+}
+
+// PipeURL returns the WebSocket URL of the relay pipe for code
+func PipeURL(relayURL, code, role string) (string, error) {
+	u, err := url.Parse(relayURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid relay URL: %w", err)
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/session/" + code + "/pipe"
+	u.RawQuery = url.Values{"role": {role}}.Encode()
+	return u.String(), nil
+}
+
+// AcceptPipe connects to the relay pipe as the session's host and waits
+// until a client joins it, returning the connection to carry the session's
+// frames. It returns ErrPipeUnsupported if the relay refuses the pipe.
+func (c *ShortCodeClient) AcceptPipe(ctx context.Context) (*websocket.Conn, error) {
+	pipeURL, err := PipeURL(c.relayURL, c.code, PipeRoleHost)
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	header.Set(deleteTokenHeader, c.deleteToken)
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, pipeURL, header)
+	if err != nil {
+		if resp != nil {
+			switch resp.StatusCode {
+			case http.StatusNotFound, http.StatusForbidden:
+				return nil, ErrPipeUnsupported
+			}
+			return nil, fmt.Errorf("failed to open relay pipe: %w", statusError(resp))
+		}
+		return nil, unreachable("open relay pipe", err)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			_ = conn.Close()
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("relay pipe closed: %w", err)
+		}
+		if msgType == websocket.TextMessage && string(data) == PipePaired {
+			return conn, nil
+		}
+	}
+}
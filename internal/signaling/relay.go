@@ -18,6 +18,38 @@ type RelayClient struct {
 	conn      *websocket.Conn
 	mu        sync.Mutex
 	closed    bool
+
+	onClientInterest func() // Called when a client fetches the offer
+}
+
+// relayDialer offers this build's relay protocol version as a subprotocol.
+// Relays that predate versioning ignore it.
+var relayDialer = &websocket.Dialer{
+	Proxy:            websocket.DefaultDialer.Proxy,
+	HandshakeTimeout: websocket.DefaultDialer.HandshakeTimeout,
+	Subprotocols:     []string{RelaySubprotocol(RelayProtocolVersion)},
+}
+
+// checkRelaySubprotocol checks the subprotocol the relay picked from
+// relayDialer's offer. Relays that predate versioning pick none.
+func checkRelaySubprotocol(conn *websocket.Conn) error {
+	protocol := conn.Subprotocol()
+	if protocol == "" {
+		return nil
+	}
+	if version, ok := ParseRelaySubprotocol(protocol); !ok || version != RelayProtocolVersion {
+		return fmt.Errorf("%w: relay chose subprotocol %q, this build offered %q", ErrRelayVersion, protocol, RelaySubprotocol(RelayProtocolVersion))
+	}
+	return nil
+}
+
+// relayError turns an error message from the relay into an error. The relay
+// names its own protocol version in the message when it refuses ours.
+func relayError(msg RelayMessage) error {
+	if msg.Version != 0 {
+		return fmt.Errorf("%w: %s", ErrRelayVersion, msg.Error)
+	}
+	return fmt.Errorf("relay error: %s", msg.Error)
 }
 
 // NewRelayClient creates a new relay client
@@ -46,9 +78,13 @@ func (r *RelayClient) ConnectAsHost(offer string) error {
 	u.RawQuery = q.Encode()
 
 	// Connect to WebSocket
-	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	conn, _, err := relayDialer.Dial(u.String(), nil)
 	if err != nil {
-		return fmt.Errorf("failed to connect to relay: %w", err)
+		return unreachable("connect to relay", err)
+	}
+	if err := checkRelaySubprotocol(conn); err != nil {
+		_ = conn.Close()
+		return err
 	}
 	r.conn = conn
 
@@ -57,6 +93,7 @@ func (r *RelayClient) ConnectAsHost(offer string) error {
 		Type:      MsgTypeRegister,
 		SessionID: r.sessionID,
 		Role:      RoleHost,
+		Version:   RelayProtocolVersion,
 	}
 	if err := r.sendMessage(regMsg); err != nil {
 		_ = r.conn.Close()
@@ -78,11 +115,22 @@ func (r *RelayClient) ConnectAsHost(offer string) error {
 	return nil
 }
 
+// OnClientInterest sets the handler called while waiting for an answer
+// each time a client fetches the offer
+func (r *RelayClient) OnClientInterest(handler func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onClientInterest = handler
+}
+
 // WaitForAnswer waits for an answer from a client
 func (r *RelayClient) WaitForAnswer(timeout time.Duration) (string, error) {
 	if r.conn == nil {
 		return "", fmt.Errorf("not connected")
 	}
+	r.mu.Lock()
+	onClientInterest := r.onClientInterest
+	r.mu.Unlock()
 
 	_ = r.conn.SetReadDeadline(time.Now().Add(timeout))
 
@@ -100,8 +148,12 @@ func (r *RelayClient) WaitForAnswer(timeout time.Duration) (string, error) {
 		switch msg.Type {
 		case MsgTypeAnswer:
 			return msg.SDP, nil
+		case MsgTypeClientInterest:
+			if onClientInterest != nil {
+				onClientInterest()
+			}
 		case MsgTypeError:
-			return "", fmt.Errorf("relay error: %s", msg.Error)
+			return "", relayError(msg)
 		}
 	}
 }
@@ -125,9 +177,13 @@ func (r *RelayClient) ConnectAsClient(sessionID string) (offer, salt string, err
 	u.RawQuery = q.Encode()
 
 	// Connect to WebSocket
-	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	conn, _, err := relayDialer.Dial(u.String(), nil)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to connect to relay: %w", err)
+		return "", "", unreachable("connect to relay", err)
+	}
+	if err := checkRelaySubprotocol(conn); err != nil {
+		_ = conn.Close()
+		return "", "", err
 	}
 	r.conn = conn
 
@@ -136,6 +192,7 @@ func (r *RelayClient) ConnectAsClient(sessionID string) (offer, salt string, err
 		Type:      MsgTypeRegister,
 		SessionID: sessionID,
 		Role:      RoleClient,
+		Version:   RelayProtocolVersion,
 	}
 	if err := r.sendMessage(regMsg); err != nil {
 		_ = r.conn.Close()
@@ -162,7 +219,7 @@ func (r *RelayClient) ConnectAsClient(sessionID string) (offer, salt string, err
 			return msg.SDP, msg.Salt, nil
 		case MsgTypeError:
 			_ = r.conn.Close()
-			return "", "", fmt.Errorf("relay error: %s", msg.Error)
+			return "", "", relayError(msg)
 		}
 	}
 }
@@ -0,0 +1,68 @@
+package signaling
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// versionRelay serves a relay that picks subprotocol for every connection
+// and answers registration with reply
+func versionRelay(t *testing.T, subprotocol string, reply RelayMessage) string {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := http.Header{}
+		if subprotocol != "" {
+			header.Set("Sec-WebSocket-Protocol", subprotocol)
+		}
+		conn, err := upgrader.Upgrade(w, r, header)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var msg RelayMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		_ = conn.WriteJSON(reply)
+		_, _, _ = conn.ReadMessage()
+	}))
+	t.Cleanup(ts.Close)
+	return "ws" + strings.TrimPrefix(ts.URL, "http")
+}
+
+func TestRelayVersionErrors(t *testing.T) {
+	offer := RelayMessage{Type: MsgTypeOffer, SDP: "offer", Salt: "salt"}
+	refused := RelayMessage{Type: MsgTypeError, Error: "relay protocol v2 is newer than this relay supports (v1): upgrade the relay", Version: 1}
+	failed := RelayMessage{Type: MsgTypeError, Error: "session full"}
+
+	tests := []struct {
+		name        string
+		subprotocol string
+		reply       RelayMessage
+		wantVersion bool // Want ErrRelayVersion
+		wantErr     bool
+	}{
+		{"unversioned relay", "", offer, false, false},
+		{"same version", RelaySubprotocol(RelayProtocolVersion), offer, false, false},
+		{"other subprotocol", RelaySubprotocol(RelayProtocolVersion + 1), offer, true, true},
+		{"unknown subprotocol", "chat", offer, true, true},
+		{"version refused", RelaySubprotocol(RelayProtocolVersion), refused, true, true},
+		{"other error", RelaySubprotocol(RelayProtocolVersion), failed, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewRelayClient(versionRelay(t, tt.subprotocol, tt.reply), "session", "")
+			defer client.Close()
+			_, _, err := client.ConnectAsClient("session")
+			if (err != nil) != tt.wantErr || errors.Is(err, ErrRelayVersion) != tt.wantVersion {
+				t.Errorf("ConnectAsClient = %v; want error %v, ErrRelayVersion %v", err, tt.wantErr, tt.wantVersion)
+			}
+		})
+	}
+}
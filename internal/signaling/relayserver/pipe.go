@@ -0,0 +1,208 @@
+package relayserver
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/artpar/terminal-tunnel/internal/signaling"
+)
+
+// maxPipeFrame bounds a message on the pipe. Frames are encrypted data
+// channel messages, which stay well under it.
+const maxPipeFrame = 256 << 10
+
+// pipe forwards a session's encrypted frames between the host and one
+// client over WebSockets, for clients that can't connect over WebRTC (see
+// signaling.EnablePipe). The host connects first and waits; a client joining
+// pairs with it, and when either end leaves both are closed, so the host
+// connects again for the next client.
+type pipe struct {
+	bytes        *atomic.Int64 // The relay's count of forwarded bytes
+	writeTimeout time.Duration // Deadline for each write to an end
+
+	mu     sync.Mutex
+	host   *pipeEnd
+	client *pipeEnd
+}
+
+// pipeEnd is one connection of a pipe
+type pipeEnd struct {
+	conn         *websocket.Conn
+	writeTimeout time.Duration
+	writeMu      sync.Mutex // gorilla/websocket allows one writer at a time
+}
+
+// write writes to the end within its write timeout, so an end that stops
+// reading fails the write, ending the pairing, instead of stalling the
+// other end's forwarding
+func (e *pipeEnd) write(msgType int, data []byte) error {
+	e.writeMu.Lock()
+	defer e.writeMu.Unlock()
+	_ = e.conn.SetWriteDeadline(time.Now().Add(e.writeTimeout))
+	return e.conn.WriteMessage(msgType, data)
+}
+
+// setHost makes conn the host's end, replacing an earlier one and any
+// client paired with it
+func (p *pipe) setHost(conn *websocket.Conn) {
+	end := &pipeEnd{conn: conn, writeTimeout: p.writeTimeout}
+	p.mu.Lock()
+	oldHost, oldClient := p.host, p.client
+	p.host, p.client = end, nil
+	p.mu.Unlock()
+	closeEnds(oldHost, oldClient)
+	go p.forward(end)
+}
+
+// waiting reports whether a host waits for a client
+func (p *pipe) waiting() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.host != nil && p.client == nil
+}
+
+// pair joins conn to the waiting host, telling the host. It returns false
+// if no host is waiting.
+func (p *pipe) pair(conn *websocket.Conn) bool {
+	end := &pipeEnd{conn: conn, writeTimeout: p.writeTimeout}
+	p.mu.Lock()
+	host := p.host
+	if host == nil || p.client != nil {
+		p.mu.Unlock()
+		return false
+	}
+	p.client = end
+	p.mu.Unlock()
+
+	if err := host.write(websocket.TextMessage, []byte(signaling.PipePaired)); err != nil {
+		p.end(end)
+		return false
+	}
+	go p.forward(end)
+	return true
+}
+
+// forward copies from's frames to the other end until from fails, then
+// ends the pairing
+func (p *pipe) forward(from *pipeEnd) {
+	from.conn.SetReadLimit(maxPipeFrame)
+	for {
+		msgType, data, err := from.conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		if msgType != websocket.BinaryMessage {
+			continue
+		}
+		p.mu.Lock()
+		to := p.client
+		if from == p.client {
+			to = p.host
+		}
+		p.mu.Unlock()
+		if to == nil {
+			continue // The host's frames before a client joins go nowhere
+		}
+		if err := to.write(websocket.BinaryMessage, data); err != nil {
+			break
+		}
+		p.bytes.Add(int64(len(data)))
+	}
+	p.end(from)
+}
+
+// end closes from and, if it is still part of the pipe, its other end
+func (p *pipe) end(from *pipeEnd) {
+	p.mu.Lock()
+	var host, client *pipeEnd
+	if from == p.host || from == p.client {
+		host, client = p.host, p.client
+		p.host, p.client = nil, nil
+	}
+	p.mu.Unlock()
+	closeEnds(from, host, client)
+}
+
+// close closes both ends, when the session goes away
+func (p *pipe) close() {
+	p.mu.Lock()
+	host, client := p.host, p.client
+	p.host, p.client = nil, nil
+	p.mu.Unlock()
+	closeEnds(host, client)
+}
+
+func closeEnds(ends ...*pipeEnd) {
+	for _, end := range ends {
+		if end != nil {
+			_ = end.conn.Close()
+		}
+	}
+}
+
+// HandlePipe handles GET /session/{code}/pipe?role=host|client, the
+// WebSocket pipe of a session created with "pipe": true. The host must
+// send the session's owner token; a client needs the host to be waiting.
+func (rs *RelayServer) HandlePipe(w http.ResponseWriter, r *http.Request) {
+	clientIP := getClientIP(r)
+	if !rs.rateLimiter.Allow(clientIP, hostOnly(r.RemoteAddr)) {
+		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/session/")
+	code := strings.ToUpper(strings.TrimSuffix(path, "/pipe"))
+
+	rs.mu.RLock()
+	session, exists := rs.shortCodes[code]
+	rs.mu.RUnlock()
+	if !exists {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if session.pipe == nil {
+		http.Error(w, "WebSocket pipe not enabled for this session", http.StatusForbidden)
+		return
+	}
+
+	role := r.URL.Query().Get("role")
+	switch role {
+	case signaling.PipeRoleHost:
+		if !session.authorized(r) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	case signaling.PipeRoleClient:
+		if !session.pipe.waiting() {
+			http.Error(w, "Host is not accepting WebSocket connections", http.StatusServiceUnavailable)
+			return
+		}
+	default:
+		http.Error(w, "role must be host or client", http.StatusBadRequest)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket pipe upgrade error: %v", err)
+		return
+	}
+	if role == signaling.PipeRoleHost {
+		session.pipe.setHost(conn)
+		return
+	}
+	if !session.pipe.pair(conn) {
+		// Another client took the host first
+		_ = conn.WriteMessage(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "host is busy"))
+		_ = conn.Close()
+		return
+	}
+	log.Printf("Session %s: client connected over the WebSocket pipe from IP %s", code, clientIP)
+}
@@ -2,19 +2,27 @@
 package relayserver
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
 	"math/big"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 
 	"github.com/artpar/terminal-tunnel/internal/signaling"
+	"github.com/artpar/terminal-tunnel/internal/web"
 )
 
 // Short code alphabet (no ambiguous chars: 0/O, 1/I/l)
@@ -23,6 +31,24 @@ const codeAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
 // Security: 8 chars = 31^8 = 852 billion possibilities (vs 31^6 = 887 million)
 const codeLength = 8
 
+// deleteTokenHeader carries the owner token for session mutations
+const deleteTokenHeader = "X-Delete-Token"
+
+// maxAnswerWait is how long an answer poll is held open when no answer has
+// arrived. Hosts may ask for less with ?wait=<seconds>.
+const maxAnswerWait = 30 * time.Second
+
+// answerCoalesceWindow is how long an answer waits before it is handed to
+// the host, whether it polls or listens on a WebSocket. A flapping client
+// can submit several answers in quick succession; only the last of a burst
+// is delivered, so the host doesn't set up a peer for each stale one.
+const answerCoalesceWindow = 250 * time.Millisecond
+
+// defaultWriteTimeout bounds a write to a WebSocket. A peer that stops
+// reading fills its TCP buffers and would otherwise block the writer, often
+// while it holds the session's lock, for as long as the connection lives.
+const defaultWriteTimeout = 10 * time.Second
+
 // Rate limiting constants
 const (
 	rateLimitWindow   = 1 * time.Minute
@@ -33,6 +59,8 @@ const (
 // RateLimiter tracks request rates per IP
 type RateLimiter struct {
 	requests map[string][]time.Time
+	trusted  []*net.IPNet // Networks exempt from the limit
+	rejected atomic.Int64 // Requests refused since start
 	mu       sync.Mutex
 }
 
@@ -45,10 +73,55 @@ func NewRateLimiter() *RateLimiter {
 	return rl
 }
 
-// Allow checks if a request from the given IP should be allowed
-func (rl *RateLimiter) Allow(ip string) bool {
+// Trust exempts a network from rate limiting. cidr is a CIDR such as
+// 10.0.0.0/8 or a single address.
+func (rl *RateLimiter) Trust(cidr string) error {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			return fmt.Errorf("invalid CIDR %q", cidr)
+		}
+		bits := 8 * net.IPv4len
+		if ip.To4() == nil {
+			bits = 8 * net.IPv6len
+		}
+		network = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+	}
+
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
+	rl.trusted = append(rl.trusted, network)
+	return nil
+}
+
+// isTrustedLocked reports whether ip is in a trusted network; the caller holds mu
+func (rl *RateLimiter) isTrustedLocked(ip string) bool {
+	if len(rl.trusted) == 0 {
+		return false
+	}
+	parsed := net.ParseIP(strings.Trim(ip, "[]"))
+	if parsed == nil {
+		return false
+	}
+	for _, network := range rl.trusted {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allow checks if a request from the given IP should be allowed. Trusted
+// networks are matched against peer, the address the connection came from,
+// not ip, which forwarded headers set and anyone can spoof.
+func (rl *RateLimiter) Allow(ip, peer string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.isTrustedLocked(peer) {
+		return true
+	}
 
 	now := time.Now()
 	cutoff := now.Add(-rateLimitWindow)
@@ -63,6 +136,7 @@ func (rl *RateLimiter) Allow(ip string) bool {
 
 	if len(recent) >= maxRequestsPerIP {
 		rl.requests[ip] = recent
+		rl.rejected.Add(1)
 		return false
 	}
 
@@ -70,6 +144,11 @@ func (rl *RateLimiter) Allow(ip string) bool {
 	return true
 }
 
+// Rejected returns how many requests have been refused
+func (rl *RateLimiter) Rejected() int64 {
+	return rl.rejected.Load()
+}
+
 // cleanupLoop periodically removes old entries
 func (rl *RateLimiter) cleanupLoop() {
 	ticker := time.NewTicker(rateLimitCleanup)
@@ -107,15 +186,23 @@ var allowedOrigins = map[string]bool{
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	Subprotocols:    []string{signaling.RelaySubprotocol(signaling.RelayProtocolVersion)},
 	CheckOrigin: func(r *http.Request) bool {
 		origin := r.Header.Get("Origin")
 		if origin == "" {
 			return true // Allow non-browser clients
 		}
-		return allowedOrigins[origin]
+		return allowedOrigins[origin] || sameOrigin(r, origin)
 	},
 }
 
+// sameOrigin reports whether origin is the relay itself, as for a web
+// client served by the relay (see ServeWebClient)
+func sameOrigin(r *http.Request, origin string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
 // Session represents a signaling session between host and client
 type Session struct {
 	ID           string
@@ -124,30 +211,60 @@ type Session struct {
 	ClientConn   *websocket.Conn
 	Offer        string
 	Answer       string
+	AnswerAt     time.Time // When Answer was submitted
 	Salt         string
 	Created      time.Time
 	LastActivity time.Time // Last activity time for expiry calculation
 	AnswerChan   chan string // Channel to notify host of answer
+	DeleteToken  string      // Owner token required for PUT/PATCH/DELETE
+	ViewerCode   string      // Read-only viewer session owned by this one (public mode)
+	ViewerKey    string      // Set on viewer sessions: key handed to viewers instead of a password
+	pipe         *pipe       // WebSocket pipe, if the host enabled it at creation
 	mu           sync.Mutex
 }
 
+// authorized reports whether the request carries the session's owner token
+func (s *Session) authorized(r *http.Request) bool {
+	token := r.Header.Get(deleteTokenHeader)
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.DeleteToken)) == 1
+}
+
 // SessionRequest is the request body for creating a session
 type SessionRequest struct {
-	SDP  string `json:"sdp"`
-	Salt string `json:"salt"`
+	SDP       string `json:"sdp"`
+	Salt      string `json:"salt"`
+	ViewerSDP string `json:"viewer_sdp,omitempty"` // Public mode: offer for read-only viewers
+	ViewerKey string `json:"viewer_key,omitempty"`
+	Code      string `json:"code,omitempty"` // Requested code (tt start --code); generated if empty
+	Pipe      bool   `json:"pipe,omitempty"` // Allow the WebSocket pipe (tt start --allow-ws-fallback)
 }
 
 // SessionResponse is the response for session creation
 type SessionResponse struct {
-	Code      string `json:"code"`
-	ExpiresIn int    `json:"expires_in"`
-	URL       string `json:"url,omitempty"`
+	Code        string `json:"code"`
+	ViewerCode  string `json:"viewer_code,omitempty"`
+	DeleteToken string `json:"delete_token"`
+	ExpiresIn   int    `json:"expires_in"`
+	URL         string `json:"url,omitempty"`
 }
 
 // SessionInfo is returned when fetching a session
 type SessionInfo struct {
 	SDP  string `json:"sdp"`
 	Salt string `json:"salt"`
+	Pipe bool   `json:"pipe,omitempty"` // Clients may fall back to the WebSocket pipe
+}
+
+// RelayStats is returned by GET /stats, for self-hosters checking that a
+// relay is healthy and sized right
+type RelayStats struct {
+	ActiveSessions      int       `json:"active_sessions"`
+	SessionsServed      int64     `json:"sessions_served"` // Since start, including expired ones
+	Started             time.Time `json:"started"`
+	Uptime              string    `json:"uptime"`
+	UptimeSeconds       int64     `json:"uptime_seconds"`
+	RateLimitRejections int64     `json:"rate_limit_rejections"`
+	PipeBytes           int64     `json:"pipe_bytes"` // Forwarded over WebSocket pipes since start
 }
 
 // AnswerRequest is the request body for submitting an answer
@@ -166,23 +283,52 @@ func generateShortCode() string {
 	return string(code)
 }
 
-// RelayServer is a WebSocket relay server for SDP exchange
+// viewerCodeSuffix marks a read-only viewer code, code+viewerCodeSuffix.
+// NormalizeRequestedCode refuses requested codes ending in it, so no
+// requested code can be a viewer code. A generated code may end in it and
+// match the viewer code of a shorter requested one; shortCodes holds viewer
+// codes too, and both kinds are checked against it before they are handed out.
+const viewerCodeSuffix = "V"
+
+// generateDeleteToken creates a random opaque owner token
+func generateDeleteToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// RelayServer is a WebSocket relay server for SDP exchange.
+//
+// Locks are taken in one order: rs.mu, then a Session's mu, then its
+// viewer's or its pipe's. rs.mu is never taken while a session's lock is
+// held. rs.mu only guards the maps, so lookups release it before locking
+// the session, and nothing that can block, such as a WebSocket write, is
+// done under it; a slow peer then holds up its own session at most.
 type RelayServer struct {
-	sessions    map[string]*Session
-	shortCodes  map[string]*Session // maps short code to session
-	mu          sync.RWMutex
-	expiration  time.Duration
-	publicURL   string // Public URL for generating client links
-	rateLimiter *RateLimiter
+	sessions     map[string]*Session
+	shortCodes   map[string]*Session // maps short code to session
+	mu           sync.RWMutex
+	expiration   time.Duration
+	publicURL    string       // Public URL for generating client links
+	webClient    http.Handler // Serves the web client at "/" (nil = API only)
+	rateLimiter  *RateLimiter
+	started      time.Time     // For uptime in /stats
+	served       atomic.Int64  // Sessions created since start
+	pipeBytes    atomic.Int64  // Bytes forwarded over WebSocket pipes since start
+	writeTimeout time.Duration // Deadline for each WebSocket write
 }
 
 // NewRelayServer creates a new relay server
 func NewRelayServer() *RelayServer {
 	rs := &RelayServer{
-		sessions:    make(map[string]*Session),
-		shortCodes:  make(map[string]*Session),
-		expiration:  24 * time.Hour,
-		rateLimiter: NewRateLimiter(),
+		sessions:     make(map[string]*Session),
+		shortCodes:   make(map[string]*Session),
+		expiration:   24 * time.Hour,
+		rateLimiter:  NewRateLimiter(),
+		writeTimeout: defaultWriteTimeout,
+		started:      time.Now(),
 	}
 
 	// Start session cleanup goroutine
@@ -196,18 +342,25 @@ func getClientIP(r *http.Request) string {
 	// Check X-Forwarded-For header (for proxies)
 	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
 		parts := strings.Split(xff, ",")
-		return strings.TrimSpace(parts[0])
+		return hostOnly(strings.TrimSpace(parts[0]))
 	}
 	// Check X-Real-IP header
 	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
+		return hostOnly(strings.TrimSpace(xri))
 	}
 	// Fall back to RemoteAddr
-	ip := r.RemoteAddr
-	if idx := strings.LastIndex(ip, ":"); idx != -1 {
-		ip = ip[:idx]
+	return hostOnly(r.RemoteAddr)
+}
+
+// hostOnly strips the port from addr, if it has one, and the brackets
+// around an IPv6 address, so "[::1]:1234", "[::1]" and "::1" all give
+// "::1". Cutting at the last ':' would mangle an IPv6 address without a
+// port.
+func hostOnly(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
 	}
-	return ip
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
 }
 
 // setCORSHeaders sets CORS headers based on origin whitelist
@@ -219,8 +372,8 @@ func setCORSHeaders(w http.ResponseWriter, r *http.Request) {
 		// For non-browser clients, allow all
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 	}
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+deleteTokenHeader)
 }
 
 // SetPublicURL sets the public URL for generating client links
@@ -228,43 +381,141 @@ func (rs *RelayServer) SetPublicURL(url string) {
 	rs.publicURL = strings.TrimSuffix(url, "/")
 }
 
+// SetWriteTimeout sets how long a WebSocket write may take before the peer
+// is taken to be stuck and its connection is closed
+func (rs *RelayServer) SetWriteTimeout(d time.Duration) {
+	rs.writeTimeout = d
+}
+
+// writeJSON writes msg to conn within the write timeout. If the write
+// fails, e.g. because the peer stopped reading, conn is closed; its read
+// loop then ends and removes it from the session.
+func (rs *RelayServer) writeJSON(conn *websocket.Conn, msg signaling.RelayMessage) error {
+	_ = conn.SetWriteDeadline(time.Now().Add(rs.writeTimeout))
+	err := conn.WriteJSON(msg)
+	if err != nil {
+		log.Printf("WebSocket write to %s failed, closing: %v", conn.RemoteAddr(), err)
+		_ = conn.Close()
+	}
+	return err
+}
+
+// TrustCIDR exempts clients in a network from rate limiting, e.g. the
+// operator's own hosts
+func (rs *RelayServer) TrustCIDR(cidr string) error {
+	return rs.rateLimiter.Trust(cidr)
+}
+
+// ServeWebClient serves the embedded web client at "/" alongside the API,
+// so one port provides the whole self-hosted stack. Session links then
+// point at this relay unless a public URL is set.
+func (rs *RelayServer) ServeWebClient() {
+	rs.webClient = web.Handler()
+}
+
+// sessionURL returns the client link for a session code, or "" if the relay
+// has no client URL. A client served by the relay is told to use it.
+func (rs *RelayServer) sessionURL(r *http.Request, code string) string {
+	if rs.webClient == nil {
+		if rs.publicURL == "" {
+			return ""
+		}
+		return fmt.Sprintf("%s/?c=%s", rs.publicURL, code)
+	}
+
+	base := rs.publicURL
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	return fmt.Sprintf("%s/?c=%s&relay=%s", base, code, url.QueryEscape(base))
+}
+
 // cleanupLoop periodically removes expired sessions
-// Sessions expire based on LastActivity, not creation time
 func (rs *RelayServer) cleanupLoop() {
 	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		rs.mu.Lock()
-		now := time.Now()
-		for id, session := range rs.sessions {
-			session.mu.Lock()
-			timeSinceActivity := now.Sub(session.LastActivity)
-			session.mu.Unlock()
-
-			if timeSinceActivity > rs.expiration {
-				session.mu.Lock()
-				if session.HostConn != nil {
-					_ = session.HostConn.Close()
-				}
-				if session.ClientConn != nil {
-					_ = session.ClientConn.Close()
-				}
-				if session.AnswerChan != nil {
-					close(session.AnswerChan)
-				}
-				session.mu.Unlock()
-				delete(rs.sessions, id)
-				if session.ShortCode != "" {
-					delete(rs.shortCodes, session.ShortCode)
-				}
-				log.Printf("Session %s expired (inactive for %v)", id, timeSinceActivity.Round(time.Second))
+	for now := range ticker.C {
+		rs.expireSessions(now)
+	}
+}
+
+// expireSessions removes the sessions inactive for longer than the
+// expiration. Sessions expire based on LastActivity, not creation time.
+// They are taken out of the maps under rs.mu and closed after it is
+// released.
+func (rs *RelayServer) expireSessions(now time.Time) {
+	type expiredSession struct {
+		session  *Session
+		inactive time.Duration
+	}
+	var expired []expiredSession
+
+	rs.mu.Lock()
+	for id, session := range rs.sessions {
+		session.mu.Lock()
+		timeSinceActivity := now.Sub(session.LastActivity)
+		session.mu.Unlock()
+
+		if timeSinceActivity > rs.expiration {
+			delete(rs.sessions, id)
+			if session.ShortCode != "" {
+				delete(rs.shortCodes, session.ShortCode)
 			}
+			rs.removeViewerLocked(session)
+			expired = append(expired, expiredSession{session, timeSinceActivity})
 		}
-		rs.mu.Unlock()
+	}
+	rs.mu.Unlock()
+
+	for _, e := range expired {
+		session := e.session
+		session.mu.Lock()
+		if session.HostConn != nil {
+			_ = session.HostConn.Close()
+			session.HostConn = nil
+		}
+		if session.ClientConn != nil {
+			_ = session.ClientConn.Close()
+			session.ClientConn = nil
+		}
+		// Nil it so a late submit falls through instead of panicking
+		if session.AnswerChan != nil {
+			close(session.AnswerChan)
+			session.AnswerChan = nil
+		}
+		session.mu.Unlock()
+		if session.pipe != nil {
+			session.pipe.close()
+		}
+		log.Printf("Session %s expired (inactive for %v)", session.ID, e.inactive.Round(time.Second))
 	}
 }
 
+// removeViewerLocked removes the viewer session owned by session, if any,
+// waking a host polling for the viewer's answer. rs.mu must be held.
+func (rs *RelayServer) removeViewerLocked(session *Session) {
+	if session.ViewerCode == "" {
+		return
+	}
+	viewer, exists := rs.shortCodes[session.ViewerCode]
+	if !exists {
+		return
+	}
+	delete(rs.shortCodes, session.ViewerCode)
+
+	viewer.mu.Lock()
+	if viewer.AnswerChan != nil {
+		close(viewer.AnswerChan)
+		viewer.AnswerChan = nil
+	}
+	viewer.mu.Unlock()
+}
+
 // HandleWebSocket handles WebSocket connections
 func (rs *RelayServer) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
@@ -276,7 +527,7 @@ func (rs *RelayServer) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	// Get session ID from query parameter
 	sessionID := r.URL.Query().Get("session")
 	if sessionID == "" {
-		_ = conn.WriteJSON(signaling.RelayMessage{
+		_ = rs.writeJSON(conn, signaling.RelayMessage{
 			Type:  signaling.MsgTypeError,
 			Error: "session parameter required",
 		})
@@ -284,6 +535,9 @@ func (rs *RelayServer) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Version offered in the subprotocol header, used if register omits it
+	version := offeredVersion(r, conn)
+
 	// Handle messages
 	for {
 		_, data, err := conn.ReadMessage()
@@ -297,14 +551,33 @@ func (rs *RelayServer) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
+		if msg.Type == signaling.MsgTypeRegister && msg.Version == 0 {
+			msg.Version = version
+		}
 		rs.handleMessage(conn, sessionID, msg)
 	}
 }
 
+// offeredVersion returns the relay protocol version a WebSocket client
+// asked for: the negotiated subprotocol, else the newest (unsupported) one
+// it offered, else 0 for clients that predate versioning
+func offeredVersion(r *http.Request, conn *websocket.Conn) int {
+	if version, ok := signaling.ParseRelaySubprotocol(conn.Subprotocol()); ok {
+		return version
+	}
+	newest := 0
+	for _, protocol := range websocket.Subprotocols(r) {
+		if version, ok := signaling.ParseRelaySubprotocol(protocol); ok && version > newest {
+			newest = version
+		}
+	}
+	return newest
+}
+
 func (rs *RelayServer) handleMessage(conn *websocket.Conn, sessionID string, msg signaling.RelayMessage) {
 	switch msg.Type {
 	case signaling.MsgTypeRegister:
-		rs.handleRegister(conn, sessionID, msg.Role)
+		rs.handleRegister(conn, sessionID, msg.Role, msg.Version)
 
 	case signaling.MsgTypeOffer:
 		rs.handleOffer(sessionID, msg.SDP, msg.Salt)
@@ -314,10 +587,21 @@ func (rs *RelayServer) handleMessage(conn *websocket.Conn, sessionID string, msg
 	}
 }
 
-func (rs *RelayServer) handleRegister(conn *websocket.Conn, sessionID, role string) {
-	rs.mu.Lock()
-	defer rs.mu.Unlock()
+func (rs *RelayServer) handleRegister(conn *websocket.Conn, sessionID, role string, version int) {
+	// Refuse incompatible peers up front rather than misreading their messages
+	if err := signaling.CheckRelayVersion(version); err != nil {
+		log.Printf("Rejected %s for session %s: %v", role, sessionID, err)
+		_ = rs.writeJSON(conn, signaling.RelayMessage{
+			Type:      signaling.MsgTypeError,
+			SessionID: sessionID,
+			Error:     err.Error(),
+			Version:   signaling.RelayProtocolVersion,
+		})
+		_ = conn.Close()
+		return
+	}
 
+	rs.mu.Lock()
 	session, exists := rs.sessions[sessionID]
 	if !exists {
 		session = &Session{
@@ -325,7 +609,9 @@ func (rs *RelayServer) handleRegister(conn *websocket.Conn, sessionID, role stri
 			Created: time.Now(),
 		}
 		rs.sessions[sessionID] = session
+		rs.served.Add(1)
 	}
+	rs.mu.Unlock()
 
 	session.mu.Lock()
 	defer session.mu.Unlock()
@@ -339,12 +625,14 @@ func (rs *RelayServer) handleRegister(conn *websocket.Conn, sessionID, role stri
 
 		// If we have an offer, send it to the client
 		if session.Offer != "" {
-			_ = conn.WriteJSON(signaling.RelayMessage{
+			if err := rs.writeJSON(conn, signaling.RelayMessage{
 				Type:      signaling.MsgTypeOffer,
 				SessionID: sessionID,
 				SDP:       session.Offer,
 				Salt:      session.Salt,
-			})
+			}); err != nil {
+				session.ClientConn = nil
+			}
 		}
 	}
 }
@@ -364,12 +652,14 @@ func (rs *RelayServer) handleOffer(sessionID, sdp, salt string) {
 
 	// If client is already connected, forward the offer
 	if session.ClientConn != nil {
-		_ = session.ClientConn.WriteJSON(signaling.RelayMessage{
+		if err := rs.writeJSON(session.ClientConn, signaling.RelayMessage{
 			Type:      signaling.MsgTypeOffer,
 			SessionID: sessionID,
 			SDP:       sdp,
 			Salt:      salt,
-		})
+		}); err != nil {
+			session.ClientConn = nil
+		}
 	}
 	session.mu.Unlock()
 
@@ -388,11 +678,13 @@ func (rs *RelayServer) handleAnswer(sessionID, sdp string) {
 	session.mu.Lock()
 	// Forward answer to host
 	if session.HostConn != nil {
-		_ = session.HostConn.WriteJSON(signaling.RelayMessage{
+		if err := rs.writeJSON(session.HostConn, signaling.RelayMessage{
 			Type:      signaling.MsgTypeAnswer,
 			SessionID: sessionID,
 			SDP:       sdp,
-		})
+		}); err != nil {
+			session.HostConn = nil
+		}
 	}
 	session.mu.Unlock()
 
@@ -402,7 +694,9 @@ func (rs *RelayServer) handleAnswer(sessionID, sdp string) {
 	go func() {
 		time.Sleep(5 * time.Second)
 		rs.mu.Lock()
-		delete(rs.sessions, sessionID)
+		if rs.sessions[sessionID] == session {
+			delete(rs.sessions, sessionID)
+		}
 		rs.mu.Unlock()
 		log.Printf("Session %s completed and cleaned up", sessionID)
 	}()
@@ -447,7 +741,7 @@ func (rs *RelayServer) HandleCreateSession(w http.ResponseWriter, r *http.Reques
 
 	// Rate limiting
 	clientIP := getClientIP(r)
-	if !rs.rateLimiter.Allow(clientIP) {
+	if !rs.rateLimiter.Allow(clientIP, hostOnly(r.RemoteAddr)) {
 		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
 		log.Printf("Rate limit exceeded for IP: %s", clientIP)
 		return
@@ -463,14 +757,42 @@ func (rs *RelayServer) HandleCreateSession(w http.ResponseWriter, r *http.Reques
 		http.Error(w, "SDP required", http.StatusBadRequest)
 		return
 	}
+	if (req.ViewerSDP == "") != (req.ViewerKey == "") {
+		http.Error(w, "viewer_sdp and viewer_key must be given together", http.StatusBadRequest)
+		return
+	}
+	if req.Code != "" {
+		code, err := signaling.NormalizeRequestedCode(req.Code)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Code = code
+	}
+
+	deleteToken, err := generateDeleteToken()
+	if err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
 
-	// Generate unique short code
+	// Take the requested code if it is free, else generate a unique one
 	rs.mu.Lock()
-	var code string
-	for {
-		code = generateShortCode()
-		if _, exists := rs.shortCodes[code]; !exists {
-			break
+	code := req.Code
+	if code != "" {
+		_, taken := rs.shortCodes[code]
+		_, viewerTaken := rs.shortCodes[code+viewerCodeSuffix]
+		if taken || (req.ViewerSDP != "" && viewerTaken) {
+			rs.mu.Unlock()
+			http.Error(w, "Session code is taken", http.StatusConflict)
+			return
+		}
+	} else {
+		for {
+			code = generateShortCode()
+			if _, exists := rs.shortCodes[code]; !exists {
+				break
+			}
 		}
 	}
 
@@ -483,20 +805,45 @@ func (rs *RelayServer) HandleCreateSession(w http.ResponseWriter, r *http.Reques
 		Created:      now,
 		LastActivity: now,
 		AnswerChan:   make(chan string, 1),
+		DeleteToken:  deleteToken,
+	}
+	if req.Pipe {
+		session.pipe = &pipe{bytes: &rs.pipeBytes, writeTimeout: rs.writeTimeout}
 	}
 	rs.sessions[code] = session
 	rs.shortCodes[code] = session
+	rs.served.Add(1)
+
+	// Public mode: a viewer session under code+V, living as long as the host's.
+	// The host's token lets it replace the viewer offer for each new viewer.
+	if req.ViewerSDP != "" {
+		session.ViewerCode = code + viewerCodeSuffix
+		rs.shortCodes[session.ViewerCode] = &Session{
+			ID:           session.ViewerCode,
+			ShortCode:    session.ViewerCode,
+			Offer:        req.ViewerSDP,
+			ViewerKey:    req.ViewerKey,
+			Created:      now,
+			LastActivity: now,
+			AnswerChan:   make(chan string, 1),
+			DeleteToken:  deleteToken,
+		}
+	}
 	rs.mu.Unlock()
 
-	log.Printf("Session created with code %s from IP %s", code, clientIP)
+	if session.ViewerCode != "" {
+		log.Printf("Session created with code %s (viewer %s) from IP %s", code, session.ViewerCode, clientIP)
+	} else {
+		log.Printf("Session created with code %s from IP %s", code, clientIP)
+	}
 
 	// Build response
 	resp := SessionResponse{
-		Code:      code,
-		ExpiresIn: int(rs.expiration.Seconds()),
-	}
-	if rs.publicURL != "" {
-		resp.URL = fmt.Sprintf("%s/?c=%s", rs.publicURL, code)
+		Code:        code,
+		ViewerCode:  session.ViewerCode,
+		DeleteToken: deleteToken,
+		ExpiresIn:   int(rs.expiration.Seconds()),
+		URL:         rs.sessionURL(r, code),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -519,7 +866,7 @@ func (rs *RelayServer) HandleGetSession(w http.ResponseWriter, r *http.Request)
 
 	// Rate limiting
 	clientIP := getClientIP(r)
-	if !rs.rateLimiter.Allow(clientIP) {
+	if !rs.rateLimiter.Allow(clientIP, hostOnly(r.RemoteAddr)) {
 		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
 		return
 	}
@@ -540,9 +887,28 @@ func (rs *RelayServer) HandleGetSession(w http.ResponseWriter, r *http.Request)
 	session.mu.Lock()
 	// Update last activity on access
 	session.LastActivity = time.Now()
-	resp := SessionInfo{
+	var resp interface{} = SessionInfo{
 		SDP:  session.Offer,
 		Salt: session.Salt,
+		Pipe: session.pipe != nil,
+	}
+	if session.ViewerKey != "" {
+		// Viewers get the key directly - no password for read-only access
+		resp = signaling.ViewerSessionResponse{
+			SDP:      session.Offer,
+			Key:      session.ViewerKey,
+			ReadOnly: true,
+			Used:     session.Answer != "",
+		}
+	}
+	// Let a host watching over WebSocket know a client is on its way
+	if session.HostConn != nil {
+		if err := rs.writeJSON(session.HostConn, signaling.RelayMessage{
+			Type:      signaling.MsgTypeClientInterest,
+			SessionID: session.ID,
+		}); err != nil {
+			session.HostConn = nil
+		}
 	}
 	session.mu.Unlock()
 
@@ -577,6 +943,11 @@ func (rs *RelayServer) HandleSessionHeartbeat(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	if !session.authorized(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
 	session.mu.Lock()
 	session.LastActivity = time.Now()
 	session.mu.Unlock()
@@ -585,7 +956,8 @@ func (rs *RelayServer) HandleSessionHeartbeat(w http.ResponseWriter, r *http.Req
 	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
-// HandleUpdateSession handles PUT /session/{code} - updates session SDP for reconnection
+// HandleUpdateSession handles PUT /session/{code} - updates session SDP for
+// reconnection, or a viewer session's SDP for the next viewer
 func (rs *RelayServer) HandleUpdateSession(w http.ResponseWriter, r *http.Request) {
 	setCORSHeaders(w, r)
 
@@ -601,7 +973,7 @@ func (rs *RelayServer) HandleUpdateSession(w http.ResponseWriter, r *http.Reques
 
 	// Rate limiting
 	clientIP := getClientIP(r)
-	if !rs.rateLimiter.Allow(clientIP) {
+	if !rs.rateLimiter.Allow(clientIP, hostOnly(r.RemoteAddr)) {
 		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
 		return
 	}
@@ -630,6 +1002,11 @@ func (rs *RelayServer) HandleUpdateSession(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if !session.authorized(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
 	session.mu.Lock()
 	session.Offer = req.SDP
 	if req.Salt != "" {
@@ -655,6 +1032,73 @@ func (rs *RelayServer) HandleUpdateSession(w http.ResponseWriter, r *http.Reques
 	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
+// HandleDeleteSession handles DELETE /session/{code} - removes the session on host shutdown
+func (rs *RelayServer) HandleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w, r)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Rate limiting
+	clientIP := getClientIP(r)
+	if !rs.rateLimiter.Allow(clientIP, hostOnly(r.RemoteAddr)) {
+		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
+
+	// Extract code from path: /session/ABC123
+	path := strings.TrimPrefix(r.URL.Path, "/session/")
+	code := strings.ToUpper(path)
+
+	rs.mu.Lock()
+	session, exists := rs.shortCodes[code]
+	if !exists {
+		rs.mu.Unlock()
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if !session.authorized(r) {
+		rs.mu.Unlock()
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	delete(rs.shortCodes, code)
+	delete(rs.sessions, session.ID)
+	rs.removeViewerLocked(session)
+	rs.mu.Unlock()
+
+	session.mu.Lock()
+	if session.HostConn != nil {
+		_ = session.HostConn.Close()
+		session.HostConn = nil
+	}
+	if session.ClientConn != nil {
+		_ = session.ClientConn.Close()
+		session.ClientConn = nil
+	}
+	// Wake any pending long-poll; nil it so a late submit falls through instead of panicking
+	if session.AnswerChan != nil {
+		close(session.AnswerChan)
+		session.AnswerChan = nil
+	}
+	session.mu.Unlock()
+	if session.pipe != nil {
+		session.pipe.close()
+	}
+
+	log.Printf("Session %s deleted by host", code)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
 // HandleSubmitAnswer handles POST /session/{code}/answer - submits answer SDP
 func (rs *RelayServer) HandleSubmitAnswer(w http.ResponseWriter, r *http.Request) {
 	setCORSHeaders(w, r)
@@ -671,7 +1115,7 @@ func (rs *RelayServer) HandleSubmitAnswer(w http.ResponseWriter, r *http.Request
 
 	// Rate limiting
 	clientIP := getClientIP(r)
-	if !rs.rateLimiter.Allow(clientIP) {
+	if !rs.rateLimiter.Allow(clientIP, hostOnly(r.RemoteAddr)) {
 		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
 		return
 	}
@@ -696,25 +1140,30 @@ func (rs *RelayServer) HandleSubmitAnswer(w http.ResponseWriter, r *http.Request
 	}
 
 	session.mu.Lock()
+	replaced := session.Answer != "" && time.Since(session.AnswerAt) < answerCoalesceWindow
 	session.Answer = req.SDP
+	session.AnswerAt = time.Now()
 
-	// Notify via WebSocket if host is connected
-	if session.HostConn != nil {
-		_ = session.HostConn.WriteJSON(signaling.RelayMessage{
-			Type:      signaling.MsgTypeAnswer,
-			SessionID: session.ID,
-			SDP:       req.SDP,
-		})
-	}
+	// Notify via WebSocket if host is connected, once the answer settles
+	submitted := session.AnswerAt
+	time.AfterFunc(answerCoalesceWindow, func() { rs.pushAnswer(session, submitted) })
 
-	// Also send to answer channel for polling
-	select {
-	case session.AnswerChan <- req.SDP:
-	default:
+	// Also send to answer channel for polling, in place of an answer no
+	// poll has taken yet
+	if session.AnswerChan != nil {
+		select {
+		case <-session.AnswerChan:
+		default:
+		}
+		session.AnswerChan <- req.SDP
 	}
 	session.mu.Unlock()
 
-	log.Printf("Answer submitted for session %s", code)
+	if replaced {
+		log.Printf("Answer submitted for session %s, replacing one not yet delivered", code)
+	} else {
+		log.Printf("Answer submitted for session %s", code)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
@@ -736,7 +1185,7 @@ func (rs *RelayServer) HandlePollAnswer(w http.ResponseWriter, r *http.Request)
 
 	// Rate limiting (but more lenient for polling)
 	clientIP := getClientIP(r)
-	if !rs.rateLimiter.Allow(clientIP) {
+	if !rs.rateLimiter.Allow(clientIP, hostOnly(r.RemoteAddr)) {
 		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
 		return
 	}
@@ -757,35 +1206,94 @@ func (rs *RelayServer) HandlePollAnswer(w http.ResponseWriter, r *http.Request)
 	// Check if answer already exists
 	session.mu.Lock()
 	if session.Answer != "" {
-		answer := session.Answer
 		session.mu.Unlock()
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(map[string]string{"sdp": answer})
+		if answer, err := settleAnswer(r.Context(), session); err == nil {
+			writeAnswer(w, answer)
+		}
 		return
 	}
 	answerChan := session.AnswerChan
 	session.mu.Unlock()
 
-	// Long-poll: wait up to 30 seconds for answer
+	// Long-poll: wait up to 30 seconds for answer, or less if the host asks
+	// with ?wait=<seconds> so it notices a dropped connection sooner
+	wait := maxAnswerWait
+	if n, err := strconv.Atoi(r.URL.Query().Get("wait")); err == nil && n > 0 && time.Duration(n)*time.Second < wait {
+		wait = time.Duration(n) * time.Second
+	}
 	select {
-	case answer := <-answerChan:
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(map[string]string{"sdp": answer})
-	case <-time.After(30 * time.Second):
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(map[string]string{"status": "waiting"})
+	case _, ok := <-answerChan:
+		if !ok {
+			// Session was deleted or expired while waiting
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		if answer, err := settleAnswer(r.Context(), session); err == nil {
+			writeAnswer(w, answer)
+		}
+	case <-time.After(wait):
+		writeAnswer(w, "")
 	case <-r.Context().Done():
 		return
 	}
 }
 
+// writeAnswer responds to an answer poll with answer, or tells the host to
+// keep waiting if there is none
+func writeAnswer(w http.ResponseWriter, answer string) {
+	w.Header().Set("Content-Type", "application/json")
+	if answer == "" {
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": "waiting"})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]string{"sdp": answer})
+}
+
+// settleAnswer returns session's answer once answerCoalesceWindow has passed
+// since it was submitted, so an answer that replaced it in the meantime is
+// returned instead. Returns "" if the answer was cleared while waiting, or
+// ctx's error if it is done first.
+func settleAnswer(ctx context.Context, session *Session) (string, error) {
+	for {
+		session.mu.Lock()
+		answer, wait := session.Answer, answerCoalesceWindow-time.Since(session.AnswerAt)
+		session.mu.Unlock()
+		if wait <= 0 || answer == "" {
+			return answer, nil
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// pushAnswer sends session's answer to its host over WebSocket, if it is
+// still the one submitted at submitted. An answer that was since replaced or
+// cleared is not sent; the push scheduled for its replacement sends that.
+func (rs *RelayServer) pushAnswer(session *Session, submitted time.Time) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.HostConn == nil || session.Answer == "" || !session.AnswerAt.Equal(submitted) {
+		return
+	}
+	if err := rs.writeJSON(session.HostConn, signaling.RelayMessage{
+		Type:      signaling.MsgTypeAnswer,
+		SessionID: session.ID,
+		SDP:       session.Answer,
+	}); err != nil {
+		session.HostConn = nil
+	}
+}
+
 // sessionHandler routes /session/* requests
 func (rs *RelayServer) sessionHandler(w http.ResponseWriter, r *http.Request) {
 	setCORSHeaders(w, r)
 
 	// Handle preflight for all session endpoints
 	if r.Method == http.MethodOptions {
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		w.WriteHeader(http.StatusOK)
 		return
 	}
@@ -798,6 +1306,12 @@ func (rs *RelayServer) sessionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// /session/{code}/pipe - WebSocket fallback for the terminal data
+	if strings.HasSuffix(path, "/pipe") {
+		rs.HandlePipe(w, r)
+		return
+	}
+
 	// /session/{code}/answer
 	if strings.HasSuffix(path, "/answer") {
 		if r.Method == http.MethodPost {
@@ -820,12 +1334,65 @@ func (rs *RelayServer) sessionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// DELETE /session/{code} - remove session on host shutdown
+	if r.Method == http.MethodDelete {
+		rs.HandleDeleteSession(w, r)
+		return
+	}
+
 	// GET /session/{code}
 	rs.HandleGetSession(w, r)
 }
 
-// Start starts the relay server on the given port
+// Stats returns a snapshot of the relay's counters
+func (rs *RelayServer) Stats() RelayStats {
+	rs.mu.RLock()
+	active := len(rs.sessions)
+	rs.mu.RUnlock()
+
+	uptime := time.Since(rs.started)
+	return RelayStats{
+		ActiveSessions:      active,
+		SessionsServed:      rs.served.Load(),
+		Started:             rs.started,
+		Uptime:              uptime.Truncate(time.Second).String(),
+		UptimeSeconds:       int64(uptime.Seconds()),
+		RateLimitRejections: rs.rateLimiter.Rejected(),
+		PipeBytes:           rs.pipeBytes.Load(),
+	}
+}
+
+// HandleStats handles GET /stats - relay counters as JSON
+func (rs *RelayServer) HandleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(rs.Stats())
+}
+
+// Start starts the relay server on the given port on all interfaces
 func (rs *RelayServer) Start(port int) error {
+	return rs.StartOn("", port)
+}
+
+// StartOn starts the relay server on the given port, listening only on
+// bind (an IP address or hostname). An empty bind listens on all interfaces.
+func (rs *RelayServer) StartOn(bind string, port int) error {
+	addr := net.JoinHostPort(bind, strconv.Itoa(port))
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	return rs.StartListener(ln)
+}
+
+// StartListener serves the relay on an existing listener
+func (rs *RelayServer) StartListener(ln net.Listener) error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/ws", rs.HandleWebSocket)
 	mux.HandleFunc("/session", rs.sessionHandler)
@@ -834,22 +1401,31 @@ func (rs *RelayServer) Start(port int) error {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("OK"))
 	})
+	mux.HandleFunc("/stats", rs.HandleStats)
+	if rs.webClient != nil {
+		// The API routes above are more specific, so they take precedence
+		mux.Handle("/", rs.webClient)
+	}
 
-	addr := fmt.Sprintf(":%d", port)
-	log.Printf("Relay server starting on %s", addr)
+	log.Printf("Relay server starting on %s", ln.Addr())
 	log.Printf("Endpoints:")
 	log.Printf("  POST /session - Create session, get short code")
 	log.Printf("  GET  /session/{code} - Get session SDP")
+	log.Printf("  GET  /session/{code}V - Get viewer SDP and key (public mode)")
 	log.Printf("  POST /session/{code}/answer - Submit answer")
 	log.Printf("  GET  /session/{code}/answer - Poll for answer")
+	log.Printf("  DELETE /session/{code} - Delete session")
 	log.Printf("  WS   /ws?session={code} - WebSocket connection")
+	log.Printf("  GET  /stats - Session counts, uptime and rate-limit rejections")
+	if rs.webClient != nil {
+		log.Printf("  GET  / - Web client")
+	}
 
 	server := &http.Server{
-		Addr:         addr,
 		Handler:      mux,
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  120 * time.Second,
 	}
-	return server.ListenAndServe()
+	return server.Serve(ln)
 }
@@ -0,0 +1,781 @@
+package relayserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/artpar/terminal-tunnel/internal/signaling"
+)
+
+func TestRequestedCode(t *testing.T) {
+	rs := NewRelayServer()
+	ts := httptest.NewServer(http.HandlerFunc(rs.sessionHandler))
+	defer ts.Close()
+
+	first := signaling.NewShortCodeClient(ts.URL, ts.URL)
+	first.RequestCode("TEAM-DEMO")
+	code, viewerCode, err := first.CreateSessionWithViewer("offer", "salt", "viewer offer", "key")
+	if err != nil {
+		t.Fatalf("CreateSessionWithViewer: %v", err)
+	}
+	if code != "TEAM-DEMO" || viewerCode != "TEAM-DEMOV" {
+		t.Fatalf("codes = %q, %q; want TEAM-DEMO, TEAM-DEMOV", code, viewerCode)
+	}
+
+	// A second session can't take it, but still gets a generated code
+	second := signaling.NewShortCodeClient(ts.URL, ts.URL)
+	second.RequestCode("TEAM-DEMO")
+	if _, err := second.CreateSession("offer", "salt"); !errors.Is(err, signaling.ErrCodeTaken) {
+		t.Fatalf("CreateSession with a taken code: err = %v, want ErrCodeTaken", err)
+	}
+	second.RequestCode("")
+	if code, err := second.CreateSession("offer", "salt"); err != nil || len(code) != codeLength {
+		t.Fatalf("CreateSession = %q, %v; want a generated code", code, err)
+	}
+
+	// Once the owner deletes the session the code is free again
+	if err := first.DeleteSession(); err != nil {
+		t.Fatalf("DeleteSession: %v", err)
+	}
+	second.RequestCode("team-demo")
+	if code, err := second.CreateSession("offer", "salt"); err != nil || code != "TEAM-DEMO" {
+		t.Fatalf("CreateSession after delete = %q, %v; want TEAM-DEMO", code, err)
+	}
+}
+
+func TestRequestedCodeInvalid(t *testing.T) {
+	rs := NewRelayServer()
+	ts := httptest.NewServer(http.HandlerFunc(rs.sessionHandler))
+	defer ts.Close()
+
+	client := signaling.NewShortCodeClient(ts.URL, ts.URL)
+	client.RequestCode("no")
+	_, err := client.CreateSession("offer", "salt")
+	if err == nil {
+		t.Fatal("CreateSession with a too-short code succeeded")
+	}
+}
+
+func TestPipe(t *testing.T) {
+	rs := NewRelayServer()
+	ts := httptest.NewServer(http.HandlerFunc(rs.sessionHandler))
+	defer ts.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Sessions created without the pipe don't get one
+	plain := signaling.NewShortCodeClient(ts.URL, ts.URL)
+	if _, err := plain.CreateSession("offer", "salt"); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if _, err := plain.AcceptPipe(ctx); !errors.Is(err, signaling.ErrPipeUnsupported) {
+		t.Fatalf("AcceptPipe without EnablePipe: err = %v, want ErrPipeUnsupported", err)
+	}
+
+	host := signaling.NewShortCodeClient(ts.URL, ts.URL)
+	host.EnablePipe()
+	code, err := host.CreateSession("offer", "salt")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	// Only the owner may take the host's end
+	hostURL, _ := signaling.PipeURL(ts.URL, code, signaling.PipeRoleHost)
+	if _, resp, err := websocket.DefaultDialer.Dial(hostURL, nil); err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("host pipe without the owner token: err = %v, want 401", err)
+	}
+
+	// Clients are turned away until the host waits
+	clientURL, _ := signaling.PipeURL(ts.URL, code, signaling.PipeRoleClient)
+	if _, resp, err := websocket.DefaultDialer.Dial(clientURL, nil); err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("client pipe before the host: err = %v, want 503", err)
+	}
+
+	accepted := make(chan *websocket.Conn, 1)
+	go func() {
+		conn, err := host.AcceptPipe(ctx)
+		if err != nil {
+			t.Errorf("AcceptPipe: %v", err)
+		}
+		accepted <- conn
+	}()
+
+	rs.mu.RLock()
+	p := rs.shortCodes[code].pipe
+	rs.mu.RUnlock()
+	for !p.waiting() {
+		if ctx.Err() != nil {
+			t.Fatal("host never waited on the pipe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	client, _, err := websocket.DefaultDialer.Dial(clientURL, nil)
+	if err != nil {
+		t.Fatalf("client pipe: %v", err)
+	}
+	defer client.Close()
+	hostConn := <-accepted
+	if hostConn == nil {
+		return
+	}
+	defer hostConn.Close()
+
+	// A second client can't join a paired pipe
+	if _, resp, err := websocket.DefaultDialer.Dial(clientURL, nil); err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("second client pipe: err = %v, want 503", err)
+	}
+
+	if err := client.WriteMessage(websocket.BinaryMessage, []byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	if _, data, err := hostConn.ReadMessage(); err != nil || string(data) != "ping" {
+		t.Fatalf("host read %q, %v; want ping", data, err)
+	}
+	if err := hostConn.WriteMessage(websocket.BinaryMessage, []byte("pong")); err != nil {
+		t.Fatal(err)
+	}
+	if _, data, err := client.ReadMessage(); err != nil || string(data) != "pong" {
+		t.Fatalf("client read %q, %v; want pong", data, err)
+	}
+	if got := rs.pipeBytes.Load(); got != 8 {
+		t.Errorf("pipeBytes = %d, want 8", got)
+	}
+
+	// When the client leaves, the host's end closes too
+	_ = client.Close()
+	_ = hostConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := hostConn.ReadMessage(); err == nil {
+		t.Fatal("host pipe still open after the client left")
+	}
+}
+
+func TestGetClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     string
+		value      string
+		want       string
+	}{
+		{"ipv4", "192.0.2.1:1234", "", "", "192.0.2.1"},
+		{"ipv4 without port", "192.0.2.1", "", "", "192.0.2.1"},
+		{"ipv6", "[2001:db8::1]:1234", "", "", "2001:db8::1"},
+		{"ipv6 loopback", "[::1]:1234", "", "", "::1"},
+		{"ipv6 without port", "2001:db8::1", "", "", "2001:db8::1"},
+		{"ipv6 bracketed without port", "[2001:db8::1]", "", "", "2001:db8::1"},
+		{"forwarded ipv4", "10.0.0.1:80", "X-Forwarded-For", "198.51.100.7, 10.0.0.2", "198.51.100.7"},
+		{"forwarded ipv4 with port", "10.0.0.1:80", "X-Forwarded-For", "198.51.100.7:5000", "198.51.100.7"},
+		{"forwarded ipv6", "10.0.0.1:80", "X-Forwarded-For", "2001:db8::7, 10.0.0.2", "2001:db8::7"},
+		{"forwarded ipv6 with port", "10.0.0.1:80", "X-Forwarded-For", "[2001:db8::7]:5000", "2001:db8::7"},
+		{"forwarded ipv6 bracketed", "10.0.0.1:80", "X-Forwarded-For", " [2001:db8::7] ", "2001:db8::7"},
+		{"real ip ipv4", "10.0.0.1:80", "X-Real-IP", "198.51.100.8", "198.51.100.8"},
+		{"real ip ipv6", "10.0.0.1:80", "X-Real-IP", "2001:db8::8", "2001:db8::8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/session", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			if got := getClientIP(r); got != tt.want {
+				t.Errorf("getClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTrustedCIDRMatchesPeer checks that a forwarded header naming a trusted
+// address doesn't exempt a client, while a trusted peer stays exempt
+func TestTrustedCIDRMatchesPeer(t *testing.T) {
+	rl := NewRateLimiter()
+	if err := rl.Trust("10.0.0.0/8"); err != nil {
+		t.Fatal(err)
+	}
+
+	spoofed := 0
+	for i := 0; i < maxRequestsPerIP+5; i++ {
+		if rl.Allow("10.0.0.5", "198.51.100.7") {
+			spoofed++
+		}
+	}
+	if spoofed != maxRequestsPerIP {
+		t.Errorf("spoofed trusted address: %d requests allowed, want %d", spoofed, maxRequestsPerIP)
+	}
+
+	for i := 0; i < maxRequestsPerIP+5; i++ {
+		if !rl.Allow("198.51.100.8", "10.0.0.1") {
+			t.Fatalf("trusted peer: request %d refused", i+1)
+		}
+	}
+}
+
+func TestWriteTimeoutNonReadingPeer(t *testing.T) {
+	rs := NewRelayServer()
+	rs.SetWriteTimeout(200 * time.Millisecond)
+	ts := httptest.NewServer(http.HandlerFunc(rs.HandleWebSocket))
+	defer ts.Close()
+
+	// A client that registers and then never reads what the relay sends
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/?session=stuck"
+	client, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer client.Close()
+	if err := client.WriteJSON(signaling.RelayMessage{
+		Type:    signaling.MsgTypeRegister,
+		Role:    signaling.RoleClient,
+		Version: signaling.RelayProtocolVersion,
+	}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	var session *Session
+	for deadline := time.Now().Add(5 * time.Second); session == nil; {
+		rs.mu.RLock()
+		if s := rs.sessions["stuck"]; s != nil {
+			s.mu.Lock()
+			if s.ClientConn != nil {
+				session = s
+			}
+			s.mu.Unlock()
+		}
+		rs.mu.RUnlock()
+		if time.Now().After(deadline) {
+			t.Fatal("client never registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Offers are forwarded to the client until its buffers fill; the write
+	// that blocks then times out instead of holding the session's lock
+	sdp := strings.Repeat("x", 1<<20)
+	for i := 0; ; i++ {
+		start := time.Now()
+		rs.handleOffer("stuck", sdp, "salt")
+		if took := time.Since(start); took > 5*time.Second {
+			t.Fatalf("offer %d took %v to forward to a stuck client", i, took)
+		}
+		session.mu.Lock()
+		dropped := session.ClientConn == nil
+		session.mu.Unlock()
+		if dropped {
+			break
+		}
+		if i == 200 {
+			t.Fatal("stuck client never dropped")
+		}
+	}
+
+	// The stuck connection was closed, so the client's reads fail once it
+	// has drained what got through
+	_ = client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err := client.ReadMessage(); err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				t.Fatal("relay did not close the stuck connection")
+			}
+			break
+		}
+	}
+}
+
+// TestConcurrentSessions creates, updates, answers, polls, deletes and
+// expires sessions from many goroutines at once over a few codes, so that
+// they contend for the same sessions. Run with -race; a lock-order
+// inversion shows up as the timeout.
+func TestConcurrentSessions(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	rs := NewRelayServer()
+	rs.expiration = 20 * time.Millisecond
+	if err := rs.TrustCIDR("192.0.2.0/24"); err != nil { // httptest's RemoteAddr
+		t.Fatal(err)
+	}
+
+	codes := []string{"STRESS-A", "STRESS-B", "STRESS-C", "STRESS-D"}
+	var tokensMu sync.Mutex
+	tokens := map[string]string{}
+
+	do := func(method, path, body, code string) *httptest.ResponseRecorder {
+		// Long polls give up quickly, as a host that went away would
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		r := httptest.NewRequestWithContext(ctx, method, path, strings.NewReader(body))
+		tokensMu.Lock()
+		r.Header.Set(deleteTokenHeader, tokens[code])
+		tokensMu.Unlock()
+		w := httptest.NewRecorder()
+		rs.sessionHandler(w, r)
+		return w
+	}
+
+	const workers, iterations = 8, 200
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for n := 0; n < iterations; n++ {
+				code := codes[(i+n)%len(codes)]
+				switch (i*iterations + n) % 9 {
+				case 0:
+					w := do(http.MethodPost, "/session", `{"sdp":"offer","salt":"salt","viewer_sdp":"v","viewer_key":"k","pipe":true,"code":"`+code+`"}`, code)
+					var resp SessionResponse
+					if w.Code == http.StatusOK && json.Unmarshal(w.Body.Bytes(), &resp) == nil {
+						tokensMu.Lock()
+						tokens[code] = resp.DeleteToken
+						tokensMu.Unlock()
+					}
+				case 1:
+					do(http.MethodPut, "/session/"+code, `{"sdp":"offer2","salt":"salt2"}`, code)
+				case 2:
+					do(http.MethodPost, "/session/"+code+"/answer", `{"sdp":"answer"}`, code)
+				case 3:
+					do(http.MethodGet, "/session/"+code+"/answer?wait=1", "", code)
+				case 4:
+					do(http.MethodGet, "/session/"+code, "", code)
+				case 5:
+					do(http.MethodPatch, "/session/"+code, "", code)
+				case 6:
+					do(http.MethodDelete, "/session/"+code, "", code)
+				case 7:
+					rs.expireSessions(time.Now())
+				case 8:
+					rs.handleOffer(code, "offer3", "salt3")
+					_ = rs.Stats()
+				}
+			}
+		}(i)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(60 * time.Second):
+		t.Fatal("relay handlers deadlocked")
+	}
+
+	// Every session left in one map is in the other
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	for code, session := range rs.shortCodes {
+		if session.ViewerKey == "" && rs.sessions[code] != session {
+			t.Errorf("session %s is in shortCodes but not sessions", code)
+		}
+	}
+}
+
+// relayRequest sends a request to rs's session API, with token as the owner
+// token if set
+func relayRequest(rs *RelayServer, method, path, body, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set(deleteTokenHeader, token)
+	}
+	w := httptest.NewRecorder()
+	rs.sessionHandler(w, r)
+	return w
+}
+
+// createSession creates a session on rs from the JSON request body
+func createSession(t *testing.T, rs *RelayServer, body string) SessionResponse {
+	t.Helper()
+	w := relayRequest(rs, http.MethodPost, "/session", body, "")
+	var resp SessionResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("create session: %d %s", w.Code, w.Body)
+	}
+	return resp
+}
+
+// newAnswerSession creates a session on rs for the answer tests and returns
+// its code and owner token
+func newAnswerSession(t *testing.T, rs *RelayServer) (string, string) {
+	t.Helper()
+	resp := createSession(t, rs, `{"sdp":"offer","salt":"salt"}`)
+	return resp.Code, resp.DeleteToken
+}
+
+// pollAnswer polls for code's answer and returns the response body
+func pollAnswer(rs *RelayServer, ctx context.Context, code string) map[string]string {
+	w := httptest.NewRecorder()
+	rs.sessionHandler(w, httptest.NewRequestWithContext(ctx, http.MethodGet, "/session/"+code+"/answer?wait=2", nil))
+	body := map[string]string{}
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+	return body
+}
+
+func submitAnswer(rs *RelayServer, code, sdp string) {
+	relayRequest(rs, http.MethodPost, "/session/"+code+"/answer", `{"sdp":"`+sdp+`"}`, "")
+}
+
+func TestAnswerReplaced(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	rs := NewRelayServer()
+	code, _ := newAnswerSession(t, rs)
+
+	// A poll already waiting gets the last of a burst, not the first
+	got := make(chan map[string]string, 1)
+	go func() { got <- pollAnswer(rs, context.Background(), code) }()
+	time.Sleep(50 * time.Millisecond)
+	submitAnswer(rs, code, "first")
+	submitAnswer(rs, code, "second")
+	if body := <-got; body["sdp"] != "second" {
+		t.Errorf("waiting poll = %v, want sdp second", body)
+	}
+
+	// So does one that arrives during the burst
+	submitAnswer(rs, code, "third")
+	go func() { got <- pollAnswer(rs, context.Background(), code) }()
+	time.Sleep(50 * time.Millisecond)
+	submitAnswer(rs, code, "fourth")
+	if body := <-got; body["sdp"] != "fourth" {
+		t.Errorf("poll during the burst = %v, want sdp fourth", body)
+	}
+}
+
+func TestAnswerCleared(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	rs := NewRelayServer()
+	code, token := newAnswerSession(t, rs)
+
+	// The host posts a new offer before the answer settles; the poll is
+	// told to keep waiting rather than handed an empty answer
+	submitAnswer(rs, code, "stale")
+	got := make(chan map[string]string, 1)
+	go func() { got <- pollAnswer(rs, context.Background(), code) }()
+	time.Sleep(50 * time.Millisecond)
+	relayRequest(rs, http.MethodPut, "/session/"+code, `{"sdp":"offer2","salt":"salt2"}`, token)
+	if body := <-got; body["status"] != "waiting" || body["sdp"] != "" {
+		t.Errorf("poll after the answer was cleared = %v, want status waiting", body)
+	}
+
+	// A poll whose host goes away stops waiting for the answer to settle
+	submitAnswer(rs, code, "answer")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if body := pollAnswer(rs, ctx, code); len(body) != 0 {
+		t.Errorf("cancelled poll = %v, want no response", body)
+	}
+	if took := time.Since(start); took >= answerCoalesceWindow {
+		t.Errorf("cancelled poll took %v, want less than %v", took, answerCoalesceWindow)
+	}
+}
+
+func TestAnswerWebSocket(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	rs := NewRelayServer()
+	code, _ := newAnswerSession(t, rs)
+	ts := httptest.NewServer(http.HandlerFunc(rs.HandleWebSocket))
+	defer ts.Close()
+
+	host, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/?session="+code, nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer host.Close()
+	if err := host.WriteJSON(signaling.RelayMessage{
+		Type:    signaling.MsgTypeRegister,
+		Role:    signaling.RoleHost,
+		Version: signaling.RelayProtocolVersion,
+	}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	rs.mu.RLock()
+	session := rs.sessions[code]
+	rs.mu.RUnlock()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		session.mu.Lock()
+		registered := session.HostConn != nil
+		session.mu.Unlock()
+		if registered {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("host never registered")
+		}
+	}
+
+	// A burst of answers reaches the host as the last one only
+	submitAnswer(rs, code, "first")
+	submitAnswer(rs, code, "second")
+	_ = host.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg signaling.RelayMessage
+	if err := host.ReadJSON(&msg); err != nil {
+		t.Fatalf("read answer: %v", err)
+	}
+	if msg.Type != signaling.MsgTypeAnswer || msg.SDP != "second" {
+		t.Errorf("host got %s %q, want answer second", msg.Type, msg.SDP)
+	}
+	_ = host.SetReadDeadline(time.Now().Add(2 * answerCoalesceWindow))
+	if err := host.ReadJSON(&msg); err == nil {
+		t.Errorf("host got a second message %s %q", msg.Type, msg.SDP)
+	}
+}
+
+func TestViewerCode(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	rs := NewRelayServer()
+	created := createSession(t, rs, `{"sdp":"offer","salt":"salt","viewer_sdp":"viewer offer","viewer_key":"key"}`)
+	if created.ViewerCode != created.Code+viewerCodeSuffix {
+		t.Fatalf("viewer code = %q, want %q", created.ViewerCode, created.Code+viewerCodeSuffix)
+	}
+
+	// The control code still hands out the host's offer, for a password
+	w := relayRequest(rs, http.MethodGet, "/session/"+created.Code, "", "")
+	var info SessionInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil || info.SDP != "offer" || info.Salt != "salt" {
+		t.Fatalf("GET control code = %d %s, want the host's offer", w.Code, w.Body)
+	}
+
+	// code+V, in any case, hands out the viewer offer and its key
+	viewer := func() signaling.ViewerSessionResponse {
+		t.Helper()
+		w := relayRequest(rs, http.MethodGet, "/session/"+strings.ToLower(created.ViewerCode), "", "")
+		var resp signaling.ViewerSessionResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("GET viewer code = %d %s", w.Code, w.Body)
+		}
+		return resp
+	}
+	if got := viewer(); got.SDP != "viewer offer" || got.Key != "key" || !got.ReadOnly || got.Used {
+		t.Fatalf("GET viewer code = %+v, want an unused read-only viewer offer", got)
+	}
+
+	// A viewer's answer reaches the host polling the viewer code, and marks
+	// the offer used until the host replaces it
+	submitAnswer(rs, created.ViewerCode, "viewer answer")
+	if body := pollAnswer(rs, context.Background(), created.ViewerCode); body["sdp"] != "viewer answer" {
+		t.Errorf("viewer answer poll = %v, want sdp viewer answer", body)
+	}
+	if !viewer().Used {
+		t.Error("viewer offer not marked used after an answer")
+	}
+	if w := relayRequest(rs, http.MethodPut, "/session/"+created.ViewerCode, `{"sdp":"next viewer offer"}`, created.DeleteToken); w.Code != http.StatusOK {
+		t.Fatalf("PUT viewer code = %d %s", w.Code, w.Body)
+	}
+	if got := viewer(); got.SDP != "next viewer offer" || got.Used {
+		t.Errorf("GET viewer code after PUT = %+v, want an unused next viewer offer", got)
+	}
+}
+
+func TestViewerCodeLifetime(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	rs := NewRelayServer()
+	const body = `{"sdp":"offer","salt":"salt","viewer_sdp":"viewer offer","viewer_key":"key"}`
+
+	// Deleting the host's session takes the viewer code with it, waking a
+	// host polling for a viewer
+	deleted := createSession(t, rs, body)
+	polled := make(chan map[string]string, 1)
+	go func() { polled <- pollAnswer(rs, context.Background(), deleted.ViewerCode) }()
+	time.Sleep(50 * time.Millisecond)
+	if w := relayRequest(rs, http.MethodDelete, "/session/"+deleted.Code, "", deleted.DeleteToken); w.Code != http.StatusOK {
+		t.Fatalf("DELETE = %d %s", w.Code, w.Body)
+	}
+	select {
+	case body := <-polled:
+		if body["sdp"] != "" || body["status"] != "" {
+			t.Errorf("viewer poll after delete = %v, want not found", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("viewer poll not woken by the host's delete")
+	}
+	if w := relayRequest(rs, http.MethodGet, "/session/"+deleted.ViewerCode, "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET viewer code after delete = %d, want 404", w.Code)
+	}
+
+	// So does the host's session expiring, but not before
+	expired := createSession(t, rs, body)
+	rs.expireSessions(time.Now())
+	if w := relayRequest(rs, http.MethodGet, "/session/"+expired.ViewerCode, "", ""); w.Code != http.StatusOK {
+		t.Fatalf("GET viewer code of a live session = %d, want 200", w.Code)
+	}
+	rs.expireSessions(time.Now().Add(rs.expiration + time.Minute))
+	if w := relayRequest(rs, http.MethodGet, "/session/"+expired.ViewerCode, "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET viewer code after expiry = %d, want 404", w.Code)
+	}
+}
+
+func TestDeleteToken(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	rs := NewRelayServer()
+	created := createSession(t, rs, `{"sdp":"offer","salt":"salt","viewer_sdp":"viewer offer","viewer_key":"key"}`)
+	other := createSession(t, rs, `{"sdp":"offer","salt":"salt"}`)
+
+	for _, tt := range []struct {
+		name  string
+		token string
+	}{
+		{"missing token", ""},
+		{"wrong token", "not-the-token"},
+		{"another session's token", other.DeleteToken},
+	} {
+		if w := relayRequest(rs, http.MethodDelete, "/session/"+created.Code, "", tt.token); w.Code != http.StatusForbidden {
+			t.Errorf("DELETE with %s = %d, want 403", tt.name, w.Code)
+		}
+		if w := relayRequest(rs, http.MethodDelete, "/session/"+created.ViewerCode, "", tt.token); w.Code != http.StatusForbidden {
+			t.Errorf("DELETE viewer code with %s = %d, want 403", tt.name, w.Code)
+		}
+	}
+	if w := relayRequest(rs, http.MethodGet, "/session/"+created.Code, "", ""); w.Code != http.StatusOK {
+		t.Fatalf("GET after refused deletes = %d, want 200", w.Code)
+	}
+
+	// The host's token deletes its viewer code, leaving the session itself
+	if w := relayRequest(rs, http.MethodDelete, "/session/"+created.ViewerCode, "", created.DeleteToken); w.Code != http.StatusOK {
+		t.Fatalf("DELETE viewer code with the host's token = %d %s", w.Code, w.Body)
+	}
+	if w := relayRequest(rs, http.MethodGet, "/session/"+created.ViewerCode, "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET deleted viewer code = %d, want 404", w.Code)
+	}
+	if w := relayRequest(rs, http.MethodGet, "/session/"+created.Code, "", ""); w.Code != http.StatusOK {
+		t.Errorf("GET session after deleting its viewer code = %d, want 200", w.Code)
+	}
+}
+
+func TestStats(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	rs := NewRelayServer()
+	stats := func() RelayStats {
+		t.Helper()
+		w := httptest.NewRecorder()
+		rs.HandleStats(w, httptest.NewRequest(http.MethodGet, "/stats", nil))
+		var s RelayStats
+		if err := json.Unmarshal(w.Body.Bytes(), &s); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("GET /stats = %d %s", w.Code, w.Body)
+		}
+		return s
+	}
+	if s := stats(); s.ActiveSessions != 0 || s.SessionsServed != 0 || s.RateLimitRejections != 0 {
+		t.Fatalf("fresh relay stats = %+v, want nothing counted", s)
+	}
+
+	// Viewer codes belong to their host's session and aren't counted apart
+	first := createSession(t, rs, `{"sdp":"offer","salt":"salt","viewer_sdp":"viewer offer","viewer_key":"key"}`)
+	createSession(t, rs, `{"sdp":"offer","salt":"salt"}`)
+	if s := stats(); s.ActiveSessions != 2 || s.SessionsServed != 2 {
+		t.Errorf("stats after two sessions = %d active, %d served; want 2, 2", s.ActiveSessions, s.SessionsServed)
+	}
+
+	// Sessions served stays counted once deleted or expired
+	relayRequest(rs, http.MethodDelete, "/session/"+first.Code, "", first.DeleteToken)
+	if s := stats(); s.ActiveSessions != 1 || s.SessionsServed != 2 {
+		t.Errorf("stats after a delete = %d active, %d served; want 1, 2", s.ActiveSessions, s.SessionsServed)
+	}
+	rs.expireSessions(time.Now().Add(rs.expiration + time.Minute))
+	if s := stats(); s.ActiveSessions != 0 || s.SessionsServed != 2 {
+		t.Errorf("stats after expiry = %d active, %d served; want 0, 2", s.ActiveSessions, s.SessionsServed)
+	}
+
+	// Requests past the rate limit are counted as they are refused
+	for i := 0; i < maxRequestsPerIP; i++ {
+		relayRequest(rs, http.MethodGet, "/session/MISSING", "", "")
+	}
+	if s := stats(); s.RateLimitRejections == 0 {
+		t.Errorf("rate-limit rejections = 0 after %d more requests", maxRequestsPerIP)
+	}
+	if s := stats(); s.UptimeSeconds < 0 || !s.Started.Equal(rs.started) {
+		t.Errorf("uptime = %ds since %v, want counted from %v", s.UptimeSeconds, s.Started, rs.started)
+	}
+}
+
+func TestClientInterest(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	rs := NewRelayServer()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", rs.HandleWebSocket)
+	mux.HandleFunc("/session", rs.sessionHandler)
+	mux.HandleFunc("/session/", rs.sessionHandler)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	host := signaling.NewShortCodeClient(ts.URL, ts.URL)
+	code, viewerCode, err := host.CreateSessionWithViewer("offer", "salt", "viewer offer", "key")
+	if err != nil {
+		t.Fatalf("CreateSessionWithViewer: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	interest := make(chan struct{}, 10)
+	watched := make(chan error, 1)
+	go func() {
+		watched <- host.WatchClientInterest(ctx, code, func() { interest <- struct{}{} })
+	}()
+	rs.mu.RLock()
+	session := rs.sessions[code]
+	rs.mu.RUnlock()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		session.mu.Lock()
+		registered := session.HostConn != nil
+		session.mu.Unlock()
+		if registered {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("host never started watching")
+		}
+	}
+
+	// Each fetch of the offer is reported, but a viewer's isn't
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get(ts.URL + "/session/" + code)
+		if err != nil {
+			t.Fatalf("GET session: %v", err)
+		}
+		resp.Body.Close()
+		select {
+		case <-interest:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("fetch %d not reported to the host", i+1)
+		}
+	}
+	if w := relayRequest(rs, http.MethodGet, "/session/"+viewerCode, "", ""); w.Code != http.StatusOK {
+		t.Fatalf("GET viewer code = %d", w.Code)
+	}
+	select {
+	case <-interest:
+		t.Error("a viewer's fetch was reported as a client's")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Watching ends quietly with its context
+	cancel()
+	select {
+	case err := <-watched:
+		if err != nil {
+			t.Errorf("WatchClientInterest after cancel: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WatchClientInterest didn't return after cancel")
+	}
+}
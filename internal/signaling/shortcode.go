@@ -4,32 +4,94 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
 // ShortCodeClient handles short code based signaling via HTTP
 type ShortCodeClient struct {
-	relayURL   string
-	clientURL  string
-	code       string
-	viewerCode string // Viewer session code (ends with V)
-	sdp        string
-	salt       string
-	viewerSDP  string // SDP for viewer peer
-	viewerKey  string // Base64-encoded viewer encryption key
-	client     *http.Client
+	relayURL    string
+	clientURL   string
+	code        string
+	viewerCode  string // Viewer session code (ends with V)
+	sdp         string
+	salt        string
+	viewerSDP   string // SDP for viewer peer
+	viewerKey   string // Base64-encoded viewer encryption key
+	deleteToken string // Owner token issued by the relay for update/heartbeat/delete
+	requested   string // Code to ask the relay for, or "" for a generated one
+	pipe        bool   // Ask the relay to allow its WebSocket pipe (EnablePipe)
+	config      ShortCodeConfig
+	client      *http.Client
+	breaker     *breaker
+
+	// Unix nanoseconds when a client last became likely: the offer was
+	// posted, or a client fetched it. Answer polls speed up after it.
+	active atomic.Int64
 }
 
+// ShortCodeConfig tunes how the client polls the relay for answers.
+//
+// A client usually connects within seconds of the code being shown, so for
+// FastPollWindow after the offer is posted (or a client fetches it) polls go
+// out every PollInterval. Past that, each empty poll doubles the delay up to
+// MaxPollInterval, trading up to that much extra connection latency for far
+// fewer requests to relays that answer polls at once. Relays that hold polls
+// open answer as soon as the client does, so they are always polled at
+// PollInterval.
+//
+// After FailureThreshold relay requests in a row fail (no reply, or a 5xx
+// status), the relay is treated as down: requests fail at once with
+// ErrRelayDown instead of going out, and every CircuitCooldown one request
+// is let through to check whether it is back.
+type ShortCodeConfig struct {
+	PollInterval     time.Duration // Delay between polls when no answer is ready yet
+	RetryBackoff     time.Duration // Delay before retrying after a relay error or repeated connection errors
+	LongPollTimeout  time.Duration // How long the relay may hold an answer poll open
+	FastPollWindow   time.Duration // How long to keep polling at PollInterval once a client is likely
+	MaxPollInterval  time.Duration // Longest delay between polls outside FastPollWindow
+	FailureThreshold int           // Consecutive failed requests before the relay is treated as down (negative = never)
+	CircuitCooldown  time.Duration // Delay between checks while the relay is treated as down
+}
+
+// longPollGrace is added to LongPollTimeout for the HTTP client timeout so
+// the relay always answers before the client gives up
+const longPollGrace = 5 * time.Second
+
+// DefaultShortCodeConfig returns the default polling configuration. The
+// long-poll is kept short so a connection lost to a network change (wifi to
+// cellular) is noticed quickly; relays that ignore ?wait= hold polls for
+// 30s, which times out and is retried like any dropped poll.
+func DefaultShortCodeConfig() ShortCodeConfig {
+	return ShortCodeConfig{
+		PollInterval:     100 * time.Millisecond,
+		RetryBackoff:     1 * time.Second,
+		LongPollTimeout:  10 * time.Second,
+		FastPollWindow:   20 * time.Second,
+		MaxPollInterval:  2 * time.Second,
+		FailureThreshold: 5,
+		CircuitCooldown:  30 * time.Second,
+	}
+}
+
+// deleteTokenHeader carries the owner token on session mutations
+const deleteTokenHeader = "X-Delete-Token"
+
 // SessionCreateResponse is the response from creating a session
 type SessionCreateResponse struct {
-	Code       string `json:"code"`
-	ViewerCode string `json:"viewer_code,omitempty"` // Only if viewer session was created
-	ExpiresIn  int    `json:"expires_in"`
-	URL        string `json:"url,omitempty"`
+	Code        string `json:"code"`
+	ViewerCode  string `json:"viewer_code,omitempty"`  // Only if viewer session was created
+	DeleteToken string `json:"delete_token,omitempty"` // Owner token for PUT/PATCH/DELETE
+	ExpiresIn   int    `json:"expires_in"`
+	URL         string `json:"url,omitempty"`
 }
 
 // SessionGetResponse is the response from getting a session
@@ -44,39 +106,123 @@ type AnswerPollResponse struct {
 	Status string `json:"status,omitempty"`
 }
 
-// NewShortCodeClient creates a new short code client
+// NewShortCodeClient creates a new short code client with the default polling configuration
 func NewShortCodeClient(relayURL, clientURL string) *ShortCodeClient {
+	return NewShortCodeClientWithConfig(relayURL, clientURL, DefaultShortCodeConfig())
+}
+
+// NewShortCodeClientWithConfig creates a new short code client. Zero fields
+// in config fall back to the defaults.
+func NewShortCodeClientWithConfig(relayURL, clientURL string, config ShortCodeConfig) *ShortCodeClient {
+	defaults := DefaultShortCodeConfig()
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaults.PollInterval
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaults.RetryBackoff
+	}
+	if config.LongPollTimeout <= 0 {
+		config.LongPollTimeout = defaults.LongPollTimeout
+	}
+	if config.FastPollWindow <= 0 {
+		config.FastPollWindow = defaults.FastPollWindow
+	}
+	if config.MaxPollInterval < config.PollInterval {
+		config.MaxPollInterval = max(defaults.MaxPollInterval, config.PollInterval)
+	}
+	if config.FailureThreshold == 0 {
+		config.FailureThreshold = defaults.FailureThreshold
+	}
+	if config.CircuitCooldown <= 0 {
+		config.CircuitCooldown = defaults.CircuitCooldown
+	}
+
 	return &ShortCodeClient{
 		relayURL:  strings.TrimSuffix(relayURL, "/"),
 		clientURL: strings.TrimSuffix(clientURL, "/"),
+		config:    config,
 		client: &http.Client{
-			Timeout: 35 * time.Second, // Slightly longer than long-poll timeout
+			Timeout: config.LongPollTimeout + longPollGrace, // Slightly longer than long-poll timeout
+		},
+		breaker: &breaker{
+			threshold: max(config.FailureThreshold, 0),
+			cooldown:  config.CircuitCooldown,
 		},
 	}
 }
 
+// OnRelayStateChange sets a handler called with true when the relay starts
+// being treated as down (see ShortCodeConfig), and with false once a
+// request gets through again
+func (c *ShortCodeClient) OnRelayStateChange(handler func(down bool)) {
+	c.breaker.mu.Lock()
+	defer c.breaker.mu.Unlock()
+	c.breaker.onChange = handler
+}
+
+// RequestCode makes later CreateSession calls ask the relay for code
+// instead of a generated one; the relay refuses with ErrCodeTaken if
+// another session holds it. code should come from NormalizeRequestedCode.
+func (c *ShortCodeClient) RequestCode(code string) {
+	c.requested = code
+}
+
+// do sends a request to the relay through the circuit breaker. A transport
+// error is wrapped as ErrRelayUnreachable with what was being done.
+func (c *ShortCodeClient) do(req *http.Request, action string) (*http.Response, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, fmt.Errorf("failed to %s: %w", action, err)
+	}
+
+	resp, err := c.client.Do(req)
+	switch {
+	case err != nil && req.Context().Err() != nil:
+		// Given up on by the caller, which says nothing about the relay
+		c.breaker.abandon()
+		return nil, fmt.Errorf("failed to %s: %w", action, err)
+	case err != nil:
+		c.breaker.record(true)
+		return nil, unreachable(action, err)
+	default:
+		c.breaker.record(resp.StatusCode >= http.StatusInternalServerError)
+	}
+	return resp, nil
+}
+
 // CreateSession creates a new session and returns a short code
 func (c *ShortCodeClient) CreateSession(sdp, salt string) (string, error) {
 	c.sdp = sdp
 	c.salt = salt
 
-	body, err := json.Marshal(map[string]string{
+	fields := map[string]any{
 		"sdp":  sdp,
 		"salt": salt,
-	})
+	}
+	if c.requested != "" {
+		fields["code"] = c.requested
+	}
+	if c.pipe {
+		fields["pipe"] = true
+	}
+	body, err := json.Marshal(fields)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	resp, err := c.client.Post(c.relayURL+"/session", "application/json", bytes.NewReader(body))
+	req, err := http.NewRequest(http.MethodPost, c.relayURL+"/session", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(req, "create session")
 	if err != nil {
-		return "", fmt.Errorf("failed to create session: %w", err)
+		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("relay returned error: %s", string(bodyBytes))
+		return "", fmt.Errorf("failed to create session: %w", statusError(resp))
 	}
 
 	var result SessionCreateResponse
@@ -85,6 +231,8 @@ func (c *ShortCodeClient) CreateSession(sdp, salt string) (string, error) {
 	}
 
 	c.code = result.Code
+	c.deleteToken = result.DeleteToken
+	c.markActive()
 	return result.Code, nil
 }
 
@@ -100,10 +248,7 @@ func (c *ShortCodeClient) GetViewerCode() string {
 
 // GetClientURL returns the URL for clients to connect
 func (c *ShortCodeClient) GetClientURL() string {
-	if c.clientURL != "" {
-		return fmt.Sprintf("%s/?c=%s", c.clientURL, c.code)
-	}
-	return fmt.Sprintf("%s/?c=%s", GetClientURL(), c.code)
+	return c.sessionURL(c.code)
 }
 
 // GetViewerURL returns the URL for viewers to connect (read-only)
@@ -111,10 +256,21 @@ func (c *ShortCodeClient) GetViewerURL() string {
 	if c.viewerCode == "" {
 		return ""
 	}
-	if c.clientURL != "" {
-		return fmt.Sprintf("%s/?c=%s", c.clientURL, c.viewerCode)
+	return c.sessionURL(c.viewerCode)
+}
+
+// sessionURL builds the web client link for a code. The web client uses
+// the default relay unless told otherwise, so a custom relay is passed along.
+func (c *ShortCodeClient) sessionURL(code string) string {
+	base := c.clientURL
+	if base == "" {
+		base = GetClientURL()
+	}
+	link := fmt.Sprintf("%s/?c=%s", base, code)
+	if c.relayURL != "" && c.relayURL != defaultRelayURL {
+		link += "&relay=" + url.QueryEscape(c.relayURL)
 	}
-	return fmt.Sprintf("%s/?c=%s", GetClientURL(), c.viewerCode)
+	return link
 }
 
 // CreateSessionWithViewer creates a session with both control and viewer codes
@@ -124,25 +280,37 @@ func (c *ShortCodeClient) CreateSessionWithViewer(sdp, salt, viewerSDP, viewerKe
 	c.viewerSDP = viewerSDP
 	c.viewerKey = viewerKey
 
-	body, err := json.Marshal(map[string]string{
+	fields := map[string]any{
 		"sdp":        sdp,
 		"salt":       salt,
 		"viewer_sdp": viewerSDP,
 		"viewer_key": viewerKey,
-	})
+	}
+	if c.requested != "" {
+		fields["code"] = c.requested
+	}
+	if c.pipe {
+		fields["pipe"] = true
+	}
+	body, err := json.Marshal(fields)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	resp, err := c.client.Post(c.relayURL+"/session", "application/json", bytes.NewReader(body))
+	req, err := http.NewRequest(http.MethodPost, c.relayURL+"/session", bytes.NewReader(body))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(req, "create session")
 	if err != nil {
-		return "", "", fmt.Errorf("failed to create session: %w", err)
+		return "", "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", "", fmt.Errorf("relay returned error: %s", string(bodyBytes))
+		return "", "", fmt.Errorf("failed to create session: %w", statusError(resp))
 	}
 
 	var result SessionCreateResponse
@@ -152,6 +320,8 @@ func (c *ShortCodeClient) CreateSessionWithViewer(sdp, salt, viewerSDP, viewerKe
 
 	c.code = result.Code
 	c.viewerCode = result.ViewerCode
+	c.deleteToken = result.DeleteToken
+	c.markActive()
 	return result.Code, result.ViewerCode, nil
 }
 
@@ -173,21 +343,60 @@ func (c *ShortCodeClient) UpdateSession(sdp, salt string) error {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	c.setDeleteToken(req)
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req, "update session")
 	if err != nil {
-		return fmt.Errorf("failed to update session: %w", err)
+		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("relay returned error: %s", string(bodyBytes))
+		return fmt.Errorf("failed to update session: %w", statusError(resp))
+	}
+
+	c.markActive()
+	return nil
+}
+
+// UpdateViewerSession replaces the viewer offer once a viewer has answered
+// it, so the next viewer to open the viewer link gets a fresh one
+func (c *ShortCodeClient) UpdateViewerSession(viewerSDP string) error {
+	if c.viewerCode == "" {
+		return fmt.Errorf("no viewer session created")
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"sdp": viewerSDP,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, c.relayURL+"/session/"+c.viewerCode, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.setDeleteToken(req)
+
+	resp, err := c.do(req, "update viewer session")
+	if err != nil {
+		return err
 	}
+	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to update viewer session: %w", statusError(resp))
+	}
 	return nil
 }
 
+// markActive notes that a client is likely to connect soon
+func (c *ShortCodeClient) markActive() {
+	c.active.Store(time.Now().UnixNano())
+}
+
 // SendHeartbeat sends a heartbeat to keep the session alive on the relay
 func (c *ShortCodeClient) SendHeartbeat() error {
 	if c.code == "" {
@@ -198,21 +407,121 @@ func (c *ShortCodeClient) SendHeartbeat() error {
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
+	c.setDeleteToken(req)
 
-	resp, err := c.client.Do(req)
+	resp, err := c.do(req, "send heartbeat")
 	if err != nil {
-		return fmt.Errorf("failed to send heartbeat: %w", err)
+		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("relay returned error: %s", string(bodyBytes))
+		return fmt.Errorf("failed to send heartbeat: %w", statusError(resp))
+	}
+
+	return nil
+}
+
+// DeleteSession removes the session from the relay so its code stops working
+// immediately instead of lingering until expiry. A session that is already
+// gone is not treated as an error.
+func (c *ShortCodeClient) DeleteSession() error {
+	if c.code == "" {
+		return fmt.Errorf("no session code")
+	}
+
+	// Short timeout - this runs during shutdown and must not stall it
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.relayURL+"/session/"+c.code, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	c.setDeleteToken(req)
+
+	resp, err := c.do(req, "delete session")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to delete session: %w", statusError(resp))
 	}
 
 	return nil
 }
 
+// setDeleteToken attaches the owner token so the relay accepts the mutation
+func (c *ShortCodeClient) setDeleteToken(req *http.Request) {
+	if c.deleteToken != "" {
+		req.Header.Set(deleteTokenHeader, c.deleteToken)
+	}
+}
+
+// WatchClientInterest calls onInterest each time a client fetches the offer
+// for code, until ctx ends. It registers with the relay as the session's
+// host over WebSocket, which the answer polls don't need; relays without
+// WebSocket support refuse the connection and nothing is reported.
+func (c *ShortCodeClient) WatchClientInterest(ctx context.Context, code string, onInterest func()) error {
+	u, err := url.Parse(c.relayURL)
+	if err != nil {
+		return fmt.Errorf("invalid relay URL: %w", err)
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/ws"
+	u.RawQuery = url.Values{"session": {code}}.Encode()
+
+	conn, _, err := relayDialer.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		return unreachable("connect to relay", err)
+	}
+	defer conn.Close()
+	if err := checkRelaySubprotocol(conn); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	if err := conn.WriteJSON(RelayMessage{
+		Type:      MsgTypeRegister,
+		SessionID: code,
+		Role:      RoleHost,
+		Version:   RelayProtocolVersion,
+	}); err != nil {
+		return fmt.Errorf("failed to register: %w", err)
+	}
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to read message: %w", err)
+		}
+
+		var msg RelayMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue // Skip malformed messages
+		}
+
+		switch msg.Type {
+		case MsgTypeClientInterest:
+			c.markActive()
+			onInterest()
+		case MsgTypeError:
+			return relayError(msg)
+		}
+	}
+}
+
 // WaitForAnswer polls the relay for an answer with context support
 func (c *ShortCodeClient) WaitForAnswer(timeout time.Duration) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
@@ -222,6 +531,17 @@ func (c *ShortCodeClient) WaitForAnswer(timeout time.Duration) (string, error) {
 
 // WaitForAnswerWithContext polls the relay for an answer with cancellation support
 func (c *ShortCodeClient) WaitForAnswerWithContext(ctx context.Context) (string, error) {
+	return c.waitForAnswer(ctx, c.code, ErrSessionExpired)
+}
+
+// waitForAnswer long-polls the relay for an answer to code. A connection
+// error, such as the reset after the host switches networks, is retried at
+// once on a fresh connection; relay errors and repeated connection errors
+// back off by RetryBackoff, and while the relay is treated as down polls wait
+// for the circuit breaker. notFound is returned if the relay has no session.
+func (c *ShortCodeClient) waitForAnswer(ctx context.Context, code string, notFound error) (string, error) {
+	retried := false // The last poll failed with a connection error and was retried at once
+	interval := c.config.PollInterval
 	for {
 		select {
 		case <-ctx.Done():
@@ -229,67 +549,113 @@ func (c *ShortCodeClient) WaitForAnswerWithContext(ctx context.Context) (string,
 		default:
 		}
 
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.relayURL+"/session/"+c.code+"/answer", nil)
-		if err != nil {
-			return "", fmt.Errorf("failed to create request: %w", err)
-		}
-
-		resp, err := c.client.Do(req)
-		if err != nil {
-			if ctx.Err() != nil {
-				return "", ctx.Err()
-			}
-			// Retry on network errors
-			select {
-			case <-ctx.Done():
-				return "", ctx.Err()
-			case <-time.After(1 * time.Second):
-				continue
-			}
-		}
-
-		if resp.StatusCode == http.StatusNotFound {
-			_ = resp.Body.Close()
-			return "", fmt.Errorf("session expired or not found")
-		}
-
-		var result AnswerPollResponse
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			_ = resp.Body.Close()
-			select {
-			case <-ctx.Done():
-				return "", ctx.Err()
-			case <-time.After(1 * time.Second):
+		delay := c.config.PollInterval
+		start := time.Now()
+		sdp, err := c.pollAnswer(ctx, code)
+		switch {
+		case err == nil && sdp != "":
+			return sdp, nil
+		case err == nil:
+			// status == "waiting", continue polling
+			retried = false
+			interval = c.nextPollInterval(interval, time.Since(start))
+			delay = interval
+		case errors.Is(err, ErrSessionExpired):
+			return "", notFound
+		case ctx.Err() != nil:
+			return "", ctx.Err()
+		case errors.Is(err, ErrRelayDown):
+			// Wait for the breaker's next check rather than spin
+			retried = false
+			delay = max(c.breaker.retryIn(), c.config.PollInterval)
+		case isConnectionError(err):
+			// Pooled connections may belong to the network that went away
+			c.client.CloseIdleConnections()
+			if !retried {
+				retried = true
 				continue
 			}
-		}
-		_ = resp.Body.Close()
-
-		if result.SDP != "" {
-			return result.SDP, nil
+			delay = c.config.RetryBackoff
+		default:
+			delay = c.config.RetryBackoff
 		}
 
-		// status == "waiting", continue polling
 		select {
 		case <-ctx.Done():
 			return "", ctx.Err()
-		case <-time.After(100 * time.Millisecond):
+		case <-time.After(delay):
 		}
 	}
 }
 
+// nextPollInterval returns the delay before the next answer poll, after the
+// last one came back empty having been held open for held (see
+// ShortCodeConfig)
+func (c *ShortCodeClient) nextPollInterval(last, held time.Duration) time.Duration {
+	if held >= c.config.LongPollTimeout/2 {
+		return c.config.PollInterval // The relay long-polls
+	}
+	if time.Since(time.Unix(0, c.active.Load())) < c.config.FastPollWindow {
+		return c.config.PollInterval
+	}
+	return min(max(2*last, c.config.PollInterval), c.config.MaxPollInterval)
+}
+
+// pollAnswer makes one long-poll request for code's answer. It returns ""
+// if the relay is still waiting when the poll ends.
+func (c *ShortCodeClient) pollAnswer(ctx context.Context, code string) (string, error) {
+	wait := int(c.config.LongPollTimeout / time.Second)
+	if wait < 1 {
+		wait = 1
+	}
+	answerURL := fmt.Sprintf("%s/session/%s/answer?wait=%d", c.relayURL, code, wait)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, answerURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.do(req, "poll for answer")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to poll for answer: %w", statusError(resp))
+	}
+
+	var result AnswerPollResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode answer: %w", err)
+	}
+	return result.SDP, nil
+}
+
+// isConnectionError reports whether err means the connection to the relay
+// failed rather than the relay: reset, closed mid-response, or timed out.
+// These are typical of a network change, and a new connection usually
+// gets through at once.
+func isConnectionError(err error) bool {
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
 // GetSession fetches session info by code (for client use)
 func GetSession(relayURL, code string) (*SessionGetResponse, error) {
 	client := &http.Client{Timeout: 10 * time.Second}
 
 	resp, err := client.Get(relayURL + "/session/" + strings.ToUpper(code))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get session: %w", err)
+		return nil, unreachable("get session", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("session not found")
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get session: %w", statusError(resp))
 	}
 
 	var result SessionGetResponse
@@ -311,13 +677,12 @@ func SubmitAnswer(relayURL, code, sdp string) error {
 
 	resp, err := client.Post(relayURL+"/session/"+strings.ToUpper(code)+"/answer", "application/json", bytes.NewReader(body))
 	if err != nil {
-		return fmt.Errorf("failed to submit answer: %w", err)
+		return unreachable("submit answer", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("relay returned error: %s", string(bodyBytes))
+		return fmt.Errorf("failed to submit answer: %w", statusError(resp))
 	}
 
 	return nil
@@ -328,75 +693,24 @@ func (c *ShortCodeClient) WaitForViewerAnswerWithContext(ctx context.Context) (s
 	if c.viewerCode == "" {
 		return "", fmt.Errorf("no viewer session created")
 	}
-
-	for {
-		select {
-		case <-ctx.Done():
-			return "", ctx.Err()
-		default:
-		}
-
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.relayURL+"/session/"+c.viewerCode+"/answer", nil)
-		if err != nil {
-			return "", fmt.Errorf("failed to create request: %w", err)
-		}
-
-		resp, err := c.client.Do(req)
-		if err != nil {
-			if ctx.Err() != nil {
-				return "", ctx.Err()
-			}
-			// Retry on network errors
-			select {
-			case <-ctx.Done():
-				return "", ctx.Err()
-			case <-time.After(1 * time.Second):
-				continue
-			}
-		}
-
-		if resp.StatusCode == http.StatusNotFound {
-			_ = resp.Body.Close()
-			return "", fmt.Errorf("viewer session expired or not found")
-		}
-
-		var result AnswerPollResponse
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			_ = resp.Body.Close()
-			select {
-			case <-ctx.Done():
-				return "", ctx.Err()
-			case <-time.After(1 * time.Second):
-				continue
-			}
-		}
-		_ = resp.Body.Close()
-
-		if result.SDP != "" {
-			return result.SDP, nil
-		}
-
-		// status == "waiting", continue polling
-		select {
-		case <-ctx.Done():
-			return "", ctx.Err()
-		case <-time.After(100 * time.Millisecond):
-		}
-	}
+	return c.waitForAnswer(ctx, c.viewerCode, errViewerNotFound)
 }
 
+// errViewerNotFound is returned when the relay no longer has the viewer session
+var errViewerNotFound = fmt.Errorf("viewer %w", ErrSessionExpired)
+
 // GetViewerSession fetches viewer session info by code (for client use)
 func GetViewerSession(relayURL, code string) (*ViewerSessionResponse, error) {
 	client := &http.Client{Timeout: 10 * time.Second}
 
 	resp, err := client.Get(relayURL + "/session/" + strings.ToUpper(code))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get viewer session: %w", err)
+		return nil, unreachable("get viewer session", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("viewer session not found")
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get viewer session: %w", statusError(resp))
 	}
 
 	var result ViewerSessionResponse
@@ -414,7 +728,7 @@ func FetchICEServers(relayURL string) (*ICEServersResponse, error) {
 
 	resp, err := client.Get(strings.TrimSuffix(relayURL, "/") + "/ice-servers")
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch ICE servers: %w", err)
+		return nil, unreachable("fetch ICE servers", err)
 	}
 	defer resp.Body.Close()
 
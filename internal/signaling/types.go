@@ -1,7 +1,13 @@
 // Package signaling provides signaling mechanisms for WebRTC connection establishment
 package signaling
 
-import "os"
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
 
 // SignalingMethod represents the method used for SDP exchange
 type SignalingMethod int
@@ -32,6 +38,35 @@ func (m SignalingMethod) String() string {
 	}
 }
 
+// methodNames are the names ParseMethods accepts
+var methodNames = map[string]SignalingMethod{
+	"relay":     MethodShortCode,
+	"websocket": MethodRelay,
+	"upnp":      MethodHTTP,
+	"manual":    MethodManual,
+}
+
+// ParseMethods parses a comma-separated list of signaling methods, in the
+// order to try them: relay (short codes through the relay's HTTP API),
+// websocket (the relay's WebSocket API), upnp (an HTTP server on this host,
+// reachable through a UPnP port mapping) and manual (QR code and
+// copy-paste)
+func ParseMethods(list string) ([]SignalingMethod, error) {
+	var methods []SignalingMethod
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		method, ok := methodNames[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown signaling method %q: want relay, websocket, upnp or manual", name)
+		}
+		if slices.Contains(methods, method) {
+			return nil, fmt.Errorf("signaling method %q is listed twice", name)
+		}
+		methods = append(methods, method)
+	}
+	return methods, nil
+}
+
 // Environment variable names for customization
 const (
 	EnvRelayURL  = "TT_RELAY_URL"
@@ -62,12 +97,13 @@ func GetClientURL() string {
 
 // RelayMessage represents a message in the relay protocol
 type RelayMessage struct {
-	Type      string `json:"type"`                 // register, offer, answer, error
+	Type      string `json:"type"`                 // register, offer, answer, error, client_interest
 	SessionID string `json:"session_id,omitempty"` // Session identifier
 	Role      string `json:"role,omitempty"`       // host, client
 	SDP       string `json:"sdp,omitempty"`        // SDP offer or answer
 	Salt      string `json:"salt,omitempty"`       // Base64 encoded salt for key derivation
 	Error     string `json:"error,omitempty"`      // Error message
+	Version   int    `json:"version,omitempty"`    // Relay protocol version (register, or the relay's own on a version error; 0 = unversioned)
 }
 
 // Message types
@@ -76,8 +112,65 @@ const (
 	MsgTypeOffer    = "offer"
 	MsgTypeAnswer   = "answer"
 	MsgTypeError    = "error"
+
+	// MsgTypeClientInterest tells the host a client fetched its offer, so
+	// it can show that a client is connecting before the answer arrives
+	MsgTypeClientInterest = "client_interest"
+)
+
+// Relay protocol versions. Clients offer RelaySubprotocol() in the
+// Sec-WebSocket-Protocol header and repeat the version in their register
+// message; the relay rejects versions it can't speak with an error message.
+// Clients that predate versioning send neither and are treated as version 1.
+const (
+	// RelayProtocolVersion is the relay protocol version spoken by this build
+	RelayProtocolVersion = 1
+
+	// MinRelayProtocolVersion is the oldest version the relay still accepts
+	MinRelayProtocolVersion = 1
+
+	// relaySubprotocolPrefix prefixes the version in the WebSocket subprotocol
+	relaySubprotocolPrefix = "tt-relay.v"
 )
 
+// RelaySubprotocol returns the WebSocket subprotocol name for a relay
+// protocol version (e.g. "tt-relay.v1")
+func RelaySubprotocol(version int) string {
+	return relaySubprotocolPrefix + strconv.Itoa(version)
+}
+
+// ParseRelaySubprotocol returns the version named by a relay subprotocol,
+// or false if it isn't one
+func ParseRelaySubprotocol(protocol string) (int, bool) {
+	v, ok := strings.CutPrefix(protocol, relaySubprotocolPrefix)
+	if !ok {
+		return 0, false
+	}
+	version, err := strconv.Atoi(v)
+	if err != nil || version < 1 {
+		return 0, false
+	}
+	return version, true
+}
+
+// CheckRelayVersion reports whether a peer using the given relay protocol
+// version can talk to this build, with an error explaining which side to
+// upgrade if not. Version 0 (unversioned) is accepted as version 1.
+func CheckRelayVersion(version int) error {
+	supported := fmt.Sprintf("v%d", RelayProtocolVersion)
+	if MinRelayProtocolVersion < RelayProtocolVersion {
+		supported = fmt.Sprintf("v%d-v%d", MinRelayProtocolVersion, RelayProtocolVersion)
+	}
+
+	switch {
+	case version > RelayProtocolVersion:
+		return fmt.Errorf("relay protocol v%d is newer than this relay supports (%s): upgrade the relay", version, supported)
+	case version > 0 && version < MinRelayProtocolVersion:
+		return fmt.Errorf("relay protocol v%d is no longer supported (%s): upgrade terminal-tunnel", version, supported)
+	}
+	return nil
+}
+
 // Roles
 const (
 	RoleHost   = "host"
@@ -94,8 +187,19 @@ type CompactData struct {
 const (
 	// CompactVersion is the current compact data format version
 	CompactVersion byte = 0x01
+	// CompactVersionSaltLen is the compact offer format whose salt is
+	// length-prefixed, used for salts that name a KDF
+	CompactVersionSaltLen byte = 0x02
+	// CompactVersionExpiry is the current compact offer format: it carries
+	// the time the offer expires, and a length-prefixed salt
+	CompactVersionExpiry byte = 0x03
+	// CompactVersionNonce is the compact offer format that also carries a
+	// random nonce, and the answer format that echoes it back
+	CompactVersionNonce byte = 0x04
 	// SaltSize is the size of the salt in bytes
 	SaltSize = 16
+	// NonceSize is the size of the offer nonce in bytes
+	NonceSize = 8
 )
 
 // ViewerSessionInfo contains info about a viewer session
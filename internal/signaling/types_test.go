@@ -0,0 +1,93 @@
+package signaling
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseMethods(t *testing.T) {
+	got, err := ParseMethods("upnp, Relay,manual")
+	if err != nil {
+		t.Fatalf("ParseMethods failed: %v", err)
+	}
+	want := []SignalingMethod{MethodHTTP, MethodShortCode, MethodManual}
+	if !slices.Equal(got, want) {
+		t.Errorf("ParseMethods = %v, want %v", got, want)
+	}
+
+	for _, list := range []string{"", "relay,", "carrier-pigeon", "relay,relay"} {
+		if _, err := ParseMethods(list); err == nil {
+			t.Errorf("ParseMethods(%q) should fail", list)
+		}
+	}
+}
+
+func TestRelaySubprotocol(t *testing.T) {
+	for _, version := range []int{1, 2, 10} {
+		got, ok := ParseRelaySubprotocol(RelaySubprotocol(version))
+		if !ok || got != version {
+			t.Errorf("ParseRelaySubprotocol(RelaySubprotocol(%d)) = %d, %v", version, got, ok)
+		}
+	}
+	if got := RelaySubprotocol(1); got != "tt-relay.v1" {
+		t.Errorf("RelaySubprotocol(1) = %q, want tt-relay.v1", got)
+	}
+}
+
+func TestParseRelaySubprotocol(t *testing.T) {
+	tests := []struct {
+		protocol string
+		version  int
+		ok       bool
+	}{
+		{"tt-relay.v1", 1, true},
+		{"tt-relay.v12", 12, true},
+		{"tt-relay.v0", 0, false},
+		{"tt-relay.v-1", 0, false},
+		{"tt-relay.v", 0, false},
+		{"tt-relay.vX", 0, false},
+		{"tt-relay.1", 0, false},
+		{"TT-RELAY.V1", 0, false},
+		{"chat", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		version, ok := ParseRelaySubprotocol(tt.protocol)
+		if version != tt.version || ok != tt.ok {
+			t.Errorf("ParseRelaySubprotocol(%q) = %d, %v; want %d, %v", tt.protocol, version, ok, tt.version, tt.ok)
+		}
+	}
+}
+
+func TestCheckRelayVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		wantErr string // Substring of the error; "" for none
+	}{
+		{"unversioned", 0, ""},
+		{"oldest supported", MinRelayProtocolVersion, ""},
+		{"current", RelayProtocolVersion, ""},
+		{"newer", RelayProtocolVersion + 1, "upgrade the relay"},
+		{"much newer", RelayProtocolVersion + 10, "upgrade the relay"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckRelayVersion(tt.version)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("CheckRelayVersion(%d) = %v, want nil", tt.version, err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("CheckRelayVersion(%d) = %v, want an error saying %q", tt.version, err, tt.wantErr)
+			}
+		})
+	}
+
+	// Only once a version is retired is there one too old; below v1 is unversioned
+	if MinRelayProtocolVersion > 1 {
+		if err := CheckRelayVersion(MinRelayProtocolVersion - 1); err == nil || !strings.Contains(err.Error(), "upgrade terminal-tunnel") {
+			t.Errorf("CheckRelayVersion(%d) = %v, want an error saying to upgrade terminal-tunnel", MinRelayProtocolVersion-1, err)
+		}
+	}
+}
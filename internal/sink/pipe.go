@@ -0,0 +1,40 @@
+package sink
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// pipe writes raw output to a named pipe. Nothing waits for a reader: the
+// pipe is opened without blocking, and output sent while no one is reading
+// is dropped.
+type pipe struct {
+	path string
+	file *os.File
+}
+
+func (p *pipe) send(batch []byte, dropped int64) error {
+	if p.file == nil {
+		file, err := os.OpenFile(p.path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+		if err != nil {
+			return fmt.Errorf("no reader on the pipe: %w", err)
+		}
+		p.file = file
+	}
+	_ = p.file.SetWriteDeadline(time.Now().Add(sendTimeout))
+	if _, err := p.file.Write(batch); err != nil {
+		p.file.Close()
+		p.file = nil
+		return err
+	}
+	return nil
+}
+
+func (p *pipe) close() error {
+	if p.file == nil {
+		return nil
+	}
+	return p.file.Close()
+}
@@ -0,0 +1,35 @@
+//go:build !windows
+
+package sink
+
+import (
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestPipeWithoutReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.fifo")
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		t.Skip("no named pipes:", err)
+	}
+	s, err := Open("pipe:"+path, "ABC123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Write([]byte("nobody reads this\n"))
+	done := make(chan error)
+	go func() { done <- s.Close() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close blocked on a pipe without a reader")
+	}
+	if s.Dropped() == 0 {
+		t.Error("output with no reader should count as dropped")
+	}
+}
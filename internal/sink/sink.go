@@ -0,0 +1,229 @@
+// Package sink streams session output off the host as it is produced: to a
+// syslog server, an HTTP webhook or a named pipe. Output is queued in memory
+// and sent in batches from a background goroutine, so a slow or unreachable
+// destination never stalls the session. Output that doesn't fit in the
+// queue is dropped, and the destination is told how much.
+package sink
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// QueueSize is how many bytes of output wait for the destination
+	// before newer output is dropped
+	QueueSize = 1 << 20
+
+	batchInterval = time.Second      // How often queued output is sent
+	batchSize     = 64 * 1024        // Queued bytes that trigger a send before the interval
+	sendTimeout   = 10 * time.Second // Limit on each send
+	closeTimeout  = 5 * time.Second  // How long Close waits for the last batch
+)
+
+// destination delivers batches of output
+type destination interface {
+	// send delivers batch, reporting dropped bytes of output lost since
+	// the previous send
+	send(batch []byte, dropped int64) error
+	close() error
+}
+
+// Sink tees session output to a destination
+type Sink struct {
+	dest destination
+	name string // Destination for display, without credentials
+
+	mu      sync.Mutex
+	queue   []byte
+	dropped int64 // Bytes dropped since the last send
+	total   int64 // Bytes dropped overall
+	failing bool  // The last send failed
+	closed  bool
+	onError func(err error)
+
+	wake chan struct{}
+	done chan struct{}
+}
+
+// Open starts streaming to spec, one of:
+//
+//	syslog://host[:port]      RFC 5424 over UDP, one message per line
+//	syslog+tcp://host[:port]  the same over TCP
+//	http(s)://...             a JSON POST per batch
+//	pipe:/path                raw output to a named pipe
+//
+// session identifies the session in what is sent.
+func Open(spec, session string) (*Sink, error) {
+	dest, name, err := parse(spec, session)
+	if err != nil {
+		return nil, err
+	}
+	s := &Sink{
+		dest: dest,
+		name: name,
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Validate reports whether Open would accept spec, without connecting
+func Validate(spec string) error {
+	_, _, err := parse(spec, "")
+	return err
+}
+
+// parse returns the destination spec names and how to show it
+func parse(spec, session string) (destination, string, error) {
+	if path, ok := strings.CutPrefix(spec, "pipe:"); ok && path != "" {
+		return &pipe{path: path}, spec, nil
+	}
+	u, err := url.Parse(spec)
+	if err == nil {
+		switch u.Scheme {
+		case "syslog", "syslog+udp", "syslog+tcp":
+			if u.Host == "" {
+				break
+			}
+			network := "udp"
+			if u.Scheme == "syslog+tcp" {
+				network = "tcp"
+			}
+			host := u.Host
+			if u.Port() == "" {
+				host += ":514"
+			}
+			return newSyslog(network, host, session), spec, nil
+		case "http", "https":
+			if u.Host == "" {
+				break
+			}
+			return newWebhook(spec, session), u.Redacted(), nil
+		}
+	}
+	return nil, "", fmt.Errorf("unsupported output sink %q: want syslog://host[:port], syslog+tcp://host[:port], an http(s) URL or pipe:/path", spec)
+}
+
+// String returns the destination, without any credentials in it
+func (s *Sink) String() string {
+	return s.name
+}
+
+// OnError sets a handler called when sending starts failing, and again
+// only after a send has succeeded in between
+func (s *Sink) OnError(handler func(err error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onError = handler
+}
+
+// Write queues a copy of p. It never blocks: when the queue is full, p is
+// dropped. Its signature suits Bridge.AddRecorder.
+func (s *Sink) Write(p []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	if len(s.queue)+len(p) > QueueSize {
+		s.dropped += int64(len(p))
+		s.total += int64(len(p))
+		return nil
+	}
+	s.queue = append(s.queue, p...)
+	if len(s.queue) >= batchSize {
+		s.signal()
+	}
+	return nil
+}
+
+// Dropped returns how many bytes of output have been dropped because the
+// destination couldn't keep up
+func (s *Sink) Dropped() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.total
+}
+
+// Close sends what is queued, waiting up to closeTimeout, and closes the
+// destination
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.signal()
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return s.dest.close()
+	case <-time.After(closeTimeout):
+		return fmt.Errorf("output sink %s: gave up waiting for the last output to be sent", s.name)
+	}
+}
+
+// signal wakes the sender. Caller must hold s.mu.
+func (s *Sink) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run sends queued output every batchInterval, or sooner when a batch fills
+// up, until the sink is closed
+func (s *Sink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(batchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.wake:
+		}
+
+		s.mu.Lock()
+		batch, dropped, closed := s.queue, s.dropped, s.closed
+		s.queue, s.dropped = nil, 0
+		s.mu.Unlock()
+
+		if len(batch) > 0 || dropped > 0 {
+			err := s.dest.send(batch, dropped)
+			s.mu.Lock()
+			if err != nil {
+				// What couldn't be sent counts as dropped for the next batch
+				s.dropped += int64(len(batch)) + dropped
+				s.total += int64(len(batch))
+			}
+			report := err != nil && !s.failing
+			s.failing = err != nil
+			onError := s.onError
+			s.mu.Unlock()
+			if report && onError != nil {
+				onError(fmt.Errorf("output sink %s: %w", s.name, err))
+			}
+		}
+		if closed {
+			return
+		}
+	}
+}
+
+// hostname names this host in what is sent
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "-"
+	}
+	return name
+}
@@ -0,0 +1,119 @@
+package sink
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOpenInvalid(t *testing.T) {
+	for _, spec := range []string{"", "pipe:", "syslog://", "ftp://example.com", "example.com:514"} {
+		if _, err := Open(spec, "ABC123"); err == nil {
+			t.Errorf("Open(%q) should fail", spec)
+		}
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var mu sync.Mutex
+	var batches []webhookBatch
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch webhookBatch
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Errorf("bad body: %v", err)
+		}
+		mu.Lock()
+		batches = append(batches, batch)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	s, err := Open(srv.URL, "ABC123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Write([]byte("hello "))
+	_ = s.Write([]byte("world\r\n"))
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(batches) != 1 {
+		t.Fatalf("got %d batches, want 1", len(batches))
+	}
+	got := batches[0]
+	if got.Session != "ABC123" || got.Sequence != 1 || got.Output != "hello world\r\n" || got.Dropped != 0 {
+		t.Errorf("batch = %+v", got)
+	}
+}
+
+func TestSlowDestinationDrops(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+
+	s, err := Open(srv.URL, "ABC123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Fill one batch, which the server holds, then more than the queue
+	chunk := make([]byte, batchSize)
+	_ = s.Write(chunk)
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	for i := 0; i < QueueSize/batchSize+2; i++ {
+		_ = s.Write(chunk)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Write blocked on a slow destination")
+	}
+	if s.Dropped() == 0 {
+		t.Error("output past the queue size should be dropped")
+	}
+	close(release)
+	_ = s.Close()
+}
+
+func TestSyslog(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("no UDP:", err)
+	}
+	defer conn.Close()
+
+	s, err := Open("syslog://"+conn.LocalAddr().String(), "ABC123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Write([]byte("first\r\nsec"))
+	_ = s.Write([]byte("ond\r\n"))
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	buf := make([]byte, 2048)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for len(lines) < 2 {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("read: %v (got %q)", err, lines)
+		}
+		lines = append(lines, string(buf[:n]))
+	}
+	for i, want := range []string{"first", "second"} {
+		if !strings.HasPrefix(lines[i], "<14>1 ") || !strings.HasSuffix(lines[i], " ABC123 - "+want) {
+			t.Errorf("message %d = %q, want an RFC 5424 message ending in %q", i, lines[i], want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+const (
+	// syslogPriority is facility user (1), severity informational (6)
+	syslogPriority = 1*8 + 6
+
+	// syslogMaxLine splits longer lines over several messages, keeping
+	// UDP datagrams well under what receivers accept
+	syslogMaxLine = 1024
+)
+
+// syslog sends each line of output as an RFC 5424 message, with the
+// session code as the MSGID. Lines are sent as output, escape sequences
+// included, without the trailing CR LF.
+type syslog struct {
+	network string // "udp" or "tcp"
+	addr    string
+	session string
+	host    string
+	conn    net.Conn
+	partial []byte // Output after the last newline, sent with the next line
+}
+
+func newSyslog(network, addr, session string) *syslog {
+	return &syslog{network: network, addr: addr, session: session, host: hostname()}
+}
+
+func (s *syslog) send(batch []byte, dropped int64) error {
+	var messages [][]byte
+	if dropped > 0 {
+		messages = append(messages, s.format([]byte(fmt.Sprintf("[%d bytes of output dropped]", dropped))))
+	}
+	data := append(s.partial, batch...)
+	for {
+		line, rest, found := bytes.Cut(data, []byte("\n"))
+		if !found {
+			break
+		}
+		data = rest
+		line = bytes.TrimRight(line, "\r")
+		for len(line) > syslogMaxLine {
+			messages = append(messages, s.format(line[:syslogMaxLine]))
+			line = line[syslogMaxLine:]
+		}
+		if len(line) > 0 {
+			messages = append(messages, s.format(line))
+		}
+	}
+	s.partial = append([]byte(nil), data...)
+	if len(s.partial) > syslogMaxLine {
+		messages = append(messages, s.format(s.partial))
+		s.partial = nil
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.network, s.addr, sendTimeout)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	_ = s.conn.SetWriteDeadline(time.Now().Add(sendTimeout))
+	for _, msg := range messages {
+		if s.network == "tcp" {
+			// Octet counting framing (RFC 6587)
+			msg = append([]byte(fmt.Sprintf("%d ", len(msg))), msg...)
+		}
+		if _, err := s.conn.Write(msg); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// format wraps msg in an RFC 5424 header
+func (s *syslog) format(msg []byte) []byte {
+	header := fmt.Sprintf("<%d>1 %s %s terminal-tunnel %d %s - ",
+		syslogPriority, time.Now().UTC().Format(time.RFC3339Nano), s.host, os.Getpid(), s.session)
+	return append([]byte(header), msg...)
+}
+
+func (s *syslog) close() error {
+	if len(s.partial) > 0 {
+		partial := s.partial
+		s.partial = nil
+		_ = s.send(append(partial, '\n'), 0)
+	}
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
@@ -0,0 +1,69 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// webhook POSTs each batch as a JSON object
+type webhook struct {
+	url      string
+	session  string
+	host     string
+	client   *http.Client
+	sequence int64
+}
+
+// webhookBatch is the body of each POST. Output is the raw terminal output,
+// escape sequences included; bytes that aren't valid UTF-8 become U+FFFD.
+type webhookBatch struct {
+	Session  string    `json:"session"`
+	Host     string    `json:"host"`
+	Sequence int64     `json:"sequence"` // Counts batches from 1, so gaps show lost ones
+	Time     time.Time `json:"time"`
+	Output   string    `json:"output"`
+	Dropped  int64     `json:"dropped,omitempty"` // Bytes of output lost since the previous batch
+}
+
+func newWebhook(url, session string) *webhook {
+	return &webhook{
+		url:     url,
+		session: session,
+		host:    hostname(),
+		client:  &http.Client{Timeout: sendTimeout},
+	}
+}
+
+func (w *webhook) send(batch []byte, dropped int64) error {
+	w.sequence++
+	body, err := json.Marshal(webhookBatch{
+		Session:  w.session,
+		Host:     w.host,
+		Sequence: w.sequence,
+		Time:     time.Now().UTC(),
+		Output:   string(batch),
+		Dropped:  dropped,
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (w *webhook) close() error {
+	w.client.CloseIdleConnections()
+	return nil
+}
@@ -3,7 +3,19 @@ package web
 
 import (
 	"embed"
+	"io/fs"
+	"net/http"
 )
 
 //go:embed static/*
 var StaticFS embed.FS
+
+// Handler serves the web client from StaticFS, with index.html at "/"
+func Handler() http.Handler {
+	static, err := fs.Sub(StaticFS, "static")
+	if err != nil {
+		// static/ is embedded at build time, so this cannot happen
+		panic(err)
+	}
+	return http.FileServer(http.FS(static))
+}
@@ -18,19 +18,46 @@ const (
 	PongTimeout = 30 * time.Second
 )
 
-// EncryptedChannel wraps a WebRTC DataChannel with encryption and protocol handling
+// framePool holds buffers for encoding and encrypting frames, so chatty
+// output doesn't allocate per frame
+var framePool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, 1024)
+		return &buf
+	},
+}
+
+// putFrame returns a buffer from framePool, keeping any growth
+func putFrame(bufp *[]byte, buf []byte) {
+	*bufp = buf[:0]
+	framePool.Put(bufp)
+}
+
+// EncryptedChannel wraps a WebRTC DataChannel, or another Transport, with
+// encryption and protocol handling. Framing, keys, keepalive and message
+// dispatch all live here; the Transport only moves opaque frames.
 type EncryptedChannel struct {
-	dc     *webrtc.DataChannel
+	t      Transport
 	key    *[32]byte
 	altKey *[32]byte // Alternate key (PBKDF2 fallback for CSP-restricted browsers)
 
-	onData   func([]byte)
-	onResize func(rows, cols uint16)
-	onClose  func()
+	onData     func([]byte)
+	onResize   func(rows, cols uint16)
+	onSizeSync func(rows, cols uint16) // Dimensions reported by client pings
+	onReset    func()                  // Client asked for a terminal reset
+	onHint     func(mode byte)         // Client asked for a bandwidth mode
+	onClose    func()
+
+	onCapabilities func(protocol.Capabilities) // Called with the negotiated set
 
 	mu        sync.Mutex
 	closed    bool
-	useAltKey bool // True if client is using altKey (PBKDF2)
+	useAltKey bool          // True if client is using altKey (PBKDF2)
+	keyKnown  chan struct{} // Closed once a frame from the client shows its key
+
+	// Capability negotiation: remote is nil until the peer sends its set
+	local  protocol.Capabilities
+	remote protocol.Capabilities
 
 	// Keepalive tracking
 	lastPongTime  time.Time
@@ -40,18 +67,35 @@ type EncryptedChannel struct {
 
 // NewEncryptedChannel creates an encrypted wrapper for a DataChannel
 func NewEncryptedChannel(dc *webrtc.DataChannel, key *[32]byte) *EncryptedChannel {
+	return NewTransportChannel(dataChannelTransport{dc}, key)
+}
+
+// NewTransportChannel creates an encrypted wrapper for any Transport, e.g.
+// the relay WebSocket a client falls back to when WebRTC can't connect
+func NewTransportChannel(t Transport, key *[32]byte) *EncryptedChannel {
 	ec := &EncryptedChannel{
-		dc:           dc,
+		t:            t,
 		key:          key,
 		lastPongTime: time.Now(), // Initialize to now, assume connection is fresh
+		local:        protocol.LocalCapabilities(),
+		keyKnown:     make(chan struct{}),
 	}
 
-	dc.OnMessage(func(msg webrtc.DataChannelMessage) {
-		ec.handleMessage(msg.Data)
-	})
+	ec.attach(t)
+	return ec
+}
+
+// attach routes t's frames and close to the channel. A transport replaced
+// by SwitchTransport no longer closes the channel.
+func (ec *EncryptedChannel) attach(t Transport) {
+	t.OnMessage(ec.handleMessage)
 
-	dc.OnClose(func() {
+	t.OnClose(func() {
 		ec.mu.Lock()
+		if ec.t != t {
+			ec.mu.Unlock()
+			return
+		}
 		ec.closed = true
 		handler := ec.onClose
 		ec.mu.Unlock()
@@ -60,8 +104,32 @@ func NewEncryptedChannel(dc *webrtc.DataChannel, key *[32]byte) *EncryptedChanne
 			handler()
 		}
 	})
+}
+
+// transportDrain is how long a replaced transport stays open, so frames
+// already on their way over it still arrive
+const transportDrain = 2 * time.Second
+
+// SwitchTransport moves the channel onto t, e.g. from the relay WebSocket
+// to a data channel that has since connected. Keys, handlers and keepalive
+// carry over, so the session continues without a reconnect. Frames are sent
+// on t from now on; the old transport is closed after transportDrain.
+func (ec *EncryptedChannel) SwitchTransport(t Transport) {
+	ec.mu.Lock()
+	old := ec.t
+	ec.t = t
+	ec.mu.Unlock()
+	ec.attach(t)
+	time.AfterFunc(transportDrain, func() {
+		_ = old.Close()
+	})
+}
 
-	return ec
+// transport returns the transport frames are sent on
+func (ec *EncryptedChannel) transport() Transport {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	return ec.t
 }
 
 // SetAltKey sets an alternate key for fallback decryption (PBKDF2 for CSP-restricted browsers)
@@ -71,20 +139,22 @@ func (ec *EncryptedChannel) SetAltKey(altKey *[32]byte) {
 	ec.altKey = altKey
 }
 
-// handleMessage decrypts and processes incoming messages
-func (ec *EncryptedChannel) handleMessage(data []byte) {
+// decodeFrame decrypts and parses a frame from the wire. The message is
+// returned by value so it stays off the heap.
+func (ec *EncryptedChannel) decodeFrame(data []byte) (protocol.Message, error) {
+	// DecodeMessage copies the payload, so the plaintext buffer can be reused
+	bufp := framePool.Get().(*[]byte)
+
 	// Try primary key first (Argon2)
-	plaintext, err := crypto.Decrypt(data, ec.key)
-	usedAltKey := false
+	plaintext, err := crypto.DecryptAppend((*bufp)[:0], data, ec.key)
 	if err != nil {
 		// Try alternate key (PBKDF2 fallback)
 		ec.mu.Lock()
 		altKey := ec.altKey
 		ec.mu.Unlock()
 		if altKey != nil {
-			plaintext, err = crypto.Decrypt(data, altKey)
+			plaintext, err = crypto.DecryptAppend((*bufp)[:0], data, altKey)
 			if err == nil {
-				usedAltKey = true
 				// Client is using PBKDF2, remember this for responses
 				ec.mu.Lock()
 				ec.useAltKey = true
@@ -93,13 +163,78 @@ func (ec *EncryptedChannel) handleMessage(data []byte) {
 		}
 		if err != nil {
 			// Both keys failed - likely wrong password or corrupted data
-			return
+			framePool.Put(bufp)
+			return protocol.Message{}, err
 		}
 	}
-	_ = usedAltKey // Used for logging if needed
 
-	// Parse the protocol message
 	msg, err := protocol.DecodeMessage(plaintext)
+	putFrame(bufp, plaintext)
+	if err != nil {
+		return protocol.Message{}, err
+	}
+	if msg.Type == protocol.MsgPing {
+		if key, ok := protocol.ParseKeyPingPayload(msg.Payload); ok {
+			ec.mu.Lock()
+			ec.useAltKey = key == protocol.KeyFallback && ec.altKey != nil
+			ec.mu.Unlock()
+		}
+	}
+	ec.markKeyKnown()
+	return *msg, nil
+}
+
+// markKeyKnown notes that the client's key is known, releasing WaitForKey
+func (ec *EncryptedChannel) markKeyKnown() {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	select {
+	case <-ec.keyKnown:
+	default:
+		close(ec.keyKnown)
+	}
+}
+
+// WaitForKey waits up to timeout for the client's first message, which
+// shows the key it encrypts with: the key ping clients send as soon as the
+// channel opens (protocol.NewKeyPingMessage), or for older clients any
+// frame, by the key that decrypts it. Replies sent before then use the
+// primary key, which a client on the alternate key can't read. Returns
+// false on timeout; without an alternate key there is nothing to wait for.
+func (ec *EncryptedChannel) WaitForKey(timeout time.Duration) bool {
+	ec.mu.Lock()
+	altKey := ec.altKey
+	ec.mu.Unlock()
+	if altKey == nil {
+		return true
+	}
+	select {
+	case <-ec.keyKnown:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// encodeFrame encodes msg and encrypts it with key into a buffer from
+// framePool. The caller hands it back with putFrame once it is sent.
+func encodeFrame(msg *protocol.Message, key *[32]byte) (bufp *[]byte, frame []byte, err error) {
+	encp := framePool.Get().(*[]byte)
+	encoded := msg.AppendEncode((*encp)[:0])
+
+	bufp = framePool.Get().(*[]byte)
+	frame, err = crypto.EncryptAppend((*bufp)[:0], encoded, key)
+	putFrame(encp, encoded)
+	if err != nil {
+		framePool.Put(bufp)
+		return nil, nil, err
+	}
+	return bufp, frame, nil
+}
+
+// handleMessage decrypts and processes incoming messages
+func (ec *EncryptedChannel) handleMessage(data []byte) {
+	msg, err := ec.decodeFrame(data)
 	if err != nil {
 		return
 	}
@@ -108,6 +243,10 @@ func (ec *EncryptedChannel) handleMessage(data []byte) {
 	ec.mu.Lock()
 	onDataHandler := ec.onData
 	onResizeHandler := ec.onResize
+	onSizeSyncHandler := ec.onSizeSync
+	onResetHandler := ec.onReset
+	onHintHandler := ec.onHint
+	onCapabilitiesHandler := ec.onCapabilities
 	ec.mu.Unlock()
 
 	switch msg.Type {
@@ -125,13 +264,44 @@ func (ec *EncryptedChannel) handleMessage(data []byte) {
 	case protocol.MsgPing:
 		// Respond with pong (ignore error - best effort response)
 		_ = ec.sendMessage(protocol.NewPongMessage())
+		// Pings may carry the client's current dimensions for drift correction
+		if onSizeSyncHandler != nil {
+			if size, ok := protocol.ParsePingPayload(msg.Payload); ok {
+				onSizeSyncHandler(size.Rows, size.Cols)
+			}
+		}
 	case protocol.MsgPong:
 		// Update last pong time for keepalive tracking
 		ec.mu.Lock()
 		ec.lastPongTime = time.Now()
 		ec.mu.Unlock()
+	case protocol.MsgReset:
+		if onResetHandler != nil {
+			onResetHandler()
+		}
+	case protocol.MsgBandwidthHint:
+		if onHintHandler != nil {
+			if mode, err := protocol.ParseBandwidthHintPayload(msg.Payload); err == nil {
+				onHintHandler(mode)
+			}
+		}
 	case protocol.MsgClose:
 		_ = ec.Close() // Ignore error on remote-initiated close
+	case protocol.MsgCapabilities:
+		caps, err := protocol.ParseCapabilitiesPayload(msg.Payload)
+		if err != nil {
+			return
+		}
+		ec.mu.Lock()
+		ec.remote = caps.Features
+		local := ec.local
+		ec.mu.Unlock()
+		if !caps.Reply {
+			_ = ec.sendMessage(protocol.NewCapabilitiesMessage(local, true))
+		}
+		if onCapabilitiesHandler != nil {
+			onCapabilitiesHandler(local.Intersect(caps.Features))
+		}
 	}
 }
 
@@ -146,20 +316,20 @@ func (ec *EncryptedChannel) sendMessage(msg *protocol.Message) error {
 	altKey := ec.altKey
 	ec.mu.Unlock()
 
-	encoded := msg.Encode()
-
 	// Use the same key the client is using
 	key := ec.key
 	if useAlt && altKey != nil {
 		key = altKey
 	}
 
-	encrypted, err := crypto.Encrypt(encoded, key)
+	bufp, frame, err := encodeFrame(msg, key)
 	if err != nil {
 		return err
 	}
-
-	if err := ec.dc.Send(encrypted); err != nil {
+	// SCTP copies the data it queues, so the buffer is free once Send returns
+	err = ec.transport().Send(frame)
+	putFrame(bufp, frame)
+	if err != nil {
 		// Debug: DC send error
 		return err
 	}
@@ -176,11 +346,97 @@ func (ec *EncryptedChannel) SendResize(rows, cols uint16) error {
 	return ec.sendMessage(protocol.NewResizeMessage(rows, cols))
 }
 
+// SendZmodemStart notifies the client that a zmodem transfer is starting
+func (ec *EncryptedChannel) SendZmodemStart(direction byte) error {
+	return ec.sendMessage(protocol.NewZmodemMessage(direction))
+}
+
+// SendBell tells the client the shell rang the terminal bell
+func (ec *EncryptedChannel) SendBell() error {
+	return ec.sendMessage(protocol.NewBellMessage())
+}
+
+// SendNotice sends the client a message to show apart from the terminal
+func (ec *EncryptedChannel) SendNotice(text string) error {
+	return ec.sendMessage(protocol.NewNoticeMessage(text))
+}
+
+// SendWriteAccess tells a viewer whether it may type into the session
+func (ec *EncryptedChannel) SendWriteAccess(granted bool) error {
+	return ec.sendMessage(protocol.NewWriteAccessMessage(granted))
+}
+
+// SendBandwidthHint asks the host for a bandwidth mode
+func (ec *EncryptedChannel) SendBandwidthHint(mode byte) error {
+	return ec.sendMessage(protocol.NewBandwidthHintMessage(mode))
+}
+
+// SendSizeRequest asks the client to report its terminal size
+func (ec *EncryptedChannel) SendSizeRequest() error {
+	return ec.sendMessage(protocol.NewSizeRequestMessage())
+}
+
+// SendViewerInfo tells a joining viewer the session's size and whether it may record
+func (ec *EncryptedChannel) SendViewerInfo(flags byte, rows, cols uint16) error {
+	return ec.sendMessage(protocol.NewViewerInfoMessage(flags, rows, cols))
+}
+
+// SendCapabilities sends the features this side supports. The peer answers
+// with its own, after which Capabilities reports the negotiated set. Peers
+// that predate negotiation ignore it.
+func (ec *EncryptedChannel) SendCapabilities() error {
+	ec.mu.Lock()
+	local := ec.local
+	ec.mu.Unlock()
+	return ec.sendMessage(protocol.NewCapabilitiesMessage(local, false))
+}
+
+// SetLocalCapabilities replaces the features this side offers (by default
+// protocol.LocalCapabilities). Call before the exchange.
+func (ec *EncryptedChannel) SetLocalCapabilities(caps protocol.Capabilities) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	ec.local = caps
+}
+
+// Capabilities returns the features both sides support. ok is false until
+// the peer has sent its capabilities, which an older peer never does.
+func (ec *EncryptedChannel) Capabilities() (caps protocol.Capabilities, ok bool) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	if ec.remote == nil {
+		return nil, false
+	}
+	return ec.local.Intersect(ec.remote), true
+}
+
+// OnCapabilities sets the handler called with the negotiated features when
+// the peer's capabilities arrive
+func (ec *EncryptedChannel) OnCapabilities(handler func(protocol.Capabilities)) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	ec.onCapabilities = handler
+}
+
 // SendClose sends a graceful close message
 func (ec *EncryptedChannel) SendClose() error {
 	return ec.sendMessage(protocol.NewCloseMessage())
 }
 
+// SendCloseWithReason sends a graceful close message with a reason for the client to display
+func (ec *EncryptedChannel) SendCloseWithReason(reason string) error {
+	return ec.sendMessage(protocol.NewCloseMessageWithReason(reason))
+}
+
+// SendCloseWithCode sends a graceful close message with a code and reason.
+// Peers that negotiated close-reason v1, or nothing, get the reason alone.
+func (ec *EncryptedChannel) SendCloseWithCode(code protocol.CloseCode, reason string) error {
+	if caps, ok := ec.Capabilities(); ok && caps[protocol.FeatureCloseReason] >= 2 {
+		return ec.sendMessage(protocol.NewCloseMessageWithCode(code, reason))
+	}
+	return ec.sendMessage(protocol.NewCloseMessageWithReason(reason))
+}
+
 // OnData sets the handler for terminal data
 func (ec *EncryptedChannel) OnData(handler func([]byte)) {
 	ec.mu.Lock()
@@ -195,6 +451,27 @@ func (ec *EncryptedChannel) OnResize(handler func(rows, cols uint16)) {
 	ec.onResize = handler
 }
 
+// OnSizeSync sets the handler for dimensions reported in keepalive pings
+func (ec *EncryptedChannel) OnSizeSync(handler func(rows, cols uint16)) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	ec.onSizeSync = handler
+}
+
+// OnReset sets the handler for terminal reset requests
+func (ec *EncryptedChannel) OnReset(handler func()) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	ec.onReset = handler
+}
+
+// OnBandwidthHint sets the handler for bandwidth mode requests
+func (ec *EncryptedChannel) OnBandwidthHint(handler func(mode byte)) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	ec.onHint = handler
+}
+
 // OnClose sets the handler for close events
 func (ec *EncryptedChannel) OnClose(handler func()) {
 	ec.mu.Lock()
@@ -217,17 +494,17 @@ func (ec *EncryptedChannel) Close() error {
 	ec.closed = true
 	ec.mu.Unlock()
 
-	return ec.dc.Close()
+	return ec.transport().Close()
 }
 
 // Ready returns true if the data channel is open
 func (ec *EncryptedChannel) Ready() bool {
-	return ec.dc.ReadyState() == webrtc.DataChannelStateOpen
+	return ec.transport().IsOpen()
 }
 
-// Label returns the data channel label
+// Label returns the data channel label (WebSocketLabel over the relay)
 func (ec *EncryptedChannel) Label() string {
-	return ec.dc.Label()
+	return ec.transport().Label()
 }
 
 // UseAltKey returns whether the channel is using the alternate (PBKDF2) key
@@ -316,3 +593,15 @@ func (ec *EncryptedChannel) StopKeepalive() {
 func (ec *EncryptedChannel) SendPing() error {
 	return ec.sendMessage(protocol.NewPingMessage())
 }
+
+// SendKeyPing sends the first ping of a connection, naming the key this
+// side encrypts with (protocol.KeySession or protocol.KeyFallback)
+func (ec *EncryptedChannel) SendKeyPing(key byte) error {
+	return ec.sendMessage(protocol.NewKeyPingMessage(key))
+}
+
+// SendPingWithSize sends a ping carrying the local terminal dimensions
+// (used by client-side keepalive so the host can correct size drift)
+func (ec *EncryptedChannel) SendPingWithSize(rows, cols uint16) error {
+	return ec.sendMessage(protocol.NewPingMessageWithSize(rows, cols))
+}
@@ -0,0 +1,207 @@
+package webrtc
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/artpar/terminal-tunnel/internal/protocol"
+)
+
+func TestCapabilitiesNegotiation(t *testing.T) {
+	pair, err := NewTestPeerPair("testpassword")
+	if err != nil {
+		t.Fatalf("NewTestPeerPair failed: %v", err)
+	}
+	defer pair.Close()
+
+	pair.HostChannel.SetLocalCapabilities(protocol.Capabilities{
+		protocol.FeatureZmodem: 2,
+		"host-only":            1,
+	})
+	pair.ClientChannel.SetLocalCapabilities(protocol.Capabilities{
+		protocol.FeatureZmodem:   1,
+		protocol.FeaturePingSize: 1,
+	})
+
+	if _, ok := pair.ClientChannel.Capabilities(); ok {
+		t.Error("capabilities reported before the exchange")
+	}
+
+	negotiated := make(chan protocol.Capabilities, 1)
+	pair.ClientChannel.OnCapabilities(func(caps protocol.Capabilities) {
+		negotiated <- caps
+	})
+	if err := pair.ClientChannel.SendCapabilities(); err != nil {
+		t.Fatalf("SendCapabilities failed: %v", err)
+	}
+
+	// The host answers with its own set
+	select {
+	case caps := <-negotiated:
+		if len(caps) != 1 || caps[protocol.FeatureZmodem] != 1 {
+			t.Errorf("client negotiated %v, want zmodem:1", caps)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the host's capabilities")
+	}
+
+	caps, ok := pair.HostChannel.Capabilities()
+	if !ok {
+		t.Fatal("host has no capabilities after the exchange")
+	}
+	if len(caps) != 1 || caps[protocol.FeatureZmodem] != 1 {
+		t.Errorf("host negotiated %v, want zmodem:1", caps)
+	}
+}
+
+// A client on the PBKDF2 fallback key is recognised from its key ping, sent
+// later than the host's old fixed wait, before the host replies
+func TestKeyPingSelectsAltKey(t *testing.T) {
+	for _, delay := range []time.Duration{0, 300 * time.Millisecond} {
+		pair, err := NewTestPeerPair("testpassword")
+		if err != nil {
+			t.Fatalf("NewTestPeerPair failed: %v", err)
+		}
+
+		var altKey [32]byte
+		altKey[0] = 7
+		pair.HostChannel.SetAltKey(&altKey)
+		pair.ClientChannel.key = &altKey
+
+		received := make(chan string, 1)
+		pair.ClientChannel.OnData(func(data []byte) {
+			received <- string(data)
+		})
+
+		go func() {
+			time.Sleep(delay)
+			_ = pair.ClientChannel.SendKeyPing(protocol.KeyFallback)
+		}()
+		if !pair.HostChannel.WaitForKey(5 * time.Second) {
+			t.Fatalf("delay %v: WaitForKey timed out", delay)
+		}
+		if !pair.HostChannel.UseAltKey() {
+			t.Fatalf("delay %v: host did not select the alternate key", delay)
+		}
+
+		// The first reply is readable by the client
+		if err := pair.HostChannel.SendData([]byte("hello")); err != nil {
+			t.Fatalf("SendData failed: %v", err)
+		}
+		select {
+		case data := <-received:
+			if data != "hello" {
+				t.Errorf("delay %v: client got %q, want \"hello\"", delay, data)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("delay %v: client could not read the host's reply", delay)
+		}
+		pair.Close()
+	}
+}
+
+func TestWaitForKeyWithoutAltKey(t *testing.T) {
+	ec := &EncryptedChannel{keyKnown: make(chan struct{})}
+	if !ec.WaitForKey(time.Hour) {
+		t.Error("WaitForKey without an alternate key should not wait")
+	}
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	var key, altKey [32]byte
+	key[0], altKey[0] = 1, 2
+	ec := &EncryptedChannel{key: &key, altKey: &altKey, keyKnown: make(chan struct{})}
+
+	for _, k := range []*[32]byte{&key, &altKey} {
+		bufp, frame, err := encodeFrame(protocol.NewDataMessage([]byte("hello")), k)
+		if err != nil {
+			t.Fatalf("encodeFrame failed: %v", err)
+		}
+		msg, err := ec.decodeFrame(frame)
+		putFrame(bufp, frame)
+		if err != nil {
+			t.Fatalf("decodeFrame failed: %v", err)
+		}
+		if msg.Type != protocol.MsgData || string(msg.Payload) != "hello" {
+			t.Errorf("decoded %v %q, want data \"hello\"", msg.Type, msg.Payload)
+		}
+	}
+	if !ec.UseAltKey() {
+		t.Error("a frame under the alternate key should switch replies to it")
+	}
+
+	var wrong [32]byte
+	bufp, frame, _ := encodeFrame(protocol.NewDataMessage([]byte("x")), &wrong)
+	if _, err := ec.decodeFrame(frame); err == nil {
+		t.Error("decodeFrame accepted a frame under the wrong key")
+	}
+	putFrame(bufp, frame)
+}
+
+// Typical interactive frames: a keystroke up to a short burst of output.
+// Before frames were pooled, on a 1 vCPU amd64 host:
+//
+//	BenchmarkEncryptedChannelEncrypt/1      379 ns/op    72 B/op  2 allocs/op
+//	BenchmarkEncryptedChannelEncrypt/16     371 ns/op    88 B/op  2 allocs/op
+//	BenchmarkEncryptedChannelEncrypt/64     572 ns/op   216 B/op  3 allocs/op
+//	BenchmarkEncryptedChannelEncrypt/256   1055 ns/op   632 B/op  3 allocs/op
+//	BenchmarkEncryptedChannelDecrypt/1      302 ns/op     4 B/op  1 allocs/op
+//	BenchmarkEncryptedChannelDecrypt/16     305 ns/op    24 B/op  1 allocs/op
+//	BenchmarkEncryptedChannelDecrypt/64     486 ns/op   144 B/op  2 allocs/op
+//	BenchmarkEncryptedChannelDecrypt/256    940 ns/op   544 B/op  2 allocs/op
+//
+// and after:
+//
+//	BenchmarkEncryptedChannelEncrypt/1      400 ns/op     0 B/op  0 allocs/op
+//	BenchmarkEncryptedChannelEncrypt/16     401 ns/op     0 B/op  0 allocs/op
+//	BenchmarkEncryptedChannelEncrypt/64     551 ns/op     0 B/op  0 allocs/op
+//	BenchmarkEncryptedChannelEncrypt/256    953 ns/op     0 B/op  0 allocs/op
+//	BenchmarkEncryptedChannelDecrypt/1      283 ns/op     1 B/op  1 allocs/op
+//	BenchmarkEncryptedChannelDecrypt/16     324 ns/op    16 B/op  1 allocs/op
+//	BenchmarkEncryptedChannelDecrypt/64     489 ns/op    64 B/op  1 allocs/op
+//	BenchmarkEncryptedChannelDecrypt/256    912 ns/op   256 B/op  1 allocs/op
+//
+// Sealing itself dominates the time; what's left to allocate is the
+// payload handed to OnData, which handlers may keep.
+var frameSizes = []int{1, 16, 64, 256}
+
+func BenchmarkEncryptedChannelEncrypt(b *testing.B) {
+	var key [32]byte
+	for _, size := range frameSizes {
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			payload := make([]byte, size)
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				bufp, frame, err := encodeFrame(protocol.NewDataMessage(payload), &key)
+				if err != nil {
+					b.Fatal(err)
+				}
+				putFrame(bufp, frame)
+			}
+		})
+	}
+}
+
+func BenchmarkEncryptedChannelDecrypt(b *testing.B) {
+	var key [32]byte
+	ec := &EncryptedChannel{key: &key, keyKnown: make(chan struct{})}
+	for _, size := range frameSizes {
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			bufp, sealed, err := encodeFrame(protocol.NewDataMessage(make([]byte, size)), &key)
+			if err != nil {
+				b.Fatal(err)
+			}
+			frame := append([]byte(nil), sealed...)
+			putFrame(bufp, sealed)
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				if _, err := ec.decodeFrame(frame); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package webrtc
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ICE environment variables. Set either server list to replace the STUN/TURN
+// servers the relay would otherwise provide.
+const (
+	// EnvSTUNServers is a comma-separated list of STUN URLs
+	// (e.g. "stun:stun.example.com:3478")
+	EnvSTUNServers = "TT_STUN_SERVERS"
+
+	// EnvTURNServers is a comma-separated list of TURN URLs. Credentials may
+	// be embedded as "turn:user:pass@host:port"; otherwise TURN_USERNAME and
+	// TURN_PASSWORD are used.
+	EnvTURNServers = "TT_TURN_SERVERS"
+
+	// EnvNoTURN disables TURN when set to a true value ("1", "true")
+	EnvNoTURN = "TT_NO_TURN"
+)
+
+// STUNServersFromEnv returns the STUN servers from TT_STUN_SERVERS, or nil if unset
+func STUNServersFromEnv() []string {
+	return splitList(os.Getenv(EnvSTUNServers))
+}
+
+// TURNServersFromEnv returns the TURN servers from TT_TURN_SERVERS, falling
+// back to the single server in TURN_URL. Returns nil if neither is set.
+func TURNServersFromEnv() []TURNConfig {
+	urls := splitList(os.Getenv(EnvTURNServers))
+	if len(urls) == 0 {
+		if turn := GetTURNFromEnv(); turn != nil {
+			return []TURNConfig{*turn}
+		}
+		return nil
+	}
+
+	defaultUser := os.Getenv(EnvTURNUsername)
+	defaultPass := os.Getenv(EnvTURNPassword)
+
+	servers := make([]TURNConfig, 0, len(urls))
+	for _, u := range urls {
+		url, user, pass := splitTURNCredentials(u)
+		if user == "" && pass == "" {
+			user, pass = defaultUser, defaultPass
+		}
+		servers = append(servers, TURNConfig{
+			URLs:       []string{url},
+			Username:   user,
+			Credential: pass,
+		})
+	}
+	return servers
+}
+
+// NoTURNFromEnv reports whether TT_NO_TURN disables TURN
+func NoTURNFromEnv() bool {
+	noTURN, _ := strconv.ParseBool(os.Getenv(EnvNoTURN))
+	return noTURN
+}
+
+// ConfigFromEnv returns a configuration built from TT_STUN_SERVERS and
+// TT_TURN_SERVERS. Returns false if neither is set, in which case the caller
+// should use relay-provided or default servers.
+func ConfigFromEnv() (Config, bool) {
+	if os.Getenv(EnvSTUNServers) == "" && os.Getenv(EnvTURNServers) == "" {
+		return Config{}, false
+	}
+	return Config{UseTURN: true}, true
+}
+
+// splitTURNCredentials splits "turn:user:pass@host:port" into the URL
+// "turn:host:port" and its credentials
+func splitTURNCredentials(u string) (url, user, pass string) {
+	scheme, rest, ok := strings.Cut(u, ":")
+	if !ok {
+		return u, "", ""
+	}
+	at := strings.LastIndex(rest, "@")
+	if at < 0 {
+		return u, "", ""
+	}
+	user, pass, _ = strings.Cut(rest[:at], ":")
+	return scheme + ":" + rest[at+1:], user, pass
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
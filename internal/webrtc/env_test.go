@@ -0,0 +1,59 @@
+package webrtc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTURNServersFromEnv(t *testing.T) {
+	t.Setenv(EnvTURNServers, "turn:alice:s3cret@turn1.example.com:3478, turns:turn2.example.com:5349?transport=tcp")
+	t.Setenv(EnvTURNUsername, "bob")
+	t.Setenv(EnvTURNPassword, "hunter2")
+
+	want := []TURNConfig{
+		{URLs: []string{"turn:turn1.example.com:3478"}, Username: "alice", Credential: "s3cret"},
+		{URLs: []string{"turns:turn2.example.com:5349?transport=tcp"}, Username: "bob", Credential: "hunter2"},
+	}
+	if got := TURNServersFromEnv(); !reflect.DeepEqual(got, want) {
+		t.Errorf("TURNServersFromEnv() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTURNServersFromEnvLegacy(t *testing.T) {
+	t.Setenv(EnvTURNServers, "")
+	t.Setenv(EnvTURNURL, "turn:legacy.example.com:3478")
+	t.Setenv(EnvTURNUsername, "user")
+	t.Setenv(EnvTURNPassword, "pass")
+
+	got := TURNServersFromEnv()
+	if len(got) != 1 || got[0].URLs[0] != "turn:legacy.example.com:3478" || got[0].Username != "user" {
+		t.Errorf("expected TURN_URL fallback, got %+v", got)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv(EnvSTUNServers, "")
+	t.Setenv(EnvTURNServers, "")
+	if _, ok := ConfigFromEnv(); ok {
+		t.Error("ConfigFromEnv should report false with no servers set")
+	}
+
+	t.Setenv(EnvSTUNServers, "stun:stun.example.com:3478")
+	config, ok := ConfigFromEnv()
+	if !ok {
+		t.Fatal("ConfigFromEnv should report true with TT_STUN_SERVERS set")
+	}
+	servers := buildICEServers(config)
+	if len(servers) == 0 || !reflect.DeepEqual(servers[0].URLs, []string{"stun:stun.example.com:3478"}) {
+		t.Errorf("STUN servers = %+v, want env value", servers)
+	}
+}
+
+func TestNoTURNFromEnv(t *testing.T) {
+	for value, want := range map[string]bool{"": false, "1": true, "true": true, "0": false, "bogus": false} {
+		t.Setenv(EnvNoTURN, value)
+		if got := NoTURNFromEnv(); got != want {
+			t.Errorf("NoTURNFromEnv() with %q = %v, want %v", value, got, want)
+		}
+	}
+}
@@ -2,9 +2,13 @@
 package webrtc
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"fmt"
 	"net"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -32,8 +36,8 @@ var defaultSTUNServers = []string{
 
 // Note: TURN servers can be configured via:
 // 1. Relay server (centralized): set TURN_URL on the relay worker
-// 2. Environment variables: TURN_URL, TURN_USERNAME, TURN_PASSWORD
-// 3. The --no-turn flag disables TURN entirely
+// 2. Environment variables: TT_TURN_SERVERS, or TURN_URL, TURN_USERNAME, TURN_PASSWORD
+// 3. The --no-turn flag (or TT_NO_TURN) disables TURN entirely
 
 // RelayICEConfig represents ICE server config from the relay
 type RelayICEConfig struct {
@@ -54,6 +58,24 @@ type Config struct {
 	ICEServers  []webrtc.ICEServer
 	TURNServers []TURNConfig // Additional TURN servers
 	UseTURN     bool         // Enable TURN for symmetric NAT
+
+	// Certificate is the DTLS certificate to use. Sharing one across peers
+	// keeps the fingerprint stable for the session so it can be pinned.
+	// If nil, each peer generates its own.
+	Certificate *webrtc.Certificate
+}
+
+// GenerateCertificate creates a DTLS certificate (ECDSA P-256) for Config.Certificate
+func GenerateCertificate() (*webrtc.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	cert, err := webrtc.GenerateCertificate(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate certificate: %w", err)
+	}
+	return cert, nil
 }
 
 // DefaultConfig returns the default configuration
@@ -127,8 +149,12 @@ func buildICEServers(config Config) []webrtc.ICEServer {
 	servers := []webrtc.ICEServer{}
 
 	// Add STUN servers
+	stunServers := STUNServersFromEnv()
+	if len(stunServers) == 0 {
+		stunServers = defaultSTUNServers
+	}
 	servers = append(servers, webrtc.ICEServer{
-		URLs: defaultSTUNServers,
+		URLs: stunServers,
 	})
 
 	// Add TURN servers for NAT traversal (if configured)
@@ -136,8 +162,8 @@ func buildICEServers(config Config) []webrtc.ICEServer {
 		var turnConfigs []TURNConfig
 
 		// Check environment variables first
-		if envTURN := GetTURNFromEnv(); envTURN != nil {
-			turnConfigs = []TURNConfig{*envTURN}
+		if envTURN := TURNServersFromEnv(); len(envTURN) > 0 {
+			turnConfigs = envTURN
 		} else if len(config.TURNServers) > 0 {
 			// Use custom TURN servers from config
 			turnConfigs = config.TURNServers
@@ -157,6 +183,20 @@ func buildICEServers(config Config) []webrtc.ICEServer {
 	return servers
 }
 
+// ICEServerURLs lists the STUN and TURN server URLs a peer created from
+// config uses, leaving out credentials
+func ICEServerURLs(config Config) []string {
+	servers := config.ICEServers
+	if len(servers) == 0 {
+		servers = buildICEServers(config)
+	}
+	var urls []string
+	for _, srv := range servers {
+		urls = append(urls, srv.URLs...)
+	}
+	return urls
+}
+
 // Peer wraps a WebRTC peer connection with helpers for terminal tunneling
 type Peer struct {
 	pc          *webrtc.PeerConnection
@@ -180,6 +220,9 @@ func NewPeer(config Config) (*Peer, error) {
 	peerConfig := webrtc.Configuration{
 		ICEServers: iceServers,
 	}
+	if config.Certificate != nil {
+		peerConfig.Certificates = []webrtc.Certificate{*config.Certificate}
+	}
 
 	var pc *webrtc.PeerConnection
 	var err error
@@ -392,6 +435,85 @@ func (p *Peer) Close() error {
 	return p.pc.Close()
 }
 
+// LocalFingerprint returns the SHA-256 fingerprint of the peer's DTLS
+// certificate in SDP form ("sha-256 AB:CD:..."). Clients can compare it
+// with the fingerprint in the offer they received to detect a relay that
+// substituted its own SDP.
+func (p *Peer) LocalFingerprint() (string, error) {
+	certs := p.pc.GetConfiguration().Certificates
+	if len(certs) == 0 {
+		return "", fmt.Errorf("no DTLS certificate")
+	}
+	return CertificateFingerprint(&certs[0])
+}
+
+// CertificateFingerprint returns the SHA-256 fingerprint of a DTLS
+// certificate in the form used by LocalFingerprint
+func CertificateFingerprint(cert *webrtc.Certificate) (string, error) {
+	fingerprints, err := cert.GetFingerprints()
+	if err != nil {
+		return "", fmt.Errorf("failed to get fingerprint: %w", err)
+	}
+	for _, fp := range fingerprints {
+		if fp.Algorithm == "sha-256" {
+			return fp.Algorithm + " " + strings.ToUpper(fp.Value), nil
+		}
+	}
+	return "", fmt.Errorf("no sha-256 fingerprint")
+}
+
+// ConnectionType reports how the peer is connected: "relay" when either
+// side of the selected ICE candidate pair is a TURN relay, "p2p" for a
+// direct path, or "" if no candidate pair has been selected yet
+func (p *Peer) ConnectionType() string {
+	sctp := p.pc.SCTP()
+	if sctp == nil || sctp.Transport() == nil {
+		return ""
+	}
+	pair, err := sctp.Transport().ICETransport().GetSelectedCandidatePair()
+	if err != nil || pair == nil || pair.Local == nil || pair.Remote == nil {
+		return ""
+	}
+	if pair.Local.Typ == webrtc.ICECandidateTypeRelay || pair.Remote.Typ == webrtc.ICECandidateTypeRelay {
+		return "relay"
+	}
+	return "p2p"
+}
+
+// RemoteCandidate returns the address and type ("host", "srflx", "prflx"
+// or "relay") of the remote side of the selected ICE candidate pair, or
+// empty strings if none has been selected yet. For a "relay" candidate the
+// address is the remote peer's TURN server, not the peer itself.
+func (p *Peer) RemoteCandidate() (address, candidateType string) {
+	sctp := p.pc.SCTP()
+	if sctp == nil || sctp.Transport() == nil {
+		return "", ""
+	}
+	pair, err := sctp.Transport().ICETransport().GetSelectedCandidatePair()
+	if err != nil || pair == nil || pair.Remote == nil {
+		return "", ""
+	}
+	return pair.Remote.Address, pair.Remote.Typ.String()
+}
+
+// SelectedCandidatePair describes the ICE candidate pair in use, e.g.
+// "host udp 192.168.1.5:51234 -> srflx udp 203.0.113.7:40000", or "" if
+// none has been selected yet
+func (p *Peer) SelectedCandidatePair() string {
+	sctp := p.pc.SCTP()
+	if sctp == nil || sctp.Transport() == nil {
+		return ""
+	}
+	pair, err := sctp.Transport().ICETransport().GetSelectedCandidatePair()
+	if err != nil || pair == nil || pair.Local == nil || pair.Remote == nil {
+		return ""
+	}
+	describe := func(c *webrtc.ICECandidate) string {
+		return fmt.Sprintf("%s %s %s", c.Typ, c.Protocol, net.JoinHostPort(c.Address, strconv.Itoa(int(c.Port))))
+	}
+	return describe(pair.Local) + " -> " + describe(pair.Remote)
+}
+
 // ConnectionState returns the current connection state
 func (p *Peer) ConnectionState() webrtc.PeerConnectionState {
 	return p.pc.ConnectionState()
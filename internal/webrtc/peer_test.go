@@ -76,6 +76,48 @@ func TestCreateOffer(t *testing.T) {
 	}
 }
 
+func TestLocalFingerprint(t *testing.T) {
+	cert, err := GenerateCertificate()
+	if err != nil {
+		t.Fatalf("GenerateCertificate failed: %v", err)
+	}
+	want, err := CertificateFingerprint(cert)
+	if err != nil {
+		t.Fatalf("CertificateFingerprint failed: %v", err)
+	}
+
+	config := ConfigWithoutTURN()
+	config.Certificate = cert
+
+	// Peers sharing a certificate present the same fingerprint
+	for i := 0; i < 2; i++ {
+		peer, err := NewPeer(config)
+		if err != nil {
+			t.Fatalf("NewPeer failed: %v", err)
+		}
+		defer peer.Close()
+
+		got, err := peer.LocalFingerprint()
+		if err != nil {
+			t.Fatalf("LocalFingerprint failed: %v", err)
+		}
+		if got != want {
+			t.Errorf("LocalFingerprint() = %q, want %q", got, want)
+		}
+
+		if _, err := peer.CreateDataChannel("terminal"); err != nil {
+			t.Fatalf("CreateDataChannel failed: %v", err)
+		}
+		sdp, err := peer.CreateOffer()
+		if err != nil {
+			t.Fatalf("CreateOffer failed: %v", err)
+		}
+		if !strings.Contains(strings.ToUpper(sdp), strings.ToUpper("a=fingerprint:"+want)) {
+			t.Errorf("offer does not contain fingerprint %q", want)
+		}
+	}
+}
+
 func TestPeerConnection(t *testing.T) {
 	// Create host peer
 	hostPeer, err := NewPeer(DefaultConfig())
@@ -171,3 +213,56 @@ func TestConnectionState(t *testing.T) {
 		t.Errorf("initial state = %v, want New", state)
 	}
 }
+
+func TestGetStats(t *testing.T) {
+	pair, err := NewTestPeerPair("testpassword")
+	if err != nil {
+		t.Fatalf("NewTestPeerPair failed: %v", err)
+	}
+	defer pair.Close()
+
+	received := make(chan bool, 1)
+	pair.ClientChannel.OnData(func(data []byte) {
+		received <- true
+	})
+	if err := pair.HostChannel.SendData([]byte("stats")); err != nil {
+		t.Fatalf("SendData failed: %v", err)
+	}
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for data")
+	}
+
+	stats := pair.HostPeer.GetStats()
+	if stats.BytesSent == 0 || stats.BytesReceived == 0 {
+		t.Errorf("bytes sent/received = %d/%d, want both > 0", stats.BytesSent, stats.BytesReceived)
+	}
+	if stats.MessagesSent == 0 {
+		t.Error("MessagesSent = 0, want > 0")
+	}
+	if stats.CandidatePair == "" {
+		t.Error("CandidatePair is empty on a connected peer")
+	}
+	if stats.DTLSState != "connected" {
+		t.Errorf("DTLSState = %q, want connected", stats.DTLSState)
+	}
+}
+
+func TestTransportStatsCheckLoss(t *testing.T) {
+	tests := []struct {
+		sent, answered uint64
+		want           float64
+	}{
+		{0, 0, 0},
+		{10, 10, 0},
+		{10, 9, 10},
+		{4, 1, 75},
+	}
+	for _, tt := range tests {
+		s := TransportStats{ChecksSent: tt.sent, ChecksAnswered: tt.answered}
+		if got := s.CheckLoss(); got != tt.want {
+			t.Errorf("CheckLoss(%d sent, %d answered) = %v, want %v", tt.sent, tt.answered, got, tt.want)
+		}
+	}
+}
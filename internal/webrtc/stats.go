@@ -0,0 +1,69 @@
+package webrtc
+
+import (
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// TransportStats is a snapshot of the transport under a peer, parsed from
+// pion's stats report
+type TransportStats struct {
+	Time             time.Time
+	BytesSent        uint64        // On the ICE transport, including DTLS/SCTP overhead
+	BytesReceived    uint64        // On the ICE transport, including DTLS/SCTP overhead
+	MessagesSent     uint64        // Data channel messages
+	MessagesReceived uint64        // Data channel messages
+	RTT              time.Duration // Current round trip time of the active candidate pair
+	ChecksSent       uint64        // ICE connectivity checks sent on the active pair
+	ChecksAnswered   uint64        // ICE connectivity check responses received
+	ICEState         string
+	DTLSState        string
+	CandidatePair    string // As described by SelectedCandidatePair
+}
+
+// CheckLoss returns the percentage of ICE connectivity checks on the active
+// pair that went unanswered. Data channel traffic is retransmitted by SCTP,
+// so this is the closest measure of packet loss on the path.
+func (s TransportStats) CheckLoss() float64 {
+	if s.ChecksSent == 0 || s.ChecksAnswered >= s.ChecksSent {
+		return 0
+	}
+	return 100 * float64(s.ChecksSent-s.ChecksAnswered) / float64(s.ChecksSent)
+}
+
+// GetStats collects the peer's current transport stats
+func (p *Peer) GetStats() TransportStats {
+	stats := TransportStats{
+		Time:          time.Now(),
+		ICEState:      p.pc.ICEConnectionState().String(),
+		CandidatePair: p.SelectedCandidatePair(),
+	}
+	if sctp := p.pc.SCTP(); sctp != nil && sctp.Transport() != nil {
+		stats.DTLSState = sctp.Transport().State().String()
+	}
+
+	var active *webrtc.ICECandidatePairStats
+	for _, s := range p.pc.GetStats() {
+		switch s := s.(type) {
+		case webrtc.TransportStats:
+			stats.BytesSent += uint64(s.BytesSent)
+			stats.BytesReceived += uint64(s.BytesReceived)
+		case webrtc.DataChannelStats:
+			stats.MessagesSent += uint64(s.MessagesSent)
+			stats.MessagesReceived += uint64(s.MessagesReceived)
+		case webrtc.ICECandidatePairStats:
+			// The nominated pair carries the traffic
+			if s.Nominated && (active == nil || s.BytesSent > active.BytesSent) {
+				pair := s
+				active = &pair
+			}
+		}
+	}
+	if active != nil {
+		stats.RTT = time.Duration(active.CurrentRoundTripTime * float64(time.Second))
+		stats.ChecksSent = uint64(active.RequestsSent)
+		stats.ChecksAnswered = uint64(active.ResponsesReceived)
+	}
+	return stats
+}
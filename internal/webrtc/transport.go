@@ -0,0 +1,142 @@
+package webrtc
+
+import (
+	"io"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/pion/webrtc/v4"
+)
+
+// Transport carries the encrypted frames of an EncryptedChannel: a WebRTC
+// data channel, or a WebSocket through the relay for clients that can't
+// connect over WebRTC at all
+type Transport interface {
+	Send(frame []byte) error
+	Close() error
+	IsOpen() bool
+	Label() string
+	// OnMessage sets the handler for frames from the other side
+	OnMessage(handler func(frame []byte))
+	// OnClose sets the handler called once the transport closes
+	OnClose(handler func())
+}
+
+// dataChannelTransport is a Transport over a WebRTC data channel
+type dataChannelTransport struct {
+	dc *webrtc.DataChannel
+}
+
+// NewDataChannelTransport returns a Transport over dc, e.g. to move a
+// channel onto it with SwitchTransport
+func NewDataChannelTransport(dc *webrtc.DataChannel) Transport {
+	return dataChannelTransport{dc}
+}
+
+func (t dataChannelTransport) Send(frame []byte) error { return t.dc.Send(frame) }
+func (t dataChannelTransport) Close() error            { return t.dc.Close() }
+func (t dataChannelTransport) Label() string           { return t.dc.Label() }
+
+func (t dataChannelTransport) IsOpen() bool {
+	return t.dc.ReadyState() == webrtc.DataChannelStateOpen
+}
+
+func (t dataChannelTransport) OnMessage(handler func([]byte)) {
+	t.dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+		handler(msg.Data)
+	})
+}
+
+func (t dataChannelTransport) OnClose(handler func()) {
+	t.dc.OnClose(handler)
+}
+
+// WebSocketLabel is the Label of a WebSocket transport
+const WebSocketLabel = "relay-websocket"
+
+// webSocketTransport is a Transport over a WebSocket. Frames are binary
+// messages; text messages are ignored.
+type webSocketTransport struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex // gorilla/websocket allows one writer at a time
+
+	mu      sync.Mutex
+	closed  bool
+	reading bool
+	onClose func()
+}
+
+// NewWebSocketTransport returns a Transport over conn. Frames are read once
+// a message handler is set; the transport closes when reading fails.
+func NewWebSocketTransport(conn *websocket.Conn) Transport {
+	return &webSocketTransport{conn: conn}
+}
+
+func (t *webSocketTransport) Send(frame []byte) error {
+	t.mu.Lock()
+	closed := t.closed
+	t.mu.Unlock()
+	if closed {
+		return io.ErrClosedPipe
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	return t.conn.WriteMessage(websocket.BinaryMessage, frame)
+}
+
+// Close closes the connection; the read loop then ends and calls the
+// close handler, as a data channel does
+func (t *webSocketTransport) Close() error {
+	return t.conn.Close()
+}
+
+func (t *webSocketTransport) IsOpen() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return !t.closed
+}
+
+func (t *webSocketTransport) Label() string { return WebSocketLabel }
+
+func (t *webSocketTransport) OnMessage(handler func([]byte)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.reading {
+		return
+	}
+	t.reading = true
+	go t.readLoop(handler)
+}
+
+// OnClose sets the close handler. If the transport already closed, it is
+// called at once.
+func (t *webSocketTransport) OnClose(handler func()) {
+	t.mu.Lock()
+	t.onClose = handler
+	closed := t.closed
+	t.mu.Unlock()
+	if closed && handler != nil {
+		go handler()
+	}
+}
+
+func (t *webSocketTransport) readLoop(handler func([]byte)) {
+	for {
+		msgType, data, err := t.conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		if msgType == websocket.BinaryMessage {
+			handler(data)
+		}
+	}
+	_ = t.conn.Close()
+
+	t.mu.Lock()
+	t.closed = true
+	onClose := t.onClose
+	t.mu.Unlock()
+	if onClose != nil {
+		onClose()
+	}
+}
@@ -0,0 +1,352 @@
+package webrtc
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/artpar/terminal-tunnel/internal/protocol"
+)
+
+// webSocketPair returns both ends of a WebSocket connection
+func webSocketPair(t *testing.T) (server, client *websocket.Conn) {
+	t.Helper()
+	accepted := make(chan *websocket.Conn, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Upgrade: %v", err)
+			return
+		}
+		accepted <- conn
+	}))
+	t.Cleanup(ts.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	return <-accepted, client
+}
+
+func TestWebSocketTransportChannel(t *testing.T) {
+	var key [32]byte
+	copy(key[:], "websocket transport test key....")
+	serverConn, clientConn := webSocketPair(t)
+	host := NewTransportChannel(NewWebSocketTransport(serverConn), &key)
+	client := NewTransportChannel(NewWebSocketTransport(clientConn), &key)
+
+	if !host.Ready() || host.Label() != WebSocketLabel {
+		t.Fatalf("Ready() = %v, Label() = %q; want an open %s channel", host.Ready(), host.Label(), WebSocketLabel)
+	}
+
+	received := make(chan string, 1)
+	host.OnData(func(data []byte) {
+		received <- string(data)
+	})
+	if err := client.SendData([]byte("ls -la\n")); err != nil {
+		t.Fatalf("SendData: %v", err)
+	}
+	select {
+	case got := <-received:
+		if got != "ls -la\n" {
+			t.Errorf("host got %q, want the client's input", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no data over the WebSocket")
+	}
+
+	// Closing one side closes the other, as with a data channel
+	closed := make(chan struct{})
+	host.OnClose(func() {
+		close(closed)
+	})
+	_ = client.Close()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("host channel not closed after the client closed")
+	}
+	if host.Ready() {
+		t.Error("Ready() = true after close")
+	}
+	if err := host.SendData([]byte("x")); err == nil {
+		t.Error("SendData after close succeeded")
+	}
+}
+
+// memTransport is one end of an in-memory Transport pair. Frames are
+// delivered in order on a goroutine, as a data channel delivers them.
+type memTransport struct {
+	peer   *memTransport
+	inbox  chan []byte
+	closed chan struct{} // Shared by both ends
+	once   *sync.Once
+
+	mu      sync.Mutex
+	onClose func()
+}
+
+func newMemTransportPair() (*memTransport, *memTransport) {
+	closed := make(chan struct{})
+	once := &sync.Once{}
+	a := &memTransport{inbox: make(chan []byte, 64), closed: closed, once: once}
+	b := &memTransport{inbox: make(chan []byte, 64), closed: closed, once: once}
+	a.peer, b.peer = b, a
+	return a, b
+}
+
+func (t *memTransport) Send(frame []byte) error {
+	select {
+	case <-t.closed:
+		return io.ErrClosedPipe
+	case t.peer.inbox <- append([]byte(nil), frame...):
+		return nil
+	}
+}
+
+func (t *memTransport) Close() error {
+	t.once.Do(func() {
+		close(t.closed)
+		for _, end := range []*memTransport{t, t.peer} {
+			end.mu.Lock()
+			handler := end.onClose
+			end.mu.Unlock()
+			if handler != nil {
+				go handler()
+			}
+		}
+	})
+	return nil
+}
+
+func (t *memTransport) IsOpen() bool {
+	select {
+	case <-t.closed:
+		return false
+	default:
+		return true
+	}
+}
+
+func (t *memTransport) Label() string { return "memory" }
+
+func (t *memTransport) OnMessage(handler func([]byte)) {
+	go func() {
+		for {
+			select {
+			case frame := <-t.inbox:
+				handler(frame)
+			case <-t.closed:
+				return
+			}
+		}
+	}()
+}
+
+func (t *memTransport) OnClose(handler func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onClose = handler
+}
+
+// newMemChannelPair returns host and client channels over an in-memory
+// transport, sharing key
+func newMemChannelPair(t *testing.T, key *[32]byte) (host, client *EncryptedChannel) {
+	t.Helper()
+	a, b := newMemTransportPair()
+	host = NewTransportChannel(a, key)
+	client = NewTransportChannel(b, key)
+	t.Cleanup(func() { _ = a.Close() })
+	return host, client
+}
+
+func TestMemTransportChannel(t *testing.T) {
+	var key [32]byte
+	key[0] = 1
+	host, client := newMemChannelPair(t, &key)
+
+	data := make(chan string, 1)
+	host.OnData(func(b []byte) { data <- string(b) })
+	resized := make(chan [2]uint16, 2)
+	host.OnResize(func(rows, cols uint16) { resized <- [2]uint16{rows, cols} })
+	host.OnSizeSync(func(rows, cols uint16) { resized <- [2]uint16{rows, cols} })
+
+	if err := client.SendData([]byte("echo hi\n")); err != nil {
+		t.Fatalf("SendData: %v", err)
+	}
+	if err := client.SendResize(40, 120); err != nil {
+		t.Fatalf("SendResize: %v", err)
+	}
+	if err := client.SendPingWithSize(41, 121); err != nil {
+		t.Fatalf("SendPingWithSize: %v", err)
+	}
+
+	timeout := time.After(5 * time.Second)
+	select {
+	case got := <-data:
+		if got != "echo hi\n" {
+			t.Errorf("host got %q, want the client's input", got)
+		}
+	case <-timeout:
+		t.Fatal("no data over the transport")
+	}
+	for _, want := range [][2]uint16{{40, 120}, {41, 121}} {
+		select {
+		case got := <-resized:
+			if got != want {
+				t.Errorf("size = %v, want %v", got, want)
+			}
+		case <-timeout:
+			t.Fatalf("size %v not received", want)
+		}
+	}
+
+	// The host answers the ping, which the client's keepalive counts
+	client.mu.Lock()
+	client.lastPongTime = time.Time{}
+	client.mu.Unlock()
+	_ = client.SendPing()
+	for {
+		client.mu.Lock()
+		got := client.lastPongTime
+		client.mu.Unlock()
+		if !got.IsZero() {
+			break
+		}
+		select {
+		case <-timeout:
+			t.Fatal("no pong over the transport")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	// Capabilities are negotiated as over a data channel
+	negotiated := make(chan protocol.Capabilities, 1)
+	client.OnCapabilities(func(caps protocol.Capabilities) { negotiated <- caps })
+	_ = client.SendCapabilities()
+	select {
+	case caps := <-negotiated:
+		if _, ok := caps[protocol.FeatureZmodem]; !ok {
+			t.Errorf("negotiated %v, want the shared features", caps)
+		}
+	case <-timeout:
+		t.Fatal("capabilities not negotiated")
+	}
+}
+
+func TestMemTransportChannelAltKey(t *testing.T) {
+	var key, altKey [32]byte
+	key[0], altKey[0] = 1, 2
+
+	// A client on the fallback key only shares the alternate key
+	a, b := newMemTransportPair()
+	t.Cleanup(func() { _ = a.Close() })
+	host := NewTransportChannel(a, &key)
+	host.SetAltKey(&altKey)
+	client := NewTransportChannel(b, &altKey)
+
+	_ = client.SendKeyPing(protocol.KeyFallback)
+	if !host.WaitForKey(5*time.Second) || !host.UseAltKey() {
+		t.Fatal("host did not select the alternate key from the key ping")
+	}
+	received := make(chan string, 1)
+	client.OnData(func(b []byte) { received <- string(b) })
+	_ = host.SendData([]byte("hello"))
+	select {
+	case got := <-received:
+		if got != "hello" {
+			t.Errorf("client got %q, want hello", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("client could not read the host's reply")
+	}
+}
+
+func TestMemTransportChannelClose(t *testing.T) {
+	var key [32]byte
+	host, client := newMemChannelPair(t, &key)
+
+	closed := make(chan struct{})
+	host.OnClose(func() { close(closed) })
+	_ = client.Close()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("host not closed after the client closed")
+	}
+	if host.Ready() {
+		t.Error("Ready() = true after close")
+	}
+	if err := host.SendData([]byte("x")); err == nil {
+		t.Error("SendData after close succeeded")
+	}
+}
+
+func TestSwitchTransport(t *testing.T) {
+	var key [32]byte
+	oldHost, oldClient := newMemTransportPair()
+	newHost, newClient := newMemTransportPair()
+	t.Cleanup(func() {
+		_ = oldHost.Close()
+		_ = newHost.Close()
+	})
+	host := NewTransportChannel(oldHost, &key)
+	client := NewTransportChannel(oldClient, &key)
+
+	closed := make(chan struct{})
+	host.OnClose(func() { close(closed) })
+	received := make(chan string, 2)
+	host.OnData(func(b []byte) { received <- string(b) })
+
+	host.SwitchTransport(newHost)
+	client.SwitchTransport(newClient)
+	if host.transport() != Transport(newHost) {
+		t.Fatal("host still sends on the old transport")
+	}
+
+	// A frame still on its way over the old transport arrives
+	bufp, frame, err := encodeFrame(protocol.NewDataMessage([]byte("late")), &key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = oldClient.Send(frame)
+	putFrame(bufp, frame)
+	_ = client.SendData([]byte("new"))
+	got := map[string]bool{}
+	for len(got) < 2 {
+		select {
+		case data := <-received:
+			got[data] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("host got %v, want late and new", got)
+		}
+	}
+	if !got["late"] || !got["new"] {
+		t.Errorf("host got %v, want late and new", got)
+	}
+
+	// Closing the old transport leaves the channel open
+	_ = oldHost.Close()
+	select {
+	case <-closed:
+		t.Fatal("channel closed with its old transport")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if !host.Ready() {
+		t.Error("Ready() = false after the old transport closed")
+	}
+
+	_ = newClient.Close()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("channel not closed with its current transport")
+	}
+}
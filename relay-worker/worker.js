@@ -56,7 +56,7 @@ function getLandingPage(clientUrl) {
     <h1>Terminal Tunnel</h1>
     <p class="tagline">P2P terminal sharing with E2E encryption</p>
     <form class="code-form" onsubmit="go(event)">
-      <input type="text" id="code" maxlength="8" placeholder="CODE" required>
+      <input type="text" id="code" maxlength="33" placeholder="CODE" required>
       <button type="submit">Connect</button>
     </form>
     <div class="divider">— or host —</div>
@@ -190,6 +190,18 @@ async function getICEServers(env) {
   return servers;
 }
 
+// A requested code (tt start --code): 8-32 letters, digits and inner
+// hyphens, not ending in V, which marks viewer codes. Returns the code in
+// upper case, or null if it is not allowed. Matches
+// signaling.NormalizeRequestedCode.
+function normalizeRequestedCode(code) {
+  if (typeof code !== 'string') return null;
+  code = code.trim().toUpperCase();
+  if (code.length < 8 || code.length > 32) return null;
+  if (!/^[A-Z0-9][A-Z0-9-]*[A-Z0-9]$/.test(code) || code.endsWith('V')) return null;
+  return code;
+}
+
 function generateCode() {
   let code = '';
   for (let i = 0; i < CODE_LENGTH; i++) {
@@ -198,12 +210,37 @@ function generateCode() {
   return code;
 }
 
+// Random opaque owner token for PUT/PATCH/DELETE
+function generateDeleteToken() {
+  const bytes = crypto.getRandomValues(new Uint8Array(16));
+  return Array.from(bytes, b => b.toString(16).padStart(2, '0')).join('');
+}
+
+// Look up a session and check the X-Delete-Token header against its owner token.
+// Sessions created before tokens existed (NULL token) stay mutable.
+async function checkSessionOwner(env, request, code) {
+  let row;
+  try {
+    row = await env.DB.prepare(
+      'SELECT code, delete_token FROM sessions WHERE code = ?'
+    ).bind(code).first();
+  } catch (e) {
+    if (!e.message?.includes('no such column')) throw e;
+    row = await env.DB.prepare(
+      'SELECT code FROM sessions WHERE code = ?'
+    ).bind(code).first();
+  }
+  if (!row) return { exists: false, allowed: false };
+  if (!row.delete_token) return { exists: true, allowed: true };
+  return { exists: true, allowed: request.headers.get('X-Delete-Token') === row.delete_token };
+}
+
 function getCorsHeaders(request) {
   const origin = request.headers.get('Origin');
   return {
     'Access-Control-Allow-Origin': origin || '*',
-    'Access-Control-Allow-Methods': 'GET, POST, PUT, PATCH, OPTIONS',
-    'Access-Control-Allow-Headers': 'Content-Type',
+    'Access-Control-Allow-Methods': 'GET, POST, PUT, PATCH, DELETE, OPTIONS',
+    'Access-Control-Allow-Headers': 'Content-Type, X-Delete-Token',
   };
 }
 
@@ -387,7 +424,7 @@ export default {
           return rateLimitResponse(corsHeaders, rateCheck.reset);
         }
 
-        const { sdp, salt, viewer_sdp, viewer_key } = await request.json();
+        const { sdp, salt, viewer_sdp, viewer_key, code: requested } = await request.json();
         if (!sdp) {
           return new Response(JSON.stringify({ error: 'SDP required' }), {
             status: 400,
@@ -395,25 +432,58 @@ export default {
           });
         }
 
-        const code = generateCode();
         const now = Math.floor(Date.now() / 1000);
+        let code = generateCode();
+        if (requested) {
+          code = normalizeRequestedCode(requested);
+          if (!code) {
+            return new Response(JSON.stringify({ error: 'Session code must be 8-32 letters, digits and hyphens, not ending in V' }), {
+              status: 400,
+              headers: { ...corsHeaders, 'Content-Type': 'application/json' }
+            });
+          }
+          // Free the code if its session expired but was not cleaned up yet
+          await env.DB.prepare(
+            'DELETE FROM sessions WHERE (code = ? OR code = ?) AND created_at < ?'
+          ).bind(code, code + 'V', now - EXPIRY_SECONDS).run();
+          const taken = await env.DB.prepare(
+            'SELECT code FROM sessions WHERE code = ? OR code = ?'
+          ).bind(code, code + 'V').first();
+          if (taken) {
+            return new Response(JSON.stringify({ error: 'Session code is taken' }), {
+              status: 409,
+              headers: { ...corsHeaders, 'Content-Type': 'application/json' }
+            });
+          }
+        }
 
-        await env.DB.prepare(
-          'INSERT INTO sessions (code, sdp, salt, created_at) VALUES (?, ?, ?, ?)'
-        ).bind(code, sdp, salt, now).run();
+        const deleteToken = generateDeleteToken();
+
+        const insert = () => env.DB.prepare(
+          'INSERT INTO sessions (code, sdp, salt, delete_token, created_at) VALUES (?, ?, ?, ?, ?)'
+        ).bind(code, sdp, salt, deleteToken, now).run();
+        try {
+          await insert();
+        } catch (e) {
+          // Add the column on first use after upgrading
+          if (!e.message?.includes('no column named delete_token')) throw e;
+          await env.DB.prepare('ALTER TABLE sessions ADD COLUMN delete_token TEXT').run();
+          await insert();
+        }
 
         // Generate ICE servers with time-window based credentials
         // All requests in the same hour get the same credentials
         const iceServers = await getICEServers(env);
 
-        const response = { code, expires_in: EXPIRY_SECONDS, iceServers };
+        const response = { code, delete_token: deleteToken, expires_in: EXPIRY_SECONDS, iceServers };
 
         // If viewer session requested, create it with V suffix
         if (viewer_sdp && viewer_key) {
           const viewerCode = code + 'V';
+          // The host's token lets it replace the viewer offer for each new viewer
           await env.DB.prepare(
-            'INSERT INTO sessions (code, sdp, key, read_only, created_at) VALUES (?, ?, ?, 1, ?)'
-          ).bind(viewerCode, viewer_sdp, viewer_key, now).run();
+            'INSERT INTO sessions (code, sdp, key, read_only, delete_token, created_at) VALUES (?, ?, ?, 1, ?, ?)'
+          ).bind(viewerCode, viewer_sdp, viewer_key, deleteToken, now).run();
           response.viewer_code = viewerCode;
         }
 
@@ -423,7 +493,7 @@ export default {
       }
 
       // GET /session/{code} - get session SDP
-      const sessionMatch = path.match(/^\/session\/([A-Z0-9]+)$/i);
+      const sessionMatch = path.match(/^\/session\/([A-Z0-9-]+)$/i);
       if (sessionMatch && request.method === 'GET') {
         // Rate limit session lookups (brute force protection)
         const clientIP = getClientIP(request);
@@ -473,27 +543,32 @@ export default {
       }
 
       // PUT /session/{code} - update session (for reconnection)
-      const updateMatch = path.match(/^\/session\/([A-Z0-9]+)$/i);
+      const updateMatch = path.match(/^\/session\/([A-Z0-9-]+)$/i);
       if (updateMatch && request.method === 'PUT') {
         const code = updateMatch[1].toUpperCase();
         const { sdp, salt } = await request.json();
 
-        const existing = await env.DB.prepare(
-          'SELECT code FROM sessions WHERE code = ?'
-        ).bind(code).first();
-
-        if (!existing) {
+        const owner = await checkSessionOwner(env, request, code);
+        if (!owner.exists) {
           return new Response(JSON.stringify({ error: 'Session not found' }), {
             status: 404,
             headers: { ...corsHeaders, 'Content-Type': 'application/json' }
           });
         }
 
+        if (!owner.allowed) {
+          return new Response(JSON.stringify({ error: 'Forbidden' }), {
+            status: 403,
+            headers: { ...corsHeaders, 'Content-Type': 'application/json' }
+          });
+        }
+
         const now = Math.floor(Date.now() / 1000);
-        // Clear answer when offer is updated - old answer won't work with new offer
+        // Clear answer when offer is updated - old answer won't work with new offer.
+        // Viewer sessions have no salt, so one left out is kept.
         await env.DB.prepare(
-          'UPDATE sessions SET sdp = ?, salt = ?, answer = NULL, created_at = ? WHERE code = ?'
-        ).bind(sdp, salt, now, code).run();
+          'UPDATE sessions SET sdp = ?, salt = COALESCE(?, salt), answer = NULL, created_at = ? WHERE code = ?'
+        ).bind(sdp, salt || null, now, code).run();
 
         return new Response(JSON.stringify({ status: 'ok' }), {
           headers: { ...corsHeaders, 'Content-Type': 'application/json' }
@@ -501,21 +576,25 @@ export default {
       }
 
       // PATCH /session/{code} - heartbeat (keep session alive)
-      const heartbeatMatch = path.match(/^\/session\/([A-Z0-9]+)$/i);
+      const heartbeatMatch = path.match(/^\/session\/([A-Z0-9-]+)$/i);
       if (heartbeatMatch && request.method === 'PATCH') {
         const code = heartbeatMatch[1].toUpperCase();
 
-        const existing = await env.DB.prepare(
-          'SELECT code FROM sessions WHERE code = ?'
-        ).bind(code).first();
-
-        if (!existing) {
+        const owner = await checkSessionOwner(env, request, code);
+        if (!owner.exists) {
           return new Response(JSON.stringify({ error: 'Session not found' }), {
             status: 404,
             headers: { ...corsHeaders, 'Content-Type': 'application/json' }
           });
         }
 
+        if (!owner.allowed) {
+          return new Response(JSON.stringify({ error: 'Forbidden' }), {
+            status: 403,
+            headers: { ...corsHeaders, 'Content-Type': 'application/json' }
+          });
+        }
+
         const now = Math.floor(Date.now() / 1000);
         await env.DB.prepare(
           'UPDATE sessions SET created_at = ? WHERE code = ?'
@@ -526,8 +605,38 @@ export default {
         });
       }
 
+      // DELETE /session/{code} - remove session on host shutdown
+      const deleteMatch = path.match(/^\/session\/([A-Z0-9-]+)$/i);
+      if (deleteMatch && request.method === 'DELETE') {
+        const code = deleteMatch[1].toUpperCase();
+
+        const owner = await checkSessionOwner(env, request, code);
+        if (!owner.exists) {
+          return new Response(JSON.stringify({ error: 'Session not found' }), {
+            status: 404,
+            headers: { ...corsHeaders, 'Content-Type': 'application/json' }
+          });
+        }
+
+        if (!owner.allowed) {
+          return new Response(JSON.stringify({ error: 'Forbidden' }), {
+            status: 403,
+            headers: { ...corsHeaders, 'Content-Type': 'application/json' }
+          });
+        }
+
+        // Remove the paired viewer session too
+        await env.DB.prepare(
+          'DELETE FROM sessions WHERE code = ? OR code = ?'
+        ).bind(code, code + 'V').run();
+
+        return new Response(JSON.stringify({ status: 'ok' }), {
+          headers: { ...corsHeaders, 'Content-Type': 'application/json' }
+        });
+      }
+
       // POST /session/{code}/answer - submit answer
-      const answerPostMatch = path.match(/^\/session\/([A-Z0-9]+)\/answer$/i);
+      const answerPostMatch = path.match(/^\/session\/([A-Z0-9-]+)\/answer$/i);
       if (answerPostMatch && request.method === 'POST') {
         // Rate limit answer submissions
         const clientIP = getClientIP(request);
@@ -560,7 +669,7 @@ export default {
       }
 
       // GET /session/{code}/answer - poll for answer
-      const answerGetMatch = path.match(/^\/session\/([A-Z0-9]+)\/answer$/i);
+      const answerGetMatch = path.match(/^\/session\/([A-Z0-9-]+)\/answer$/i);
       if (answerGetMatch && request.method === 'GET') {
         const code = answerGetMatch[1].toUpperCase();
         const session = await env.DB.prepare(